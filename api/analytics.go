@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"telegram-archive-bot/storage"
+)
+
+const (
+	// maxAnalyticsDays bounds the window of GET /api/analytics
+	maxAnalyticsDays = 90
+	// maxAnalyticsLimit bounds the entries of each analytics list
+	maxAnalyticsLimit = 1000
+)
+
+// handleAnalytics returns the domain analytics of converted data over the
+// last ?days=N days (default 7), with up to ?limit=N entries per list (default 50)
+func (s *Server) handleAnalytics(w http.ResponseWriter, r *http.Request) {
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 || parsed > maxAnalyticsDays {
+			s.writeError(w, http.StatusBadRequest, "days must be between 1 and 90")
+			return
+		}
+		days = parsed
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 || parsed > maxAnalyticsLimit {
+			s.writeError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = parsed
+	}
+
+	analytics, err := s.taskStore.GetDomainAnalytics(time.Now().AddDate(0, 0, -days), limit)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to compute domain analytics")
+		s.writeError(w, http.StatusInternalServerError, "failed to compute domain analytics")
+		return
+	}
+
+	if analytics.TopDomains == nil {
+		analytics.TopDomains = []storage.DomainCount{}
+	}
+	if analytics.NewDomains == nil {
+		analytics.NewDomains = []storage.DomainCount{}
+	}
+	if analytics.Overlaps == nil {
+		analytics.Overlaps = []storage.ArchiveOverlap{}
+	}
+	s.writeJSON(w, http.StatusOK, analytics)
+}
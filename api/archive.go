@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// archiveMetadataResponse is the body of GET /api/tasks/{id}/archive
+type archiveMetadataResponse struct {
+	TaskID   string                 `json:"task_id"`
+	FileName string                 `json:"file_name"`
+	FileSize int64                  `json:"file_size"`
+	Ratio    float64                `json:"ratio"`
+	Archive  *utils.ArchiveMetadata `json:"archive"`
+}
+
+// handleArchiveMetadata exports the inspected archive metadata of a task,
+// addressed by short ID, full ID or an unambiguous ID prefix
+func (s *Server) handleArchiveMetadata(w http.ResponseWriter, r *http.Request) {
+	taskID, err := s.taskStore.ResolveTaskID(r.PathValue("id"))
+	switch {
+	case errors.Is(err, storage.ErrTaskNotFound):
+		s.writeError(w, http.StatusNotFound, "task not found")
+		return
+	case errors.Is(err, storage.ErrAmbiguousTaskID):
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		s.logger.WithError(err).Warn("Failed to resolve task ID")
+		s.writeError(w, http.StatusInternalServerError, "failed to resolve task ID")
+		return
+	}
+
+	task, err := s.taskStore.GetByID(taskID)
+	if err != nil {
+		s.logger.WithError(err).WithField("task_id", taskID).Warn("Failed to load task")
+		s.writeError(w, http.StatusInternalServerError, "failed to load task")
+		return
+	}
+
+	meta, err := s.taskStore.GetArchiveMetadata(taskID)
+	if err != nil {
+		s.logger.WithError(err).WithField("task_id", taskID).Warn("Failed to load archive metadata")
+		s.writeError(w, http.StatusInternalServerError, "failed to load archive metadata")
+		return
+	}
+	if meta == nil {
+		s.writeError(w, http.StatusNotFound, "archive was not inspected")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, archiveMetadataResponse{
+		TaskID:   task.ID,
+		FileName: task.FileName,
+		FileSize: task.FileSize,
+		Ratio:    meta.Ratio(),
+		Archive:  meta,
+	})
+}
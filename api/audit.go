@@ -0,0 +1,213 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+const (
+	// defaultAuditLimit is the page size of the audit endpoints without ?limit=
+	defaultAuditLimit = 50
+	// maxAuditLimit bounds ?limit= of the audit endpoints
+	maxAuditLimit = 500
+)
+
+// auditPage is the paging and sorting shared by the audit endpoints
+type auditPage struct {
+	sortBy    string
+	ascending bool
+	limit     int
+	offset    int
+	since     time.Time
+	until     time.Time
+}
+
+// adminAuditResponse is the body of GET /api/audit/admin
+type adminAuditResponse struct {
+	Total   int                       `json:"total"`
+	Limit   int                       `json:"limit"`
+	Offset  int                       `json:"offset"`
+	Entries []storage.AdminAuditEntry `json:"entries"`
+}
+
+// securityAuditResponse is the body of GET /api/audit/security
+type securityAuditResponse struct {
+	Total  int                      `json:"total"`
+	Limit  int                      `json:"limit"`
+	Offset int                      `json:"offset"`
+	Events []*storage.SecurityEvent `json:"events"`
+}
+
+// parseAuditPage reads ?sort=, ?order=asc|desc, ?limit=, ?offset= and the
+// RFC 3339 ?since= and ?until= bounds
+func parseAuditPage(query url.Values) (auditPage, error) {
+	page := auditPage{sortBy: query.Get("sort"), limit: defaultAuditLimit}
+
+	switch strings.ToLower(query.Get("order")) {
+	case "", "desc":
+	case "asc":
+		page.ascending = true
+	default:
+		return page, fmt.Errorf("order must be asc or desc")
+	}
+
+	if v := query.Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 || parsed > maxAuditLimit {
+			return page, fmt.Errorf("limit must be between 1 and %d", maxAuditLimit)
+		}
+		page.limit = parsed
+	}
+	if v := query.Get("offset"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			return page, fmt.Errorf("offset must be a non-negative number")
+		}
+		page.offset = parsed
+	}
+
+	for name, bound := range map[string]*time.Time{"since": &page.since, "until": &page.until} {
+		if v := query.Get(name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return page, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+			*bound = parsed
+		}
+	}
+	return page, nil
+}
+
+// parseUserID reads the optional ?user= Telegram user ID
+func parseUserID(query url.Values) (int64, error) {
+	v := query.Get("user")
+	if v == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("user must be a numeric Telegram user ID")
+	}
+	return id, nil
+}
+
+// handleAdminAudit pages through the admin audit trail, filtered by ?user=,
+// ?action=, ?resource= (substring), ?result= and ?session=, sorted by ?sort= (timestamp,
+// user, action, result or duration)
+func (s *Server) handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, err := parseAuditPage(query)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID, err := parseUserID(query)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filters := storage.AuditFilters{
+		UserID:    userID,
+		Action:    strings.ToUpper(query.Get("action")),
+		Resource:  query.Get("resource"),
+		Result:    query.Get("result"),
+		SessionID: query.Get("session"),
+		StartTime: page.since,
+		EndTime:   page.until,
+		SortBy:    page.sortBy,
+		Ascending: page.ascending,
+		Limit:     page.limit,
+		Offset:    page.offset,
+	}
+
+	audit := storage.NewAdminAuditLogger(s.taskStore.GetDB(), s.logger)
+	entries, err := audit.GetAuditEntries(filters)
+	if err != nil {
+		if errors.Is(err, storage.ErrUnsupportedSort) {
+			s.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.logger.WithError(err).Warn("Failed to query admin audit trail")
+		s.writeError(w, http.StatusInternalServerError, "failed to query admin audit trail")
+		return
+	}
+	total, err := audit.CountAuditEntries(filters)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to count admin audit entries")
+		s.writeError(w, http.StatusInternalServerError, "failed to query admin audit trail")
+		return
+	}
+
+	response := adminAuditResponse{Total: total, Limit: page.limit, Offset: page.offset, Entries: entries}
+	if response.Entries == nil {
+		response.Entries = []storage.AdminAuditEntry{}
+	}
+	s.writeJSON(w, http.StatusOK, response)
+}
+
+// handleSecurityAudit pages through the security events, filtered by ?task=,
+// ?type=, ?action=, ?user= and ?min_threat= (a level name such as HIGH),
+// sorted by ?sort= (timestamp, threat, type or user)
+func (s *Server) handleSecurityAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, err := parseAuditPage(query)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID, err := parseUserID(query)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filters := storage.SecurityEventFilters{
+		TaskID:      query.Get("task"),
+		EventType:   storage.SecurityEventType(query.Get("type")),
+		ActionTaken: storage.SecurityAction(query.Get("action")),
+		UserID:      userID,
+		StartTime:   page.since,
+		EndTime:     page.until,
+		SortBy:      page.sortBy,
+		Ascending:   page.ascending,
+		Limit:       page.limit,
+		Offset:      page.offset,
+	}
+	if v := query.Get("min_threat"); v != "" {
+		level, err := utils.ParseThreatLevel(v)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		filters.MinThreatLevel = level
+	}
+
+	audit := storage.NewSecurityAuditLogger(s.taskStore.GetDB(), s.logger)
+	events, err := audit.QuerySecurityEvents(filters)
+	if err != nil {
+		if errors.Is(err, storage.ErrUnsupportedSort) {
+			s.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.logger.WithError(err).Warn("Failed to query security events")
+		s.writeError(w, http.StatusInternalServerError, "failed to query security events")
+		return
+	}
+	total, err := audit.CountSecurityEvents(filters)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to count security events")
+		s.writeError(w, http.StatusInternalServerError, "failed to query security events")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, securityAuditResponse{Total: total, Limit: page.limit, Offset: page.offset, Events: events})
+}
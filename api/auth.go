@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// Access configures who may call the API
+type Access struct {
+	// Clients are the API keys and client certificate names with their scopes
+	Clients []utils.APIClient
+	// RequireAuth protects every route; otherwise only the audit and pprof
+	// routes need a client with their scope
+	RequireAuth bool
+	// AllowedIPs are the addresses that may connect, every address when nil
+	AllowedIPs *utils.IPAllowlist
+	// TLS serves HTTPS, verifying client certificates when it has ClientCAs
+	TLS *tls.Config
+	// Audit records every request when set
+	Audit *storage.AdminAuditLogger
+}
+
+// protectedScopes always need an authenticated client
+var protectedScopes = map[string]bool{utils.APIScopeAudit: true, utils.APIScopePprof: true}
+
+// requestInfoKey is the context key of a request's *requestInfo
+type requestInfoKey struct{}
+
+// requestInfo is filled in while a request is handled, for its audit entry
+type requestInfo struct {
+	client string
+	// probe marks liveness probes, which are too frequent to audit
+	probe bool
+}
+
+// SetAccess applies authentication, scopes, the IP allowlist, TLS and
+// request auditing; call it before Start
+func (s *Server) SetAccess(access Access) {
+	s.access = access
+}
+
+// SetClients replaces the API keys and client certificate names, e.g. after
+// a secret holding API keys was rotated
+func (s *Server) SetClients(clients []utils.APIClient) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	s.access.Clients = clients
+}
+
+// clients returns the current API clients
+func (s *Server) clients() []utils.APIClient {
+	s.clientsMu.RLock()
+	defer s.clientsMu.RUnlock()
+	return s.access.Clients
+}
+
+// AllowsScope reports whether any client may use the scope, e.g. to decide
+// whether the pprof routes are worth serving
+func (s *Server) AllowsScope(scope string) bool {
+	for _, client := range s.clients() {
+		if client.HasScope(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// route registers a handler that needs a client with the scope
+func (s *Server) route(pattern, scope string, handler http.HandlerFunc) {
+	s.mux.Handle(pattern, s.requireScope(scope, handler))
+}
+
+// requireScope rejects requests whose client is unknown (401) or lacks the
+// scope (403); unprotected scopes are open unless RequireAuth is set
+func (s *Server) requireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.access.RequireAuth && !protectedScopes[scope] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client, ok := s.authenticate(r)
+		if !ok {
+			s.logger.WithField("path", r.URL.Path).WithField("remote_addr", r.RemoteAddr).Warn("Rejected unauthenticated API request")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			s.writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+			info.client = client.Name
+		}
+		if !client.HasScope(scope) {
+			s.logger.WithField("path", r.URL.Path).WithField("client", client.Name).Warn("Rejected API request outside the client's scopes")
+			s.writeError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate identifies the client by its bearer key, or else by the
+// common name of its verified client certificate
+func (s *Server) authenticate(r *http.Request) (utils.APIClient, bool) {
+	if given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		for _, client := range s.clients() {
+			if client.Key != "" && subtle.ConstantTimeCompare([]byte(given), []byte(client.Key)) == 1 {
+				return client, true
+			}
+		}
+		return utils.APIClient{}, false
+	}
+
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		for _, client := range s.clients() {
+			if client.CertName != "" && client.CertName == name {
+				return client, true
+			}
+		}
+	}
+	return utils.APIClient{}, false
+}
+
+// statusRecorder remembers the status written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush keeps streaming responses such as CPU profiles working
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// guard rejects addresses outside the allowlist and audits every request
+func (s *Server) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		if s.access.AllowedIPs.Allows(r.RemoteAddr) {
+			next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)))
+		} else {
+			s.logger.WithField("remote_addr", r.RemoteAddr).Warn("Rejected API request from an address outside API_ALLOWED_IPS")
+			s.writeError(recorder, http.StatusForbidden, "forbidden")
+		}
+
+		if s.access.Audit == nil || info.probe {
+			return
+		}
+		result := "SUCCESS"
+		switch {
+		case recorder.status == http.StatusUnauthorized || recorder.status == http.StatusForbidden:
+			result = "BLOCKED"
+		case recorder.status >= 400:
+			result = "FAILED"
+		}
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		s.access.Audit.LogAPIRequest(storage.APIRequest{
+			Client:    info.client,
+			Method:    r.Method,
+			Resource:  r.URL.Path,
+			IPAddress: ip,
+			UserAgent: r.UserAgent(),
+			Status:    recorder.status,
+			Result:    result,
+			Duration:  time.Since(start),
+		})
+	})
+}
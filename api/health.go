@@ -0,0 +1,114 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"telegram-archive-bot/storage"
+)
+
+// maxHealthHistoryHours bounds the window of the health history endpoints
+const maxHealthHistoryHours = 24 * 7
+
+// healthChangesResponse is the body of GET /api/health/changes
+type healthChangesResponse struct {
+	Since   time.Time                    `json:"since"`
+	Changes []storage.HealthStatusChange `json:"changes"`
+}
+
+// healthRecord is a stored health check or diagnostic suite
+type healthRecord struct {
+	ID         int64                           `json:"id"`
+	Kind       string                          `json:"kind"`
+	Status     string                          `json:"status"`
+	CheckedAt  time.Time                       `json:"checked_at"`
+	Components []storage.HealthComponentStatus `json:"components"`
+	Result     interface{}                     `json:"result,omitempty"`
+}
+
+// healthRecordsResponse is the body of GET /api/health/records
+type healthRecordsResponse struct {
+	Kind    string         `json:"kind"`
+	Since   time.Time      `json:"since"`
+	Records []healthRecord `json:"records"`
+}
+
+// healthHistorySince reads ?hours=N (default 24) as the start of the window
+func healthHistorySince(r *http.Request) (time.Time, bool) {
+	hours := 24
+	if v := r.URL.Query().Get("hours"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 || parsed > maxHealthHistoryHours {
+			return time.Time{}, false
+		}
+		hours = parsed
+	}
+	return time.Now().Add(-time.Duration(hours) * time.Hour), true
+}
+
+// handleHealthChanges lists component status changes of both health checks
+// and diagnostics over the last ?hours=N hours
+func (s *Server) handleHealthChanges(w http.ResponseWriter, r *http.Request) {
+	since, ok := healthHistorySince(r)
+	if !ok {
+		s.writeError(w, http.StatusBadRequest, "hours must be between 1 and 168")
+		return
+	}
+
+	changes, err := s.taskStore.GetAllHealthStatusChanges(since)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to load health status changes")
+		s.writeError(w, http.StatusInternalServerError, "failed to load health status changes")
+		return
+	}
+
+	response := healthChangesResponse{Since: since, Changes: changes}
+	if response.Changes == nil {
+		response.Changes = []storage.HealthStatusChange{}
+	}
+	s.writeJSON(w, http.StatusOK, response)
+}
+
+// handleHealthRecords returns the stored records of ?kind= (health_check by
+// default) over the last ?hours=N hours; ?full=1 includes the original results
+func (s *Server) handleHealthRecords(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("kind")
+	switch kind {
+	case "":
+		kind = storage.HealthRecordCheck
+	case storage.HealthRecordCheck, storage.HealthRecordDiagnostics:
+	default:
+		s.writeError(w, http.StatusBadRequest, "kind must be health_check or diagnostics")
+		return
+	}
+	since, ok := healthHistorySince(r)
+	if !ok {
+		s.writeError(w, http.StatusBadRequest, "hours must be between 1 and 168")
+		return
+	}
+	full := r.URL.Query().Get("full") == "1"
+
+	records, err := s.taskStore.GetHealthRecords(kind, since, full)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to load health records")
+		s.writeError(w, http.StatusInternalServerError, "failed to load health records")
+		return
+	}
+
+	response := healthRecordsResponse{Kind: kind, Since: since, Records: make([]healthRecord, 0, len(records))}
+	for _, record := range records {
+		item := healthRecord{
+			ID:         record.ID,
+			Kind:       record.Kind,
+			Status:     record.Status,
+			CheckedAt:  record.CheckedAt,
+			Components: record.Components,
+		}
+		if len(record.Result) > 0 {
+			item.Result = record.Result
+		}
+		response.Records = append(response.Records, item)
+	}
+	s.writeJSON(w, http.StatusOK, response)
+}
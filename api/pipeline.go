@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+
+	"telegram-archive-bot/monitoring"
+	"telegram-archive-bot/utils"
+)
+
+// EnablePipelineGraph serves GET /api/pipeline/graph from build, which
+// snapshots the orchestrator's pipeline; the process that runs it enables it
+func (s *Server) EnablePipelineGraph(build func() (*monitoring.PipelineGraph, error)) {
+	s.route("GET /api/pipeline/graph", utils.APIScopeReports, func(w http.ResponseWriter, r *http.Request) {
+		s.handlePipelineGraph(w, r, build)
+	})
+}
+
+// handlePipelineGraph renders where the work in the pipeline sits as
+// ?format=dot (default), mermaid or json
+func (s *Server) handlePipelineGraph(w http.ResponseWriter, r *http.Request, build func() (*monitoring.PipelineGraph, error)) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = monitoring.GraphFormatDOT
+	}
+	if format != "json" && format != monitoring.GraphFormatDOT && format != monitoring.GraphFormatMermaid {
+		s.writeError(w, http.StatusBadRequest, "format must be dot, mermaid or json")
+		return
+	}
+
+	graph, err := build()
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to build pipeline graph")
+		s.writeError(w, http.StatusInternalServerError, "failed to build pipeline graph")
+		return
+	}
+	if format == "json" {
+		s.writeJSON(w, http.StatusOK, graph)
+		return
+	}
+
+	text, err := graph.Render(format)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	contentType := "text/vnd.graphviz; charset=utf-8"
+	if format == monitoring.GraphFormatMermaid {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(text)); err != nil {
+		s.logger.WithError(err).Debug("Failed to write API response")
+	}
+}
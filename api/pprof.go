@@ -0,0 +1,18 @@
+package api
+
+import (
+	"net/http/pprof"
+
+	"telegram-archive-bot/utils"
+)
+
+// EnableProfiling serves the net/http/pprof endpoints under /debug/pprof/ to
+// clients with the pprof scope
+func (s *Server) EnableProfiling() {
+	s.route("GET /debug/pprof/", utils.APIScopePprof, pprof.Index)
+	s.route("GET /debug/pprof/cmdline", utils.APIScopePprof, pprof.Cmdline)
+	s.route("GET /debug/pprof/profile", utils.APIScopePprof, pprof.Profile)
+	s.route("GET /debug/pprof/symbol", utils.APIScopePprof, pprof.Symbol)
+	s.route("POST /debug/pprof/symbol", utils.APIScopePprof, pprof.Symbol)
+	s.route("GET /debug/pprof/trace", utils.APIScopePprof, pprof.Trace)
+}
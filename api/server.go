@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// shutdownTimeout bounds how long in-flight requests may finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
+// Server is the read-only HTTP API for reports and status
+type Server struct {
+	logger    *utils.Logger
+	taskStore *storage.TaskStore
+	mux       *http.ServeMux
+	access    Access
+
+	// clientsMu guards access.Clients, which SetClients replaces at runtime
+	clientsMu sync.RWMutex
+}
+
+// NewServer creates the HTTP API with its built-in routes
+func NewServer(logger *utils.Logger, taskStore *storage.TaskStore) *Server {
+	s := &Server{
+		logger:    logger,
+		taskStore: taskStore,
+		mux:       http.NewServeMux(),
+	}
+
+	s.route("GET /api/sla", utils.APIScopeReports, s.handleSLA)
+	s.route("GET /api/health/changes", utils.APIScopeReports, s.handleHealthChanges)
+	s.route("GET /api/health/records", utils.APIScopeReports, s.handleHealthRecords)
+	s.route("GET /api/tasks/{id}/archive", utils.APIScopeReports, s.handleArchiveMetadata)
+	s.route("GET /api/analytics", utils.APIScopeReports, s.handleAnalytics)
+	s.route("GET /api/audit/admin", utils.APIScopeAudit, s.handleAdminAudit)
+	s.route("GET /api/audit/security", utils.APIScopeAudit, s.handleSecurityAudit)
+
+	return s
+}
+
+// Handle registers an additional route, e.g. "GET /api/thing", that needs a
+// client with the scope
+func (s *Server) Handle(pattern, scope string, handler http.Handler) {
+	s.mux.Handle(pattern, s.requireScope(scope, handler))
+}
+
+// HandleProbe registers a liveness probe route, e.g. "GET /healthz", that
+// needs no client so watchdogs can call it; the IP allowlist still applies
+// and probes are not audited
+func (s *Server) HandleProbe(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+			info.probe = true
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+// Start serves the API on addr until ctx is cancelled
+func (s *Server) Start(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	server := &http.Server{
+		Handler:           s.guard(s.mux),
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         s.access.TLS,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	s.logger.WithField("addr", listener.Addr().String()).WithField("tls", s.access.TLS != nil).Info("HTTP API listening")
+	if s.access.TLS != nil {
+		// The certificates are already loaded into TLSConfig
+		err = server.ServeTLS(listener, "", "")
+	} else {
+		err = server.Serve(listener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// writeJSON sends v as an indented JSON response
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		s.logger.WithError(err).Debug("Failed to write API response")
+	}
+}
+
+// writeError sends a JSON error response
+func (s *Server) writeError(w http.ResponseWriter, status int, message string) {
+	s.writeJSON(w, status, map[string]string{"error": message})
+}
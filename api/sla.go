@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"telegram-archive-bot/storage"
+)
+
+// componentSLA is a component's availability with durations in seconds
+type componentSLA struct {
+	Component        string  `json:"component"`
+	UptimePercent    float64 `json:"uptime_percent"`
+	CoveragePercent  float64 `json:"coverage_percent"`
+	HealthySeconds   int64   `json:"healthy_seconds"`
+	DegradedSeconds  int64   `json:"degraded_seconds"`
+	UnhealthySeconds int64   `json:"unhealthy_seconds"`
+	MonitoredSeconds int64   `json:"monitored_seconds"`
+	Outages          int     `json:"outages"`
+}
+
+// slaResponse is the body of GET /api/sla
+type slaResponse struct {
+	Month      string         `json:"month"`
+	From       time.Time      `json:"from"`
+	To         time.Time      `json:"to"`
+	Components []componentSLA `json:"components"`
+}
+
+// handleSLA reports monthly component availability; ?month=YYYY-MM selects
+// the month, the current one by default
+func (s *Server) handleSLA(w http.ResponseWriter, r *http.Request) {
+	month := time.Now()
+	if v := r.URL.Query().Get("month"); v != "" {
+		parsed, err := time.ParseInLocation("2006-01", v, time.Local)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "month must be formatted as YYYY-MM")
+			return
+		}
+		month = parsed
+	}
+
+	from, to := storage.MonthRange(month)
+	report, err := s.taskStore.GetAvailabilityReport(from, to)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to build availability report")
+		s.writeError(w, http.StatusInternalServerError, "failed to build availability report")
+		return
+	}
+
+	response := slaResponse{
+		Month:      from.Format("2006-01"),
+		From:       report.From,
+		To:         report.To,
+		Components: make([]componentSLA, 0, len(report.Components)),
+	}
+	for _, c := range report.Components {
+		response.Components = append(response.Components, componentSLA{
+			Component:        c.Component,
+			UptimePercent:    c.UptimePercent,
+			CoveragePercent:  c.CoveragePercent,
+			HealthySeconds:   int64(c.Healthy.Seconds()),
+			DegradedSeconds:  int64(c.Degraded.Seconds()),
+			UnhealthySeconds: int64(c.Unhealthy.Seconds()),
+			MonitoredSeconds: int64(c.Monitored.Seconds()),
+			Outages:          c.Outages,
+		})
+	}
+	s.writeJSON(w, http.StatusOK, response)
+}
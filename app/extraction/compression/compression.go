@@ -0,0 +1,148 @@
+// Package compression selects the codec of database backups and archived
+// output files, and detects it again when they are read back.
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec names a compression format.
+type Codec string
+
+// Supported codecs.
+const (
+	None Codec = "none"
+	Gzip Codec = "gzip"
+	Zstd Codec = "zstd"
+)
+
+// Magic numbers at the start of compressed streams.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Parse returns the codec with the given name; an empty name is None.
+func Parse(name string) (Codec, error) {
+	switch c := Codec(strings.ToLower(strings.TrimSpace(name))); c {
+	case "", None:
+		return None, nil
+	case Gzip, Zstd:
+		return c, nil
+	default:
+		return "", fmt.Errorf("unknown compression codec %q (supported: none, gzip, zstd)", name)
+	}
+}
+
+// Extension returns the file name suffix of the codec.
+func (c Codec) Extension() string {
+	switch c {
+	case Gzip:
+		return ".gz"
+	case Zstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// CheckLevel validates a compression level; 0 always means the codec default.
+// gzip takes 1 (fastest) to 9, zstd 1 to 22 like the zstd command line tool.
+func (c Codec) CheckLevel(level int) error {
+	max := 0
+	switch c {
+	case Gzip:
+		max = gzip.BestCompression
+	case Zstd:
+		max = 22
+	}
+	if level < 0 || level > max {
+		return fmt.Errorf("%s compression level must be between 0 and %d, got %d", c, max, level)
+	}
+	return nil
+}
+
+// FromName returns the codec a file name's extension indicates.
+func FromName(name string) Codec {
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		return Gzip
+	case strings.HasSuffix(name, ".zst"):
+		return Zstd
+	}
+	return None
+}
+
+// NewWriter compresses what is written to w. Close flushes the compressed
+// stream but leaves w open.
+func NewWriter(w io.Writer, c Codec, level int) (io.WriteCloser, error) {
+	if err := c.CheckLevel(level); err != nil {
+		return nil, err
+	}
+	switch c {
+	case None:
+		return nopWriteCloser{w}, nil
+	case Gzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case Zstd:
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if level > 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(w, opts...)
+	}
+	return nil, fmt.Errorf("unknown compression codec %q", c)
+}
+
+// NewReader decompresses r, detecting the codec from the magic number rather
+// than the file name, so renamed files are still read correctly. Data with
+// neither magic number is returned as is.
+func NewReader(r io.Reader) (io.ReadCloser, Codec, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, None, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, zstdMagic):
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, Zstd, err
+		}
+		return zstdReadCloser{zr}, Zstd, nil
+	case bytes.HasPrefix(header, gzipMagic):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, Gzip, err
+		}
+		return gr, Gzip, nil
+	}
+	return io.NopCloser(br), None, nil
+}
+
+// nopWriteCloser passes writes through uncompressed.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// zstdReadCloser adapts zstd.Decoder, whose Close returns nothing.
+type zstdReadCloser struct {
+	*zstd.Decoder
+}
+
+func (z zstdReadCloser) Close() error {
+	z.Decoder.Close()
+	return nil
+}
@@ -0,0 +1,150 @@
+// Package browser converts credential and cookie stores copied from browser
+// profiles into the url:username:password lines the converter produces from
+// txt logs.
+package browser
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ErrUnsupportedStore is returned for SQLite files that no parser reads,
+// such as browsing history.
+var ErrUnsupportedStore = errors.New("unsupported browser store")
+
+// sqliteMagic starts every SQLite 3 database file.
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// storeNames are the file names of the stores the parsers read.
+var storeNames = map[string]bool{
+	"login data":             true, // Chromium, Chrome, Edge, Brave, Opera, ...
+	"login data for account": true, // Chromium account-scoped passwords
+	"cookies":                true, // Chromium cookies
+	"cookies.sqlite":         true, // Firefox cookies
+}
+
+// Credential is one saved login.
+type Credential struct {
+	URL      string
+	Username string
+	Password string
+}
+
+// Line renders the credential like the converter's txt log output.
+func (c Credential) Line() string {
+	return fmt.Sprintf("%s:%s:%s", c.URL, c.Username, c.Password)
+}
+
+// Result is what a parser read from one store.
+type Result struct {
+	Format      string
+	Credentials []Credential
+	Encrypted   int // Entries whose password or value the browser encrypted; they are not decrypted
+	Incomplete  int // Entries without a URL, username or password
+}
+
+// parser reads one store format from an open database.
+type parser struct {
+	format string
+	// detect reports whether the database has this format's tables
+	detect func(db *sql.DB) bool
+	parse  func(db *sql.DB) (*Result, error)
+}
+
+// parsers are tried in order; the first whose detect matches reads the store.
+var parsers = []parser{
+	{format: "chromium-logins", detect: hasChromiumLogins, parse: parseChromiumLogins},
+	{format: "chromium-cookies", detect: hasChromiumCookies, parse: parseChromiumCookies},
+	{format: "firefox-cookies", detect: hasFirefoxCookies, parse: parseFirefoxCookies},
+}
+
+// IsStoreName reports whether an archive entry is a store the parsers read.
+func IsStoreName(name string) bool {
+	// Archives made on Windows may separate folders with backslashes
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasSuffix(name, "/") {
+		return false // A folder, e.g. a stealer log's "Cookies/"
+	}
+	return storeNames[strings.ToLower(path.Base(name))]
+}
+
+// IsSQLite reports whether the file is an SQLite database.
+func IsSQLite(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, len(sqliteMagic))
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	return HasSQLiteHeader(header)
+}
+
+// HasSQLiteHeader reports whether content starts like an SQLite database.
+func HasSQLiteHeader(content []byte) bool {
+	return bytes.HasPrefix(content, sqliteMagic)
+}
+
+// Parse detects the format of a browser store and reads its credentials.
+// The file is opened read-only and is never modified.
+func Parse(path string) (*Result, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro&immutable=1")
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer db.Close()
+
+	for _, p := range parsers {
+		if !p.detect(db) {
+			continue
+		}
+		result, err := p.parse(db)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s store: %w", p.format, err)
+		}
+		result.Format = p.format
+		return result, nil
+	}
+	return nil, ErrUnsupportedStore
+}
+
+// hasColumns reports whether table exists with all of the given columns.
+func hasColumns(db *sql.DB, table string, columns ...string) bool {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%q)", table))
+	if err != nil {
+		return false
+	}
+	defer rows.Close()
+
+	found := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid        int
+			name, kind string
+			notNull    int
+			defaultV   sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &kind, &notNull, &defaultV, &pk); err != nil {
+			return false
+		}
+		found[name] = true
+	}
+	for _, column := range columns {
+		if !found[column] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package browser
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fixtureStore builds the SQLite store described by testdata/<name>.sql
+func fixtureStore(t *testing.T, name string) string {
+	t.Helper()
+	schema, err := os.ReadFile(filepath.Join("testdata", name+".sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), name+".sqlite")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("building %s: %v", name, err)
+	}
+	return path
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		fixture    string
+		format     string
+		lines      []string
+		encrypted  int
+		incomplete int
+	}{
+		{
+			fixture: "chromium_logins",
+			format:  "chromium-logins",
+			lines: []string{
+				"https://example.com/login:alice:hunter2",
+				"https://shop.example/auth:bob@shop.example:s3cret",
+			},
+			encrypted:  1,
+			incomplete: 1,
+		},
+		{
+			fixture: "chromium_cookies",
+			format:  "chromium-cookies",
+			lines: []string{
+				"https://example.com/:SID:abc123",
+				"http://shop.example/cart:cart:42",
+			},
+			encrypted:  1,
+			incomplete: 1,
+		},
+		{
+			fixture: "firefox_cookies",
+			format:  "firefox-cookies",
+			lines: []string{
+				"https://mozilla.org/:session:xyz",
+				"http://example.net/:pref:dark",
+			},
+			incomplete: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			path := fixtureStore(t, tt.fixture)
+			if !IsSQLite(path) {
+				t.Fatal("fixture not recognized as SQLite")
+			}
+
+			result, err := Parse(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Format != tt.format {
+				t.Errorf("format = %q, want %q", result.Format, tt.format)
+			}
+			var lines []string
+			for _, c := range result.Credentials {
+				lines = append(lines, c.Line())
+			}
+			if !reflect.DeepEqual(lines, tt.lines) {
+				t.Errorf("lines = %q, want %q", lines, tt.lines)
+			}
+			if result.Encrypted != tt.encrypted || result.Incomplete != tt.incomplete {
+				t.Errorf("encrypted %d, incomplete %d; want %d, %d",
+					result.Encrypted, result.Incomplete, tt.encrypted, tt.incomplete)
+			}
+		})
+	}
+}
+
+func TestParseUnsupportedStore(t *testing.T) {
+	if _, err := Parse(fixtureStore(t, "history")); !errors.Is(err, ErrUnsupportedStore) {
+		t.Fatalf("Parse(History) = %v, want ErrUnsupportedStore", err)
+	}
+}
+
+func TestIsStoreName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"Chrome/Default/Login Data", true},
+		{`Edge\Default\Login Data For Account`, true},
+		{"Chrome/Default/Network/Cookies", true},
+		{"Firefox/abcd.default-release/cookies.sqlite", true},
+		{"Cookies/", false},
+		{"Cookies/Chrome_Default.txt", false},
+		{"Chrome/Default/History", false},
+	}
+	for _, tt := range tests {
+		if got := IsStoreName(tt.name); got != tt.want {
+			t.Errorf("IsStoreName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
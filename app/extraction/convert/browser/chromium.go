@@ -0,0 +1,65 @@
+package browser
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"unicode/utf8"
+)
+
+// chromiumEncryptedPrefixes mark password values encrypted by the browser
+// (OS keychain, DPAPI or app-bound keys); only plaintext values are used.
+var chromiumEncryptedPrefixes = [][]byte{[]byte("v10"), []byte("v11"), []byte("v20")}
+
+// hasChromiumLogins detects the logins table of a Chromium "Login Data" file.
+func hasChromiumLogins(db *sql.DB) bool {
+	return hasColumns(db, "logins", "origin_url", "action_url", "username_value", "password_value")
+}
+
+// parseChromiumLogins reads the saved logins of a Chromium "Login Data" file.
+// Blacklisted sites ("never save") have no username or password and are skipped.
+func parseChromiumLogins(db *sql.DB) (*Result, error) {
+	rows, err := db.Query(`SELECT origin_url, action_url, username_value, password_value FROM logins`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &Result{}
+	for rows.Next() {
+		var origin, action, username sql.NullString
+		var password []byte
+		if err := rows.Scan(&origin, &action, &username, &password); err != nil {
+			return nil, err
+		}
+
+		url := strings.TrimSpace(origin.String)
+		if url == "" {
+			url = strings.TrimSpace(action.String)
+		}
+		user := strings.TrimSpace(username.String)
+
+		if chromiumEncrypted(password) {
+			result.Encrypted++
+			continue
+		}
+		pass := strings.TrimSpace(string(password))
+		if url == "" || user == "" || pass == "" {
+			result.Incomplete++
+			continue
+		}
+		result.Credentials = append(result.Credentials, Credential{URL: url, Username: user, Password: pass})
+	}
+	return result, rows.Err()
+}
+
+// chromiumEncrypted reports whether a password value was encrypted by the browser.
+func chromiumEncrypted(value []byte) bool {
+	for _, prefix := range chromiumEncryptedPrefixes {
+		if bytes.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	// Older Windows builds stored DPAPI blobs without a version prefix
+	return len(value) > 0 && !utf8.Valid(value)
+}
@@ -0,0 +1,87 @@
+package browser
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// hasChromiumCookies detects the cookies table of a Chromium "Cookies" file.
+func hasChromiumCookies(db *sql.DB) bool {
+	return hasColumns(db, "cookies", "host_key", "name", "value", "encrypted_value", "path", "is_secure")
+}
+
+// parseChromiumCookies reads the cookies of a Chromium "Cookies" file.
+// Chromium keeps the value in encrypted_value on every platform it can, so
+// most cookies of a recent profile are counted as encrypted.
+func parseChromiumCookies(db *sql.DB) (*Result, error) {
+	rows, err := db.Query(`SELECT host_key, path, is_secure, name, value, encrypted_value FROM cookies`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &Result{}
+	for rows.Next() {
+		var host, path, name, value sql.NullString
+		var secure sql.NullInt64
+		var encrypted []byte
+		if err := rows.Scan(&host, &path, &secure, &name, &value, &encrypted); err != nil {
+			return nil, err
+		}
+
+		if value.String == "" && len(encrypted) > 0 {
+			result.Encrypted++
+			continue
+		}
+		result.addCookie(host.String, path.String, secure.Int64 != 0, name.String, value.String)
+	}
+	return result, rows.Err()
+}
+
+// hasFirefoxCookies detects the moz_cookies table of a Firefox "cookies.sqlite" file.
+func hasFirefoxCookies(db *sql.DB) bool {
+	return hasColumns(db, "moz_cookies", "host", "name", "value", "path", "isSecure")
+}
+
+// parseFirefoxCookies reads the cookies of a Firefox "cookies.sqlite" file,
+// whose values are never encrypted.
+func parseFirefoxCookies(db *sql.DB) (*Result, error) {
+	rows, err := db.Query(`SELECT host, path, isSecure, name, value FROM moz_cookies`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &Result{}
+	for rows.Next() {
+		var host, path, name, value sql.NullString
+		var secure sql.NullInt64
+		if err := rows.Scan(&host, &path, &secure, &name, &value); err != nil {
+			return nil, err
+		}
+		result.addCookie(host.String, path.String, secure.Int64 != 0, name.String, value.String)
+	}
+	return result, rows.Err()
+}
+
+// addCookie records a cookie as a credential of the URL it is sent to, with
+// its name as the username and its value as the password.
+func (r *Result) addCookie(host, path string, secure bool, name, value string) {
+	// A leading dot marks a cookie sent to subdomains as well
+	host = strings.TrimPrefix(strings.TrimSpace(host), ".")
+	name = strings.TrimSpace(name)
+	value = strings.TrimSpace(value)
+	if host == "" || name == "" || value == "" {
+		r.Incomplete++
+		return
+	}
+
+	scheme := "http://"
+	if secure {
+		scheme = "https://"
+	}
+	if path = strings.TrimSpace(path); path == "" {
+		path = "/"
+	}
+	r.Credentials = append(r.Credentials, Credential{URL: scheme + host + path, Username: name, Password: value})
+}
@@ -0,0 +1,16 @@
+-- Chromium "Cookies", trimmed to the columns that matter
+CREATE TABLE cookies (
+	creation_utc INTEGER NOT NULL,
+	host_key TEXT NOT NULL,
+	name TEXT NOT NULL,
+	value TEXT NOT NULL,
+	encrypted_value BLOB NOT NULL,
+	path TEXT NOT NULL,
+	expires_utc INTEGER NOT NULL,
+	is_secure INTEGER NOT NULL,
+	is_httponly INTEGER NOT NULL
+);
+INSERT INTO cookies VALUES (13300000000000000, '.example.com', 'SID', 'abc123', X'', '/', 13400000000000000, 1, 1);
+INSERT INTO cookies VALUES (13300000000000000, 'shop.example', 'cart', '42', X'', '/cart', 0, 0, 0);
+INSERT INTO cookies VALUES (13300000000000000, '.bank.example', 'session', '', X'7631300102030405', '/', 13400000000000000, 1, 1);
+INSERT INTO cookies VALUES (13300000000000000, 'empty.example', 'flag', '', X'', '/', 0, 0, 0);
@@ -0,0 +1,16 @@
+-- Chromium "Login Data", trimmed to the columns that matter
+CREATE TABLE logins (
+	origin_url VARCHAR NOT NULL,
+	action_url VARCHAR,
+	username_element VARCHAR,
+	username_value VARCHAR,
+	password_element VARCHAR,
+	password_value BLOB,
+	signon_realm VARCHAR NOT NULL,
+	date_created INTEGER NOT NULL,
+	blacklisted_by_user INTEGER NOT NULL
+);
+INSERT INTO logins VALUES ('https://example.com/login', 'https://example.com/session', 'user', 'alice', 'pass', CAST('hunter2' AS BLOB), 'https://example.com/', 13300000000000000, 0);
+INSERT INTO logins VALUES ('', 'https://shop.example/auth', 'email', 'bob@shop.example', 'pw', CAST('s3cret' AS BLOB), 'https://shop.example/', 13300000000000000, 0);
+INSERT INTO logins VALUES ('https://bank.example/', '', 'user', 'carol', 'pass', X'7631300102030405', 'https://bank.example/', 13300000000000000, 0);
+INSERT INTO logins VALUES ('https://never.example/', '', '', '', '', X'', 'https://never.example/', 13300000000000000, 1);
@@ -0,0 +1,15 @@
+-- Firefox "cookies.sqlite", trimmed to the columns that matter
+CREATE TABLE moz_cookies (
+	id INTEGER PRIMARY KEY,
+	originAttributes TEXT NOT NULL DEFAULT '',
+	name TEXT,
+	value TEXT,
+	host TEXT,
+	path TEXT,
+	expiry INTEGER,
+	isSecure INTEGER,
+	isHttpOnly INTEGER
+);
+INSERT INTO moz_cookies VALUES (1, '', 'session', 'xyz', '.mozilla.org', '/', 1800000000, 1, 1);
+INSERT INTO moz_cookies VALUES (2, '', 'pref', 'dark', 'example.net', '', 1800000000, 0, 0);
+INSERT INTO moz_cookies VALUES (3, '', 'empty', '', 'example.net', '/', 1800000000, 0, 0);
@@ -0,0 +1,8 @@
+-- Chromium "History", which no parser reads
+CREATE TABLE urls (
+	id INTEGER PRIMARY KEY,
+	url LONGVARCHAR,
+	title LONGVARCHAR,
+	visit_count INTEGER DEFAULT 0 NOT NULL
+);
+INSERT INTO urls VALUES (1, 'https://example.com/', 'Example', 3);
@@ -0,0 +1,62 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"telegram-archive-bot/app/extraction/convert/browser"
+)
+
+// processBrowserStore converts a browser credential store the extractor
+// copied out of an archive, and reports what it did like processFile.
+func processBrowserStore(inputFilePath string, out *outputWriter, errorFolder string) fileResult {
+	result, err := browser.Parse(inputFilePath)
+	if errors.Is(err, browser.ErrUnsupportedStore) {
+		fmt.Printf("Deleting %s (no supported browser store)\n", inputFilePath)
+		os.Remove(inputFilePath)
+		return fileResult{outcome: OutcomeNoCredentials, warning: "unsupported SQLite file"}
+	}
+	if err != nil {
+		return quarantined(inputFilePath, errorFolder, fmt.Sprintf("Reading browser store failed: %v", err))
+	}
+
+	var credentials, hosts []string
+	for _, c := range result.Credentials {
+		if strings.Contains(c.URL, "://t.me/") {
+			continue
+		}
+		credentials = append(credentials, c.Line())
+		hosts = append(hosts, credentialHost(c.URL))
+	}
+
+	res := fileResult{outcome: OutcomeConverted, credentials: len(credentials), hosts: hosts}
+	if result.Encrypted > 0 {
+		res.warning = fmt.Sprintf("%s: %d encrypted values skipped", result.Format, result.Encrypted)
+	}
+
+	written := true
+	if len(credentials) > 0 {
+		res.duplicates, written = saveCreds(out, credentials)
+	}
+
+	if len(credentials) == 0 {
+		logError(inputFilePath, "No credentials found")
+		res.outcome = OutcomeNoCredentials
+	} else if !written {
+		logError(inputFilePath, "Failed to write credentials to output file")
+		res.outcome = OutcomeWriteFailed
+		res.warning = "failed to write credentials to output file"
+		fmt.Printf("Keeping file %s due to failed credential writing\n", inputFilePath)
+		return res
+	} else {
+		fmt.Printf("Credentials from %s (%s) → %s\n", inputFilePath, result.Format, currentOutput(out))
+	}
+
+	if err := os.Remove(inputFilePath); err != nil {
+		fmt.Printf("Error deleting file %s: %v\n", inputFilePath, err)
+		logError(inputFilePath, fmt.Sprintf("Failed to delete file: %v", err))
+	}
+	return res
+}
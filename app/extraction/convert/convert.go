@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/cheggaaa/pb/v3"
 	"github.com/common-nighthawk/go-figure"
@@ -15,8 +16,15 @@ import (
 	"github.com/saintfish/chardet"
 	"golang.org/x/text/encoding/ianaindex"
 	"golang.org/x/text/transform"
+
+	"telegram-archive-bot/app/extraction/convert/browser"
+	"telegram-archive-bot/app/extraction/progress"
 )
 
+// outputMu serializes the output, the duplicate filter and banks.txt between
+// the workers of a pass.
+var outputMu sync.Mutex
+
 // printHeader prints the application banner.
 func printHeader() {
 	os.Stdout.WriteString("\033[H\033[2J") // Clear screen
@@ -38,6 +46,12 @@ func quarantine(src, errFolder, reason string) {
 	logError(src, reason)
 }
 
+// quarantined quarantines a file and reports it as the file's result.
+func quarantined(src, errFolder, reason string) fileResult {
+	quarantine(src, errFolder, reason)
+	return fileResult{outcome: OutcomeQuarantined, warning: reason}
+}
+
 // logError writes an error message to the error log file.
 func logError(src, msg string) {
 	f, err := os.OpenFile("lconv_error_log.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
@@ -60,13 +74,24 @@ func shutilMove(src, dst string) error {
 	}
 	defer in.Close()
 
-	out, err := os.Create(dst)
+	// Copy into a .partial file so the destination only appears once complete
+	partialDst := dst + ".partial"
+	out, err := os.Create(partialDst)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
 
 	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(partialDst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(partialDst)
+		return err
+	}
+	if err := os.Rename(partialDst, dst); err != nil {
+		os.Remove(partialDst)
 		return err
 	}
 
@@ -75,6 +100,9 @@ func shutilMove(src, dst string) error {
 
 // appendContext writes the matched line plus next 3 lines to banks.txt.
 func appendContext(trigger string, sc *bufio.Scanner) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
 	doneFile := filepath.Join("files", "done", "banks.txt")
 	f, err := os.OpenFile(doneFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -89,21 +117,31 @@ func appendContext(trigger string, sc *bufio.Scanner) {
 	}
 }
 
-// saveCreds writes all credentials to the output file and returns success status.
-func saveCreds(out string, creds []string) bool {
-	f, err := os.OpenFile(out, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		fmt.Printf("Error opening output file %s: %v\n", out, err)
-		return false
+// saveCreds writes the credentials not converted before to the output
+// and returns how many were skipped as duplicates and success status.
+func saveCreds(out *outputWriter, creds []string) (int, bool) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	fresh, keys := unseenCreds(creds)
+	duplicates := len(creds) - len(fresh)
+	if len(fresh) == 0 {
+		return duplicates, true
 	}
-	defer f.Close()
-	for _, c := range creds {
-		if _, err := fmt.Fprintln(f, c); err != nil {
-			fmt.Printf("Error writing to output file %s: %v\n", out, err)
-			return false
-		}
+
+	if err := out.write(fresh); err != nil {
+		fmt.Printf("Error writing to output file %s: %v\n", out.current(), err)
+		return duplicates, false
 	}
-	return true
+	markSeen(keys)
+	return duplicates, true
+}
+
+// currentOutput returns the chunk being written while other workers may write.
+func currentOutput(out *outputWriter) string {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	return out.current()
 }
 
 // hasAny returns true if s contains any of the provided keys.
@@ -134,10 +172,11 @@ func cleanURL(line string) string {
 	return strings.TrimPrefix(value, "://")
 }
 
-// processFile implements the core file processing logic.
-func processFile(inputFilePath, outputFilePath, errorFolder string) {
+// processFile implements the core file processing logic and reports what it did.
+func processFile(inputFilePath string, out *outputWriter, errorFolder string) fileResult {
 	var (
 		credentials  []string
+		hosts        []string
 		foundStrings bool
 	)
 
@@ -150,39 +189,35 @@ func processFile(inputFilePath, outputFilePath, errorFolder string) {
 	rawdata, err := os.ReadFile(inputFilePath)
 	if err != nil {
 		fmt.Printf("File not found: %s. Skipping.\n", inputFilePath)
-		return
+		return fileResult{outcome: OutcomeMissing}
 	}
 
 	detector := chardet.NewTextDetector()
 	result, err := detector.DetectBest(rawdata)
 	if err != nil || result == nil {
-		quarantine(inputFilePath, errorFolder, fmt.Sprintf("Error detecting encoding: %v", err))
-		return
+		return quarantined(inputFilePath, errorFolder, fmt.Sprintf("Error detecting encoding: %v", err))
 	}
 
 	encoding := strings.ToLower(result.Charset)
 	if encoding != "utf-8" {
 		enc, _ := ianaindex.IANA.Encoding(strings.ToUpper(encoding))
 		if enc == nil {
-			quarantine(inputFilePath, errorFolder, fmt.Sprintf("Unsupported encoding: %s", encoding))
-			return
+			return quarantined(inputFilePath, errorFolder, fmt.Sprintf("Unsupported encoding: %s", encoding))
 		}
 		utf8Bytes, _, err := transform.Bytes(enc.NewDecoder(), rawdata)
 		if err != nil {
-			quarantine(inputFilePath, errorFolder, fmt.Sprintf("Encoding conversion failed: %v", err))
-			return
+			return quarantined(inputFilePath, errorFolder, fmt.Sprintf("Encoding conversion failed: %v", err))
 		}
 		err = os.WriteFile(inputFilePath, utf8Bytes, 0644)
 		if err != nil {
-			quarantine(inputFilePath, errorFolder, fmt.Sprintf("Writing UTF-8 file failed: %v", err))
-			return
+			return quarantined(inputFilePath, errorFolder, fmt.Sprintf("Writing UTF-8 file failed: %v", err))
 		}
 	}
 
 	file, err := os.Open(inputFilePath)
 	if err != nil {
 		fmt.Printf("File not found: %s. Skipping.\n", inputFilePath)
-		return
+		return fileResult{outcome: OutcomeMissing}
 	}
 	defer file.Close()
 
@@ -193,14 +228,13 @@ func processFile(inputFilePath, outputFilePath, errorFolder string) {
 		lineCount++
 	}
 	if err := scanner.Err(); err != nil {
-		quarantine(inputFilePath, errorFolder, fmt.Sprintf("Reading file failed: %v", err))
-		return
+		return quarantined(inputFilePath, errorFolder, fmt.Sprintf("Reading file failed: %v", err))
 	}
 	if lineCount == 0 {
 		fmt.Printf("Deleting empty file %s\n", inputFilePath)
 		file.Close()
 		os.Remove(inputFilePath)
-		return
+		return fileResult{outcome: OutcomeEmpty}
 	}
 
 	// Reset file pointer
@@ -242,6 +276,7 @@ func processFile(inputFilePath, outputFilePath, errorFolder string) {
 		if username != "" && password != "" && url != "" {
 			if !strings.Contains(url, "://t.me/") {
 				credentials = append(credentials, fmt.Sprintf("%s:%s:%s", url, username, password))
+				hosts = append(hosts, credentialHost(url))
 			}
 			username, password, url = "", "", ""
 		}
@@ -249,11 +284,11 @@ func processFile(inputFilePath, outputFilePath, errorFolder string) {
 	bar.Finish()
 
 	if err := scanner.Err(); err != nil {
-		quarantine(inputFilePath, errorFolder, fmt.Sprintf("Error reading file: %v", err))
-		return
+		return quarantined(inputFilePath, errorFolder, fmt.Sprintf("Error reading file: %v", err))
 	}
 
 	var credentialsWritten bool
+	var duplicates int
 	if len(credentials) == 0 {
 		fileInfo, err := os.Stat(inputFilePath)
 		if err == nil && fileInfo.Size() == 0 {
@@ -261,19 +296,29 @@ func processFile(inputFilePath, outputFilePath, errorFolder string) {
 			if err := os.Remove(inputFilePath); err != nil {
 				fmt.Printf("Error deleting empty file %s: %v\n", inputFilePath, err)
 			}
-			return
+			return fileResult{outcome: OutcomeEmpty}
 		} else {
 			logError(inputFilePath, "No credentials found")
 		}
 	} else {
-		credentialsWritten = saveCreds(outputFilePath, credentials)
+		duplicates, credentialsWritten = saveCreds(out, credentials)
 		if credentialsWritten {
-			fmt.Printf("Credentials from %s → %s\n", inputFilePath, outputFilePath)
+			fmt.Printf("Credentials from %s → %s\n", inputFilePath, currentOutput(out))
 		} else {
 			logError(inputFilePath, "Failed to write credentials to output file")
 		}
 	}
 
+	res := fileResult{outcome: OutcomeConverted, credentials: len(credentials), duplicates: duplicates, hosts: hosts, bankMatch: foundStrings}
+	if len(credentials) > 0 && !credentialsWritten {
+		res.outcome = OutcomeWriteFailed
+		res.warning = "failed to write credentials to output file"
+	} else if len(credentials) == 0 && foundStrings {
+		res.outcome = OutcomeBankMatch
+	} else if len(credentials) == 0 {
+		res.outcome = OutcomeNoCredentials
+	}
+
 	// Delete or move file based on processing results
 	if !foundStrings && len(credentials) == 0 {
 		fileInfo, err := os.Stat(inputFilePath)
@@ -291,13 +336,16 @@ func processFile(inputFilePath, outputFilePath, errorFolder string) {
 		destFolder := filepath.Join("files", "etbanks")
 		if err := os.MkdirAll(destFolder, 0755); err != nil {
 			fmt.Printf("Failed to create folder %s: %v\n", destFolder, err)
-			return
+			res.warning = fmt.Sprintf("failed to create %s: %v", destFolder, err)
+			return res
 		}
 		destPath := filepath.Join(destFolder, filepath.Base(inputFilePath))
 		if err := shutilMove(inputFilePath, destPath); err != nil {
 			logError(inputFilePath, fmt.Sprintf("Failed moving file: %v", err))
+			res.warning = fmt.Sprintf("failed to move to %s: %v", destFolder, err)
 		} else {
 			fmt.Printf("Moved %s → %s\n", inputFilePath, destPath)
+			res.produced = destPath
 		}
 	} else if len(credentials) > 0 && credentialsWritten {
 		// Close file before deletion
@@ -314,9 +362,13 @@ func processFile(inputFilePath, outputFilePath, errorFolder string) {
 		// If we had credentials but failed to write them, don't delete the file
 		fmt.Printf("Keeping file %s due to failed credential writing\n", inputFilePath)
 	}
+
+	return res
 }
 
-func ConvertTextFiles() error {
+// ConvertTextFiles converts the files in CONVERT_INPUT_DIR into
+// CONVERT_OUTPUT_FILE, with up to workers files converted at once
+func ConvertTextFiles(workers int) error {
 	printHeader()
 
 	// Read paths from environment
@@ -342,13 +394,71 @@ func ConvertTextFiles() error {
 		return fmt.Errorf("reading folder %s: %w", inputPath, err)
 	}
 
+	out, err := newOutputWriter(outputFile)
+	if err != nil {
+		return err
+	}
+
+	var inputFiles []os.DirEntry
 	for _, fileInfo := range fileInfos {
-		if fileInfo.IsDir() {
+		if fileInfo.IsDir() || strings.HasSuffix(fileInfo.Name(), ".partial") {
 			continue
 		}
-		filePath := filepath.Join(inputPath, fileInfo.Name())
-		fmt.Println(fileInfo.Name())
-		processFile(filePath, outputFile, errorFolder)
+		inputFiles = append(inputFiles, fileInfo)
+	}
+
+	loadSeenLines()
+	manifest := newManifest(outputFile)
+	reporter := progress.NewReporter("conversion", len(inputFiles))
+
+	// Files are handed out to the workers in directory order
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	started := 0
+	next := make(chan os.DirEntry)
+	for w := 0; w < max(workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fileInfo := range next {
+				mu.Lock()
+				reporter.Update(started, fileInfo.Name())
+				started++
+				mu.Unlock()
+
+				filePath := filepath.Join(inputPath, fileInfo.Name())
+				fmt.Println(fileInfo.Name())
+				var res fileResult
+				if browser.IsSQLite(filePath) {
+					res = processBrowserStore(filePath, out, errorFolder)
+				} else {
+					res = processFile(filePath, out, errorFolder)
+				}
+
+				mu.Lock()
+				manifest.add(fileInfo.Name(), res)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, fileInfo := range inputFiles {
+		next <- fileInfo
+	}
+	close(next)
+	wg.Wait()
+	reporter.Update(len(inputFiles), "")
+
+	if err := out.closeChunk(); err != nil {
+		return err
+	}
+
+	if err := saveSeenLines(); err != nil {
+		// Lines of this pass may be converted again; the store still drops them
+		fmt.Printf("Error saving duplicate filter: %v\n", err)
+	}
+
+	if err := manifest.finish(out.written); err != nil {
+		return fmt.Errorf("writing result manifest: %w", err)
 	}
 	return nil
 }
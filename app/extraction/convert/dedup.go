@@ -0,0 +1,79 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"telegram-archive-bot/app/extraction/dedup"
+)
+
+// DedupEnvFile names the environment variable with the path of the Bloom
+// filter of lines already converted. When it is unset, or the file is
+// missing, every credential is written as before.
+const DedupEnvFile = "CONVERT_DEDUP_FILE"
+
+// seenLines is the filter of the running pass, nil when deduplication is off.
+var seenLines *dedup.Filter
+
+// loadSeenLines opens the filter named by DedupEnvFile for this pass.
+func loadSeenLines() {
+	seenLines = nil
+	path := os.Getenv(DedupEnvFile)
+	if path == "" {
+		return
+	}
+
+	filter, err := dedup.Load(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// The orchestrator creates the filter; converting without one is safe
+		return
+	}
+	if err != nil {
+		fmt.Printf("Duplicate filter %s unusable, converting without it: %v\n", path, err)
+		return
+	}
+	seenLines = filter
+}
+
+// saveSeenLines writes back the filter with the lines of this pass.
+func saveSeenLines() error {
+	if seenLines == nil {
+		return nil
+	}
+	defer func() { seenLines = nil }()
+	return seenLines.Save(os.Getenv(DedupEnvFile))
+}
+
+// unseenCreds drops the credentials the filter has already seen, including
+// repeats within creds, and returns the rest with their keys.
+func unseenCreds(creds []string) ([]string, []uint64) {
+	if seenLines == nil {
+		return creds, nil
+	}
+
+	fresh := make([]string, 0, len(creds))
+	keys := make([]uint64, 0, len(creds))
+	batch := make(map[uint64]bool, len(creds))
+	for _, c := range creds {
+		key := dedup.Key(c)
+		if batch[key] || seenLines.Has(key) {
+			continue
+		}
+		batch[key] = true
+		fresh = append(fresh, c)
+		keys = append(keys, key)
+	}
+	return fresh, keys
+}
+
+// markSeen adds written credentials to the filter. It is only called once
+// they are on disk, so a failed write is retried in full next pass.
+func markSeen(keys []uint64) {
+	if seenLines == nil {
+		return
+	}
+	for _, key := range keys {
+		seenLines.Add(key)
+	}
+}
@@ -0,0 +1,167 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ManifestEnvFile names the environment variable with the path the result
+// manifest is written to. When it is unset no manifest is written.
+const ManifestEnvFile = "CONVERT_MANIFEST_FILE"
+
+// Per-file outcomes counted in the manifest.
+const (
+	OutcomeConverted     = "converted"      // Credentials written to the output file
+	OutcomeBankMatch     = "bank_match"     // Bank strings but no credentials, moved to etbanks
+	OutcomeNoCredentials = "no_credentials" // Nothing of interest, deleted
+	OutcomeEmpty         = "empty"          // Empty file, deleted
+	OutcomeQuarantined   = "quarantined"    // Unreadable file, moved to the error folder
+	OutcomeWriteFailed   = "write_failed"   // Credentials found but not written, file kept
+	OutcomeMissing       = "missing"        // File disappeared before it was read
+)
+
+// maxManifestWarnings bounds the warnings kept so a bad batch cannot bloat the manifest.
+const maxManifestWarnings = 100
+
+// Manifest is the machine-readable result of one conversion pass.
+type Manifest struct {
+	StartedAt      time.Time      `json:"started_at"`
+	FinishedAt     time.Time      `json:"finished_at"`
+	OutputFile     string         `json:"output_file"`
+	ParserVersion  int            `json:"parser_version"`
+	FilesProcessed int            `json:"files_processed"`
+	Credentials    int            `json:"credentials"`
+	Domains        int            `json:"domains"`
+	FilesProduced  []string       `json:"files_produced"`
+	Outcomes       map[string]int `json:"outcomes"`
+	Warnings       []string       `json:"warnings,omitempty"`
+
+	// DuplicatesSkipped counts credentials the duplicate filter had seen in
+	// earlier passes, or earlier in this one, and so were not written.
+	DuplicatesSkipped int `json:"duplicates_skipped,omitempty"`
+
+	// DomainCredentials counts the credentials found per domain.
+	DomainCredentials map[string]int `json:"domain_credentials,omitempty"`
+
+	// DroppedWarnings counts warnings beyond maxManifestWarnings.
+	DroppedWarnings int `json:"dropped_warnings,omitempty"`
+
+	bankMatched bool
+}
+
+// fileResult is what processFile did with a single input file.
+type fileResult struct {
+	outcome     string
+	credentials int
+	duplicates  int // Credentials skipped as converted before
+	hosts       []string
+	bankMatch   bool
+	produced    string
+	warning     string
+}
+
+// newManifest starts the manifest for a conversion pass writing to outputFile.
+func newManifest(outputFile string) *Manifest {
+	return &Manifest{
+		StartedAt:     time.Now(),
+		OutputFile:    outputFile,
+		ParserVersion: ParserVersion,
+		FilesProduced: []string{},
+		Outcomes:      make(map[string]int),
+
+		DomainCredentials: make(map[string]int),
+	}
+}
+
+// add records the result of one input file.
+func (m *Manifest) add(name string, res fileResult) {
+	m.FilesProcessed++
+	m.Outcomes[res.outcome]++
+	m.Credentials += res.credentials
+	m.DuplicatesSkipped += res.duplicates
+	m.bankMatched = m.bankMatched || res.bankMatch
+	for _, host := range res.hosts {
+		if host != "" {
+			m.DomainCredentials[host]++
+		}
+	}
+	if res.produced != "" {
+		m.FilesProduced = append(m.FilesProduced, res.produced)
+	}
+	if res.warning != "" {
+		if len(m.Warnings) < maxManifestWarnings {
+			m.Warnings = append(m.Warnings, fmt.Sprintf("%s: %s", name, res.warning))
+		} else {
+			m.DroppedWarnings++
+		}
+	}
+}
+
+// finish completes the manifest with the output chunks written and writes
+// it where ManifestEnvFile points.
+func (m *Manifest) finish(outputs []string) error {
+	m.FinishedAt = time.Now()
+	m.Domains = len(m.DomainCredentials)
+	m.FilesProduced = append(append([]string{}, outputs...), m.FilesProduced...)
+	if m.bankMatched {
+		m.FilesProduced = append(m.FilesProduced, filepath.Join("files", "done", "banks.txt"))
+	}
+
+	path := os.Getenv(ManifestEnvFile)
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Replace atomically so the orchestrator never parses half a manifest
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// ReadManifest loads a result manifest; it returns nil, nil if none was written.
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read result manifest: %w", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode result manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// credentialHost returns the lower-cased host of a credential URL.
+func credentialHost(raw string) string {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
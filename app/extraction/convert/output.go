@@ -0,0 +1,253 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables configuring how converted credentials are split into
+// files. When all are unset, every credential goes to CONVERT_OUTPUT_FILE.
+const (
+	OutputEnvMaxLines = "CONVERT_CHUNK_MAX_LINES" // Lines per file, 0 for no limit
+	OutputEnvMaxBytes = "CONVERT_CHUNK_MAX_BYTES" // Bytes per file, 0 for no limit
+	OutputEnvName     = "CONVERT_OUTPUT_NAME"     // File name template, see expandOutputName
+	OutputEnvTask     = "CONVERT_OUTPUT_TASK"     // Value of {task}
+	OutputEnvTag      = "CONVERT_OUTPUT_TAG"      // Value of {tag}
+)
+
+// OutputSettings configure how ConvertTextFiles splits and names its output.
+type OutputSettings struct {
+	MaxLines     int64  // Lines per file, 0 for no limit
+	MaxBytes     int64  // Bytes per file, 0 for no limit
+	NameTemplate string // "" names files after CONVERT_OUTPUT_FILE
+	Task         string
+	Tag          string
+}
+
+// Apply sets the environment variables ConvertTextFiles reads the settings from.
+func (s OutputSettings) Apply() {
+	setOrUnset(OutputEnvMaxLines, s.MaxLines)
+	setOrUnset(OutputEnvMaxBytes, s.MaxBytes)
+	setOrUnset(OutputEnvName, s.NameTemplate)
+	setOrUnset(OutputEnvTask, s.Task)
+	setOrUnset(OutputEnvTag, s.Tag)
+}
+
+// setOrUnset sets an environment variable, or unsets it for a zero value.
+func setOrUnset[T comparable](name string, value T) {
+	var zero T
+	if value == zero {
+		os.Unsetenv(name)
+		return
+	}
+	os.Setenv(name, fmt.Sprint(value))
+}
+
+// outputWriter appends credentials to numbered chunk files in the output
+// directory. Each chunk is written as a .partial file and renamed into place
+// once full or at the end of the pass, so the store stage never scans a
+// half-written file.
+type outputWriter struct {
+	dir      string
+	template string
+	maxLines int64
+	maxBytes int64
+	task     string
+	tag      string
+	started  time.Time
+
+	seq     int
+	path    string // Final name of the open chunk, "" when none is open
+	file    *os.File
+	buf     *bufio.Writer
+	lines   int64
+	bytes   int64
+	written []string
+}
+
+// newOutputWriter configures the writer from CONVERT_OUTPUT_FILE and the
+// chunking variables. Without a name template, chunks are named after the
+// output file.
+func newOutputWriter(outputFile string) (*outputWriter, error) {
+	w := &outputWriter{
+		dir:      filepath.Dir(outputFile),
+		template: os.Getenv(OutputEnvName),
+		task:     sanitizeNamePart(os.Getenv(OutputEnvTask)),
+		tag:      sanitizeNamePart(os.Getenv(OutputEnvTag)),
+		started:  time.Now(),
+	}
+	if w.template == "" {
+		w.template = filepath.Base(outputFile)
+	}
+	if strings.ContainsAny(w.template, `/\`) {
+		return nil, fmt.Errorf("%s must be a file name, got %q", OutputEnvName, w.template)
+	}
+
+	var err error
+	if w.maxLines, err = readLimit(OutputEnvMaxLines); err != nil {
+		return nil, err
+	}
+	if w.maxBytes, err = readLimit(OutputEnvMaxBytes); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(w.dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating output folder: %w", err)
+	}
+	w.publishLeftovers()
+	return w, nil
+}
+
+// publishLeftovers renames the chunks a crashed pass left open into place.
+// Their lines were converted and their input files deleted, so dropping them
+// would lose credentials.
+func (w *outputWriter) publishLeftovers() {
+	partials, _ := filepath.Glob(filepath.Join(w.dir, "*.partial"))
+	for _, partial := range partials {
+		final := strings.TrimSuffix(partial, ".partial")
+		if _, err := os.Stat(final); !os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(partial, final); err != nil {
+			fmt.Printf("Error publishing leftover output file %s: %v\n", partial, err)
+		}
+	}
+}
+
+// readLimit parses a non-negative chunk limit; unset means no limit.
+func readLimit(name string) (int64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s: %s", name, v)
+	}
+	return n, nil
+}
+
+// sanitizeNamePart keeps a template value safe to use in a file name.
+func sanitizeNamePart(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// expandOutputName fills in the template for chunk seq. Supported
+// placeholders are {date} (20060102), {time} (150405), {task}, {tag} and
+// {seq} (001, 002, ...). Empty values and the separators left around them
+// are dropped. When the template has no {seq}, chunks after the first get
+// _002, _003, ... before the extension.
+func (w *outputWriter) expandOutputName(seq int) string {
+	seqText := fmt.Sprintf("%03d", seq)
+	name := strings.NewReplacer(
+		"{date}", w.started.Format("20060102"),
+		"{time}", w.started.Format("150405"),
+		"{task}", w.task,
+		"{tag}", w.tag,
+		"{seq}", seqText,
+	).Replace(w.template)
+
+	for _, sep := range []string{"__", "--", "_-", "-_"} {
+		for strings.Contains(name, sep) {
+			name = strings.ReplaceAll(name, sep, sep[:1])
+		}
+	}
+	ext := filepath.Ext(name)
+	stem := strings.Trim(strings.TrimSuffix(name, ext), "_-")
+	if stem == "" {
+		stem = "converted"
+	}
+	if !strings.Contains(w.template, "{seq}") && seq > 1 {
+		stem += "_" + seqText
+	}
+	return stem + ext
+}
+
+// write appends credentials, starting a new chunk whenever a limit is reached.
+func (w *outputWriter) write(creds []string) error {
+	for _, c := range creds {
+		size := int64(len(c)) + 1
+		if w.file != nil && w.lines > 0 &&
+			(w.maxLines > 0 && w.lines >= w.maxLines || w.maxBytes > 0 && w.bytes+size > w.maxBytes) {
+			if err := w.closeChunk(); err != nil {
+				return err
+			}
+		}
+		if w.file == nil {
+			if err := w.openChunk(); err != nil {
+				return err
+			}
+		}
+		if _, err := w.buf.WriteString(c + "\n"); err != nil {
+			return err
+		}
+		w.lines++
+		w.bytes += size
+	}
+	// Flush per input file so a failure is reported with the file it belongs to
+	return w.buf.Flush()
+}
+
+// openChunk opens the next free chunk name. A name is taken when a chunk of
+// an earlier pass still waits for the store stage.
+func (w *outputWriter) openChunk() error {
+	for {
+		w.seq++
+		path := filepath.Join(w.dir, w.expandOutputName(w.seq))
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if _, err := os.Stat(path + ".partial"); err == nil {
+			continue
+		}
+
+		file, err := os.OpenFile(path+".partial", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		w.path, w.file, w.buf = path, file, bufio.NewWriterSize(file, 64*1024)
+		w.lines, w.bytes = 0, 0
+		return nil
+	}
+}
+
+// closeChunk publishes the open chunk under its final name.
+func (w *outputWriter) closeChunk() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.buf.Flush()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	partial := w.path + ".partial"
+	w.file, w.buf = nil, nil
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", partial, err)
+	}
+	if err := os.Rename(partial, w.path); err != nil {
+		return fmt.Errorf("publishing output file %s: %w", w.path, err)
+	}
+	w.written = append(w.written, w.path)
+	return nil
+}
+
+// current returns the chunk being written, for log lines.
+func (w *outputWriter) current() string {
+	if w.path == "" {
+		return w.dir
+	}
+	return w.path
+}
@@ -0,0 +1,24 @@
+package convert
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+)
+
+// ParserVersion is the version of the credential parser. Bump it with every
+// change that converts the same input differently, so a reprocess campaign
+// can apply the change to passes converted before it
+const ParserVersion = 1
+
+// source is the converter source compiled into this binary, kept to detect
+// when the source on disk has changed since the build
+//
+//go:embed convert.go
+var source []byte
+
+// SourceHash returns the SHA-256 of the converter source this binary was built from
+func SourceHash() string {
+	sum := sha256.Sum256(source)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,288 @@
+// Package dedup keeps a Bloom filter of the credential lines already
+// converted, so the convert stage can skip them without asking the store.
+package dedup
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileMagic starts every filter file; the last byte is the format version.
+var fileMagic = [8]byte{'T', 'A', 'B', 'L', 'O', 'O', 'M', 1}
+
+// maxHashes bounds the hash functions so a tiny false positive rate cannot
+// make every lookup touch dozens of cache lines.
+const maxHashes = 16
+
+// ErrBadFile is returned when a filter file is truncated or not a filter.
+var ErrBadFile = errors.New("not a dedup filter file")
+
+// Filter is a Bloom filter over 64-bit line keys. It is not safe for
+// concurrent use.
+type Filter struct {
+	bits      []uint64
+	m         uint64 // Number of bits
+	k         uint32 // Hash functions per key
+	capacity  uint64
+	fpRate    float64
+	added     uint64
+	rebuiltAt time.Time
+}
+
+// New sizes a filter to hold capacity keys at the given false positive rate.
+func New(capacity uint64, fpRate float64) (*Filter, error) {
+	if capacity == 0 {
+		return nil, fmt.Errorf("filter capacity must be positive")
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		return nil, fmt.Errorf("false positive rate must be between 0 and 1, got %g", fpRate)
+	}
+
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	m = (m + 63) / 64 * 64
+	k := uint32(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	if k > maxHashes {
+		k = maxHashes
+	}
+
+	return &Filter{
+		bits:     make([]uint64, m/64),
+		m:        m,
+		k:        k,
+		capacity: capacity,
+		fpRate:   fpRate,
+	}, nil
+}
+
+// Key returns the key of a line: the first 8 bytes of the MD5 of the trimmed
+// line, the same digest the store keeps in lines.line_hash.
+func Key(line string) uint64 {
+	sum := md5.Sum([]byte(strings.TrimSpace(line)))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// KeyFromHash returns the key of a line from its hex MD5 as stored in the
+// line_hash column.
+func KeyFromHash(hash string) (uint64, error) {
+	if len(hash) < 16 {
+		return 0, fmt.Errorf("line hash too short: %q", hash)
+	}
+	return strconv.ParseUint(hash[:16], 16, 64)
+}
+
+// Add records a key.
+func (f *Filter) Add(key uint64) {
+	h1, h2 := hashes(key)
+	for i := uint64(0); i < uint64(f.k); i++ {
+		bit := (h1 + i*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+	f.added++
+}
+
+// Has reports whether a key may have been added. False positives happen at
+// about the configured rate once the filter is full; false negatives never.
+func (f *Filter) Has(key uint64) bool {
+	h1, h2 := hashes(key)
+	for i := uint64(0); i < uint64(f.k); i++ {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hashes derives the two hashes of double hashing from a key. The key is
+// already uniform, so the second hash only needs to differ from it and be odd.
+func hashes(key uint64) (uint64, uint64) {
+	z := key + 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return key, (z ^ (z >> 31)) | 1
+}
+
+// Added returns how many keys were added, counting repeats.
+func (f *Filter) Added() uint64 {
+	return f.added
+}
+
+// Full reports whether more keys were added than the filter was sized for,
+// past which the false positive rate climbs quickly.
+func (f *Filter) Full() bool {
+	return f.added > f.capacity
+}
+
+// RebuiltAt returns when the filter was last rebuilt from the store.
+func (f *Filter) RebuiltAt() time.Time {
+	return f.rebuiltAt
+}
+
+// MarkRebuilt records that the filter now reflects the store.
+func (f *Filter) MarkRebuilt(at time.Time) {
+	f.rebuiltAt = at
+}
+
+// SizeBytes returns the memory taken by the bit array.
+func (f *Filter) SizeBytes() uint64 {
+	return f.m / 8
+}
+
+// header is the fixed part of a filter file, before the bit array.
+type header struct {
+	Magic     [8]byte
+	K         uint32
+	_         uint32
+	M         uint64
+	Capacity  uint64
+	FPRate    float64
+	Added     uint64
+	RebuiltAt int64
+}
+
+// Info describes a filter file without loading its bit array.
+type Info struct {
+	Capacity  uint64
+	FPRate    float64
+	Added     uint64
+	SizeBytes uint64
+	RebuiltAt time.Time // Zero until rebuilt from the store
+}
+
+// Matches reports whether the filter was sized with the given parameters.
+func (i Info) Matches(capacity uint64, fpRate float64) bool {
+	return i.Capacity == capacity && i.FPRate == fpRate
+}
+
+// Inspect reads the header of a filter file.
+func Inspect(path string) (Info, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Info{}, err
+	}
+	defer file.Close()
+
+	h, err := readHeader(file)
+	if err != nil {
+		return Info{}, err
+	}
+	return h.info(), nil
+}
+
+// readHeader reads and checks the header of a filter file.
+func readHeader(file *os.File) (header, error) {
+	var h header
+	if err := binary.Read(file, binary.LittleEndian, &h); err != nil {
+		return h, fmt.Errorf("%w: %v", ErrBadFile, err)
+	}
+	if h.Magic != fileMagic || h.K == 0 || h.M == 0 || h.M%64 != 0 {
+		return h, ErrBadFile
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return h, err
+	}
+	if info.Size() != int64(binary.Size(h))+int64(h.M/8) {
+		return h, fmt.Errorf("%w: size does not match header", ErrBadFile)
+	}
+	return h, nil
+}
+
+// info converts a header to its description.
+func (h header) info() Info {
+	i := Info{Capacity: h.Capacity, FPRate: h.FPRate, Added: h.Added, SizeBytes: h.M / 8}
+	if h.RebuiltAt != 0 {
+		i.RebuiltAt = time.Unix(h.RebuiltAt, 0)
+	}
+	return i
+}
+
+// Load reads a filter written by Save.
+func Load(path string) (*Filter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	h, err := readHeader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Filter{
+		bits:      make([]uint64, h.M/64),
+		m:         h.M,
+		k:         h.K,
+		capacity:  h.Capacity,
+		fpRate:    h.FPRate,
+		added:     h.Added,
+		rebuiltAt: h.info().RebuiltAt,
+	}
+	if err := binary.Read(bufio.NewReaderSize(file, 1<<20), binary.LittleEndian, f.bits); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadFile, err)
+	}
+	return f, nil
+}
+
+// Save writes the filter to path, replacing it atomically so a crash never
+// leaves half a filter behind.
+func (f *Filter) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	h := header{
+		Magic:    fileMagic,
+		K:        f.k,
+		M:        f.m,
+		Capacity: f.capacity,
+		FPRate:   f.fpRate,
+		Added:    f.added,
+	}
+	if !f.rebuiltAt.IsZero() {
+		h.RebuiltAt = f.rebuiltAt.Unix()
+	}
+
+	w := bufio.NewWriterSize(file, 1<<20)
+	err = binary.Write(w, binary.LittleEndian, &h)
+	if err == nil {
+		err = binary.Write(w, binary.LittleEndian, f.bits)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
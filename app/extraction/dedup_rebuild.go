@@ -0,0 +1,63 @@
+package extraction
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"telegram-archive-bot/app/extraction/dedup"
+)
+
+// RebuildDedupFilter adds the hash of every line in the store database to
+// filter, so the convert stage's duplicate filter matches what is stored.
+// Only line_hash is read, never the content. Returns the lines read
+func RebuildDedupFilter(ctx context.Context, filter *dedup.Filter, logger func(string, ...interface{})) (int64, error) {
+	cfg := LoadConfig()
+	if !cfg.RunDB {
+		return 0, fmt.Errorf("store database is disabled")
+	}
+
+	shardManager, err := NewShardManager(cfg, logger)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to store database: %w", err)
+	}
+	defer shardManager.Close()
+
+	var total int64
+	for shard := 0; shard < shardManager.GetShardCount(); shard++ {
+		count, err := addShardHashes(ctx, shardManager.GetShardConnection(shard), filter)
+		total += count
+		if err != nil {
+			return total, fmt.Errorf("failed to read line hashes of shard %d: %w", shard, err)
+		}
+		if logger != nil {
+			logger("✓ Duplicate filter: %d line hashes read from shard %d", count, shard)
+		}
+	}
+	return total, nil
+}
+
+// addShardHashes streams the line hashes of one shard into filter
+func addShardHashes(ctx context.Context, db *sql.DB, filter *dedup.Filter) (int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT line_hash FROM `lines`")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	var hash string
+	for rows.Next() {
+		if err := rows.Scan(&hash); err != nil {
+			return count, err
+		}
+		key, err := dedup.KeyFromHash(hash)
+		if err != nil {
+			// Not a hash this pipeline wrote; the line cannot match a credential
+			continue
+		}
+		filter.Add(key)
+		count++
+	}
+	return count, rows.Err()
+}
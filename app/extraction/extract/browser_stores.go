@@ -0,0 +1,64 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/yeka/zip"
+
+	"telegram-archive-bot/app/extraction/convert/browser"
+)
+
+// maxBrowserStoreBytes skips stores too large to be one profile's saved logins
+// or cookies.
+const maxBrowserStoreBytes = 64 << 20
+
+// saveBrowserStore copies a browser credential store read from an archive to
+// the output directory, where the converter parses it.
+func saveBrowserStore(name string, r io.Reader, destinationPath string) error {
+	content, err := io.ReadAll(io.LimitReader(r, maxBrowserStoreBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(content) > maxBrowserStoreBytes {
+		return fmt.Errorf("store larger than %d bytes", maxBrowserStoreBytes)
+	}
+	// Stealer logs also name folders and Netscape cookie exports "Cookies"
+	if !browser.HasSQLiteHeader(content) {
+		return fmt.Errorf("not an SQLite database")
+	}
+
+	newFilename := outputName("browser", ".sqlite")
+	newFilePath := filepath.Join(destinationPath, newFilename)
+	if err := writeFileAtomic(newFilePath, content); err != nil {
+		return err
+	}
+
+	color.Green("✅ Browser store %s saved: %s", name, newFilePath)
+	return nil
+}
+
+// saveZIPBrowserStore copies a ZIP browser store entry, trying the passwords
+// when it is encrypted.
+func saveZIPBrowserStore(f *zip.File, passwords []string, destinationPath string) {
+	for _, password := range passwords {
+		if f.IsEncrypted() {
+			f.SetPassword(password)
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			continue
+		}
+		err = saveBrowserStore(f.Name, rc, destinationPath)
+		rc.Close()
+		if err == nil || !f.IsEncrypted() {
+			if err != nil {
+				color.Yellow("⚠️ Skipped browser store %s: %v", f.Name, err)
+			}
+			return
+		}
+	}
+}
@@ -9,21 +9,65 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/nwaples/rardecode"
 	"github.com/yeka/zip"
+
+	"telegram-archive-bot/app/extraction/convert/browser"
+	"telegram-archive-bot/app/extraction/progress"
 )
 
-func ExtractArchives() {
+// ExtractArchives extracts the archives in files/all into files/pass, with
+// up to workers archives extracted at once
+func ExtractArchives(workers int) {
 	fmt.Print("\033[H\033[2J")
 	color.Cyan("\nStarting the EXTRACTOR...\n")
 
 	inputDirectory := "app/extraction/files/all"
 	outputDirectory := "app/extraction/files/pass"
 
-	processArchivesInDir(inputDirectory, outputDirectory)
+	processArchivesInDir(inputDirectory, outputDirectory, workers)
+}
+
+// outputSeq numbers the files written by every extraction worker
+var outputSeq atomic.Int64
+
+// outputName returns a file name no other extraction worker writes, e.g.
+// password_12_1700000000000000000.txt; a per-archive count and the clock
+// alone repeat when archives are extracted at once
+func outputName(prefix, ext string) string {
+	return fmt.Sprintf("%s_%d_%d%s", prefix, outputSeq.Add(1), time.Now().UnixNano(), ext)
+}
+
+// writeFileAtomic writes content to a .partial file and renames it into place
+func writeFileAtomic(path string, content []byte) error {
+	partialPath := path + ".partial"
+
+	outFile, err := os.Create(partialPath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := outFile.Write(content); err != nil {
+		outFile.Close()
+		os.Remove(partialPath)
+		return err
+	}
+	if err := outFile.Sync(); err != nil {
+		outFile.Close()
+		os.Remove(partialPath)
+		return err
+	}
+	if err := outFile.Close(); err != nil {
+		os.Remove(partialPath)
+		return err
+	}
+
+	return os.Rename(partialPath, path)
 }
 
 func readPasswordsFromFile(passwordFile string) []string {
@@ -81,8 +125,18 @@ func extractZIPFiles(archivePath, destinationPath string, passwords []string) (b
 
 	extractedFiles := 0
 	passwordFailed := false
+	images := newImageCollector()
 
 	for _, f := range r.File {
+		if images.wants(f.Name, int64(f.UncompressedSize64)) {
+			images.saveZIPImage(f, passwords)
+			continue
+		}
+		if browser.IsStoreName(f.Name) {
+			saveZIPBrowserStore(f, passwords, destinationPath)
+			continue
+		}
+
 		match, _ := regexp.MatchString(`.*asswor.*\.txt`, f.Name)
 		if !match {
 			continue
@@ -108,22 +162,12 @@ func extractZIPFiles(archivePath, destinationPath string, passwords []string) (b
 			}
 
 			// Only create file if extraction was successful
-			timestamp := time.Now().UnixNano()
-			newFilename := fmt.Sprintf("password_%d_%d.txt", extractedFiles, timestamp)
+			newFilename := outputName("password", ".txt")
 			newFilePath := filepath.Join(destinationPath, newFilename)
 
-			outFile, err := os.Create(newFilePath)
-			if err != nil {
-				color.Red("🛠️ Error creating file: %v", err)
-				continue
-			}
-
-			_, err = outFile.Write(content)
-			outFile.Close()
-
-			if err != nil {
+			// Write via a .partial file so the converter never picks up a half-written file
+			if err := writeFileAtomic(newFilePath, content); err != nil {
 				color.Red("🛠️ Error writing file: %v", err)
-				os.Remove(newFilePath) // Clean up failed file
 				continue
 			}
 
@@ -152,6 +196,7 @@ func extractRARFiles(archivePath, destinationPath string, passwords []string) (b
 	extractedFiles := 0
 	hasPasswordFiles := false
 	isArchivePasswordProtected := false
+	images := newImageCollector()
 
 	// First, try to open without password and attempt to read to detect if archive is password-protected
 	rr, err := rardecode.OpenReader(archivePath, "")
@@ -206,6 +251,19 @@ func extractRARFiles(archivePath, destinationPath string, passwords []string) (b
 					break
 				}
 
+				if images.wants(header.Name, header.UnPackedSize) {
+					if err := images.save(header.Name, rr); err != nil {
+						color.Yellow("⚠️ Skipped image %s: %v", header.Name, err)
+					}
+					continue
+				}
+				if browser.IsStoreName(header.Name) {
+					if err := saveBrowserStore(header.Name, rr, destinationPath); err != nil {
+						color.Yellow("⚠️ Skipped browser store %s: %v", header.Name, err)
+					}
+					continue
+				}
+
 				match, _ := regexp.MatchString(`.*asswor.*\.txt`, header.Name)
 				if !match {
 					continue
@@ -220,22 +278,12 @@ func extractRARFiles(archivePath, destinationPath string, passwords []string) (b
 				}
 
 				// Only create file if extraction was successful
-				timestamp := time.Now().UnixNano()
-				newFilename := fmt.Sprintf("password_%d_%d.txt", extractedFiles, timestamp)
+				newFilename := outputName("password", ".txt")
 				newFilePath := filepath.Join(destinationPath, newFilename)
 
-				outFile, err := os.Create(newFilePath)
-				if err != nil {
-					color.Red("🛠️ Error creating file: %v", err)
-					continue
-				}
-
-				_, err = outFile.Write(content)
-				outFile.Close()
-
-				if err != nil {
+				// Write via a .partial file so the converter never picks up a half-written file
+				if err := writeFileAtomic(newFilePath, content); err != nil {
 					color.Red("🛠️ Error writing file: %v", err)
-					os.Remove(newFilePath) // Clean up failed file
 					continue
 				}
 
@@ -267,6 +315,19 @@ func extractRARFiles(archivePath, destinationPath string, passwords []string) (b
 					break
 				}
 
+				if images.wants(header.Name, header.UnPackedSize) {
+					if err := images.save(header.Name, rr); err != nil {
+						color.Yellow("⚠️ Skipped image %s: %v", header.Name, err)
+					}
+					continue
+				}
+				if browser.IsStoreName(header.Name) {
+					if err := saveBrowserStore(header.Name, rr, destinationPath); err != nil {
+						color.Yellow("⚠️ Skipped browser store %s: %v", header.Name, err)
+					}
+					continue
+				}
+
 				match, _ := regexp.MatchString(`.*asswor.*\.txt`, header.Name)
 				if !match {
 					continue
@@ -282,22 +343,12 @@ func extractRARFiles(archivePath, destinationPath string, passwords []string) (b
 				}
 
 				// Only create file if extraction was successful
-				timestamp := time.Now().UnixNano()
-				newFilename := fmt.Sprintf("password_%d_%d.txt", extractedFiles, timestamp)
+				newFilename := outputName("password", ".txt")
 				newFilePath := filepath.Join(destinationPath, newFilename)
 
-				outFile, err := os.Create(newFilePath)
-				if err != nil {
-					color.Red("🛠️ Error creating file: %v", err)
-					continue
-				}
-
-				_, err = outFile.Write(content)
-				outFile.Close()
-
-				if err != nil {
+				// Write via a .partial file so the converter never picks up a half-written file
+				if err := writeFileAtomic(newFilePath, content); err != nil {
 					color.Red("🛠️ Error writing file: %v", err)
-					os.Remove(newFilePath) // Clean up failed file
 					continue
 				}
 
@@ -369,7 +420,7 @@ func forceDeleteFile(filePath string) error {
 	return fmt.Errorf("failed to delete file after %d attempts", maxAttempts)
 }
 
-func processArchivesInDir(inputDir, outputDir string) {
+func processArchivesInDir(inputDir, outputDir string, workers int) {
 	if _, err := os.Stat(inputDir); os.IsNotExist(err) {
 		color.Red("🚫 Input directory %s does not exist.", inputDir)
 		return
@@ -398,14 +449,15 @@ func processArchivesInDir(inputDir, outputDir string) {
 			return
 		}
 
-		supportedFiles := 0
 		processedFiles := 0
 
+		var archives []string
 		for _, file := range files {
 			if strings.HasSuffix(file.Name(), ".zip") || strings.HasSuffix(file.Name(), ".rar") {
-				supportedFiles++
+				archives = append(archives, file.Name())
 			}
 		}
+		supportedFiles := len(archives)
 
 		if supportedFiles == 0 {
 			break
@@ -413,68 +465,102 @@ func processArchivesInDir(inputDir, outputDir string) {
 
 		color.Cyan("📂 Processing %d supported files in %s", supportedFiles, inputDir)
 
-		for _, file := range files {
-			filePath := filepath.Join(inputDir, file.Name())
-			var success, passwordFailed, shouldDelete bool
-			if strings.HasSuffix(file.Name(), ".zip") {
-				color.Blue("\n📦 Found ZIP archive: %s", filePath)
-				success, passwordFailed, shouldDelete = extractZIPFiles(filePath, outputDir, passwords)
-			} else if strings.HasSuffix(file.Name(), ".rar") {
-				color.Blue("\n📦 Found RAR archive: %s", filePath)
-				success, passwordFailed, shouldDelete = extractRARFiles(filePath, outputDir, passwords)
-			} else {
-				continue
-			}
-
-			if success {
-				// Successfully extracted, delete the archive
-				err := forceDeleteFile(filePath)
-				if err != nil {
-					color.Red("🛠️ Error deleting file: %v", err)
-					// If deletion failed, rename the file to prevent re-processing
-					newPath := filePath + ".processed"
-					if renameErr := os.Rename(filePath, newPath); renameErr != nil {
-						color.Red("❌ Failed to rename file: %v", renameErr)
-					} else {
-						color.Yellow("⚠️ Renamed file to: %s", newPath)
-					}
-				} else {
-					color.Green("🗑️ Deleted archive file: %s", filePath)
-					processedFiles++
-				}
-			} else if passwordFailed {
-				// Password protected but no correct password found, move to nopass
-				uniqueFilename := generateUniqueFilename(nopassDir, file.Name())
-				nopassPath := filepath.Join(nopassDir, uniqueFilename)
-				err := os.Rename(filePath, nopassPath)
-				if err != nil {
-					color.Red("🛠️ Error moving file to nopass: %v", err)
-				} else {
-					color.Yellow("🔒 Moved password-protected file to: %s", nopassPath)
-					processedFiles++
-				}
-			} else if shouldDelete {
-				// Archive couldn't be extracted by any means, delete it
-				err := forceDeleteFile(filePath)
-				if err != nil {
-					color.Red("🛠️ Error deleting unextractable file: %v", err)
-					// If deletion failed, rename the file to prevent re-processing
-					newPath := filePath + ".failed"
-					if renameErr := os.Rename(filePath, newPath); renameErr != nil {
-						color.Red("❌ Failed to rename failed file: %v", renameErr)
-					} else {
-						color.Yellow("⚠️ Renamed failed file to: %s", newPath)
+		reporter := progress.NewReporter("extraction", supportedFiles)
+		attempted := 0
+
+		// Archives are handed out to the workers in directory order
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		next := make(chan string)
+		for w := 0; w < max(workers, 1); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for name := range next {
+					mu.Lock()
+					reporter.Update(attempted, name)
+					attempted++
+					mu.Unlock()
+
+					if processArchive(inputDir, name, outputDir, nopassDir, passwords) {
+						mu.Lock()
+						processedFiles++
+						mu.Unlock()
 					}
-				} else {
-					color.Red("🗑️ Deleted unextractable archive: %s", filePath)
-					processedFiles++
 				}
-			}
+			}()
 		}
+		for _, name := range archives {
+			next <- name
+		}
+		close(next)
+		wg.Wait()
 
+		reporter.Update(attempted, "")
 		color.Yellow("Processed %d out of %d supported files", processedFiles, supportedFiles)
 	}
 
 	elapsed := time.Since(start)
 	color.Green("Total Extraction Time: %s", elapsed)
 }
+
+// processArchive extracts one archive and deletes it, or moves it to the
+// no-password directory; it reports whether the archive was dealt with
+func processArchive(inputDir, name, outputDir, nopassDir string, passwords []string) (processed bool) {
+	filePath := filepath.Join(inputDir, name)
+	var success, passwordFailed, shouldDelete bool
+	if strings.HasSuffix(name, ".zip") {
+		color.Blue("\n📦 Found ZIP archive: %s", filePath)
+		success, passwordFailed, shouldDelete = extractZIPFiles(filePath, outputDir, passwords)
+	} else {
+		color.Blue("\n📦 Found RAR archive: %s", filePath)
+		success, passwordFailed, shouldDelete = extractRARFiles(filePath, outputDir, passwords)
+	}
+
+	if success {
+		// Successfully extracted, delete the archive
+		err := forceDeleteFile(filePath)
+		if err != nil {
+			color.Red("🛠️ Error deleting file: %v", err)
+			// If deletion failed, rename the file to prevent re-processing
+			newPath := filePath + ".processed"
+			if renameErr := os.Rename(filePath, newPath); renameErr != nil {
+				color.Red("❌ Failed to rename file: %v", renameErr)
+			} else {
+				color.Yellow("⚠️ Renamed file to: %s", newPath)
+			}
+		} else {
+			color.Green("🗑️ Deleted archive file: %s", filePath)
+			processed = true
+		}
+	} else if passwordFailed {
+		// Password protected but no correct password found, move to nopass
+		uniqueFilename := generateUniqueFilename(nopassDir, name)
+		nopassPath := filepath.Join(nopassDir, uniqueFilename)
+		err := os.Rename(filePath, nopassPath)
+		if err != nil {
+			color.Red("🛠️ Error moving file to nopass: %v", err)
+		} else {
+			color.Yellow("🔒 Moved password-protected file to: %s", nopassPath)
+			processed = true
+		}
+	} else if shouldDelete {
+		// Archive couldn't be extracted by any means, delete it
+		err := forceDeleteFile(filePath)
+		if err != nil {
+			color.Red("🛠️ Error deleting unextractable file: %v", err)
+			// If deletion failed, rename the file to prevent re-processing
+			newPath := filePath + ".failed"
+			if renameErr := os.Rename(filePath, newPath); renameErr != nil {
+				color.Red("❌ Failed to rename failed file: %v", renameErr)
+			} else {
+				color.Yellow("⚠️ Renamed failed file to: %s", newPath)
+			}
+		} else {
+			color.Red("🗑️ Deleted unextractable archive: %s", filePath)
+			processed = true
+		}
+	}
+
+	return processed
+}
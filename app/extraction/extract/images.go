@@ -0,0 +1,122 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/yeka/zip"
+)
+
+// Environment variables configuring screenshot collection for OCR. When
+// ImagesEnvDir is unset, images in archives are skipped as before.
+const (
+	ImagesEnvDir      = "EXTRACT_IMAGES_DIR"      // Directory images are copied to
+	ImagesEnvMax      = "EXTRACT_IMAGES_MAX"      // Most images taken from one archive
+	ImagesEnvMaxBytes = "EXTRACT_IMAGE_MAX_BYTES" // Larger images are skipped
+)
+
+// Defaults when the limits are unset or invalid.
+const (
+	defaultImagesMax     = 20
+	defaultImageMaxBytes = 10 << 20
+	minScreenshotBytes   = 2 << 10 // Smaller images are icons, not screenshots
+)
+
+// imageExtensions are the image types the OCR stage can read.
+var imageExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".bmp":  true,
+	".tif":  true,
+	".tiff": true,
+}
+
+// imageCollector copies the screenshots of one archive to the OCR directory.
+type imageCollector struct {
+	dir      string
+	max      int
+	maxBytes int64
+	saved    int
+}
+
+// newImageCollector returns a collector for one archive, nil when OCR is off.
+func newImageCollector() *imageCollector {
+	dir := os.Getenv(ImagesEnvDir)
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		color.Red("🛠️ Error creating image directory: %v", err)
+		return nil
+	}
+
+	c := &imageCollector{dir: dir, max: defaultImagesMax, maxBytes: defaultImageMaxBytes}
+	if n, err := strconv.Atoi(os.Getenv(ImagesEnvMax)); err == nil && n > 0 {
+		c.max = n
+	}
+	if n, err := strconv.ParseInt(os.Getenv(ImagesEnvMaxBytes), 10, 64); err == nil && n > 0 {
+		c.maxBytes = n
+	}
+	return c
+}
+
+// wants reports whether an archive entry should be copied for OCR.
+func (c *imageCollector) wants(name string, size int64) bool {
+	if c == nil || c.saved >= c.max {
+		return false
+	}
+	if !imageExtensions[strings.ToLower(filepath.Ext(name))] {
+		return false
+	}
+	return size >= minScreenshotBytes && size <= c.maxBytes
+}
+
+// save writes an image read from the archive to the OCR directory.
+func (c *imageCollector) save(name string, r io.Reader) error {
+	// Read one byte past the limit so an entry whose header lied is caught
+	content, err := io.ReadAll(io.LimitReader(r, c.maxBytes+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(content)) > c.maxBytes {
+		return fmt.Errorf("image larger than %d bytes", c.maxBytes)
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	newFilename := outputName("image", ext)
+	if err := writeFileAtomic(filepath.Join(c.dir, newFilename), content); err != nil {
+		return err
+	}
+
+	color.Green("🖼️ Image saved for OCR: %s", newFilename)
+	c.saved++
+	return nil
+}
+
+// saveZIPImage copies a ZIP image entry, trying the passwords when it is encrypted.
+func (c *imageCollector) saveZIPImage(f *zip.File, passwords []string) {
+	for _, password := range passwords {
+		if f.IsEncrypted() {
+			f.SetPassword(password)
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			continue
+		}
+		err = c.save(f.Name, rc)
+		rc.Close()
+		if err == nil || !f.IsEncrypted() {
+			if err != nil {
+				color.Yellow("⚠️ Skipped image %s: %v", f.Name, err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package extract
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+)
+
+// source is the extractor source compiled into this binary, kept to detect
+// when the source on disk has changed since the build
+//
+//go:embed extract.go
+var source []byte
+
+// SourceHash returns the SHA-256 of the extractor source this binary was built from
+func SourceHash() string {
+	sum := sha256.Sum256(source)
+	return hex.EncodeToString(sum[:])
+}
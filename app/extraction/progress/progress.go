@@ -0,0 +1,102 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// EnvFile names the environment variable with the progress file path. The
+// extract and convert stages rewrite that file as they go; when it is unset
+// no progress is written
+const EnvFile = "EXTRACTION_PROGRESS_FILE"
+
+// Report is the content of a progress file
+type Report struct {
+	Stage     string    `json:"stage"`
+	Current   string    `json:"current,omitempty"`
+	Done      int       `json:"done"`
+	Total     int       `json:"total"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Percent returns how much of the stage is done, 0-100
+func (r *Report) Percent() float64 {
+	if r.Total <= 0 {
+		return 0
+	}
+	return float64(r.Done) * 100 / float64(r.Total)
+}
+
+// Reporter writes progress for one pass over a stage's input files
+type Reporter struct {
+	path   string
+	report Report
+}
+
+// NewReporter starts reporting progress for total files; it is a no-op when
+// EnvFile is unset
+func NewReporter(stage string, total int) *Reporter {
+	now := time.Now()
+	r := &Reporter{
+		path: os.Getenv(EnvFile),
+		report: Report{
+			Stage:     stage,
+			Total:     total,
+			StartedAt: now,
+			UpdatedAt: now,
+		},
+	}
+	r.write()
+	return r
+}
+
+// Update records that done files are finished and current is being processed
+func (r *Reporter) Update(done int, current string) {
+	r.report.Done = done
+	r.report.Current = current
+	r.report.UpdatedAt = time.Now()
+	r.write()
+}
+
+// write replaces the progress file atomically so readers never see half a report
+func (r *Reporter) write() {
+	if r.path == "" {
+		return
+	}
+
+	data, err := json.Marshal(r.report)
+	if err != nil {
+		return
+	}
+
+	// Progress is best effort and must never fail the stage itself
+	os.MkdirAll(filepath.Dir(r.path), 0755)
+	tmpPath := r.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return
+	}
+	if err := os.Rename(tmpPath, r.path); err != nil {
+		os.Remove(tmpPath)
+	}
+}
+
+// Read loads a progress file; it returns nil, nil if none was written yet
+func Read(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read progress file: %w", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to decode progress file: %w", err)
+	}
+	return &report, nil
+}
@@ -0,0 +1,42 @@
+package extraction
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PurgeStoreLines deletes credential lines entered before cutoff from every
+// shard of the store database and returns how many were deleted. The
+// duplicate filter keeps their keys until its next rebuild, so the same
+// lines are skipped as duplicates until then
+func PurgeStoreLines(ctx context.Context, cutoff time.Time, logger func(string, ...interface{})) (int64, error) {
+	cfg := LoadConfig()
+	if !cfg.RunDB {
+		return 0, fmt.Errorf("store database is disabled")
+	}
+
+	shardManager, err := NewShardManager(cfg, logger)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to store database: %w", err)
+	}
+	defer shardManager.Close()
+
+	// date_of_entry is a DATE, so lines entered on the cutoff day are kept
+	date := cutoff.Format("2006-01-02")
+
+	var total int64
+	for shard := 0; shard < shardManager.GetShardCount(); shard++ {
+		result, err := shardManager.GetShardConnection(shard).ExecContext(ctx,
+			"DELETE FROM `lines` WHERE date_of_entry < ?", date)
+		if err != nil {
+			return total, fmt.Errorf("failed to delete expired lines of shard %d: %w", shard, err)
+		}
+		count, _ := result.RowsAffected()
+		total += count
+		if logger != nil {
+			logger("✓ Retention: %d lines entered before %s deleted from shard %d", count, date, shard)
+		}
+	}
+	return total, nil
+}
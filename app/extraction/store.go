@@ -21,6 +21,8 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/sirupsen/logrus"
+	"telegram-archive-bot/app/extraction/compression"
+	"telegram-archive-bot/utils"
 )
 
 // ensureSchema creates the database table if it doesn't exist with proper constraints
@@ -876,11 +878,12 @@ func LoadConfig() Config {
 		RunDB:     true,
 		DBType:    "mysql",
 
-		// MySQL configuration for golexor.com database
-		MySQLHost: "91.204.209.6:3306",
-		MySQLUser: "golexorcom_urllist",
-		MySQLPass: "Iman@091124",
-		MySQLDB:   "golexorcom_data",
+		// MySQL configuration for golexor.com database; MYSQL_* settings
+		// override it and may reference a secret store
+		MySQLHost: storeSetting("MYSQL_HOST", "91.204.209.6:3306"),
+		MySQLUser: storeSetting("MYSQL_USER", "golexorcom_urllist"),
+		MySQLPass: storeSetting("MYSQL_PASSWORD", "Iman@091124"),
+		MySQLDB:   storeSetting("MYSQL_DATABASE", "golexorcom_data"),
 
 		SQLiteName: "local.db",
 
@@ -895,6 +898,17 @@ func LoadConfig() Config {
 	}
 }
 
+// storeSetting returns the setting name with secret references resolved, or
+// fallback when it is unset. Unresolvable references were already reported
+// by utils.LoadConfig, so they leave the value empty and the connection fails
+func storeSetting(name, fallback string) string {
+	if os.Getenv(name) == "" {
+		return fallback
+	}
+	value, _ := utils.SecretEnv(name)
+	return value
+}
+
 // LogManager handles structured logging with file output and rotation
 type LogManager struct {
 	logger   *logrus.Logger
@@ -1091,7 +1105,7 @@ func (s *StoreService) BackupFileWithTimestamp(filePath string) (string, error)
 	ext := filepath.Ext(originalFileName)
 	nameWithoutExt := strings.TrimSuffix(originalFileName, ext)
 	timestamp := time.Now().Format("20060102_150405")
-	backupFileName := fmt.Sprintf("%s_%s%s", nameWithoutExt, timestamp, ext)
+	backupFileName := fmt.Sprintf("%s_%s%s", nameWithoutExt, timestamp, ext) + s.archiveCodec.Extension()
 	backupPath := filepath.Join(backupRootDir, backupFileName)
 
 	// Perform atomic file copy with integrity checks
@@ -1150,12 +1164,19 @@ func (s *StoreService) atomicFileCopy(src, dst string) error {
 		}
 	}()
 
+	// Compress archived copies when a codec is configured
+	out, err := compression.NewWriter(dstFile, s.archiveCodec, s.archiveLevel)
+	if err != nil {
+		finalErr = fmt.Errorf("failed to create %s writer: %w", s.archiveCodec, err)
+		return finalErr
+	}
+
 	// Copy data with buffer for efficiency
 	buffer := make([]byte, 32*1024) // 32KB buffer for efficiency
 	for {
 		n, readErr := srcFile.Read(buffer)
 		if n > 0 {
-			written, writeErr := dstFile.Write(buffer[:n])
+			written, writeErr := out.Write(buffer[:n])
 			bytesTransferred += int64(written)
 			if writeErr != nil {
 				finalErr = fmt.Errorf("write error during copy: %w", writeErr)
@@ -1174,6 +1195,10 @@ func (s *StoreService) atomicFileCopy(src, dst string) error {
 			return finalErr
 		}
 	}
+	if err := out.Close(); err != nil {
+		finalErr = fmt.Errorf("failed to finish %s stream: %w", s.archiveCodec, err)
+		return finalErr
+	}
 
 	// Log successful read operation
 	s.logFileRead(src, readStartTime, bytesTransferred, nil)
@@ -1371,13 +1396,20 @@ func (tx *FileTransaction) restoreFromBackup(backupPath, originalPath string) er
 	}
 	defer srcFile.Close()
 
+	// Backups may be compressed; the codec is detected from the content
+	reader, codec, err := compression.NewReader(srcFile)
+	if err != nil {
+		return fmt.Errorf("failed to open %s backup %s: %w", codec, backupPath, err)
+	}
+	defer reader.Close()
+
 	dstFile, err := os.Create(originalPath)
 	if err != nil {
 		return fmt.Errorf("failed to create restored file %s: %w", originalPath, err)
 	}
 	defer dstFile.Close()
 
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
+	if _, err := io.Copy(dstFile, reader); err != nil {
 		return fmt.Errorf("failed to copy backup content: %w", err)
 	}
 
@@ -2876,11 +2908,11 @@ type BotFileSender interface {
 
 // TelegramBotSender implements BotFileSender for Telegram Bot API
 type TelegramBotSender struct {
-	bot *tgbotapi.BotAPI
+	bot utils.MessageSender
 }
 
 // NewTelegramBotSender creates a new TelegramBotSender instance
-func NewTelegramBotSender(bot *tgbotapi.BotAPI) *TelegramBotSender {
+func NewTelegramBotSender(bot utils.MessageSender) *TelegramBotSender {
 	return &TelegramBotSender{bot: bot}
 }
 
@@ -2904,6 +2936,8 @@ type StoreService struct {
 	logManager    *LogManager                  // Structured logging manager
 	botSender     BotFileSender               // Bot interface for sending files
 	adminChatID   int64                       // Admin chat ID for sending files
+	archiveCodec  compression.Codec           // Codec of the timestamped backups in files/backups
+	archiveLevel  int
 }
 
 // NewStoreService creates a new StoreService instance with structured logging
@@ -2926,11 +2960,12 @@ func NewStoreServiceWithBot(logger func(string, ...interface{}), botSender BotFi
 	}
 
 	service := &StoreService{
-		config:      config,
-		logger:      logger,
-		logManager:  logManager,
-		botSender:   botSender,
-		adminChatID: adminChatID,
+		config:       config,
+		logger:       logger,
+		logManager:   logManager,
+		botSender:    botSender,
+		adminChatID:  adminChatID,
+		archiveCodec: compression.None,
 	}
 
 	// Log service initialization
@@ -2949,6 +2984,13 @@ func NewStoreServiceWithBot(logger func(string, ...interface{}), botSender BotFi
 	return service
 }
 
+// SetArchiveCompression compresses the timestamped backups of output files
+// with the given codec and level (0 for the codec default)
+func (s *StoreService) SetArchiveCompression(codec compression.Codec, level int) {
+	s.archiveCodec = codec
+	s.archiveLevel = level
+}
+
 // Close gracefully closes the StoreService and its resources
 func (s *StoreService) Close() error {
 	if s.logManager != nil {
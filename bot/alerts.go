@@ -0,0 +1,348 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/monitoring"
+	"telegram-archive-bot/storage"
+)
+
+// Defaults of a rule added without level=, cooldown= or baseline=
+const (
+	defaultRuleLevel       = monitoring.AlertLevelWarning
+	defaultRuleCooldown    = 5 * time.Minute
+	defaultAnomalyBaseline = time.Hour
+)
+
+// alertRuleSettings are the key=value words of /alerts rule add and edit;
+// nil fields were not given
+type alertRuleSettings struct {
+	metric      *string
+	operator    *string
+	threshold   *float64
+	conditions  []monitoring.AlertCondition
+	forDuration *time.Duration
+	anomaly     *float64
+	baseline    *time.Duration
+	level       *monitoring.AlertLevel
+	cooldown    *time.Duration
+	paths       map[string]*float64 // nil removes the volume's own threshold
+}
+
+// parseAlertRuleSettings reads metric=, op=, threshold=, when=, for=,
+// anomaly=, baseline=, level=, cooldown= and path.<volume>= words
+func parseAlertRuleSettings(words []string) (alertRuleSettings, error) {
+	var s alertRuleSettings
+	for _, word := range words {
+		key, value, ok := strings.Cut(word, "=")
+		if !ok || value == "" {
+			return s, fmt.Errorf("settings are written as key=value, got %q", word)
+		}
+		if volume, ok := strings.CutPrefix(strings.ToLower(key), "path."); ok {
+			if s.paths == nil {
+				s.paths = make(map[string]*float64)
+			}
+			if strings.EqualFold(value, "default") {
+				s.paths[volume] = nil
+				continue
+			}
+			threshold, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return s, fmt.Errorf("the threshold of volume %s must be a percentage or default, got %q", volume, value)
+			}
+			s.paths[volume] = &threshold
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "metric":
+			metric := strings.ToLower(value)
+			s.metric = &metric
+		case "op":
+			s.operator = &value
+		case "threshold":
+			threshold, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return s, fmt.Errorf("threshold must be a number, got %q", value)
+			}
+			s.threshold = &threshold
+		case "when":
+			conditions, err := monitoring.ParseAlertConditions(value)
+			if err != nil {
+				return s, err
+			}
+			s.conditions = conditions
+		case "for", "baseline":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return s, fmt.Errorf("%s must be a duration such as 10m, got %q", key, value)
+			}
+			if strings.EqualFold(key, "for") {
+				s.forDuration = &d
+			} else {
+				s.baseline = &d
+			}
+		case "anomaly":
+			z, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return s, fmt.Errorf("anomaly must be a number of standard deviations, got %q", value)
+			}
+			s.anomaly = &z
+		case "level":
+			level := monitoring.AlertLevel(strings.ToUpper(value))
+			s.level = &level
+		case "cooldown":
+			cooldown, err := time.ParseDuration(value)
+			if err != nil {
+				return s, fmt.Errorf("cooldown must be a duration such as 10m, got %q", value)
+			}
+			s.cooldown = &cooldown
+		default:
+			return s, fmt.Errorf("unknown setting %q, use metric, op, threshold, when, for, anomaly, baseline, level, "+
+				"cooldown or path.<volume>", key)
+		}
+	}
+	return s, nil
+}
+
+// apply sets the given settings on rule. when= makes it a composite rule,
+// anomaly= an anomaly rule and metric=, op= or threshold= on a composite
+// rule a threshold rule
+func (s alertRuleSettings) apply(rule *monitoring.AlertRule) {
+	kind := rule.Kind
+	switch {
+	case s.conditions != nil:
+		rule.Kind = monitoring.AlertRuleComposite
+		rule.Conditions = s.conditions
+		rule.Metric, rule.Operator, rule.Threshold, rule.Baseline = "", "", 0, 0
+	case s.anomaly != nil:
+		rule.Kind = monitoring.AlertRuleAnomaly
+		rule.Threshold = *s.anomaly
+		rule.Conditions = nil
+		if rule.Operator != "<" {
+			rule.Operator = ">"
+		}
+		if rule.Baseline == 0 {
+			rule.Baseline = defaultAnomalyBaseline
+		}
+	case rule.Kind == monitoring.AlertRuleComposite && (s.metric != nil || s.operator != nil || s.threshold != nil):
+		rule.Kind = monitoring.AlertRuleThreshold
+		rule.Conditions = nil
+	}
+
+	if s.metric != nil {
+		rule.Metric = *s.metric
+	}
+	if s.operator != nil {
+		rule.Operator = *s.operator
+	}
+	if s.threshold != nil {
+		rule.Threshold = *s.threshold
+	}
+	if s.baseline != nil {
+		rule.Baseline = *s.baseline
+	}
+	if s.forDuration != nil {
+		rule.For = *s.forDuration
+	}
+	// The message of a built-in rule describes its metric and kind
+	if s.metric != nil || rule.Kind != kind {
+		rule.Message = ""
+	}
+	if s.level != nil {
+		rule.Level = *s.level
+	}
+	if s.cooldown != nil {
+		rule.Cooldown = *s.cooldown
+	}
+	// Volume thresholds only apply to disk threshold rules
+	if rule.Kind != monitoring.AlertRuleThreshold || rule.Metric != "disk_used_percent" {
+		rule.PathThresholds = nil
+	}
+	for volume, threshold := range s.paths {
+		if threshold == nil {
+			delete(rule.PathThresholds, volume)
+			continue
+		}
+		if rule.PathThresholds == nil {
+			rule.PathThresholds = make(map[string]float64)
+		}
+		rule.PathThresholds[volume] = *threshold
+	}
+}
+
+// handleAlertsCommand shows the active alerts, or lists and changes the alert rules:
+// /alerts [rule [list|add|edit|enable|disable|delete] [name] [key=value...]]
+func (tb *TelegramBot) handleAlertsCommand(message *tgbotapi.Message, args CommandArgs) {
+	if tb.health == nil {
+		tb.respond(message, "❌ Health monitoring is not available")
+		return
+	}
+	alerts := tb.health.GetAlertManager()
+
+	if !args.Has("rule") {
+		tb.respond(message, formatActiveAlerts(alerts.GetActiveAlerts()))
+		return
+	}
+
+	action := args.Get("action")
+	name := args.Get("name")
+	if action == "" || action == "list" {
+		tb.respond(message, formatAlertRules(alerts.GetRules()))
+		return
+	}
+	if name == "" {
+		tb.respond(message, args.Usage())
+		return
+	}
+	settings, err := parseAlertRuleSettings(args.Words()[3:])
+	if err != nil {
+		tb.respond(message, fmt.Sprintf("❌ %s\n\n%s", err, args.Usage()))
+		return
+	}
+
+	var found bool
+	switch action {
+	case "add":
+		if _, exists := alerts.GetRule(name); exists {
+			tb.respond(message, fmt.Sprintf("❌ Rule `%s` already exists, use `/alerts rule edit`", name))
+			return
+		}
+		if settings.conditions == nil && settings.metric == nil {
+			tb.respond(message, "❌ A new rule needs metric=, op= and threshold=, conditions in when=, "+
+				"or metric= and anomaly=\n\n"+formatAlertMetrics())
+			return
+		}
+		rule := monitoring.AlertRule{Name: name, Level: defaultRuleLevel, Cooldown: defaultRuleCooldown, Enabled: true}
+		settings.apply(&rule)
+		err = alerts.SaveRule(rule)
+		found = true
+	case "edit":
+		var rule monitoring.AlertRule
+		rule, found = alerts.GetRule(name)
+		if found {
+			settings.apply(&rule)
+			err = alerts.SaveRule(rule)
+		}
+	case "enable", "disable":
+		found, err = alerts.SetRuleEnabled(name, action == "enable")
+	case "delete":
+		found, err = alerts.DeleteRule(name)
+	}
+	if !found {
+		tb.respond(message, fmt.Sprintf("❌ No alert rule `%s`, see `/alerts rule list`", name))
+		return
+	}
+	if err != nil {
+		tb.logger.WithError(err).WithField("rule_name", name).Warn("Alert rule change rejected")
+		tb.respond(message, fmt.Sprintf("❌ %s", tgbotapi.EscapeText(tgbotapi.ModeMarkdown, err.Error())))
+		return
+	}
+
+	tb.auditLogger(message).LogSystemAction(message.From.ID, message.From.UserName, storage.AdminActionConfigChange,
+		"alert_rule", map[string]interface{}{"rule": name, "action": action, "settings": strings.Join(args.Words()[3:], " ")},
+		"success", nil)
+
+	if rule, ok := alerts.GetRule(name); ok {
+		tb.respond(message, "✅ *Alert rule updated*\n\n"+formatAlertRule(rule))
+		return
+	}
+	tb.respond(message, fmt.Sprintf("✅ Alert rule `%s` deleted", name))
+}
+
+// formatActiveAlerts lists the active alerts, most severe first
+func formatActiveAlerts(alerts []*monitoring.Alert) string {
+	if len(alerts) == 0 {
+		return "✅ No active alerts\n\nRules: `/alerts rule list`"
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Level != alerts[j].Level {
+			return alerts[i].Level == monitoring.AlertLevelCritical ||
+				(alerts[i].Level == monitoring.AlertLevelWarning && alerts[j].Level == monitoring.AlertLevelInfo)
+		}
+		return alerts[i].Timestamp.Before(alerts[j].Timestamp)
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🚨 *Active alerts (%d)*\n\n", len(alerts))
+	for _, alert := range alerts {
+		fmt.Fprintf(&b, "%s %s\n%s\n_since %s, seen %d time(s)_\n\n", alertLevelEmoji(alert.Level),
+			tgbotapi.EscapeText(tgbotapi.ModeMarkdown, alert.Title),
+			tgbotapi.EscapeText(tgbotapi.ModeMarkdown, alert.Message),
+			alert.Timestamp.Format("2006-01-02 15:04"), alert.Count)
+	}
+	b.WriteString("Rules: `/alerts rule list`")
+	return b.String()
+}
+
+// formatAlertRules lists the alert rules with how to change them
+func formatAlertRules(rules []monitoring.AlertRule) string {
+	var b strings.Builder
+	b.WriteString("📏 *Alert rules*\n\n")
+	for _, rule := range rules {
+		b.WriteString(formatAlertRule(rule))
+		b.WriteString("\n")
+	}
+	b.WriteString("\nChange with `/alerts rule edit <name> threshold=<n>`, " +
+		"`/alerts rule disable <name>`, or add one with " +
+		"`/alerts rule add <name> metric=<metric> op=> threshold=<n>`, " +
+		"`/alerts rule add <name> when=queue_depth:rising&throughput_per_hour:falling for=10m` " +
+		"or `/alerts rule add <name> metric=<metric> anomaly=3 baseline=1h`. " +
+		"Disk rules take a threshold per volume: `/alerts rule edit low_disk_space path.backups=95`\n\n")
+	b.WriteString(formatAlertMetrics())
+	return b.String()
+}
+
+// formatAlertRule describes one rule on a line
+func formatAlertRule(rule monitoring.AlertRule) string {
+	state := "✅"
+	if !rule.Enabled {
+		state = "⏸"
+	}
+	line := fmt.Sprintf("%s `%s` %s %s `%s`, cooldown %s", state, rule.Name, alertLevelEmoji(rule.Level),
+		rule.Kind, rule.Describe(), rule.Cooldown)
+	if rule.Builtin {
+		line += ", built in"
+	}
+	if !rule.LastFired.IsZero() {
+		line += ", last fired " + rule.LastFired.Format("2006-01-02 15:04")
+	}
+	return line
+}
+
+// formatAlertMetrics lists the metrics rules can use
+func formatAlertMetrics() string {
+	metrics := monitoring.AlertMetrics()
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("Metrics:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "• `%s` - %s\n", name, metrics[name])
+	}
+	b.WriteString("Conditions: `metric>n`, `>=`, `<`, `<=`, or `metric:rising`/`metric:falling` " +
+		"compared with 5 minutes earlier, joined by `&`\n")
+	b.WriteString("Levels: info, warning, critical")
+	return b.String()
+}
+
+// alertLevelEmoji marks an alert level
+func alertLevelEmoji(level monitoring.AlertLevel) string {
+	switch level {
+	case monitoring.AlertLevelCritical:
+		return "🚨"
+	case monitoring.AlertLevelWarning:
+		return "⚠️"
+	default:
+		return "ℹ️"
+	}
+}
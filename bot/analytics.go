@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/storage"
+)
+
+const (
+	// maxAnalyticsDays bounds the window of /analytics
+	maxAnalyticsDays = 90
+	// analyticsListSize is the number of entries in each /analytics list
+	analyticsListSize = 10
+)
+
+func (tb *TelegramBot) handleAnalyticsCommand(message *tgbotapi.Message, args CommandArgs) {
+	days := 7
+	if arg := args.Get("days"); arg != "" {
+		parsed, err := strconv.Atoi(arg)
+		if err != nil || parsed <= 0 || parsed > maxAnalyticsDays {
+			tb.respond(message, args.Usage())
+			return
+		}
+		days = parsed
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	analytics, err := tb.taskStore.GetDomainAnalytics(since, analyticsListSize)
+	if err != nil {
+		tb.logger.WithError(err).Error("Failed to compute domain analytics")
+		tb.respond(message, "❌ Failed to compute domain analytics")
+		return
+	}
+
+	tb.respond(message, formatDomainAnalytics(analytics, days))
+}
+
+// formatDomainAnalytics renders top domains, new domains and pass overlaps
+func formatDomainAnalytics(a *storage.DomainAnalytics, days int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📈 *Domain analytics, last %d days*\n", days)
+	fmt.Fprintf(&b, "%d credentials on %d domains\n", a.Credentials, a.Domains)
+
+	if a.Domains == 0 {
+		b.WriteString("\nNo converted credentials in this period.")
+		return b.String()
+	}
+
+	b.WriteString("\n*Top domains*\n")
+	for i, d := range a.TopDomains {
+		fmt.Fprintf(&b, "%d. `%s`: %d (%d passes)\n", i+1, escapeAnalytics(d.Domain), d.Credentials, d.Passes)
+	}
+
+	fmt.Fprintf(&b, "\n*New domains*: %d\n", a.NewDomainCount)
+	for _, d := range a.NewDomains {
+		fmt.Fprintf(&b, "• `%s`: %d, first seen %s\n", escapeAnalytics(d.Domain), d.Credentials,
+			d.FirstSeen.Local().Format("01-02 15:04"))
+	}
+
+	b.WriteString("\n*Overlap with earlier passes* (domains / credentials)\n")
+	for _, o := range a.Overlaps {
+		archives := "no tasks"
+		if len(o.Archives) == 1 {
+			archives = o.Archives[0]
+		} else if len(o.Archives) > 1 {
+			archives = fmt.Sprintf("%s +%d", o.Archives[0], len(o.Archives)-1)
+		}
+		fmt.Fprintf(&b, "• %s `%s`: %.0f%% / %.0f%% of %d domains\n", o.RecordedAt.Local().Format("01-02 15:04"),
+			escapeAnalytics(archives), o.DomainOverlap, o.CredentialOverlap, o.Domains)
+	}
+	return b.String()
+}
+
+// escapeAnalytics keeps a value from closing its code span
+func escapeAnalytics(s string) string {
+	return strings.ReplaceAll(s, "`", "'")
+}
@@ -0,0 +1,327 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// auditPageSize is the number of entries on one /audit page
+const auditPageSize = 10
+
+// auditCallbackPrefix starts the data of the /audit page buttons
+const auditCallbackPrefix = "audit"
+
+// auditQuery is one /audit page: the filters and the entries skipped. The
+// time range is fixed when /audit is sent, so paging back and forth shows
+// the same entries while new ones are logged
+type auditQuery struct {
+	userID  int64
+	action  string
+	session string
+	since   time.Time
+	until   time.Time
+	offset  int
+}
+
+// parseAuditFilters reads the user=, action=, since= and session= filters of
+// /audit, in any order. A session is shown as a whole, oldest first, so it
+// takes no other filters; this also keeps the page buttons under 64 bytes
+func parseAuditFilters(words []string, now time.Time) (auditQuery, error) {
+	q := auditQuery{until: now}
+	for _, word := range words {
+		key, value, ok := strings.Cut(word, "=")
+		if !ok || value == "" {
+			return q, fmt.Errorf("filters are written as key=value, got %q", word)
+		}
+		switch strings.ToLower(key) {
+		case "user":
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id <= 0 {
+				return q, fmt.Errorf("user must be a numeric Telegram user ID, got %q", value)
+			}
+			q.userID = id
+		case "action":
+			// Actions are upper case words; the length keeps the page buttons under 64 bytes
+			q.action = strings.ToUpper(value)
+			if len(q.action) > 24 || strings.Trim(q.action, "ABCDEFGHIJKLMNOPQRSTUVWXYZ_") != "" {
+				return q, fmt.Errorf("unknown action %q", value)
+			}
+		case "since":
+			d, err := parseAuditDuration(value)
+			if err != nil {
+				return q, err
+			}
+			q.since = now.Add(-d)
+		case "session":
+			q.session = strings.ToLower(value)
+			if len(q.session) > 16 || strings.Trim(q.session, "0123456789abcdef") != "" {
+				return q, fmt.Errorf("unknown session %q", value)
+			}
+		default:
+			return q, fmt.Errorf("unknown filter %q, use user, action, since or session", key)
+		}
+	}
+	if q.session != "" && len(words) > 1 {
+		return q, fmt.Errorf("session cannot be combined with other filters")
+	}
+	return q, nil
+}
+
+// parseAuditDuration accepts Go durations plus days, e.g. 90m, 24h or 7d
+func parseAuditDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	} else if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("since must be a duration such as 24h or 7d, got %q", value)
+}
+
+// callbackData encodes the query for the page at offset. Numbers are base 36
+// to stay within Telegram's 64 byte limit
+func (q auditQuery) callbackData(offset int) string {
+	var since string
+	if !q.since.IsZero() {
+		since = strconv.FormatInt(q.since.Unix(), 36)
+	}
+	return strings.Join([]string{
+		auditCallbackPrefix,
+		strconv.FormatInt(int64(offset), 36),
+		strconv.FormatInt(q.userID, 36),
+		q.action,
+		since,
+		strconv.FormatInt(q.until.Unix(), 36),
+		q.session,
+	}, ":")
+}
+
+// parseAuditCallback decodes the payload written by callbackData
+func parseAuditCallback(payload string) (auditQuery, error) {
+	var q auditQuery
+	parts := strings.Split(payload, ":")
+	if len(parts) == 5 {
+		// Buttons sent before sessions were tracked
+		parts = append(parts, "")
+	}
+	if len(parts) != 6 {
+		return q, fmt.Errorf("malformed audit page data %q", payload)
+	}
+
+	offset, err := strconv.ParseInt(parts[0], 36, 64)
+	if err != nil || offset < 0 {
+		return q, fmt.Errorf("malformed audit page offset %q", parts[0])
+	}
+	if q.userID, err = strconv.ParseInt(parts[1], 36, 64); err != nil {
+		return q, fmt.Errorf("malformed audit user %q", parts[1])
+	}
+	q.action = parts[2]
+	if parts[3] != "" {
+		since, err := strconv.ParseInt(parts[3], 36, 64)
+		if err != nil {
+			return q, fmt.Errorf("malformed audit start time %q", parts[3])
+		}
+		q.since = time.Unix(since, 0)
+	}
+	until, err := strconv.ParseInt(parts[4], 36, 64)
+	if err != nil {
+		return q, fmt.Errorf("malformed audit end time %q", parts[4])
+	}
+	q.until = time.Unix(until, 0)
+	q.session = parts[5]
+	q.offset = int(offset)
+	return q, nil
+}
+
+// handleAuditCommand shows the first page of the admin audit trail:
+// /audit [user=<id>] [action=<action>] [since=<duration>]
+func (tb *TelegramBot) handleAuditCommand(message *tgbotapi.Message, args CommandArgs) {
+	q, err := parseAuditFilters(args.Words(), time.Now())
+	if err != nil {
+		tb.respond(message, fmt.Sprintf("❌ %s\n\n%s", err, args.Usage()))
+		return
+	}
+
+	text, keyboard, err := tb.renderAuditPage(q)
+	if err != nil {
+		tb.logger.WithError(err).Warn("Failed to read audit trail")
+		tb.respond(message, "❌ Failed to read the audit trail")
+		return
+	}
+
+	if len(keyboard.InlineKeyboard) == 0 {
+		tb.respond(message, text)
+		return
+	}
+	if _, err := tb.sendKeyboard(message.Chat.ID, tb.messageThread(message), text, keyboard); err != nil {
+		tb.logger.WithError(err).Warn("Failed to send audit page")
+	}
+}
+
+// handleAuditCallback turns the page of an /audit message; the returned text
+// is shown to the admin when the page cannot be shown
+func (tb *TelegramBot) handleAuditCallback(query *tgbotapi.CallbackQuery, payload string) string {
+	if query.Message == nil {
+		return "The audit message is too old to page"
+	}
+	q, err := parseAuditCallback(payload)
+	if err != nil {
+		tb.logger.WithError(err).Warn("Invalid audit page button")
+		return "Invalid page"
+	}
+
+	text, keyboard, err := tb.renderAuditPage(q)
+	if err != nil {
+		tb.logger.WithError(err).Warn("Failed to read audit trail")
+		return "Failed to read the audit trail"
+	}
+	if err := tb.EditMessageKeyboard(query.Message.Chat.ID, query.Message.MessageID, text, keyboard); err != nil {
+		tb.logger.WithError(err).Debug("Failed to show audit page")
+	}
+	return ""
+}
+
+// renderAuditPage reads one page of entries and builds its message and
+// Previous/Next buttons. One extra entry is read to know whether a next page exists
+func (tb *TelegramBot) renderAuditPage(q auditQuery) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	audit := storage.NewAdminAuditLogger(tb.auditDB, &utils.Logger{Logger: tb.logger})
+	entries, err := audit.GetAuditEntries(storage.AuditFilters{
+		UserID:    q.userID,
+		Action:    q.action,
+		SessionID: q.session,
+		StartTime: q.since,
+		EndTime:   q.until,
+		Ascending: q.session != "",
+		Limit:     auditPageSize + 1,
+		Offset:    q.offset,
+	})
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	hasNext := len(entries) > auditPageSize
+	if hasNext {
+		entries = entries[:auditPageSize]
+	}
+
+	previous, next := "◀️ Newer", "Older ▶️"
+	if q.session != "" {
+		previous, next = "◀️ Earlier", "Later ▶️"
+	}
+	var buttons []tgbotapi.InlineKeyboardButton
+	if q.offset > 0 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(previous, q.callbackData(max(q.offset-auditPageSize, 0))))
+	}
+	if hasNext {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(next, q.callbackData(q.offset+auditPageSize)))
+	}
+	var keyboard tgbotapi.InlineKeyboardMarkup
+	if len(buttons) > 0 {
+		keyboard = tgbotapi.NewInlineKeyboardMarkup(buttons)
+	}
+
+	return formatAuditPage(q, entries), keyboard, nil
+}
+
+// formatAuditPage renders the entries of one /audit page
+func formatAuditPage(q auditQuery, entries []storage.AdminAuditEntry) string {
+	var b strings.Builder
+	b.WriteString("📜 *Admin audit trail*\n")
+
+	var filters []string
+	if q.userID != 0 {
+		filters = append(filters, fmt.Sprintf("user `%d`", q.userID))
+	}
+	if q.action != "" {
+		filters = append(filters, fmt.Sprintf("action `%s`", escapeCode(q.action)))
+	}
+	if !q.since.IsZero() {
+		filters = append(filters, "since "+q.since.Format("2006-01-02 15:04"))
+	}
+	if q.session != "" {
+		filters = append(filters, fmt.Sprintf("session `%s`", q.session))
+	}
+	if len(filters) > 0 {
+		fmt.Fprintf(&b, "Filters: %s\n", strings.Join(filters, ", "))
+	}
+
+	if len(entries) == 0 {
+		if q.offset > 0 && q.session != "" {
+			b.WriteString("\nNo later entries.")
+		} else if q.offset > 0 {
+			b.WriteString("\nNo older entries.")
+		} else {
+			b.WriteString("\nNo matching entries.")
+		}
+		return b.String()
+	}
+	order := "newest first"
+	if q.session != "" {
+		order = "oldest first"
+	}
+	fmt.Fprintf(&b, "Entries %d-%d, %s\n\n", q.offset+1, q.offset+len(entries), order)
+
+	for _, entry := range entries {
+		user := entry.Username
+		if user == "" {
+			user = strconv.FormatInt(entry.UserID, 10)
+		}
+		fmt.Fprintf(&b, "%s `%s` *%s*", auditResultIcon(entry.Result),
+			entry.Timestamp.Local().Format("01-02 15:04"), strings.ReplaceAll(string(entry.Action), "_", " "))
+		if entry.Resource != "" {
+			fmt.Fprintf(&b, " `%s`", escapeCode(truncateAudit(entry.Resource, 40)))
+		}
+		fmt.Fprintf(&b, "\n    by `%s`", escapeCode(user))
+		if entry.SessionID != "" && q.session == "" {
+			fmt.Fprintf(&b, " in session `%s`", entry.SessionID)
+		}
+		if entry.Duration > 0 {
+			fmt.Fprintf(&b, " in %s", formatLatency(time.Duration(entry.Duration)*time.Millisecond))
+		}
+		if entry.ErrorMsg != "" {
+			fmt.Fprintf(&b, "\n    `%s`", escapeCode(truncateAudit(entry.ErrorMsg, 80)))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// auditResultIcon marks an entry's result; loggers write results in either case
+func auditResultIcon(result string) string {
+	switch strings.ToUpper(result) {
+	case "SUCCESS":
+		return "✅"
+	case "FAILED", "STILL_UNAVAILABLE":
+		return "❌"
+	case "BLOCKED":
+		return "⛔"
+	case "RATE_LIMITED":
+		return "⏳"
+	case "SECURITY_EVENT":
+		return "🛡"
+	}
+	return "•"
+}
+
+// truncateAudit shortens a value to at most n runes
+func truncateAudit(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// escapeCode keeps a value from closing its Markdown code span
+func escapeCode(s string) string {
+	return strings.ReplaceAll(s, "`", "'")
+}
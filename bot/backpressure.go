@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"fmt"
+
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/storage"
+)
+
+// SetCostEstimator sets the cost model used for ETAs and admission
+func (tb *TelegramBot) SetCostEstimator(costs *storage.CostEstimator) {
+	tb.costs = costs
+}
+
+// costModel returns the current cost model, nil when there is none
+func (tb *TelegramBot) costModel() *storage.CostModel {
+	if tb.costs == nil {
+		return nil
+	}
+	model, err := tb.costs.Model()
+	if err != nil {
+		tb.logger.WithError(err).Warn("Failed to fit cost model")
+	}
+	return model
+}
+
+// queuePressure returns why the queue is full, "" while it takes new files.
+// The queue is full once the unfinished tasks reach QUEUE_MAX_DEPTH or the
+// fullest monitored volume, as of the last health check, reaches
+// QUEUE_MAX_DISK_PERCENT
+func (tb *TelegramBot) queuePressure() string {
+	if limit := tb.config.QueueMaxDepth; limit > 0 {
+		depth := 0
+		for _, status := range []models.TaskStatus{models.TaskStatusPending, models.TaskStatusDownloading, models.TaskStatusDownloaded} {
+			count, err := tb.taskStore.GetTaskCountByStatus(status)
+			if err != nil {
+				// Better to take a file too many than to turn users away on a hiccup
+				tb.logger.WithError(err).Warn("Failed to count queued tasks for backpressure")
+				return ""
+			}
+			depth += count
+		}
+		if depth >= limit {
+			return fmt.Sprintf("%d files are waiting, the limit is %d", depth, limit)
+		}
+	}
+
+	if limit := tb.config.QueueMaxDiskPercent; limit > 0 && tb.health != nil {
+		if check := tb.health.GetLastHealthCheck(); check != nil && check.SystemInfo.DiskPercent >= limit {
+			return fmt.Sprintf("disk space is %.0f%% used, the limit is %.0f%%", check.SystemInfo.DiskPercent, limit)
+		}
+	}
+	return ""
+}
+
+// admissionDenial returns why a file is refused on its predicted cost, "" to
+// admit it. A file is refused when it is estimated to take longer than
+// ADMISSION_MAX_TASK_TIME, or when the disk left after it and the files
+// queued before it, as of the last health check, would fall below
+// ADMISSION_MIN_FREE_DISK. Queued files count in full until downloaded and
+// by the text they extract to after
+func (tb *TelegramBot) admissionDenial(model *storage.CostModel, fileType string, fileSize int64) string {
+	if model == nil {
+		return ""
+	}
+	cost := model.Estimate(fileType, fileSize)
+
+	if limit := tb.config.AdmissionMaxTaskTime; limit > 0 && cost.Total() > limit {
+		return fmt.Sprintf("this file is estimated to take %s to process, the limit is %s",
+			formatLatency(cost.Total()), formatLatency(limit))
+	}
+
+	if limit := tb.config.AdmissionMinFreeDisk; limit > 0 && tb.health != nil {
+		check := tb.health.GetLastHealthCheck()
+		if check == nil {
+			return ""
+		}
+		queued, err := tb.taskStore.GetQueuedFiles()
+		if err != nil {
+			// As with backpressure, a hiccup should not turn users away
+			tb.logger.WithError(err).Warn("Failed to list queued files for admission")
+			return ""
+		}
+		needed := cost.DiskBytes
+		for _, f := range queued {
+			if f.Status == models.TaskStatusPending {
+				needed += model.Estimate(f.FileType, f.FileSize).DiskBytes
+			} else {
+				needed += model.ExtractedBytes(f.FileType, f.FileSize)
+			}
+		}
+		if left := check.SystemInfo.DiskFree - needed; left < limit {
+			return fmt.Sprintf("the queued files need about %s of the %s free, which would leave less than %s",
+				formatStatsBytes(needed), formatStatsBytes(check.SystemInfo.DiskFree), formatStatsBytes(limit))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+package bot
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// backupMessageInterval spaces the edits of a backup's progress message,
+// well under Telegram's edit rate limit
+const backupMessageInterval = 3 * time.Second
+
+// SetBackupService sets the backup service /backup runs
+func (tb *TelegramBot) SetBackupService(backups *storage.BackupService) {
+	tb.backups = backups
+}
+
+// handleBackupCommand takes a verified database backup, editing one message
+// with its progress, since large databases take minutes to dump
+func (tb *TelegramBot) handleBackupCommand(message *tgbotapi.Message, args CommandArgs) {
+	if tb.backups == nil {
+		tb.respond(message, "❌ Database backups are not available")
+		return
+	}
+	if !tb.backupRunning.CompareAndSwap(false, true) {
+		tb.respond(message, "⚠️ A backup is already running, try again when it has finished")
+		return
+	}
+	defer tb.backupRunning.Store(false)
+	defer utils.Maintenance.Begin("database backup")()
+
+	chatID := message.Chat.ID
+	messageID, err := tb.SendMessageToThread(chatID, tb.messageThread(message), "⏳ Starting database backup...")
+	if err != nil {
+		tb.logger.WithError(err).Warn("Failed to send backup progress message")
+	}
+
+	var lastEdit time.Time
+	progress := func(p storage.BackupProgress) {
+		if messageID == 0 || !p.Done && time.Since(lastEdit) < backupMessageInterval {
+			return
+		}
+		lastEdit = time.Now()
+		if err := tb.EditMessage(chatID, messageID, formatBackupProgress(p)); err != nil {
+			tb.logger.WithError(err).Debug("Failed to update backup progress message")
+		}
+	}
+
+	backupPath, err := tb.backups.CreateBackup(storage.BackupOptions{
+		Codec:        tb.config.BackupCompression,
+		Level:        tb.config.BackupCompressionLevel,
+		VerifyBackup: true,
+		Progress:     progress,
+	})
+
+	details := map[string]interface{}{"file": filepath.Base(backupPath)}
+	result := "success"
+	if err != nil {
+		result = "failed"
+	}
+	tb.auditLogger(message).LogSystemAction(message.From.ID, message.From.UserName, storage.AdminActionSystemDiag,
+		"database_backup", details, result, err)
+
+	var text string
+	if err != nil {
+		tb.logger.WithError(err).Warn("Database backup failed")
+		text = fmt.Sprintf("❌ Database backup failed: `%s`", strings.ReplaceAll(err.Error(), "`", "'"))
+	} else {
+		text = formatBackupResult(backupPath)
+	}
+	if messageID == 0 || tb.EditMessage(chatID, messageID, text) != nil {
+		tb.respond(message, text)
+	}
+}
+
+// formatBackupProgress renders a progress update of /backup and /restore
+func formatBackupProgress(p storage.BackupProgress) string {
+	var b strings.Builder
+	switch p.Phase {
+	case storage.BackupPhaseVerify:
+		b.WriteString("🔍 *Verifying backup*\n\n")
+	case storage.BackupPhaseRestore:
+		b.WriteString("♻️ *Restoring database*\n\n")
+	default:
+		b.WriteString("⏳ *Backing up database*\n\n")
+	}
+	fmt.Fprintf(&b, "`%s` %.0f%%\n", p.Bar(20), p.Percent())
+	fmt.Fprintf(&b, "Tables: %d/%d\n", p.TablesDone, p.TablesTotal)
+	if p.RowsTotal > 0 {
+		fmt.Fprintf(&b, "Rows: %d/%d\n", p.RowsDone, p.RowsTotal)
+	}
+	if p.BytesTotal > 0 {
+		fmt.Fprintf(&b, "Restored: %s of %s\n", formatStatsBytes(p.Bytes), formatStatsBytes(p.BytesTotal))
+	} else {
+		fmt.Fprintf(&b, "Written: %s\n", formatStatsBytes(p.Bytes))
+	}
+	return b.String()
+}
+
+// formatBackupResult describes a finished backup from its catalog entry
+func formatBackupResult(backupPath string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "✅ *Database backup created*\n\n`%s`\n", filepath.Base(backupPath))
+	meta, err := storage.ReadBackupMetadata(backupPath)
+	if err != nil || meta == nil {
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Size: %s (%s)\n", formatStatsBytes(meta.Size), meta.Codec)
+	fmt.Fprintf(&b, "Rows: %d in %d tables\n", meta.TotalRows(), len(meta.RowCounts))
+	fmt.Fprintf(&b, "Schema version: %d\n", meta.SchemaVersion)
+	fmt.Fprintf(&b, "Duration: %s\n", formatLatency(time.Duration(meta.Duration*float64(time.Second))))
+	if v := meta.Verification; v != nil && v.OK {
+		fmt.Fprintf(&b, "Verified: integrity %s, row counts match\n", v.Integrity)
+	}
+	return b.String()
+}
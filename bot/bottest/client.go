@@ -0,0 +1,299 @@
+// Package bottest provides a fake Telegram client, so the bot and download
+// workers can be exercised end to end without network access or a bot token
+package bottest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Call is one request the fake client received
+type Call struct {
+	Method string // Bot API method, e.g. sendMessage or sendDocument
+	ChatID int64
+	Text   string // Message text, or a document's caption
+	// Chattable is what was passed to Send or Request, nil for MakeRequest
+	Chattable tgbotapi.Chattable
+	// Params are the parameters passed to MakeRequest, nil otherwise
+	Params tgbotapi.Params
+}
+
+// Client is an in-memory utils.TelegramClient. Updates added with AddUpdate
+// are returned by the next getUpdates request, files added with AddFile are
+// returned by GetFile, and every other request is recorded and succeeds. It
+// is safe for concurrent use
+type Client struct {
+	// Self is the bot's own account
+	Self tgbotapi.User
+
+	mu            sync.Mutex
+	updates       []tgbotapi.Update
+	nextUpdateID  int
+	nextMessageID int
+	files         map[string]tgbotapi.File
+	calls         []Call
+	failures      map[string]error
+	stopped       bool
+}
+
+// NewClient returns a fake client for a bot named test_bot
+func NewClient() *Client {
+	return &Client{
+		Self:          tgbotapi.User{ID: 1000, IsBot: true, FirstName: "Test", UserName: "test_bot"},
+		nextUpdateID:  1,
+		nextMessageID: 1,
+		files:         make(map[string]tgbotapi.File),
+		failures:      make(map[string]error),
+	}
+}
+
+// AddUpdate queues an update for getUpdates, numbering it when UpdateID is 0
+func (c *Client) AddUpdate(update tgbotapi.Update) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if update.UpdateID == 0 {
+		update.UpdateID = c.nextUpdateID
+	}
+	if update.UpdateID >= c.nextUpdateID {
+		c.nextUpdateID = update.UpdateID + 1
+	}
+	c.updates = append(c.updates, update)
+}
+
+// AddMessage queues a text message from userID in their private chat;
+// commands like /stats are marked as such
+func (c *Client) AddMessage(userID int64, text string) {
+	c.AddUpdate(tgbotapi.Update{Message: c.message(userID, text)})
+}
+
+// AddDocument queues a document upload from userID and registers it for
+// GetFile; filePath is what GetFile reports, e.g. documents/file_1.zip
+func (c *Client) AddDocument(userID int64, fileName string, size int64, filePath string) tgbotapi.Document {
+	c.mu.Lock()
+	fileID := fmt.Sprintf("file-%d", len(c.files)+1)
+	c.mu.Unlock()
+
+	document := tgbotapi.Document{
+		FileID:       fileID,
+		FileUniqueID: fileID + "-unique",
+		FileName:     fileName,
+		FileSize:     int(size),
+	}
+	c.AddFile(tgbotapi.File{FileID: fileID, FileUniqueID: document.FileUniqueID, FileSize: int(size), FilePath: filePath})
+
+	message := c.message(userID, "")
+	message.Document = &document
+	c.AddUpdate(tgbotapi.Update{Message: message})
+	return document
+}
+
+// AddFile registers a file GetFile returns
+func (c *Client) AddFile(file tgbotapi.File) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[file.FileID] = file
+}
+
+// FailNext makes the next request for method (e.g. sendMessage, getFile)
+// fail with err, such as a *tgbotapi.Error carrying a flood wait
+func (c *Client) FailNext(method string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures[method] = err
+}
+
+// Calls returns the recorded requests, oldest first; getUpdates polls are
+// not recorded
+func (c *Client) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// CallsTo returns the recorded requests of one method
+func (c *Client) CallsTo(method string) []Call {
+	var calls []Call
+	for _, call := range c.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Send records c and returns a message with the next message ID
+func (c *Client) Send(chattable tgbotapi.Chattable) (tgbotapi.Message, error) {
+	resp, err := c.Request(chattable)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+	var message tgbotapi.Message
+	if err := json.Unmarshal(resp.Result, &message); err != nil {
+		return tgbotapi.Message{}, err
+	}
+	return message, nil
+}
+
+// Request returns the queued updates for a tgbotapi.UpdateConfig and records
+// anything else
+func (c *Client) Request(chattable tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	if config, ok := chattable.(tgbotapi.UpdateConfig); ok {
+		return c.getUpdates(config)
+	}
+
+	return c.record(describe(chattable))
+}
+
+// MakeRequest records a raw request
+func (c *Client) MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error) {
+	chatID, _ := strconv.ParseInt(params["chat_id"], 10, 64)
+	return c.record(Call{Method: endpoint, ChatID: chatID, Text: params["text"], Params: params})
+}
+
+// GetFile returns a file registered with AddFile or AddDocument
+func (c *Client) GetFile(config tgbotapi.FileConfig) (tgbotapi.File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.takeFailure("getFile"); err != nil {
+		return tgbotapi.File{}, err
+	}
+	file, ok := c.files[config.FileID]
+	if !ok {
+		return tgbotapi.File{}, &tgbotapi.Error{Code: 400, Message: "Bad Request: invalid file_id"}
+	}
+	return file, nil
+}
+
+// StopReceivingUpdates marks the client stopped
+func (c *Client) StopReceivingUpdates() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+}
+
+// Stopped reports whether StopReceivingUpdates was called
+func (c *Client) Stopped() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stopped
+}
+
+// getUpdates returns the queued updates from config.Offset on, like the real
+// long poll but without waiting
+func (c *Client) getUpdates(config tgbotapi.UpdateConfig) (*tgbotapi.APIResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.takeFailure("getUpdates"); err != nil {
+		return nil, err
+	}
+
+	// Confirmed updates are dropped, as Telegram does
+	pending := c.updates[:0]
+	for _, update := range c.updates {
+		if update.UpdateID >= config.Offset {
+			pending = append(pending, update)
+		}
+	}
+	c.updates = pending
+
+	updates := pending
+	if config.Limit > 0 && len(updates) > config.Limit {
+		updates = updates[:config.Limit]
+	}
+	result, err := json.Marshal(updates)
+	if err != nil {
+		return nil, err
+	}
+	return &tgbotapi.APIResponse{Ok: true, Result: result}, nil
+}
+
+// record stores a call and answers it with a message, the shape most
+// methods return
+func (c *Client) record(call Call) (*tgbotapi.APIResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.takeFailure(call.Method); err != nil {
+		return nil, err
+	}
+	c.calls = append(c.calls, call)
+
+	message := tgbotapi.Message{
+		MessageID: c.nextMessageID,
+		Date:      int(time.Now().Unix()),
+		Chat:      &tgbotapi.Chat{ID: call.ChatID},
+		Text:      call.Text,
+	}
+	c.nextMessageID++
+	result, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	return &tgbotapi.APIResponse{Ok: true, Result: result}, nil
+}
+
+// takeFailure returns and clears the failure set for method; c.mu must be held
+func (c *Client) takeFailure(method string) error {
+	err := c.failures[method]
+	delete(c.failures, method)
+	return err
+}
+
+// message builds a private chat message from userID
+func (c *Client) message(userID int64, text string) *tgbotapi.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	message := &tgbotapi.Message{
+		MessageID: c.nextMessageID,
+		From:      &tgbotapi.User{ID: userID, FirstName: "User"},
+		Date:      int(time.Now().Unix()),
+		Chat:      &tgbotapi.Chat{ID: userID, Type: "private"},
+		Text:      text,
+	}
+	c.nextMessageID++
+
+	// Telegram marks a leading /command so Message.IsCommand recognizes it
+	if strings.HasPrefix(text, "/") {
+		length := strings.IndexAny(text, " \n")
+		if length < 0 {
+			length = len(text)
+		}
+		message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: length}}
+	}
+	return message
+}
+
+// describe records the configs the bot sends; their parameters are not
+// exported by tgbotapi
+func describe(chattable tgbotapi.Chattable) Call {
+	call := Call{Chattable: chattable}
+	switch config := chattable.(type) {
+	case tgbotapi.MessageConfig:
+		call.Method, call.ChatID, call.Text = "sendMessage", config.ChatID, config.Text
+	case tgbotapi.DocumentConfig:
+		call.Method, call.ChatID, call.Text = "sendDocument", config.ChatID, config.Caption
+	case tgbotapi.EditMessageTextConfig:
+		call.Method, call.ChatID, call.Text = "editMessageText", config.ChatID, config.Text
+	case tgbotapi.CallbackConfig:
+		call.Method, call.Text = "answerCallbackQuery", config.Text
+	case tgbotapi.InlineConfig:
+		call.Method = "answerInlineQuery"
+	case tgbotapi.DeleteMessageConfig:
+		call.Method, call.ChatID = "deleteMessage", config.ChatID
+	case tgbotapi.ChatActionConfig:
+		call.Method, call.ChatID = "sendChatAction", config.ChatID
+	default:
+		call.Method = fmt.Sprintf("%T", chattable)
+	}
+	return call
+}
@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/utils"
+)
+
+// handleCallbackQuerySafe handles an inline keyboard button press, recovering
+// from panics like handleUpdateSafe
+func (tb *TelegramBot) handleCallbackQuerySafe(query *tgbotapi.CallbackQuery) (handled bool) {
+	fields := map[string]interface{}{"callback_query_id": query.ID}
+	if query.From != nil {
+		fields["user_id"] = query.From.ID
+	}
+	defer utils.RecoverPanic("telegram_callback_query", fields)
+
+	tb.handleCallbackQuery(query)
+	return true
+}
+
+// handleCallbackQuery routes a button press to the command that sent the
+// keyboard. Button data is "<command>:<payload>", so pages need no state
+// on the bot side; Telegram limits it to 64 bytes
+func (tb *TelegramBot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
+	answer := tgbotapi.NewCallback(query.ID, "")
+	if query.From == nil || !tb.isAdmin(query.From.ID) {
+		if query.From != nil {
+			tb.logger.WithField("user_id", query.From.ID).Warn("Unauthorized button press")
+		}
+		answer.Text = "⛔ Not allowed"
+		tb.answerCallbackQuery(answer)
+		return
+	}
+
+	command, payload, _ := strings.Cut(query.Data, ":")
+	switch command {
+	case auditCallbackPrefix:
+		answer.Text = tb.handleAuditCallback(query, payload)
+	case restoreCallbackPrefix:
+		answer.Text = tb.handleRestoreCallback(query, payload)
+	default:
+		answer.Text = "This button is no longer supported"
+	}
+	tb.answerCallbackQuery(answer)
+}
+
+// answerCallbackQuery stops the button's loading indicator, showing text if set
+func (tb *TelegramBot) answerCallbackQuery(answer tgbotapi.CallbackConfig) {
+	err := tb.withFloodWait(func() error {
+		_, err := tb.bot.Request(answer)
+		return err
+	})
+	if err != nil {
+		tb.logger.WithError(err).Debug("Failed to answer callback query")
+	}
+}
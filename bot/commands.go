@@ -0,0 +1,276 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// CommandPermission is who may run a command
+type CommandPermission int
+
+const (
+	// PermissionAdmin allows every user in ADMIN_IDS
+	PermissionAdmin CommandPermission = iota
+	// PermissionOwner allows only the first user in ADMIN_IDS
+	PermissionOwner
+)
+
+// CommandArg describes one positional command argument
+type CommandArg struct {
+	Name      string
+	Hint      string // Shown after the name in usage, e.g. an accepted range
+	Prompt    string // Asked when the command is sent without arguments
+	Optional  bool
+	Choices   []string // Accepted values, matched case-insensitively; any value when empty
+	ReplyTask bool     // May be left out when the command replies to a task message
+}
+
+// Command is a bot command with its argument schema and handler
+type Command struct {
+	Name        string
+	Args        []CommandArg
+	Description string
+	Examples    []string
+	Permission  CommandPermission
+	Handler     func(message *tgbotapi.Message, args CommandArgs)
+}
+
+// CommandArgs are the parsed arguments of one command invocation
+type CommandArgs struct {
+	command *Command
+	values  map[string]string
+	words   []string
+}
+
+// Get returns the named argument, "" when it was left out. Arguments with
+// choices are returned lower-cased
+func (a CommandArgs) Get(name string) string {
+	return a.values[name]
+}
+
+// Has reports whether the named argument was given
+func (a CommandArgs) Has(name string) bool {
+	return a.values[name] != ""
+}
+
+// Words returns the raw argument words, for commands whose optional
+// arguments may come in any order
+func (a CommandArgs) Words() []string {
+	return a.words
+}
+
+// Usage returns the usage message of the command
+func (a CommandArgs) Usage() string {
+	return a.command.Usage()
+}
+
+// Synopsis renders the command and its arguments, e.g. "/priority <id> <high|normal|low>"
+func (c *Command) Synopsis() string {
+	parts := []string{"/" + c.Name}
+	for _, arg := range c.Args {
+		label := arg.Name
+		if len(arg.Choices) > 0 {
+			label = strings.Join(arg.Choices, "|")
+		}
+		if arg.Hint != "" {
+			label += ", " + arg.Hint
+		}
+
+		switch {
+		case arg.Optional:
+			parts = append(parts, "["+label+"]")
+		case len(arg.Choices) == 1:
+			// A required keyword such as "capture" is written as is
+			parts = append(parts, label)
+		default:
+			parts = append(parts, "<"+label+">")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Usage returns the message shown when the command is called with invalid arguments
+func (c *Command) Usage() string {
+	usage := "Usage: " + c.Synopsis()
+	for _, arg := range c.Args {
+		if arg.ReplyTask {
+			usage += fmt.Sprintf(" (or reply to a task message with /%s)", c.Name)
+			break
+		}
+	}
+	if len(c.Examples) > 0 {
+		usage += "\nExamples: `" + strings.Join(c.Examples, "`, `") + "`"
+	}
+	return usage
+}
+
+// ArgumentFlow returns a conversation asking for each required argument in
+// turn, for a command sent without arguments, or nil when it needs none.
+// Answers are checked against the argument choices, and run receives them as
+// if they had been given on the command line
+func (c *Command) ArgumentFlow(run func(message *tgbotapi.Message, args CommandArgs)) *ConversationFlow {
+	flow := &ConversationFlow{Name: c.Name}
+	keywords := make(map[string]string)
+	for _, arg := range c.Args {
+		if arg.Optional {
+			continue
+		}
+		if len(arg.Choices) == 1 {
+			// There is nothing to ask for a keyword such as "capture"
+			keywords[arg.Name] = arg.Choices[0]
+			continue
+		}
+		arg := arg
+		prompt := arg.Prompt
+		if prompt == "" {
+			prompt = fmt.Sprintf("Send the %s", arg.Name)
+			if len(arg.Choices) > 0 {
+				prompt += ": " + strings.Join(arg.Choices, ", ")
+			}
+		}
+		flow.Steps = append(flow.Steps, ConversationStep{
+			Name:   arg.Name,
+			Prompt: prompt + " (or /cancel)",
+			Validate: func(input string) (string, error) {
+				if input == "" || strings.ContainsAny(input, " \t\n") {
+					return "", fmt.Errorf("send a single word")
+				}
+				if len(arg.Choices) == 0 {
+					return input, nil
+				}
+				if !containsChoice(arg.Choices, input) {
+					return "", fmt.Errorf("%s must be one of %s", arg.Name, strings.Join(arg.Choices, ", "))
+				}
+				return strings.ToLower(input), nil
+			},
+		})
+	}
+	if len(flow.Steps) == 0 {
+		return nil
+	}
+
+	flow.Complete = func(message *tgbotapi.Message, values map[string]string) {
+		for name, keyword := range keywords {
+			values[name] = keyword
+		}
+		args := CommandArgs{command: c, values: values}
+		for _, arg := range c.Args {
+			if value := values[arg.Name]; value != "" {
+				args.words = append(args.words, value)
+			}
+		}
+		run(message, args)
+	}
+	return flow
+}
+
+// Parse checks the message's arguments against the schema. A ReplyTask
+// argument left out of a reply to a task message is returned as ""
+func (c *Command) Parse(message *tgbotapi.Message) (CommandArgs, error) {
+	args := CommandArgs{command: c, values: make(map[string]string)}
+	words := strings.Fields(message.CommandArguments())
+	args.words = words
+
+	required := 0
+	replyIndex := -1
+	for i, arg := range c.Args {
+		if !arg.Optional {
+			required++
+		}
+		if arg.ReplyTask {
+			replyIndex = i
+		}
+	}
+
+	positional := words
+	if message.ReplyToMessage != nil && replyIndex >= 0 && len(words) < required {
+		positional = make([]string, 0, len(words)+1)
+		positional = append(positional, words[:replyIndex]...)
+		positional = append(positional, "")
+		positional = append(positional, words[replyIndex:]...)
+	}
+	if len(positional) < required {
+		return args, fmt.Errorf("/%s needs %d argument(s), got %d", c.Name, required, len(words))
+	}
+	if len(positional) > len(c.Args) {
+		return args, fmt.Errorf("/%s takes at most %d argument(s), got %d", c.Name, len(c.Args), len(words))
+	}
+
+	for i, value := range positional {
+		arg := c.Args[i]
+		if value != "" && len(arg.Choices) > 0 {
+			value = strings.ToLower(value)
+			if !containsChoice(arg.Choices, value) {
+				return args, fmt.Errorf("%s must be one of %s", arg.Name, strings.Join(arg.Choices, ", "))
+			}
+		}
+		args.values[arg.Name] = value
+	}
+	return args, nil
+}
+
+func containsChoice(choices []string, value string) bool {
+	for _, choice := range choices {
+		if strings.EqualFold(choice, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// CommandRegistry dispatches bot commands by name and generates /help
+type CommandRegistry struct {
+	commands []*Command
+	byName   map[string]*Command
+}
+
+// NewCommandRegistry creates an empty registry
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{byName: make(map[string]*Command)}
+}
+
+// Register adds a command. Commands are listed in /help in registration
+// order; registering a name twice is a programming error and panics
+func (r *CommandRegistry) Register(cmd *Command) {
+	if _, exists := r.byName[cmd.Name]; exists {
+		panic(fmt.Sprintf("bot command /%s registered twice", cmd.Name))
+	}
+	r.commands = append(r.commands, cmd)
+	r.byName[cmd.Name] = cmd
+}
+
+// Lookup returns the command with the given name
+func (r *CommandRegistry) Lookup(name string) (*Command, bool) {
+	cmd, ok := r.byName[strings.ToLower(name)]
+	return cmd, ok
+}
+
+// Commands returns all commands in registration order
+func (r *CommandRegistry) Commands() []*Command {
+	return r.commands
+}
+
+// HelpLines lists the commands allowed by the permission check, one
+// "/name <args> - description" line each
+func (r *CommandRegistry) HelpLines(allowed func(CommandPermission) bool) string {
+	var b strings.Builder
+	for _, cmd := range r.commands {
+		if allowed != nil && !allowed(cmd.Permission) {
+			continue
+		}
+		fmt.Fprintf(&b, "%s - %s\n", cmd.Synopsis(), cmd.Description)
+	}
+	return b.String()
+}
+
+// hasPermission reports whether a user may run commands with the given permission
+func (tb *TelegramBot) hasPermission(userID int64, permission CommandPermission) bool {
+	switch permission {
+	case PermissionOwner:
+		return len(tb.config.AdminIDs) > 0 && tb.config.AdminIDs[0] == userID
+	default:
+		return tb.isAdmin(userID)
+	}
+}
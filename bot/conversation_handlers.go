@@ -0,0 +1,38 @@
+package bot
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleConversationAnswer passes a plain text message to the sender's
+// running conversation, if any, and sends the next prompt
+func (tb *TelegramBot) handleConversationAnswer(message *tgbotapi.Message) {
+	reply, ok := tb.conversations.Answer(message)
+	if !ok {
+		return
+	}
+	if reply != "" {
+		tb.respond(message, reply)
+	}
+}
+
+// cancelConversation ends the sender's conversation on a bare /cancel and
+// reports whether it did. /cancel with a task ID still cancels the task
+func (tb *TelegramBot) cancelConversation(message *tgbotapi.Message) bool {
+	if message.Command() != "cancel" || message.CommandArguments() != "" || message.ReplyToMessage != nil {
+		return false
+	}
+	flow, ok := tb.conversations.Cancel(message)
+	if !ok {
+		return false
+	}
+	tb.respond(message, "🛑 /"+flow.Name+" cancelled, nothing was changed.")
+	return true
+}
+
+// conversationTimedOut tells the user a conversation expired waiting for an answer
+func (tb *TelegramBot) conversationTimedOut(chatID int64, threadID int, flow *ConversationFlow) {
+	if _, err := tb.SendMessageToThread(chatID, threadID, "⌛ /"+flow.Name+" timed out waiting for an answer, nothing was changed."); err != nil {
+		tb.logger.WithError(err).WithField("flow", flow.Name).Warn("Failed to send conversation timeout notice")
+	}
+}
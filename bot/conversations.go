@@ -0,0 +1,171 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DefaultConversationTimeout is how long a conversation waits for each answer
+const DefaultConversationTimeout = 2 * time.Minute
+
+// ConversationStep asks for one input of a multi-step flow
+type ConversationStep struct {
+	Name   string
+	Prompt string
+	// Validate checks an answer and returns the value to store; an error
+	// repeats the prompt with the error message
+	Validate func(input string) (string, error)
+}
+
+// ConversationFlow is a multi-step interaction: each step's prompt is sent in
+// turn and Complete runs with every answer once the last step is answered
+type ConversationFlow struct {
+	Name     string
+	Steps    []ConversationStep
+	Timeout  time.Duration // Per step; DefaultConversationTimeout when zero
+	Complete func(message *tgbotapi.Message, values map[string]string)
+}
+
+// conversationKey identifies a conversation; a user may have one per chat
+type conversationKey struct {
+	chatID int64
+	userID int64
+}
+
+// conversation is the state of one running flow
+type conversation struct {
+	flow     *ConversationFlow
+	step     int
+	values   map[string]string
+	threadID int
+	timer    *time.Timer
+	deadline time.Time
+}
+
+// ConversationManager tracks the running conversation of each user
+type ConversationManager struct {
+	mu     sync.Mutex
+	active map[conversationKey]*conversation
+
+	// onTimeout is called after a conversation expires without an answer
+	onTimeout func(chatID int64, threadID int, flow *ConversationFlow)
+}
+
+// NewConversationManager creates a manager; onTimeout may be nil
+func NewConversationManager(onTimeout func(chatID int64, threadID int, flow *ConversationFlow)) *ConversationManager {
+	return &ConversationManager{
+		active:    make(map[conversationKey]*conversation),
+		onTimeout: onTimeout,
+	}
+}
+
+// Start begins a flow for the message's sender, replacing any conversation
+// they had in that chat, and returns the first prompt
+func (cm *ConversationManager) Start(message *tgbotapi.Message, threadID int, flow *ConversationFlow) string {
+	key := conversationKey{chatID: message.Chat.ID, userID: message.From.ID}
+	conv := &conversation{flow: flow, values: make(map[string]string), threadID: threadID}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.stopLocked(key)
+	cm.active[key] = conv
+	cm.armLocked(key, conv)
+	return flow.Steps[0].Prompt
+}
+
+// Cancel ends the sender's conversation and reports whether there was one
+func (cm *ConversationManager) Cancel(message *tgbotapi.Message) (*ConversationFlow, bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	key := conversationKey{chatID: message.Chat.ID, userID: message.From.ID}
+	conv, ok := cm.active[key]
+	if !ok {
+		return nil, false
+	}
+	cm.stopLocked(key)
+	return conv.flow, true
+}
+
+// Answer feeds a message to the sender's conversation. It returns the reply
+// to send and whether the message belonged to a conversation at all. When
+// the last step is answered the flow's Complete runs before Answer returns
+func (cm *ConversationManager) Answer(message *tgbotapi.Message) (string, bool) {
+	key := conversationKey{chatID: message.Chat.ID, userID: message.From.ID}
+
+	cm.mu.Lock()
+	conv, ok := cm.active[key]
+	if !ok {
+		cm.mu.Unlock()
+		return "", false
+	}
+
+	step := conv.flow.Steps[conv.step]
+	value := strings.TrimSpace(message.Text)
+	if step.Validate != nil {
+		validated, err := step.Validate(value)
+		if err != nil {
+			cm.armLocked(key, conv)
+			cm.mu.Unlock()
+			return fmt.Sprintf("❌ %s\n%s", err.Error(), step.Prompt), true
+		}
+		value = validated
+	}
+	conv.values[step.Name] = value
+	conv.step++
+
+	if conv.step < len(conv.flow.Steps) {
+		cm.armLocked(key, conv)
+		prompt := conv.flow.Steps[conv.step].Prompt
+		cm.mu.Unlock()
+		return prompt, true
+	}
+
+	cm.stopLocked(key)
+	cm.mu.Unlock()
+
+	conv.flow.Complete(message, conv.values)
+	return "", true
+}
+
+// armLocked restarts the step timeout of a conversation
+func (cm *ConversationManager) armLocked(key conversationKey, conv *conversation) {
+	if conv.timer != nil {
+		conv.timer.Stop()
+	}
+	timeout := conv.flow.Timeout
+	if timeout <= 0 {
+		timeout = DefaultConversationTimeout
+	}
+	conv.deadline = time.Now().Add(timeout)
+	conv.timer = time.AfterFunc(timeout, func() { cm.expire(key, conv) })
+}
+
+// stopLocked removes a conversation and stops its timeout
+func (cm *ConversationManager) stopLocked(key conversationKey) {
+	if conv, ok := cm.active[key]; ok {
+		if conv.timer != nil {
+			conv.timer.Stop()
+		}
+		delete(cm.active, key)
+	}
+}
+
+// expire drops a conversation whose step timed out, unless it has since
+// been answered, cancelled or replaced
+func (cm *ConversationManager) expire(key conversationKey, conv *conversation) {
+	cm.mu.Lock()
+	if cm.active[key] != conv || time.Now().Before(conv.deadline) {
+		cm.mu.Unlock()
+		return
+	}
+	delete(cm.active, key)
+	cm.mu.Unlock()
+
+	if cm.onTimeout != nil {
+		cm.onTimeout(key.chatID, conv.threadID, conv.flow)
+	}
+}
@@ -0,0 +1,159 @@
+package bot
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/monitoring"
+	"telegram-archive-bot/storage"
+)
+
+// handleDiagCommand runs the self-diagnostics on demand or exports them as a
+// bundle: /diag run [suite], /diag bundle [send]
+func (tb *TelegramBot) handleDiagCommand(message *tgbotapi.Message, args CommandArgs) {
+	if tb.health == nil {
+		tb.respond(message, "❌ Health monitoring is not available")
+		return
+	}
+
+	option := args.Get("suite|send")
+	if args.Get("action") == "bundle" {
+		if option != "" && option != "send" {
+			tb.respond(message, args.Usage())
+			return
+		}
+		tb.handleDiagBundle(message, option == "send")
+		return
+	}
+	if option == "send" {
+		tb.respond(message, args.Usage())
+		return
+	}
+
+	var suites []string
+	if option != "" {
+		suites = append(suites, option)
+	}
+	name := "all"
+	if len(suites) > 0 {
+		name = suites[0]
+	}
+
+	tb.respond(message, fmt.Sprintf("⏳ Running %s diagnostics...", name))
+	result, err := tb.health.RunDiagnostics(suites...)
+
+	details := map[string]interface{}{"suite": name}
+	outcome := "failed"
+	if err == nil {
+		details["overall_status"] = string(result.OverallStatus)
+		details["checks_run"] = len(result.Results)
+		outcome = "success"
+	}
+	tb.auditLogger(message).LogSystemAction(message.From.ID, message.From.UserName, storage.AdminActionSystemDiag,
+		"diagnostics_run", details, outcome, err)
+
+	if err != nil {
+		tb.logger.WithError(err).Warn("Diagnostics run failed")
+		tb.respond(message, fmt.Sprintf("❌ Diagnostics failed: `%s`", strings.ReplaceAll(err.Error(), "`", "'")))
+		return
+	}
+	tb.respond(message, formatDiagnostics(result, name))
+}
+
+// handleDiagBundle writes a diagnostics bundle to the backup directory and
+// sends it to the admin on request
+func (tb *TelegramBot) handleDiagBundle(message *tgbotapi.Message, send bool) {
+	if !tb.diagBundleRunning.CompareAndSwap(false, true) {
+		tb.respond(message, "⚠️ A diagnostics bundle is already being written, try again when it has finished")
+		return
+	}
+	defer tb.diagBundleRunning.Store(false)
+
+	tb.respond(message, "⏳ Running diagnostics and writing the bundle...")
+	bundle, err := tb.health.CreateDiagnosticsBundle(monitoring.DiagnosticsBundleOptions{
+		Dir:      tb.config.BackupDir,
+		Password: tb.config.DiagBundlePassword,
+		LogFile:  tb.config.LogFilePath,
+	})
+
+	details := map[string]interface{}{"sent": send}
+	result := "failed"
+	if err == nil {
+		details["file"] = filepath.Base(bundle.Path)
+		details["files"] = bundle.Files
+		details["problems"] = bundle.Problems
+		result = "success"
+	}
+	tb.auditLogger(message).LogSystemAction(message.From.ID, message.From.UserName, storage.AdminActionSystemDiag,
+		"diagnostics_bundle", details, result, err)
+
+	if err != nil {
+		tb.logger.WithError(err).Warn("Diagnostics bundle failed")
+		tb.respond(message, fmt.Sprintf("❌ Diagnostics bundle failed: `%s`", strings.ReplaceAll(err.Error(), "`", "'")))
+		return
+	}
+	tb.respond(message, formatDiagBundle(bundle))
+
+	if !send {
+		return
+	}
+	if err := tb.SendDocument(message.Chat.ID, bundle.Path, ""); err != nil {
+		tb.logger.WithError(err).WithField("file", filepath.Base(bundle.Path)).Warn("Failed to send diagnostics bundle")
+		tb.respond(message, fmt.Sprintf("❌ Failed to send `%s`", filepath.Base(bundle.Path)))
+	}
+}
+
+// formatDiagBundle summarizes a written diagnostics bundle
+func formatDiagBundle(bundle *monitoring.DiagnosticsBundle) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "✅ *Diagnostics bundle written*\n`%s`\n\n", bundle.Path)
+	for _, file := range bundle.Files {
+		fmt.Fprintf(&b, "• `%s`\n", file)
+	}
+	if len(bundle.Problems) > 0 {
+		b.WriteString("\n⚠️ *Not collected:*\n")
+		for _, problem := range bundle.Problems {
+			fmt.Fprintf(&b, "• %s\n", strings.ReplaceAll(problem, "`", "'"))
+		}
+	}
+	if bundle.GeneratedPassword {
+		fmt.Fprintf(&b, "\n🔑 Password: `%s`\nSet `DIAG_BUNDLE_PASSWORD` to use a fixed one", bundle.Password)
+	} else {
+		b.WriteString("\n🔑 Encrypted with `DIAG_BUNDLE_PASSWORD`")
+	}
+	return b.String()
+}
+
+// formatDiagnostics renders a diagnostics run for /diag
+func formatDiagnostics(suite *monitoring.DiagnosticSuite, name string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s *Diagnostics (%s): %s*\n", diagStatusEmoji(suite.OverallStatus), name, suite.OverallStatus)
+	fmt.Fprintf(&b, "%d checks in %s\n\n", len(suite.Results), suite.Duration.Round(time.Millisecond))
+
+	for _, r := range suite.Results {
+		fmt.Fprintf(&b, "%s `%s`", diagStatusEmoji(r.Status), r.Name)
+		if r.Message != "" {
+			fmt.Fprintf(&b, ": %s", strings.ReplaceAll(r.Message, "`", "'"))
+		}
+		fmt.Fprintf(&b, " (%s)\n", r.Duration.Round(time.Millisecond))
+	}
+	return b.String()
+}
+
+// diagStatusEmoji marks a diagnostic status
+func diagStatusEmoji(status monitoring.HealthStatus) string {
+	switch status {
+	case monitoring.HealthStatusHealthy:
+		return "✅"
+	case monitoring.HealthStatusDegraded:
+		return "⚠️"
+	case monitoring.HealthStatusUnhealthy:
+		return "🔴"
+	default:
+		return "❔"
+	}
+}
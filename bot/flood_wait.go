@@ -0,0 +1,33 @@
+package bot
+
+import (
+	"time"
+
+	"telegram-archive-bot/utils"
+)
+
+// maxFloodWaitRetries bounds how often one request is repeated after a 429
+const maxFloodWaitRetries = 2
+
+// withFloodWait runs send and, when Telegram answers 429 Too Many Requests,
+// waits exactly the retry_after it gave before trying again. Retrying sooner
+// only extends the flood wait
+func (tb *TelegramBot) withFloodWait(send func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := send()
+		wait, limited := utils.TelegramRetryAfter(err)
+		if !limited || attempt >= maxFloodWaitRetries {
+			return err
+		}
+
+		tb.logger.WithField("retry_after", wait).
+			WithField("attempt", attempt+1).
+			Warn("Telegram flood wait, delaying request")
+
+		select {
+		case <-tb.stopChan:
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
@@ -11,13 +11,57 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"telegram-archive-bot/models"
+	"telegram-archive-bot/monitoring"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
 )
 
+// handleUpdateSafe runs handleUpdate with panic recovery and crash reporting,
+// and reports whether it returned without panicking. threadID is the forum
+// topic the message was posted in, used to route replies
+func (tb *TelegramBot) handleUpdateSafe(update tgbotapi.Update, threadID int) (handled bool) {
+	fields := map[string]interface{}{"update_id": update.UpdateID}
+	if update.Message != nil {
+		fields["chat_id"] = update.Message.Chat.ID
+		if update.Message.From != nil {
+			fields["user_id"] = update.Message.From.ID
+		}
+		if update.Message.Document != nil {
+			fields["file_name"] = update.Message.Document.FileName
+		}
+	}
+	defer utils.RecoverPanic("telegram_handler", fields)
+
+	if update.Message != nil && threadID != 0 {
+		fields["message_thread_id"] = threadID
+		tb.messageThreads.Store(update.Message, threadID)
+		defer tb.messageThreads.Delete(update.Message)
+	}
+
+	tb.handleUpdate(update)
+	return true
+}
+
 func (tb *TelegramBot) handleUpdate(update tgbotapi.Update) {
 	// Check if user is admin
 	if !tb.isAdmin(update.Message.From.ID) {
 		tb.logger.WithField("user_id", update.Message.From.ID).
 			Warn("Unauthorized access attempt")
+		// Recorded so repeated attempts raise an alert; plain chatter in a
+		// group the bot is in is not an attempt
+		attempted := ""
+		switch {
+		case update.Message.IsCommand():
+			attempted = "/" + update.Message.Command()
+		case update.Message.Document != nil:
+			attempted = "upload"
+		case update.Message.Chat.IsPrivate():
+			attempted = "message"
+		}
+		if attempted != "" {
+			storage.NewAdminAuditLogger(tb.auditDB, &utils.Logger{Logger: tb.logger}).
+				LogUnauthorizedAttempt(update.Message.From.ID, update.Message.From.UserName, attempted, "")
+		}
 		// Silently ignore non-admin messages (don't respond)
 		return
 	}
@@ -33,6 +77,11 @@ func (tb *TelegramBot) handleUpdate(update tgbotapi.Update) {
 		tb.handleDocument(update.Message)
 		return
 	}
+
+	// Answers to a multi-step command
+	if update.Message.Text != "" {
+		tb.handleConversationAnswer(update.Message)
+	}
 }
 
 func (tb *TelegramBot) isAdmin(userID int64) bool {
@@ -44,22 +93,156 @@ func (tb *TelegramBot) isAdmin(userID int64) bool {
 	return false
 }
 
+// registerCommands registers every bot command; /help lists them in this order
+func (tb *TelegramBot) registerCommands() {
+	taskID := CommandArg{Name: "id", Prompt: "Send the task ID", ReplyTask: true}
+	maxCPUSeconds := int(utils.MaxCPUProfileDuration.Seconds())
+
+	for _, cmd := range []*Command{
+		{Name: "start", Description: "Welcome message", Handler: tb.handleStartCommand},
+		{Name: "help", Description: "This help message", Handler: tb.handleHelpCommand},
+		{Name: "queue", Description: "Show queue statistics (pending, downloading, processing)", Handler: tb.handleQueueCommand},
+		{Name: "stats", Args: []CommandArg{
+			{Name: "detailed", Optional: true, Choices: []string{"detailed"}},
+			{Name: "window", Optional: true, Choices: []string{"1h", "24h", "7d"}},
+		}, Description: "Overall system statistics, or stage percentiles and throughput per window", Handler: tb.handleStatsCommand},
+		{Name: "task", Args: []CommandArg{taskID},
+			Description: "Show status and details of a task", Handler: tb.handleTaskCommand},
+		{Name: "cancel", Args: []CommandArg{taskID},
+			Description: "Cancel a task that is still queued", Handler: tb.handleCancelCommand},
+		{Name: "delete", Args: []CommandArg{taskID},
+			Description: "Delete a finished task and purge its files after a grace period", Handler: tb.handleDeleteCommand},
+		{Name: "undelete", Args: []CommandArg{taskID},
+			Description: "Take back a deletion before the task's files are purged", Handler: tb.handleUndeleteCommand},
+		{Name: "retry", Args: []CommandArg{taskID},
+			Description: "Queue a finished or failed task again with the same file and settings", Handler: tb.handleRetryCommand},
+		{Name: "reprocess", Args: []CommandArg{{Name: "action", Optional: true, Choices: []string{"start", "cancel"}}},
+			Description: "Show parser versions, or convert passes made with an older parser again", Handler: tb.handleReprocessCommand},
+		{Name: "priority", Args: []CommandArg{taskID, {Name: "level", Prompt: "Send the priority: high, normal or low", Choices: []string{"high", "normal", "low"}}},
+			Description: "Move a queued task up or down the queue", Handler: tb.handlePriorityCommand},
+		{Name: "signatures", Args: []CommandArg{{Name: "action", Optional: true, Choices: []string{"reload"}}},
+			Description: "Show or reload the security signature definitions", Handler: tb.handleSignaturesCommand},
+		{Name: "sla", Args: []CommandArg{{Name: "YYYY-MM", Optional: true}},
+			Description: "Monthly uptime per component", Handler: tb.handleSLACommand},
+		{Name: "healthlog", Args: []CommandArg{{Name: "hours", Hint: "1-168", Optional: true}},
+			Description: "Component status changes from the health history", Handler: tb.handleHealthLogCommand},
+		{Name: "diag", Args: []CommandArg{
+			{Name: "action", Choices: []string{"run", "bundle"}},
+			{Name: "suite|send", Optional: true, Choices: append([]string{"send"}, monitoring.DiagnosticSuiteNames...)},
+		}, Description: "Run the self-diagnostics now, or export them with logs and config as an encrypted zip",
+			Examples: []string{"/diag run", "/diag run database", "/diag bundle send"},
+			Handler:  tb.handleDiagCommand},
+		{Name: "analytics", Args: []CommandArg{{Name: "days", Hint: fmt.Sprintf("1-%d", maxAnalyticsDays), Optional: true}},
+			Description: "Top domains, new domains and pass overlap of converted data", Handler: tb.handleAnalyticsCommand},
+		{Name: "profile", Args: []CommandArg{
+			{Name: "action", Choices: []string{"capture"}},
+			{Name: "cpu seconds", Hint: fmt.Sprintf("0-%d", maxCPUSeconds), Optional: true},
+			{Name: "send", Optional: true},
+		}, Description: "Capture heap, goroutine and CPU profiles", Handler: tb.handleProfileCommand},
+		{Name: "audit", Args: []CommandArg{
+			{Name: "user=<id>", Optional: true},
+			{Name: "action=<action>", Optional: true},
+			{Name: "since=<duration>", Hint: "e.g. 24h, 7d", Optional: true},
+			{Name: "session=<id>", Optional: true},
+		}, Description: "Browse the admin audit trail, newest first, or one admin session",
+			Examples: []string{"/audit", "/audit action=COMMAND since=24h", "/audit user=123456789", "/audit session=3f9c2a1b"},
+			Handler:  tb.handleAuditCommand},
+		{Name: "backup", Description: "Back up and verify the database, with progress",
+			Handler: tb.handleBackupCommand},
+		{Name: "restore", Args: []CommandArg{{Name: "backup", Optional: true}},
+			Description: "List backups, or restore one once a second admin approves",
+			Examples:    []string{"/restore", "/restore bot_backup_20260101_030000.sql.gz"},
+			Handler:     tb.handleRestoreCommand},
+		{Name: "throttle", Args: []CommandArg{{Name: "rate|off|auto", Optional: true}, {Name: "duration", Optional: true}},
+			Description: "Show or override the download bandwidth limit",
+			Examples:    []string{"/throttle 10MB 2h", "/throttle off 30m", "/throttle auto"},
+			Handler:     tb.handleThrottleCommand},
+		{Name: "limits", Args: []CommandArg{
+			{Name: "stage", Optional: true, Choices: []string{utils.StageDownloads, utils.StageExtractions, utils.StageConversions}},
+			{Name: "limit", Hint: fmt.Sprintf("1-%d", utils.MaxStageConcurrency), Optional: true},
+		},
+			Description: "Show or change how many downloads, extractions and conversions run at once",
+			Examples:    []string{"/limits", "/limits downloads 5", "/limits conversions 2"},
+			Handler:     tb.handleLimitsCommand},
+		{Name: "pipeline", Args: []CommandArg{
+			{Name: "format", Optional: true, Choices: []string{monitoring.GraphFormatMermaid, monitoring.GraphFormatDOT}},
+		},
+			Description: "Show where the backlog sits as a Mermaid or Graphviz graph",
+			Examples:    []string{"/pipeline", "/pipeline dot"},
+			Handler:     tb.handlePipelineCommand},
+		{Name: "version", Description: "Show the running build and available updates", Handler: tb.handleVersionCommand},
+		{Name: "status", Description: "Show overall health with the build and schema version", Handler: tb.handleStatusCommand},
+		{Name: "alerts", Args: []CommandArg{
+			{Name: "rule", Optional: true, Choices: []string{"rule"}},
+			{Name: "action", Optional: true, Choices: []string{"list", "add", "edit", "enable", "disable", "delete"}},
+			{Name: "name", Optional: true},
+			{Name: "metric=<metric>", Optional: true},
+			{Name: "op=<op>", Optional: true},
+			{Name: "threshold=<n>", Optional: true},
+			{Name: "when=<conditions>", Optional: true},
+			{Name: "for=<duration>", Optional: true},
+			{Name: "anomaly=<z>", Optional: true},
+			{Name: "baseline=<duration>", Optional: true},
+			{Name: "level=<level>", Optional: true},
+			{Name: "cooldown=<duration>", Optional: true},
+		},
+			Description: "Show active alerts, or list and change the alert rules",
+			Examples: []string{"/alerts rule edit high_cpu_usage threshold=90", "/alerts rule add big_queue metric=queue_depth op=> threshold=200 level=critical",
+				"/alerts rule add slowdown when=queue_depth:rising&throughput_per_hour:falling for=10m", "/alerts rule add cpu_spike metric=cpu_percent anomaly=3 baseline=2h",
+				"/alerts rule disable high_load_average"},
+			Handler: tb.handleAlertsCommand},
+	} {
+		tb.commands.Register(cmd)
+	}
+}
+
+// handleCommand checks the command's permission and arguments before running it.
+// A command sent without its required arguments asks for them one at a time
 func (tb *TelegramBot) handleCommand(message *tgbotapi.Message) {
-	switch message.Command() {
-	case "start":
-		tb.handleStartCommand(message)
-	case "help":
-		tb.handleHelpCommand(message)
-	case "queue":
-		tb.handleQueueCommand(message)
-	case "stats":
-		tb.handleStatsCommand(message)
-	default:
-		tb.SendMessage(message.Chat.ID, "Unknown command. Send /help for available commands.")
+	if tb.cancelConversation(message) {
+		return
+	}
+
+	cmd, ok := tb.commands.Lookup(message.Command())
+	if !ok {
+		tb.respond(message, "Unknown command. Send /help for available commands.")
+		return
 	}
+
+	if !tb.hasPermission(message.From.ID, cmd.Permission) {
+		tb.logger.WithField("user_id", message.From.ID).
+			WithField("command", cmd.Name).
+			Warn("Command denied by permission")
+		tb.respond(message, "⛔ You are not allowed to use this command.")
+		return
+	}
+
+	run := func(message *tgbotapi.Message, args CommandArgs) {
+		tb.runCommand(cmd, message, args)
+	}
+	args, err := cmd.Parse(message)
+	if err != nil {
+		if flow := cmd.ArgumentFlow(run); flow != nil && len(args.Words()) == 0 && message.ReplyToMessage == nil {
+			tb.respond(message, tb.conversations.Start(message, tb.messageThread(message), flow))
+			return
+		}
+		tb.respond(message, args.Usage())
+		return
+	}
+
+	run(message, args)
+}
+
+// runCommand runs a command and records it in the sender's audit session
+func (tb *TelegramBot) runCommand(cmd *Command, message *tgbotapi.Message, args CommandArgs) {
+	audit := tb.auditLogger(message)
+	start := time.Now()
+	cmd.Handler(message, args)
+	audit.LogCommand(message.From.ID, message.From.UserName, "/"+cmd.Name,
+		strings.Join(args.Words(), " "), "SUCCESS", time.Since(start), nil)
 }
 
-func (tb *TelegramBot) handleStartCommand(message *tgbotapi.Message) {
+func (tb *TelegramBot) handleStartCommand(message *tgbotapi.Message, args CommandArgs) {
 	text := `👋 Welcome to Telegram Archive Bot (Option 1)
 
 📤 Send me files to process:
@@ -70,22 +253,24 @@ func (tb *TelegramBot) handleStartCommand(message *tgbotapi.Message) {
 /help - Show this help message
 /queue - View queue status
 /stats - View processing statistics
+/task <id> - Show task details
 
 🔄 Files are processed sequentially for maximum reliability!`
 
-	tb.SendMessage(message.Chat.ID, text)
+	tb.respond(message, text)
 }
 
-func (tb *TelegramBot) handleHelpCommand(message *tgbotapi.Message) {
+func (tb *TelegramBot) handleHelpCommand(message *tgbotapi.Message, args CommandArgs) {
+	commands := tb.commands.HelpLines(func(permission CommandPermission) bool {
+		return tb.hasPermission(message.From.ID, permission)
+	})
 	text := `📚 Available Commands:
 
-/start - Welcome message
-/help - This help message
-/queue - Show queue statistics (pending, downloading, processing)
-/stats - Overall system statistics
-
+` + commands + `
 📤 File Upload:
 Simply send a file (ZIP, RAR, or TXT) and it will be queued for processing.
+Each file gets a short task ID (e.g. 01A7) that all task commands accept.
+Task commands also work as a reply to an upload or to a bot message about it.
 
 ⚡ Processing Pipeline (Sequential):
 1. Download (3 concurrent workers)
@@ -94,10 +279,10 @@ Simply send a file (ZIP, RAR, or TXT) and it will be queued for processing.
 
 Files are processed one stage at a time for stability and reliability.`
 
-	tb.SendMessage(message.Chat.ID, text)
+	tb.respond(message, text)
 }
 
-func (tb *TelegramBot) handleQueueCommand(message *tgbotapi.Message) {
+func (tb *TelegramBot) handleQueueCommand(message *tgbotapi.Message, args CommandArgs) {
 	// Get queue statistics
 	pending, _ := tb.taskStore.GetTaskCountByStatus(models.TaskStatusPending)
 	downloading, _ := tb.taskStore.GetTaskCountByStatus(models.TaskStatusDownloading)
@@ -112,10 +297,19 @@ func (tb *TelegramBot) handleQueueCommand(message *tgbotapi.Message) {
 Processing is sequential - one stage at a time for reliability.`,
 		pending, downloading, downloaded)
 
-	tb.SendMessage(message.Chat.ID, text)
+	tb.respond(message, text)
 }
 
-func (tb *TelegramBot) handleStatsCommand(message *tgbotapi.Message) {
+func (tb *TelegramBot) handleStatsCommand(message *tgbotapi.Message, args CommandArgs) {
+	if args.Has("detailed") {
+		tb.handleDetailedStats(message, args.Get("window"))
+		return
+	}
+	if args.Has("window") {
+		tb.respond(message, args.Usage())
+		return
+	}
+
 	// Get overall statistics
 	completed, _ := tb.taskStore.GetTaskCountByStatus(models.TaskStatusCompleted)
 	failed, _ := tb.taskStore.GetTaskCountByStatus(models.TaskStatusFailed)
@@ -125,66 +319,145 @@ func (tb *TelegramBot) handleStatsCommand(message *tgbotapi.Message) {
 • Completed: %d files
 • Failed: %d files
 
-Use /queue to see current queue status.`,
+Use /queue to see current queue status, or /stats detailed for latencies.`,
 		completed, failed)
 
-	tb.SendMessage(message.Chat.ID, text)
+	tb.respond(message, text)
 }
 
 func (tb *TelegramBot) handleDocument(message *tgbotapi.Message) {
 	doc := message.Document
 
+	// A restore replaces the task table, so a task queued now would be lost
+	if utils.Maintenance.Paused() {
+		tb.respond(message, "🛠 The bot is paused for maintenance. Please resubmit this file when it has finished.")
+		return
+	}
+
 	// Validate file size
 	maxSize := tb.config.MaxFileSizeMB * 1024 * 1024
 	if int64(doc.FileSize) > maxSize {
-		tb.SendMessage(message.Chat.ID, fmt.Sprintf("❌ File too large. Max size: %d MB", tb.config.MaxFileSizeMB))
+		tb.respond(message, fmt.Sprintf("❌ File too large. Max size: %d MB", tb.config.MaxFileSizeMB))
 		return
 	}
 
 	// Detect file type
 	fileType := tb.detectFileType(doc.FileName)
 	if fileType == "" {
-		tb.SendMessage(message.Chat.ID, "❌ Unsupported file type. Supported: ZIP, RAR, TXT")
+		tb.respond(message, "❌ Unsupported file type. Supported: ZIP, RAR, TXT")
+		return
+	}
+
+	// Reject re-forwards of a file already queued or processed before downloading it again
+	existing, err := tb.taskStore.GetByFileUniqueID(doc.FileUniqueID)
+	if err != nil {
+		tb.logger.WithError(err).Warn("Failed to check for duplicate file")
+	} else if existing != nil {
+		tb.logger.WithFields(logrus.Fields{
+			"task_id":  existing.ID,
+			"filename": doc.FileName,
+			"user_id":  message.From.ID,
+		}).Info("Duplicate file rejected before download")
+		tb.respond(message, fmt.Sprintf("♻️ This file was already submitted as task `%s` (%s). Only failed files can be sent again; /retry %s processes it again.",
+			tb.shortTaskID(existing), strings.ToLower(string(existing.Status)), tb.shortTaskID(existing)))
+		return
+	}
+
+	// Turn files away or tell the user about the wait while the queue is full
+	pressure := tb.queuePressure()
+	if pressure != "" && tb.config.QueueFullAction == utils.QueueFullReject {
+		tb.logger.WithFields(logrus.Fields{
+			"filename": doc.FileName,
+			"user_id":  message.From.ID,
+			"reason":   pressure,
+		}).Info("File rejected, queue full")
+		tb.respond(message, fmt.Sprintf("🚦 Queue full (%s). Please resubmit this file later.", pressure))
+		return
+	}
+
+	// Turn away files the pipeline cannot take on, however long they wait
+	model := tb.costModel()
+	if denial := tb.admissionDenial(model, fileType, int64(doc.FileSize)); denial != "" {
+		tb.logger.WithFields(logrus.Fields{
+			"filename": doc.FileName,
+			"user_id":  message.From.ID,
+			"reason":   denial,
+		}).Info("File rejected on estimated cost")
+		tb.respond(message, fmt.Sprintf("⛔ File not accepted: %s.", denial))
 		return
 	}
 
 	// Create task
 	task := &models.Task{
-		ID:             uuid.New().String(),
-		UserID:         message.From.ID,
-		ChatID:         message.Chat.ID,
-		FileName:       doc.FileName,
-		FileSize:       int64(doc.FileSize),
-		FileType:       fileType,
-		TelegramFileID: doc.FileID,
-		Status:         models.TaskStatusPending,
-		RetryCount:     0,
-		Notified:       false,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
+		ID:                   uuid.New().String(),
+		UserID:               message.From.ID,
+		ChatID:               message.Chat.ID,
+		FileName:             doc.FileName,
+		FileSize:             int64(doc.FileSize),
+		FileType:             fileType,
+		TelegramFileID:       doc.FileID,
+		TelegramFileUniqueID: doc.FileUniqueID,
+		Status:               models.TaskStatusPending,
+		RetryCount:           0,
+		Notified:             false,
+		CreatedAt:            time.Now(),
+		UpdatedAt:            time.Now(),
 	}
 
 	// Save to database
-	err := tb.taskStore.Create(task)
+	err = tb.taskStore.Create(task)
 	if err != nil {
 		tb.logger.WithError(err).Error("Failed to create task")
-		tb.SendMessage(message.Chat.ID, "❌ Error queuing file for processing. Please try again.")
+		tb.respond(message, "❌ Error queuing file for processing. Please try again.")
 		return
 	}
+	if model != nil {
+		if err := tb.taskStore.SetEstimatedCost(task.ID, model.Estimate(fileType, task.FileSize).Total()); err != nil {
+			tb.logger.WithError(err).WithField("task_id", task.ID).Warn("Failed to save estimated cost")
+		}
+	}
 
-	// Send confirmation
-	confirmText := fmt.Sprintf(`✅ File received!
+	// Send confirmation with queue position and ETA
+	estimate, err := tb.taskStore.EstimateCompletion(task, tb.estimateOptions())
+	if err != nil {
+		tb.logger.WithError(err).WithField("task_id", task.ID).Warn("Failed to estimate task completion")
+	}
+	confirmText := tb.formatProgressMessage(task, estimate)
+	if pressure != "" {
+		position := "at the end of the queue"
+		if estimate != nil {
+			position = fmt.Sprintf("at position #%d", estimate.Position)
+		}
+		confirmText = fmt.Sprintf("🚦 Queue full (%s): auto-queued %s, processing may take a while.\n\n", pressure, position) + confirmText
+	}
 
-📄 Filename: %s
-📦 Size: %.2f MB
-🆔 Task ID: %s
+	threadID := tb.messageThread(message)
+	if err := tb.taskStore.SaveTaskOrigin(&storage.TaskOrigin{
+		TaskID:          task.ID,
+		ChatID:          message.Chat.ID,
+		ChatUsername:    message.Chat.UserName,
+		MessageThreadID: threadID,
+		MessageID:       message.MessageID,
+	}); err != nil {
+		tb.logger.WithError(err).WithField("task_id", task.ID).Warn("Failed to save task origin")
+	}
 
-You'll receive a notification when processing completes.`,
-		doc.FileName,
-		float64(doc.FileSize)/(1024*1024),
-		task.ID[:8]) // Show first 8 chars of UUID
+	// Reply to the upload so the confirmation and its progress edits stay attached to it
+	messageID, err := tb.sendReply(message.Chat.ID, threadID, message.MessageID, confirmText)
+	if err != nil {
+		tb.logger.WithError(err).WithField("task_id", task.ID).Error("Failed to send confirmation")
+	} else {
+		tb.linkTaskMessage(task.ID, message.Chat.ID, messageID, storage.TaskMessageConfirmation)
+	}
+	if err == nil && estimate != nil {
+		// Track the message so the estimate can be refreshed as the queue drains
+		if err := tb.taskStore.SaveProgressMessage(task.ID, message.Chat.ID, messageID, confirmText); err != nil {
+			tb.logger.WithError(err).WithField("task_id", task.ID).Warn("Failed to track progress message")
+		}
+	}
 
-	tb.SendMessage(message.Chat.ID, confirmText)
+	tb.auditLogger(message).LogFileOperation(message.From.ID, message.From.UserName, storage.AdminActionFileUpload,
+		doc.FileName, int64(doc.FileSize), map[string]interface{}{"task_id": task.ID, "file_type": fileType}, "SUCCESS", nil)
 
 	tb.logger.WithFields(logrus.Fields{
 		"task_id":   task.ID,
@@ -192,6 +465,7 @@ You'll receive a notification when processing completes.`,
 		"file_type": fileType,
 		"file_size": doc.FileSize,
 		"user_id":   message.From.ID,
+		"deferred":  pressure != "",
 	}).Info("File queued for processing")
 }
 
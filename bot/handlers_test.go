@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"telegram-archive-bot/bot/bottest"
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// waitFor polls cond until it holds, failing the test after 10 seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// repliesContaining returns the messages sent to chatID that contain text
+func repliesContaining(client *bottest.Client, chatID int64, text string) int {
+	count := 0
+	for _, call := range client.CallsTo("sendMessage") {
+		if call.ChatID == chatID && strings.Contains(call.Text, text) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestDocumentUploadSurvivesDatabaseFault(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	taskStore := storage.NewTaskStore(db)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	client := bottest.NewClient()
+	config := &utils.Config{AdminIDs: []int64{42}, MaxFileSizeMB: 100}
+	tb := NewTelegramBotWithClient(config, logger, taskStore, db.DB(), client, client.Self)
+
+	// Every task store write fails as if the database were locked
+	faults, err := utils.ParseFaultInjection(func(name string) string {
+		if name == "DB_BUSY_PROBABILITY" {
+			return "1"
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := utils.Faults
+	utils.Faults = faults
+	defer func() { utils.Faults = previous }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	polling := make(chan struct{})
+	go func() {
+		defer close(polling)
+		tb.PollUpdates(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-polling
+	}()
+
+	first := client.AddDocument(42, "logs.zip", 1024, "documents/file_1.zip")
+	waitFor(t, "the upload to be turned away", func() bool {
+		return repliesContaining(client, 42, "Error queuing file") == 1
+	})
+	if task, err := taskStore.GetByFileUniqueID(first.FileUniqueID); err != nil || task != nil {
+		t.Fatalf("task queued while the database was failing: %+v, %v", task, err)
+	}
+
+	// Once the database recovers, the resubmitted file is queued
+	utils.Faults = previous
+	second := client.AddDocument(42, "logs.zip", 1024, "documents/file_2.zip")
+	var task *models.Task
+	waitFor(t, "the resubmitted file to be queued", func() bool {
+		task, _ = taskStore.GetByFileUniqueID(second.FileUniqueID)
+		return task != nil
+	})
+	if task.Status != models.TaskStatusPending || task.TelegramFileID != second.FileID || task.UserID != 42 {
+		t.Fatalf("queued task = %+v", task)
+	}
+	waitFor(t, "the upload to be confirmed", func() bool {
+		return len(client.CallsTo("sendMessage")) == 2
+	})
+}
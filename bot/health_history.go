@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/storage"
+)
+
+// maxHealthLogChanges caps how many status changes one /healthlog reply lists
+const maxHealthLogChanges = 40
+
+// handleHealthLogCommand lists component status changes from the stored
+// health history: /healthlog [hours]
+func (tb *TelegramBot) handleHealthLogCommand(message *tgbotapi.Message, args CommandArgs) {
+	hours := 24
+	if arg := args.Get("hours"); arg != "" {
+		parsed, err := strconv.Atoi(arg)
+		if err != nil || parsed <= 0 || parsed > 24*7 {
+			tb.respond(message, args.Usage())
+			return
+		}
+		hours = parsed
+	}
+
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+	changes, err := tb.taskStore.GetAllHealthStatusChanges(since)
+	if err != nil {
+		tb.logger.WithError(err).Error("Failed to load health status changes")
+		tb.respond(message, "❌ Failed to load health history")
+		return
+	}
+
+	tb.respond(message, formatHealthLog(changes, hours))
+}
+
+// formatHealthLog renders the newest status changes, counting flaps per component
+func formatHealthLog(changes []storage.HealthStatusChange, hours int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🩺 *Health status changes, last %dh*\n", hours)
+
+	if len(changes) == 0 {
+		b.WriteString("\nNo component changed status in this period.")
+		return b.String()
+	}
+
+	counts := make(map[string]int)
+	var order []string
+	for _, c := range changes {
+		if counts[c.Component] == 0 {
+			order = append(order, c.Component)
+		}
+		counts[c.Component]++
+	}
+	b.WriteString("\nChanges per component:\n")
+	for _, name := range order {
+		fmt.Fprintf(&b, "• `%s`: %d\n", name, counts[name])
+	}
+
+	shown := changes
+	if len(shown) > maxHealthLogChanges {
+		shown = shown[len(shown)-maxHealthLogChanges:]
+		fmt.Fprintf(&b, "\nLatest %d of %d changes:\n", maxHealthLogChanges, len(changes))
+	} else {
+		b.WriteString("\nChanges:\n")
+	}
+	for _, c := range shown {
+		source := ""
+		if c.Kind == storage.HealthRecordDiagnostics {
+			source = " (diag)"
+		}
+		fmt.Fprintf(&b, "%s `%s`%s %s → %s", c.At.Format("01-02 15:04:05"), c.Component, source,
+			strings.ToLower(c.From), strings.ToLower(c.To))
+		if c.Message != "" {
+			fmt.Fprintf(&b, ": `%s`", strings.ReplaceAll(c.Message, "`", "'"))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
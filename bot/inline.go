@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/utils"
+)
+
+// inlineResultLimit caps the number of tasks returned to an inline query
+const inlineResultLimit = 20
+
+// statusEmoji gives each task status a compact marker for inline results
+var statusEmoji = map[models.TaskStatus]string{
+	models.TaskStatusPending:     "⏳",
+	models.TaskStatusDownloading: "⬇️",
+	models.TaskStatusDownloaded:  "⚙️",
+	models.TaskStatusCompleted:   "✅",
+	models.TaskStatusFailed:      "❌",
+}
+
+// handleInlineQuerySafe runs handleInlineQuery with panic recovery and crash
+// reporting, and reports whether it returned without panicking
+func (tb *TelegramBot) handleInlineQuerySafe(query *tgbotapi.InlineQuery) (handled bool) {
+	fields := map[string]interface{}{"inline_query_id": query.ID}
+	if query.From != nil {
+		fields["user_id"] = query.From.ID
+	}
+	defer utils.RecoverPanic("telegram_inline_query", fields)
+
+	tb.handleInlineQuery(query)
+	return true
+}
+
+// handleInlineQuery answers `@bot <task id or filename>` with matching tasks
+func (tb *TelegramBot) handleInlineQuery(query *tgbotapi.InlineQuery) {
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		IsPersonal:    true,
+		CacheTime:     0, // Task status changes constantly, never cache
+		Results:       []interface{}{},
+	}
+
+	// Non-admins get an empty answer so the bot does not leak task names
+	if query.From == nil || !tb.isAdmin(query.From.ID) {
+		if query.From != nil {
+			tb.logger.WithField("user_id", query.From.ID).Warn("Unauthorized inline query attempt")
+		}
+		tb.answerInlineQuery(answer)
+		return
+	}
+
+	tasks, err := tb.taskStore.SearchTasks(query.Query, inlineResultLimit)
+	if err != nil {
+		tb.logger.WithError(err).WithField("query", query.Query).Error("Failed to search tasks for inline query")
+		tb.answerInlineQuery(answer)
+		return
+	}
+
+	for _, task := range tasks {
+		article := tgbotapi.NewInlineQueryResultArticleMarkdown(task.ID,
+			fmt.Sprintf("%s %s", statusEmoji[task.Status], task.FileName),
+			tb.formatTaskDetails(task))
+		article.Description = fmt.Sprintf("%s • %s • %.2f MB • %s",
+			tb.shortTaskID(task),
+			task.Status,
+			float64(task.FileSize)/(1024*1024),
+			task.CreatedAt.Format("Jan 2 15:04"))
+		answer.Results = append(answer.Results, article)
+	}
+
+	tb.answerInlineQuery(answer)
+
+	tb.logger.WithFields(logrus.Fields{
+		"user_id": query.From.ID,
+		"query":   query.Query,
+		"results": len(tasks),
+	}).Debug("Answered inline query")
+}
+
+func (tb *TelegramBot) answerInlineQuery(answer tgbotapi.InlineConfig) {
+	if _, err := tb.bot.Request(answer); err != nil {
+		tb.logger.WithError(err).WithField("inline_query_id", answer.InlineQueryID).Warn("Failed to answer inline query")
+	}
+}
@@ -0,0 +1,71 @@
+package bot
+
+import (
+	"time"
+
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// Store is what the bot reads from and records in the task store
+type Store interface {
+	storage.TaskRepository
+
+	// Submissions and their messages
+	GetByFileUniqueID(fileUniqueID string) (*models.Task, error)
+	SaveTaskOrigin(origin *storage.TaskOrigin) error
+	GetTaskOrigin(taskID string) (*storage.TaskOrigin, error)
+	SaveTaskMessage(taskID string, chatID int64, messageID int, kind string) error
+	CountTaskMessages(chatID int64, messageID int) (int, error)
+	GetTaskIDByMessage(chatID int64, messageID int) (string, error)
+	ResolveTaskID(input string) (string, error)
+	GetShortID(taskID string) (string, error)
+	SearchTasks(query string, limit int) ([]*models.Task, error)
+
+	// Task commands
+	CancelTask(taskID string) error
+	RetryTask(taskID string) (*models.Task, error)
+	GetPriority(taskID string) (int, error)
+	SetPriority(taskID string, priority int) error
+	SoftDeleteTask(taskID string, userID int64, username string, grace time.Duration) (*storage.TaskDeletion, error)
+	UndeleteTask(taskID string) error
+	GetTaskDeletion(taskID string) (*storage.TaskDeletion, error)
+	GetTaskErrors(taskID string) ([]*storage.TaskError, error)
+	GetTaskTraffic(taskID string) (*storage.DownloadTraffic, error)
+	GetArchiveMetadata(taskID string) (*utils.ArchiveMetadata, error)
+	GetTaskConversionResult(taskID string) (*storage.ConversionResult, error)
+
+	// Queue and progress
+	GetQueuePosition(task *models.Task) (int, error)
+	GetQueuedFiles() ([]storage.QueuedFile, error)
+	EstimateCompletion(task *models.Task, opts storage.EstimateOptions) (*storage.QueueEstimate, error)
+	SetEstimatedCost(taskID string, cost time.Duration) error
+	SaveProgressMessage(taskID string, chatID int64, messageID int, text string) error
+	GetProgressMessages() ([]*storage.ProgressMessage, error)
+	DeleteProgressMessage(taskID string) error
+	GetCompletedUnnotifiedTasks() ([]*models.Task, error)
+	MarkNotified(taskID string) error
+
+	// Reports
+	GetTaskStats(from, to time.Time) (*storage.TaskStats, error)
+	GetBandwidthUsage(from, to time.Time) (*storage.BandwidthUsage, error)
+	GetDomainAnalytics(since time.Time, limit int) (*storage.DomainAnalytics, error)
+	GetAvailabilityReport(from, to time.Time) (*storage.AvailabilityReport, error)
+	GetAllHealthStatusChanges(since time.Time) ([]storage.HealthStatusChange, error)
+
+	// Reprocess campaigns
+	GetParserVersionCounts() (map[int]int, error)
+	GetReprocessBacklog(version int) (*storage.ReprocessBacklog, error)
+	StartReprocessCampaign(version int, startedBy int64, startedByName string) (*storage.ReprocessCampaign, error)
+	CancelReprocessCampaign() (*storage.ReprocessCampaign, error)
+	GetLatestReprocessCampaign() (*storage.ReprocessCampaign, error)
+
+	// Update offsets
+	GetUpdateOffset(botID int64) (int, error)
+	SaveUpdateOffset(botID int64, offset int) error
+	ClaimUpdate(botID int64, updateID int) (storage.UpdateClaim, error)
+	CompleteUpdate(botID int64, updateID int) error
+	ReleaseUpdate(botID int64, updateID int) error
+	PruneProcessedUpdates(retention time.Duration) (int64, error)
+}
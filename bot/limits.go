@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// SetStageLimits sets the stage concurrency limits that /limits shows and changes
+func (tb *TelegramBot) SetStageLimits(limits *utils.StageLimits) {
+	tb.stageLimits = limits
+}
+
+// handleLimitsCommand shows or changes how many tasks a stage works on at
+// once: /limits [<stage> <limit>]
+func (tb *TelegramBot) handleLimitsCommand(message *tgbotapi.Message, args CommandArgs) {
+	if tb.stageLimits == nil {
+		tb.respond(message, "❌ Stage limits are not available")
+		return
+	}
+
+	stage := args.Get("stage")
+	if stage == "" {
+		tb.respond(message, tb.formatStageLimits("⚙️ *Stage concurrency*"))
+		return
+	}
+	if !args.Has("limit") {
+		tb.respond(message, args.Usage())
+		return
+	}
+	limit, err := strconv.Atoi(args.Get("limit"))
+	if err != nil {
+		tb.respond(message, args.Usage())
+		return
+	}
+
+	previous, err := tb.stageLimits.Set(stage, limit)
+	details := map[string]interface{}{"stage": stage, "limit": limit}
+	result := "failed"
+	if err == nil {
+		details["previous"] = previous
+		result = "success"
+	}
+	tb.auditLogger(message).LogSystemAction(message.From.ID, message.From.UserName, storage.AdminActionConfigChange,
+		"stage_limit", details, result, err)
+
+	if err != nil {
+		tb.respond(message, fmt.Sprintf("❌ %s", err))
+		return
+	}
+	tb.logger.WithField("stage", stage).
+		WithField("limit", limit).
+		WithField("previous", previous).
+		Info("Stage concurrency limit changed")
+	tb.respond(message, tb.formatStageLimits(fmt.Sprintf("✅ *%s limit changed from %d to %d*", stageTitle(stage), previous, limit)))
+}
+
+// formatStageLimits lists each stage's limit next to its configured value
+func (tb *TelegramBot) formatStageLimits(title string) string {
+	configured := map[string]int{
+		utils.StageDownloads:   tb.config.MaxConcurrentDownloads,
+		utils.StageExtractions: tb.config.MaxConcurrentExtractions,
+		utils.StageConversions: tb.config.MaxConcurrentConversions,
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", title)
+	for _, stage := range tb.stageLimits.Stages() {
+		limit := tb.stageLimits.Limit(stage)
+		fmt.Fprintf(&b, "• %s: %d", stageTitle(stage), limit)
+		if stage == utils.StageDownloads {
+			fmt.Fprintf(&b, " (%d running)", tb.stageLimits.Active(stage))
+		}
+		if limit != configured[stage] {
+			fmt.Fprintf(&b, ", configured %d", configured[stage])
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\nDownloads follow a change within `DOWNLOAD_POLL_INTERVAL`, extraction and conversion at their next pass. " +
+		"Changes last until the next restart; set `MAX_CONCURRENT_*` to keep them")
+	return b.String()
+}
+
+// stageTitle capitalizes a stage name for messages
+func stageTitle(stage string) string {
+	if stage == "" {
+		return stage
+	}
+	return strings.ToUpper(stage[:1]) + stage[1:]
+}
@@ -0,0 +1,57 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"telegram-archive-bot/storage"
+)
+
+// supergroupIDPrefix is prepended to supergroup and channel IDs by the Bot API
+const supergroupIDPrefix = "-100"
+
+// messageLink builds a t.me deep link to a task's original upload. Links only
+// exist for supergroups and channels; private chats and basic groups get ""
+func messageLink(origin *storage.TaskOrigin) string {
+	if origin == nil || origin.MessageID == 0 {
+		return ""
+	}
+
+	var chatPath string
+	if origin.ChatUsername != "" {
+		chatPath = origin.ChatUsername
+	} else {
+		chatID := strconv.FormatInt(origin.ChatID, 10)
+		if !strings.HasPrefix(chatID, supergroupIDPrefix) {
+			return ""
+		}
+		chatPath = "c/" + strings.TrimPrefix(chatID, supergroupIDPrefix)
+	}
+
+	if origin.MessageThreadID != 0 {
+		return fmt.Sprintf("https://t.me/%s/%d/%d", chatPath, origin.MessageThreadID, origin.MessageID)
+	}
+	return fmt.Sprintf("https://t.me/%s/%d", chatPath, origin.MessageID)
+}
+
+// taskOrigin returns where a task was submitted, logging lookup failures
+func (tb *TelegramBot) taskOrigin(taskID string) *storage.TaskOrigin {
+	origin, err := tb.taskStore.GetTaskOrigin(taskID)
+	if err != nil {
+		tb.logger.WithError(err).WithField("task_id", taskID).Warn("Failed to get task origin")
+		return nil
+	}
+	return origin
+}
+
+// linkTaskMessage records that a bot message is about a task, so replies to it
+// can be traced back to the task later
+func (tb *TelegramBot) linkTaskMessage(taskID string, chatID int64, messageID int, kind string) {
+	if err := tb.taskStore.SaveTaskMessage(taskID, chatID, messageID, kind); err != nil {
+		tb.logger.WithError(err).
+			WithField("task_id", taskID).
+			WithField("message_id", messageID).
+			Warn("Failed to link message to task")
+	}
+}
@@ -0,0 +1,33 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+
+	"telegram-archive-bot/monitoring"
+)
+
+// telegramNotificationChannel delivers alert notifications as bot messages,
+// with admin chat IDs as recipients
+type telegramNotificationChannel struct {
+	tb *TelegramBot
+}
+
+// NotificationChannel returns the bot as the primary channel of the alert outbox
+func (tb *TelegramBot) NotificationChannel() monitoring.NotificationChannel {
+	return telegramNotificationChannel{tb: tb}
+}
+
+// Name implements monitoring.NotificationChannel
+func (c telegramNotificationChannel) Name() string {
+	return "telegram"
+}
+
+// Send implements monitoring.NotificationChannel
+func (c telegramNotificationChannel) Send(recipient, text string) error {
+	chatID, err := strconv.ParseInt(recipient, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID %q: %w", recipient, err)
+	}
+	return c.tb.SendMessage(chatID, text)
+}
@@ -6,6 +6,9 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/storage"
 )
 
 // SendCompletionNotifications sends notifications for completed tasks
@@ -21,32 +24,48 @@ func (tb *TelegramBot) SendCompletionNotifications() error {
 		return nil // No tasks to notify
 	}
 
-	// Group tasks by chat ID
-	tasksByChat := make(map[int64][]string)
+	// Group tasks by chat and forum topic so each topic gets its own notice
+	type destination struct {
+		chatID   int64
+		threadID int
+	}
+	tasksByDestination := make(map[destination][]*models.Task)
+	origins := make(map[string]*storage.TaskOrigin)
 	for _, task := range tasks {
-		tasksByChat[task.ChatID] = append(tasksByChat[task.ChatID], task.FileName)
+		dest := destination{chatID: task.ChatID}
+		if origin := tb.taskOrigin(task.ID); origin != nil {
+			dest.threadID = origin.MessageThreadID
+			origins[task.ID] = origin
+		}
+		tasksByDestination[dest] = append(tasksByDestination[dest], task)
 	}
 
 	// Send batched notifications (respect 20 msg/min limit)
-	for chatID, filenames := range tasksByChat {
-		message := tb.formatCompletionMessage(filenames)
+	for dest, destTasks := range tasksByDestination {
+		message := tb.formatCompletionMessage(destTasks, origins)
+
+		// A single result replies to its upload; a batch links each upload instead
+		replyTo := 0
+		if len(destTasks) == 1 && origins[destTasks[0].ID] != nil {
+			replyTo = origins[destTasks[0].ID].MessageID
+		}
 
-		err := tb.SendMessage(chatID, message)
+		messageID, err := tb.sendReply(dest.chatID, dest.threadID, replyTo, message)
 		if err != nil {
 			tb.logger.WithError(err).
-				WithField("chat_id", chatID).
+				WithField("chat_id", dest.chatID).
+				WithField("message_thread_id", dest.threadID).
 				Error("Failed to send completion notification")
 			continue
 		}
 
 		// Mark tasks as notified
-		for _, task := range tasks {
-			if task.ChatID == chatID {
-				if err := tb.taskStore.MarkNotified(task.ID); err != nil {
-					tb.logger.WithError(err).
-						WithField("task_id", task.ID).
-						Error("Failed to mark task as notified")
-				}
+		for _, task := range destTasks {
+			tb.linkTaskMessage(task.ID, dest.chatID, messageID, storage.TaskMessageCompletion)
+			if err := tb.taskStore.MarkNotified(task.ID); err != nil {
+				tb.logger.WithError(err).
+					WithField("task_id", task.ID).
+					Error("Failed to mark task as notified")
 			}
 		}
 
@@ -56,26 +75,34 @@ func (tb *TelegramBot) SendCompletionNotifications() error {
 
 	tb.logger.WithFields(logrus.Fields{
 		"task_count": len(tasks),
-		"chat_count": len(tasksByChat),
+		"chat_count": len(tasksByDestination),
 	}).Info("Sent completion notifications")
 
 	return nil
 }
 
-func (tb *TelegramBot) formatCompletionMessage(filenames []string) string {
-	if len(filenames) == 1 {
-		return fmt.Sprintf(`✅ *Processing Complete*
+func (tb *TelegramBot) formatCompletionMessage(tasks []*models.Task, origins map[string]*storage.TaskOrigin) string {
+	if len(tasks) == 1 {
+		text := fmt.Sprintf(`✅ *Processing Complete*
 
 📄 File: %s
-
-Your file has been successfully processed and stored!`,
-			filenames[0])
+🆔 Task ID: `+"`%s`"+`
+`,
+			tasks[0].FileName,
+			tb.shortTaskID(tasks[0]))
+		if link := messageLink(origins[tasks[0].ID]); link != "" {
+			text += fmt.Sprintf("🔗 [Original upload](%s) from %s\n", link, tasks[0].CreatedAt.Format("Jan 2 15:04"))
+		}
+		return text + "\nYour file has been successfully processed and stored!"
 	}
 
 	// Multiple files - create a bulleted list
-	fileList := make([]string, len(filenames))
-	for i, filename := range filenames {
-		fileList[i] = fmt.Sprintf("• %s", filename)
+	fileList := make([]string, len(tasks))
+	for i, task := range tasks {
+		fileList[i] = fmt.Sprintf("• %s (`%s`)", task.FileName, tb.shortTaskID(task))
+		if link := messageLink(origins[task.ID]); link != "" {
+			fileList[i] += fmt.Sprintf(" [upload](%s)", link)
+		}
 	}
 
 	return fmt.Sprintf(`✅ *Processing Complete*
@@ -84,7 +111,7 @@ Your file has been successfully processed and stored!`,
 %s
 
 All files have been successfully processed and stored!`,
-		len(filenames),
+		len(tasks),
 		strings.Join(fileList, "\n"))
 }
 
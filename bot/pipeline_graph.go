@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/monitoring"
+)
+
+// SetPipelineGraph sets how /pipeline snapshots the pipeline
+func (tb *TelegramBot) SetPipelineGraph(build func() (*monitoring.PipelineGraph, error)) {
+	tb.pipelineGraph = build
+}
+
+// handlePipelineCommand shows where the work in the pipeline sits, as a
+// Mermaid flowchart by default: /pipeline [mermaid|dot]
+func (tb *TelegramBot) handlePipelineCommand(message *tgbotapi.Message, args CommandArgs) {
+	if tb.pipelineGraph == nil {
+		tb.respond(message, "❌ The pipeline graph is not available")
+		return
+	}
+
+	format := args.Get("format")
+	if format == "" {
+		format = monitoring.GraphFormatMermaid
+	}
+	graph, err := tb.pipelineGraph()
+	if err != nil {
+		tb.logger.WithError(err).Error("Failed to build pipeline graph")
+		tb.respond(message, "❌ Error building the pipeline graph. Please try again.")
+		return
+	}
+	text, err := graph.Render(format)
+	if err != nil {
+		tb.respond(message, args.Usage())
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("🗺 *Pipeline*\n\n")
+	busy := false
+	for _, node := range graph.Nodes {
+		if node.State == monitoring.NodeIdle {
+			continue
+		}
+		busy = true
+		line := fmt.Sprintf("%s: %d %s, %s", node.ID, node.Count, node.Unit, node.State)
+		if node.Detail != "" {
+			line += fmt.Sprintf(" (%s)", node.Detail)
+		}
+		fmt.Fprintf(&b, "• %s\n", tgbotapi.EscapeText(tgbotapi.ModeMarkdown, line))
+	}
+	if !busy {
+		b.WriteString("Nothing is waiting\n")
+	}
+	if graph.Maintenance.Active {
+		fmt.Fprintf(&b, "• maintenance: %s\n", tgbotapi.EscapeText(tgbotapi.ModeMarkdown, strings.Join(graph.Maintenance.Reasons, ", ")))
+	}
+	fmt.Fprintf(&b, "\n```\n%s```", text)
+	tb.respond(message, b.String())
+}
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// defaultCPUProfileSeconds is how long /profile capture samples the CPU by default
+const defaultCPUProfileSeconds = 10
+
+// handleProfileCommand captures runtime profiles into the profile directory:
+// /profile capture [cpu seconds] [send]
+func (tb *TelegramBot) handleProfileCommand(message *tgbotapi.Message, args CommandArgs) {
+	// The CPU seconds and send may come in either order
+	seconds := defaultCPUProfileSeconds
+	send := false
+	for _, arg := range args.Words()[1:] {
+		if arg == "send" {
+			send = true
+			continue
+		}
+		parsed, err := strconv.Atoi(arg)
+		if err != nil || parsed < 0 || time.Duration(parsed)*time.Second > utils.MaxCPUProfileDuration {
+			tb.respond(message, args.Usage())
+			return
+		}
+		seconds = parsed
+	}
+
+	tb.respond(message, fmt.Sprintf("⏳ Capturing heap and goroutine profiles and %ds of CPU profile...", seconds))
+	paths, err := utils.CaptureProfiles(tb.config.ProfileDir, time.Duration(seconds)*time.Second)
+
+	details := map[string]interface{}{
+		"cpu_seconds": seconds,
+		"files":       paths,
+		"sent":        send,
+	}
+	result := "success"
+	if err != nil {
+		result = "failed"
+	}
+	tb.auditLogger(message).LogSystemAction(message.From.ID, message.From.UserName, storage.AdminActionSystemDiag,
+		"profile_capture", details, result, err)
+
+	var b strings.Builder
+	if err != nil {
+		tb.logger.WithError(err).Warn("Profile capture failed")
+		if errors.Is(err, utils.ErrCPUProfileActive) {
+			b.WriteString("⚠️ A CPU profile is already running, try again later\n")
+		} else {
+			fmt.Fprintf(&b, "❌ Profile capture failed: `%s`\n", strings.ReplaceAll(err.Error(), "`", "'"))
+		}
+	} else {
+		b.WriteString("✅ *Profiles captured*\n")
+	}
+	for _, path := range paths {
+		fmt.Fprintf(&b, "• `%s`\n", path)
+	}
+	if len(paths) > 0 {
+		b.WriteString("\nInspect with `go tool pprof <file>`")
+	}
+	tb.respond(message, b.String())
+
+	if !send {
+		return
+	}
+	for _, path := range paths {
+		if err := tb.SendDocument(message.Chat.ID, path, ""); err != nil {
+			tb.logger.WithError(err).WithField("file", filepath.Base(path)).Warn("Failed to send profile")
+			tb.respond(message, fmt.Sprintf("❌ Failed to send `%s`", filepath.Base(path)))
+		}
+	}
+}
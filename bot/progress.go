@@ -0,0 +1,168 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"telegram-archive-bot/app/extraction/progress"
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// UpdateProgressMessages refreshes queue position and ETA in tracked progress messages
+// This is called periodically by the processing orchestrator as the queue drains
+func (tb *TelegramBot) UpdateProgressMessages() error {
+	messages, err := tb.taskStore.GetProgressMessages()
+	if err != nil {
+		return fmt.Errorf("failed to get progress messages: %w", err)
+	}
+
+	updated := 0
+	for _, msg := range messages {
+		task, err := tb.taskStore.GetByID(msg.TaskID)
+		if err != nil {
+			// Task no longer exists, stop tracking it
+			tb.taskStore.DeleteProgressMessage(msg.TaskID)
+			continue
+		}
+
+		var estimate *storage.QueueEstimate
+		if !task.IsCompleted() {
+			estimate, err = tb.taskStore.EstimateCompletion(task, tb.estimateOptions())
+			if err != nil {
+				tb.logger.WithError(err).
+					WithField("task_id", task.ID).
+					Warn("Failed to estimate task completion")
+				continue
+			}
+		}
+
+		text := tb.formatProgressMessage(task, estimate)
+		if text != msg.LastText {
+			if err := tb.EditMessage(msg.ChatID, msg.MessageID, text); err != nil {
+				tb.logger.WithError(err).
+					WithField("task_id", task.ID).
+					WithField("message_id", msg.MessageID).
+					Warn("Failed to update progress message")
+			} else {
+				updated++
+				if err := tb.taskStore.SaveProgressMessage(task.ID, msg.ChatID, msg.MessageID, text); err != nil {
+					tb.logger.WithError(err).Warn("Failed to save progress message")
+				}
+			}
+		}
+
+		// Final state has been reported, no further updates needed
+		if task.IsCompleted() {
+			if err := tb.taskStore.DeleteProgressMessage(task.ID); err != nil {
+				tb.logger.WithError(err).Warn("Failed to delete progress message")
+			}
+		}
+	}
+
+	if updated > 0 {
+		tb.logger.WithFields(logrus.Fields{
+			"updated": updated,
+			"tracked": len(messages),
+		}).Debug("Updated task progress messages")
+	}
+
+	return nil
+}
+
+// estimateOptions are the queue estimate options with the current download
+// limit and cost model
+func (tb *TelegramBot) estimateOptions() storage.EstimateOptions {
+	opts := storage.DefaultEstimateOptions()
+	if tb.stageLimits != nil {
+		opts.DownloadWorkers = tb.stageLimits.Limit(utils.StageDownloads)
+	}
+	if model := tb.costModel(); model != nil {
+		opts.Costs = model
+	}
+	return opts
+}
+
+// SetStageProgress sets the progress of the running extraction or conversion
+// pass shown in progress messages; nil clears it
+func (tb *TelegramBot) SetStageProgress(report *progress.Report) {
+	tb.stageProgressMu.Lock()
+	defer tb.stageProgressMu.Unlock()
+	tb.stageProgress = report
+}
+
+// currentStageProgress returns the running stage's progress, or nil
+func (tb *TelegramBot) currentStageProgress() *progress.Report {
+	tb.stageProgressMu.RLock()
+	defer tb.stageProgressMu.RUnlock()
+	return tb.stageProgress
+}
+
+// formatProgressMessage builds the task progress text shown to the submitter
+func (tb *TelegramBot) formatProgressMessage(task *models.Task, estimate *storage.QueueEstimate) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `✅ File received!
+
+📄 Filename: %s
+📦 Size: %.2f MB
+🆔 Task ID: `+"`%s`"+`
+`,
+		task.FileName,
+		float64(task.FileSize)/(1024*1024),
+		tb.shortTaskID(task))
+
+	switch task.Status {
+	case models.TaskStatusPending:
+		if estimate == nil {
+			b.WriteString("\n📍 Status: Queued")
+			break
+		}
+		fmt.Fprintf(&b, "\n📍 Queue position: #%d (%d ahead)", estimate.Position, estimate.PendingAhead)
+	case models.TaskStatusDownloading:
+		b.WriteString("\n⬇️ Status: Downloading")
+	case models.TaskStatusDownloaded:
+		if report := tb.currentStageProgress(); report != nil && report.Total > 0 {
+			verb := "Extracting archives"
+			if report.Stage == "conversion" {
+				verb = "Converting files"
+			}
+			fmt.Fprintf(&b, "\n⚙️ Status: %s (%d/%d, %.0f%%)", verb, report.Done, report.Total, report.Percent())
+			break
+		}
+		b.WriteString("\n⚙️ Status: Waiting for extraction and conversion")
+	case models.TaskStatusCompleted:
+		b.WriteString("\n🎉 Status: Completed")
+	case models.TaskStatusFailed:
+		b.WriteString("\n❌ Status: Failed")
+	}
+
+	if estimate != nil {
+		fmt.Fprintf(&b, "\n⏱ Estimated completion: %s (around %s)",
+			formatETA(estimate.ETA),
+			estimate.EstimatedAt.Add(estimate.ETA).Format("15:04"))
+		if !estimate.HasHistory {
+			b.WriteString("\n_Rough estimate - not enough processing history yet_")
+		}
+		b.WriteString("\n\nYou'll receive a notification when processing completes.")
+	}
+
+	return b.String()
+}
+
+// formatETA renders an ETA rounded to whole minutes so edits only happen on visible changes
+func formatETA(eta time.Duration) string {
+	minutes := int(eta.Round(time.Minute) / time.Minute)
+	switch {
+	case minutes < 1:
+		return "less than a minute"
+	case minutes < 60:
+		return fmt.Sprintf("~%d min", minutes)
+	default:
+		return fmt.Sprintf("~%dh %dm", minutes/60, minutes%60)
+	}
+}
@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/app/extraction/convert"
+	"telegram-archive-bot/storage"
+)
+
+// handleReprocessCommand shows the parser versions tasks were converted with
+// and starts or cancels a campaign converting the older ones again:
+// /reprocess [start|cancel]
+func (tb *TelegramBot) handleReprocessCommand(message *tgbotapi.Message, args CommandArgs) {
+	switch args.Get("action") {
+	case "start":
+		campaign, err := tb.taskStore.StartReprocessCampaign(convert.ParserVersion, message.From.ID, message.From.UserName)
+		switch {
+		case errors.Is(err, storage.ErrCampaignRunning):
+			tb.respond(message, "❌ A reprocess campaign is already running. /reprocess shows its progress.")
+			return
+		case errors.Is(err, storage.ErrNothingToReprocess):
+			tb.respond(message, fmt.Sprintf("✅ No kept conversion input is older than parser v%d. "+
+				"Passes are only kept with `CONVERSION_KEEP_INPUTS=true`; /retry reprocesses a single task from its upload.",
+				convert.ParserVersion))
+			return
+		}
+		details := map[string]interface{}{"parser_version": convert.ParserVersion}
+		if campaign != nil {
+			details["campaign_id"] = campaign.ID
+			details["passes"] = campaign.Total
+		}
+		tb.auditLogger(message).LogSystemAction(message.From.ID, message.From.UserName, storage.AdminActionReprocess,
+			"reprocess_campaign", details, "SUCCESS", err)
+		if err != nil {
+			tb.logger.WithError(err).Error("Failed to start reprocess campaign")
+			tb.respond(message, "❌ Error starting reprocess campaign. Please try again.")
+			return
+		}
+		tb.logger.WithField("campaign_id", campaign.ID).
+			WithField("parser_version", campaign.ParserVersion).
+			WithField("passes", campaign.Total).
+			Info("Reprocess campaign started")
+		tb.respond(message, fmt.Sprintf("🔄 *Reprocess campaign #%d started*\n\n%d conversion passes covering %d tasks "+
+			"will be converted again with parser v%d, one pass whenever the pipeline is idle.",
+			campaign.ID, campaign.Total, campaign.Tasks, campaign.ParserVersion))
+
+	case "cancel":
+		campaign, err := tb.taskStore.CancelReprocessCampaign()
+		if errors.Is(err, storage.ErrNoCampaignRunning) {
+			tb.respond(message, "❌ No reprocess campaign is running")
+			return
+		}
+		details := map[string]interface{}{}
+		if campaign != nil {
+			details["campaign_id"] = campaign.ID
+			details["done"] = campaign.Done
+			details["pending"] = campaign.Pending()
+		}
+		tb.auditLogger(message).LogSystemAction(message.From.ID, message.From.UserName, storage.AdminActionReprocess,
+			"reprocess_campaign", details, "CANCELLED", err)
+		if err != nil {
+			tb.logger.WithError(err).Error("Failed to cancel reprocess campaign")
+			tb.respond(message, "❌ Error cancelling reprocess campaign. Please try again.")
+			return
+		}
+		tb.respond(message, fmt.Sprintf("🛑 Reprocess campaign #%d cancelled after %d of %d passes",
+			campaign.ID, campaign.Done+campaign.Failed, campaign.Total))
+
+	default:
+		text, err := tb.formatReprocessStatus()
+		if err != nil {
+			tb.logger.WithError(err).Error("Failed to get reprocess status")
+			tb.respond(message, "❌ Error getting reprocess status. Please try again.")
+			return
+		}
+		tb.respond(message, text)
+	}
+}
+
+// formatReprocessStatus lists the tasks per parser version, what a campaign
+// would cover and the latest campaign
+func (tb *TelegramBot) formatReprocessStatus() (string, error) {
+	counts, err := tb.taskStore.GetParserVersionCounts()
+	if err != nil {
+		return "", err
+	}
+	backlog, err := tb.taskStore.GetReprocessBacklog(convert.ParserVersion)
+	if err != nil {
+		return "", err
+	}
+	campaign, err := tb.taskStore.GetLatestReprocessCampaign()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔄 *Reprocessing*\n\nCurrent parser: v%d\n", convert.ParserVersion)
+
+	versions := make([]int, 0, len(counts))
+	for version := range counts {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	if len(versions) > 0 {
+		b.WriteString("\n*Tasks by parser version*\n")
+	}
+	for _, version := range versions {
+		name := fmt.Sprintf("v%d", version)
+		if version == 0 {
+			name = "untracked"
+		}
+		fmt.Fprintf(&b, "• %s: %d\n", name, counts[version])
+	}
+
+	fmt.Fprintf(&b, "\n%d older passes covering %d tasks can be converted again", backlog.Passes, backlog.Tasks)
+	if backlog.Unavailable > 0 {
+		fmt.Fprintf(&b, "; %d tasks have no kept input and need /retry", backlog.Unavailable)
+	}
+	b.WriteString("\n")
+
+	if campaign != nil {
+		fmt.Fprintf(&b, "\n*Campaign #%d* (parser v%d): %s\n", campaign.ID, campaign.ParserVersion, campaign.Status)
+		fmt.Fprintf(&b, "• Passes: %d done, %d failed, %d pending of %d\n",
+			campaign.Done, campaign.Failed, campaign.Pending(), campaign.Total)
+		fmt.Fprintf(&b, "• Tasks: %d\n", campaign.Tasks)
+		fmt.Fprintf(&b, "• Started: %s", campaign.CreatedAt.Format("2006-01-02 15:04"))
+		if campaign.StartedByName != "" {
+			fmt.Fprintf(&b, " by @%s", campaign.StartedByName)
+		}
+		b.WriteString("\n")
+		if campaign.FinishedAt != nil {
+			fmt.Fprintf(&b, "• Finished: %s\n", campaign.FinishedAt.Format("2006-01-02 15:04"))
+		}
+		if campaign.LastErr != "" {
+			fmt.Fprintf(&b, "• Last error: %s\n", campaign.LastErr)
+		}
+	}
+	if campaign == nil || campaign.Status != storage.ReprocessRunning {
+		b.WriteString("\n/reprocess start converts the older passes again")
+	}
+	return b.String(), nil
+}
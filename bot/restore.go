@@ -0,0 +1,472 @@
+package bot
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+const (
+	// restoreCallbackPrefix starts the data of the /restore approval buttons
+	restoreCallbackPrefix = "restore"
+	// restoreApprovalTTL is how long a restore request waits for a second admin
+	restoreApprovalTTL = 15 * time.Minute
+	// restoreQuiesceTimeout is how long a restore waits for running downloads
+	// and the processing cycle to finish before giving up
+	restoreQuiesceTimeout = 10 * time.Minute
+	// restoreListLimit caps the backups /restore lists
+	restoreListLimit = 10
+)
+
+// SetRestoreHooks sets what /restore waits for and runs around a restore:
+// cycleRunning reports whether the orchestrator is mid-cycle, and restored
+// reconciles the tasks and files on disk with the restored database
+func (tb *TelegramBot) SetRestoreHooks(cycleRunning func() bool, restored func()) {
+	tb.cycleRunning = cycleRunning
+	tb.restored = restored
+}
+
+// restoreRequest is a /restore waiting for a second admin's approval
+type restoreRequest struct {
+	id            int
+	backup        storage.BackupInfo
+	requestedBy   int64
+	requesterUser string // Telegram username, for the audit trail
+	requesterName string // Markdown-escaped, for messages
+	createdAt     time.Time
+	chatID        int64
+
+	// messages are the approval messages sent to the admins, by chat; the
+	// restore's progress is shown in the requester's
+	messages   map[int64]int
+	messagesMu sync.Mutex
+}
+
+// addMessage records an approval message sent for the request
+func (req *restoreRequest) addMessage(chatID int64, messageID int) {
+	req.messagesMu.Lock()
+	defer req.messagesMu.Unlock()
+	req.messages[chatID] = messageID
+}
+
+// sentMessages returns the approval messages sent so far, by chat
+func (req *restoreRequest) sentMessages() map[int64]int {
+	req.messagesMu.Lock()
+	defer req.messagesMu.Unlock()
+	messages := make(map[int64]int, len(req.messages))
+	for chatID, messageID := range req.messages {
+		messages[chatID] = messageID
+	}
+	return messages
+}
+
+// restoreRequests holds the pending /restore requests. A request only lives
+// in memory: a restart drops it, and the approval has to be asked for again
+type restoreRequests struct {
+	mutex    sync.Mutex
+	next     int
+	requests map[int]*restoreRequest
+}
+
+// add records a request, refusing a second one while another is pending
+func (r *restoreRequests) add(req *restoreRequest) (pending *restoreRequest) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.requests == nil {
+		r.requests = make(map[int]*restoreRequest)
+	}
+	for id, other := range r.requests {
+		if time.Since(other.createdAt) > restoreApprovalTTL {
+			delete(r.requests, id)
+			continue
+		}
+		return other
+	}
+	r.next++
+	req.id = r.next
+	r.requests[req.id] = req
+	return nil
+}
+
+// take removes a request and returns it, nil when it was already decided
+func (r *restoreRequests) take(id int) *restoreRequest {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	req := r.requests[id]
+	delete(r.requests, id)
+	return req
+}
+
+// put returns a request taken by a button press that did not decide it
+func (r *restoreRequests) put(req *restoreRequest) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.requests[req.id] = req
+}
+
+// handleRestoreCommand lists the backups, or asks the other admins to
+// approve restoring one: /restore [backup]
+func (tb *TelegramBot) handleRestoreCommand(message *tgbotapi.Message, args CommandArgs) {
+	if tb.backups == nil {
+		tb.respond(message, "❌ Database backups are not available")
+		return
+	}
+	backups, err := tb.backups.ListBackups()
+	if err != nil {
+		tb.logger.WithError(err).Error("Failed to list backups")
+		tb.respond(message, "❌ Error listing backups. Please try again.")
+		return
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Created.After(backups[j].Created) })
+
+	name := args.Get("backup")
+	if name == "" {
+		tb.respond(message, formatRestoreList(backups))
+		return
+	}
+
+	// Only names from the backup directory are accepted, never a path
+	var backup *storage.BackupInfo
+	for i := range backups {
+		if backups[i].Name == name {
+			backup = &backups[i]
+			break
+		}
+	}
+	if backup == nil {
+		tb.respond(message, fmt.Sprintf("❌ No backup named `%s`. /restore lists the backups.", strings.ReplaceAll(name, "`", "'")))
+		return
+	}
+	if reason := restoreRefusal(backup); reason != "" {
+		tb.respond(message, fmt.Sprintf("❌ `%s` cannot be restored: %s", backup.Name, reason))
+		return
+	}
+	if len(tb.config.AdminIDs) < 2 {
+		tb.respond(message, "❌ A restore must be approved by a second admin, and only one admin is configured. "+
+			"Restore on the host with `go run ./cmd/backup -action=restore` instead.")
+		return
+	}
+
+	req := &restoreRequest{
+		backup:        *backup,
+		requestedBy:   message.From.ID,
+		requesterUser: message.From.UserName,
+		requesterName: adminName(message.From),
+		createdAt:     time.Now(),
+		messages:      make(map[int64]int),
+		chatID:        message.Chat.ID,
+	}
+	if pending := tb.restores.add(req); pending != nil {
+		tb.respond(message, fmt.Sprintf("⚠️ A restore of `%s` requested by %s is waiting for approval",
+			pending.backup.Name, pending.requesterName))
+		return
+	}
+
+	tb.auditLogger(message).LogSystemAction(message.From.ID, message.From.UserName, storage.AdminActionRestore,
+		"database_restore", map[string]interface{}{"backup": backup.Name}, "REQUESTED", nil)
+	tb.logger.WithField("backup", backup.Name).
+		WithField("requested_by", message.From.ID).
+		Info("Database restore requested")
+
+	// The request goes to the requester's chat and to every other admin, so a
+	// second admin sees it even when it was asked for in a private chat
+	text := formatRestoreRequest(req)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ Approve", fmt.Sprintf("%s:approve:%d", restoreCallbackPrefix, req.id)),
+		tgbotapi.NewInlineKeyboardButtonData("❌ Reject", fmt.Sprintf("%s:reject:%d", restoreCallbackPrefix, req.id)),
+	))
+	messageID, err := tb.sendKeyboard(message.Chat.ID, tb.messageThread(message), text, keyboard)
+	if err != nil {
+		tb.logger.WithError(err).Warn("Failed to send restore request")
+	} else {
+		req.addMessage(message.Chat.ID, messageID)
+	}
+	for _, adminID := range tb.config.AdminIDs {
+		if adminID == message.From.ID || adminID == message.Chat.ID {
+			continue
+		}
+		messageID, err := tb.sendKeyboard(adminID, 0, text, keyboard)
+		if err != nil {
+			tb.logger.WithError(err).WithField("admin_id", adminID).Warn("Failed to send restore request to admin")
+			continue
+		}
+		req.addMessage(adminID, messageID)
+	}
+}
+
+// handleRestoreCallback approves or rejects a restore request. Any admin may
+// reject it, but only one other than the requester may approve it
+func (tb *TelegramBot) handleRestoreCallback(query *tgbotapi.CallbackQuery, payload string) string {
+	action, idText, _ := strings.Cut(payload, ":")
+	id, err := strconv.Atoi(idText)
+	if err != nil || action != "approve" && action != "reject" {
+		return "Invalid restore button"
+	}
+
+	req := tb.restores.take(id)
+	if req == nil {
+		return "This restore request was already decided or has expired"
+	}
+	if time.Since(req.createdAt) > restoreApprovalTTL {
+		tb.editRestoreMessages(req, fmt.Sprintf("⌛ Restore of `%s` requested by %s expired without approval",
+			req.backup.Name, req.requesterName))
+		return "This restore request has expired"
+	}
+
+	approver := adminName(query.From)
+	details := map[string]interface{}{
+		"backup":         req.backup.Name,
+		"requested_by":   req.requestedBy,
+		"requester_name": req.requesterUser,
+	}
+	if action == "reject" {
+		tb.callbackAuditLogger(query).LogSystemAction(query.From.ID, query.From.UserName, storage.AdminActionRestore,
+			"database_restore", details, "REJECTED", nil)
+		tb.editRestoreMessages(req, fmt.Sprintf("❌ Restore of `%s` requested by %s was rejected by %s",
+			req.backup.Name, req.requesterName, approver))
+		return "Restore rejected"
+	}
+
+	if query.From.ID == req.requestedBy {
+		tb.restores.put(req)
+		return "⛔ Another admin must approve your restore"
+	}
+	if !tb.backupRunning.CompareAndSwap(false, true) {
+		tb.restores.put(req)
+		return "A backup or restore is running, try again when it has finished"
+	}
+
+	tb.logger.WithFields(logrus.Fields{
+		"backup":       req.backup.Name,
+		"requested_by": req.requestedBy,
+		"approved_by":  query.From.ID,
+	}).Warn("Database restore approved")
+	tb.editRestoreMessages(req, fmt.Sprintf("✅ Restore of `%s` requested by %s was approved by %s. "+
+		"Progress is shown in the requester's chat.", req.backup.Name, req.requesterName, approver))
+
+	// The restore takes minutes; the button's answer can't wait for it
+	go func() {
+		defer utils.RecoverPanic("database_restore", map[string]interface{}{"backup": req.backup.Name})
+		defer tb.backupRunning.Store(false)
+		tb.runRestore(req, query, details)
+	}()
+	return "Restore approved"
+}
+
+// runRestore pauses the pipeline, backs up the current database, restores
+// the approved backup, verifies the result and reconciles the tasks with it.
+// The audit entries written before the restore are replaced with the
+// backup's, so its outcome is audited afterwards with who asked and approved
+func (tb *TelegramBot) runRestore(req *restoreRequest, query *tgbotapi.CallbackQuery, details map[string]interface{}) {
+	chatID, messageID := req.chatID, req.sentMessages()[req.chatID]
+	show := func(text string) {
+		if messageID == 0 || tb.EditMessage(chatID, messageID, text) != nil {
+			if _, err := tb.SendMessageToThread(chatID, 0, text); err != nil {
+				tb.logger.WithError(err).Warn("Failed to send restore progress message")
+			}
+		}
+	}
+	details["approved_by"] = query.From.ID
+
+	resume := utils.Maintenance.Pause("database restore")
+	defer resume()
+
+	show("⏸ *Restore approved*\n\nWaiting for running downloads and processing to finish...")
+	if err := tb.waitForIdlePipeline(restoreQuiesceTimeout); err != nil {
+		tb.finishRestore(query, details, err, "", nil, show)
+		return
+	}
+
+	var lastEdit time.Time
+	progress := func(p storage.BackupProgress) {
+		if !p.Done && time.Since(lastEdit) < backupMessageInterval {
+			return
+		}
+		lastEdit = time.Now()
+		if messageID != 0 {
+			if err := tb.EditMessage(chatID, messageID, formatBackupProgress(p)); err != nil {
+				tb.logger.WithError(err).Debug("Failed to update restore progress message")
+			}
+		}
+	}
+
+	// Backed up here rather than by the restore, so its name can be reported
+	previous, err := tb.backups.CreateBackup(storage.BackupOptions{
+		Codec:    tb.config.BackupCompression,
+		Level:    tb.config.BackupCompressionLevel,
+		Progress: progress,
+	})
+	if err != nil {
+		tb.finishRestore(query, details, fmt.Errorf("failed to back up the current database: %w", err), "", nil, show)
+		return
+	}
+	details["previous"] = filepath.Base(previous)
+
+	err = tb.backups.RestoreFromBackup(storage.RestoreOptions{
+		BackupFile: req.backup.Path,
+		Progress:   progress,
+	})
+	if err != nil {
+		tb.finishRestore(query, details, err, previous, nil, show)
+		return
+	}
+
+	show("🔍 *Verifying restored database*\n\nRunning the integrity check and comparing row counts...")
+	verification := tb.backups.VerifyRestoredDatabase(req.backup.Metadata)
+	if !verification.OK {
+		err = fmt.Errorf("restored database failed verification: %s", verification.Error)
+	}
+
+	// Tasks whose files moved on since the backup are set right before the
+	// pipeline resumes
+	if tb.restored != nil {
+		tb.restored()
+	}
+	tb.finishRestore(query, details, err, previous, &verification, show)
+}
+
+// waitForIdlePipeline waits until no download runs and the orchestrator is
+// between cycles; the paused pipeline starts no new ones
+func (tb *TelegramBot) waitForIdlePipeline(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		downloads := tb.stageLimits.Active(utils.StageDownloads)
+		cycle := tb.cycleRunning != nil && tb.cycleRunning()
+		if downloads == 0 && !cycle {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("pipeline still busy after %s (%d downloads running, processing cycle running: %t)",
+				timeout, downloads, cycle)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// finishRestore audits the outcome of a restore and reports it
+func (tb *TelegramBot) finishRestore(query *tgbotapi.CallbackQuery, details map[string]interface{}, err error,
+	previous string, verification *storage.BackupVerification, show func(string)) {
+	result := "SUCCESS"
+	if err != nil {
+		result = "FAILED"
+	}
+	if verification != nil {
+		details["integrity"] = verification.Integrity
+		details["rows"] = verification.Rows
+	}
+	tb.callbackAuditLogger(query).LogSystemAction(query.From.ID, query.From.UserName, storage.AdminActionRestore,
+		"database_restore", details, result, err)
+
+	var b strings.Builder
+	if err != nil {
+		tb.logger.WithError(err).WithField("backup", details["backup"]).Error("Database restore failed")
+		fmt.Fprintf(&b, "❌ *Database restore failed*\n\n`%s`\n", strings.ReplaceAll(err.Error(), "`", "'"))
+	} else {
+		tb.logger.WithField("backup", details["backup"]).Warn("Database restored from backup")
+		fmt.Fprintf(&b, "✅ *Database restored*\n\n`%s`\n", details["backup"])
+	}
+	if verification != nil {
+		fmt.Fprintf(&b, "Integrity: %s\n", verification.Integrity)
+		fmt.Fprintf(&b, "Rows: %d in %d tables\n", verification.Rows, verification.Tables)
+		for _, mismatch := range verification.Mismatches {
+			fmt.Fprintf(&b, "• %s\n", tgbotapi.EscapeText(tgbotapi.ModeMarkdown, mismatch))
+		}
+	}
+	if previous != "" {
+		fmt.Fprintf(&b, "\nThe database before the restore was saved as `%s`; /restore it to undo.\n", filepath.Base(previous))
+	}
+	b.WriteString("\nDownloads and processing have resumed.")
+	show(b.String())
+}
+
+// editRestoreMessages replaces the approval messages of a request, dropping their buttons
+func (tb *TelegramBot) editRestoreMessages(req *restoreRequest, text string) {
+	for chatID, messageID := range req.sentMessages() {
+		if err := tb.EditMessage(chatID, messageID, text); err != nil {
+			tb.logger.WithError(err).WithField("chat_id", chatID).Debug("Failed to update restore request message")
+		}
+	}
+}
+
+// restoreRefusal explains why a backup cannot be restored from the bot, or
+// returns "" when it can
+func restoreRefusal(backup *storage.BackupInfo) string {
+	meta := backup.Metadata
+	if meta == nil {
+		return "it has no catalog entry to check it against; restore it on the host with `go run ./cmd/backup -action=restore`"
+	}
+	if err := storage.CheckSchemaCompatibility(meta); err != nil {
+		return tgbotapi.EscapeText(tgbotapi.ModeMarkdown, err.Error())
+	}
+	if meta.Verification != nil && !meta.Verification.OK {
+		return "it failed verification"
+	}
+	return ""
+}
+
+// formatRestoreList lists the newest backups with their catalog entries
+func formatRestoreList(backups []storage.BackupInfo) string {
+	if len(backups) == 0 {
+		return "📦 No backups found. /backup takes one."
+	}
+
+	var b strings.Builder
+	b.WriteString("📦 *Backups*\n\n")
+	for i, backup := range backups {
+		if i == restoreListLimit {
+			fmt.Fprintf(&b, "…and %d older\n\n", len(backups)-restoreListLimit)
+			break
+		}
+		fmt.Fprintf(&b, "`%s`\n", backup.Name)
+		fmt.Fprintf(&b, "• %s, %s\n", backup.Created.Format("2006-01-02 15:04"), formatStatsBytes(backup.Size))
+		if meta := backup.Metadata; meta != nil {
+			fmt.Fprintf(&b, "• Schema v%d, %d rows in %d tables\n", meta.SchemaVersion, meta.TotalRows(), len(meta.RowCounts))
+		}
+		if reason := restoreRefusal(&backup); reason != "" {
+			fmt.Fprintf(&b, "• ⚠️ Cannot be restored: %s\n", reason)
+		} else if backup.Metadata.Verification == nil {
+			b.WriteString("• Not verified\n")
+		} else {
+			b.WriteString("• ✅ Verified\n")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("/restore <name> restores a backup once a second admin approves")
+	return b.String()
+}
+
+// formatRestoreRequest describes a restore request to the admins asked to approve it
+func formatRestoreRequest(req *restoreRequest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "♻️ *Database restore requested*\n\n%s wants to restore `%s`\n", req.requesterName, req.backup.Name)
+	meta := req.backup.Metadata
+	fmt.Fprintf(&b, "• Created: %s\n", meta.Created.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "• Size: %s (%s)\n", formatStatsBytes(req.backup.Size), meta.Codec)
+	fmt.Fprintf(&b, "• Rows: %d in %d tables\n", meta.TotalRows(), len(meta.RowCounts))
+	fmt.Fprintf(&b, "• Schema version: %d (current %d)\n", meta.SchemaVersion, storage.LatestSchemaVersion())
+	if meta.Verification == nil {
+		b.WriteString("• ⚠️ Not verified\n")
+	}
+	fmt.Fprintf(&b, "\nEverything written since the backup is replaced. Downloads and processing pause "+
+		"while the current database is backed up and the backup restored.\n\n"+
+		"Another admin must approve within %d minutes.", int(restoreApprovalTTL.Minutes()))
+	return b.String()
+}
+
+// adminName is how an admin is named in restore messages
+func adminName(user *tgbotapi.User) string {
+	if user.UserName != "" {
+		return tgbotapi.EscapeText(tgbotapi.ModeMarkdown, "@"+user.UserName)
+	}
+	return tgbotapi.EscapeText(tgbotapi.ModeMarkdown, fmt.Sprintf("%s (%d)", user.FirstName, user.ID))
+}
@@ -0,0 +1,131 @@
+package bot
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+
+	"telegram-archive-bot/bot/bottest"
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// restoreCommand builds a /restore message from an admin's private chat
+func restoreCommand(userID int64, text string) *tgbotapi.Message {
+	command := strings.Fields(text)[0]
+	return &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: userID, FirstName: "Admin", UserName: "admin"},
+		Chat:      &tgbotapi.Chat{ID: userID, Type: "private"},
+		Text:      text,
+		Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+	}
+}
+
+// restoreButton presses a /restore approval button as userID
+func restoreButton(userID int64, data string) *tgbotapi.CallbackQuery {
+	return &tgbotapi.CallbackQuery{
+		ID:      "query",
+		From:    &tgbotapi.User{ID: userID, FirstName: "Admin", UserName: "admin2"},
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: userID, Type: "private"}},
+		Data:    data,
+	}
+}
+
+func TestRestoreApprovedBySecondAdmin(t *testing.T) {
+	dir := t.TempDir()
+	db, err := storage.NewDatabase(filepath.Join(dir, "bot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	taskStore := storage.NewTaskStore(db)
+
+	backups, err := storage.NewBackupService(db, storage.BackupOptions{BackupDir: filepath.Join(dir, "backups")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	taken, err := backups.CreateBackup(storage.BackupOptions{VerifyBackup: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Backups are named by the second, and the restore backs up the current
+	// database first, so the one restored gets a name of its own
+	backupPath := filepath.Join(dir, "backups", "bot_backup_before_task.sql")
+	for _, suffix := range []string{"", ".meta.json"} {
+		if err := os.Rename(taken+suffix, backupPath+suffix); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A task written after the backup is gone once it is restored
+	task := &models.Task{ID: "task-after-backup", UserID: 1, ChatID: 1, FileName: "late.zip",
+		FileType: "ZIP", Status: models.TaskStatusPending, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := taskStore.Create(task); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	client := bottest.NewClient()
+	config := &utils.Config{AdminIDs: []int64{1, 2}}
+	tb := NewTelegramBotWithClient(config, logger, taskStore, db.DB(), client, client.Self)
+	tb.SetBackupService(backups)
+	restored := make(chan struct{})
+	tb.SetRestoreHooks(func() bool { return false }, func() { close(restored) })
+
+	tb.handleUpdateSafe(tgbotapi.Update{Message: restoreCommand(1, "/restore "+filepath.Base(backupPath))}, 0)
+	requests := client.CallsTo("sendMessage")
+	if len(requests) != 2 {
+		t.Fatalf("restore request sent %d times, want to both admins: %+v", len(requests), client.Calls())
+	}
+
+	// The requester can't approve their own restore
+	tb.handleCallbackQuery(restoreButton(1, "restore:approve:1"))
+	if answers := client.CallsTo("answerCallbackQuery"); len(answers) != 1 || !strings.Contains(answers[0].Text, "Another admin") {
+		t.Fatalf("requester's approval answered with %+v", answers)
+	}
+
+	tb.handleCallbackQuery(restoreButton(2, "restore:approve:1"))
+	select {
+	case <-restored:
+	case <-time.After(30 * time.Second):
+		t.Fatal("restore did not run after the second admin approved it")
+	}
+
+	// The result is the last edit of the requester's message; the pause ends
+	// and the restore stops counting as running right after it
+	deadline := time.Now().Add(10 * time.Second)
+	var result string
+	for time.Now().Before(deadline) {
+		for _, edit := range client.CallsTo("editMessageText") {
+			if edit.ChatID == 1 && strings.Contains(edit.Text, "Database restore") {
+				result = edit.Text
+			}
+		}
+		if result != "" && !utils.Maintenance.Paused() && !tb.backupRunning.Load() {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strings.Contains(result, "✅ *Database restored*") || !strings.Contains(result, "Integrity: ok") {
+		t.Fatalf("restore result = %q", result)
+	}
+	if utils.Maintenance.Paused() {
+		t.Fatal("pipeline still paused after the restore")
+	}
+
+	if got, err := taskStore.GetByID(task.ID); err == nil && got != nil {
+		t.Fatalf("task written after the backup survived the restore: %+v", got)
+	}
+	if tb.backupRunning.Load() {
+		t.Fatal("restore still marked running")
+	}
+}
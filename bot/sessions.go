@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/google/uuid"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// AdminSessionIdleTimeout is how long an admin may be idle before their next
+// command starts a new audit session
+const AdminSessionIdleTimeout = 30 * time.Minute
+
+// adminSession is one sequence of interactions by an admin
+type adminSession struct {
+	id       string
+	lastSeen time.Time
+}
+
+// AdminSessionTracker groups each admin's commands and uploads into sessions,
+// so the audit entries of a multi-command operation share a session ID
+type AdminSessionTracker struct {
+	mu       sync.Mutex
+	idle     time.Duration
+	sessions map[int64]*adminSession
+}
+
+// NewAdminSessionTracker creates a tracker that ends sessions after idle
+func NewAdminSessionTracker(idle time.Duration) *AdminSessionTracker {
+	return &AdminSessionTracker{idle: idle, sessions: make(map[int64]*adminSession)}
+}
+
+// Touch returns the user's current session ID, starting a new session when
+// they have none or were idle for too long
+func (st *AdminSessionTracker) Touch(userID int64, now time.Time) string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	session, ok := st.sessions[userID]
+	if !ok || now.Sub(session.lastSeen) > st.idle {
+		session = &adminSession{id: uuid.NewString()[:8]}
+		st.sessions[userID] = session
+
+		// Sessions are only replaced above, so drop the expired ones of other admins here
+		for id, other := range st.sessions {
+			if now.Sub(other.lastSeen) > st.idle && other != session {
+				delete(st.sessions, id)
+			}
+		}
+	}
+	session.lastSeen = now
+	return session.id
+}
+
+// telegramClientInfo describes the chat and client an admin interacted through
+func telegramClientInfo(message *tgbotapi.Message, threadID int) map[string]interface{} {
+	info := map[string]interface{}{
+		"chat_id":   message.Chat.ID,
+		"chat_type": message.Chat.Type,
+	}
+	if message.From != nil && message.From.LanguageCode != "" {
+		info["language"] = message.From.LanguageCode
+	}
+	if threadID != 0 {
+		info["message_thread_id"] = threadID
+	}
+	return info
+}
+
+// auditLogger returns an audit logger for the message's sender that records
+// entries in their current session, with their client info
+func (tb *TelegramBot) auditLogger(message *tgbotapi.Message) *storage.AdminAuditLogger {
+	audit := storage.NewAdminAuditLogger(tb.auditDB, &utils.Logger{Logger: tb.logger})
+	return audit.WithSession(tb.sessions.Touch(message.From.ID, time.Now()), telegramClientInfo(message, tb.messageThread(message)))
+}
+
+// callbackAuditLogger returns an audit logger for the admin who pressed a
+// button, recording entries in their current session
+func (tb *TelegramBot) callbackAuditLogger(query *tgbotapi.CallbackQuery) *storage.AdminAuditLogger {
+	audit := storage.NewAdminAuditLogger(tb.auditDB, &utils.Logger{Logger: tb.logger})
+	info := map[string]interface{}{}
+	if query.Message != nil {
+		info = telegramClientInfo(query.Message, 0)
+	}
+	if query.From.LanguageCode != "" {
+		info["language"] = query.From.LanguageCode
+	}
+	return audit.WithSession(tb.sessions.Touch(query.From.ID, time.Now()), info)
+}
@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// SetSignatureRegistry sets the signature definitions that /signatures shows and reloads
+func (tb *TelegramBot) SetSignatureRegistry(registry *utils.SignatureRegistry) {
+	tb.signatures = registry
+}
+
+func (tb *TelegramBot) handleSignaturesCommand(message *tgbotapi.Message, args CommandArgs) {
+	if tb.signatures == nil {
+		tb.respond(message, "❌ Signature definitions are not available")
+		return
+	}
+
+	if args.Get("action") == "reload" {
+		tb.reloadSignatures(message)
+		return
+	}
+	tb.respond(message, formatSignatureSet("🛡 *Signature definitions*", tb.signatures.Current()))
+}
+
+// reloadSignatures reloads the definitions file, keeping the active
+// definitions if the file is invalid, and audit-logs the outcome
+func (tb *TelegramBot) reloadSignatures(message *tgbotapi.Message) {
+	previous := tb.signatures.Current()
+	set, err := tb.signatures.Reload()
+
+	details := map[string]interface{}{
+		"path":             tb.signatures.Path(),
+		"previous_version": previous.Version,
+	}
+	result := "success"
+	if err != nil {
+		result = "rejected"
+	} else {
+		details["version"] = set.Version
+	}
+	tb.auditLogger(message).LogSystemAction(message.From.ID, message.From.UserName, storage.AdminActionConfigChange,
+		"signature_definitions", details, result, err)
+
+	if err != nil {
+		tb.logger.WithError(err).Warn("Signature definitions reload rejected")
+		tb.respond(message, fmt.Sprintf("❌ Reload rejected, still using version %s:\n```\n%s\n```",
+			previous.Version, err.Error()))
+		return
+	}
+
+	tb.respond(message, formatSignatureSet(fmt.Sprintf("✅ *Signature definitions reloaded* (was %s)", previous.Version), set))
+}
+
+// formatSignatureSet summarizes a version of the signature definitions
+func formatSignatureSet(title string, set *utils.SignatureSet) string {
+	allowed := 0
+	for _, rules := range set.AllowedSignatures {
+		allowed += len(rules)
+	}
+
+	return fmt.Sprintf(`%s
+
+🏷 Version: %s
+📁 Source: %s
+🕐 Loaded: %s
+
+✅ Allowed signatures: %d (%d file types)
+☣️ Malware signatures: %d
+🎭 Polyglot patterns: %d
+⚠️ Suspicious patterns: %d`,
+		title,
+		set.Version,
+		set.Source,
+		set.LoadedAt.Format("2006-01-02 15:04:05"),
+		allowed, len(set.AllowedSignatures),
+		len(set.MalwareSignatures),
+		len(set.PolyglotPatterns),
+		len(set.SuspiciousPatterns))
+}
@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/storage"
+)
+
+// handleSLACommand reports monthly uptime per component: /sla [YYYY-MM]
+func (tb *TelegramBot) handleSLACommand(message *tgbotapi.Message, args CommandArgs) {
+	month := time.Now()
+	if arg := args.Get("YYYY-MM"); arg != "" {
+		parsed, err := time.ParseInLocation("2006-01", arg, time.Local)
+		if err != nil {
+			tb.respond(message, args.Usage())
+			return
+		}
+		month = parsed
+	}
+
+	from, to := storage.MonthRange(month)
+	report, err := tb.taskStore.GetAvailabilityReport(from, to)
+	if err != nil {
+		tb.logger.WithError(err).Error("Failed to build availability report")
+		tb.respond(message, "❌ Failed to build availability report")
+		return
+	}
+
+	tb.respond(message, formatAvailabilityReport(report))
+}
+
+// formatAvailabilityReport lists each component's uptime, outages and how
+// much of the month was monitored
+func formatAvailabilityReport(report *storage.AvailabilityReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📈 *Availability for %s*\n", report.From.Format("January 2006"))
+
+	if len(report.Components) == 0 {
+		b.WriteString("\nNo health checks were recorded in this month.")
+		return b.String()
+	}
+
+	for _, c := range report.Components {
+		emoji := "🟢"
+		switch {
+		case c.UptimePercent < 99:
+			emoji = "🔴"
+		case c.UptimePercent < 99.9:
+			emoji = "🟡"
+		}
+
+		fmt.Fprintf(&b, "\n%s `%s`: %.3f%% up\n", emoji, c.Component, c.UptimePercent)
+		fmt.Fprintf(&b, "   Down %s in %d outages", formatSLADuration(c.Unhealthy), c.Outages)
+		if c.Degraded > 0 {
+			fmt.Fprintf(&b, ", degraded %s", formatSLADuration(c.Degraded))
+		}
+		fmt.Fprintf(&b, "\n   Monitored %s (%.1f%% coverage)\n", formatSLADuration(c.Monitored), c.CoveragePercent)
+	}
+
+	b.WriteString("\nTime the bot was not running is unmonitored and not counted as downtime.")
+	return b.String()
+}
+
+// formatSLADuration renders a duration as days, hours and minutes
+func formatSLADuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := d / (24 * time.Hour)
+	hours := (d % (24 * time.Hour)) / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
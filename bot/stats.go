@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/storage"
+)
+
+// defaultStatsWindow is the /stats detailed window when none is given
+const defaultStatsWindow = "24h"
+
+// handleDetailedStats reports task outcomes and stage latency percentiles
+// over a window: /stats detailed [1h|24h|7d]
+func (tb *TelegramBot) handleDetailedStats(message *tgbotapi.Message, window string) {
+	if window == "" {
+		window = defaultStatsWindow
+	}
+
+	to := time.Now()
+	stats, err := tb.taskStore.GetTaskStats(to.Add(-storage.StatsWindows[window]), to)
+	if err != nil {
+		tb.logger.WithError(err).Error("Failed to compute task statistics")
+		tb.respond(message, "❌ Failed to compute statistics")
+		return
+	}
+
+	tb.respond(message, formatTaskStats(stats, window))
+}
+
+// formatTaskStats renders task outcomes, throughput and per-stage percentiles
+func formatTaskStats(stats *storage.TaskStats, window string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📈 *Detailed statistics (last %s)*\n\n", window)
+
+	b.WriteString("*Tasks*\n")
+	fmt.Fprintf(&b, "• Completed: %d, failed: %d (%.1f%% success)\n", stats.Completed, stats.Failed, stats.SuccessRate())
+	fmt.Fprintf(&b, "• Throughput: %.1f tasks/hour\n", stats.ThroughputPerHour())
+	fmt.Fprintf(&b, "• Bytes processed: %s\n", formatStatsBytes(stats.BytesProcessed))
+
+	b.WriteString("\n*Stage latency* (p50 / p90 / p99)\n")
+	for _, stage := range stats.Stages {
+		if stage.Runs == 0 && stage.Failures == 0 {
+			fmt.Fprintf(&b, "• %s: no runs\n", stage.Stage)
+			continue
+		}
+		fmt.Fprintf(&b, "• %s: %s / %s / %s\n", stage.Stage,
+			formatLatency(stage.P50), formatLatency(stage.P90), formatLatency(stage.P99))
+		fmt.Fprintf(&b, "  %d runs, %d files, %d failed attempts (%.1f%% success)\n",
+			stage.Runs, stage.Items, stage.Failures, stage.SuccessRate())
+	}
+
+	b.WriteString("\nWindows: `/stats detailed 1h`, `24h` or `7d`")
+	return b.String()
+}
+
+// formatLatency renders a stage duration with precision suited to its size
+func formatLatency(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	default:
+		return d.Round(time.Second).String()
+	}
+}
+
+// formatStatsBytes renders a byte count in the largest fitting unit
+func formatStatsBytes(bytes int64) string {
+	switch {
+	case bytes >= 1<<40:
+		return fmt.Sprintf("%.2f TB", float64(bytes)/(1<<40))
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.2f GB", float64(bytes)/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.2f MB", float64(bytes)/(1<<20))
+	default:
+		return fmt.Sprintf("%.2f KB", float64(bytes)/(1<<10))
+	}
+}
@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/monitoring"
+	"telegram-archive-bot/storage"
+)
+
+// SetHealthMonitor sets the health monitor whose last check /status shows
+func (tb *TelegramBot) SetHealthMonitor(health *monitoring.HealthMonitor) {
+	tb.health = health
+}
+
+// handleStatusCommand shows the last health check with the build and schema
+// version it ran on, so reports can be matched to the exact code
+func (tb *TelegramBot) handleStatusCommand(message *tgbotapi.Message, args CommandArgs) {
+	if tb.health == nil {
+		tb.respond(message, "❌ Health monitoring is not available")
+		return
+	}
+	check := tb.health.GetLastHealthCheck()
+	if check == nil {
+		tb.respond(message, "⏳ No health check has completed yet, try again in a minute")
+		return
+	}
+	tb.respond(message, formatStatus(check))
+}
+
+// formatStatus summarizes a health check for /status
+func formatStatus(check *monitoring.HealthCheck) string {
+	emoji := "✅"
+	switch check.Status {
+	case monitoring.HealthStatusDegraded:
+		emoji = "⚠️"
+	case monitoring.HealthStatusUnhealthy:
+		emoji = "🔴"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s *Status: %s*\n\n", emoji, check.Status)
+	fmt.Fprintf(&b, "⏱ Uptime: %s\n", formatSLADuration(check.Uptime))
+	fmt.Fprintf(&b, "🏷 Build: `%s`\n", check.Build)
+	if !check.Build.BuiltAt.IsZero() {
+		fmt.Fprintf(&b, "🕐 Built: %s\n", check.Build.BuiltAt.Format("2006-01-02 15:04"))
+	}
+	fmt.Fprintf(&b, "🗄 Schema version: %d", check.SchemaVersion)
+	if latest := storage.LatestSchemaVersion(); check.SchemaVersion != latest {
+		fmt.Fprintf(&b, " (binary expects %d)", latest)
+	}
+	b.WriteString("\n")
+	info := check.SystemInfo
+	fmt.Fprintf(&b, "🖥 CPU: %.1f%%, memory: %.1f MB\n", info.CPUUsage, info.MemoryUsage)
+	if info.DiskPath != "" {
+		fmt.Fprintf(&b, "💽 Disk: %.1f%% used, %s free (`%s`)\n", info.DiskPercent,
+			monitoring.FormatBytes(uint64(info.DiskFree)), info.DiskPath)
+	}
+
+	unhealthy := 0
+	for _, c := range check.Components {
+		if c.Status == monitoring.HealthStatusHealthy {
+			continue
+		}
+		if unhealthy == 0 {
+			b.WriteString("\n*Components needing attention:*\n")
+		}
+		unhealthy++
+		fmt.Fprintf(&b, "• `%s`: %s", c.Name, c.Status)
+		if c.Message != "" {
+			fmt.Fprintf(&b, " (%s)", c.Message)
+		}
+		b.WriteString("\n")
+	}
+	if unhealthy == 0 {
+		fmt.Fprintf(&b, "\nAll %d components healthy\n", len(check.Components))
+	}
+	fmt.Fprintf(&b, "\n_Checked %s ago_", formatSLADuration(time.Since(check.Timestamp).Round(time.Minute)))
+	return b.String()
+}
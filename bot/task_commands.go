@@ -0,0 +1,492 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+
+	"telegram-archive-bot/app/extraction/convert"
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// priorityLevels maps the names accepted by /priority to stored priorities
+var priorityLevels = map[string]int{
+	"high":   models.TaskPriorityHigh,
+	"normal": models.TaskPriorityNormal,
+	"low":    models.TaskPriorityLow,
+}
+
+// shortTaskID returns the short ID shown to users for a task
+func (tb *TelegramBot) shortTaskID(task *models.Task) string {
+	if task.ShortID != "" {
+		return task.ShortID
+	}
+
+	shortID, err := tb.taskStore.GetShortID(task.ID)
+	if err != nil {
+		tb.logger.WithError(err).WithField("task_id", task.ID).Warn("Failed to get short task ID")
+		return task.ID[:8]
+	}
+	task.ShortID = shortID
+	return shortID
+}
+
+// resolveTaskArgument looks up the task named by a command argument and
+// replies with an explanation when it cannot be found
+func (tb *TelegramBot) resolveTaskArgument(message *tgbotapi.Message, arg, usage string) (*models.Task, bool) {
+	if arg == "" && message.ReplyToMessage != nil {
+		return tb.resolveRepliedTask(message)
+	}
+	if arg == "" {
+		tb.respond(message, usage)
+		return nil, false
+	}
+
+	taskID, err := tb.taskStore.ResolveTaskID(arg)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrTaskNotFound):
+			tb.respond(message, fmt.Sprintf("❌ No task found for `%s`", arg))
+		case errors.Is(err, storage.ErrAmbiguousTaskID):
+			tb.respond(message, fmt.Sprintf("❌ `%s` matches more than one task. Use the short ID instead.", arg))
+		default:
+			tb.logger.WithError(err).WithField("input", arg).Error("Failed to resolve task ID")
+			tb.respond(message, "❌ Error looking up task. Please try again.")
+		}
+		return nil, false
+	}
+
+	task, err := tb.taskStore.GetByID(taskID)
+	if err != nil {
+		tb.respond(message, fmt.Sprintf("❌ No task found for `%s`", arg))
+		return nil, false
+	}
+	return task, true
+}
+
+// resolveRepliedTask finds the task for a command sent as a reply to an upload
+// or to one of the bot's messages about a task
+func (tb *TelegramBot) resolveRepliedTask(message *tgbotapi.Message) (*models.Task, bool) {
+	replied := message.ReplyToMessage
+
+	if count, err := tb.taskStore.CountTaskMessages(message.Chat.ID, replied.MessageID); err == nil && count > 1 {
+		tb.respond(message, fmt.Sprintf("❌ That message covers %d tasks. Pass a task ID instead.", count))
+		return nil, false
+	}
+
+	taskID, err := tb.taskStore.GetTaskIDByMessage(message.Chat.ID, replied.MessageID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrTaskNotFound) {
+			tb.logger.WithError(err).WithField("message_id", replied.MessageID).Error("Failed to resolve task from reply")
+		}
+		tb.respond(message, "❌ That message is not linked to a task. Pass a task ID instead.")
+		return nil, false
+	}
+
+	task, err := tb.taskStore.GetByID(taskID)
+	if err != nil {
+		tb.respond(message, "❌ The task for that message no longer exists.")
+		return nil, false
+	}
+	return task, true
+}
+
+func (tb *TelegramBot) handleTaskCommand(message *tgbotapi.Message, args CommandArgs) {
+	task, ok := tb.resolveTaskArgument(message, args.Get("id"), args.Usage())
+	if !ok {
+		return
+	}
+
+	tb.respond(message, tb.formatTaskDetails(task))
+}
+
+// formatTaskDetails builds the full status summary used by /task and inline queries
+func (tb *TelegramBot) formatTaskDetails(task *models.Task) string {
+	var estimate *storage.QueueEstimate
+	if task.Status == models.TaskStatusPending {
+		var err error
+		estimate, err = tb.taskStore.EstimateCompletion(task, tb.estimateOptions())
+		if err != nil {
+			tb.logger.WithError(err).WithField("task_id", task.ID).Warn("Failed to estimate task completion")
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `📋 *Task %s*
+
+📄 File: %s
+📦 Size: %.2f MB
+🔑 Full ID: %s
+`,
+		tb.shortTaskID(task),
+		task.FileName,
+		float64(task.FileSize)/(1024*1024),
+		task.ID)
+
+	if link := messageLink(tb.taskOrigin(task.ID)); link != "" {
+		fmt.Fprintf(&b, "🔗 [Original upload](%s)\n", link)
+	}
+	if storageName := utils.SanitizeFileName(task.FileName); storageName != task.FileName {
+		fmt.Fprintf(&b, "💾 Stored as: %s\n", storageName)
+	}
+	fmt.Fprintf(&b, "📍 Status: %s\n", task.Status)
+	if priority, err := tb.taskStore.GetPriority(task.ID); err == nil && priority != models.TaskPriorityNormal {
+		fmt.Fprintf(&b, "⭐ Priority: %s\n", priorityName(priority))
+	}
+	if estimate != nil {
+		fmt.Fprintf(&b, "🔢 Queue position: #%d\n⏱ Estimated completion: %s\n",
+			estimate.Position, formatETA(estimate.ETA))
+	}
+	if task.RetryCount > 0 {
+		fmt.Fprintf(&b, "🔁 Retries: %d\n", task.RetryCount)
+	}
+	if task.ErrorMessage != "" {
+		fmt.Fprintf(&b, "⚠️ Error: %s\n", task.ErrorMessage)
+	}
+	if history, err := tb.taskStore.GetTaskErrors(task.ID); err != nil {
+		tb.logger.WithError(err).WithField("task_id", task.ID).Warn("Failed to get task error history")
+	} else if len(history) > 0 {
+		b.WriteString(formatTaskErrorHistory(history))
+	}
+	if result, err := tb.taskStore.GetTaskConversionResult(task.ID); err != nil {
+		tb.logger.WithError(err).WithField("task_id", task.ID).Warn("Failed to get task conversion result")
+	} else if result != nil {
+		fmt.Fprintf(&b, "🧾 Converted: %d credentials, %d domains from %d files%s\n",
+			result.Credentials, result.Domains, result.FilesProcessed, parserVersionNote(result.ParserVersion))
+		if result.Warnings > 0 {
+			fmt.Fprintf(&b, "⚠️ Conversion warnings: %d\n", result.Warnings)
+		}
+	}
+	if traffic, err := tb.taskStore.GetTaskTraffic(task.ID); err != nil {
+		tb.logger.WithError(err).WithField("task_id", task.ID).Warn("Failed to get task traffic")
+	} else if traffic != nil {
+		fmt.Fprintf(&b, "📶 Downloaded: %s, Bot API traffic %s\n",
+			formatStatsBytes(traffic.DownloadedBytes), formatStatsBytes(traffic.BotAPIBytes))
+	}
+	if meta, err := tb.taskStore.GetArchiveMetadata(task.ID); err != nil {
+		tb.logger.WithError(err).WithField("task_id", task.ID).Warn("Failed to get archive metadata")
+	} else if meta != nil {
+		b.WriteString(formatArchiveMetadata(meta))
+	}
+	if deletion, err := tb.taskStore.GetTaskDeletion(task.ID); err != nil {
+		tb.logger.WithError(err).WithField("task_id", task.ID).Warn("Failed to get task deletion")
+	} else if deletion != nil {
+		b.WriteString(formatTaskDeletion(deletion))
+	}
+	fmt.Fprintf(&b, "🕐 Created: %s\n", task.CreatedAt.Format("2006-01-02 15:04:05"))
+	if task.CompletedAt != nil {
+		fmt.Fprintf(&b, "🏁 Finished: %s (%s)\n", task.CompletedAt.Format("2006-01-02 15:04:05"),
+			task.CompletedAt.Sub(task.CreatedAt).Round(time.Second))
+	}
+
+	return b.String()
+}
+
+func (tb *TelegramBot) handleCancelCommand(message *tgbotapi.Message, args CommandArgs) {
+	task, ok := tb.resolveTaskArgument(message, args.Get("id"), args.Usage())
+	if !ok {
+		return
+	}
+
+	if task.Status != models.TaskStatusPending {
+		tb.respond(message, fmt.Sprintf("❌ Task `%s` is %s and can no longer be cancelled",
+			tb.shortTaskID(task), strings.ToLower(string(task.Status))))
+		return
+	}
+
+	if err := tb.taskStore.CancelTask(task.ID); err != nil {
+		tb.logger.WithError(err).WithField("task_id", task.ID).Warn("Failed to cancel task")
+		tb.respond(message, fmt.Sprintf("❌ Task `%s` could not be cancelled, it has already started downloading",
+			tb.shortTaskID(task)))
+		return
+	}
+
+	tb.logger.WithFields(logrus.Fields{
+		"task_id": task.ID,
+		"user_id": message.From.ID,
+	}).Info("Task cancelled by admin")
+
+	tb.respond(message, fmt.Sprintf("🛑 Task `%s` (%s) cancelled", tb.shortTaskID(task), task.FileName))
+}
+
+func (tb *TelegramBot) handleDeleteCommand(message *tgbotapi.Message, args CommandArgs) {
+	task, ok := tb.resolveTaskArgument(message, args.Get("id"), args.Usage())
+	if !ok {
+		return
+	}
+
+	// Stages work on files in bulk, so only tasks that left the pipeline can go
+	if !task.IsCompleted() {
+		tb.respond(message, fmt.Sprintf("❌ Task `%s` is %s. Cancel it or wait until it finishes before deleting it.",
+			tb.shortTaskID(task), strings.ToLower(string(task.Status))))
+		return
+	}
+
+	grace := tb.config.TaskPurgeGrace
+	deletion, err := tb.taskStore.SoftDeleteTask(task.ID, message.From.ID, message.From.UserName, grace)
+	if errors.Is(err, storage.ErrTaskAlreadyDeleted) {
+		tb.respond(message, fmt.Sprintf("❌ Task `%s` is already deleted", tb.shortTaskID(task)))
+		return
+	}
+	tb.auditLogger(message).LogSystemAction(message.From.ID, message.From.UserName, storage.AdminActionTaskDelete, task.ID,
+		map[string]interface{}{"file_name": task.FileName, "purge_grace": grace.String()}, "SUCCESS", err)
+	if err != nil {
+		tb.logger.WithError(err).WithField("task_id", task.ID).Error("Failed to delete task")
+		tb.respond(message, "❌ Error deleting task. Please try again.")
+		return
+	}
+
+	tb.logger.WithFields(logrus.Fields{
+		"task_id":     task.ID,
+		"user_id":     message.From.ID,
+		"purge_after": deletion.PurgeAfter,
+	}).Info("Task deleted by admin")
+
+	tb.respond(message, fmt.Sprintf("🗑️ Task `%s` (%s) deleted. Its files will be purged after %s; /undelete %s takes it back until then.",
+		tb.shortTaskID(task), task.FileName, deletion.PurgeAfter.Format("2006-01-02 15:04"), tb.shortTaskID(task)))
+}
+
+func (tb *TelegramBot) handleUndeleteCommand(message *tgbotapi.Message, args CommandArgs) {
+	task, ok := tb.resolveTaskArgument(message, args.Get("id"), args.Usage())
+	if !ok {
+		return
+	}
+
+	err := tb.taskStore.UndeleteTask(task.ID)
+	switch {
+	case errors.Is(err, storage.ErrTaskNotDeleted):
+		tb.respond(message, fmt.Sprintf("❌ Task `%s` is not deleted", tb.shortTaskID(task)))
+		return
+	case errors.Is(err, storage.ErrTaskPurged):
+		tb.respond(message, fmt.Sprintf("❌ The files of task `%s` were already purged", tb.shortTaskID(task)))
+		return
+	}
+	tb.auditLogger(message).LogSystemAction(message.From.ID, message.From.UserName, storage.AdminActionTaskUndelete, task.ID,
+		map[string]interface{}{"file_name": task.FileName}, "SUCCESS", err)
+	if err != nil {
+		tb.logger.WithError(err).WithField("task_id", task.ID).Error("Failed to undelete task")
+		tb.respond(message, "❌ Error undeleting task. Please try again.")
+		return
+	}
+
+	tb.respond(message, fmt.Sprintf("♻️ Task `%s` (%s) restored, its files will be kept", tb.shortTaskID(task), task.FileName))
+}
+
+// handleRetryCommand queues a finished task's file again as a new task, e.g.
+// to reprocess it after a pass.txt or parser update
+func (tb *TelegramBot) handleRetryCommand(message *tgbotapi.Message, args CommandArgs) {
+	task, ok := tb.resolveTaskArgument(message, args.Get("id"), args.Usage())
+	if !ok {
+		return
+	}
+
+	clone, err := tb.taskStore.RetryTask(task.ID)
+	var running *storage.RetryInProgressError
+	switch {
+	case errors.Is(err, storage.ErrTaskNotFinished):
+		tb.respond(message, fmt.Sprintf("❌ Task `%s` is %s. Only completed or failed tasks can be retried.",
+			tb.shortTaskID(task), strings.ToLower(string(task.Status))))
+		return
+	case errors.Is(err, storage.ErrTaskAlreadyDeleted):
+		tb.respond(message, fmt.Sprintf("❌ Task `%s` is deleted. /undelete %s first to retry it.",
+			tb.shortTaskID(task), tb.shortTaskID(task)))
+		return
+	case errors.Is(err, storage.ErrTaskNoFileID):
+		tb.respond(message, fmt.Sprintf("❌ Task `%s` has no stored Telegram file. Send the file again instead.", tb.shortTaskID(task)))
+		return
+	case errors.As(err, &running):
+		tb.respond(message, fmt.Sprintf("❌ This file is already being processed again as task `%s` (%s)",
+			tb.shortTaskID(running.Task), strings.ToLower(string(running.Task.Status))))
+		return
+	}
+
+	details := map[string]interface{}{"file_name": task.FileName}
+	if clone != nil {
+		details["retry_task_id"] = clone.ID
+	}
+	tb.auditLogger(message).LogSystemAction(message.From.ID, message.From.UserName, storage.AdminActionTaskRetry, task.ID,
+		details, "SUCCESS", err)
+	if clone == nil {
+		tb.logger.WithError(err).WithField("task_id", task.ID).Error("Failed to retry task")
+		tb.respond(message, "❌ Error queuing task again. Please try again.")
+		return
+	}
+	if err != nil {
+		// The clone is queued; only its origin was not copied
+		tb.logger.WithError(err).WithField("task_id", clone.ID).Warn("Failed to copy task origin to retry")
+	}
+	if model := tb.costModel(); model != nil {
+		if err := tb.taskStore.SetEstimatedCost(clone.ID, model.Estimate(clone.FileType, clone.FileSize).Total()); err != nil {
+			tb.logger.WithError(err).WithField("task_id", clone.ID).Warn("Failed to save estimated cost")
+		}
+	}
+
+	tb.logger.WithFields(logrus.Fields{
+		"task_id":       task.ID,
+		"retry_task_id": clone.ID,
+		"user_id":       message.From.ID,
+	}).Info("Task retried by admin")
+
+	estimate, err := tb.taskStore.EstimateCompletion(clone, tb.estimateOptions())
+	if err != nil {
+		tb.logger.WithError(err).WithField("task_id", clone.ID).Warn("Failed to estimate task completion")
+	}
+	text := fmt.Sprintf("🔁 Task `%s` queued again as `%s`\n\n", tb.shortTaskID(task), tb.shortTaskID(clone)) +
+		tb.formatProgressMessage(clone, estimate)
+	messageID, err := tb.sendReply(message.Chat.ID, tb.messageThread(message), message.MessageID, text)
+	if err != nil {
+		tb.logger.WithError(err).WithField("task_id", clone.ID).Error("Failed to send retry confirmation")
+		return
+	}
+	tb.linkTaskMessage(clone.ID, message.Chat.ID, messageID, storage.TaskMessageConfirmation)
+	if estimate != nil {
+		if err := tb.taskStore.SaveProgressMessage(clone.ID, message.Chat.ID, messageID, text); err != nil {
+			tb.logger.WithError(err).WithField("task_id", clone.ID).Warn("Failed to track progress message")
+		}
+	}
+}
+
+func (tb *TelegramBot) handlePriorityCommand(message *tgbotapi.Message, args CommandArgs) {
+	priority := priorityLevels[args.Get("level")]
+
+	task, ok := tb.resolveTaskArgument(message, args.Get("id"), args.Usage())
+	if !ok {
+		return
+	}
+
+	if err := tb.taskStore.SetPriority(task.ID, priority); err != nil {
+		tb.respond(message, fmt.Sprintf("❌ Task `%s` is %s, only queued tasks can be reprioritized",
+			tb.shortTaskID(task), strings.ToLower(string(task.Status))))
+		return
+	}
+
+	text := fmt.Sprintf("⭐ Task `%s` priority set to %s", tb.shortTaskID(task), priorityName(priority))
+	if position, err := tb.taskStore.GetQueuePosition(task); err == nil {
+		text += fmt.Sprintf("\n📍 Queue position: #%d", position)
+	}
+	tb.respond(message, text)
+}
+
+// priorityName returns the /priority level name for a stored priority
+func priorityName(priority int) string {
+	switch {
+	case priority >= models.TaskPriorityHigh:
+		return "high"
+	case priority <= models.TaskPriorityLow:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// parserVersionNote names the parser a task was converted with, and whether
+// it is older than the current one
+func parserVersionNote(version int) string {
+	switch {
+	case version == 0:
+		return ""
+	case version < convert.ParserVersion:
+		return fmt.Sprintf(" (parser v%d, current v%d)", version, convert.ParserVersion)
+	default:
+		return fmt.Sprintf(" (parser v%d)", version)
+	}
+}
+
+// formatTaskDeletion describes a deleted task's purge state for /task
+func formatTaskDeletion(deletion *storage.TaskDeletion) string {
+	switch {
+	case deletion.PurgedAt != nil:
+		return fmt.Sprintf("🗑️ Deleted %s, %d files purged %s\n", deletion.DeletedAt.Format("2006-01-02 15:04"),
+			deletion.FilesRemoved, deletion.PurgedAt.Format("2006-01-02 15:04"))
+	case deletion.PurgeError != "":
+		return fmt.Sprintf("🗑️ Deleted %s, purge failing: `%s`\n", deletion.DeletedAt.Format("2006-01-02 15:04"),
+			strings.ReplaceAll(deletion.PurgeError, "`", "'"))
+	default:
+		return fmt.Sprintf("🗑️ Deleted %s, files purged after %s\n", deletion.DeletedAt.Format("2006-01-02 15:04"),
+			deletion.PurgeAfter.Format("2006-01-02 15:04"))
+	}
+}
+
+// maxTaskErrorLines caps how many failures /task lists
+const maxTaskErrorLines = 5
+
+// formatTaskErrorHistory lists a task's most recent failures with their stage,
+// attempt, error category and chosen retry delay
+func formatTaskErrorHistory(history []*storage.TaskError) string {
+	var b strings.Builder
+	summary := storage.SummarizeTaskErrors(history)
+	fmt.Fprintf(&b, "📜 Failures: %d", summary.Failures)
+	if summary.DominantCategory != "" {
+		fmt.Fprintf(&b, " (mostly %s)", summary.DominantCategory)
+	}
+	b.WriteString("\n")
+
+	shown := history
+	if len(shown) > maxTaskErrorLines {
+		shown = shown[len(shown)-maxTaskErrorLines:]
+	}
+	for _, taskErr := range shown {
+		message := taskErr.ErrorMessage
+		if runes := []rune(message); len(runes) > 120 {
+			message = string(runes[:117]) + "..."
+		}
+		fmt.Fprintf(&b, "  • %s %s #%d [%s]", taskErr.OccurredAt.Format("01-02 15:04"), taskErr.Stage,
+			taskErr.Attempt, taskErr.ErrorCategory)
+		if taskErr.RetryDelay > 0 {
+			fmt.Fprintf(&b, ", retry in %s", taskErr.RetryDelay)
+		}
+		fmt.Fprintf(&b, ": `%s`\n", strings.ReplaceAll(message, "`", "'"))
+	}
+	return b.String()
+}
+
+// formatArchiveMetadata renders the inspected archive layout of a task
+func formatArchiveMetadata(meta *utils.ArchiveMetadata) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🗜 Archive: %s, %d entries", meta.Format, meta.Entries)
+	if meta.Directories > 0 {
+		fmt.Fprintf(&b, " (%d folders)", meta.Directories)
+	}
+	fmt.Fprintf(&b, ", %s unpacked", formatStatsBytes(meta.UncompressedSize))
+	if ratio := meta.Ratio(); ratio > 0 {
+		fmt.Fprintf(&b, ", ratio %.1fx (max entry %.1fx)", ratio, meta.MaxEntryRatio)
+	}
+	b.WriteString("\n")
+
+	if len(meta.Methods) > 0 {
+		methods := make([]string, 0, len(meta.Methods))
+		for method, count := range meta.Methods {
+			methods = append(methods, fmt.Sprintf("%s %d", method, count))
+		}
+		sort.Strings(methods)
+		fmt.Fprintf(&b, "⚙️ Methods: %s\n", strings.Join(methods, ", "))
+	}
+	if meta.Encrypted {
+		if meta.EncryptedEntries > 0 {
+			fmt.Fprintf(&b, "🔒 Encrypted: %d entries\n", meta.EncryptedEntries)
+		} else {
+			b.WriteString("🔒 Encrypted: yes\n")
+		}
+	}
+	if meta.Comment != "" {
+		comment := meta.Comment
+		if runes := []rune(comment); len(runes) > 120 {
+			comment = string(runes[:117]) + "..."
+		}
+		fmt.Fprintf(&b, "💬 Comment: `%s`\n", strings.ReplaceAll(comment, "`", "'"))
+	}
+	if meta.EntryComments > 0 {
+		fmt.Fprintf(&b, "💬 Entry comments: %d\n", meta.EntryComments)
+	}
+	if meta.Partial {
+		fmt.Fprintf(&b, "⚠️ Inspection incomplete: `%s`\n", strings.ReplaceAll(meta.Error, "`", "'"))
+	}
+	return b.String()
+}
@@ -1,35 +1,109 @@
 package bot
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/sirupsen/logrus"
 
+	"telegram-archive-bot/app/extraction/progress"
+	"telegram-archive-bot/monitoring"
 	"telegram-archive-bot/storage"
 	"telegram-archive-bot/utils"
 )
 
 type TelegramBot struct {
-	bot       *tgbotapi.BotAPI
+	bot       utils.TelegramClient
+	self      tgbotapi.User // The bot's own account
 	config    *utils.Config
 	logger    *logrus.Logger
-	taskStore *storage.TaskStore
-	stopChan  chan struct{}
+	taskStore Store
+	// auditDB holds the admin audit log
+	auditDB  *sql.DB
+	stopChan chan struct{}
+
+	// messageThreads maps incoming messages being handled to their forum topic
+	messageThreads sync.Map
+
+	// stageProgress is the running extraction/conversion pass, nil when idle
+	stageProgress   *progress.Report
+	stageProgressMu sync.RWMutex
+
+	// signatures are the security signature definitions reloaded by /signatures
+	signatures *utils.SignatureRegistry
+
+	// bandwidth is the download bandwidth limit adjusted by /throttle
+	bandwidth *utils.BandwidthLimiter
+
+	// stageLimits are the stage concurrency limits adjusted by /limits
+	stageLimits *utils.StageLimits
+
+	// costs predicts each file's processing time and disk use for ETAs and admission
+	costs *storage.CostEstimator
+
+	// commands are the registered bot commands
+	commands *CommandRegistry
+
+	// conversations are the multi-step flows waiting for a user's answer
+	conversations *ConversationManager
+
+	// sessions group each admin's audited interactions
+	sessions *AdminSessionTracker
+
+	// backups takes the database backups of /backup and the restores of
+	// /restore, one at a time
+	backups       *storage.BackupService
+	backupRunning atomic.Bool
+	// restores are the /restore requests waiting for a second admin
+	restores restoreRequests
+	// cycleRunning reports whether the orchestrator is mid-cycle, which a
+	// restore waits out; restored runs recovery after a restore
+	cycleRunning func() bool
+	restored     func()
+
+	// pollHook is called after each successful getUpdates, e.g. to feed the watchdog
+	pollHook func()
+	// handling tracks the updates whose handlers are running
+	handling *updateHandlers
+
+	// updater is the self-updater whose status /version shows, nil when not set
+	updater *utils.SelfUpdater
+
+	// health is the health monitor whose last check /status shows and
+	// whose diagnostics /diag runs
+	health *monitoring.HealthMonitor
+	// diagBundleRunning allows one /diag bundle at a time
+	diagBundleRunning atomic.Bool
+
+	// pipelineGraph snapshots the pipeline for /pipeline, nil when this
+	// process does not run the orchestrator
+	pipelineGraph func() (*monitoring.PipelineGraph, error)
 }
 
-func NewTelegramBot(config *utils.Config, logger *logrus.Logger, taskStore *storage.TaskStore) (*TelegramBot, error) {
+// NewTelegramBot connects to the Bot API. taskStore holds the tasks and
+// auditDB the admin audit log, usually the same database
+func NewTelegramBot(config *utils.Config, logger *logrus.Logger, taskStore Store, auditDB *sql.DB) (*TelegramBot, error) {
 	var bot *tgbotapi.BotAPI
 	var err error
 
+	// Every Bot API exchange is counted for the network metrics
+	client := utils.Traffic.BotAPI.Client(&http.Client{})
+
 	// Check if using Local Bot API
 	if config.UseLocalBotAPI {
-		bot, err = tgbotapi.NewBotAPIWithAPIEndpoint(
+		bot, err = tgbotapi.NewBotAPIWithClient(
 			config.TelegramBotToken,
 			config.LocalBotAPIURL+"/bot%s/%s",
+			client,
 		)
 	} else {
-		bot, err = tgbotapi.NewBotAPI(config.TelegramBotToken)
+		bot, err = tgbotapi.NewBotAPIWithClient(config.TelegramBotToken, tgbotapi.APIEndpoint, client)
 	}
 
 	if err != nil {
@@ -38,34 +112,106 @@ func NewTelegramBot(config *utils.Config, logger *logrus.Logger, taskStore *stor
 
 	logger.WithField("username", bot.Self.UserName).Info("Telegram bot authorized")
 
-	return &TelegramBot{
-		bot:       bot,
+	return NewTelegramBotWithClient(config, logger, taskStore, auditDB, bot, bot.Self), nil
+}
+
+// NewTelegramBotWithClient creates a bot that talks to Telegram through client,
+// e.g. the fake client of bot/bottest in integration tests. self is the bot's
+// own account, which keys its saved update offsets
+func NewTelegramBotWithClient(config *utils.Config, logger *logrus.Logger, taskStore Store, auditDB *sql.DB, client utils.TelegramClient, self tgbotapi.User) *TelegramBot {
+	tb := &TelegramBot{
+		bot:       client,
+		self:      self,
 		config:    config,
 		logger:    logger,
 		taskStore: taskStore,
+		auditDB:   auditDB,
 		stopChan:  make(chan struct{}),
-	}, nil
+		commands:  NewCommandRegistry(),
+		sessions:  NewAdminSessionTracker(AdminSessionIdleTimeout),
+	}
+	tb.handling = newUpdateHandlers()
+	tb.conversations = NewConversationManager(tb.conversationTimedOut)
+	tb.registerCommands()
+
+	return tb
 }
 
 func (tb *TelegramBot) Start() error {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	updates := tb.bot.GetUpdatesChan(u)
+	go func() {
+		<-tb.stopChan
+		tb.logger.Info("Bot stopping...")
+		cancel()
+	}()
 
 	tb.logger.Info("Bot started, listening for updates...")
 
+	return tb.PollUpdates(ctx)
+}
+
+// SetPollHook installs a callback run after each successful poll of Telegram
+func (tb *TelegramBot) SetPollHook(hook func()) {
+	tb.pollHook = hook
+}
+
+// PollUpdates long-polls Telegram until ctx is cancelled. Unlike Start it can be
+// called again after returning, which leader failover relies on
+func (tb *TelegramBot) PollUpdates(ctx context.Context) error {
+	// Resume after the last update handled before a restart or failover
+	u := tgbotapi.NewUpdate(tb.loadUpdateOffset())
+	u.Timeout = 10 // Short long-poll so leadership changes take effect quickly
+	lastPrune := time.Time{}
+
+	tb.logger.Info("Bot polling for updates...")
+
 	for {
 		select {
-		case <-tb.stopChan:
-			tb.logger.Info("Bot stopping...")
+		case <-ctx.Done():
+			tb.logger.Info("Bot polling stopped")
 			return nil
-		case update := <-updates:
-			if update.Message == nil {
-				continue
+		default:
+		}
+
+		updates, threads, err := tb.getUpdates(u)
+		if err != nil {
+			wait := 3 * time.Second
+			if retryAfter, ok := utils.TelegramRetryAfter(err); ok {
+				wait = retryAfter
+			}
+			tb.logger.WithError(err).WithField("retry_in", wait).Warn("Failed to get updates, retrying")
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(wait):
 			}
+			continue
+		}
+		if tb.pollHook != nil {
+			tb.pollHook()
+		}
+
+		next, started := tb.dispatchUpdates(u.Offset, updates, threads)
+		if next != u.Offset {
+			u.Offset = next
+			tb.saveUpdateOffset(u.Offset)
+		} else if len(updates) > 0 && started == 0 {
+			// Only updates still being handled came back; poll again once
+			// one of them finishes
+			select {
+			case <-ctx.Done():
+				tb.logger.Info("Bot polling stopped")
+				return nil
+			case <-tb.handling.finished:
+			case <-time.After(updateRecheckInterval):
+			}
+		}
 
-			go tb.handleUpdate(update)
+		if time.Since(lastPrune) > time.Hour {
+			tb.pruneProcessedUpdates()
+			lastPrune = time.Now()
 		}
 	}
 }
@@ -75,15 +221,53 @@ func (tb *TelegramBot) Stop() {
 	tb.bot.StopReceivingUpdates()
 }
 
-func (tb *TelegramBot) GetBotAPI() *tgbotapi.BotAPI {
+func (tb *TelegramBot) GetBotAPI() utils.TelegramClient {
 	return tb.bot
 }
 
 func (tb *TelegramBot) SendMessage(chatID int64, text string) error {
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ParseMode = "Markdown"
-	_, err := tb.bot.Send(msg)
-	return err
+	return tb.withFloodWait(func() error {
+		_, err := tb.bot.Send(msg)
+		return err
+	})
+}
+
+// SendMessageWithID sends a message and returns its message ID for later edits
+func (tb *TelegramBot) SendMessageWithID(chatID int64, text string) (int, error) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	var sent tgbotapi.Message
+	err := tb.withFloodWait(func() error {
+		var sendErr error
+		sent, sendErr = tb.bot.Send(msg)
+		return sendErr
+	})
+	if err != nil {
+		return 0, err
+	}
+	return sent.MessageID, nil
+}
+
+// EditMessageKeyboard replaces the text and inline keyboard of a previously sent message
+func (tb *TelegramBot) EditMessageKeyboard(chatID int64, messageID int, text string, keyboard tgbotapi.InlineKeyboardMarkup) error {
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, keyboard)
+	edit.ParseMode = "Markdown"
+	return tb.withFloodWait(func() error {
+		_, err := tb.bot.Send(edit)
+		return err
+	})
+}
+
+// EditMessage replaces the text of a previously sent message
+func (tb *TelegramBot) EditMessage(chatID int64, messageID int, text string) error {
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ParseMode = "Markdown"
+	return tb.withFloodWait(func() error {
+		_, err := tb.bot.Send(edit)
+		return err
+	})
 }
 
 // SendDocument sends a file document to the specified chat ID with a caption
@@ -92,7 +276,10 @@ func (tb *TelegramBot) SendDocument(chatID int64, filePath string, caption strin
 	doc.Caption = caption
 	doc.ParseMode = "Markdown"
 
-	_, err := tb.bot.Send(doc)
+	err := tb.withFloodWait(func() error {
+		_, sendErr := tb.bot.Send(doc)
+		return sendErr
+	})
 	if err != nil {
 		return fmt.Errorf("failed to send document %s: %w", filePath, err)
 	}
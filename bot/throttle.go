@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// SetBandwidthLimiter sets the download bandwidth limit that /throttle shows and overrides
+func (tb *TelegramBot) SetBandwidthLimiter(limiter *utils.BandwidthLimiter) {
+	tb.bandwidth = limiter
+}
+
+// handleThrottleCommand shows or overrides the download bandwidth limit:
+// /throttle [<rate>|off|auto] [duration]
+func (tb *TelegramBot) handleThrottleCommand(message *tgbotapi.Message, args CommandArgs) {
+	if tb.bandwidth == nil {
+		tb.respond(message, "❌ Bandwidth limiting is not available")
+		return
+	}
+
+	setting := args.Get("rate|off|auto")
+	if setting == "" {
+		tb.respond(message, tb.formatThrottleStatus("🚦 *Download bandwidth*"))
+		return
+	}
+
+	details := map[string]interface{}{"setting": setting}
+	if strings.EqualFold(setting, "auto") {
+		if args.Has("duration") {
+			tb.respond(message, args.Usage())
+			return
+		}
+		tb.bandwidth.ClearOverride()
+	} else {
+		rate, err := utils.ParseBandwidthRate(setting)
+		if err != nil {
+			tb.respond(message, args.Usage())
+			return
+		}
+		var duration time.Duration
+		if args.Has("duration") {
+			duration, err = time.ParseDuration(args.Get("duration"))
+			if err != nil || duration <= 0 {
+				tb.respond(message, args.Usage())
+				return
+			}
+			details["duration"] = duration.String()
+		}
+		details["rate_bytes_per_second"] = rate
+		tb.bandwidth.SetOverride(rate, duration)
+	}
+
+	tb.auditLogger(message).LogSystemAction(message.From.ID, message.From.UserName, storage.AdminActionConfigChange,
+		"bandwidth_limit", details, "success", nil)
+
+	tb.respond(message, tb.formatThrottleStatus("✅ *Download bandwidth updated*"))
+}
+
+// formatThrottleStatus describes the limit in effect, any override and the schedule
+func (tb *TelegramBot) formatThrottleStatus(title string) string {
+	var b strings.Builder
+	rate, source := tb.bandwidth.Rate()
+	fmt.Fprintf(&b, "%s\n\nCurrent limit: %s (`%s`)\n", title, utils.FormatBandwidthRate(rate), source)
+
+	if overrideRate, until, active := tb.bandwidth.Override(); active {
+		expiry := "until `/throttle auto`"
+		if !until.IsZero() {
+			expiry = "until " + until.Format("15:04")
+		}
+		fmt.Fprintf(&b, "Override: %s %s\n", utils.FormatBandwidthRate(overrideRate), expiry)
+	}
+
+	schedule := tb.bandwidth.Schedule()
+	b.WriteString("\nSchedule:\n")
+	for _, w := range schedule.Windows {
+		fmt.Fprintf(&b, "• `%s`: %s\n", w.String(), utils.FormatBandwidthRate(w.Rate))
+	}
+	fmt.Fprintf(&b, "• Otherwise: %s", utils.FormatBandwidthRate(schedule.Default))
+
+	now := time.Now()
+	if usage, err := tb.taskStore.GetBandwidthUsage(now.Add(-24*time.Hour), now); err != nil {
+		tb.logger.WithError(err).Warn("Failed to get bandwidth usage")
+	} else {
+		fmt.Fprintf(&b, "\n\nLast 24h: %s downloaded for %d tasks, %s of Bot API requests",
+			formatStatsBytes(usage.DownloadedBytes), usage.Tasks, formatStatsBytes(usage.BotAPIBytes))
+	}
+	return b.String()
+}
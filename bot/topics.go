@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// The telegram-bot-api v5.5.1 types predate forum topics, so message_thread_id
+// is decoded from the raw update and sent as an extra request parameter
+
+// topicFields holds the forum topic fields missing from tgbotapi.Message
+type topicFields struct {
+	MessageThreadID int  `json:"message_thread_id"`
+	IsTopicMessage  bool `json:"is_topic_message"`
+}
+
+// rawTopicUpdate mirrors the parts of an update needed to find its topic
+type rawTopicUpdate struct {
+	UpdateID int          `json:"update_id"`
+	Message  *topicFields `json:"message"`
+}
+
+// getUpdates fetches updates like BotAPI.GetUpdates and also returns the forum
+// topic of each message, keyed by update ID
+func (tb *TelegramBot) getUpdates(config tgbotapi.UpdateConfig) ([]tgbotapi.Update, map[int]int, error) {
+	resp, err := tb.bot.Request(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var updates []tgbotapi.Update
+	if err := json.Unmarshal(resp.Result, &updates); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode updates: %w", err)
+	}
+
+	var raw []rawTopicUpdate
+	if err := json.Unmarshal(resp.Result, &raw); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode update topics: %w", err)
+	}
+
+	threads := make(map[int]int)
+	for _, r := range raw {
+		// Replies in ordinary groups also carry a thread ID; only forum topics
+		// accept it when sending
+		if r.Message != nil && r.Message.IsTopicMessage && r.Message.MessageThreadID != 0 {
+			threads[r.UpdateID] = r.Message.MessageThreadID
+		}
+	}
+
+	return updates, threads, nil
+}
+
+// messageThread returns the forum topic an incoming message was posted in, or 0
+func (tb *TelegramBot) messageThread(message *tgbotapi.Message) int {
+	if threadID, ok := tb.messageThreads.Load(message); ok {
+		return threadID.(int)
+	}
+	return 0
+}
+
+// respond sends a reply into the chat and forum topic the message came from
+func (tb *TelegramBot) respond(message *tgbotapi.Message, text string) error {
+	_, err := tb.SendMessageToThread(message.Chat.ID, tb.messageThread(message), text)
+	return err
+}
+
+// SendMessageToThread sends a Markdown message into a forum topic and returns
+// its message ID; threadID 0 sends to the chat itself
+func (tb *TelegramBot) SendMessageToThread(chatID int64, threadID int, text string) (int, error) {
+	return tb.sendReply(chatID, threadID, 0, text)
+}
+
+// sendReply sends a Markdown message into a forum topic as a reply to
+// replyTo; zero threadID or replyTo leave that part out
+func (tb *TelegramBot) sendReply(chatID int64, threadID, replyTo int, text string) (int, error) {
+	if threadID == 0 && replyTo == 0 {
+		return tb.SendMessageWithID(chatID, text)
+	}
+
+	params := tgbotapi.Params{
+		"chat_id":    strconv.FormatInt(chatID, 10),
+		"text":       text,
+		"parse_mode": "Markdown",
+	}
+	params.AddNonZero("message_thread_id", threadID)
+	if replyTo != 0 {
+		params.AddNonZero("reply_to_message_id", replyTo)
+		// The original upload may have been deleted; still deliver the message
+		params.AddBool("allow_sending_without_reply", true)
+	}
+	return tb.sendMessageParams(params)
+}
+
+// sendKeyboard sends a Markdown message with an inline keyboard into a forum
+// topic; threadID 0 sends to the chat itself
+func (tb *TelegramBot) sendKeyboard(chatID int64, threadID int, text string, keyboard tgbotapi.InlineKeyboardMarkup) (int, error) {
+	params := tgbotapi.Params{
+		"chat_id":    strconv.FormatInt(chatID, 10),
+		"text":       text,
+		"parse_mode": "Markdown",
+	}
+	params.AddNonZero("message_thread_id", threadID)
+	if err := params.AddInterface("reply_markup", keyboard); err != nil {
+		return 0, fmt.Errorf("failed to encode keyboard: %w", err)
+	}
+	return tb.sendMessageParams(params)
+}
+
+// sendMessageParams calls sendMessage and returns the sent message's ID
+func (tb *TelegramBot) sendMessageParams(params tgbotapi.Params) (int, error) {
+	var resp *tgbotapi.APIResponse
+	err := tb.withFloodWait(func() error {
+		var sendErr error
+		resp, sendErr = tb.bot.MakeRequest("sendMessage", params)
+		return sendErr
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var sent tgbotapi.Message
+	if err := json.Unmarshal(resp.Result, &sent); err != nil {
+		return 0, fmt.Errorf("failed to decode sent message: %w", err)
+	}
+	return sent.MessageID, nil
+}
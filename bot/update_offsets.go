@@ -0,0 +1,236 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/storage"
+)
+
+// maxUpdateAttempts is how often an update whose handler fails is handled
+// before it is given up, so one bad update cannot hold up polling forever
+const maxUpdateAttempts = 3
+
+// updateRecheckInterval is how long polling waits when every update it got
+// back is still being handled
+const updateRecheckInterval = time.Second
+
+// updateHoldTimeout is how long an update still being handled holds the
+// offset back. A longer handler, such as /backup or a flood wait, lets polling
+// move on to newer updates; only a crash within this time has it redelivered
+const updateHoldTimeout = 30 * time.Second
+
+// updateHandlers tracks the updates being handled by this process
+type updateHandlers struct {
+	mu       sync.Mutex
+	running  map[int]bool
+	handled  map[int]bool      // Updates known to be handled, so no claim is needed
+	held     map[int]time.Time // When an unfinished update started holding the offset
+	attempts map[int]int       // Failed attempts of updates that will be retried
+	// holdTimeout is updateHoldTimeout, shortened in tests
+	holdTimeout time.Duration
+	// finished is signalled when a handler returns
+	finished chan struct{}
+}
+
+func newUpdateHandlers() *updateHandlers {
+	return &updateHandlers{
+		running:     make(map[int]bool),
+		handled:     make(map[int]bool),
+		held:        make(map[int]time.Time),
+		attempts:    make(map[int]int),
+		holdTimeout: updateHoldTimeout,
+		finished:    make(chan struct{}, 1),
+	}
+}
+
+// hold reports whether an unfinished update still holds the offset back
+func (h *updateHandlers) hold(updateID int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	since, ok := h.held[updateID]
+	if !ok {
+		h.held[updateID] = time.Now()
+		return true
+	}
+	return time.Since(since) < h.holdTimeout
+}
+
+// loadUpdateOffset returns the saved getUpdates offset, or 0 to let Telegram
+// resend everything it has not yet seen confirmed
+func (tb *TelegramBot) loadUpdateOffset() int {
+	offset, err := tb.taskStore.GetUpdateOffset(tb.self.ID)
+	if err != nil {
+		tb.logger.WithError(err).Warn("Failed to load saved update offset, starting from Telegram's queue")
+		return 0
+	}
+	if offset > 0 {
+		tb.logger.WithField("offset", offset).Info("Resuming updates from saved offset")
+	}
+	return offset
+}
+
+// saveUpdateOffset persists the offset once the updates before it are handled
+func (tb *TelegramBot) saveUpdateOffset(offset int) {
+	if err := tb.taskStore.SaveUpdateOffset(tb.self.ID, offset); err != nil {
+		tb.logger.WithError(err).WithField("offset", offset).Warn("Failed to save update offset")
+	}
+}
+
+// dispatchUpdates starts a handler for each update of a batch that is not
+// handled yet, and returns the next getUpdates offset and how many handlers
+// it started. The offset stops at the first update still being handled, here
+// or by another instance, so a crash mid-way has it delivered again. An update
+// holds the offset for at most updateHoldTimeout, so a slow handler does not
+// keep newer updates from being fetched
+func (tb *TelegramBot) dispatchUpdates(offset int, updates []tgbotapi.Update, threads map[int]int) (int, int) {
+	next := offset
+	unfinished := -1
+	started := 0
+
+	holdAt := func(updateID int) {
+		if tb.handling.hold(updateID) && (unfinished < 0 || updateID < unfinished) {
+			unfinished = updateID
+		}
+	}
+
+	for _, update := range updates {
+		updateID := update.UpdateID
+		if updateID >= next {
+			next = updateID + 1
+		}
+
+		tb.handling.mu.Lock()
+		running := tb.handling.running[updateID]
+		done := tb.handling.handled[updateID] || tb.handling.attempts[updateID] >= maxUpdateAttempts
+		tb.handling.mu.Unlock()
+		if done {
+			continue
+		}
+
+		claimed := true
+		if !running {
+			claimed, running = tb.claimUpdate(updateID)
+		}
+		if running {
+			holdAt(updateID)
+			continue
+		}
+		if !claimed {
+			tb.handling.mu.Lock()
+			tb.handling.handled[updateID] = true
+			tb.handling.mu.Unlock()
+			continue
+		}
+
+		tb.handling.mu.Lock()
+		tb.handling.running[updateID] = true
+		tb.handling.mu.Unlock()
+		holdAt(updateID)
+		started++
+		go tb.handleClaimedUpdate(update, threads[updateID])
+	}
+
+	if unfinished >= 0 && unfinished < next {
+		next = unfinished
+	}
+
+	// Updates before the offset are not delivered again
+	tb.handling.mu.Lock()
+	for updateID := range tb.handling.handled {
+		if updateID < next {
+			delete(tb.handling.handled, updateID)
+		}
+	}
+	for updateID := range tb.handling.held {
+		if updateID < next {
+			delete(tb.handling.held, updateID)
+		}
+	}
+	for updateID := range tb.handling.attempts {
+		if updateID < next {
+			delete(tb.handling.attempts, updateID)
+		}
+	}
+	tb.handling.mu.Unlock()
+
+	return next, started
+}
+
+// claimUpdate claims an update for this process. claimed reports whether it
+// should be handled; busy whether another instance is handling it. Updates
+// already handled are replays, e.g. redelivered after a crash before the
+// offset was saved or fetched by two instances around a failover. If the
+// claim cannot be recorded the update is still handled rather than dropped
+func (tb *TelegramBot) claimUpdate(updateID int) (claimed, busy bool) {
+	claim, err := tb.taskStore.ClaimUpdate(tb.self.ID, updateID)
+	if err != nil {
+		tb.logger.WithError(err).WithField("update_id", updateID).Warn("Failed to record update, handling it anyway")
+		return true, false
+	}
+	switch claim {
+	case storage.UpdateHandled:
+		tb.logger.WithField("update_id", updateID).Info("Skipping update that was already handled")
+		return false, false
+	case storage.UpdateBusy:
+		tb.logger.WithField("update_id", updateID).Debug("Update is being handled by another instance")
+		return false, true
+	}
+	return true, false
+}
+
+// handleClaimedUpdate handles an update, then records it as handled, or
+// releases the claim when the handler failed so it is handled again
+func (tb *TelegramBot) handleClaimedUpdate(update tgbotapi.Update, threadID int) {
+	handled := true
+	switch {
+	case update.InlineQuery != nil:
+		handled = tb.handleInlineQuerySafe(update.InlineQuery)
+	case update.CallbackQuery != nil:
+		handled = tb.handleCallbackQuerySafe(update.CallbackQuery)
+	case update.Message != nil:
+		handled = tb.handleUpdateSafe(update, threadID)
+	}
+
+	updateID := update.UpdateID
+	if handled {
+		if err := tb.taskStore.CompleteUpdate(tb.self.ID, updateID); err != nil {
+			tb.logger.WithError(err).WithField("update_id", updateID).Warn("Failed to record update as handled")
+		}
+	} else {
+		tb.logger.WithField("update_id", updateID).Warn("Update handler failed, releasing the update")
+		if err := tb.taskStore.ReleaseUpdate(tb.self.ID, updateID); err != nil {
+			tb.logger.WithError(err).WithField("update_id", updateID).Warn("Failed to release update")
+		}
+	}
+
+	tb.handling.mu.Lock()
+	delete(tb.handling.running, updateID)
+	delete(tb.handling.held, updateID)
+	if handled {
+		tb.handling.handled[updateID] = true
+		delete(tb.handling.attempts, updateID)
+	} else {
+		tb.handling.attempts[updateID]++
+	}
+	tb.handling.mu.Unlock()
+
+	select {
+	case tb.handling.finished <- struct{}{}:
+	default:
+	}
+}
+
+// pruneProcessedUpdates drops claims too old to be redelivered
+func (tb *TelegramBot) pruneProcessedUpdates() {
+	removed, err := tb.taskStore.PruneProcessedUpdates(storage.ProcessedUpdateRetention)
+	if err != nil {
+		tb.logger.WithError(err).Warn("Failed to prune processed updates")
+		return
+	}
+	if removed > 0 {
+		tb.logger.WithField("removed", removed).Debug("Pruned processed updates")
+	}
+}
@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+
+	"telegram-archive-bot/bot/bottest"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+func TestPollUpdatesOffsetWaitsForHandlers(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	taskStore := storage.NewTaskStore(db)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	client := bottest.NewClient()
+	config := &utils.Config{AdminIDs: []int64{42}}
+	tb := NewTelegramBotWithClient(config, logger, taskStore, db.DB(), client, client.Self)
+	botID := client.Self.ID
+
+	// Update 1 is being handled by another instance
+	client.AddMessage(42, "/start")
+	if claim, err := taskStore.ClaimUpdate(botID, 1); err != nil || claim != storage.UpdateClaimed {
+		t.Fatalf("ClaimUpdate = %v, %v", claim, err)
+	}
+	// Update 2's handler panics: a message without a sender
+	client.AddUpdate(tgbotapi.Update{Message: &tgbotapi.Message{MessageID: 99, Chat: &tgbotapi.Chat{ID: 42}}})
+	client.AddMessage(42, "/help")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	polling := make(chan struct{})
+	go func() {
+		defer close(polling)
+		tb.PollUpdates(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-polling
+	}()
+
+	waitFor(t, "/help to be answered", func() bool {
+		return len(client.CallsTo("sendMessage")) == 1
+	})
+	waitFor(t, "the failing update to be given up", func() bool {
+		tb.handling.mu.Lock()
+		defer tb.handling.mu.Unlock()
+		return tb.handling.attempts[2] == maxUpdateAttempts
+	})
+	if offset, _ := taskStore.GetUpdateOffset(botID); offset > 1 {
+		t.Fatalf("offset saved as %d while update 1 is still being handled", offset)
+	}
+
+	// Once the other instance finishes, the offset moves past the batch
+	if err := taskStore.CompleteUpdate(botID, 1); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the offset to move past the batch", func() bool {
+		offset, _ := taskStore.GetUpdateOffset(botID)
+		return offset == 4
+	})
+	if calls := client.CallsTo("sendMessage"); len(calls) != 1 {
+		t.Fatalf("update handled by the other instance answered again: %+v", calls)
+	}
+
+	if claim, _ := taskStore.ClaimUpdate(botID, 3); claim != storage.UpdateHandled {
+		t.Errorf("handled update 3 claim = %v, want UpdateHandled", claim)
+	}
+	// The failed update's claim was released rather than recorded as handled
+	if claim, _ := taskStore.ClaimUpdate(botID, 2); claim != storage.UpdateClaimed {
+		t.Errorf("failed update 2 claim = %v, want UpdateClaimed", claim)
+	}
+}
+
+func TestPollUpdatesHoldsOffsetForLimitedTime(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	taskStore := storage.NewTaskStore(db)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	client := bottest.NewClient()
+	config := &utils.Config{AdminIDs: []int64{42}}
+	tb := NewTelegramBotWithClient(config, logger, taskStore, db.DB(), client, client.Self)
+	tb.handling.holdTimeout = 200 * time.Millisecond
+	botID := client.Self.ID
+
+	// Update 1 is stuck in a handler on another instance
+	client.AddMessage(42, "/start")
+	if _, err := taskStore.ClaimUpdate(botID, 1); err != nil {
+		t.Fatal(err)
+	}
+	client.AddMessage(42, "/help")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	polling := make(chan struct{})
+	go func() {
+		defer close(polling)
+		tb.PollUpdates(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-polling
+	}()
+
+	waitFor(t, "the offset to move past the stuck update", func() bool {
+		offset, _ := taskStore.GetUpdateOffset(botID)
+		return offset == 3
+	})
+
+	// Newer updates are fetched and handled while update 1 is still unfinished
+	client.AddMessage(42, "/help")
+	waitFor(t, "the newer update to be answered", func() bool {
+		return len(client.CallsTo("sendMessage")) == 2
+	})
+	if claim, _ := taskStore.ClaimUpdate(botID, 1); claim != storage.UpdateBusy {
+		t.Errorf("stuck update 1 claim = %v, want UpdateBusy", claim)
+	}
+}
@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// SetSelfUpdater sets the updater whose status /version shows
+func (tb *TelegramBot) SetSelfUpdater(updater *utils.SelfUpdater) {
+	tb.updater = updater
+}
+
+// handleVersionCommand shows the running build and, when self-update is
+// enabled, the latest release of the update channel
+func (tb *TelegramBot) handleVersionCommand(message *tgbotapi.Message, args CommandArgs) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🏷 *Version*\n\n")
+	build := utils.CurrentBuild()
+	fmt.Fprintf(&b, "Build: `%s`\n", build)
+	if build.Commit != "" {
+		fmt.Fprintf(&b, "Commit: `%s`\n", build.Commit)
+	}
+	if !build.BuiltAt.IsZero() {
+		fmt.Fprintf(&b, "Built: %s\n", build.BuiltAt.Format("2006-01-02 15:04"))
+	}
+	fmt.Fprintf(&b, "Go: %s (%s/%s)\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "Schema version: %d\n", storage.LatestSchemaVersion())
+
+	if tb.updater == nil {
+		tb.respond(message, b.String())
+		return
+	}
+
+	status := tb.updater.Status()
+	fmt.Fprintf(&b, "\n🔄 *Updates:* %s\n", status.State)
+	if status.State == utils.UpdateStateDisabled {
+		tb.respond(message, b.String())
+		return
+	}
+	fmt.Fprintf(&b, "Channel: %s\n", status.Channel)
+	if status.Latest != nil {
+		fmt.Fprintf(&b, "Latest release: `%s`", status.Latest.Version)
+		if !status.Latest.PublishedAt.IsZero() {
+			fmt.Fprintf(&b, " (%s)", status.Latest.PublishedAt.Format("2006-01-02"))
+		}
+		b.WriteString("\n")
+	}
+	if !status.LastCheck.IsZero() {
+		fmt.Fprintf(&b, "Last check: %s\n", status.LastCheck.Format("2006-01-02 15:04:05"))
+	}
+	if status.State == utils.UpdateStateStaged {
+		fmt.Fprintf(&b, "Restart: when idle, %s\n", tb.config.UpdateRestartWindow)
+	}
+	if status.LastError != "" {
+		fmt.Fprintf(&b, "Last error: `%s`\n", status.LastError)
+	}
+	tb.respond(message, b.String())
+}
@@ -0,0 +1,366 @@
+package cluster
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"telegram-archive-bot/app/extraction/convert"
+	"telegram-archive-bot/app/extraction/extract"
+	"telegram-archive-bot/utils"
+)
+
+// Directory layout used inside each job's working directory, matching the
+// cwd-relative paths expected by the extract and convert packages
+const (
+	agentAllDir  = "app/extraction/files/all"
+	agentPassDir = "app/extraction/files/pass"
+	agentTxtDir  = "app/extraction/files/txt"
+)
+
+// Agent runs on a worker node, pulling jobs from the coordinator and
+// processing them locally
+type Agent struct {
+	client       *JobClient
+	logger       *utils.Logger
+	workerID     string
+	workDir      string
+	kinds        []string
+	pollInterval time.Duration
+	lease        time.Duration
+}
+
+// tokenCredentials attaches the cluster token to every call
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{tokenMetadataKey: string(t)}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// Dial connects to a coordinator, authenticating with token when set and
+// over TLS when tlsConfig is set
+func Dial(addr, token string, tlsConfig *tls.Config) (*grpc.ClientConn, error) {
+	transport := insecure.NewCredentials()
+	if tlsConfig != nil {
+		transport = credentials.NewTLS(tlsConfig)
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(transport),
+	}
+	if token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials(token)))
+	}
+
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to coordinator %s: %w", addr, err)
+	}
+	return conn, nil
+}
+
+// NewAgent creates a worker agent that processes jobs under workDir
+func NewAgent(conn *grpc.ClientConn, workerID, workDir string, logger *utils.Logger) *Agent {
+	return &Agent{
+		client:       NewJobClient(conn),
+		logger:       logger,
+		workerID:     workerID,
+		workDir:      workDir,
+		kinds:        []string{JobExtract, JobConvert},
+		pollInterval: 5 * time.Second,
+		lease:        5 * time.Minute,
+	}
+}
+
+// Run registers with the coordinator and processes jobs until ctx is cancelled
+func (a *Agent) Run(ctx context.Context) error {
+	absWorkDir, err := filepath.Abs(a.workDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve work directory: %w", err)
+	}
+	a.workDir = absWorkDir
+	if err := os.MkdirAll(a.workDir, 0755); err != nil {
+		return fmt.Errorf("failed to create work directory: %w", err)
+	}
+
+	if err := a.register(ctx); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		job, err := a.client.PullJob(ctx, &PullJobRequest{WorkerID: a.workerID, Kinds: a.kinds})
+		if err != nil {
+			a.logger.WithError(err).Warn("Failed to pull job from coordinator")
+			// The coordinator may have restarted and forgotten this worker
+			if regErr := a.register(ctx); regErr != nil {
+				return regErr
+			}
+			continue
+		}
+
+		if job.ID == "" {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(a.pollInterval):
+			}
+			continue
+		}
+
+		a.processJob(ctx, job)
+	}
+}
+
+// register announces the worker, retrying until the coordinator is reachable
+func (a *Agent) register(ctx context.Context) error {
+	hostname, _ := os.Hostname()
+	req := &RegisterRequest{
+		WorkerID: a.workerID,
+		Hostname: hostname,
+		Kinds:    a.kinds,
+		Slots:    1,
+	}
+
+	for {
+		resp, err := a.client.Register(ctx, req)
+		if err == nil {
+			if resp.PollIntervalMs > 0 {
+				a.pollInterval = time.Duration(resp.PollIntervalMs) * time.Millisecond
+			}
+			if resp.LeaseMs > 0 {
+				a.lease = time.Duration(resp.LeaseMs) * time.Millisecond
+			}
+			a.logger.WithField("worker_id", a.workerID).Info("Registered with cluster coordinator")
+			return nil
+		}
+
+		a.logger.WithError(err).Warn("Failed to register with coordinator, retrying")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(a.pollInterval):
+		}
+	}
+}
+
+// processJob downloads a job's input, runs the stage locally and uploads the results
+func (a *Agent) processJob(ctx context.Context, job *Job) {
+	logger := a.logger.WithField("job_id", job.ID).WithField("kind", job.Kind).WithField("file", job.FileName)
+	logger.Info("Processing cluster job")
+
+	jobDir := filepath.Join(a.workDir, "jobs", job.ID)
+	defer os.RemoveAll(jobDir)
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go a.heartbeat(heartbeatCtx, job.ID)
+
+	startTime := time.Now()
+	outputs, err := a.runJob(ctx, job, jobDir)
+
+	complete := &CompleteJobRequest{WorkerID: a.workerID, JobID: job.ID, Success: err == nil}
+	if err == nil {
+		for _, output := range outputs {
+			if uploadErr := a.upload(ctx, job.ID, output); uploadErr != nil {
+				err = uploadErr
+				break
+			}
+			complete.Outputs = append(complete.Outputs, filepath.Base(output))
+		}
+	}
+	if err != nil {
+		complete.Success = false
+		complete.Error = err.Error()
+		complete.Outputs = nil
+	}
+
+	if completeErr := a.client.CompleteJob(ctx, complete); completeErr != nil {
+		logger.WithError(completeErr).Error("Failed to report job completion")
+		return
+	}
+
+	if err != nil {
+		logger.WithError(err).Warn("Cluster job failed")
+		return
+	}
+	logger.WithField("duration_seconds", time.Since(startTime).Seconds()).
+		WithField("outputs", len(outputs)).
+		Info("Cluster job completed")
+}
+
+// runJob executes the extract or convert stage inside jobDir and returns the result files
+func (a *Agent) runJob(ctx context.Context, job *Job, jobDir string) ([]string, error) {
+	inputDir := agentAllDir
+	if job.Kind == JobConvert {
+		inputDir = agentPassDir
+	}
+	for _, dir := range []string{agentAllDir, agentPassDir, agentTxtDir} {
+		if err := os.MkdirAll(filepath.Join(jobDir, dir), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create job directory: %w", err)
+		}
+	}
+
+	if err := a.download(ctx, job, filepath.Join(jobDir, inputDir, job.FileName)); err != nil {
+		return nil, err
+	}
+
+	// The extract and convert packages use cwd-relative paths
+	originalDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	if err := os.Chdir(jobDir); err != nil {
+		return nil, fmt.Errorf("failed to enter job directory: %w", err)
+	}
+	defer os.Chdir(originalDir)
+
+	var resultDir string
+	switch job.Kind {
+	case JobExtract:
+		passwords := strings.Join(job.Passwords, "\n")
+		if err := os.WriteFile("pass.txt", []byte(passwords), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write password list: %w", err)
+		}
+		extract.ExtractArchives(1)
+		resultDir = agentPassDir
+
+	case JobConvert:
+		os.Setenv("CONVERT_INPUT_DIR", agentPassDir)
+		os.Setenv("CONVERT_OUTPUT_FILE", filepath.Join(agentTxtDir, "converted.txt"))
+		if err := convert.ConvertTextFiles(1); err != nil {
+			return nil, fmt.Errorf("conversion failed: %w", err)
+		}
+		resultDir = agentTxtDir
+
+	default:
+		return nil, fmt.Errorf("unsupported job kind: %s", job.Kind)
+	}
+
+	entries, err := os.ReadDir(resultDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results: %w", err)
+	}
+
+	var outputs []string
+	for _, entry := range entries {
+		if entry.IsDir() || utils.IsPartialFile(entry.Name()) {
+			continue
+		}
+		outputs = append(outputs, filepath.Join(jobDir, resultDir, entry.Name()))
+	}
+	return outputs, nil
+}
+
+// download fetches a job's input file in chunks
+func (a *Agent) download(ctx context.Context, job *Job, path string) error {
+	out, err := utils.CreateAtomic(path, 0644)
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	for {
+		chunk, err := a.client.ReadChunk(ctx, &ReadChunkRequest{WorkerID: a.workerID, JobID: job.ID, Offset: offset})
+		if err != nil {
+			out.Abort()
+			return fmt.Errorf("failed to download job input: %w", err)
+		}
+		if _, err := out.Write(chunk.Data); err != nil {
+			out.Abort()
+			return fmt.Errorf("failed to write job input: %w", err)
+		}
+		offset += int64(len(chunk.Data))
+		if chunk.EOF || len(chunk.Data) == 0 {
+			break
+		}
+	}
+
+	if offset != job.FileSize {
+		out.Abort()
+		return fmt.Errorf("downloaded %d bytes, expected %d", offset, job.FileSize)
+	}
+
+	return out.Commit()
+}
+
+// upload sends a result file to the coordinator in chunks
+func (a *Agent) upload(ctx context.Context, jobID, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open result file: %w", err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, ChunkSize)
+	var offset int64
+	for {
+		n, readErr := f.Read(buf)
+		if n > 0 {
+			req := &WriteChunkRequest{
+				WorkerID: a.workerID,
+				JobID:    jobID,
+				FileName: filepath.Base(path),
+				Offset:   offset,
+				Data:     buf[:n],
+			}
+			if err := a.client.WriteChunk(ctx, req); err != nil {
+				return fmt.Errorf("failed to upload result file: %w", err)
+			}
+			offset += int64(n)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("failed to read result file: %w", readErr)
+		}
+	}
+
+	// Empty files still need to exist on the coordinator
+	if offset == 0 {
+		req := &WriteChunkRequest{WorkerID: a.workerID, JobID: jobID, FileName: filepath.Base(path)}
+		if err := a.client.WriteChunk(ctx, req); err != nil {
+			return fmt.Errorf("failed to upload result file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// heartbeat keeps the job lease alive while it is being processed
+func (a *Agent) heartbeat(ctx context.Context, jobID string) {
+	interval := a.lease / 3
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.client.Heartbeat(ctx, &HeartbeatRequest{WorkerID: a.workerID, JobID: jobID}); err != nil {
+				a.logger.WithError(err).WithField("job_id", jobID).Warn("Failed to send heartbeat")
+			}
+		}
+	}
+}
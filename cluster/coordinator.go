@@ -0,0 +1,558 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"telegram-archive-bot/utils"
+)
+
+// Jobs are returned to local processing after this many lost leases
+const maxJobAttempts = 3
+
+// Coordinator hands extraction and conversion jobs to remote worker nodes
+// and places their results back into the local pipeline directories
+type Coordinator struct {
+	logger       *utils.Logger
+	token        string
+	lease        time.Duration
+	pollInterval time.Duration
+	stagingDir   string
+	passwordFile string
+
+	// tlsConfig, allowedIPs and onReject are set by SetAccess
+	tlsConfig  *tls.Config
+	allowedIPs *utils.IPAllowlist
+	onReject   func(addr, method, reason string)
+
+	mutex     sync.Mutex
+	workers   map[string]*workerInfo
+	queue     []*jobState
+	jobs      map[string]*jobState
+	localOnly map[string]bool
+	server    *grpc.Server
+}
+
+type workerInfo struct {
+	ID       string
+	Hostname string
+	Kinds    []string
+	LastSeen time.Time
+}
+
+type jobState struct {
+	Job
+	SourcePath string // Staged input file
+	ReturnPath string // Where the input goes back to if remote processing fails
+	OutputDir  string // Pipeline directory receiving the results
+	WorkerID   string
+	LeaseUntil time.Time
+	Attempts   int
+}
+
+// NewCoordinator creates a coordinator that stages dispatched files under stagingDir
+func NewCoordinator(logger *utils.Logger, token string, lease time.Duration, stagingDir string) *Coordinator {
+	if lease <= 0 {
+		lease = 5 * time.Minute
+	}
+	return &Coordinator{
+		logger:       logger,
+		token:        token,
+		lease:        lease,
+		pollInterval: 5 * time.Second,
+		stagingDir:   stagingDir,
+		passwordFile: "./pass.txt",
+		workers:      make(map[string]*workerInfo),
+		jobs:         make(map[string]*jobState),
+		localOnly:    make(map[string]bool),
+	}
+}
+
+// SetAccess serves the job service over TLS (verifying worker certificates
+// when tlsConfig has ClientCAs), only to allowedIPs, and reports rejected
+// calls to onReject; call it before Start. Any argument may be nil
+func (c *Coordinator) SetAccess(tlsConfig *tls.Config, allowedIPs *utils.IPAllowlist, onReject func(addr, method, reason string)) {
+	c.tlsConfig = tlsConfig
+	c.allowedIPs = allowedIPs
+	c.onReject = onReject
+}
+
+// SetToken replaces the shared cluster token, e.g. after the secret was rotated
+func (c *Coordinator) SetToken(token string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.token = token
+}
+
+// Start serves the job service on addr until ctx is cancelled
+func (c *Coordinator) Start(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(c.authInterceptor)}
+	if c.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(c.tlsConfig)))
+	}
+	c.server = grpc.NewServer(opts...)
+	RegisterJobServer(c.server, c)
+
+	go c.reapExpiredLeases(ctx)
+	go func() {
+		<-ctx.Done()
+		c.server.GracefulStop()
+	}()
+
+	c.logger.WithField("addr", addr).Info("Cluster coordinator listening")
+	return c.server.Serve(listener)
+}
+
+// authInterceptor rejects calls from addresses outside the allowlist and
+// calls that don't carry the shared cluster token
+func (c *Coordinator) authInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var addr string
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+
+	if !c.allowedIPs.Allows(addr) {
+		c.reject(addr, info.FullMethod, "address not in CLUSTER_ALLOWED_IPS")
+		return nil, status.Error(codes.PermissionDenied, "address not allowed")
+	}
+	c.mutex.Lock()
+	token := c.token
+	c.mutex.Unlock()
+	// Without a configured token every call is rejected
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(tokenMetadataKey)
+	if token == "" || len(values) == 0 || subtle.ConstantTimeCompare([]byte(values[0]), []byte(token)) != 1 {
+		c.reject(addr, info.FullMethod, "invalid cluster token")
+		return nil, status.Error(codes.Unauthenticated, "invalid cluster token")
+	}
+	return handler(ctx, req)
+}
+
+// reject logs a rejected call and reports it to onReject
+func (c *Coordinator) reject(addr, method, reason string) {
+	c.logger.WithField("remote_addr", addr).WithField("method", method).Warn("Rejected cluster call: " + reason)
+	if c.onReject != nil {
+		c.onReject(addr, method, reason)
+	}
+}
+
+// HasWorkers reports whether any worker node has been seen within the lease period
+func (c *Coordinator) HasWorkers(kind string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, w := range c.workers {
+		if time.Since(w.LastSeen) < c.lease && containsKind(w.Kinds, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// PendingJobs returns the number of queued and in-flight jobs
+func (c *Coordinator) PendingJobs() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.jobs)
+}
+
+// Dispatch stages the files in dir as jobs of the given kind and returns how
+// many were handed to the cluster; results are placed in outputDir
+func (c *Coordinator) Dispatch(kind, dir, outputDir string) (int, error) {
+	if !c.HasWorkers(kind) {
+		return 0, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	var passwords []string
+	if kind == JobExtract {
+		passwords = readPasswords(c.passwordFile)
+	}
+
+	dispatched := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || utils.IsPartialFile(name) {
+			continue
+		}
+		if kind == JobExtract && !strings.HasSuffix(name, ".zip") && !strings.HasSuffix(name, ".rar") {
+			continue
+		}
+
+		sourcePath := filepath.Join(dir, name)
+
+		c.mutex.Lock()
+		skip := c.localOnly[sourcePath]
+		c.mutex.Unlock()
+		if skip {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		jobID := uuid.New().String()
+		stagedPath := filepath.Join(c.stagingDir, jobID, "in", name)
+		if err := utils.MoveFile(sourcePath, stagedPath, utils.DefaultMoveOptions()); err != nil {
+			c.logger.WithError(err).WithField("file", sourcePath).Warn("Failed to stage file for cluster job")
+			continue
+		}
+
+		job := &jobState{
+			Job: Job{
+				ID:        jobID,
+				Kind:      kind,
+				FileName:  name,
+				FileSize:  info.Size(),
+				Passwords: passwords,
+			},
+			SourcePath: stagedPath,
+			ReturnPath: sourcePath,
+			OutputDir:  outputDir,
+		}
+
+		c.mutex.Lock()
+		c.jobs[jobID] = job
+		c.queue = append(c.queue, job)
+		c.mutex.Unlock()
+
+		c.logger.WithField("job_id", jobID).
+			WithField("kind", kind).
+			WithField("file", name).
+			Info("Dispatched file to cluster")
+		dispatched++
+	}
+
+	return dispatched, nil
+}
+
+// Register records a worker node
+func (c *Coordinator) Register(ctx context.Context, req *RegisterRequest) (*RegisterResponse, error) {
+	if req.WorkerID == "" {
+		return nil, status.Error(codes.InvalidArgument, "worker_id is required")
+	}
+
+	c.mutex.Lock()
+	c.workers[req.WorkerID] = &workerInfo{
+		ID:       req.WorkerID,
+		Hostname: req.Hostname,
+		Kinds:    req.Kinds,
+		LastSeen: time.Now(),
+	}
+	c.mutex.Unlock()
+
+	c.logger.WithField("worker_id", req.WorkerID).
+		WithField("hostname", req.Hostname).
+		WithField("kinds", req.Kinds).
+		Info("Worker node registered")
+
+	return &RegisterResponse{
+		PollIntervalMs: c.pollInterval.Milliseconds(),
+		LeaseMs:        c.lease.Milliseconds(),
+	}, nil
+}
+
+// PullJob leases the next queued job matching the worker's kinds
+func (c *Coordinator) PullJob(ctx context.Context, req *PullJobRequest) (*Job, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.touchLocked(req.WorkerID); err != nil {
+		return nil, err
+	}
+
+	for i, job := range c.queue {
+		if !containsKind(req.Kinds, job.Kind) {
+			continue
+		}
+		c.queue = append(c.queue[:i], c.queue[i+1:]...)
+		job.WorkerID = req.WorkerID
+		job.LeaseUntil = time.Now().Add(c.lease)
+		job.Attempts++
+
+		c.logger.WithField("job_id", job.ID).
+			WithField("worker_id", req.WorkerID).
+			WithField("attempt", job.Attempts).
+			Info("Cluster job leased")
+
+		leased := job.Job
+		return &leased, nil
+	}
+
+	return &Job{}, nil
+}
+
+// ReadChunk serves part of a leased job's input file
+func (c *Coordinator) ReadChunk(ctx context.Context, req *ReadChunkRequest) (*Chunk, error) {
+	job, err := c.leasedJob(req.WorkerID, req.JobID)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(job.SourcePath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to open job input: %v", err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, ChunkSize)
+	n, err := f.ReadAt(buf, req.Offset)
+	if err != nil && err != io.EOF {
+		return nil, status.Errorf(codes.Internal, "failed to read job input: %v", err)
+	}
+
+	return &Chunk{Data: buf[:n], EOF: err == io.EOF || req.Offset+int64(n) >= job.FileSize}, nil
+}
+
+// WriteChunk stores part of a result file uploaded by a worker
+func (c *Coordinator) WriteChunk(ctx context.Context, req *WriteChunkRequest) (*Empty, error) {
+	if _, err := c.leasedJob(req.WorkerID, req.JobID); err != nil {
+		return nil, err
+	}
+
+	outPath, err := c.outputPath(req.JobID, req.FileName)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create output directory: %v", err)
+	}
+
+	f, err := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to open output file: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteAt(req.Data, req.Offset); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to write output file: %v", err)
+	}
+
+	return &Empty{}, nil
+}
+
+// CompleteJob publishes a job's results or returns its input to local processing
+func (c *Coordinator) CompleteJob(ctx context.Context, req *CompleteJobRequest) (*Empty, error) {
+	job, err := c.leasedJob(req.WorkerID, req.JobID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	delete(c.jobs, job.ID)
+	c.mutex.Unlock()
+
+	if !req.Success {
+		c.logger.WithField("job_id", job.ID).
+			WithField("worker_id", req.WorkerID).
+			WithField("error", req.Error).
+			Warn("Cluster job failed, returning file to local processing")
+		c.returnToLocal(job)
+		return &Empty{}, nil
+	}
+
+	for _, name := range req.Outputs {
+		outPath, err := c.outputPath(job.ID, name)
+		if err != nil {
+			return nil, err
+		}
+		// Prefix with the job ID so results from different workers never collide
+		finalPath := filepath.Join(job.OutputDir, fmt.Sprintf("%s_%s", job.ID[:8], filepath.Base(name)))
+		if err := utils.MoveFile(outPath, finalPath, utils.DefaultMoveOptions()); err != nil {
+			c.logger.WithError(err).WithField("job_id", job.ID).Error("Failed to publish cluster job output")
+			c.returnToLocal(job)
+			return nil, status.Errorf(codes.Internal, "failed to publish output: %v", err)
+		}
+	}
+
+	os.RemoveAll(filepath.Join(c.stagingDir, job.ID))
+
+	c.logger.WithField("job_id", job.ID).
+		WithField("worker_id", req.WorkerID).
+		WithField("outputs", len(req.Outputs)).
+		Info("Cluster job completed")
+
+	return &Empty{}, nil
+}
+
+// Heartbeat extends the worker's liveness and job lease
+func (c *Coordinator) Heartbeat(ctx context.Context, req *HeartbeatRequest) (*Empty, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.touchLocked(req.WorkerID); err != nil {
+		return nil, err
+	}
+	if job, ok := c.jobs[req.JobID]; ok && job.WorkerID == req.WorkerID {
+		job.LeaseUntil = time.Now().Add(c.lease)
+	}
+
+	return &Empty{}, nil
+}
+
+// touchLocked updates a worker's last-seen time; callers must hold the mutex
+func (c *Coordinator) touchLocked(workerID string) error {
+	w, ok := c.workers[workerID]
+	if !ok {
+		return status.Error(codes.FailedPrecondition, "worker not registered")
+	}
+	w.LastSeen = time.Now()
+	return nil
+}
+
+// leasedJob returns the job if it is currently leased to workerID
+func (c *Coordinator) leasedJob(workerID, jobID string) (*jobState, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	job, ok := c.jobs[jobID]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "unknown job")
+	}
+	if job.WorkerID != workerID {
+		return nil, status.Error(codes.PermissionDenied, "job is not leased to this worker")
+	}
+	return job, nil
+}
+
+// outputPath resolves an uploaded result file inside the job's staging directory
+func (c *Coordinator) outputPath(jobID, name string) (string, error) {
+	base := filepath.Base(name)
+	if base == "." || base == ".." || base == string(filepath.Separator) {
+		return "", status.Error(codes.InvalidArgument, "invalid output file name")
+	}
+	return filepath.Join(c.stagingDir, jobID, "out", base), nil
+}
+
+// reapExpiredLeases requeues jobs whose worker stopped heartbeating
+func (c *Coordinator) reapExpiredLeases(ctx context.Context) {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var expired []*jobState
+		c.mutex.Lock()
+		for _, job := range c.jobs {
+			if job.WorkerID == "" || time.Now().Before(job.LeaseUntil) {
+				continue
+			}
+			c.logger.WithField("job_id", job.ID).
+				WithField("worker_id", job.WorkerID).
+				Warn("Cluster job lease expired")
+			job.WorkerID = ""
+			if job.Attempts >= maxJobAttempts {
+				delete(c.jobs, job.ID)
+				expired = append(expired, job)
+				continue
+			}
+			c.queue = append(c.queue, job)
+		}
+		c.mutex.Unlock()
+
+		for _, job := range expired {
+			c.returnToLocal(job)
+		}
+	}
+}
+
+// returnToLocal moves a job's input back into its pipeline directory and
+// excludes it from further dispatch so the local stage processes it
+func (c *Coordinator) returnToLocal(job *jobState) {
+	if err := utils.MoveFile(job.SourcePath, job.ReturnPath, utils.DefaultMoveOptions()); err != nil {
+		c.logger.WithError(err).
+			WithField("job_id", job.ID).
+			WithField("staged_path", job.SourcePath).
+			Error("Failed to return cluster job input to local pipeline")
+		return
+	}
+
+	c.mutex.Lock()
+	c.localOnly[job.ReturnPath] = true
+	c.mutex.Unlock()
+
+	os.RemoveAll(filepath.Join(c.stagingDir, job.ID))
+}
+
+// Requeue returns all staged inputs to their pipeline directories, used at startup
+// to recover jobs that were in flight when the coordinator stopped
+func (c *Coordinator) Requeue(returnDirs map[string]string) {
+	entries, err := os.ReadDir(c.stagingDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		inputs, _ := filepath.Glob(filepath.Join(c.stagingDir, entry.Name(), "in", "*"))
+		for _, input := range inputs {
+			name := filepath.Base(input)
+			dir := returnDirs[JobConvert]
+			if strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".rar") {
+				dir = returnDirs[JobExtract]
+			}
+			if err := utils.MoveFile(input, filepath.Join(dir, name), utils.DefaultMoveOptions()); err != nil {
+				c.logger.WithError(err).WithField("file", input).Warn("Failed to recover staged cluster input")
+			}
+		}
+		os.RemoveAll(filepath.Join(c.stagingDir, entry.Name()))
+	}
+}
+
+func containsKind(kinds []string, kind string) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// readPasswords loads the archive password list shipped with extract jobs
+func readPasswords(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var passwords []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			passwords = append(passwords, line)
+		}
+	}
+	return passwords
+}
@@ -0,0 +1,193 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// Job kinds handed out to worker nodes
+const (
+	JobExtract = "extract"
+	JobConvert = "convert"
+)
+
+// Chunk size used for file transfers; stays well below gRPC's 4MB message limit
+const ChunkSize = 1024 * 1024
+
+// Metadata key carrying the shared cluster token
+const tokenMetadataKey = "x-cluster-token"
+
+const serviceName = "cluster.JobService"
+
+// jsonCodec encodes messages as JSON so the service needs no generated protobuf code
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return "json" }
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// RegisterRequest announces a worker node to the coordinator
+type RegisterRequest struct {
+	WorkerID string   `json:"worker_id"`
+	Hostname string   `json:"hostname"`
+	Kinds    []string `json:"kinds"`
+	Slots    int      `json:"slots"`
+}
+
+// RegisterResponse tells the worker how often to poll and heartbeat
+type RegisterResponse struct {
+	PollIntervalMs int64 `json:"poll_interval_ms"`
+	LeaseMs        int64 `json:"lease_ms"`
+}
+
+// PullJobRequest asks the coordinator for the next job of the given kinds
+type PullJobRequest struct {
+	WorkerID string   `json:"worker_id"`
+	Kinds    []string `json:"kinds"`
+}
+
+// Job describes a unit of extraction or conversion work; an empty ID means no work
+type Job struct {
+	ID        string   `json:"id"`
+	Kind      string   `json:"kind"`
+	FileName  string   `json:"file_name"`
+	FileSize  int64    `json:"file_size"`
+	Passwords []string `json:"passwords,omitempty"`
+}
+
+// ReadChunkRequest fetches part of a job's input file
+type ReadChunkRequest struct {
+	WorkerID string `json:"worker_id"`
+	JobID    string `json:"job_id"`
+	Offset   int64  `json:"offset"`
+}
+
+// Chunk carries file data; EOF is set on the last chunk
+type Chunk struct {
+	Data []byte `json:"data"`
+	EOF  bool   `json:"eof"`
+}
+
+// WriteChunkRequest uploads part of a result file produced by a job
+type WriteChunkRequest struct {
+	WorkerID string `json:"worker_id"`
+	JobID    string `json:"job_id"`
+	FileName string `json:"file_name"`
+	Offset   int64  `json:"offset"`
+	Data     []byte `json:"data"`
+}
+
+// CompleteJobRequest reports the outcome of a job; Outputs lists the uploaded result files
+type CompleteJobRequest struct {
+	WorkerID string   `json:"worker_id"`
+	JobID    string   `json:"job_id"`
+	Success  bool     `json:"success"`
+	Error    string   `json:"error,omitempty"`
+	Outputs  []string `json:"outputs,omitempty"`
+}
+
+// HeartbeatRequest extends the lease on the worker's in-flight job
+type HeartbeatRequest struct {
+	WorkerID string `json:"worker_id"`
+	JobID    string `json:"job_id,omitempty"`
+}
+
+// Empty is returned by calls with no response payload
+type Empty struct{}
+
+// JobServer is implemented by the coordinator
+type JobServer interface {
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	PullJob(context.Context, *PullJobRequest) (*Job, error)
+	ReadChunk(context.Context, *ReadChunkRequest) (*Chunk, error)
+	WriteChunk(context.Context, *WriteChunkRequest) (*Empty, error)
+	CompleteJob(context.Context, *CompleteJobRequest) (*Empty, error)
+	Heartbeat(context.Context, *HeartbeatRequest) (*Empty, error)
+}
+
+// unaryHandler builds a grpc method handler for a JobServer method
+func unaryHandler[Req any, Resp any](method string, call func(JobServer, context.Context, *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := new(Req)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(JobServer), ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + method}
+			return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(JobServer), ctx, req.(*Req))
+			})
+		},
+	}
+}
+
+var jobServiceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*JobServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryHandler("Register", JobServer.Register),
+		unaryHandler("PullJob", JobServer.PullJob),
+		unaryHandler("ReadChunk", JobServer.ReadChunk),
+		unaryHandler("WriteChunk", JobServer.WriteChunk),
+		unaryHandler("CompleteJob", JobServer.CompleteJob),
+		unaryHandler("Heartbeat", JobServer.Heartbeat),
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterJobServer registers the job service on a gRPC server
+func RegisterJobServer(s *grpc.Server, srv JobServer) {
+	s.RegisterService(&jobServiceDesc, srv)
+}
+
+// JobClient is the worker-side client for the job service
+type JobClient struct {
+	conn *grpc.ClientConn
+}
+
+// NewJobClient wraps an established gRPC connection
+func NewJobClient(conn *grpc.ClientConn) *JobClient {
+	return &JobClient{conn: conn}
+}
+
+func (c *JobClient) invoke(ctx context.Context, method string, req, resp interface{}) error {
+	return c.conn.Invoke(ctx, "/"+serviceName+"/"+method, req, resp, grpc.CallContentSubtype("json"))
+}
+
+func (c *JobClient) Register(ctx context.Context, req *RegisterRequest) (*RegisterResponse, error) {
+	resp := new(RegisterResponse)
+	return resp, c.invoke(ctx, "Register", req, resp)
+}
+
+func (c *JobClient) PullJob(ctx context.Context, req *PullJobRequest) (*Job, error) {
+	resp := new(Job)
+	return resp, c.invoke(ctx, "PullJob", req, resp)
+}
+
+func (c *JobClient) ReadChunk(ctx context.Context, req *ReadChunkRequest) (*Chunk, error) {
+	resp := new(Chunk)
+	return resp, c.invoke(ctx, "ReadChunk", req, resp)
+}
+
+func (c *JobClient) WriteChunk(ctx context.Context, req *WriteChunkRequest) error {
+	return c.invoke(ctx, "WriteChunk", req, new(Empty))
+}
+
+func (c *JobClient) CompleteJob(ctx context.Context, req *CompleteJobRequest) error {
+	return c.invoke(ctx, "CompleteJob", req, new(Empty))
+}
+
+func (c *JobClient) Heartbeat(ctx context.Context, req *HeartbeatRequest) error {
+	return c.invoke(ctx, "Heartbeat", req, new(Empty))
+}
@@ -4,23 +4,30 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
+	"telegram-archive-bot/app/extraction/compression"
 	"telegram-archive-bot/storage"
 	"telegram-archive-bot/utils"
 )
 
 var (
-	action         = flag.String("action", "", "Action to perform: backup, restore, list, cleanup, stats")
+	action         = flag.String("action", "", "Action to perform: backup, restore, verify, list, cleanup, stats")
 	configFile     = flag.String("config", ".env", "Path to config file")
-	backupFile     = flag.String("file", "", "Backup file path (for restore)")
-	backupDir      = flag.String("dir", "backups", "Backup directory")
+	backupFile     = flag.String("file", "", "Backup file path (for restore and verify)")
+	backupDir      = flag.String("dir", "", "Backup directory (default: BACKUP_DIR)")
 	retentionDays  = flag.Int("retention", 30, "Backup retention in days")
 	compress       = flag.Bool("compress", true, "Compress backups")
+	codecName      = flag.String("codec", "", "Compression codec: gzip, zstd or none (default: BACKUP_COMPRESSION)")
+	level          = flag.Int("level", -1, "Compression level, 0 for the codec default (default: BACKUP_COMPRESSION_LEVEL)")
 	verify         = flag.Bool("verify", true, "Verify backup/restore operations")
 	createBackup   = flag.Bool("backup-current", true, "Create backup of current DB before restore")
 	force          = flag.Bool("force", false, "Force operation without confirmation")
+	verbose        = flag.Bool("verbose", false, "Show the full catalog entry of each backup (for list)")
+	showProgress   = flag.Bool("progress", true, "Show a progress bar for backup, verify and restore")
+	forceMigrate   = flag.Bool("force-migrate", false, "Restore backups of an unknown or newer schema version")
 )
 
 func main() {
@@ -46,11 +53,33 @@ func main() {
 	}
 	defer db.Close()
 
+	if *backupDir == "" {
+		*backupDir = config.BackupDir
+	}
+
+	// Flags override the configured codec and level
+	codec, codecLevel := config.BackupCompression, config.BackupCompressionLevel
+	if *codecName != "" {
+		if codec, err = compression.Parse(*codecName); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		codecLevel = 0
+	}
+	if *level >= 0 {
+		codecLevel = *level
+	}
+	if !*compress {
+		codec = compression.None
+		codecLevel = 0
+	}
+
 	// Initialize backup service
 	backupService, err := storage.NewBackupService(db, storage.BackupOptions{
 		BackupDir:       *backupDir,
 		RetentionPeriod: time.Duration(*retentionDays) * 24 * time.Hour,
-		Compress:        *compress,
+		Codec:           codec,
+		Level:           codecLevel,
 		VerifyBackup:    *verify,
 	})
 	if err != nil {
@@ -61,9 +90,11 @@ func main() {
 	// Execute requested action
 	switch *action {
 	case "backup":
-		executeBackup(backupService)
+		executeBackup(backupService, codec, codecLevel)
 	case "restore":
 		executeRestore(backupService)
+	case "verify":
+		verifyBackup(backupService)
 	case "list":
 		listBackups(backupService)
 	case "cleanup":
@@ -77,16 +108,19 @@ func main() {
 	}
 }
 
-func executeBackup(bs *storage.BackupService) {
+func executeBackup(bs *storage.BackupService, codec compression.Codec, level int) {
 	fmt.Println("Creating database backup...")
 	
 	opts := storage.BackupOptions{
 		BackupDir:    *backupDir,
-		Compress:     *compress,
+		Codec:        codec,
+		Level:        level,
 		VerifyBackup: *verify,
+		Progress:     progressFunc(),
 	}
 	
 	backupPath, err := bs.CreateBackup(opts)
+	endProgress()
 	if err != nil {
 		fmt.Printf("Error creating backup: %v\n", err)
 		os.Exit(1)
@@ -102,8 +136,48 @@ func executeBackup(bs *storage.BackupService) {
 	fmt.Printf("✅ Backup created successfully!\n")
 	fmt.Printf("   File: %s\n", backupPath)
 	fmt.Printf("   Size: %s\n", formatBytes(info.Size()))
-	fmt.Printf("   Compressed: %t\n", *compress)
-	fmt.Printf("   Verified: %t\n", *verify)
+	fmt.Printf("   Compression: %s\n", codec)
+	meta, err := storage.ReadBackupMetadata(backupPath)
+	if err != nil || meta == nil {
+		fmt.Printf("   Catalog: unavailable (%v)\n", err)
+		return
+	}
+	fmt.Printf("   Schema version: %d\n", meta.SchemaVersion)
+	fmt.Printf("   Rows: %d in %d tables\n", meta.TotalRows(), len(meta.RowCounts))
+	fmt.Printf("   SHA-256: %s\n", meta.SHA256)
+	fmt.Printf("   Duration: %.1fs\n", meta.Duration)
+	if meta.Verification != nil {
+		fmt.Printf("   Verified: %d tables, %d rows, integrity %s\n",
+			meta.Verification.Tables, meta.Verification.Rows, meta.Verification.Integrity)
+	} else {
+		fmt.Printf("   Verified: false\n")
+	}
+}
+
+func verifyBackup(bs *storage.BackupService) {
+	if *backupFile == "" {
+		fmt.Println("Error: backup file must be specified with -file flag")
+		os.Exit(1)
+	}
+	
+	fmt.Println("Verifying backup in a scratch database...")
+	
+	verification, err := bs.VerifyBackup(*backupFile, progressFunc())
+	endProgress()
+	if err != nil {
+		fmt.Printf("Error verifying backup: %v\n", err)
+		os.Exit(1)
+	}
+	
+	if !verification.OK {
+		fmt.Printf("❌ Backup verification failed: %s\n", verification.Error)
+		os.Exit(1)
+	}
+	
+	fmt.Printf("✅ Backup verified successfully!\n")
+	fmt.Printf("   Tables: %d\n", verification.Tables)
+	fmt.Printf("   Rows: %d\n", verification.Rows)
+	fmt.Printf("   Integrity: %s\n", verification.Integrity)
 }
 
 func executeRestore(bs *storage.BackupService) {
@@ -118,6 +192,25 @@ func executeRestore(bs *storage.BackupService) {
 		os.Exit(1)
 	}
 	
+	// Refuse incompatible backups before asking for confirmation
+	meta, err := storage.ReadBackupMetadata(*backupFile)
+	if err != nil {
+		fmt.Printf("Error reading backup catalog: %v\n", err)
+		os.Exit(1)
+	}
+	if meta != nil {
+		fmt.Printf("Backup schema version %d (bot %s), this binary expects %d (bot %s)\n",
+			meta.SchemaVersion, meta.AppVersion, storage.LatestSchemaVersion(), utils.BotVersion())
+	}
+	if err := storage.CheckSchemaCompatibility(meta); err != nil {
+		if !*forceMigrate {
+			fmt.Printf("Error: %v\n", err)
+			fmt.Println("Run with -force-migrate to restore it anyway.")
+			os.Exit(1)
+		}
+		fmt.Printf("⚠️  %v, restoring anyway (-force-migrate)\n", err)
+	}
+	
 	// Confirm restore operation
 	if !*force {
 		fmt.Printf("⚠️  This will restore the database from: %s\n", *backupFile)
@@ -140,9 +233,12 @@ func executeRestore(bs *storage.BackupService) {
 		BackupFile:    *backupFile,
 		VerifyRestore: *verify,
 		CreateBackup:  *createBackup,
+		Progress:      progressFunc(),
+		ForceMigrate:  *forceMigrate,
 	}
 	
-	err := bs.RestoreFromBackup(opts)
+	err = bs.RestoreFromBackup(opts)
+	endProgress()
 	if err != nil {
 		fmt.Printf("Error restoring backup: %v\n", err)
 		os.Exit(1)
@@ -167,24 +263,70 @@ func listBackups(bs *storage.BackupService) {
 	}
 	
 	fmt.Printf("Found %d backup(s) in %s:\n\n", len(backups), *backupDir)
-	fmt.Printf("%-30s %-12s %-20s %s\n", "NAME", "SIZE", "CREATED", "COMPRESSED")
-	fmt.Printf("%s\n", strings.Repeat("-", 80))
+	fmt.Printf("%-34s %-10s %-20s %-8s %-7s %-12s %-16s %s\n", "NAME", "SIZE", "CREATED", "CODEC", "SCHEMA", "ROWS", "HOST", "VERIFIED")
+	fmt.Printf("%s\n", strings.Repeat("-", 120))
 	
 	for _, backup := range backups {
 		compressed := "No"
 		if backup.Compressed {
-			compressed = "Yes"
+			compressed = string(backup.Codec)
 		}
 		
-		fmt.Printf("%-30s %-12s %-20s %s\n",
+		// Backups taken before the catalog existed only have file details
+		schema, rows, host, verified := "-", "-", "-", "-"
+		if meta := backup.Metadata; meta != nil {
+			if meta.SchemaVersion > 0 {
+				schema = fmt.Sprintf("%d", meta.SchemaVersion)
+			}
+			if meta.RowCounts != nil {
+				rows = fmt.Sprintf("%d", meta.TotalRows())
+			}
+			if meta.Host != "" {
+				host = meta.Host
+			}
+			if meta.Verification != nil {
+				verified = "OK"
+				if !meta.Verification.OK {
+					verified = "FAILED"
+				}
+			}
+		}
+		
+		fmt.Printf("%-34s %-10s %-20s %-8s %-7s %-12s %-16s %s\n",
 			backup.Name,
 			formatBytes(backup.Size),
 			backup.Created.Format("2006-01-02 15:04:05"),
 			compressed,
+			schema,
+			rows,
+			host,
+			verified,
 		)
+		
+		if *verbose && backup.Metadata != nil {
+			printCatalogEntry(backup.Metadata)
+		}
 	}
 }
 
+// printCatalogEntry prints the catalog details of one backup
+func printCatalogEntry(meta *storage.BackupMetadata) {
+	fmt.Printf("    sha256: %s\n", meta.SHA256)
+	fmt.Printf("    duration: %.1fs, level: %d, encrypted: %t\n", meta.Duration, meta.Level, meta.Encrypted)
+	tables := make([]string, 0, len(meta.RowCounts))
+	for table := range meta.RowCounts {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		fmt.Printf("    %-40s %d rows\n", table, meta.RowCounts[table])
+	}
+	if v := meta.Verification; v != nil && !v.OK {
+		fmt.Printf("    verification failed: %s\n", v.Error)
+	}
+	fmt.Println()
+}
+
 func cleanupBackups(bs *storage.BackupService) {
 	if !*force {
 		fmt.Printf("⚠️  This will remove backup files older than %d days.\n", *retentionDays)
@@ -244,6 +386,41 @@ func showStats(bs *storage.BackupService) {
 	}
 }
 
+// progressShown is set once a progress line has been drawn
+var progressShown bool
+
+// progressFunc redraws a progress line in place, or returns nil when -progress=false
+func progressFunc() storage.ProgressFunc {
+	if !*showProgress {
+		return nil
+	}
+	return func(p storage.BackupProgress) {
+		line := fmt.Sprintf("%-7s %s %5.1f%%  %d/%d tables", p.Phase, p.Bar(30), p.Percent(), p.TablesDone, p.TablesTotal)
+		if p.RowsTotal > 0 {
+			line += fmt.Sprintf("  %d/%d rows", p.RowsDone, p.RowsTotal)
+		}
+		if p.BytesTotal > 0 {
+			line += fmt.Sprintf("  %s/%s", formatBytes(p.Bytes), formatBytes(p.BytesTotal))
+		} else {
+			line += fmt.Sprintf("  %s written", formatBytes(p.Bytes))
+		}
+		fmt.Printf("\r%-110s", line)
+		progressShown = true
+		if p.Done {
+			fmt.Println()
+			progressShown = false
+		}
+	}
+}
+
+// endProgress ends a progress line an error interrupted
+func endProgress() {
+	if progressShown {
+		fmt.Println()
+		progressShown = false
+	}
+}
+
 func formatBytes(bytes int64) string {
 	const unit = 1024
 	if bytes < unit {
@@ -266,6 +443,7 @@ func printUsage() {
 	fmt.Println("Actions:")
 	fmt.Println("  backup    Create a new database backup")
 	fmt.Println("  restore   Restore database from backup file")
+	fmt.Println("  verify    Restore a backup into a scratch database and check it")
 	fmt.Println("  list      List available backup files")
 	fmt.Println("  cleanup   Remove old backup files")
 	fmt.Println("  stats     Show backup statistics")
@@ -280,9 +458,21 @@ func printUsage() {
 	fmt.Println("  # List all backups")
 	fmt.Printf("  %s -action=list\n", os.Args[0])
 	fmt.Println()
-	fmt.Println("  # Restore from specific backup")
+	fmt.Println("  # List backups with hashes and per-table row counts")
+	fmt.Printf("  %s -action=list -verbose\n", os.Args[0])
+	fmt.Println()
+	fmt.Println("  # Create a zstd backup at level 9")
+	fmt.Printf("  %s -action=backup -codec=zstd -level=9\n", os.Args[0])
+	fmt.Println()
+	fmt.Println("  # Restore from specific backup (gzip and zstd are detected automatically)")
 	fmt.Printf("  %s -action=restore -file=backups/bot_backup_20240125_120000.sql.gz\n", os.Args[0])
 	fmt.Println()
+	fmt.Println("  # Restore a backup taken by a newer bot version")
+	fmt.Printf("  %s -action=restore -file=backups/bot_backup_20240125_120000.sql.gz -force-migrate\n", os.Args[0])
+	fmt.Println()
+	fmt.Println("  # Re-verify an existing backup")
+	fmt.Printf("  %s -action=verify -file=backups/bot_backup_20240125_120000.sql.gz\n", os.Args[0])
+	fmt.Println()
 	fmt.Println("  # Cleanup old backups")
 	fmt.Printf("  %s -action=cleanup -retention=7\n", os.Args[0])
 	fmt.Println()
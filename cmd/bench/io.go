@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram-archive-bot/utils"
+)
+
+// ioResult holds the measurements of one hashing strategy
+type ioResult struct {
+	Name        string
+	Duration    time.Duration
+	Bytes       int64
+	CacheGrowth int64 // Growth of the kernel page cache while hashing
+	PeakCache   int64
+}
+
+// runIOBenchmark compares plain io.Copy hashing with the tuned I/O layer under
+// concurrency and returns the exit code
+func runIOBenchmark() int {
+	dir := *workDir
+	var err error
+	if dir == "" {
+		dir, err = os.MkdirTemp("", "telegram-bot-iobench-")
+		if err != nil {
+			fmt.Printf("Error creating working directory: %v\n", err)
+			return 1
+		}
+	}
+	if !*keep {
+		defer os.RemoveAll(dir)
+	}
+
+	fmt.Printf("💾 Large file I/O benchmark\n")
+	fmt.Printf("   Directory: %s\n", dir)
+	fmt.Printf("   Files: %d × %d MB\n\n", *ioConcurrency, *ioSizeMB)
+
+	strategies := []struct {
+		name string
+		hash func(path string) (int64, error)
+	}{
+		{"default", hashDefault},
+		{"tuned", func(path string) (int64, error) {
+			_, n, err := utils.HashFileTuned(path, utils.DefaultIOTuning())
+			return n, err
+		}},
+	}
+
+	var results []ioResult
+	for _, strategy := range strategies {
+		// Fresh, uncached files for every strategy so results are comparable
+		paths, err := generateIOFiles(filepath.Join(dir, strategy.name))
+		if err != nil {
+			fmt.Printf("Error generating files: %v\n", err)
+			return 1
+		}
+
+		fmt.Printf("⏱  Hashing with %s I/O...\n", strategy.name)
+		result, err := measureIO(strategy.name, paths, strategy.hash)
+		if err != nil {
+			fmt.Printf("Error running %s strategy: %v\n", strategy.name, err)
+			return 1
+		}
+		results = append(results, result)
+
+		for _, p := range paths {
+			os.Remove(p)
+		}
+	}
+
+	fmt.Printf("\n📊 I/O Benchmark Results\n")
+	fmt.Printf("%-10s %10s %12s %10s %14s %14s\n", "Strategy", "Duration", "Data", "MB/s", "Cache growth", "Peak cache")
+	fmt.Println(strings.Repeat("-", 76))
+	for _, r := range results {
+		mbps := float64(r.Bytes) / (1024 * 1024) / r.Duration.Seconds()
+		fmt.Printf("%-10s %10s %12s %10.2f %14s %14s\n",
+			r.Name,
+			r.Duration.Round(time.Millisecond),
+			formatBytes(r.Bytes),
+			mbps,
+			formatBytes(r.CacheGrowth),
+			formatBytes(r.PeakCache))
+	}
+	return 0
+}
+
+// hashDefault mirrors the original hashing approach (32KB io.Copy, no hints)
+func hashDefault(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return io.Copy(sha256.New(), f)
+}
+
+// generateIOFiles writes random files and evicts them from the page cache
+func generateIOFiles(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	chunk := make([]byte, 1024*1024)
+	var paths []string
+	for i := 0; i < *ioConcurrency; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("io_%d.bin", i))
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		w := bufio.NewWriterSize(f, len(chunk))
+		for mb := 0; mb < *ioSizeMB; mb++ {
+			rand.Read(chunk)
+			if _, err := w.Write(chunk); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+		if err := w.Flush(); err != nil {
+			f.Close()
+			return nil, err
+		}
+		f.Close()
+
+		if err := utils.DropFileCache(path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// measureIO hashes all files concurrently while sampling the page cache size
+func measureIO(name string, paths []string, hash func(string) (int64, error)) (ioResult, error) {
+	result := ioResult{Name: name}
+	baseline := pageCacheBytes()
+
+	stop := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			if growth := pageCacheBytes() - baseline; growth > result.PeakCache {
+				result.PeakCache = growth
+			}
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	start := time.Now()
+	for _, path := range paths {
+		wg.Add(1)
+		go func(p string) {
+			defer wg.Done()
+			n, err := hash(p)
+			mu.Lock()
+			defer mu.Unlock()
+			result.Bytes += n
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}(path)
+	}
+	wg.Wait()
+	result.Duration = time.Since(start)
+
+	close(stop)
+	<-sampled
+	result.CacheGrowth = pageCacheBytes() - baseline
+
+	return result, firstErr
+}
+
+// pageCacheBytes reads the "Cached" value from /proc/meminfo (0 when unavailable)
+func pageCacheBytes() int64 {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "Cached:") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 {
+				kb, _ := strconv.ParseInt(fields[1], 10, 64)
+				return kb * 1024
+			}
+		}
+	}
+	return 0
+}
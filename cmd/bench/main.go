@@ -0,0 +1,466 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"telegram-archive-bot/app/extraction/convert"
+	"telegram-archive-bot/app/extraction/extract"
+)
+
+var (
+	workDir         = flag.String("dir", "", "Working directory for the benchmark (default: temporary directory)")
+	archiveCount    = flag.Int("archives", 5, "Number of synthetic ZIP archives to generate")
+	txtCount        = flag.Int("txt", 0, "Number of plain TXT submissions to generate")
+	logsPerArchive  = flag.Int("logs", 10, "Number of log folders (Passwords.txt files) per archive")
+	linesPerLog     = flag.Int("lines", 1000, "Number of credential records per Passwords.txt")
+	fillerKB        = flag.Int("filler", 64, "Size in KB of non-credential filler files per log folder")
+	downloadWorkers = flag.Int("download-workers", 3, "Concurrent workers for the simulated download stage")
+	runs            = flag.Int("runs", 1, "Number of benchmark runs to average")
+	passwordFile    = flag.String("passwords", "", "Password list copied to the working directory (optional)")
+	keep            = flag.Bool("keep", false, "Keep the working directory after the benchmark")
+	ioBench         = flag.Bool("io", false, "Run the large file I/O benchmark (default vs tuned hashing) instead of the pipeline")
+	ioSizeMB        = flag.Int("io-size", 512, "Size in MB of each file used by the I/O benchmark")
+	ioConcurrency   = flag.Int("io-concurrency", 3, "Concurrent hashing operations in the I/O benchmark (simulates parallel downloads)")
+)
+
+// StageResult holds the measurements for a single pipeline stage
+type StageResult struct {
+	Name          string
+	Duration      time.Duration
+	Files         int
+	Bytes         int64
+	UserCPU       time.Duration
+	SystemCPU     time.Duration
+	PeakHeap      uint64
+	PeakGoroutine int
+}
+
+func main() {
+	flag.Parse()
+	os.Exit(run())
+}
+
+// run runs the selected benchmark and returns the exit code; deferred cleanup
+// of the working directory has run by the time main exits
+func run() int {
+	if *ioBench {
+		return runIOBenchmark()
+	}
+
+	if *archiveCount <= 0 && *txtCount <= 0 {
+		printUsage()
+		return 1
+	}
+	if *fillerKB < 0 {
+		fmt.Printf("Invalid -filler %d: size can't be negative\n", *fillerKB)
+		return 1
+	}
+	if *runs <= 0 {
+		fmt.Printf("Invalid -runs %d: at least one run is needed\n", *runs)
+		return 1
+	}
+
+	originalDir, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Error getting working directory: %v\n", err)
+		return 1
+	}
+
+	dir := *workDir
+	if dir == "" {
+		dir, err = os.MkdirTemp("", "telegram-bot-bench-")
+		if err != nil {
+			fmt.Printf("Error creating working directory: %v\n", err)
+			return 1
+		}
+	}
+	dir, _ = filepath.Abs(dir)
+	if !*keep {
+		defer os.RemoveAll(dir)
+	}
+
+	if *passwordFile != "" {
+		if err := copyFile(*passwordFile, filepath.Join(dir, "pass.txt")); err != nil {
+			fmt.Printf("Error copying password file: %v\n", err)
+			return 1
+		}
+	}
+
+	// The extraction and conversion stages use paths relative to the working directory
+	if err := os.Chdir(dir); err != nil {
+		fmt.Printf("Error changing to working directory: %v\n", err)
+		return 1
+	}
+	defer os.Chdir(originalDir)
+
+	fmt.Printf("🏁 Pipeline benchmark\n")
+	fmt.Printf("   Directory: %s\n", dir)
+	fmt.Printf("   Archives: %d (%d logs × %d records, %d KB filler)\n", *archiveCount, *logsPerArchive, *linesPerLog, *fillerKB)
+	fmt.Printf("   TXT files: %d\n", *txtCount)
+	fmt.Printf("   Download workers: %d\n", *downloadWorkers)
+	fmt.Printf("   Runs: %d\n\n", *runs)
+
+	var allResults [][]StageResult
+	for run := 1; run <= *runs; run++ {
+		results, err := runBenchmark(run)
+		if err != nil {
+			fmt.Printf("Error in run %d: %v\n", run, err)
+			return 1
+		}
+		allResults = append(allResults, results)
+	}
+
+	printReport(allResults)
+	return 0
+}
+
+// runBenchmark generates fresh input and runs every stage once
+func runBenchmark(run int) ([]StageResult, error) {
+	for _, d := range []string{"source", "app", "files"} {
+		os.RemoveAll(d)
+	}
+
+	dirs := []string{"source", "app/extraction/files/all", "app/extraction/files/pass", "app/extraction/files/txt"}
+	for _, d := range dirs {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory %s: %w", d, err)
+		}
+	}
+
+	fmt.Printf("📦 Run %d: generating synthetic input...\n", run)
+	if err := generateInput("source"); err != nil {
+		return nil, fmt.Errorf("failed to generate input: %w", err)
+	}
+
+	var results []StageResult
+
+	// Stage 1: simulated download (copy into the extraction inbox with N workers)
+	result, err := measureStage("download", "source", func() error {
+		return simulateDownloads("source", "app/extraction/files/all", "app/extraction/files/txt", *downloadWorkers)
+	})
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, result)
+
+	// Stage 2: extraction (files/all → files/pass)
+	result, err = measureStage("extraction", "app/extraction/files/all", func() error {
+		extract.ExtractArchives(1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, result)
+
+	// Stage 3: conversion (files/pass → files/txt/converted.txt)
+	os.Setenv("CONVERT_INPUT_DIR", "app/extraction/files/pass")
+	os.Setenv("CONVERT_OUTPUT_FILE", "app/extraction/files/txt/converted.txt")
+	result, err = measureStage("conversion", "app/extraction/files/pass", func() error {
+		return convert.ConvertTextFiles(1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, result)
+
+	return results, nil
+}
+
+// generateInput writes synthetic archives and txt files into dir
+func generateInput(dir string) error {
+	for i := 0; i < *archiveCount; i++ {
+		if err := generateArchive(filepath.Join(dir, fmt.Sprintf("bench_%03d.zip", i)), i); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < *txtCount; i++ {
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("bench_%03d.txt", i)))
+		if err != nil {
+			return err
+		}
+		w := bufio.NewWriter(f)
+		writeCredentials(w, fmt.Sprintf("t%d", i), *linesPerLog)
+		if err := w.Flush(); err != nil {
+			f.Close()
+			return err
+		}
+		f.Close()
+	}
+
+	return nil
+}
+
+// generateArchive builds a ZIP laid out like a stealer log bundle
+func generateArchive(path string, index int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	filler := []byte(strings.Repeat("benchmark filler data\n", (*fillerKB*1024)/22+1))[:*fillerKB*1024]
+
+	for log := 0; log < *logsPerArchive; log++ {
+		folder := fmt.Sprintf("LOG_%03d_%03d", index, log)
+
+		w, err := zw.Create(folder + "/Passwords.txt")
+		if err != nil {
+			return err
+		}
+		writeCredentials(w, fmt.Sprintf("a%dl%d", index, log), *linesPerLog)
+
+		if *fillerKB > 0 {
+			w, err = zw.Create(folder + "/System.txt")
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(filler); err != nil {
+				return err
+			}
+		}
+	}
+
+	return zw.Close()
+}
+
+// writeCredentials writes records in the URL/Username/Password layout the converter parses
+func writeCredentials(w io.Writer, prefix string, count int) {
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(w, "URL: https://site%d.example.com/login\nUsername: %s_user%d\nPassword: %s_pass%d\n===============\n",
+			i%500, prefix, i, prefix, i)
+	}
+}
+
+// simulateDownloads copies generated files to the pipeline inboxes using a fixed worker count
+func simulateDownloads(srcDir, archiveDir, txtDir string, workers int) error {
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return err
+	}
+
+	if workers <= 0 {
+		workers = 1
+	}
+
+	jobs := make(chan string)
+	errs := make(chan error, len(entries))
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				dst := filepath.Join(archiveDir, name)
+				if strings.HasSuffix(name, ".txt") {
+					dst = filepath.Join(txtDir, name)
+				}
+				if err := copyFile(filepath.Join(srcDir, name), dst); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			jobs <- entry.Name()
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		return err
+	}
+	return nil
+}
+
+// measureStage runs fn while sampling CPU, heap and goroutine usage
+func measureStage(name, inputDir string, fn func() error) (StageResult, error) {
+	result := StageResult{Name: name}
+	result.Files, result.Bytes = directoryUsage(inputDir)
+
+	var before syscall.Rusage
+	syscall.Getrusage(syscall.RUSAGE_SELF, &before)
+
+	stop := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		var m runtime.MemStats
+		for {
+			runtime.ReadMemStats(&m)
+			if m.HeapAlloc > result.PeakHeap {
+				result.PeakHeap = m.HeapAlloc
+			}
+			if g := runtime.NumGoroutine(); g > result.PeakGoroutine {
+				result.PeakGoroutine = g
+			}
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	start := time.Now()
+	err := fn()
+	result.Duration = time.Since(start)
+
+	close(stop)
+	<-sampled
+
+	var after syscall.Rusage
+	syscall.Getrusage(syscall.RUSAGE_SELF, &after)
+	result.UserCPU = time.Duration(after.Utime.Nano() - before.Utime.Nano())
+	result.SystemCPU = time.Duration(after.Stime.Nano() - before.Stime.Nano())
+
+	if err != nil {
+		return result, fmt.Errorf("%s stage failed: %w", name, err)
+	}
+	return result, nil
+}
+
+// directoryUsage returns the number of files and total bytes in a directory
+func directoryUsage(dir string) (int, int64) {
+	var files int
+	var size int64
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	return files, size
+}
+
+func printReport(allResults [][]StageResult) {
+	fmt.Printf("\n📊 Benchmark Results (average of %d run(s))\n", len(allResults))
+	fmt.Printf("%-12s %10s %7s %10s %10s %9s %10s %10s %10s %6s\n",
+		"Stage", "Duration", "Files", "Input", "MB/s", "Files/s", "User CPU", "Sys CPU", "Peak Heap", "Gor.")
+	fmt.Println(strings.Repeat("-", 104))
+
+	stages := len(allResults[0])
+	var total time.Duration
+	for s := 0; s < stages; s++ {
+		avg := StageResult{Name: allResults[0][s].Name}
+		for _, results := range allResults {
+			r := results[s]
+			avg.Duration += r.Duration
+			avg.Files += r.Files
+			avg.Bytes += r.Bytes
+			avg.UserCPU += r.UserCPU
+			avg.SystemCPU += r.SystemCPU
+			if r.PeakHeap > avg.PeakHeap {
+				avg.PeakHeap = r.PeakHeap
+			}
+			if r.PeakGoroutine > avg.PeakGoroutine {
+				avg.PeakGoroutine = r.PeakGoroutine
+			}
+		}
+		n := len(allResults)
+		avg.Duration /= time.Duration(n)
+		avg.Files /= n
+		avg.Bytes /= int64(n)
+		avg.UserCPU /= time.Duration(n)
+		avg.SystemCPU /= time.Duration(n)
+		total += avg.Duration
+
+		seconds := avg.Duration.Seconds()
+		var mbps, fps float64
+		if seconds > 0 {
+			mbps = float64(avg.Bytes) / (1024 * 1024) / seconds
+			fps = float64(avg.Files) / seconds
+		}
+
+		fmt.Printf("%-12s %10s %7d %10s %10.2f %9.2f %10s %10s %10s %6d\n",
+			avg.Name,
+			avg.Duration.Round(time.Millisecond),
+			avg.Files,
+			formatBytes(avg.Bytes),
+			mbps,
+			fps,
+			avg.UserCPU.Round(time.Millisecond),
+			avg.SystemCPU.Round(time.Millisecond),
+			formatBytes(int64(avg.PeakHeap)),
+			avg.PeakGoroutine)
+	}
+
+	var usage syscall.Rusage
+	syscall.Getrusage(syscall.RUSAGE_SELF, &usage)
+
+	fmt.Println(strings.Repeat("-", 104))
+	fmt.Printf("Total pipeline time: %s\n", total.Round(time.Millisecond))
+	fmt.Printf("Max RSS: %s\n", formatBytes(usage.Maxrss*1024))
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func printUsage() {
+	fmt.Println("Telegram Archive Bot - Pipeline Benchmark Tool")
+	fmt.Println()
+	fmt.Println("Generates synthetic archives and runs them through the local pipeline")
+	fmt.Println("(simulated download → extraction → conversion) without Telegram.")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  go run cmd/bench/main.go [options]")
+	fmt.Println()
+	fmt.Println("Options:")
+	flag.PrintDefaults()
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  go run cmd/bench/main.go -archives 10 -logs 20 -lines 5000")
+	fmt.Println("  go run cmd/bench/main.go -archives 20 -download-workers 1 -runs 3")
+	fmt.Println("  go run cmd/bench/main.go -archives 0 -txt 50 -lines 20000")
+	fmt.Println("  go run cmd/bench/main.go -io -io-size 2048 -io-concurrency 3")
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"telegram-archive-bot/utils"
+)
+
+var (
+	action  = flag.String("action", "", "Action to perform: list, inspect, extract")
+	dir     = flag.String("dir", "app/extraction/files/errors", "Quarantine directory (for list)")
+	file    = flag.String("file", "", "Quarantine container (for inspect and extract)")
+	output  = flag.String("out", "", "Where to write the extracted file (for extract)")
+	keyPath = flag.String("key", "", "Quarantine key file (default: QUARANTINE_KEY_PATH)")
+	force   = flag.Bool("force", false, "Force operation without confirmation")
+)
+
+func main() {
+	flag.Parse()
+
+	switch *action {
+	case "list":
+		listContainers()
+	case "inspect":
+		inspectContainer()
+	case "extract":
+		extractContainer()
+	default:
+		if *action != "" {
+			fmt.Printf("Unknown action: %s\n", *action)
+		}
+		printUsage()
+		os.Exit(1)
+	}
+}
+
+func listContainers() {
+	paths, err := filepath.Glob(filepath.Join(*dir, "*"+utils.QuarantineContainerExt))
+	if err != nil {
+		fmt.Printf("Error listing quarantine directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(paths) == 0 {
+		fmt.Printf("No quarantined files found in directory: %s\n", *dir)
+		return
+	}
+
+	fmt.Printf("Found %d quarantined file(s) in %s:\n\n", len(paths), *dir)
+	fmt.Printf("%-10s %-30s %-12s %-8s %s\n", "TASK", "FILE", "SIZE", "THREAT", "QUARANTINED")
+	fmt.Printf("%s\n", strings.Repeat("-", 80))
+
+	for _, path := range paths {
+		meta, err := utils.ReadQuarantineMetadata(path)
+		if err != nil {
+			fmt.Printf("%-10s %s (%v)\n", "?", filepath.Base(path), err)
+			continue
+		}
+		fmt.Printf("%-10s %-30s %-12s %-8s %s\n",
+			meta.TaskID,
+			meta.FileName,
+			formatBytes(meta.FileSize),
+			meta.ThreatLevel,
+			meta.QuarantinedAt.Format("2006-01-02 15:04:05"),
+		)
+	}
+}
+
+func inspectContainer() {
+	requireFile()
+
+	meta, err := utils.ReadQuarantineMetadata(*file)
+	if err != nil {
+		fmt.Printf("Error reading container: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Task:        %s\n", meta.TaskID)
+	fmt.Printf("File:        %s (%s)\n", meta.FileName, formatBytes(meta.FileSize))
+	fmt.Printf("SHA-256:     %s\n", meta.FileHash)
+	fmt.Printf("User:        %d\n", meta.UserID)
+	fmt.Printf("Origin:      %s\n", meta.SourcePath)
+	fmt.Printf("Quarantined: %s\n", meta.QuarantinedAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Threat:      %s\n", meta.ThreatLevel)
+	fmt.Printf("Reason:      %s\n", meta.Reason)
+	if len(meta.Findings) > 0 {
+		fmt.Println("Findings:")
+		for _, finding := range meta.Findings {
+			fmt.Printf("  - %s\n", finding)
+		}
+	}
+}
+
+func extractContainer() {
+	requireFile()
+	if *output == "" {
+		fmt.Println("Error: output path must be specified with -out flag")
+		os.Exit(1)
+	}
+	if _, err := os.Stat(*output); err == nil {
+		fmt.Printf("Error: output file already exists: %s\n", *output)
+		os.Exit(1)
+	}
+
+	meta, err := utils.ReadQuarantineMetadata(*file)
+	if err != nil {
+		fmt.Printf("Error reading container: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Extraction recreates a file that was flagged as dangerous
+	if !*force {
+		fmt.Printf("⚠️  %s was quarantined with threat level %s.\n", meta.FileName, meta.ThreatLevel)
+		fmt.Println("   Only extract it on an isolated machine for analysis.")
+		fmt.Print("Are you sure you want to continue? (y/N): ")
+
+		var response string
+		fmt.Scanln(&response)
+		if strings.ToLower(response) != "y" && strings.ToLower(response) != "yes" {
+			fmt.Println("Extraction cancelled.")
+			return
+		}
+	}
+
+	path, secretKey := *keyPath, ""
+	if path == "" {
+		config, err := utils.LoadConfig()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		path, secretKey = config.QuarantineKeyPath, config.QuarantineKey
+	}
+	var key []byte
+	if secretKey != "" {
+		key, err = utils.ParseQuarantineKey(secretKey)
+	} else {
+		if _, err := os.Stat(path); err != nil {
+			fmt.Printf("Error: quarantine key not found: %v\n", err)
+			os.Exit(1)
+		}
+		key, err = utils.LoadQuarantineKey(path)
+	}
+	if err != nil {
+		fmt.Printf("Error loading quarantine key: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The extracted file is written without execute permission
+	out, err := utils.CreateAtomic(*output, 0600)
+	if err != nil {
+		fmt.Printf("Error creating output file: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := utils.ExtractQuarantineContainer(*file, key, out); err != nil {
+		out.Abort()
+		fmt.Printf("Error extracting container: %v\n", err)
+		os.Exit(1)
+	}
+	if err := out.Commit(); err != nil {
+		fmt.Printf("Error writing output file: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✅ Extracted %s (%s) to %s\n", meta.FileName, formatBytes(meta.FileSize), *output)
+	fmt.Println("   Content verified against the recorded SHA-256")
+}
+
+func requireFile() {
+	if *file == "" {
+		fmt.Println("Error: quarantine container must be specified with -file flag")
+		os.Exit(1)
+	}
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func printUsage() {
+	fmt.Println("Telegram Archive Bot - Quarantine Tool")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Printf("  %s -action=<action> [options]\n", os.Args[0])
+	fmt.Println()
+	fmt.Println("Actions:")
+	fmt.Println("  list      List quarantine containers in a directory")
+	fmt.Println("  inspect   Show the metadata and threat findings of a container")
+	fmt.Println("  extract   Decrypt a container for analysis")
+	fmt.Println()
+	fmt.Println("Options:")
+	flag.PrintDefaults()
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  # List quarantined files")
+	fmt.Printf("  %s -action=list\n", os.Args[0])
+	fmt.Println()
+	fmt.Println("  # Show why a file was quarantined")
+	fmt.Printf("  %s -action=inspect -file=app/extraction/files/errors/quarantine_abc123_sample.zip.quarantine\n", os.Args[0])
+	fmt.Println()
+	fmt.Println("  # Extract a file for analysis on an isolated machine")
+	fmt.Printf("  %s -action=extract -file=<container> -out=/tmp/sample.bin\n", os.Args[0])
+}
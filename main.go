@@ -2,14 +2,24 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/joho/godotenv"
+	"telegram-archive-bot/api"
 	"telegram-archive-bot/bot"
+	"telegram-archive-bot/cluster"
+	"telegram-archive-bot/models"
 	"telegram-archive-bot/monitoring"
 	"telegram-archive-bot/orchestrator"
 	"telegram-archive-bot/storage"
@@ -17,7 +27,80 @@ import (
 	"telegram-archive-bot/workers"
 )
 
+// Heartbeat deadlines for supervised components; each covers the longest
+// single blocking step the component performs between heartbeats
+const (
+	healthMonitorHeartbeatTimeout    = 5 * time.Minute
+	downloadWorkerHeartbeatTimeout   = 45 * time.Minute  // One download incl. hashing and moves
+	orchestratorHeartbeatTimeout     = 150 * time.Minute // Store stage may run for up to 2 hours
+	dlqMonitorHeartbeatTimeout       = 15 * time.Minute
+	quarantineRetryHeartbeatTimeout  = 15 * time.Minute
+	alertDigestHeartbeatTimeout      = 5 * time.Minute
+	alertOutboxHeartbeatTimeout      = 5 * time.Minute  // One delivery attempt, a flood wait included
+	adminAnomalyHeartbeatTimeout     = 15 * time.Minute
+	dailyReportHeartbeatTimeout      = 15 * time.Minute
+	goroutineMonitorHeartbeatTimeout = 5 * time.Minute
+	secretsRefreshHeartbeatSlack     = 5 * time.Minute // Added to SECRETS_REFRESH_INTERVAL
+	watchdogHeartbeatSlack           = 5 * time.Minute // Added to WATCHDOG_INTERVAL
+	statusFileHeartbeatSlack         = 5 * time.Minute // Added to STATUS_FILE_INTERVAL
+	systemdNotifyHeartbeatTimeout    = 5 * time.Minute
+	selfUpdateHeartbeatTimeout       = 25 * time.Minute // Feed and binary downloads, 10m each
+	leaseReclaimHeartbeatSlack       = 5 * time.Minute  // Added to half of TASK_LEASE_TTL
+	taskPurgeHeartbeatTimeout        = 30 * time.Minute // Hashing one deleted task's files
+	retentionHeartbeatTimeout        = time.Hour        // Deleting expired lines from one store shard
+)
+
+// downloadWorkerCount is the number of download workers started; how many
+// download at once is MAX_CONCURRENT_DOWNLOADS, which /limits may raise
+const downloadWorkerCount = utils.MaxStageConcurrency
+
+// Shutdown deadlines, per component in shutdown order
+const (
+	botShutdownTimeout           = 5 * time.Second
+	supervisorShutdownTimeout    = 30 * time.Second // Running loops finish their current step
+	downloadShutdownTimeout      = 30 * time.Second // Moving downloaded files and wiping temp files
+	degradationShutdownTimeout   = 10 * time.Second
+	healthMonitorShutdownTimeout = 10 * time.Second // Delivers alerts raised while stopping
+)
+
+var (
+	role            = flag.String("role", "bot", "Node role: bot (accept, download and coordinate) or worker (remote extraction/conversion)")
+	coordinatorAddr = flag.String("coordinator", "", "Coordinator address for -role=worker (default CLUSTER_COORDINATOR_ADDR)")
+	workDir         = flag.String("work-dir", "worker-data", "Working directory for -role=worker")
+	workerID        = flag.String("worker-id", "", "Worker node ID for -role=worker (default hostname)")
+	printConfig     = flag.Bool("print-config", false, "Print the effective configuration with secrets masked, then exit")
+	printEnvSample  = flag.Bool("print-env-sample", false, "Print an annotated sample .env with every setting at its default, then exit")
+	printVersion    = flag.Bool("version", false, "Print the build version, commit and schema version, then exit")
+)
+
 func main() {
+	flag.Parse()
+
+	switch {
+	case *printEnvSample:
+		if err := utils.WriteSampleEnv(os.Stdout); err != nil {
+			log.Fatalf("Failed to write sample env: %v", err)
+		}
+		return
+	case *printConfig:
+		runPrintConfig()
+		return
+	case *printVersion:
+		build := utils.CurrentBuild()
+		fmt.Printf("telegram-archive-bot %s\ncommit: %s\nbuilt: %s\ngo: %s\nschema version: %d\n",
+			build, build.Commit, build.BuiltAt.Format(time.RFC3339), build.GoVersion, storage.LatestSchemaVersion())
+		return
+	}
+
+	switch *role {
+	case "bot":
+	case "worker":
+		runWorker()
+		return
+	default:
+		log.Fatalf("Unknown role %q (expected bot or worker)", *role)
+	}
+
 	config, err := utils.LoadConfig()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
@@ -28,121 +111,753 @@ func main() {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
 
+	// Deferred first so a restart into an installed update happens after every
+	// other cleanup, e.g. closing the database and releasing the instance lock
+	restartIntoUpdate := false
+	defer func() {
+		if !restartIntoUpdate {
+			return
+		}
+		logger.Info("Restarting into the installed update...")
+		if err := utils.Reexec(); err != nil {
+			logger.WithError(err).Error("Failed to restart; exiting so the service manager starts the new binary")
+			os.Exit(1)
+		}
+	}()
+
+	// Keep the heap within container limits during large hashing bursts
+	tuning, err := utils.ApplyMemoryTuning(config)
+	if err != nil {
+		logger.WithError(err).Warn("Memory limit not applied")
+	}
+	logger.WithField("settings", tuning.String()).Info("Garbage collector configured")
+
+	// Capture panics from workers and handlers as crash reports
+	crashReporter := utils.NewCrashReporter(logger, config.CrashReportDir)
+	utils.SetDefaultCrashReporter(crashReporter)
+	if config.SentryDSN != "" {
+		sentrySink, err := utils.NewSentrySink(config.SentryDSN, config.SentryEnvironment)
+		if err != nil {
+			logger.WithError(err).Warn("Sentry integration disabled")
+		} else {
+			crashReporter.AddSink(sentrySink)
+		}
+	}
+
+	// Refuse to start a second instance against the same database and Bot API
+	// directories; HA instances share them on purpose and coordinate by lease
+	if !config.HAEnabled && config.DatabasePath != storage.MemoryDatabasePath {
+		instanceLock, err := utils.AcquireInstanceLock(config.DatabasePath + ".lock")
+		if err != nil {
+			logger.Fatalf("Failed to start: %v", err)
+		}
+		defer instanceLock.Release()
+		logger.WithField("lock_file", instanceLock.Path()).Debug("Instance lock acquired")
+	}
+
 	db, err := storage.NewDatabase(config.DatabasePath)
 	if err != nil {
 		logger.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
 
+	// Crash reports carry the schema version so they can be matched to backups
+	if schemaVersion, err := db.SchemaVersion(); err == nil {
+		crashReporter.SetSchemaVersion(schemaVersion)
+		build := utils.CurrentBuild()
+		logger.WithField("version", build.String()).
+			WithField("commit", build.Commit).
+			WithField("schema_version", schemaVersion).
+			Info("Build info")
+	}
+
 	taskStore := storage.NewTaskStore(db)
+	crashReporter.AddSink(func(report *utils.CrashReport) {
+		if err := taskStore.SaveCrashReport(report); err != nil {
+			logger.WithError(err).WithField("crash_id", report.ID).Warn("Failed to persist crash report")
+		}
+	})
 	
-	// Initialize download worker first to get BotAPIPathManager
-	downloadWorker := workers.NewDownloadWorker(nil, config, logger, taskStore) // Temporary, will set bot later
+	// Extract/convert are compiled in; warn if their sources changed since this build
+	workers.VerifyProcessorBuilds(taskStore, logger)
 	
-	// Initialize recovery service with BotAPIPathManager and perform crash recovery
-	recoveryService := storage.NewRecoveryService(taskStore, logger, downloadWorker.GetBotAPIPathManager())
-	if err := recoveryService.RecoverIncompleteTasks(context.Background()); err != nil {
-		logger.WithError(err).Error("Crash recovery failed, continuing with startup")
+	// Crash recovery needs the Local Bot API paths before the bot and the
+	// download worker exist
+	botAPIPathManager := utils.NewBotAPIPathManager(config, logger)
+	if err := botAPIPathManager.EnsureDirectories(); err != nil {
+		logger.WithError(err).Fatal("Failed to ensure Local Bot API directories")
 	}
 	
-	// Cleanup orphaned files
-	if err := recoveryService.CleanupOrphanedFiles(); err != nil {
-		logger.WithError(err).Warn("Orphaned file cleanup failed")
+	// Initialize recovery service with BotAPIPathManager and perform crash recovery
+	recoveryService := storage.NewRecoveryService(taskStore, logger, botAPIPathManager)
+	// Recovery leaves a reconciliation report in the database, sent to the
+	// admins once the bot is up
+	runRecovery := func(trigger string) *storage.ReconciliationReport {
+		return recoveryService.Reconcile(context.Background(), trigger, config.HAInstanceID)
+	}
+
+	// In HA mode recovery runs once this instance is elected, so a standby
+	// never resets tasks the active leader is still processing
+	var startupReconciliation *storage.ReconciliationReport
+	if !config.HAEnabled {
+		startupReconciliation = runRecovery(storage.ReconcileStartup)
 	}
 	
 	// Initialize Telegram bot
-	telegramBot, err := bot.NewTelegramBot(config, logger.Logger, taskStore)
+	telegramBot, err := bot.NewTelegramBot(config, logger.Logger, taskStore, db.DB())
 	if err != nil {
 		logger.Fatalf("Failed to initialize Telegram bot: %v", err)
 	}
 
-	// Update download worker with actual bot API
-	downloadWorker = workers.NewDownloadWorker(telegramBot.GetBotAPI(), config, logger, taskStore)
+	// Notify admins about crashes
+	crashReporter.AddSink(func(report *utils.CrashReport) {
+		message := formatCrashMessage(report)
+		for _, adminID := range config.AdminIDs {
+			if err := telegramBot.SendMessage(adminID, message); err != nil {
+				logger.WithError(err).
+					WithField("admin_id", adminID).
+					WithField("crash_id", report.ID).
+					Error("Failed to send crash notification to admin")
+			}
+		}
+	})
+
+	// Tell the admins what recovery changed; a clean start is not reported
+	notifyReconciliation := func(report *storage.ReconciliationReport) {
+		if report == nil || !report.Changed() {
+			return
+		}
+		message := formatReconciliationMessage(report)
+		for _, adminID := range config.AdminIDs {
+			if err := telegramBot.SendMessage(adminID, message); err != nil {
+				logger.WithError(err).
+					WithField("admin_id", adminID).
+					Error("Failed to send reconciliation report to admin")
+			}
+		}
+	}
+	notifyReconciliation(startupReconciliation)
+
+	// Initialize the download worker now that the bot can look up files
+	downloadWorker := workers.NewDownloadWorker(telegramBot.GetBotAPI(), botAPIPathManager, config, logger, taskStore, db.DB())
+
+	// Hand newly queued tasks to idle download workers instead of waiting for their next poll
+	taskDispatch := storage.NewTaskDispatch(downloadWorkerCount)
+	taskStore.SetDispatch(taskDispatch, config.TaskDispatchUpdateHook)
+	downloadWorker.SetDispatch(taskDispatch)
+
+	// /signatures reloads the definitions the download worker validates files with
+	telegramBot.SetSignatureRegistry(downloadWorker.GetSignatureRegistry())
+
+	// Download hashing and moves share one bandwidth limit that /throttle adjusts
+	bandwidthLimiter := utils.NewBandwidthLimiter(config.BandwidthSchedule)
+	downloadWorker.SetBandwidthLimiter(bandwidthLimiter)
+	telegramBot.SetBandwidthLimiter(bandwidthLimiter)
+
+	// Downloads, extractions and conversions share one set of limits that /limits adjusts
+	stageLimits := utils.NewStageLimits(config.MaxConcurrentDownloads, config.MaxConcurrentExtractions, config.MaxConcurrentConversions)
+	downloadWorker.SetStageLimits(stageLimits)
+	telegramBot.SetStageLimits(stageLimits)
+
+	// The cost model fitted on stage timings orders the queue and admits new files
+	taskStore.SetQueueOrder(storage.QueueOrder{Mode: config.QueueOrder, MaxWait: config.QueueShortestFirstMaxWait})
+	telegramBot.SetCostEstimator(storage.NewCostEstimator(taskStore, config.CostModelRefresh))
+
+	// /backup writes to the same directory as cmd/backup
+	backupService, err := storage.NewBackupService(db, storage.BackupOptions{
+		BackupDir: config.BackupDir,
+		Codec:     config.BackupCompression,
+		Level:     config.BackupCompressionLevel,
+	})
+	if err != nil {
+		logger.WithError(err).Warn("Database backups via /backup are unavailable")
+	} else {
+		telegramBot.SetBackupService(backupService)
+	}
 
 	// Initialize sequential orchestrator (Option 1 architecture)
 	sequentialOrchestrator := orchestrator.NewSequentialOrchestrator(logger.Logger, config, taskStore, telegramBot)
+	sequentialOrchestrator.SetStageLimits(stageLimits)
+	if tempManager := downloadWorker.GetTempManager(); tempManager != nil {
+		sequentialOrchestrator.SetTaskFileReferences(tempManager)
+	}
+
+	// /restore waits for the processing cycle to finish, and recovery sets
+	// the tasks right against the files on disk once the backup is restored
+	telegramBot.SetRestoreHooks(sequentialOrchestrator.CycleRunning, func() {
+		notifyReconciliation(runRecovery(storage.ReconcileRestore))
+	})
+	if ledgers := utils.NewLedgerSinks(config); len(ledgers) > 0 {
+		sequentialOrchestrator.SetLedgers(ledgers)
+		logger.WithField("ledgers", len(ledgers)).Info("Completion ledger enabled")
+	}
+	if config.OCREnabled {
+		ocrEngine := utils.NewOCREngine(config)
+		if err := ocrEngine.Available(); err != nil {
+			logger.WithError(err).Warn("Screenshot OCR disabled")
+		} else {
+			sequentialOrchestrator.SetOCR(ocrEngine)
+			logger.WithField("languages", config.OCRLanguages).Info("Screenshot OCR enabled")
+		}
+	}
+
+	// Initialize cluster coordinator when remote worker nodes are enabled
+	var coordinator *cluster.Coordinator
+	if config.ClusterListenAddr != "" {
+		coordinator = cluster.NewCoordinator(logger, config.ClusterToken, config.ClusterJobLease, "app/extraction/files/cluster")
+		var clusterTLS *tls.Config
+		if config.ClusterTLSCert != "" {
+			clusterTLS, err = utils.LoadServerTLS(config.ClusterTLSCert, config.ClusterTLSKey, config.ClusterTLSClientCA, true)
+			if err != nil {
+				logger.Fatalf("Failed to load cluster TLS configuration: %v", err)
+			}
+		}
+		clusterAudit := storage.NewAdminAuditLogger(db.DB(), logger)
+		coordinator.SetAccess(clusterTLS, config.ClusterAllowedIPs, func(addr, method, reason string) {
+			ip, _, splitErr := net.SplitHostPort(addr)
+			if splitErr != nil {
+				ip = addr
+			}
+			clusterAudit.LogAPIRequest(storage.APIRequest{
+				Method: "gRPC", Resource: method, IPAddress: ip, Result: "BLOCKED", Error: reason,
+			})
+		})
+		sequentialOrchestrator.SetCoordinator(coordinator)
+		utils.Secrets.OnRotate("CLUSTER_TOKEN", coordinator.SetToken)
+	}
+
+	// Publish loop liveness for systemd or external monitors
+	watchdogPolicy := monitoring.DefaultWatchdogPolicy()
+	watchdogPolicy.StatusFile = config.WatchdogFile
+	watchdogPolicy.Interval = config.WatchdogInterval
+	watchdog := monitoring.NewWatchdog(logger, watchdogPolicy)
+	sequentialOrchestrator.SetLoopHook(func() { watchdog.Mark(monitoring.WatchdogOrchestratorLoop) })
+	telegramBot.SetPollHook(func() { watchdog.Mark(monitoring.WatchdogTelegramPoll) })
 	
 	// Initialize health monitor
 	healthMonitor := monitoring.NewHealthMonitor(logger, taskStore)
+	healthMonitor.SetHistorySize(config.HealthHistorySize)
+	// Report the volumes downloads and backups land on, which may be separate mounts
+	if botAPIPath, err := botAPIPathManager.DetectLocalBotAPIPath(); err == nil {
+		healthMonitor.GetSystemMonitor().SetDiskPath("bot_api", botAPIPath)
+	}
+	if config.BackupDir != "" {
+		healthMonitor.GetSystemMonitor().SetDiskPath("backups", config.BackupDir)
+	}
+	telegramBot.SetHealthMonitor(healthMonitor)
+
+	// /pipeline and GET /api/pipeline/graph show where the backlog sits
+	pipelineGraph := func() (*monitoring.PipelineGraph, error) {
+		return monitoring.BuildPipelineGraph(taskStore, sequentialOrchestrator)
+	}
+	telegramBot.SetPipelineGraph(pipelineGraph)
 	
-	// Register Telegram alert notification callback
+	// Alert notifications to the admins go through a stored outbox, retried
+	// until Telegram accepts them and failed over when it keeps refusing
+	adminRecipients := make([]string, 0, len(config.AdminIDs))
+	for _, adminID := range config.AdminIDs {
+		adminRecipients = append(adminRecipients, strconv.FormatInt(adminID, 10))
+	}
+	alertOutbox := monitoring.NewAlertOutbox(logger, taskStore, telegramBot.NotificationChannel(), adminRecipients,
+		monitoring.NewFailoverChannels(config), config.AlertFailoverAfter, config.AlertOutboxMaxAge)
+	healthMonitor.RegisterChecker(alertOutbox)
+	healthMonitor.SetCheckPolicy(monitoring.HealthCheckPolicy{
+		Interval:         config.HealthCheckInterval,
+		Jitter:           config.HealthCheckJitter,
+		Timeout:          config.HealthCheckTimeout,
+		CheckerTimeouts:  config.HealthCheckerTimeouts,
+		CheckerIntervals: config.HealthCheckerIntervals,
+	})
+
+	// Register alert notification callback; non-critical alerts are
+	// batched into a digest so repeated firings don't flood the admins
 	alertManager := healthMonitor.GetAlertManager()
-	alertManager.AddAlertCallback(func(alert *monitoring.Alert) {
-		// Send alert notification to all admin users
-		alertMessage := formatAlertMessage(alert)
-		for _, adminID := range config.AdminIDs {
-			if err := telegramBot.SendMessage(adminID, alertMessage); err != nil {
-				logger.WithError(err).
-					WithField("admin_id", adminID).
-					WithField("alert_id", alert.ID).
-					Error("Failed to send alert notification to admin")
+	alertDigester := monitoring.NewAlertDigester(logger, config.AlertDigestWindow, alertOutbox.Enqueue, formatAlertMessage)
+	alertManager.AddAlertCallback(alertDigester.Notify)
+	
+	// Supervisor restarts crashed or deadlocked components and raises ComponentDown alerts
+	supervisor := monitoring.NewSupervisor(logger, alertManager)
+
+	// Install signed releases of the update channel once the queue is idle
+	updater := utils.NewSelfUpdater(config, logger)
+	updater.SetIdleCheck(func() bool {
+		for _, status := range []models.TaskStatus{models.TaskStatusPending, models.TaskStatusDownloading, models.TaskStatusDownloaded} {
+			if count, err := taskStore.GetTaskCountByStatus(status); err != nil || count > 0 {
+				return false
 			}
 		}
+		return sequentialOrchestrator.IsIdle()
 	})
-	
-	healthMonitor.Start()
-	defer healthMonitor.Stop()
+	updater.SetNotifier(func(title, message string) {
+		alertManager.RaiseAlert(monitoring.AlertTypeSelfUpdate, monitoring.AlertLevelInfo, "self_update", title, message, nil)
+	})
+	telegramBot.SetSelfUpdater(updater)
+
+	// Escalate dead letter entries that nobody has dealt with and send a weekly digest
+	dlqPolicy := monitoring.DefaultDLQAlertPolicy()
+	dlqPolicy.WarningAge = config.DLQWarningAge
+	dlqPolicy.CriticalAge = config.DLQCriticalAge
+	dlqPolicy.WarningCount = config.DLQWarningCount
+	dlqPolicy.CriticalCount = config.DLQCriticalCount
+	dlqPolicy.DigestWeekday = config.DLQDigestWeekday
+	dlqPolicy.DigestHour = config.DLQDigestHour
+	dlqMonitor := monitoring.NewDLQMonitor(logger, storage.NewDeadLetterQueue(db), taskStore, alertManager, dlqPolicy,
+		func(text string) {
+			for _, adminID := range config.AdminIDs {
+				if err := telegramBot.SendMessage(adminID, text); err != nil {
+					logger.WithError(err).
+						WithField("admin_id", adminID).
+						Error("Failed to send dead letter digest to admin")
+				}
+			}
+		})
+
+	// Report each day's throughput next to its CPU and disk I/O
+	dailyReporter := monitoring.NewDailyReporter(logger, taskStore, config.DailyReportHour,
+		func(text string) {
+			for _, adminID := range config.AdminIDs {
+				if err := telegramBot.SendMessage(adminID, text); err != nil {
+					logger.WithError(err).
+						WithField("admin_id", adminID).
+						Error("Failed to send daily report to admin")
+				}
+			}
+		})
+
+	// Flag download bursts, unusual admin hours and repeated unauthorized attempts
+	anomalyPolicy := monitoring.DefaultAdminAnomalyPolicy()
+	anomalyPolicy.Window = config.AdminAnomalyWindow
+	anomalyPolicy.DownloadBurst = config.AdminAnomalyDownloadBurst
+	anomalyPolicy.UnauthorizedAttempts = config.AdminAnomalyUnauthorizedAttempts
+	anomalyMonitor := monitoring.NewAdminAnomalyMonitor(logger, storage.NewAdminAuditLogger(db.DB(), logger),
+		alertManager, anomalyPolicy)
 
 	logger.Info("Telegram Archive Bot starting (Option 1: Sequential Pipeline)...")
 	logger.WithField("admins", config.AdminIDs).Info("Authorized admin IDs loaded")
+	if utils.Faults.Enabled() {
+		logger.WithField("faults", utils.Faults.String()).
+			Warn("Fault injection enabled: operations will fail and stall on purpose")
+	}
+	if config.DryRun {
+		logger.WithField("throughput", config.DryRunThroughput).
+			Warn("Dry run enabled: extraction and conversion are simulated and their input discarded")
+	}
 	logger.WithField("start_time", healthMonitor.GetStartTime()).Info("Health monitoring started")
 
 	// Start workers and orchestrator with context
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Start 3 download workers (Telegram API limit)
-	logger.Info("Starting 3 download workers...")
-	for i := 1; i <= 3; i++ {
-		workerID := i
+	supervisor.Go(ctx, "health_monitor", healthMonitorHeartbeatTimeout, healthMonitor.Run)
+
+	// Alerts are raised on every instance, so their digests are delivered everywhere too
+	supervisor.Go(ctx, "alert_digest", alertDigestHeartbeatTimeout, alertDigester.Run)
+
+	// Alert on stalled background goroutines and on goroutines piling up beyond them
+	goroutinePolicy := monitoring.DefaultGoroutinePolicy()
+	goroutinePolicy.LeakMargin = config.GoroutineLeakMargin
+	goroutinePolicy.LeakSustain = config.GoroutineLeakSustain
+	goroutineMonitor := monitoring.NewGoroutineMonitor(logger, alertManager, utils.Goroutines, goroutinePolicy)
+	supervisor.Go(ctx, "goroutine_monitor", goroutineMonitorHeartbeatTimeout, goroutineMonitor.Run)
+
+	if config.WatchdogFile != "" {
+		supervisor.Go(ctx, "watchdog", config.WatchdogInterval+watchdogHeartbeatSlack, watchdog.Run)
+	}
+
+	// Publish queue, health and maintenance state for host tooling
+	if config.StatusFile != "" {
+		statusFilePolicy := monitoring.DefaultStatusFilePolicy()
+		statusFilePolicy.Path = config.StatusFile
+		statusFilePolicy.Interval = config.StatusFileInterval
+		statusFile := monitoring.NewStatusFileWriter(logger, statusFilePolicy, taskStore, healthMonitor, sequentialOrchestrator)
+		supervisor.Go(ctx, "status_file", config.StatusFileInterval+statusFileHeartbeatSlack, statusFile.Run)
+	}
+
+	if config.UpdateFeedURL != "" {
+		logger.WithField("channel", config.UpdateChannel).
+			WithField("restart_window", config.UpdateRestartWindow.String()).
+			Info("Self-update enabled")
+		supervisor.Go(ctx, "self_update", selfUpdateHeartbeatTimeout, updater.Run)
+	}
+
+	// Re-fetch secrets from their stores and apply rotated values
+	utils.Secrets.OnRotate("TELEGRAM_BOT_TOKEN", func(string) {
+		logger.Warn("TELEGRAM_BOT_TOKEN was rotated; restart the bot to use the new token")
+	})
+	if config.SecretsRefreshInterval > 0 {
+		supervisor.Go(ctx, "secrets_refresh", config.SecretsRefreshInterval+secretsRefreshHeartbeatSlack, func(ctx context.Context) error {
+			return utils.Secrets.Run(ctx, config.SecretsRefreshInterval, logger)
+		})
+	}
+
+	// Serve the HTTP API when enabled
+	if config.APIListenAddr != "" {
+		apiServer := api.NewServer(logger, taskStore)
+		apiClients := func() []utils.APIClient {
+			clients := append(append([]utils.APIClient{}, config.APIKeys...), config.APIClientCerts...)
+			if config.APIAuthToken != "" {
+				// The original single token keeps full access
+				clients = append(clients, utils.APIClient{
+					Name: "api_auth_token", Key: config.APIAuthToken, Scopes: []string{utils.APIScopeAll},
+				})
+			}
+			return clients
+		}
+		access := api.Access{
+			Clients:     apiClients(),
+			RequireAuth: len(config.APIKeys) > 0 || len(config.APIClientCerts) > 0,
+			AllowedIPs:  config.APIAllowedIPs,
+			Audit:       storage.NewAdminAuditLogger(db.DB(), logger),
+		}
+		utils.Secrets.OnRotate("API_AUTH_TOKEN", func(token string) {
+			config.APIAuthToken = token
+			apiServer.SetClients(apiClients())
+		})
+		utils.Secrets.OnRotate("API_KEYS", func(keys string) {
+			clients, err := utils.ParseAPIKeys(keys)
+			if err != nil {
+				logger.WithError(err).Error("Rotated API_KEYS are invalid; keeping the previous keys")
+				return
+			}
+			config.APIKeys = clients
+			apiServer.SetClients(apiClients())
+		})
+		if config.APITLSCert != "" {
+			access.TLS, err = utils.LoadServerTLS(config.APITLSCert, config.APITLSKey, config.APITLSClientCA, false)
+			if err != nil {
+				logger.Fatalf("Failed to load HTTP API TLS configuration: %v", err)
+			}
+		}
+		apiServer.SetAccess(access)
+		if apiServer.AllowsScope(utils.APIScopePprof) {
+			apiServer.EnableProfiling()
+		}
+		apiServer.HandleProbe("GET /healthz", watchdog)
+		apiServer.EnablePipelineGraph(pipelineGraph)
 		go func() {
-			if err := downloadWorker.StartPolling(ctx, workerID); err != nil && err != context.Canceled {
-				logger.WithField("worker_id", workerID).
-					WithError(err).
-					Error("Download worker stopped with error")
+			defer utils.Goroutines.Register("http_api", 0).Done()
+			if err := apiServer.Start(ctx, config.APIListenAddr); err != nil {
+				logger.WithError(err).Error("HTTP API stopped with error")
 			}
 		}()
 	}
 
-	// Start sequential orchestrator
-	logger.Info("Starting sequential processing orchestrator...")
-	go func() {
-		if err := sequentialOrchestrator.Start(ctx); err != nil && err != context.Canceled {
-			logger.WithError(err).Error("Sequential orchestrator stopped with error")
+	// Recover the pipeline's directories and pass.txt when they fail, deferring
+	// the stages that need them, and restart the Local Bot API server if it
+	// stops answering
+	degradation := utils.NewGracefulDegradationManager(logger)
+	if config.UseLocalBotAPI && config.LocalBotAPIEnabled {
+		degradation.RegisterEndpoint(utils.LocalBotAPIDependency, config.LocalBotAPIURL, time.Minute, utils.FallbackManual)
+	}
+	sequentialOrchestrator.SetDegradation(degradation)
+	degradation.RegisterDefaultRecoveryActions(config)
+	degradation.SetRecoveryAuditor(storage.NewAdminAuditLogger(db.DB(), logger).LogRecoveryAttempt)
+	// Keep stages deferred by an outage across restarts
+	degradation.SetOperationStore(taskStore)
+	degradation.StartMonitoring(ctx)
+
+	// startProcessing runs the download workers, orchestrator and coordinator until ctx is cancelled
+	startProcessing := func(ctx context.Context) {
+		if coordinator != nil {
+			coordinator.Requeue(map[string]string{
+				cluster.JobExtract: "app/extraction/files/all",
+				cluster.JobConvert: "app/extraction/files/pass",
+			})
 		}
-	}()
 
-	// Start bot in goroutine
-	logger.Info("Starting Telegram bot...")
-	go func() {
-		if err := telegramBot.Start(); err != nil {
-			logger.WithError(err).Error("Bot stopped with error")
+		// Start the download workers; only MAX_CONCURRENT_DOWNLOADS of them download at once
+		logger.Infof("Starting %d download workers, %d downloading at once...", downloadWorkerCount, stageLimits.Limit(utils.StageDownloads))
+		for i := 1; i <= downloadWorkerCount; i++ {
+			workerID := i
+			supervisor.Go(ctx, fmt.Sprintf("download_worker_%d", workerID), downloadWorkerHeartbeatTimeout, func(ctx context.Context) error {
+				return downloadWorker.StartPolling(ctx, workerID)
+			})
+		}
+
+		// Requeue downloads whose worker stopped renewing its lease
+		leaseReclaimInterval := config.TaskLeaseTTL / 2
+		supervisor.Go(ctx, "lease_reclaim", leaseReclaimInterval+leaseReclaimHeartbeatSlack, func(ctx context.Context) error {
+			return recoveryService.RunLeaseReclaim(ctx, leaseReclaimInterval)
+		})
+
+		// Start sequential orchestrator
+		logger.Info("Starting sequential processing orchestrator...")
+		supervisor.Go(ctx, "orchestrator", orchestratorHeartbeatTimeout, sequentialOrchestrator.Start)
+		watchdog.Track(ctx, monitoring.WatchdogOrchestratorLoop, config.WatchdogLoopStaleAfter)
+
+		supervisor.Go(ctx, "dlq_monitor", dlqMonitorHeartbeatTimeout, dlqMonitor.Run)
+		supervisor.Go(ctx, "admin_anomaly_monitor", adminAnomalyHeartbeatTimeout, anomalyMonitor.Run)
+		if config.DailyReportHour >= 0 {
+			supervisor.Go(ctx, "daily_report", dailyReportHeartbeatTimeout, dailyReporter.Run)
 		}
-	}()
+
+		// Deliver stored alert notifications; any instance may have queued them
+		supervisor.Go(ctx, "alert_outbox", alertOutboxHeartbeatTimeout, alertOutbox.Run)
+
+		// Keep retrying flagged files that could not be quarantined yet
+		supervisor.Go(ctx, "quarantine_retry", quarantineRetryHeartbeatTimeout, downloadWorker.RunQuarantineRetry)
+
+		// Purge the files of tasks deleted with /delete once their grace period is over
+		taskPurger := workers.NewTaskPurger(taskStore, storage.NewAdminAuditLogger(db.DB(), logger), logger, config.IOTuning())
+		supervisor.Go(ctx, "task_purge", taskPurgeHeartbeatTimeout, taskPurger.Run)
+
+		// Delete data past its retention policy, leaving deletion certificates in the audit log
+		if len(config.RetentionPolicies) > 0 {
+			retention := workers.NewRetentionManager(taskStore, storage.NewAdminAuditLogger(db.DB(), logger), logger,
+				config.RetentionPolicies, config.RetentionInterval, config.IOTuning())
+			supervisor.Go(ctx, "retention", retentionHeartbeatTimeout, retention.Run)
+		}
+
+		// Start cluster coordinator
+		if coordinator != nil {
+			go func() {
+				defer utils.Goroutines.Register("cluster_coordinator", 0).Done()
+				if err := coordinator.Start(ctx, config.ClusterListenAddr); err != nil {
+					logger.WithError(err).Error("Cluster coordinator stopped with error")
+				}
+			}()
+		}
+	}
+
+	if config.HAEnabled {
+		// Only the elected leader polls Telegram and processes files; a standby
+		// takes over when the leader's lease expires
+		elector := storage.NewLeaderElector(db, "bot", config.HAInstanceID, config.HALeaseTTL, logger)
+		sequentialOrchestrator.SetFence(elector.Fence)
+
+		logger.WithField("instance_id", config.HAInstanceID).
+			WithField("lease_ttl", config.HALeaseTTL).
+			Info("High availability enabled, campaigning for leadership...")
+		go func() {
+			defer utils.Goroutines.Register("leader_election", 0).Done()
+			elector.Run(ctx, func(leaderCtx context.Context) {
+				notifyReconciliation(runRecovery(storage.ReconcileFailover))
+				startProcessing(leaderCtx)
+				watchdog.Track(leaderCtx, monitoring.WatchdogTelegramPoll, config.WatchdogPollStaleAfter)
+				defer utils.Goroutines.Register("telegram_poll", 0).Done()
+				if err := telegramBot.PollUpdates(leaderCtx); err != nil {
+					logger.WithError(err).Error("Bot polling stopped with error")
+				}
+			})
+		}()
+	} else {
+		startProcessing(ctx)
+
+		// Start bot in goroutine
+		logger.Info("Starting Telegram bot...")
+		watchdog.Track(ctx, monitoring.WatchdogTelegramPoll, config.WatchdogPollStaleAfter)
+		go func() {
+			defer utils.Goroutines.Register("telegram_poll", 0).Done()
+			if err := telegramBot.Start(); err != nil {
+				logger.WithError(err).Error("Bot stopped with error")
+			}
+		}()
+	}
+
+	// Report startup, queue depth and liveness when run as a Type=notify systemd unit
+	systemdNotifier := monitoring.NewSystemdNotifier(logger, taskStore, watchdog, monitoring.DefaultSystemdPolicy())
+	if utils.SdNotifyEnabled() {
+		systemdNotifier.Ready()
+		supervisor.Go(ctx, "systemd_notify", systemdNotifyHeartbeatTimeout, systemdNotifier.Run)
+		if interval := utils.SdWatchdogInterval(); interval > 0 {
+			logger.WithField("watchdog_sec", interval).Info("systemd watchdog enabled")
+		}
+	}
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	<-sigChan
-	logger.Info("Shutdown signal received, shutting down gracefully...")
+	select {
+	case <-sigChan:
+		logger.Info("Shutdown signal received, shutting down gracefully...")
+	case <-updater.Restart():
+		logger.Info("Update installed, shutting down gracefully to restart...")
+		restartIntoUpdate = true
+	}
+	systemdNotifier.Stopping()
 
 	// Cancel context to stop all workers and orchestrator
 	cancel()
 
-	// Give workers time to finish current tasks (5 seconds)
+	// Stop taking new work first and the health monitor last, so alerts
+	// raised while stopping are still delivered
+	shutdown := utils.NewShutdownSequence(logger)
+	shutdown.Add("telegram_bot", botShutdownTimeout, utils.ShutdownFunc(func(context.Context) error {
+		telegramBot.Stop()
+		return nil
+	}))
+	shutdown.Add("supervisor", supervisorShutdownTimeout, supervisor)
+	shutdown.Add("download_worker", downloadShutdownTimeout, downloadWorker)
+	shutdown.Add("dependency_recovery", degradationShutdownTimeout, degradation)
+	shutdown.Add("health_monitor", healthMonitorShutdownTimeout, healthMonitor)
+
 	logger.Info("Waiting for workers to finish current tasks...")
-	time.Sleep(5 * time.Second)
+	if err := shutdown.Shutdown(context.Background()); err != nil {
+		logger.WithError(err).Error("Error shutting down")
+	}
+
+	logger.Info("Telegram Archive Bot stopped")
+}
 
-	// Shutdown download worker (including secure temp manager)
-	if err := downloadWorker.Shutdown(); err != nil {
-		logger.WithError(err).Error("Error shutting down download worker")
+// runPrintConfig prints the settings in effect and then reports any problem
+// LoadConfig finds with them
+func runPrintConfig() {
+	// Settings may come from the environment alone
+	godotenv.Load()
+	if err := utils.WriteEffectiveConfig(os.Stdout); err != nil {
+		log.Fatalf("Failed to write configuration: %v", err)
+	}
+	if _, err := utils.LoadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+}
 
-	// Stop Telegram bot
-	telegramBot.Stop()
+// runWorker runs this binary as a remote worker node that pulls extraction
+// and conversion jobs from the coordinator
+func runWorker() {
+	// Worker nodes don't need the bot configuration; .env is optional
+	godotenv.Load()
 
-	logger.Info("Telegram Archive Bot stopped")
+	logLevel := os.Getenv("LOG_LEVEL")
+	if logLevel == "" {
+		logLevel = "info"
+	}
+	logger, err := utils.NewLogger(&utils.Config{LogLevel: strings.ToLower(logLevel), LogFilePath: "logs/worker.log"})
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	addr := *coordinatorAddr
+	if addr == "" {
+		addr = os.Getenv("CLUSTER_COORDINATOR_ADDR")
+	}
+	if addr == "" {
+		logger.Fatal("Coordinator address is required (-coordinator or CLUSTER_COORDINATOR_ADDR)")
+	}
+
+	id := *workerID
+	if id == "" {
+		id, _ = os.Hostname()
+	}
+
+	var tlsConfig *tls.Config
+	if os.Getenv("CLUSTER_TLS_CA") != "" || os.Getenv("CLUSTER_TLS_CERT") != "" {
+		tlsConfig, err = utils.LoadClientTLS(os.Getenv("CLUSTER_TLS_CA"), os.Getenv("CLUSTER_TLS_CERT"), os.Getenv("CLUSTER_TLS_KEY"))
+		if err != nil {
+			logger.Fatalf("Failed to load cluster TLS configuration: %v", err)
+		}
+	}
+
+	token, err := utils.SecretEnv("CLUSTER_TOKEN")
+	if err != nil {
+		logger.Fatalf("Failed to read CLUSTER_TOKEN: %v", err)
+	}
+	if token == "" {
+		logger.Fatal("CLUSTER_TOKEN is required for worker nodes")
+	}
+	conn, err := cluster.Dial(addr, token, tlsConfig)
+	if err != nil {
+		logger.Fatalf("Failed to connect to coordinator: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		logger.Info("Shutdown signal received, stopping worker node...")
+		cancel()
+	}()
+
+	logger.WithField("coordinator", addr).
+		WithField("worker_id", id).
+		Info("Telegram Archive Bot worker node starting...")
+
+	agent := cluster.NewAgent(conn, id, *workDir, logger)
+	if err := agent.Run(ctx); err != nil && err != context.Canceled {
+		logger.WithError(err).Error("Worker node stopped with error")
+	}
+
+	logger.Info("Worker node stopped")
+}
+
+// formatCrashMessage formats a crash report for Telegram notification
+func formatCrashMessage(report *utils.CrashReport) string {
+	// Backticks in the panic value would break the Markdown code span
+	panicValue := strings.ReplaceAll(report.PanicValue, "`", "'")
+	if len(panicValue) > 500 {
+		panicValue = panicValue[:500] + "..."
+	}
+
+	message := fmt.Sprintf("💥 *Crash Report*\n\n"+
+		"🧩 Component: `%s`\n"+
+		"🆔 Report: `%s`\n", report.Component, report.ID[:8])
+	if report.TaskID != "" {
+		message += fmt.Sprintf("📋 Task: `%s`\n", report.TaskID)
+	}
+	message += fmt.Sprintf("🏷 Build: `%s` (schema %d)\n", report.Build, report.SchemaVersion)
+	message += fmt.Sprintf("🖥️ Host: `%s`\n"+
+		"🕐 Time: %s\n\n"+
+		"`%s`\n\n"+
+		"Full stack trace saved to crash_reports", report.Hostname, report.Timestamp.Format("2006-01-02 15:04:05"), panicValue)
+
+	return message
+}
+
+// reconciliationListLimit caps the entries listed per section of the
+// reconciliation message; the stored report has all of them
+const reconciliationListLimit = 5
+
+// formatReconciliationMessage summarizes a reconciliation report for admins
+func formatReconciliationMessage(report *storage.ReconciliationReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🧭 *Recovery Report* (%s)\n\n", report.Trigger)
+	fmt.Fprintf(&b, "🔎 Tasks examined: %d (%d ready for extraction, %d queued for download)\n",
+		report.TasksExamined, report.TasksReady, report.TasksQueued)
+
+	writeTasks := func(title string, tasks []storage.ReconciledTask) {
+		if len(tasks) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s: %d\n", title, len(tasks))
+		for i, task := range tasks {
+			if i == reconciliationListLimit {
+				fmt.Fprintf(&b, "  …and %d more\n", len(tasks)-i)
+				break
+			}
+			fmt.Fprintf(&b, "  • `%s` %s → %s: %s\n", task.TaskID, task.FromStatus, task.ToStatus, tgbotapi.EscapeText(tgbotapi.ModeMarkdown, task.Reason))
+		}
+	}
+	writeFiles := func(title string, count int, files []storage.ReconciledFile) {
+		if count == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s: %d\n", title, count)
+		for i, file := range files {
+			if i == reconciliationListLimit {
+				fmt.Fprintf(&b, "  …and %d more\n", count-i)
+				break
+			}
+			fmt.Fprintf(&b, "  • `%s` (%s)\n", strings.ReplaceAll(file.Path, "`", "'"), monitoring.FormatBytes(uint64(file.Size)))
+		}
+	}
+
+	writeTasks("♻️ Tasks reset", report.TasksReset)
+	writeTasks("🔗 Tasks re-linked to their files", report.TasksRelinked)
+	writeTasks("❌ Tasks failed", report.TasksFailed)
+	writeFiles("🧹 Orphaned files removed", report.OrphanedFileCount, report.OrphanedFiles)
+	writeFiles("☣️ Unaccounted files to review for quarantine", report.CandidateCount, report.QuarantineCandidates)
+	for _, err := range report.Errors {
+		fmt.Fprintf(&b, "\n⚠️ %s\n", tgbotapi.EscapeText(tgbotapi.ModeMarkdown, err))
+	}
+
+	fmt.Fprintf(&b, "\nFull report #%d saved to reconciliation\\_reports", report.ID)
+	return b.String()
 }
 
 // formatAlertMessage formats an alert for Telegram notification
@@ -167,6 +882,8 @@ func formatAlertMessage(alert *monitoring.Alert) string {
 		typeDescription = "High CPU Usage"
 	case monitoring.AlertTypeDiskSpace:
 		typeDescription = "Low Disk Space"
+	case monitoring.AlertTypeDiskIO:
+		typeDescription = "Slow Disk I/O"
 	case monitoring.AlertTypeQueueBackup:
 		typeDescription = "Queue Backup"
 	case monitoring.AlertTypeProcessFailure:
@@ -177,6 +894,16 @@ func formatAlertMessage(alert *monitoring.Alert) string {
 		typeDescription = "Component Down"
 	case monitoring.AlertTypeHighLoadAvg:
 		typeDescription = "High Load Average"
+	case monitoring.AlertTypeAdminAnomaly:
+		typeDescription = "Unusual Admin Behavior"
+	case monitoring.AlertTypeGoroutineStall:
+		typeDescription = "Stalled Goroutine"
+	case monitoring.AlertTypeGoroutineLeak:
+		typeDescription = "Goroutine Leak"
+	case monitoring.AlertTypeDegradation:
+		typeDescription = "Degradation"
+	case monitoring.AlertTypeMetricAnomaly:
+		typeDescription = "Metric Anomaly"
 	default:
 		typeDescription = string(alert.Type)
 	}
@@ -23,7 +23,11 @@ type Task struct {
 	FileType       string    `db:"file_type" json:"file_type"`
 	FileHash       string    `db:"file_hash" json:"file_hash"`
 	TelegramFileID string    `db:"telegram_file_id" json:"telegram_file_id"`
+	// TelegramFileUniqueID is the same for every forward of a file, unlike TelegramFileID
+	TelegramFileUniqueID string `db:"telegram_file_unique_id" json:"telegram_file_unique_id,omitempty"`
 	LocalAPIPath   string    `db:"local_api_path" json:"local_api_path,omitempty"`
+	// RetryOf is the task an upload was first submitted as, set on tasks created by /retry
+	RetryOf        string    `db:"retry_of" json:"retry_of,omitempty"`
 	Status         TaskStatus `db:"status" json:"status"`
 	ErrorMessage   string    `db:"error_message" json:"error_message,omitempty"`
 	ErrorCategory  string    `db:"error_category" json:"error_category,omitempty"`
@@ -33,8 +37,16 @@ type Task struct {
 	CreatedAt      time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
 	CompletedAt    *time.Time `db:"completed_at" json:"completed_at,omitempty"`
+	ShortID        string    `db:"-" json:"short_id,omitempty"`
 }
 
+// Task priorities, higher values are downloaded first
+const (
+	TaskPriorityLow    = -10
+	TaskPriorityNormal = 0
+	TaskPriorityHigh   = 10
+)
+
 func (t *Task) IsCompleted() bool {
 	return t.Status == TaskStatusCompleted || t.Status == TaskStatusFailed
 }
\ No newline at end of file
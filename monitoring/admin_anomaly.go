@@ -0,0 +1,258 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// AdminAnomalyPolicy sets which admin behavior is flagged as unusual
+type AdminAnomalyPolicy struct {
+	CheckInterval time.Duration
+	// Window is the recent period bursts and unusual hours are judged over
+	Window time.Duration
+	// DownloadBurst is the number of files one admin may send within Window
+	DownloadBurst int
+	// UnauthorizedAttempts is the number of refused messages from one user
+	// within Window that raises an alert; twice as many is critical
+	UnauthorizedAttempts int
+	// BaselineDays of history teach each admin's usual hours, once they have
+	// at least MinBaseline entries. An hour with less than UnusualHourShare
+	// of those entries is unusual
+	BaselineDays     int
+	MinBaseline      int
+	UnusualHourShare float64
+}
+
+// DefaultAdminAnomalyPolicy returns the policy used when nothing is configured
+func DefaultAdminAnomalyPolicy() AdminAnomalyPolicy {
+	return AdminAnomalyPolicy{
+		CheckInterval:        time.Minute,
+		Window:               10 * time.Minute,
+		DownloadBurst:        30,
+		UnauthorizedAttempts: 5,
+		BaselineDays:         30,
+		MinBaseline:          50,
+		UnusualHourShare:     0.01,
+	}
+}
+
+// adminBaselineRefresh is how often the usual hours are re-learned
+const adminBaselineRefresh = 6 * time.Hour
+
+// adminActiveActions are the audit actions an admin performs themselves
+var adminActiveActions = []storage.AdminAuditAction{storage.AdminActionCommand, storage.AdminActionFileUpload}
+
+// AdminAnomalyMonitor watches the admin audit log for bursts of downloads,
+// commands at hours an admin is normally inactive and repeated unauthorized
+// attempts, and raises security alerts for them
+type AdminAnomalyMonitor struct {
+	logger       *utils.Logger
+	audit        *storage.AdminAuditLogger
+	alertManager *AlertManager
+	policy       AdminAnomalyPolicy
+
+	// baseline counts each admin's entries per local hour of day
+	baseline   map[int64]*[24]int
+	baselineAt time.Time
+
+	// active are the components with a raised alert
+	active map[string]bool
+}
+
+// adminAnomaly is one flagged pattern
+type adminAnomaly struct {
+	component string
+	level     AlertLevel
+	title     string
+	message   string
+	metadata  map[string]interface{}
+}
+
+// NewAdminAnomalyMonitor creates a monitor over the admin audit log
+func NewAdminAnomalyMonitor(logger *utils.Logger, audit *storage.AdminAuditLogger,
+	alertManager *AlertManager, policy AdminAnomalyPolicy) *AdminAnomalyMonitor {
+	return &AdminAnomalyMonitor{
+		logger:       logger,
+		audit:        audit,
+		alertManager: alertManager,
+		policy:       policy,
+		active:       make(map[string]bool),
+	}
+}
+
+// Run checks admin behavior until ctx is cancelled
+func (m *AdminAnomalyMonitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.policy.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		utils.Heartbeat(ctx)
+		m.check(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// check raises an alert for each anomaly in the window and resolves the
+// alerts of anomalies that have passed
+func (m *AdminAnomalyMonitor) check(now time.Time) {
+	if now.Sub(m.baselineAt) >= adminBaselineRefresh {
+		if err := m.refreshBaseline(now); err != nil {
+			m.logger.WithError(err).Warn("Failed to learn usual admin hours")
+		}
+	}
+
+	since := now.Add(-m.policy.Window)
+	recent, err := m.audit.GetAdminActivity(since, storage.AdminActionCommand, storage.AdminActionFileUpload,
+		storage.AdminActionFileDownload, storage.AdminActionUnauthorized)
+	if err != nil {
+		m.logger.WithError(err).Warn("Failed to check admin activity")
+		return
+	}
+
+	firing := make(map[string]bool)
+	for _, anomaly := range m.detect(recent) {
+		firing[anomaly.component] = true
+		m.active[anomaly.component] = true
+		m.alertManager.RaiseAlert(AlertTypeAdminAnomaly, anomaly.level, anomaly.component,
+			anomaly.title, anomaly.message, anomaly.metadata)
+	}
+
+	for component := range m.active {
+		if firing[component] {
+			continue
+		}
+		delete(m.active, component)
+		if m.alertManager.ResolveComponentAlert(AlertTypeAdminAnomaly, component) {
+			m.logger.WithField("component", component).Info("Admin behavior back to normal")
+		}
+	}
+}
+
+// detect finds the anomalies in the activity of the window
+func (m *AdminAnomalyMonitor) detect(recent []storage.AdminActivity) []adminAnomaly {
+	downloads := make(map[int64]int)
+	unauthorized := make(map[int64]int)
+	unusualHours := make(map[int64]map[int]int)
+
+	for _, a := range recent {
+		switch a.Action {
+		case storage.AdminActionFileUpload, storage.AdminActionFileDownload:
+			downloads[a.UserID]++
+		case storage.AdminActionUnauthorized:
+			unauthorized[a.UserID]++
+			continue
+		}
+		if hour := a.Timestamp.Local().Hour(); m.unusualHour(a.UserID, hour) {
+			if unusualHours[a.UserID] == nil {
+				unusualHours[a.UserID] = make(map[int]int)
+			}
+			unusualHours[a.UserID][hour]++
+		}
+	}
+
+	window := fmt.Sprintf("%.0f minutes", m.policy.Window.Minutes())
+	var anomalies []adminAnomaly
+
+	for userID, count := range downloads {
+		if count < m.policy.DownloadBurst {
+			continue
+		}
+		anomalies = append(anomalies, adminAnomaly{
+			component: fmt.Sprintf("admin_%d_downloads", userID),
+			level:     AlertLevelWarning,
+			title:     "Burst of admin downloads",
+			message:   fmt.Sprintf("Admin %d sent %d files in the last %s (threshold %d)", userID, count, window, m.policy.DownloadBurst),
+			metadata:  map[string]interface{}{"user_id": userID, "files": count},
+		})
+	}
+
+	for userID, count := range unauthorized {
+		if count < m.policy.UnauthorizedAttempts {
+			continue
+		}
+		level := AlertLevelWarning
+		if count >= 2*m.policy.UnauthorizedAttempts {
+			level = AlertLevelCritical
+		}
+		anomalies = append(anomalies, adminAnomaly{
+			component: fmt.Sprintf("user_%d_unauthorized", userID),
+			level:     level,
+			title:     "Repeated unauthorized attempts",
+			message:   fmt.Sprintf("User %d was refused %d times in the last %s", userID, count, window),
+			metadata:  map[string]interface{}{"user_id": userID, "attempts": count},
+		})
+	}
+
+	for userID, hours := range unusualHours {
+		sorted := make([]int, 0, len(hours))
+		entries := 0
+		for hour, count := range hours {
+			sorted = append(sorted, hour)
+			entries += count
+		}
+		sort.Ints(sorted)
+		anomalies = append(anomalies, adminAnomaly{
+			component: fmt.Sprintf("admin_%d_hours", userID),
+			level:     AlertLevelWarning,
+			title:     "Admin active at an unusual hour",
+			message: fmt.Sprintf("Admin %d ran %d commands or uploads at %02d:00, when they are normally inactive",
+				userID, entries, sorted[0]),
+			metadata: map[string]interface{}{"user_id": userID, "entries": entries, "hours": sorted},
+		})
+	}
+
+	return anomalies
+}
+
+// unusualHour reports whether the admin rarely acts at the hour, judged only
+// once they have enough history
+func (m *AdminAnomalyMonitor) unusualHour(userID int64, hour int) bool {
+	hours := m.baseline[userID]
+	if hours == nil {
+		return false
+	}
+	total := 0
+	for _, count := range hours {
+		total += count
+	}
+	if total < m.policy.MinBaseline {
+		return false
+	}
+	return float64(hours[hour]) < m.policy.UnusualHourShare*float64(total)
+}
+
+// refreshBaseline learns each admin's usual hours from the history before the
+// current window, so the activity being judged doesn't vouch for itself
+func (m *AdminAnomalyMonitor) refreshBaseline(now time.Time) error {
+	since := now.AddDate(0, 0, -m.policy.BaselineDays)
+	history, err := m.audit.GetAdminActivity(since, adminActiveActions...)
+	if err != nil {
+		return err
+	}
+
+	windowStart := now.Add(-m.policy.Window)
+	baseline := make(map[int64]*[24]int)
+	for _, a := range history {
+		if !a.Timestamp.Before(windowStart) {
+			break
+		}
+		if baseline[a.UserID] == nil {
+			baseline[a.UserID] = &[24]int{}
+		}
+		baseline[a.UserID][a.Timestamp.Local().Hour()]++
+	}
+
+	m.baseline = baseline
+	m.baselineAt = now
+	return nil
+}
@@ -0,0 +1,279 @@
+package monitoring
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AlertRuleKind is how a rule decides to fire
+type AlertRuleKind string
+
+const (
+	// AlertRuleThreshold compares one metric with a threshold
+	AlertRuleThreshold AlertRuleKind = "threshold"
+	// AlertRuleComposite fires when all of its conditions hold
+	AlertRuleComposite AlertRuleKind = "composite"
+	// AlertRuleAnomaly fires when a metric is Threshold standard deviations
+	// away from its average over the Baseline window
+	AlertRuleAnomaly AlertRuleKind = "anomaly"
+)
+
+const (
+	// alertTrendWindow is how far back rising and falling conditions compare
+	alertTrendWindow = 5 * time.Minute
+	// alertRateWindow is the window rate metrics such as throughput are measured over
+	alertRateWindow = 15 * time.Minute
+	// alertHistoryWindow is how long metric samples are kept, and the longest
+	// anomaly baseline
+	alertHistoryWindow = 24 * time.Hour
+	// anomalyMinSamples is how many baseline samples an anomaly rule needs
+	// before it judges a value
+	anomalyMinSamples = 10
+	// maxAlertConditions caps the conditions of a composite rule
+	maxAlertConditions = 5
+)
+
+// Trend operators, holding when a metric changed by more than the threshold
+// over alertTrendWindow
+const (
+	OperatorRising  = "rising"
+	OperatorFalling = "falling"
+)
+
+// AlertCondition is one comparison of a composite rule
+type AlertCondition struct {
+	Metric    string  `json:"metric"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+}
+
+// String writes the condition the way ParseAlertConditions reads it
+func (c AlertCondition) String() string {
+	if c.Operator == OperatorRising || c.Operator == OperatorFalling {
+		if c.Threshold == 0 {
+			return c.Metric + ":" + c.Operator
+		}
+		return fmt.Sprintf("%s:%s:%g", c.Metric, c.Operator, c.Threshold)
+	}
+	return fmt.Sprintf("%s%s%g", c.Metric, c.Operator, c.Threshold)
+}
+
+// ParseAlertConditions reads conditions joined by &, each a comparison such
+// as queue_depth>50 or a trend such as throughput_per_hour:falling, with an
+// optional minimum change: queue_depth:rising:10
+func ParseAlertConditions(text string) ([]AlertCondition, error) {
+	var conditions []AlertCondition
+	for _, part := range strings.Split(text, "&") {
+		condition, err := parseAlertCondition(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
+
+func parseAlertCondition(text string) (AlertCondition, error) {
+	if metric, trend, ok := strings.Cut(text, ":"); ok {
+		condition := AlertCondition{Metric: strings.ToLower(metric)}
+		operator, change, hasChange := strings.Cut(trend, ":")
+		condition.Operator = strings.ToLower(operator)
+		if hasChange {
+			threshold, err := strconv.ParseFloat(change, 64)
+			if err != nil || threshold < 0 {
+				return condition, fmt.Errorf("the change of %q must be a number of at least 0", text)
+			}
+			condition.Threshold = threshold
+		}
+		return condition, condition.validate()
+	}
+
+	for _, operator := range []string{">=", "<=", ">", "<"} {
+		if metric, value, ok := strings.Cut(text, operator); ok {
+			threshold, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return AlertCondition{}, fmt.Errorf("the threshold of %q must be a number", text)
+			}
+			condition := AlertCondition{Metric: strings.ToLower(metric), Operator: operator, Threshold: threshold}
+			return condition, condition.validate()
+		}
+	}
+	return AlertCondition{}, fmt.Errorf("cannot read condition %q, write e.g. queue_depth>50 or queue_depth:rising", text)
+}
+
+// validate checks the condition's metric and operator
+func (c AlertCondition) validate() error {
+	if _, ok := alertMetrics[c.Metric]; !ok {
+		return fmt.Errorf("unknown metric %q", c.Metric)
+	}
+	if _, ok := alertOperators[c.Operator]; !ok && c.Operator != OperatorRising && c.Operator != OperatorFalling {
+		return fmt.Errorf("unknown operator %q, use >, >=, <, <=, rising or falling", c.Operator)
+	}
+	if (c.Operator == OperatorRising || c.Operator == OperatorFalling) && c.Threshold < 0 {
+		return fmt.Errorf("the change of a %s condition must be at least 0", c.Operator)
+	}
+	return nil
+}
+
+// evaluate reports whether the condition holds at the latest sample and
+// describes the values it compared
+func (c AlertCondition) evaluate(history *metricHistory) (string, bool) {
+	value, ok := history.latest(c.Metric)
+	if !ok {
+		return "", false
+	}
+
+	switch c.Operator {
+	case OperatorRising, OperatorFalling:
+		past, ok := history.before(c.Metric, alertTrendWindow)
+		if !ok {
+			return "", false
+		}
+		change := value - past
+		if c.Operator == OperatorFalling {
+			change = -change
+		}
+		return fmt.Sprintf("%s %s from %.2f to %.2f in %s", c.Metric, c.Operator, past, value, alertTrendWindow),
+			change > c.Threshold
+	default:
+		return fmt.Sprintf("%s is %.2f (%s %g)", c.Metric, value, c.Operator, c.Threshold),
+			alertOperators[c.Operator](value, c.Threshold)
+	}
+}
+
+// metricSample is a metric value read at one alert check
+type metricSample struct {
+	at    time.Time
+	value float64
+}
+
+// metricHistory keeps the values of every alert metric over
+// alertHistoryWindow, for trend, rate and anomaly rules
+type metricHistory struct {
+	mutex    sync.RWMutex
+	series   map[string][]metricSample
+	lastRead time.Time // Time of the latest check
+}
+
+func newMetricHistory() *metricHistory {
+	return &metricHistory{series: make(map[string][]metricSample)}
+}
+
+// record reads every metric available at now. Rate metrics are derived from
+// the samples of their base metric, so they are read after it
+func (h *metricHistory) record(now time.Time, snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.lastRead = now
+	for name, metric := range alertMetrics {
+		if metric.value == nil {
+			continue
+		}
+		if value, ok := metric.value(snapshot, metrics); ok {
+			h.append(name, now, value)
+		}
+	}
+	for name, metric := range alertMetrics {
+		if metric.rateOf == "" {
+			continue
+		}
+		if rate, ok := h.rate(metric.rateOf, now); ok {
+			h.append(name, now, rate)
+		}
+	}
+}
+
+// append adds a sample and drops the ones older than alertHistoryWindow
+func (h *metricHistory) append(name string, now time.Time, value float64) {
+	samples := append(h.series[name], metricSample{at: now, value: value})
+	cutoff := now.Add(-alertHistoryWindow)
+	drop := 0
+	for drop < len(samples) && samples[drop].at.Before(cutoff) {
+		drop++
+	}
+	if drop > 0 {
+		samples = append([]metricSample(nil), samples[drop:]...)
+	}
+	h.series[name] = samples
+}
+
+// rate is the hourly increase of a counter metric over alertRateWindow.
+// A counter that went down, e.g. as rows were purged, has no rate
+func (h *metricHistory) rate(name string, now time.Time) (float64, bool) {
+	samples := h.series[name]
+	if len(samples) < 2 || !samples[len(samples)-1].at.Equal(now) {
+		return 0, false
+	}
+	latest := samples[len(samples)-1]
+	past, ok := sampleBefore(samples, now.Add(-alertRateWindow))
+	if !ok || past.value > latest.value {
+		return 0, false
+	}
+	return (latest.value - past.value) / latest.at.Sub(past.at).Hours(), true
+}
+
+// latest returns the metric's value at the latest check, false when it was
+// not available then
+func (h *metricHistory) latest(name string) (float64, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	samples := h.series[name]
+	if len(samples) == 0 || !samples[len(samples)-1].at.Equal(h.lastRead) {
+		return 0, false
+	}
+	return samples[len(samples)-1].value, true
+}
+
+// before returns the metric's value ago before the latest check, false when
+// the history does not reach back that far
+func (h *metricHistory) before(name string, ago time.Duration) (float64, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	sample, ok := sampleBefore(h.series[name], h.lastRead.Add(-ago))
+	return sample.value, ok
+}
+
+// baseline returns the mean and standard deviation of the metric over the
+// window before the latest check, not counting the latest sample
+func (h *metricHistory) baseline(name string, window time.Duration) (mean, stddev float64, samples int) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	from := h.lastRead.Add(-window)
+	var values []float64
+	for _, sample := range h.series[name] {
+		if !sample.at.Before(from) && sample.at.Before(h.lastRead) {
+			values = append(values, sample.value)
+		}
+	}
+	if len(values) == 0 {
+		return 0, 0, 0
+	}
+
+	for _, value := range values {
+		mean += value
+	}
+	mean /= float64(len(values))
+	for _, value := range values {
+		stddev += (value - mean) * (value - mean)
+	}
+	stddev = math.Sqrt(stddev / float64(len(values)))
+	return mean, stddev, len(values)
+}
+
+// sampleBefore returns the newest sample taken at or before t
+func sampleBefore(samples []metricSample, t time.Time) (metricSample, bool) {
+	for i := len(samples) - 1; i >= 0; i-- {
+		if !samples[i].at.After(t) {
+			return samples[i], true
+		}
+	}
+	return metricSample{}, false
+}
@@ -0,0 +1,187 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram-archive-bot/utils"
+)
+
+// alertDigestCheckInterval is how often pending alerts are checked for a due digest
+const alertDigestCheckInterval = 30 * time.Second
+
+// alertDigestMaxGroups caps the alert groups listed in one digest message
+const alertDigestMaxGroups = 20
+
+// markdownEscaper escapes alert text, which often contains underscores, for
+// Telegram Markdown messages
+var markdownEscaper = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+
+// alertGroup aggregates the firings of one alert within a digest window
+type alertGroup struct {
+	alert     *Alert // Latest firing
+	count     int
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// AlertDigester batches alert notifications so repeated alerts within a window
+// arrive as one digest message instead of one message per firing. Critical
+// alerts are always delivered immediately
+type AlertDigester struct {
+	logger  *utils.Logger
+	window  time.Duration
+	send    func(text string)
+	format  func(alert *Alert) string
+	mutex   sync.Mutex
+	pending map[string]*alertGroup
+	started time.Time // First alert of the current window
+}
+
+// NewAlertDigester creates a digester that delivers through send; format renders
+// alerts sent on their own. A zero window delivers every alert immediately
+func NewAlertDigester(logger *utils.Logger, window time.Duration, send func(text string), format func(alert *Alert) string) *AlertDigester {
+	return &AlertDigester{
+		logger:  logger,
+		window:  window,
+		send:    send,
+		format:  format,
+		pending: make(map[string]*alertGroup),
+	}
+}
+
+// Notify is an AlertCallback that sends critical alerts right away and queues
+// everything else for the next digest
+func (d *AlertDigester) Notify(alert *Alert) {
+	if d.window <= 0 || alert.Level == AlertLevelCritical {
+		d.send(d.format(alert))
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+	if len(d.pending) == 0 {
+		d.started = now
+	}
+
+	key := fmt.Sprintf("%s_%s_%s", alert.Type, alert.Component, alert.Title)
+	group, exists := d.pending[key]
+	if !exists {
+		group = &alertGroup{firstSeen: now}
+		d.pending[key] = group
+	}
+	// The alert manager passes each firing as its own copy
+	group.alert = alert
+	group.count++
+	group.lastSeen = now
+}
+
+// Run sends a digest once the window of the first queued alert has passed,
+// until ctx is cancelled; queued alerts are flushed on the way out
+func (d *AlertDigester) Run(ctx context.Context) error {
+	interval := alertDigestCheckInterval
+	if d.window > 0 && d.window < interval {
+		interval = d.window
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		utils.Heartbeat(ctx)
+
+		select {
+		case <-ctx.Done():
+			d.Flush()
+			return nil
+		case <-ticker.C:
+		}
+
+		d.mutex.Lock()
+		due := len(d.pending) > 0 && time.Since(d.started) >= d.window
+		d.mutex.Unlock()
+		if due {
+			d.Flush()
+		}
+	}
+}
+
+// Flush sends everything queued so far. A single alert that fired once is
+// sent in its usual form rather than as a digest
+func (d *AlertDigester) Flush() {
+	d.mutex.Lock()
+	groups := make([]*alertGroup, 0, len(d.pending))
+	for _, group := range d.pending {
+		groups = append(groups, group)
+	}
+	started := d.started
+	d.pending = make(map[string]*alertGroup)
+	d.mutex.Unlock()
+
+	if len(groups) == 0 {
+		return
+	}
+	if len(groups) == 1 && groups[0].count == 1 {
+		d.send(d.format(groups[0].alert))
+		return
+	}
+
+	d.send(formatAlertDigest(groups, started, time.Now()))
+
+	firings := 0
+	for _, group := range groups {
+		firings += group.count
+	}
+	d.logger.WithField("alerts", len(groups)).
+		WithField("firings", firings).
+		Info("Sent alert digest")
+}
+
+// formatAlertDigest lists alert groups by severity, then by how often they fired
+func formatAlertDigest(groups []*alertGroup, started, now time.Time) string {
+	sort.Slice(groups, func(i, j int) bool {
+		if ri, rj := alertLevelRank(groups[i].alert.Level), alertLevelRank(groups[j].alert.Level); ri != rj {
+			return ri > rj
+		}
+		if groups[i].count != groups[j].count {
+			return groups[i].count > groups[j].count
+		}
+		return groups[i].firstSeen.Before(groups[j].firstSeen)
+	})
+
+	firings := 0
+	for _, group := range groups {
+		firings += group.count
+	}
+
+	var b strings.Builder
+	b.WriteString("📋 *Alert Digest*\n\n")
+	fmt.Fprintf(&b, "%d alerts fired %d times between %s and %s\n",
+		len(groups), firings, started.Format("15:04:05"), now.Format("15:04:05"))
+
+	for i, group := range groups {
+		if i >= alertDigestMaxGroups {
+			fmt.Fprintf(&b, "\n… and %d more", len(groups)-i)
+			break
+		}
+
+		alert := group.alert
+		emoji := "ℹ️"
+		if alert.Level == AlertLevelWarning {
+			emoji = "⚠️"
+		}
+		fmt.Fprintf(&b, "\n%s %s ×%d\n", emoji, markdownEscaper.Replace(alert.Title), group.count)
+		if alert.Component != "" {
+			fmt.Fprintf(&b, "🔧 %s\n", markdownEscaper.Replace(alert.Component))
+		}
+		fmt.Fprintf(&b, "🕐 First %s, last %s\n", group.firstSeen.Format("15:04:05"), group.lastSeen.Format("15:04:05"))
+		fmt.Fprintf(&b, "📝 %s\n", markdownEscaper.Replace(alert.Message))
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,342 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+const (
+	// alertOutboxCheckInterval is how often due notifications are looked for
+	// when no new one woke the outbox
+	alertOutboxCheckInterval = 15 * time.Second
+	// alertOutboxBatch caps the notifications attempted per check
+	alertOutboxBatch = 50
+	// alertOutboxRetryBase is the wait after the first failed attempt; it
+	// doubles with each further failure up to alertOutboxRetryMax
+	alertOutboxRetryBase = 30 * time.Second
+	alertOutboxRetryMax  = 10 * time.Minute
+	// alertOutboxRetention is how long delivered, failed over and expired
+	// notifications are kept
+	alertOutboxRetention = 7 * 24 * time.Hour
+	// alertOutboxPurgeInterval is how often they are purged
+	alertOutboxPurgeInterval = time.Hour
+)
+
+// NotificationChannel delivers alert notifications
+type NotificationChannel interface {
+	// Name identifies the channel; outbox entries are stored per name
+	Name() string
+	// Send delivers text to recipient. Channels that deliver to their own
+	// configured destination get an empty recipient
+	Send(recipient, text string) error
+}
+
+// channelHealth tracks the recent deliveries of one channel
+type channelHealth struct {
+	consecutiveFailures int
+	lastError           string
+}
+
+// AlertOutbox stores alert notifications before sending them, so a failed
+// delivery is retried instead of lost. Notifications go to every recipient
+// over the primary channel; once the primary failed failoverAfter times in a
+// row, they are handed to each failover channel instead. Notifications not
+// delivered within maxAge expire
+type AlertOutbox struct {
+	logger        *utils.Logger
+	taskStore     *storage.TaskStore
+	primary       NotificationChannel
+	recipients    []string
+	failover      []NotificationChannel
+	channels      map[string]NotificationChannel
+	failoverAfter int
+	maxAge        time.Duration
+	wake          chan struct{}
+	mutex         sync.Mutex
+	health        map[string]*channelHealth
+}
+
+// NewAlertOutbox creates an outbox delivering to recipients over primary and
+// falling back to the failover channels, which may be empty
+func NewAlertOutbox(logger *utils.Logger, taskStore *storage.TaskStore, primary NotificationChannel, recipients []string,
+	failover []NotificationChannel, failoverAfter int, maxAge time.Duration) *AlertOutbox {
+	o := &AlertOutbox{
+		logger:        logger,
+		taskStore:     taskStore,
+		primary:       primary,
+		recipients:    recipients,
+		failover:      failover,
+		channels:      map[string]NotificationChannel{primary.Name(): primary},
+		failoverAfter: failoverAfter,
+		maxAge:        maxAge,
+		wake:          make(chan struct{}, 1),
+		health:        make(map[string]*channelHealth),
+	}
+	for _, channel := range failover {
+		o.channels[channel.Name()] = channel
+	}
+	for name := range o.channels {
+		o.health[name] = &channelHealth{}
+	}
+	return o
+}
+
+// Enqueue stores a notification for every recipient and wakes the outbox.
+// When it cannot be stored, e.g. because the database is what failed, it
+// is sent right away over the primary channel instead
+func (o *AlertOutbox) Enqueue(text string) {
+	now := time.Now()
+	messageID := uuid.New().String()
+	entries := make([]*storage.AlertOutboxEntry, 0, len(o.recipients))
+	for _, recipient := range o.recipients {
+		entries = append(entries, &storage.AlertOutboxEntry{
+			MessageID: messageID,
+			Channel:   o.primary.Name(),
+			Recipient: recipient,
+			Text:      text,
+			CreatedAt: now,
+		})
+	}
+
+	if err := o.taskStore.EnqueueAlertNotifications(entries); err != nil {
+		o.logger.WithError(err).Error("Failed to store alert notification, sending it directly")
+		for _, recipient := range o.recipients {
+			if err := o.primary.Send(recipient, text); err != nil {
+				o.recordFailure(o.primary.Name(), err)
+				o.logger.WithError(err).
+					WithField("channel", o.primary.Name()).
+					WithField("recipient", recipient).
+					Error("Failed to send alert notification")
+				continue
+			}
+			o.recordSuccess(o.primary.Name())
+		}
+		return
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers due notifications until ctx is cancelled. Notifications still
+// pending then are delivered by the next run
+func (o *AlertOutbox) Run(ctx context.Context) error {
+	ticker := time.NewTicker(alertOutboxCheckInterval)
+	defer ticker.Stop()
+
+	var lastPurge time.Time
+	for {
+		utils.Heartbeat(ctx)
+
+		now := time.Now()
+		o.deliverDue(ctx, now)
+		if now.Sub(lastPurge) >= alertOutboxPurgeInterval {
+			if purged, err := o.taskStore.PurgeAlertNotifications(now.Add(-alertOutboxRetention)); err != nil {
+				o.logger.WithError(err).Warn("Failed to purge alert outbox")
+			} else if purged > 0 {
+				o.logger.WithField("purged", purged).Info("Purged old alert notifications")
+			}
+			lastPurge = now
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+// deliverDue attempts every due notification once
+func (o *AlertOutbox) deliverDue(ctx context.Context, now time.Time) {
+	entries, err := o.taskStore.GetDueAlertNotifications(now, alertOutboxBatch)
+	if err != nil {
+		o.logger.WithError(err).Warn("Failed to load due alert notifications")
+		return
+	}
+
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		utils.Heartbeat(ctx)
+		o.deliver(entry, now)
+	}
+}
+
+// deliver attempts one notification and records the outcome
+func (o *AlertOutbox) deliver(entry *storage.AlertOutboxEntry, now time.Time) {
+	log := o.logger.WithField("channel", entry.Channel).
+		WithField("message_id", entry.MessageID).
+		WithField("recipient", entry.Recipient)
+
+	if now.Sub(entry.CreatedAt) > o.maxAge {
+		if err := o.taskStore.CloseAlertNotification(entry.ID, storage.AlertOutboxExpired, entry.Attempts, entry.LastError); err != nil {
+			log.WithError(err).Warn("Failed to expire alert notification")
+		}
+		log.WithField("attempts", entry.Attempts).Error("Alert notification expired undelivered")
+		return
+	}
+
+	channel, ok := o.channels[entry.Channel]
+	if !ok {
+		// The failover channel was removed from the config since
+		if err := o.taskStore.CloseAlertNotification(entry.ID, storage.AlertOutboxExpired, entry.Attempts,
+			"channel is no longer configured"); err != nil {
+			log.WithError(err).Warn("Failed to expire alert notification")
+		}
+		log.Warn("Dropped alert notification for a channel that is no longer configured")
+		return
+	}
+
+	attempts := entry.Attempts + 1
+	sendErr := channel.Send(entry.Recipient, entry.Text)
+	if sendErr == nil {
+		o.recordSuccess(entry.Channel)
+		if err := o.taskStore.MarkAlertNotificationDelivered(entry.ID, attempts, time.Now()); err != nil {
+			log.WithError(err).Warn("Failed to mark alert notification delivered")
+		}
+		if attempts > 1 {
+			log.WithField("attempts", attempts).Info("Alert notification delivered after retrying")
+		}
+		return
+	}
+
+	failures := o.recordFailure(entry.Channel, sendErr)
+	log = log.WithError(sendErr).WithField("attempts", attempts)
+	if entry.Channel == o.primary.Name() && len(o.failover) > 0 &&
+		(attempts >= o.failoverAfter || failures >= o.failoverAfter) {
+		if err := o.failOver(entry, attempts, sendErr); err != nil {
+			log.WithError(err).Error("Failed to hand alert notification to the failover channels")
+		} else {
+			log.Warn("Alert notification handed to the failover channels")
+			return
+		}
+	}
+
+	next := now.Add(alertOutboxRetryDelay(attempts))
+	if err := o.taskStore.RetryAlertNotification(entry.ID, attempts, sendErr.Error(), next); err != nil {
+		log.WithError(err).Warn("Failed to reschedule alert notification")
+	}
+	log.WithField("next_attempt", next.Format(time.RFC3339)).Warn("Alert notification failed, will retry")
+}
+
+// failOver queues the notification on every failover channel, once per
+// message however many recipients it had, and closes the primary entry
+func (o *AlertOutbox) failOver(entry *storage.AlertOutboxEntry, attempts int, sendErr error) error {
+	text := fmt.Sprintf("⚠️ Sent here because %s delivery failed %d time(s): %s\n\n%s",
+		o.primary.Name(), attempts, sendErr, entry.Text)
+	now := time.Now()
+	copies := make([]*storage.AlertOutboxEntry, 0, len(o.failover))
+	for _, channel := range o.failover {
+		copies = append(copies, &storage.AlertOutboxEntry{
+			MessageID: entry.MessageID,
+			Channel:   channel.Name(),
+			Text:      text,
+			CreatedAt: now,
+		})
+	}
+	if err := o.taskStore.EnqueueAlertNotifications(copies); err != nil {
+		return err
+	}
+	if err := o.taskStore.CloseAlertNotification(entry.ID, storage.AlertOutboxFailedOver, attempts, sendErr.Error()); err != nil {
+		return err
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// alertOutboxRetryDelay is the wait before the next attempt after the given
+// number of failed ones
+func alertOutboxRetryDelay(attempts int) time.Duration {
+	delay := alertOutboxRetryBase
+	for i := 1; i < attempts && delay < alertOutboxRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > alertOutboxRetryMax {
+		delay = alertOutboxRetryMax
+	}
+	return delay
+}
+
+func (o *AlertOutbox) recordSuccess(channel string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	health := o.health[channel]
+	health.consecutiveFailures = 0
+}
+
+// recordFailure returns the channel's consecutive failures including this one
+func (o *AlertOutbox) recordFailure(channel string, err error) int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	health := o.health[channel]
+	health.consecutiveFailures++
+	health.lastError = err.Error()
+	return health.consecutiveFailures
+}
+
+// Name implements HealthChecker
+func (o *AlertOutbox) Name() string {
+	return "notifications"
+}
+
+// Check implements HealthChecker. Alert delivery is degraded while a channel
+// keeps failing and unhealthy when the primary does with nowhere to fail over
+func (o *AlertOutbox) Check(ctx context.Context) ComponentHealth {
+	start := time.Now()
+	result := ComponentHealth{Name: o.Name(), Status: HealthStatusHealthy, LastChecked: start}
+
+	var parts []string
+	o.mutex.Lock()
+	for _, channel := range append([]NotificationChannel{o.primary}, o.failover...) {
+		health := o.health[channel.Name()]
+		if health.consecutiveFailures == 0 {
+			parts = append(parts, channel.Name()+" ok")
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s failed %d time(s) in a row: %s", channel.Name(),
+			health.consecutiveFailures, health.lastError))
+		if health.consecutiveFailures < o.failoverAfter {
+			continue
+		}
+		if channel == o.primary && len(o.failover) == 0 {
+			result.Status = HealthStatusUnhealthy
+		} else if result.Status == HealthStatusHealthy {
+			result.Status = HealthStatusDegraded
+		}
+	}
+	o.mutex.Unlock()
+
+	counts, err := o.taskStore.CountAlertNotifications()
+	if err != nil {
+		parts = append(parts, "outbox unreadable: "+err.Error())
+		if result.Status == HealthStatusHealthy {
+			result.Status = HealthStatusDegraded
+		}
+	} else {
+		parts = append(parts, fmt.Sprintf("%d pending, %d expired", counts[storage.AlertOutboxPending],
+			counts[storage.AlertOutboxExpired]))
+	}
+
+	result.Message = strings.Join(parts, "; ")
+	result.ResponseTimeMs = time.Since(start).Milliseconds()
+	return result
+}
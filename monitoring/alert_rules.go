@@ -0,0 +1,618 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"telegram-archive-bot/storage"
+)
+
+// alertMetric is a value alert rules can be defined on
+type alertMetric struct {
+	description string
+	alertType   AlertType // Type of the alerts raised by threshold rules on the metric
+	// value reads the metric; false when it is not available yet
+	value func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) (float64, bool)
+	// rateOf names the counter metric this one is the hourly rate of, for
+	// metrics derived from the sample history instead of read directly
+	rateOf string
+}
+
+// diskUsedMetric is the metric whose threshold rules may compare each
+// monitored volume with its own threshold
+const diskUsedMetric = "disk_used_percent"
+
+// alertMetrics are the metrics rules can use, by name
+var alertMetrics = map[string]alertMetric{
+	"memory_mb": {description: "Go heap allocated, MB", alertType: AlertTypeHighMemory,
+		value: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) (float64, bool) {
+			if snapshot == nil {
+				return 0, false
+			}
+			return snapshot.Memory.AllocMB, true
+		}},
+	"cpu_percent": {description: "CPU utilization, %", alertType: AlertTypeHighCPU,
+		value: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) (float64, bool) {
+			if snapshot == nil {
+				return 0, false
+			}
+			return snapshot.CPU.TotalPercent, true
+		}},
+	diskUsedMetric: {description: "Space used on the fullest volume, %; rules may set a threshold per volume", alertType: AlertTypeDiskSpace,
+		value: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) (float64, bool) {
+			if snapshot == nil || len(snapshot.Disk) == 0 {
+				return 0, false
+			}
+			fullest := 0.0
+			for _, disk := range snapshot.Disk {
+				if disk.UsedPercent > fullest {
+					fullest = disk.UsedPercent
+				}
+			}
+			return fullest, true
+		}},
+	"disk_io_util_percent": {description: "Busy time of the busiest volume's device, %", alertType: AlertTypeDiskIO,
+		value: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) (float64, bool) {
+			if snapshot == nil || len(snapshot.DiskIO) == 0 {
+				return 0, false
+			}
+			busiest := 0.0
+			for _, io := range snapshot.DiskIO {
+				busiest = math.Max(busiest, io.UtilPercent)
+			}
+			return busiest, true
+		}},
+	"disk_io_latency_ms": {description: "Average I/O time on the slowest volume's device, ms", alertType: AlertTypeDiskIO,
+		value: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) (float64, bool) {
+			if snapshot == nil || len(snapshot.DiskIO) == 0 {
+				return 0, false
+			}
+			slowest := 0.0
+			for _, io := range snapshot.DiskIO {
+				slowest = math.Max(slowest, io.LatencyMs())
+			}
+			return slowest, true
+		}},
+	"queue_depth": {description: "Tasks waiting in the queue", alertType: AlertTypeQueueBackup,
+		value: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) (float64, bool) {
+			if metrics == nil {
+				return 0, false
+			}
+			return float64(metrics.GetQueueMetrics().QueueDepth), true
+		}},
+	"completed_tasks": {description: "Completed tasks in the database", alertType: AlertTypeQueueBackup,
+		value: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) (float64, bool) {
+			if metrics == nil {
+				return 0, false
+			}
+			return float64(metrics.GetQueueMetrics().CompletedTasks), true
+		}},
+	"throughput_per_hour": {description: "Tasks completed per hour over the last 15m", alertType: AlertTypeQueueBackup,
+		rateOf: "completed_tasks"},
+	"load_avg_5m": {description: "5-minute load average", alertType: AlertTypeHighLoadAvg,
+		value: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) (float64, bool) {
+			if snapshot == nil || len(snapshot.LoadAvg) < 2 {
+				return 0, false
+			}
+			return snapshot.LoadAvg[1], true
+		}},
+	"goroutines": {description: "Running goroutines", alertType: AlertTypeSystemFailure,
+		value: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) (float64, bool) {
+			if snapshot == nil {
+				return 0, false
+			}
+			return float64(snapshot.Process.Goroutines), true
+		}},
+	"failure_rate_percent": {description: "Failed share of processed tasks, %, from 10 tasks on", alertType: AlertTypeProcessFailure,
+		value: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) (float64, bool) {
+			if metrics == nil {
+				return 0, false
+			}
+			queueMetrics := metrics.GetQueueMetrics()
+			totalProcessed := queueMetrics.CompletedTasks + queueMetrics.FailedTasks
+			if totalProcessed < 10 { // Need at least 10 processed tasks
+				return 0, false
+			}
+			return float64(queueMetrics.FailedTasks) / float64(totalProcessed) * 100, true
+		}},
+}
+
+// AlertMetrics lists the metric names rules accept with their descriptions
+func AlertMetrics() map[string]string {
+	names := make(map[string]string, len(alertMetrics))
+	for name, metric := range alertMetrics {
+		names[name] = metric.description
+	}
+	return names
+}
+
+// alertOperators are the comparisons threshold rules and conditions accept
+// besides the rising and falling trends
+var alertOperators = map[string]func(value, threshold float64) bool{
+	">":  func(value, threshold float64) bool { return value > threshold },
+	">=": func(value, threshold float64) bool { return value >= threshold },
+	"<":  func(value, threshold float64) bool { return value < threshold },
+	"<=": func(value, threshold float64) bool { return value <= threshold },
+}
+
+// defaultAlertRules are the built-in rules every installation starts with
+func defaultAlertRules() []*AlertRule {
+	rules := []*AlertRule{
+		{Name: "high_memory_usage", Level: AlertLevelWarning, Metric: "memory_mb", Operator: ">", Threshold: 500,
+			Message: "High memory usage detected: %.1fMB allocated", Cooldown: 5 * time.Minute},
+		{Name: "critical_memory_usage", Level: AlertLevelCritical, Metric: "memory_mb", Operator: ">", Threshold: 1000,
+			Message: "Critical memory usage detected: %.1fMB allocated", Cooldown: 2 * time.Minute},
+		{Name: "high_cpu_usage", Level: AlertLevelWarning, Metric: "cpu_percent", Operator: ">", Threshold: 80,
+			Message: "High CPU usage detected: %.1f%% utilization", Cooldown: 3 * time.Minute},
+		{Name: "low_disk_space", Level: AlertLevelWarning, Metric: diskUsedMetric, Operator: ">", Threshold: 85,
+			Message: "Low disk space detected: %.1f%% used", Cooldown: 10 * time.Minute},
+		{Name: "queue_backup", Level: AlertLevelWarning, Metric: "queue_depth", Operator: ">", Threshold: 50,
+			Message: "Queue backup detected: %.0f items in queue", Cooldown: 5 * time.Minute},
+		{Name: "high_load_average", Level: AlertLevelWarning, Metric: "load_avg_5m", Operator: ">", Threshold: 2.0,
+			Message: "High system load average detected: %.2f (5min)", Cooldown: 5 * time.Minute},
+		{Name: "high_goroutine_count", Level: AlertLevelWarning, Metric: "goroutines", Operator: ">", Threshold: 1000,
+			Message: "High goroutine count detected: %.0f goroutines", Cooldown: 5 * time.Minute},
+		{Name: "high_failure_rate", Level: AlertLevelWarning, Metric: "failure_rate_percent", Operator: ">", Threshold: 25,
+			Message: "High failure rate detected: %.1f%% of tasks are failing", Cooldown: 10 * time.Minute},
+		// The queue grows while fewer tasks complete: processing is falling behind
+		{Name: "queue_stalling", Kind: AlertRuleComposite, Level: AlertLevelWarning, Conditions: []AlertCondition{
+			{Metric: "queue_depth", Operator: OperatorRising},
+			{Metric: "throughput_per_hour", Operator: OperatorFalling},
+		}, For: 10 * time.Minute, Cooldown: 30 * time.Minute},
+	}
+	for _, rule := range rules {
+		rule.Enabled = true
+		rule.Builtin = true
+		if err := rule.validate(); err != nil {
+			panic(fmt.Sprintf("invalid built-in alert rule %s: %v", rule.Name, err))
+		}
+	}
+	return rules
+}
+
+// validate checks a rule and fills in its kind and alert type
+func (r *AlertRule) validate() error {
+	if r.Name == "" || strings.ContainsAny(r.Name, " \t\n") {
+		return fmt.Errorf("rule name must be a single word")
+	}
+	if r.Kind == "" {
+		r.Kind = AlertRuleThreshold
+	}
+
+	switch r.Kind {
+	case AlertRuleThreshold:
+		condition := AlertCondition{Metric: r.Metric, Operator: r.Operator, Threshold: r.Threshold}
+		if err := condition.validate(); err != nil {
+			return err
+		}
+		r.Type = alertMetrics[r.Metric].alertType
+	case AlertRuleComposite:
+		if len(r.Conditions) == 0 || len(r.Conditions) > maxAlertConditions {
+			return fmt.Errorf("a composite rule needs 1 to %d conditions", maxAlertConditions)
+		}
+		for _, condition := range r.Conditions {
+			if err := condition.validate(); err != nil {
+				return err
+			}
+		}
+		r.Type = AlertTypeDegradation
+	case AlertRuleAnomaly:
+		if _, ok := alertMetrics[r.Metric]; !ok {
+			return fmt.Errorf("unknown metric %q", r.Metric)
+		}
+		if r.Operator != ">" && r.Operator != "<" {
+			return fmt.Errorf("an anomaly rule watches for values above (>) or below (<) the baseline")
+		}
+		if r.Threshold <= 0 {
+			return fmt.Errorf("the anomaly threshold is a number of standard deviations above 0")
+		}
+		if r.Baseline < 10*time.Minute || r.Baseline > alertHistoryWindow {
+			return fmt.Errorf("the baseline must be between 10m and %s", alertHistoryWindow)
+		}
+		r.Type = AlertTypeMetricAnomaly
+	default:
+		return fmt.Errorf("unknown rule kind %q", r.Kind)
+	}
+
+	if len(r.PathThresholds) > 0 && (r.Kind != AlertRuleThreshold || r.Metric != diskUsedMetric) {
+		return fmt.Errorf("only threshold rules on %s have per-volume thresholds", diskUsedMetric)
+	}
+	for path, threshold := range r.PathThresholds {
+		if path == "" || strings.ContainsAny(path, " \t\n=") {
+			return fmt.Errorf("volume name %q must be a single word", path)
+		}
+		if threshold < 0 || threshold > 100 {
+			return fmt.Errorf("the threshold of volume %s must be a percentage", path)
+		}
+	}
+
+	switch r.Level {
+	case AlertLevelInfo, AlertLevelWarning, AlertLevelCritical:
+	default:
+		return fmt.Errorf("unknown level %q", r.Level)
+	}
+	if r.Cooldown < time.Minute {
+		return fmt.Errorf("cooldown must be at least 1m")
+	}
+	if r.For < 0 || r.For > alertHistoryWindow {
+		return fmt.Errorf("for must be between 0 and %s", alertHistoryWindow)
+	}
+	return nil
+}
+
+// Describe writes the rule's condition, e.g. "queue_depth > 50"
+func (r *AlertRule) Describe() string {
+	var condition string
+	switch {
+	case r.Condition != nil:
+		condition = "custom condition"
+	case r.Kind == AlertRuleComposite:
+		parts := make([]string, 0, len(r.Conditions))
+		for _, c := range r.Conditions {
+			parts = append(parts, c.String())
+		}
+		condition = strings.Join(parts, " & ")
+	case r.Kind == AlertRuleAnomaly:
+		direction := "above"
+		if r.Operator == "<" {
+			direction = "below"
+		}
+		condition = fmt.Sprintf("%s %gσ %s its %s average", r.Metric, r.Threshold, direction, r.Baseline)
+	default:
+		condition = AlertCondition{Metric: r.Metric, Operator: r.Operator, Threshold: r.Threshold}.String()
+		if len(r.PathThresholds) > 0 {
+			paths := make([]string, 0, len(r.PathThresholds))
+			for path, threshold := range r.PathThresholds {
+				paths = append(paths, fmt.Sprintf("%s %s %g", path, r.Operator, threshold))
+			}
+			sort.Strings(paths)
+			condition += " (" + strings.Join(paths, ", ") + ")"
+		}
+	}
+	if r.For > 0 {
+		condition += fmt.Sprintf(" for %s", r.For)
+	}
+	return condition
+}
+
+// evaluate reports whether the rule's condition holds at the latest check
+// and the alert message if it does
+func (r *AlertRule) evaluate(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics, history *metricHistory) (string, bool) {
+	if r.Condition != nil {
+		return r.Message, r.Condition(snapshot, metrics)
+	}
+
+	switch r.Kind {
+	case AlertRuleComposite:
+		descriptions := make([]string, 0, len(r.Conditions))
+		for _, condition := range r.Conditions {
+			description, holds := condition.evaluate(history)
+			if !holds {
+				return "", false
+			}
+			descriptions = append(descriptions, description)
+		}
+		return fmt.Sprintf("%s (rule %s)", strings.Join(descriptions, " and "), r.Name), true
+
+	case AlertRuleAnomaly:
+		value, ok := history.latest(r.Metric)
+		mean, stddev, samples := history.baseline(r.Metric, r.Baseline)
+		// A flat baseline says nothing about how unusual a change is
+		if !ok || samples < anomalyMinSamples || stddev < 1e-9 {
+			return "", false
+		}
+		z := (value - mean) / stddev
+		if (r.Operator == ">" && z <= r.Threshold) || (r.Operator == "<" && z >= -r.Threshold) {
+			return "", false
+		}
+		direction := "above"
+		if z < 0 {
+			direction = "below"
+		}
+		return fmt.Sprintf("%s is %.2f, %.1f standard deviations %s its %s average of %.2f (rule %s)",
+			r.Metric, value, math.Abs(z), direction, r.Baseline, mean, r.Name), true
+
+	default:
+		if r.Metric == diskUsedMetric {
+			return r.evaluateDisk(snapshot)
+		}
+		condition := AlertCondition{Metric: r.Metric, Operator: r.Operator, Threshold: r.Threshold}
+		description, holds := condition.evaluate(history)
+		if !holds {
+			return "", false
+		}
+		if r.Message == "" {
+			return fmt.Sprintf("%s (rule %s)", description, r.Name), true
+		}
+		value, _ := history.latest(r.Metric)
+		return fmt.Sprintf(r.Message, value), true
+	}
+}
+
+// evaluateDisk compares each monitored volume with its threshold, the
+// rule's own one for volumes without a threshold of their own, and names
+// the volumes past it, fullest first
+func (r *AlertRule) evaluateDisk(snapshot *SystemResourceSnapshot) (string, bool) {
+	if snapshot == nil {
+		return "", false
+	}
+	type breach struct {
+		path      string
+		used      float64
+		threshold float64
+	}
+	var breaches []breach
+	for path, disk := range snapshot.Disk {
+		threshold, ok := r.PathThresholds[path]
+		if !ok {
+			threshold = r.Threshold
+		}
+		if alertOperators[r.Operator](disk.UsedPercent, threshold) {
+			breaches = append(breaches, breach{path: path, used: disk.UsedPercent, threshold: threshold})
+		}
+	}
+	if len(breaches) == 0 {
+		return "", false
+	}
+	sort.Slice(breaches, func(i, j int) bool {
+		if breaches[i].used != breaches[j].used {
+			return breaches[i].used > breaches[j].used
+		}
+		return breaches[i].path < breaches[j].path
+	})
+
+	volumes := make([]string, 0, len(breaches))
+	for _, b := range breaches {
+		volumes = append(volumes, fmt.Sprintf("%s %.1f%% (%s %g)", b.path, b.used, r.Operator, b.threshold))
+	}
+	if r.Message == "" {
+		return fmt.Sprintf("%s on %s (rule %s)", diskUsedMetric, strings.Join(volumes, ", "), r.Name), true
+	}
+	return fmt.Sprintf(r.Message, breaches[0].used) + " on " + strings.Join(volumes, ", "), true
+}
+
+// toRecord converts a rule to its stored form
+func (r *AlertRule) toRecord() (*storage.AlertRuleRecord, error) {
+	var conditions string
+	if len(r.Conditions) > 0 {
+		data, err := json.Marshal(r.Conditions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode conditions of alert rule %s: %w", r.Name, err)
+		}
+		conditions = string(data)
+	}
+	var pathThresholds string
+	if len(r.PathThresholds) > 0 {
+		data, err := json.Marshal(r.PathThresholds)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode volume thresholds of alert rule %s: %w", r.Name, err)
+		}
+		pathThresholds = string(data)
+	}
+	return &storage.AlertRuleRecord{
+		Name:           r.Name,
+		Kind:           string(r.Kind),
+		AlertType:      string(r.Type),
+		Level:          string(r.Level),
+		Metric:         r.Metric,
+		Operator:       r.Operator,
+		Threshold:      r.Threshold,
+		Conditions:     conditions,
+		PathThresholds: pathThresholds,
+		For:            r.For,
+		Baseline:       r.Baseline,
+		Message:        r.Message,
+		Cooldown:       r.Cooldown,
+		Enabled:        r.Enabled,
+		Builtin:        r.Builtin,
+		UpdatedAt:      time.Now(),
+	}, nil
+}
+
+// alertRuleFromRecord converts a stored rule back, checking it as if it was new
+func alertRuleFromRecord(record *storage.AlertRuleRecord) (*AlertRule, error) {
+	rule := &AlertRule{
+		Name:      record.Name,
+		Kind:      AlertRuleKind(record.Kind),
+		Level:     AlertLevel(record.Level),
+		Metric:    record.Metric,
+		Operator:  record.Operator,
+		Threshold: record.Threshold,
+		For:       record.For,
+		Baseline:  record.Baseline,
+		Message:   record.Message,
+		Cooldown:  record.Cooldown,
+		Enabled:   record.Enabled,
+		Builtin:   record.Builtin,
+		LastFired: record.LastFired,
+	}
+	if record.Conditions != "" {
+		if err := json.Unmarshal([]byte(record.Conditions), &rule.Conditions); err != nil {
+			return nil, fmt.Errorf("failed to decode conditions: %w", err)
+		}
+	}
+	if record.PathThresholds != "" {
+		if err := json.Unmarshal([]byte(record.PathThresholds), &rule.PathThresholds); err != nil {
+			return nil, fmt.Errorf("failed to decode volume thresholds: %w", err)
+		}
+	}
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// SetRuleStore persists the rules in store. Stored definitions
+// replace the built-in ones of the same name, built-in rules not stored yet
+// are saved, and each rule keeps the last time it fired so its cooldown
+// holds across restarts
+func (am *AlertManager) SetRuleStore(store *storage.TaskStore) error {
+	records, err := store.GetAlertRules()
+	if err != nil {
+		return err
+	}
+
+	am.mutex.Lock()
+	am.ruleStore = store
+	stored := make(map[string]bool, len(records))
+	for _, record := range records {
+		rule, err := alertRuleFromRecord(record)
+		if err != nil {
+			am.logger.WithError(err).WithField("rule_name", record.Name).Warn("Ignoring invalid stored alert rule")
+			continue
+		}
+		stored[rule.Name] = true
+		am.rules[rule.Name] = rule
+	}
+	var missing []*AlertRule
+	for _, rule := range am.rules {
+		if rule.Builtin && !stored[rule.Name] {
+			missing = append(missing, rule)
+		}
+	}
+	am.mutex.Unlock()
+
+	for _, rule := range missing {
+		record, err := rule.toRecord()
+		if err != nil {
+			return err
+		}
+		if err := store.SaveAlertRule(record); err != nil {
+			return err
+		}
+	}
+	am.logger.WithField("stored_rules", len(records)).
+		WithField("new_builtin_rules", len(missing)).
+		Info("Alert rules loaded")
+	return nil
+}
+
+// GetRules returns copies of the rules by name
+func (am *AlertManager) GetRules() []AlertRule {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+
+	rules := make([]AlertRule, 0, len(am.rules))
+	for _, rule := range am.rules {
+		rules = append(rules, rule.clone())
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules
+}
+
+// GetRule returns a copy of the named rule
+func (am *AlertManager) GetRule(name string) (AlertRule, bool) {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+
+	rule, ok := am.rules[name]
+	if !ok {
+		return AlertRule{}, false
+	}
+	return rule.clone(), true
+}
+
+// clone copies the rule so the copy's conditions and thresholds can be changed
+func (r *AlertRule) clone() AlertRule {
+	rule := *r
+	rule.Conditions = append([]AlertCondition(nil), r.Conditions...)
+	if r.PathThresholds != nil {
+		rule.PathThresholds = make(map[string]float64, len(r.PathThresholds))
+		for path, threshold := range r.PathThresholds {
+			rule.PathThresholds[path] = threshold
+		}
+	}
+	return rule
+}
+
+// SaveRule adds a rule or replaces the definition of the one with the same
+// name, keeping when it last fired and whether it is built in, and persists
+// it. A condition that was holding starts over
+func (am *AlertManager) SaveRule(rule AlertRule) error {
+	rule.Condition = nil
+	if err := rule.validate(); err != nil {
+		return err
+	}
+	record, err := rule.toRecord()
+	if err != nil {
+		return err
+	}
+
+	am.mutex.Lock()
+	if existing, ok := am.rules[rule.Name]; ok {
+		rule.LastFired = existing.LastFired
+		rule.Builtin = existing.Builtin
+		record.Builtin = existing.Builtin
+	}
+	delete(am.holdingSince, rule.Name)
+	store := am.ruleStore
+	am.mutex.Unlock()
+
+	if store != nil {
+		if err := store.SaveAlertRule(record); err != nil {
+			return err
+		}
+	}
+	am.AddRule(&rule)
+	return nil
+}
+
+// SetRuleEnabled enables or disables a rule, returning false if there is none
+func (am *AlertManager) SetRuleEnabled(name string, enabled bool) (bool, error) {
+	rule, ok := am.GetRule(name)
+	if !ok {
+		return false, nil
+	}
+	if rule.Condition != nil {
+		return true, fmt.Errorf("rule %s is defined in code and cannot be changed", name)
+	}
+	rule.Enabled = enabled
+	return true, am.SaveRule(rule)
+}
+
+// DeleteRule deletes a rule added at runtime, returning false if there is
+// none. Built-in rules can only be disabled
+func (am *AlertManager) DeleteRule(name string) (bool, error) {
+	rule, ok := am.GetRule(name)
+	if !ok {
+		return false, nil
+	}
+	if rule.Builtin || rule.Condition != nil {
+		return true, fmt.Errorf("rule %s is built in, disable it instead", name)
+	}
+
+	am.mutex.RLock()
+	store := am.ruleStore
+	am.mutex.RUnlock()
+	if store != nil {
+		if _, err := store.DeleteAlertRule(name); err != nil {
+			return true, err
+		}
+	}
+	am.RemoveRule(name)
+	return true, nil
+}
+
+// recordFired sets when a rule last fired and persists it. A rule edited
+// while it was being evaluated has its new definition updated too
+func (am *AlertManager) recordFired(rule *AlertRule, at time.Time) {
+	am.mutex.Lock()
+	rule.LastFired = at
+	current, ok := am.rules[rule.Name]
+	if ok {
+		current.LastFired = at
+	}
+	store := am.ruleStore
+	am.mutex.Unlock()
+
+	if store == nil || !ok || current.Condition != nil {
+		return
+	}
+	if err := store.SetAlertRuleLastFired(rule.Name, at); err != nil {
+		am.logger.WithError(err).WithField("rule_name", rule.Name).Warn("Failed to record alert rule firing")
+	}
+}
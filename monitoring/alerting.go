@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"telegram-archive-bot/storage"
 	"telegram-archive-bot/utils"
 )
 
@@ -26,11 +27,19 @@ const (
 	AlertTypeHighMemory     AlertType = "HIGH_MEMORY"
 	AlertTypeHighCPU        AlertType = "HIGH_CPU"
 	AlertTypeDiskSpace      AlertType = "DISK_SPACE"
+	AlertTypeDiskIO         AlertType = "DISK_IO"
 	AlertTypeQueueBackup    AlertType = "QUEUE_BACKUP"
 	AlertTypeProcessFailure AlertType = "PROCESS_FAILURE"
 	AlertTypeSystemFailure  AlertType = "SYSTEM_FAILURE"
 	AlertTypeComponentDown  AlertType = "COMPONENT_DOWN"
 	AlertTypeHighLoadAvg    AlertType = "HIGH_LOAD_AVERAGE"
+	AlertTypeDeadLetter     AlertType = "DEAD_LETTER"
+	AlertTypeAdminAnomaly   AlertType = "ADMIN_ANOMALY"
+	AlertTypeSelfUpdate     AlertType = "SELF_UPDATE"
+	AlertTypeGoroutineStall AlertType = "GOROUTINE_STALL"
+	AlertTypeGoroutineLeak  AlertType = "GOROUTINE_LEAK"
+	AlertTypeDegradation    AlertType = "DEGRADATION"
+	AlertTypeMetricAnomaly  AlertType = "METRIC_ANOMALY"
 )
 
 // Alert represents a system alert
@@ -49,48 +58,62 @@ type Alert struct {
 	LastSeen    time.Time              `json:"last_seen"`
 }
 
-// AlertRule represents conditions that trigger alerts
+// AlertRule represents conditions that trigger alerts. Kind decides how the
+// metrics are judged; a rule with a Condition evaluates that instead and is
+// not persisted
+
 type AlertRule struct {
-	Name        string                 `json:"name"`
-	Type        AlertType              `json:"type"`
-	Level       AlertLevel             `json:"level"`
-	Condition   func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) bool
-	Message     string                 `json:"message"`
-	Cooldown    time.Duration          `json:"cooldown"`
-	Enabled     bool                   `json:"enabled"`
-	LastFired   time.Time              `json:"last_fired"`
-	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	Name           string        `json:"name"`
+	Kind           AlertRuleKind `json:"kind"`
+	Type           AlertType     `json:"type"`
+	Level          AlertLevel    `json:"level"`
+	Condition      func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) bool
+	Metric         string                 `json:"metric,omitempty"`
+	Operator       string                 `json:"operator,omitempty"`
+	Threshold      float64                `json:"threshold,omitempty"`       // Anomaly rules: standard deviations
+	Conditions     []AlertCondition       `json:"conditions,omitempty"`      // Composite rules: all have to hold
+	PathThresholds map[string]float64     `json:"path_thresholds,omitempty"` // Disk rules: threshold per monitored volume, the others use Threshold
+	For            time.Duration          `json:"for,omitempty"`             // How long the condition has to hold before firing
+	Baseline       time.Duration          `json:"baseline,omitempty"`        // Anomaly rules: window of the rolling average
+	Message        string                 `json:"message"`
+	Cooldown       time.Duration          `json:"cooldown"`
+	Enabled        bool                   `json:"enabled"`
+	Builtin        bool                   `json:"builtin"`
+	LastFired      time.Time              `json:"last_fired"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
 }
 
 // AlertManager manages alerts and notifications
 type AlertManager struct {
 	logger          *utils.Logger
 	rules           map[string]*AlertRule
+	ruleStore       *storage.TaskStore
+	history         *metricHistory
+	holdingSince    map[string]time.Time // Since when each rule's condition has held
 	activeAlerts    map[string]*Alert
 	alertHistory    []*Alert
 	mutex           sync.RWMutex
 	notificationCh  chan *Alert
-	ctx             context.Context
-	cancel          context.CancelFunc
+	routines        *utils.Routines
 	alertCallbacks  []AlertCallback
 	maxHistorySize  int
 }
 
-// AlertCallback is called when an alert is triggered
+// AlertCallback is called when an alert is triggered, with a copy of the alert
+// taken when it was delivered
 type AlertCallback func(alert *Alert)
 
 // NewAlertManager creates a new alert manager
 func NewAlertManager(logger *utils.Logger) *AlertManager {
-	ctx, cancel := context.WithCancel(context.Background())
-	
 	am := &AlertManager{
 		logger:         logger,
 		rules:          make(map[string]*AlertRule),
+		history:        newMetricHistory(),
+		holdingSince:   make(map[string]time.Time),
 		activeAlerts:   make(map[string]*Alert),
 		alertHistory:   make([]*Alert, 0),
 		notificationCh: make(chan *Alert, 100),
-		ctx:            ctx,
-		cancel:         cancel,
+		routines:       utils.NewRoutines(context.Background()),
 		maxHistorySize: 1000,
 	}
 	
@@ -98,7 +121,7 @@ func NewAlertManager(logger *utils.Logger) *AlertManager {
 	am.setupDefaultRules()
 	
 	// Start the notification processor
-	go am.processNotifications()
+	am.routines.Go("alert_notifications", 0, am.processNotifications)
 	
 	logger.Info("Alert manager initialized with default rules")
 	return am
@@ -106,134 +129,9 @@ func NewAlertManager(logger *utils.Logger) *AlertManager {
 
 // setupDefaultRules configures default alerting rules
 func (am *AlertManager) setupDefaultRules() {
-	// High memory usage alert
-	am.AddRule(&AlertRule{
-		Name:  "high_memory_usage",
-		Type:  AlertTypeHighMemory,
-		Level: AlertLevelWarning,
-		Condition: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) bool {
-			return snapshot != nil && snapshot.Memory.AllocMB > 500 // 500MB threshold
-		},
-		Message:  "High memory usage detected: %.1fMB allocated",
-		Cooldown: 5 * time.Minute,
-		Enabled:  true,
-	})
-	
-	// Critical memory usage alert
-	am.AddRule(&AlertRule{
-		Name:  "critical_memory_usage",
-		Type:  AlertTypeHighMemory,
-		Level: AlertLevelCritical,
-		Condition: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) bool {
-			return snapshot != nil && snapshot.Memory.AllocMB > 1000 // 1GB threshold
-		},
-		Message:  "Critical memory usage detected: %.1fMB allocated",
-		Cooldown: 2 * time.Minute,
-		Enabled:  true,
-	})
-	
-	// High CPU usage alert
-	am.AddRule(&AlertRule{
-		Name:  "high_cpu_usage",
-		Type:  AlertTypeHighCPU,
-		Level: AlertLevelWarning,
-		Condition: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) bool {
-			return snapshot != nil && snapshot.CPU.TotalPercent > 80 // 80% threshold
-		},
-		Message:  "High CPU usage detected: %.1f%% utilization",
-		Cooldown: 3 * time.Minute,
-		Enabled:  true,
-	})
-	
-	// Disk space alert
-	am.AddRule(&AlertRule{
-		Name:  "low_disk_space",
-		Type:  AlertTypeDiskSpace,
-		Level: AlertLevelWarning,
-		Condition: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) bool {
-			if snapshot == nil || snapshot.Disk == nil {
-				return false
-			}
-			for _, disk := range snapshot.Disk {
-				if disk.UsedPercent > 85 { // 85% threshold
-					return true
-				}
-			}
-			return false
-		},
-		Message:  "Low disk space detected on one or more volumes",
-		Cooldown: 10 * time.Minute,
-		Enabled:  true,
-	})
-	
-	// Queue backup alert
-	am.AddRule(&AlertRule{
-		Name:  "queue_backup",
-		Type:  AlertTypeQueueBackup,
-		Level: AlertLevelWarning,
-		Condition: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) bool {
-			if metrics == nil {
-				return false
-			}
-			queueMetrics := metrics.GetQueueMetrics()
-			return queueMetrics.QueueDepth > 50 // 50 items threshold
-		},
-		Message:  "Queue backup detected: %d items in queue",
-		Cooldown: 5 * time.Minute,
-		Enabled:  true,
-	})
-	
-	// High load average alert (Linux)
-	am.AddRule(&AlertRule{
-		Name:  "high_load_average",
-		Type:  AlertTypeHighLoadAvg,
-		Level: AlertLevelWarning,
-		Condition: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) bool {
-			if snapshot == nil || len(snapshot.LoadAvg) == 0 {
-				return false
-			}
-			// Alert if 5-minute load average > 2.0
-			return snapshot.LoadAvg[1] > 2.0
-		},
-		Message:  "High system load average detected: %.2f (5min)",
-		Cooldown: 5 * time.Minute,
-		Enabled:  true,
-	})
-	
-	// Too many goroutines alert
-	am.AddRule(&AlertRule{
-		Name:  "high_goroutine_count",
-		Type:  AlertTypeSystemFailure,
-		Level: AlertLevelWarning,
-		Condition: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) bool {
-			return snapshot != nil && snapshot.Process.Goroutines > 1000 // 1000 goroutines threshold
-		},
-		Message:  "High goroutine count detected: %d goroutines",
-		Cooldown: 5 * time.Minute,
-		Enabled:  true,
-	})
-	
-	// Failed tasks accumulation alert
-	am.AddRule(&AlertRule{
-		Name:  "high_failure_rate",
-		Type:  AlertTypeProcessFailure,
-		Level: AlertLevelWarning,
-		Condition: func(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) bool {
-			if metrics == nil {
-				return false
-			}
-			queueMetrics := metrics.GetQueueMetrics()
-			totalProcessed := queueMetrics.CompletedTasks + queueMetrics.FailedTasks
-			if totalProcessed < 10 { // Need at least 10 processed tasks
-				return false
-			}
-			failureRate := float64(queueMetrics.FailedTasks) / float64(totalProcessed) * 100
-			return failureRate > 25 // 25% failure rate threshold
-		},
-		Message:  "High failure rate detected: %.1f%% of tasks are failing",
-		Cooldown: 10 * time.Minute,
-		Enabled:  true,
-	})
+	for _, rule := range defaultAlertRules() {
+		am.AddRule(rule)
+	}
 }
 
 // AddRule adds a new alert rule
@@ -282,18 +180,36 @@ func (am *AlertManager) CheckAlerts(snapshot *SystemResourceSnapshot, metrics *P
 	am.mutex.RUnlock()
 	
 	now := time.Now()
+	am.history.record(now, snapshot, metrics)
 	
 	for _, rule := range rules {
-		// Check cooldown
-		if !rule.LastFired.IsZero() && now.Sub(rule.LastFired) < rule.Cooldown {
+		// Evaluate condition; rules with a duration fire once it held that long
+		message, holds := rule.evaluate(snapshot, metrics, am.history)
+		am.mutex.Lock()
+		since, held := am.holdingSince[rule.Name]
+		switch {
+		case !holds:
+			delete(am.holdingSince, rule.Name)
+		case !held:
+			since = now
+			am.holdingSince[rule.Name] = now
+		}
+		lastFired := rule.LastFired
+		am.mutex.Unlock()
+		if !holds || now.Sub(since) < rule.For {
 			continue
 		}
+		if rule.For > 0 {
+			message += fmt.Sprintf(", for %s", now.Sub(since).Round(time.Second))
+		}
 		
-		// Evaluate condition
-		if rule.Condition(snapshot, metrics) {
-			am.triggerAlert(rule, snapshot, metrics)
-			rule.LastFired = now
+		// Check cooldown
+		if !lastFired.IsZero() && now.Sub(lastFired) < rule.Cooldown {
+			continue
 		}
+		
+		am.triggerAlert(rule, message)
+		am.recordFired(rule, now)
 	}
 	
 	// Check for alerts that should be auto-resolved
@@ -301,42 +217,9 @@ func (am *AlertManager) CheckAlerts(snapshot *SystemResourceSnapshot, metrics *P
 }
 
 // triggerAlert creates and processes a new alert
-func (am *AlertManager) triggerAlert(rule *AlertRule, snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) {
+func (am *AlertManager) triggerAlert(rule *AlertRule, message string) {
 	alertID := fmt.Sprintf("%s_%d", rule.Name, time.Now().Unix())
 	
-	// Prepare alert message with context-specific data
-	message := rule.Message
-	switch rule.Type {
-	case AlertTypeHighMemory:
-		if snapshot != nil {
-			message = fmt.Sprintf(rule.Message, snapshot.Memory.AllocMB)
-		}
-	case AlertTypeHighCPU:
-		if snapshot != nil {
-			message = fmt.Sprintf(rule.Message, snapshot.CPU.TotalPercent)
-		}
-	case AlertTypeQueueBackup:
-		if metrics != nil {
-			queueMetrics := metrics.GetQueueMetrics()
-			message = fmt.Sprintf(rule.Message, queueMetrics.QueueDepth)
-		}
-	case AlertTypeHighLoadAvg:
-		if snapshot != nil && len(snapshot.LoadAvg) > 1 {
-			message = fmt.Sprintf(rule.Message, snapshot.LoadAvg[1])
-		}
-	case AlertTypeSystemFailure:
-		if snapshot != nil {
-			message = fmt.Sprintf(rule.Message, snapshot.Process.Goroutines)
-		}
-	case AlertTypeProcessFailure:
-		if metrics != nil {
-			queueMetrics := metrics.GetQueueMetrics()
-			totalProcessed := queueMetrics.CompletedTasks + queueMetrics.FailedTasks
-			failureRate := float64(queueMetrics.FailedTasks) / float64(totalProcessed) * 100
-			message = fmt.Sprintf(rule.Message, failureRate)
-		}
-	}
-	
 	am.mutex.Lock()
 	defer am.mutex.Unlock()
 	
@@ -373,6 +256,92 @@ func (am *AlertManager) triggerAlert(rule *AlertRule, snapshot *SystemResourceSn
 	}
 }
 
+// RaiseAlert raises an alert that isn't driven by a rule, e.g. from the
+// component supervisor; repeated alerts for the same component are merged,
+// and an alert raised again at a higher level is escalated and re-sent
+func (am *AlertManager) RaiseAlert(alertType AlertType, level AlertLevel, component, title, message string, metadata map[string]interface{}) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	now := time.Now()
+	key := fmt.Sprintf("%s_%s", alertType, component)
+	if existingAlert, exists := am.activeAlerts[key]; exists {
+		existingAlert.Count++
+		existingAlert.LastSeen = now
+		existingAlert.Message = message
+		existingAlert.Metadata = metadata
+
+		if alertLevelRank(level) > alertLevelRank(existingAlert.Level) {
+			am.logger.WithField("alert_id", existingAlert.ID).
+				WithField("from_level", string(existingAlert.Level)).
+				WithField("to_level", string(level)).
+				Warn("Alert escalated")
+			existingAlert.Level = level
+			existingAlert.Title = title
+
+			select {
+			case am.notificationCh <- existingAlert:
+			default:
+				am.logger.Warn("Alert notification channel full, dropping alert")
+			}
+		}
+	} else {
+		alert := &Alert{
+			ID:        fmt.Sprintf("%s_%d", key, now.Unix()),
+			Type:      alertType,
+			Level:     level,
+			Title:     title,
+			Message:   message,
+			Timestamp: now,
+			Component: component,
+			Metadata:  metadata,
+			Count:     1,
+			LastSeen:  now,
+		}
+		am.activeAlerts[key] = alert
+		am.addToHistory(alert)
+
+		select {
+		case am.notificationCh <- alert:
+		default:
+			am.logger.Warn("Alert notification channel full, dropping alert")
+		}
+	}
+}
+
+// ResolveComponentAlert resolves the active alert raised with RaiseAlert for
+// the given type and component, returning false if there was none
+func (am *AlertManager) ResolveComponentAlert(alertType AlertType, component string) bool {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	key := fmt.Sprintf("%s_%s", alertType, component)
+	alert, exists := am.activeAlerts[key]
+	if !exists {
+		return false
+	}
+
+	now := time.Now()
+	alert.Resolved = true
+	alert.ResolvedAt = &now
+	delete(am.activeAlerts, key)
+
+	am.logger.WithField("alert_id", alert.ID).Info("Alert resolved")
+	return true
+}
+
+// alertLevelRank orders alert levels by severity
+func alertLevelRank(level AlertLevel) int {
+	switch level {
+	case AlertLevelCritical:
+		return 2
+	case AlertLevelWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
 // checkAutoResolve checks if any active alerts should be automatically resolved
 func (am *AlertManager) checkAutoResolve(snapshot *SystemResourceSnapshot, metrics *PerformanceMetrics) {
 	am.mutex.Lock()
@@ -395,11 +364,19 @@ func (am *AlertManager) checkAutoResolve(snapshot *SystemResourceSnapshot, metri
 }
 
 // processNotifications handles alert notifications
-func (am *AlertManager) processNotifications() {
+func (am *AlertManager) processNotifications(ctx context.Context) {
 	for {
 		select {
-		case <-am.ctx.Done():
-			return
+		case <-ctx.Done():
+			// Deliver what was raised before shutdown
+			for {
+				select {
+				case alert := <-am.notificationCh:
+					am.handleAlert(alert)
+				default:
+					return
+				}
+			}
 		case alert := <-am.notificationCh:
 			am.handleAlert(alert)
 		}
@@ -407,28 +384,36 @@ func (am *AlertManager) processNotifications() {
 }
 
 // handleAlert processes an alert by calling registered callbacks
-func (am *AlertManager) handleAlert(alert *Alert) {
+func (am *AlertManager) handleAlert(active *Alert) {
+	// Callbacks get a copy; the active alert keeps being updated under the lock
+	am.mutex.RLock()
+	snapshot := *active
+	alert := &snapshot
+	callbacks := make([]AlertCallback, len(am.alertCallbacks))
+	copy(callbacks, am.alertCallbacks)
+	am.mutex.RUnlock()
+
 	am.logger.WithField("alert_id", alert.ID).
 		WithField("alert_type", string(alert.Type)).
 		WithField("alert_level", string(alert.Level)).
 		WithField("alert_message", alert.Message).
 		Warn("Alert triggered")
 	
-	am.mutex.RLock()
-	callbacks := make([]AlertCallback, len(am.alertCallbacks))
-	copy(callbacks, am.alertCallbacks)
-	am.mutex.RUnlock()
-	
 	// Call all registered callbacks
 	for _, callback := range callbacks {
-		go func(cb AlertCallback) {
+		cb := callback
+		run := func() {
 			defer func() {
 				if r := recover(); r != nil {
 					am.logger.WithField("panic", r).Error("Alert callback panicked")
 				}
 			}()
 			cb(alert)
-		}(callback)
+		}
+		// While shutting down, callbacks run in turn so Shutdown waits for them
+		if !am.routines.Go("alert_callback", 0, func(context.Context) { run() }) {
+			run()
+		}
 	}
 }
 
@@ -528,9 +513,9 @@ func (am *AlertManager) GetAlertStats() map[string]interface{} {
 	return stats
 }
 
-// Stop shuts down the alert manager
-func (am *AlertManager) Stop() {
+// Shutdown stops the alert manager once queued alerts are delivered and
+// running callbacks have returned
+func (am *AlertManager) Shutdown(ctx context.Context) error {
 	am.logger.Info("Stopping alert manager")
-	am.cancel()
-	close(am.notificationCh)
+	return am.routines.Stop(ctx)
 }
\ No newline at end of file
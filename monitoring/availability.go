@@ -0,0 +1,86 @@
+package monitoring
+
+import (
+	"sync"
+	"time"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// availabilityGapFactor is how many missed health checks end the current
+// interval; the time since the last check then counts as unmonitored
+const availabilityGapFactor = 3
+
+// openInterval is the interval a component's latest health check extended
+type openInterval struct {
+	id        int64
+	status    HealthStatus
+	lastCheck time.Time
+}
+
+// AvailabilityTracker records each component's status over time as intervals
+// so uptime can be reported for periods longer than a single health check
+type AvailabilityTracker struct {
+	logger    *utils.Logger
+	taskStore *storage.TaskStore
+	maxGap    time.Duration
+	mutex     sync.Mutex
+	open      map[string]*openInterval
+}
+
+// NewAvailabilityTracker creates a tracker for health checks run every checkInterval
+func NewAvailabilityTracker(logger *utils.Logger, taskStore *storage.TaskStore, checkInterval time.Duration) *AvailabilityTracker {
+	return &AvailabilityTracker{
+		logger:    logger,
+		taskStore: taskStore,
+		maxGap:    availabilityGapFactor * checkInterval,
+		open:      make(map[string]*openInterval),
+	}
+}
+
+// SetCheckInterval changes how often components are expected to be checked
+func (at *AvailabilityTracker) SetCheckInterval(checkInterval time.Duration) {
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+	at.maxGap = availabilityGapFactor * checkInterval
+}
+
+// Record extends each component's current interval, or starts a new one when
+// its status changed or checks stopped for a while
+func (at *AvailabilityTracker) Record(check *HealthCheck) {
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+
+	for _, component := range check.Components {
+		now := component.LastChecked
+		if now.IsZero() {
+			now = check.Timestamp
+		}
+
+		current, ok := at.open[component.Name]
+		if ok && current.status == component.Status && now.Sub(current.lastCheck) <= at.maxGap {
+			if err := at.taskStore.ExtendAvailabilityInterval(current.id, now); err != nil {
+				at.logger.WithError(err).WithField("component", component.Name).Warn("Failed to record component availability")
+				continue
+			}
+			current.lastCheck = now
+			continue
+		}
+
+		// A status change closes the previous interval at this check so the two are contiguous
+		if ok && current.status != component.Status && now.Sub(current.lastCheck) <= at.maxGap {
+			if err := at.taskStore.ExtendAvailabilityInterval(current.id, now); err != nil {
+				at.logger.WithError(err).WithField("component", component.Name).Warn("Failed to close component availability interval")
+			}
+		}
+
+		id, err := at.taskStore.StartAvailabilityInterval(component.Name, string(component.Status), now)
+		if err != nil {
+			at.logger.WithError(err).WithField("component", component.Name).Warn("Failed to record component availability")
+			delete(at.open, component.Name)
+			continue
+		}
+		at.open[component.Name] = &openInterval{id: id, status: component.Status, lastCheck: now}
+	}
+}
@@ -0,0 +1,220 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// dailyReportName is the scheduled_reports name of the daily resource report
+const dailyReportName = "daily_resource_report"
+
+// dailyReportCheckInterval is how often the reporter looks for a due report
+const dailyReportCheckInterval = 5 * time.Minute
+
+// saturatedPercent is the CPU or disk busy time from which a health check
+// counts as saturated on that resource
+const saturatedPercent = 80.0
+
+// DailyResourceReport correlates a day's throughput with the CPU and disk
+// I/O recorded by the health checks, to tell CPU-bound from I/O-bound days
+type DailyResourceReport struct {
+	From   time.Time
+	To     time.Time
+	Checks int // Health checks in the history for the day
+	Tasks  *storage.TaskStats
+
+	AvgCPU           float64
+	PeakCPU          float64
+	AvgIOUtil        float64
+	PeakIOUtil       float64
+	AvgIOLatencyMs   float64
+	AvgReadBytesSec  float64
+	AvgWriteBytesSec float64
+	// Shares of the checks in which CPU or the busiest volume's device
+	// was saturated
+	CPUSaturated float64
+	IOSaturated  float64
+}
+
+// Bottleneck names the resource that held the pipeline back during the day
+func (r *DailyResourceReport) Bottleneck() string {
+	switch {
+	case r.Checks == 0:
+		return "unknown"
+	case r.CPUSaturated < 0.1 && r.IOSaturated < 0.1:
+		return "none"
+	case r.IOSaturated > r.CPUSaturated:
+		return "I/O-bound"
+	default:
+		return "CPU-bound"
+	}
+}
+
+// DailyReporter sends admins a daily report of the pipeline's throughput
+// next to the CPU and disk I/O of the same day
+type DailyReporter struct {
+	logger    *utils.Logger
+	taskStore *storage.TaskStore
+	hour      int
+	send      func(text string)
+}
+
+// NewDailyReporter creates a reporter that sends the report for the
+// preceding 24 hours at the given hour
+func NewDailyReporter(logger *utils.Logger, taskStore *storage.TaskStore, hour int, send func(text string)) *DailyReporter {
+	return &DailyReporter{
+		logger:    logger,
+		taskStore: taskStore,
+		hour:      hour,
+		send:      send,
+	}
+}
+
+// Run sends the report whenever it is due until ctx is cancelled
+func (dr *DailyReporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(dailyReportCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		utils.Heartbeat(ctx)
+		dr.check(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// check sends the report once the day's slot has passed without one
+func (dr *DailyReporter) check(now time.Time) {
+	slot := lastDailySlot(now, dr.hour)
+	lastSent, err := dr.taskStore.GetReportLastSent(dailyReportName)
+	if err != nil {
+		dr.logger.WithError(err).Warn("Failed to read daily report schedule")
+		return
+	}
+	if lastSent.IsZero() {
+		// First run: start the schedule at the next slot instead of sending immediately
+		if err := dr.taskStore.MarkReportSent(dailyReportName, slot); err != nil {
+			dr.logger.WithError(err).Warn("Failed to initialize daily report schedule")
+		}
+		return
+	}
+	if !lastSent.Before(slot) {
+		return
+	}
+
+	report, err := dr.BuildReport(slot.AddDate(0, 0, -1), slot)
+	if err != nil {
+		dr.logger.WithError(err).Warn("Failed to build daily report")
+		return
+	}
+	dr.send(formatDailyReport(report))
+
+	if err := dr.taskStore.MarkReportSent(dailyReportName, now); err != nil {
+		dr.logger.WithError(err).Warn("Failed to record daily report")
+	}
+	dr.logger.WithField("bottleneck", report.Bottleneck()).Info("Sent daily resource report")
+}
+
+// lastDailySlot returns the most recent occurrence of hour at or before now
+func lastDailySlot(now time.Time, hour int) time.Time {
+	slot := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -1)
+	}
+	return slot
+}
+
+// BuildReport averages the system info of the health checks recorded
+// between from and to and pairs it with the task statistics of that window
+func (dr *DailyReporter) BuildReport(from, to time.Time) (*DailyResourceReport, error) {
+	stats, err := dr.taskStore.GetTaskStats(from, to)
+	if err != nil {
+		return nil, err
+	}
+	records, err := dr.taskStore.GetHealthRecords(storage.HealthRecordCheck, from, true)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DailyResourceReport{From: from, To: to, Tasks: stats}
+	var cpuSaturated, ioSaturated int
+	for _, record := range records {
+		if !record.CheckedAt.Before(to) {
+			break
+		}
+		var check HealthCheck
+		if err := json.Unmarshal(record.Result, &check); err != nil {
+			continue
+		}
+		info := check.SystemInfo
+		report.Checks++
+		report.AvgCPU += info.CPUUsage
+		report.PeakCPU = math.Max(report.PeakCPU, info.CPUUsage)
+		report.AvgIOUtil += info.IOUtilPercent
+		report.PeakIOUtil = math.Max(report.PeakIOUtil, info.IOUtilPercent)
+		report.AvgIOLatencyMs += info.IOLatencyMs
+		report.AvgReadBytesSec += info.IOReadBytesPerSec
+		report.AvgWriteBytesSec += info.IOWriteBytesPerSec
+		if info.CPUUsage >= saturatedPercent {
+			cpuSaturated++
+		}
+		if info.IOUtilPercent >= saturatedPercent {
+			ioSaturated++
+		}
+	}
+	if report.Checks > 0 {
+		n := float64(report.Checks)
+		report.AvgCPU /= n
+		report.AvgIOUtil /= n
+		report.AvgIOLatencyMs /= n
+		report.AvgReadBytesSec /= n
+		report.AvgWriteBytesSec /= n
+		report.CPUSaturated = float64(cpuSaturated) / n
+		report.IOSaturated = float64(ioSaturated) / n
+	}
+	return report, nil
+}
+
+// formatDailyReport renders the report for Telegram
+func formatDailyReport(report *DailyResourceReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 *Daily Resource Report* (%s – %s)\n\n",
+		report.From.Format("Jan 2 15:04"), report.To.Format("Jan 2 15:04"))
+
+	fmt.Fprintf(&b, "*Throughput:* %d completed, %d failed, %.1f/h, %s processed\n",
+		report.Tasks.Completed, report.Tasks.Failed, report.Tasks.ThroughputPerHour(),
+		FormatBytes(uint64(report.Tasks.BytesProcessed)))
+	for _, stage := range report.Tasks.Stages {
+		if stage.Runs == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "• %s: p50 %s, p90 %s\n", stage.Stage,
+			stage.P50.Round(time.Second), stage.P90.Round(time.Second))
+	}
+
+	if report.Checks == 0 {
+		b.WriteString("\nNo health checks were recorded for the day.")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "\n*CPU:* avg %.1f%%, peak %.1f%%, saturated %.0f%% of the day\n",
+		report.AvgCPU, report.PeakCPU, report.CPUSaturated*100)
+	fmt.Fprintf(&b, "*Disk I/O:* avg %.1f%% busy, peak %.1f%%, saturated %.0f%% of the day\n",
+		report.AvgIOUtil, report.PeakIOUtil, report.IOSaturated*100)
+	fmt.Fprintf(&b, "   %.1f ms per I/O, read %s/s, write %s/s\n", report.AvgIOLatencyMs,
+		FormatBytes(uint64(report.AvgReadBytesSec)), FormatBytes(uint64(report.AvgWriteBytesSec)))
+
+	fmt.Fprintf(&b, "\n*Bottleneck:* %s (%d health checks)", report.Bottleneck(), report.Checks)
+	return b.String()
+}
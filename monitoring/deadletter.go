@@ -0,0 +1,251 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// dlqAlertComponent is the component name used for dead letter alerts
+const dlqAlertComponent = "dead_letter_queue"
+
+// dlqDigestReport is the scheduled_reports name of the weekly digest
+const dlqDigestReport = "dlq_weekly_digest"
+
+// DLQAlertPolicy sets when unresolved dead letter entries raise alerts
+type DLQAlertPolicy struct {
+	WarningAge     time.Duration
+	CriticalAge    time.Duration
+	WarningCount   int
+	CriticalCount  int
+	CheckInterval  time.Duration
+	DigestWeekday  time.Weekday
+	DigestHour     int
+	DigestMaxItems int
+}
+
+// DefaultDLQAlertPolicy returns the policy used when nothing is configured
+func DefaultDLQAlertPolicy() DLQAlertPolicy {
+	return DLQAlertPolicy{
+		WarningAge:     24 * time.Hour,
+		CriticalAge:    72 * time.Hour,
+		WarningCount:   5,
+		CriticalCount:  20,
+		CheckInterval:  5 * time.Minute,
+		DigestWeekday:  time.Monday,
+		DigestHour:     9,
+		DigestMaxItems: 10,
+	}
+}
+
+// DLQMonitor raises escalating alerts for dead letter entries that need manual
+// intervention and sends a weekly digest of everything still unresolved
+type DLQMonitor struct {
+	logger       *utils.Logger
+	dlq          *storage.DeadLetterQueue
+	taskStore    *storage.TaskStore
+	alertManager *AlertManager
+	policy       DLQAlertPolicy
+	sendDigest   func(text string)
+}
+
+// NewDLQMonitor creates a dead letter monitor; sendDigest delivers the weekly digest
+func NewDLQMonitor(logger *utils.Logger, dlq *storage.DeadLetterQueue, taskStore *storage.TaskStore,
+	alertManager *AlertManager, policy DLQAlertPolicy, sendDigest func(text string)) *DLQMonitor {
+	return &DLQMonitor{
+		logger:       logger,
+		dlq:          dlq,
+		taskStore:    taskStore,
+		alertManager: alertManager,
+		policy:       policy,
+		sendDigest:   sendDigest,
+	}
+}
+
+// Run checks the dead letter queue until ctx is cancelled
+func (m *DLQMonitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.policy.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		utils.Heartbeat(ctx)
+		m.check(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// check evaluates the alert thresholds and sends the digest when it is due
+func (m *DLQMonitor) check(now time.Time) {
+	entries, err := m.dlq.GetManualIntervention()
+	if err != nil {
+		m.logger.WithError(err).Warn("Failed to check dead letter queue")
+		return
+	}
+
+	m.evaluateAlert(entries, now)
+
+	if m.digestDue(now) {
+		m.sendWeeklyDigest(entries, now)
+	}
+}
+
+// evaluateAlert raises, escalates or resolves the dead letter alert
+func (m *DLQMonitor) evaluateAlert(entries []*storage.DeadLetterEntry, now time.Time) {
+	count := len(entries)
+	if count == 0 {
+		if m.alertManager.ResolveComponentAlert(AlertTypeDeadLetter, dlqAlertComponent) {
+			m.logger.Info("Dead letter queue cleared")
+		}
+		return
+	}
+
+	oldest := entries[0]
+	oldestAge := now.Sub(oldest.DeadLetterAt)
+	for _, entry := range entries {
+		if age := now.Sub(entry.DeadLetterAt); age > oldestAge {
+			oldestAge = age
+			oldest = entry
+		}
+	}
+
+	var level AlertLevel
+	switch {
+	case oldestAge >= m.policy.CriticalAge || count >= m.policy.CriticalCount:
+		level = AlertLevelCritical
+	case oldestAge >= m.policy.WarningAge || count >= m.policy.WarningCount:
+		level = AlertLevelWarning
+	default:
+		if m.alertManager.ResolveComponentAlert(AlertTypeDeadLetter, dlqAlertComponent) {
+			m.logger.Info("Dead letter queue back under alert thresholds")
+		}
+		return
+	}
+
+	message := fmt.Sprintf("%d dead letter entries need manual intervention; oldest is %s old (%s)",
+		count, formatAge(oldestAge), oldest.FileName)
+
+	m.alertManager.RaiseAlert(
+		AlertTypeDeadLetter,
+		level,
+		dlqAlertComponent,
+		"Unresolved dead letter entries",
+		message,
+		map[string]interface{}{
+			"count":           count,
+			"oldest_age":      oldestAge.Round(time.Minute).String(),
+			"oldest_entry_id": oldest.ID,
+		},
+	)
+}
+
+// digestDue reports whether this week's digest slot has passed without a digest
+func (m *DLQMonitor) digestDue(now time.Time) bool {
+	if m.sendDigest == nil {
+		return false
+	}
+
+	slot := lastDigestSlot(now, m.policy.DigestWeekday, m.policy.DigestHour)
+	lastSent, err := m.taskStore.GetReportLastSent(dlqDigestReport)
+	if err != nil {
+		m.logger.WithError(err).Warn("Failed to read dead letter digest schedule")
+		return false
+	}
+	if lastSent.IsZero() {
+		// First run: start the schedule at the next slot instead of sending immediately
+		if err := m.taskStore.MarkReportSent(dlqDigestReport, slot); err != nil {
+			m.logger.WithError(err).Warn("Failed to initialize dead letter digest schedule")
+		}
+		return false
+	}
+	return lastSent.Before(slot)
+}
+
+// lastDigestSlot returns the most recent weekday/hour slot at or before now
+func lastDigestSlot(now time.Time, weekday time.Weekday, hour int) time.Time {
+	daysBack := (int(now.Weekday()) - int(weekday) + 7) % 7
+	slot := time.Date(now.Year(), now.Month(), now.Day()-daysBack, hour, 0, 0, 0, now.Location())
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -7)
+	}
+	return slot
+}
+
+// sendWeeklyDigest summarizes unresolved entries by reason with the oldest first
+func (m *DLQMonitor) sendWeeklyDigest(entries []*storage.DeadLetterEntry, now time.Time) {
+	m.sendDigest(m.formatDigest(entries, now))
+
+	if err := m.taskStore.MarkReportSent(dlqDigestReport, now); err != nil {
+		m.logger.WithError(err).Warn("Failed to record dead letter digest")
+	}
+
+	m.logger.WithField("unresolved", len(entries)).Info("Sent weekly dead letter digest")
+}
+
+func (m *DLQMonitor) formatDigest(entries []*storage.DeadLetterEntry, now time.Time) string {
+	var b strings.Builder
+	b.WriteString("📬 *Weekly Dead Letter Digest*\n\n")
+
+	if len(entries) == 0 {
+		b.WriteString("✅ No dead letter entries need manual intervention.")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "⚠️ %d entries need manual intervention\n\n", len(entries))
+
+	byReason := make(map[storage.DeadLetterReason]int)
+	for _, entry := range entries {
+		byReason[entry.Reason]++
+	}
+	reasons := make([]string, 0, len(byReason))
+	for reason := range byReason {
+		reasons = append(reasons, string(reason))
+	}
+	sort.Strings(reasons)
+
+	b.WriteString("*By reason:*\n")
+	for _, reason := range reasons {
+		fmt.Fprintf(&b, "• %s: %d\n", reason, byReason[storage.DeadLetterReason(reason)])
+	}
+
+	sorted := make([]*storage.DeadLetterEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].DeadLetterAt.Before(sorted[j].DeadLetterAt)
+	})
+
+	b.WriteString("\n*Oldest unresolved:*\n")
+	for i, entry := range sorted {
+		if i >= m.policy.DigestMaxItems {
+			fmt.Fprintf(&b, "… and %d more\n", len(sorted)-i)
+			break
+		}
+		taskRef := entry.OriginalTaskID
+		if shortID, err := m.taskStore.GetShortID(entry.OriginalTaskID); err == nil {
+			taskRef = shortID
+		}
+		fmt.Fprintf(&b, "• `%s` %s — %s, %s old\n", taskRef, entry.FileName, entry.Reason,
+			formatAge(now.Sub(entry.DeadLetterAt)))
+	}
+
+	return b.String()
+}
+
+// formatAge renders an age in days and hours for alert messages
+func formatAge(age time.Duration) string {
+	days := int(age.Hours()) / 24
+	hours := int(age.Hours()) % 24
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh", days, hours)
+	}
+	return fmt.Sprintf("%dh %dm", hours, int(age.Minutes())%60)
+}
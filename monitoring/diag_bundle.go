@@ -0,0 +1,251 @@
+package monitoring
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yeka/zip"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// defaultBundleLogBytes is how much of the log's end a bundle includes by default
+const defaultBundleLogBytes = 5 << 20
+
+// Windows of the health history a bundle includes
+const (
+	bundleHealthCheckWindow = time.Hour
+	bundleDiagnosticsWindow = 24 * time.Hour
+)
+
+// DiagnosticsBundleOptions sets where a diagnostics bundle is written and
+// what goes into it
+type DiagnosticsBundleOptions struct {
+	Dir      string // Directory the bundle is written to, usually BACKUP_DIR
+	Password string // Encrypts every file; a random one is generated when empty
+	LogFile  string // Log whose end is included
+	LogBytes int64  // How much of the log is included, defaultBundleLogBytes when 0
+}
+
+// DiagnosticsBundle is a written diagnostics bundle
+type DiagnosticsBundle struct {
+	Path              string
+	Password          string
+	GeneratedPassword bool
+	Files             []string
+	// Problems lists the parts that could not be collected; the bundle is
+	// written without them
+	Problems []string
+}
+
+// bundlePart is one file of a bundle and how its contents are collected
+type bundlePart struct {
+	name    string
+	collect func(w io.Writer) error
+}
+
+// bundleManifest describes a bundle's contents, as manifest.json
+type bundleManifest struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Build     utils.BuildInfo `json:"build"`
+	Uptime    string          `json:"uptime"`
+	Files     []string        `json:"files"`
+	Problems  []string        `json:"problems,omitempty"`
+}
+
+// CreateDiagnosticsBundle runs the self-diagnostics and writes them with
+// the end of the log, the recent health checks, the configuration with
+// secrets masked, database statistics and disk usage into one AES-256
+// encrypted zip, for troubleshooting an installation remotely
+func (hm *HealthMonitor) CreateDiagnosticsBundle(opts DiagnosticsBundleOptions) (*DiagnosticsBundle, error) {
+	bundle := &DiagnosticsBundle{Password: opts.Password}
+	if bundle.Password == "" {
+		key := make([]byte, 12)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate bundle password: %w", err)
+		}
+		bundle.Password = hex.EncodeToString(key)
+		bundle.GeneratedPassword = true
+	}
+	if opts.LogBytes <= 0 {
+		opts.LogBytes = defaultBundleLogBytes
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+	now := time.Now()
+	bundle.Path = filepath.Join(opts.Dir, fmt.Sprintf("diag_bundle_%s.zip", now.Format("20060102_150405")))
+	tmpPath := bundle.Path + ".tmp"
+
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bundle: %w", err)
+	}
+	defer os.Remove(tmpPath)
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	add := func(name string, collect func(w io.Writer) error) error {
+		// Collect first, so a part that fails leaves nothing half written
+		var buf bytes.Buffer
+		if err := collect(&buf); err != nil {
+			bundle.Problems = append(bundle.Problems, fmt.Sprintf("%s: %v", name, err))
+			return nil
+		}
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		header.SetModTime(now)
+		header.SetPassword(bundle.Password)
+		header.SetEncryptionMethod(zip.AES256Encryption)
+		w, err := archive.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", name, err)
+		}
+		if _, err := buf.WriteTo(w); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		bundle.Files = append(bundle.Files, name)
+		return nil
+	}
+
+	parts := []bundlePart{
+		{"diagnostics/run.json", func(w io.Writer) error {
+			suite, err := hm.RunDiagnostics()
+			if err != nil {
+				return err
+			}
+			return writeBundleJSON(w, suite)
+		}},
+		{"diagnostics/history.json", hm.bundleHistory(storage.HealthRecordDiagnostics, now.Add(-bundleDiagnosticsWindow))},
+		{"health/last_check.json", func(w io.Writer) error {
+			check := hm.GetLastHealthCheck()
+			if check == nil {
+				return fmt.Errorf("no health check has completed yet")
+			}
+			return writeBundleJSON(w, check)
+		}},
+		{"health/checks.json", hm.bundleHistory(storage.HealthRecordCheck, now.Add(-bundleHealthCheckWindow))},
+		{"config.env", utils.WriteEffectiveConfig},
+		{"database.json", func(w io.Writer) error {
+			if hm.taskStore == nil {
+				return fmt.Errorf("task store not initialized")
+			}
+			stats, err := hm.taskStore.GetDatabaseStats()
+			if err != nil {
+				return err
+			}
+			return writeBundleJSON(w, stats)
+		}},
+		{"disk.json", hm.bundleDiskUsage},
+	}
+	if opts.LogFile != "" {
+		parts = append(parts, bundlePart{"logs/" + filepath.Base(opts.LogFile), func(w io.Writer) error {
+			return writeLogTail(w, opts.LogFile, opts.LogBytes)
+		}})
+	}
+	for _, part := range parts {
+		if err := add(part.name, part.collect); err != nil {
+			return nil, err
+		}
+	}
+
+	manifest := bundleManifest{
+		CreatedAt: now,
+		Build:     utils.CurrentBuild(),
+		Uptime:    hm.GetUptime().Round(time.Second).String(),
+		Files:     bundle.Files,
+		Problems:  bundle.Problems,
+	}
+	if err := add("manifest.json", func(w io.Writer) error { return writeBundleJSON(w, manifest) }); err != nil {
+		return nil, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish bundle: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close bundle: %w", err)
+	}
+	if err := os.Rename(tmpPath, bundle.Path); err != nil {
+		return nil, fmt.Errorf("failed to move bundle into place: %w", err)
+	}
+
+	hm.logger.WithField("file", bundle.Path).
+		WithField("files", len(bundle.Files)).
+		WithField("problems", len(bundle.Problems)).
+		Info("Diagnostics bundle written")
+	return bundle, nil
+}
+
+// bundleHistory collects the full health history records of a kind since a time
+func (hm *HealthMonitor) bundleHistory(kind string, since time.Time) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		if hm.taskStore == nil {
+			return fmt.Errorf("task store not initialized")
+		}
+		records, err := hm.taskStore.GetHealthRecords(kind, since, true)
+		if err != nil {
+			return err
+		}
+		return writeBundleJSON(w, records)
+	}
+}
+
+// bundleDiskUsage writes the monitored volumes with their usage and I/O
+// from the last system snapshot
+func (hm *HealthMonitor) bundleDiskUsage(w io.Writer) error {
+	snapshot := hm.GetLastSystemSnapshot()
+	if snapshot == nil {
+		return fmt.Errorf("no system snapshot has been taken yet")
+	}
+	return writeBundleJSON(w, struct {
+		Timestamp time.Time              `json:"timestamp"`
+		Paths     map[string]string      `json:"paths"`
+		Usage     map[string]DiskStats   `json:"usage"`
+		IO        map[string]DiskIOStats `json:"io"`
+	}{snapshot.Timestamp, hm.systemMonitor.diskPaths, snapshot.Disk, snapshot.DiskIO})
+}
+
+// writeBundleJSON writes v as indented JSON
+func writeBundleJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeLogTail writes up to limit bytes from the end of a log file,
+// starting at a whole line
+func writeLogTail(w io.Writer, path string, limit int64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(file)
+	if info.Size() > limit {
+		if _, err := file.Seek(info.Size()-limit, io.SeekStart); err != nil {
+			return err
+		}
+		reader.Reset(file)
+		// Drop the partial line the window starts in
+		if _, err := reader.ReadString('\n'); err != nil && err != io.EOF {
+			return err
+		}
+	}
+	_, err = io.Copy(w, reader)
+	return err
+}
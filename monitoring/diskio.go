@@ -0,0 +1,159 @@
+package monitoring
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// diskSectorSize is the unit of the sector counts in /proc/diskstats,
+// whatever the device's real sector size
+const diskSectorSize = 512
+
+// DiskIOStats is the I/O of the block device holding a monitored volume,
+// averaged over the interval since the previous snapshot
+type DiskIOStats struct {
+	Device           string  `json:"device"`
+	ReadBytesPerSec  float64 `json:"read_bytes_per_sec"`
+	WriteBytesPerSec float64 `json:"write_bytes_per_sec"`
+	ReadsPerSec      float64 `json:"reads_per_sec"`
+	WritesPerSec     float64 `json:"writes_per_sec"`
+	ReadLatencyMs    float64 `json:"read_latency_ms"`  // Average time a read took
+	WriteLatencyMs   float64 `json:"write_latency_ms"` // Average time a write took
+	UtilPercent      float64 `json:"util_percent"`     // Share of the interval the device was busy
+}
+
+// LatencyMs is the average time an I/O took, reads and writes together
+func (s DiskIOStats) LatencyMs() float64 {
+	ops := s.ReadsPerSec + s.WritesPerSec
+	if ops == 0 {
+		return 0
+	}
+	return (s.ReadLatencyMs*s.ReadsPerSec + s.WriteLatencyMs*s.WritesPerSec) / ops
+}
+
+// diskCounters are one device's cumulative counters from /proc/diskstats
+type diskCounters struct {
+	name           string
+	reads          uint64
+	sectorsRead    uint64
+	readMs         uint64
+	writes         uint64
+	sectorsWritten uint64
+	writeMs        uint64
+	ioMs           uint64
+}
+
+// getDiskIOStats attributes the I/O of each monitored volume's block device
+// since the previous call. Volumes on devices without counters, such as
+// overlay or tmpfs mounts, are left out, as is everything on the first call
+func (srm *SystemResourceMonitor) getDiskIOStats() (map[string]DiskIOStats, error) {
+	counters, err := readDiskCounters()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	previous, elapsed := srm.lastDiskIO, now.Sub(srm.lastDiskIOCheck).Seconds()
+	srm.lastDiskIO, srm.lastDiskIOCheck = counters, now
+
+	stats := make(map[string]DiskIOStats)
+	if previous == nil || elapsed <= 0 {
+		return stats, nil
+	}
+	for name, path := range srm.diskPaths {
+		device, err := blockDevice(path)
+		if err != nil {
+			continue
+		}
+		current, ok := counters[device]
+		past, hadPast := previous[device]
+		// Counters that went back belong to a device that was replaced
+		if !ok || !hadPast || current.reads < past.reads || current.writes < past.writes || current.ioMs < past.ioMs {
+			continue
+		}
+		stats[name] = diskIODelta(current, past, elapsed)
+	}
+	return stats, nil
+}
+
+// diskIODelta turns two readings of a device's counters elapsed seconds
+// apart into rates and latencies
+func diskIODelta(current, past diskCounters, elapsed float64) DiskIOStats {
+	reads := float64(current.reads - past.reads)
+	writes := float64(current.writes - past.writes)
+	stats := DiskIOStats{
+		Device:           current.name,
+		ReadBytesPerSec:  float64(current.sectorsRead-past.sectorsRead) * diskSectorSize / elapsed,
+		WriteBytesPerSec: float64(current.sectorsWritten-past.sectorsWritten) * diskSectorSize / elapsed,
+		ReadsPerSec:      reads / elapsed,
+		WritesPerSec:     writes / elapsed,
+		UtilPercent:      float64(current.ioMs-past.ioMs) / (elapsed * 1000) * 100,
+	}
+	if reads > 0 {
+		stats.ReadLatencyMs = float64(current.readMs-past.readMs) / reads
+	}
+	if writes > 0 {
+		stats.WriteLatencyMs = float64(current.writeMs-past.writeMs) / writes
+	}
+	if stats.UtilPercent > 100 {
+		stats.UtilPercent = 100
+	}
+	return stats
+}
+
+// readDiskCounters reads /proc/diskstats (Linux only), keyed by major:minor
+func readDiskCounters() (map[string]diskCounters, error) {
+	file, err := os.Open("/proc/diskstats")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open /proc/diskstats: %w", err)
+	}
+	defer file.Close()
+
+	counters := make(map[string]diskCounters)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 14 {
+			continue
+		}
+		values := make([]uint64, 11)
+		for i := range values {
+			values[i], _ = strconv.ParseUint(fields[i+3], 10, 64)
+		}
+		counters[fields[0]+":"+fields[1]] = diskCounters{
+			name:           fields[2],
+			reads:          values[0],
+			sectorsRead:    values[2],
+			readMs:         values[3],
+			writes:         values[4],
+			sectorsWritten: values[6],
+			writeMs:        values[7],
+			ioMs:           values[9],
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read /proc/diskstats: %w", err)
+	}
+	return counters, nil
+}
+
+// blockDevice returns the major:minor of the device holding path, or of its
+// nearest existing parent
+func blockDevice(path string) (string, error) {
+	existing, err := existingPath(path)
+	if err != nil {
+		return "", err
+	}
+	var stat syscall.Stat_t
+	if err := syscall.Stat(existing, &stat); err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", existing, err)
+	}
+	dev := uint64(stat.Dev)
+	return fmt.Sprintf("%d:%d", unix.Major(dev), unix.Minor(dev)), nil
+}
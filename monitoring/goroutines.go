@@ -0,0 +1,144 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"telegram-archive-bot/utils"
+)
+
+// goroutineDriftComponent is the component of goroutine leak alerts
+const goroutineDriftComponent = "goroutines"
+
+// GoroutinePolicy sets when background goroutines are reported
+type GoroutinePolicy struct {
+	CheckInterval time.Duration
+	// LeakMargin is how many goroutines may run beyond the registered ones,
+	// for the runtime, connection pools and request handlers
+	LeakMargin int
+	// LeakSustain is how long the count has to stay above the margin before
+	// it counts as a leak rather than a burst of work
+	LeakSustain time.Duration
+}
+
+// DefaultGoroutinePolicy returns the policy used when nothing is configured
+func DefaultGoroutinePolicy() GoroutinePolicy {
+	return GoroutinePolicy{
+		CheckInterval: time.Minute,
+		LeakMargin:    500,
+		LeakSustain:   10 * time.Minute,
+	}
+}
+
+// GoroutineMonitor alerts when registered background goroutines stop
+// heartbeating and when the process runs far more goroutines than are
+// registered, which points at a leak
+type GoroutineMonitor struct {
+	logger       *utils.Logger
+	alertManager *AlertManager
+	registry     *utils.GoroutineRegistry
+	policy       GoroutinePolicy
+
+	// driftSince is when the count first exceeded the margin, zero when it doesn't
+	driftSince time.Time
+
+	// active are the components with a raised alert
+	active map[string]bool
+}
+
+// NewGoroutineMonitor creates a monitor over the goroutine registry
+func NewGoroutineMonitor(logger *utils.Logger, alertManager *AlertManager, registry *utils.GoroutineRegistry, policy GoroutinePolicy) *GoroutineMonitor {
+	return &GoroutineMonitor{
+		logger:       logger,
+		alertManager: alertManager,
+		registry:     registry,
+		policy:       policy,
+		active:       make(map[string]bool),
+	}
+}
+
+// Run checks the goroutines until ctx is cancelled
+func (m *GoroutineMonitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.policy.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		utils.Heartbeat(ctx)
+		m.check(time.Now(), runtime.NumGoroutine())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// check raises an alert for each stalled goroutine name and for sustained
+// drift, and resolves the alerts of conditions that have cleared
+func (m *GoroutineMonitor) check(now time.Time, total int) {
+	firing := make(map[string]bool)
+
+	stalled := make(map[string][]utils.GoroutineInfo)
+	for _, info := range m.registry.Stale() {
+		stalled[info.Name] = append(stalled[info.Name], info)
+	}
+	for name, infos := range stalled {
+		oldest := infos[0].LastHeartbeat
+		for _, info := range infos[1:] {
+			if info.LastHeartbeat.Before(oldest) {
+				oldest = info.LastHeartbeat
+			}
+		}
+		firing[name] = true
+		m.active[name] = true
+		m.alertManager.RaiseAlert(AlertTypeGoroutineStall, AlertLevelWarning, name,
+			"Background goroutine stalled",
+			fmt.Sprintf("%d %s goroutine(s) stopped heartbeating, last heartbeat %s ago (limit %s)",
+				len(infos), name, now.Sub(oldest).Round(time.Second), infos[0].StaleAfter),
+			map[string]interface{}{
+				"instances":      len(infos),
+				"last_heartbeat": oldest,
+				"stale_after":    infos[0].StaleAfter.String(),
+			})
+	}
+
+	registered := m.registry.Len()
+	if excess := total - registered; excess > m.policy.LeakMargin {
+		if m.driftSince.IsZero() {
+			m.driftSince = now
+		}
+		if now.Sub(m.driftSince) >= m.policy.LeakSustain {
+			firing[goroutineDriftComponent] = true
+			m.active[goroutineDriftComponent] = true
+			m.alertManager.RaiseAlert(AlertTypeGoroutineLeak, AlertLevelWarning, goroutineDriftComponent,
+				"Possible goroutine leak",
+				fmt.Sprintf("%d goroutines running for %d registered ones, %d above the margin of %d for %s",
+					total, registered, excess-m.policy.LeakMargin, m.policy.LeakMargin, now.Sub(m.driftSince).Round(time.Second)),
+				map[string]interface{}{
+					"goroutines":  total,
+					"registered":  registered,
+					"leak_margin": m.policy.LeakMargin,
+					"since":       m.driftSince,
+				})
+		}
+	} else {
+		m.driftSince = time.Time{}
+	}
+
+	for component := range m.active {
+		if firing[component] {
+			continue
+		}
+		delete(m.active, component)
+		alertType := AlertTypeGoroutineStall
+		if component == goroutineDriftComponent {
+			alertType = AlertTypeGoroutineLeak
+		}
+		if m.alertManager.ResolveComponentAlert(alertType, component) {
+			m.logger.WithField("component", component).Info("Goroutines back to normal")
+		}
+	}
+}
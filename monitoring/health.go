@@ -2,10 +2,12 @@ package monitoring
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
@@ -38,14 +40,28 @@ type HealthCheck struct {
 	Uptime     time.Duration     `json:"uptime"`
 	Components []ComponentHealth `json:"components"`
 	SystemInfo SystemInfo        `json:"system_info"`
+	// Build and SchemaVersion tie the check to the exact code and database
+	Build         utils.BuildInfo `json:"build"`
+	SchemaVersion int             `json:"schema_version"`
 }
 
 // SystemInfo contains system resource information
 type SystemInfo struct {
-	CPUUsage      float64 `json:"cpu_usage_percent"`
+	CPUUsage      float64 `json:"cpu_usage_percent"` // Whole machine, over the interval since the previous check
 	MemoryUsage   float64 `json:"memory_usage_mb"`
 	MemoryPercent float64 `json:"memory_usage_percent"`
+	// Disk figures are of the fullest filesystem holding the monitored
+	// directories, named by DiskPath as in SystemResourceSnapshot.Disk
+	DiskPath      string  `json:"disk_path,omitempty"`
 	DiskUsage     int64   `json:"disk_usage_bytes"`
+	DiskPercent   float64 `json:"disk_usage_percent"`
+	DiskFree      int64   `json:"disk_free_bytes"`
+	// I/O figures are of the busiest volume's device, named by IOVolume
+	IOVolume           string  `json:"io_volume,omitempty"`
+	IOUtilPercent      float64 `json:"io_util_percent"`
+	IOLatencyMs        float64 `json:"io_latency_ms"`
+	IOReadBytesPerSec  float64 `json:"io_read_bytes_per_sec"`
+	IOWriteBytesPerSec float64 `json:"io_write_bytes_per_sec"`
 	Goroutines    int     `json:"goroutines"`
 	StartTime     time.Time `json:"start_time"`
 }
@@ -64,6 +80,7 @@ type DiagnosticResult struct {
 type DiagnosticSuite struct {
 	Timestamp   time.Time           `json:"timestamp"`
 	Duration    time.Duration       `json:"duration"`
+	Suites      []string            `json:"suites,omitempty"` // Empty when every suite ran
 	Results     []DiagnosticResult  `json:"results"`
 	OverallStatus HealthStatus      `json:"overall_status"`
 }
@@ -76,14 +93,16 @@ type HealthMonitor struct {
 	metrics            *PerformanceMetrics
 	systemMonitor      *SystemResourceMonitor
 	alertManager       *AlertManager
+	availability       *AvailabilityTracker
+	historySize        int
 	components         map[string]HealthChecker
 	lastCheck          *HealthCheck
 	lastSystemSnapshot *SystemResourceSnapshot
 	lastDiagnostics    *DiagnosticSuite
 	checkMutex         sync.RWMutex
-	checkInterval      time.Duration
-	ctx                context.Context
-	cancel             context.CancelFunc
+	policy             HealthCheckPolicy
+	checkerStates      map[string]*checkerState
+	routines           *utils.Routines
 }
 
 // HealthChecker interface for individual component health checks
@@ -94,8 +113,6 @@ type HealthChecker interface {
 
 // NewHealthMonitor creates a new health monitor
 func NewHealthMonitor(logger *utils.Logger, taskStore *storage.TaskStore) *HealthMonitor {
-	ctx, cancel := context.WithCancel(context.Background())
-	
 	hm := &HealthMonitor{
 		startTime:     time.Now(),
 		logger:        logger,
@@ -104,9 +121,26 @@ func NewHealthMonitor(logger *utils.Logger, taskStore *storage.TaskStore) *Healt
 		systemMonitor: NewSystemResourceMonitor(logger),
 		alertManager:  NewAlertManager(logger),
 		components:    make(map[string]HealthChecker),
-		checkInterval: 30 * time.Second, // Check every 30 seconds
-		ctx:           ctx,
-		cancel:        cancel,
+		policy:        DefaultHealthCheckPolicy(),
+		checkerStates: make(map[string]*checkerState),
+		historySize:   DefaultHealthHistorySize,
+		routines:      utils.NewRoutines(context.Background()),
+	}
+
+	// CPU and disk I/O usage are measured between two readings; take the
+	// first now so the first health check already reports them
+	if _, err := hm.systemMonitor.getCPUStats(); err != nil {
+		logger.WithError(err).Debug("Failed to read initial CPU stats")
+	}
+	if _, err := hm.systemMonitor.getDiskIOStats(); err != nil {
+		logger.WithError(err).Debug("Failed to read initial disk I/O stats")
+	}
+
+	if taskStore != nil {
+		hm.availability = NewAvailabilityTracker(logger, taskStore, hm.policy.Interval)
+		if err := hm.alertManager.SetRuleStore(taskStore); err != nil {
+			logger.WithError(err).Warn("Failed to load stored alert rules, using the built-in ones")
+		}
 	}
 
 	// Register built-in health checkers
@@ -127,30 +161,38 @@ func (hm *HealthMonitor) RegisterChecker(checker HealthChecker) {
 // Start begins periodic health checks
 func (hm *HealthMonitor) Start() {
 	hm.logger.Info("Starting health monitor")
-	
-	// Perform initial health check
+	hm.routines.Go("health_monitor", 0, func(ctx context.Context) { hm.Run(ctx) })
+}
+
+// Run performs an initial and then periodic health checks until ctx or the monitor is stopped
+func (hm *HealthMonitor) Run(ctx context.Context) error {
 	hm.performHealthCheck()
-	
-	// Start periodic checks
-	ticker := time.NewTicker(hm.checkInterval)
-	go func() {
-		defer ticker.Stop()
-		for {
-			select {
-			case <-hm.ctx.Done():
-				hm.logger.Info("Health monitor stopped")
-				return
-			case <-ticker.C:
-				hm.performHealthCheck()
-			}
+
+	timer := time.NewTimer(hm.nextCheckDelay())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			hm.logger.Info("Health monitor stopped")
+			return ctx.Err()
+		case <-hm.routines.Context().Done():
+			hm.logger.Info("Health monitor stopped")
+			return nil
+		case <-timer.C:
+			utils.Heartbeat(ctx)
+			hm.performHealthCheck()
+			utils.Heartbeat(ctx)
+			timer.Reset(hm.nextCheckDelay())
 		}
-	}()
+	}
 }
 
-// Stop stops the health monitor
-func (hm *HealthMonitor) Stop() {
+// Shutdown stops the health monitor, waiting for a running check or
+// self-diagnostics, and then its alert manager
+func (hm *HealthMonitor) Shutdown(ctx context.Context) error {
 	hm.logger.Info("Stopping health monitor")
-	hm.cancel()
+	return errors.Join(hm.routines.Stop(ctx), hm.alertManager.Shutdown(ctx))
 }
 
 // GetUptime returns the application uptime
@@ -195,23 +237,36 @@ func (hm *HealthMonitor) GetLastHealthCheck() *HealthCheck {
 // performHealthCheck runs all registered health checkers
 func (hm *HealthMonitor) performHealthCheck() {
 	start := time.Now()
+
+	// Capture system resource snapshot first; the check reports its CPU and disk usage
+	if systemSnapshot, err := hm.systemMonitor.GetSystemSnapshot(); err == nil {
+		hm.checkMutex.Lock()
+		hm.lastSystemSnapshot = systemSnapshot
+		hm.checkMutex.Unlock()
+		hm.metrics.UpdateSystemMetrics(systemSnapshot)
+	} else {
+		hm.logger.WithError(err).Debug("Failed to capture system snapshot")
+	}
 	
 	healthCheck := &HealthCheck{
 		Timestamp:  start,
 		Uptime:     hm.GetUptime(),
 		Components: make([]ComponentHealth, 0, len(hm.components)),
-		SystemInfo: hm.getSystemInfo(),
+		SystemInfo: hm.getSystemInfo(hm.GetLastSystemSnapshot()),
+		Build:      utils.CurrentBuild(),
+	}
+	if hm.taskStore != nil {
+		if version, err := hm.taskStore.SchemaVersion(); err == nil {
+			healthCheck.SchemaVersion = version
+		}
 	}
 
 	overallStatus := HealthStatusHealthy
 	
 	// Check all registered components
 	for _, checker := range hm.components {
-		checkStart := time.Now()
-		componentHealth := checker.Check(hm.ctx)
-		componentHealth.ResponseTimeMs = time.Since(checkStart).Milliseconds()
-		componentHealth.LastChecked = time.Now()
-		
+		componentHealth := hm.runChecker(checker)
+
 		healthCheck.Components = append(healthCheck.Components, componentHealth)
 		
 		// Determine overall status (worst case wins)
@@ -228,6 +283,12 @@ func (hm *HealthMonitor) performHealthCheck() {
 	hm.checkMutex.Lock()
 	hm.lastCheck = healthCheck
 	hm.checkMutex.Unlock()
+
+	// Record component up/down intervals for SLA reporting and keep the check for trend views
+	if hm.availability != nil {
+		hm.availability.Record(healthCheck)
+	}
+	hm.recordHealthCheck(healthCheck)
 	
 	// Log the results
 	duration := time.Since(start)
@@ -241,15 +302,6 @@ func (hm *HealthMonitor) performHealthCheck() {
 		hm.updateQueueMetrics()
 	}
 	
-	// Capture system resource snapshot
-	if systemSnapshot, err := hm.systemMonitor.GetSystemSnapshot(); err == nil {
-		hm.checkMutex.Lock()
-		hm.lastSystemSnapshot = systemSnapshot
-		hm.checkMutex.Unlock()
-	} else {
-		hm.logger.WithError(err).Debug("Failed to capture system snapshot")
-	}
-	
 	// Check alerts based on current system state
 	hm.alertManager.CheckAlerts(hm.lastSystemSnapshot, hm.metrics)
 	
@@ -264,7 +316,7 @@ func (hm *HealthMonitor) performHealthCheck() {
 	}
 	
 	if shouldRunDiagnostics {
-		go hm.RunSelfDiagnostics()
+		hm.routines.Go("self_diagnostics", 0, func(context.Context) { hm.RunSelfDiagnostics() })
 	}
 	
 	// Log any unhealthy components
@@ -278,17 +330,43 @@ func (hm *HealthMonitor) performHealthCheck() {
 	}
 }
 
-// getSystemInfo collects current system resource information
-func (hm *HealthMonitor) getSystemInfo() SystemInfo {
+// getSystemInfo collects current system resource information, taking CPU,
+// disk usage and disk I/O from the snapshot when there is one
+func (hm *HealthMonitor) getSystemInfo(snapshot *SystemResourceSnapshot) SystemInfo {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 	
-	return SystemInfo{
+	info := SystemInfo{
 		MemoryUsage:   float64(m.Alloc) / 1024 / 1024, // Convert to MB
 		MemoryPercent: float64(m.Alloc) / float64(m.Sys) * 100,
 		Goroutines:    runtime.NumGoroutine(),
 		StartTime:     hm.startTime,
 	}
+	if snapshot == nil {
+		return info
+	}
+
+	info.CPUUsage = snapshot.CPU.TotalPercent
+	for path, disk := range snapshot.Disk {
+		if info.DiskPath == "" || disk.UsedPercent > info.DiskPercent ||
+			(disk.UsedPercent == info.DiskPercent && path < info.DiskPath) {
+			info.DiskPath = path
+			info.DiskUsage = int64(disk.UsedBytes)
+			info.DiskPercent = disk.UsedPercent
+			info.DiskFree = int64(disk.AvailableBytes)
+		}
+	}
+	for volume, io := range snapshot.DiskIO {
+		if info.IOVolume == "" || io.UtilPercent > info.IOUtilPercent ||
+			(io.UtilPercent == info.IOUtilPercent && volume < info.IOVolume) {
+			info.IOVolume = volume
+			info.IOUtilPercent = io.UtilPercent
+			info.IOLatencyMs = io.LatencyMs()
+			info.IOReadBytesPerSec = io.ReadBytesPerSec
+			info.IOWriteBytesPerSec = io.WriteBytesPerSec
+		}
+	}
+	return info
 }
 
 // updateQueueMetrics updates queue metrics from task store
@@ -357,6 +435,7 @@ func (f *FileSystemHealthChecker) Check(ctx context.Context) ComponentHealth {
 		"data",
 		"logs",
 		"app/extraction/files/all",
+		"app/extraction/files/fastpath",
 		"app/extraction/files/pass",
 		"app/extraction/files/txt",
 		"app/extraction/files/done",
@@ -478,31 +557,78 @@ func (e *ExternalDependencyHealthChecker) Check(ctx context.Context) ComponentHe
 	}
 }
 
+// Diagnostic suites, the groups of self-diagnostics that can run on their own
+const (
+	DiagnosticSuiteFilesystem   = "filesystem"
+	DiagnosticSuiteDatabase     = "database"
+	DiagnosticSuiteTelegram     = "telegram"
+	DiagnosticSuiteDependencies = "dependencies"
+	DiagnosticSuiteRuntime      = "runtime"
+)
+
+// DiagnosticSuiteNames are the suites RunDiagnostics accepts
+var DiagnosticSuiteNames = []string{
+	DiagnosticSuiteDependencies,
+	DiagnosticSuiteFilesystem,
+	DiagnosticSuiteDatabase,
+	DiagnosticSuiteTelegram,
+	DiagnosticSuiteRuntime,
+}
+
+// diagnostics are the self-diagnostics by suite, in the order they run
+var diagnostics = []struct {
+	suite string
+	run   func(hm *HealthMonitor) DiagnosticResult
+}{
+	// External executable tests
+	{DiagnosticSuiteDependencies, (*HealthMonitor).diagnosExtractExecutable},
+	{DiagnosticSuiteDependencies, (*HealthMonitor).diagnosConvertExecutable},
+	// Directory structure validation
+	{DiagnosticSuiteFilesystem, (*HealthMonitor).diagnosDirectoryStructure},
+	// Database integrity check
+	{DiagnosticSuiteDatabase, (*HealthMonitor).diagnosDatabaseIntegrity},
+	// Password file validation
+	{DiagnosticSuiteFilesystem, (*HealthMonitor).diagnosPasswordFile},
+	// Disk space projections
+	{DiagnosticSuiteFilesystem, (*HealthMonitor).diagnosDiskSpaceProjections},
+	// Network connectivity (Telegram API)
+	{DiagnosticSuiteTelegram, (*HealthMonitor).diagnosTelegramConnectivity},
+	// Registered background goroutines
+	{DiagnosticSuiteRuntime, (*HealthMonitor).diagnosGoroutines},
+}
+
 // RunSelfDiagnostics runs comprehensive diagnostic checks
 func (hm *HealthMonitor) RunSelfDiagnostics() {
 	hm.logger.Info("Starting periodic self-diagnostics")
-	
+	if _, err := hm.RunDiagnostics(); err != nil {
+		hm.logger.WithError(err).Warn("Self-diagnostics failed")
+	}
+}
+
+// RunDiagnostics runs the diagnostics of the given suites, or all of them
+// without any, and returns the results. Only a run of every suite becomes
+// the last diagnostics; every run is kept in the history
+func (hm *HealthMonitor) RunDiagnostics(suites ...string) (*DiagnosticSuite, error) {
+	selected := make(map[string]bool)
+	for _, suite := range suites {
+		known := false
+		for _, name := range DiagnosticSuiteNames {
+			known = known || name == suite
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown diagnostic suite %q (expected one of %s)",
+				suite, strings.Join(DiagnosticSuiteNames, ", "))
+		}
+		selected[suite] = true
+	}
+
 	startTime := time.Now()
 	results := make([]DiagnosticResult, 0)
-	
-	// Diagnostic 1: External executable tests
-	results = append(results, hm.diagnosExtractExecutable())
-	results = append(results, hm.diagnosConvertExecutable())
-	
-	// Diagnostic 2: Directory structure validation
-	results = append(results, hm.diagnosDirectoryStructure())
-	
-	// Diagnostic 3: Database integrity check
-	results = append(results, hm.diagnosDatabaseIntegrity())
-	
-	// Diagnostic 4: Password file validation
-	results = append(results, hm.diagnosPasswordFile())
-	
-	// Diagnostic 5: Disk space projections
-	results = append(results, hm.diagnosDiskSpaceProjections())
-	
-	// Diagnostic 6: Network connectivity (Telegram API)
-	results = append(results, hm.diagnosTelegramConnectivity())
+	for _, diagnostic := range diagnostics {
+		if len(selected) == 0 || selected[diagnostic.suite] {
+			results = append(results, diagnostic.run(hm))
+		}
+	}
 	
 	// Determine overall status
 	overallStatus := HealthStatusHealthy
@@ -518,14 +644,18 @@ func (hm *HealthMonitor) RunSelfDiagnostics() {
 	suite := &DiagnosticSuite{
 		Timestamp:     startTime,
 		Duration:      time.Since(startTime),
+		Suites:        suites,
 		Results:       results,
 		OverallStatus: overallStatus,
 	}
 	
 	// Store diagnostics
-	hm.checkMutex.Lock()
-	hm.lastDiagnostics = suite
-	hm.checkMutex.Unlock()
+	if len(selected) == 0 {
+		hm.checkMutex.Lock()
+		hm.lastDiagnostics = suite
+		hm.checkMutex.Unlock()
+	}
+	hm.recordDiagnostics(suite)
 	
 	// Log results
 	hm.logger.WithField("overall_status", string(overallStatus)).
@@ -541,6 +671,7 @@ func (hm *HealthMonitor) RunSelfDiagnostics() {
 				Error("Critical diagnostic issue detected")
 		}
 	}
+	return suite, nil
 }
 
 // diagnosExtractExecutable tests the extract.go executable
@@ -624,6 +755,7 @@ func (hm *HealthMonitor) diagnosDirectoryStructure() DiagnosticResult {
 	
 	requiredDirs := []string{
 		"app/extraction/files/all",
+		"app/extraction/files/fastpath",
 		"app/extraction/files/pass",
 		"app/extraction/files/txt",
 		"app/extraction/files/done",
@@ -820,6 +952,43 @@ func (hm *HealthMonitor) diagnosTelegramConnectivity() DiagnosticResult {
 	return result
 }
 
+// diagnosGoroutines lists the registered background goroutines and flags
+// the ones that stopped heartbeating
+func (hm *HealthMonitor) diagnosGoroutines() DiagnosticResult {
+	start := time.Now()
+	result := DiagnosticResult{
+		Name:      "goroutines",
+		Timestamp: start,
+		Details:   make(map[string]interface{}),
+	}
+	
+	registered := utils.Goroutines.Snapshot()
+	total := runtime.NumGoroutine()
+	stale := 0
+	for _, info := range registered {
+		if info.Stale {
+			stale++
+		}
+	}
+	
+	result.Details["goroutines"] = total
+	result.Details["registered"] = len(registered)
+	result.Details["unregistered"] = total - len(registered)
+	result.Details["stale"] = stale
+	result.Details["registry"] = registered
+	
+	if stale > 0 {
+		result.Status = HealthStatusDegraded
+		result.Message = fmt.Sprintf("%d of %d registered goroutines stopped heartbeating", stale, len(registered))
+	} else {
+		result.Status = HealthStatusHealthy
+		result.Message = fmt.Sprintf("%d registered goroutines heartbeating, %d goroutines in total", len(registered), total)
+	}
+	
+	result.Duration = time.Since(start)
+	return result
+}
+
 // GetLastDiagnostics returns the most recent diagnostic suite
 func (hm *HealthMonitor) GetLastDiagnostics() *DiagnosticSuite {
 	hm.checkMutex.RLock()
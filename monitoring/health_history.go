@@ -0,0 +1,73 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"time"
+
+	"telegram-archive-bot/storage"
+)
+
+// DefaultHealthHistorySize keeps about two days of 30 second health checks
+const DefaultHealthHistorySize = 5760
+
+// SetHistorySize sets how many health checks and diagnostic suites are kept
+// in the database; 0 disables the history
+func (hm *HealthMonitor) SetHistorySize(size int) {
+	hm.checkMutex.Lock()
+	defer hm.checkMutex.Unlock()
+	hm.historySize = size
+}
+
+// recordHealthCheck stores a health check in the history
+func (hm *HealthMonitor) recordHealthCheck(check *HealthCheck) {
+	components := make([]storage.HealthComponentStatus, 0, len(check.Components))
+	for _, c := range check.Components {
+		components = append(components, storage.HealthComponentStatus{
+			Name:    c.Name,
+			Status:  string(c.Status),
+			Message: c.Message,
+		})
+	}
+	hm.saveHistory(storage.HealthRecordCheck, string(check.Status), check.Timestamp, check, components)
+}
+
+// recordDiagnostics stores a diagnostic suite in the history
+func (hm *HealthMonitor) recordDiagnostics(suite *DiagnosticSuite) {
+	components := make([]storage.HealthComponentStatus, 0, len(suite.Results))
+	for _, r := range suite.Results {
+		components = append(components, storage.HealthComponentStatus{
+			Name:    r.Name,
+			Status:  string(r.Status),
+			Message: r.Message,
+		})
+	}
+	hm.saveHistory(storage.HealthRecordDiagnostics, string(suite.OverallStatus), suite.Timestamp, suite, components)
+}
+
+// saveHistory writes one history record, trimming the oldest beyond the history size
+func (hm *HealthMonitor) saveHistory(kind, status string, checkedAt time.Time, result interface{}, components []storage.HealthComponentStatus) {
+	hm.checkMutex.RLock()
+	size := hm.historySize
+	hm.checkMutex.RUnlock()
+	if hm.taskStore == nil || size <= 0 {
+		return
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		hm.logger.WithError(err).WithField("kind", kind).Warn("Failed to encode health history record")
+		return
+	}
+
+	record := &storage.HealthRecord{
+		Kind:       kind,
+		Status:     status,
+		CheckedAt:  checkedAt,
+		Components: components,
+		Result:     data,
+	}
+
+	if err := hm.taskStore.SaveHealthRecord(record, size); err != nil {
+		hm.logger.WithError(err).WithField("kind", kind).Warn("Failed to save health history record")
+	}
+}
@@ -0,0 +1,161 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"telegram-archive-bot/utils"
+)
+
+// HealthCheckPolicy sets how often health checks run and how long each
+// checker may take
+type HealthCheckPolicy struct {
+	Interval time.Duration
+	// Jitter spreads each wait over ±Jitter/2 of Interval, so instances
+	// started together do not check in step; 0 disables it
+	Jitter float64
+	// Timeout bounds every checker without its own entry in CheckerTimeouts
+	Timeout         time.Duration
+	CheckerTimeouts map[string]time.Duration
+	// CheckerIntervals run some checkers less often than Interval; between
+	// runs their last result is reported again
+	CheckerIntervals map[string]time.Duration
+}
+
+// DefaultHealthCheckPolicy returns the policy used when nothing is configured
+func DefaultHealthCheckPolicy() HealthCheckPolicy {
+	return HealthCheckPolicy{
+		Interval: 30 * time.Second,
+		Jitter:   0.1,
+		Timeout:  10 * time.Second,
+	}
+}
+
+// checkerState is what the monitor remembers of one checker between checks
+type checkerState struct {
+	last    *ComponentHealth
+	lastRun time.Time
+	// runningSince is set while a run has not returned, including one the
+	// monitor stopped waiting for after its timeout
+	runningSince time.Time
+}
+
+// SetCheckPolicy sets the health check schedule and checker timeouts; call
+// it before Run
+func (hm *HealthMonitor) SetCheckPolicy(policy HealthCheckPolicy) {
+	hm.checkMutex.Lock()
+	hm.policy = policy
+	hm.checkMutex.Unlock()
+
+	for name := range policy.CheckerIntervals {
+		if _, ok := hm.components[name]; !ok {
+			hm.logger.WithField("component", name).Warn("Health check interval set for an unknown checker")
+		}
+	}
+	for name := range policy.CheckerTimeouts {
+		if _, ok := hm.components[name]; !ok {
+			hm.logger.WithField("component", name).Warn("Health check timeout set for an unknown checker")
+		}
+	}
+
+	// A checker run less often must not count as unmonitored in between
+	if hm.availability != nil {
+		longest := policy.Interval
+		for _, interval := range policy.CheckerIntervals {
+			if interval > longest {
+				longest = interval
+			}
+		}
+		hm.availability.SetCheckInterval(longest)
+	}
+}
+
+// nextCheckDelay is the wait before the next health check, with jitter
+func (hm *HealthMonitor) nextCheckDelay() time.Duration {
+	hm.checkMutex.RLock()
+	defer hm.checkMutex.RUnlock()
+	return utils.Jitter(hm.policy.Interval, hm.policy.Jitter)
+}
+
+// runChecker returns a checker's health: its last result while its own
+// interval has not passed, otherwise a fresh run bounded by its timeout. A
+// run that times out keeps going in the background and is reported as
+// unhealthy until it returns, so a hanging checker never piles up runs
+func (hm *HealthMonitor) runChecker(checker HealthChecker) ComponentHealth {
+	name := checker.Name()
+
+	hm.checkMutex.Lock()
+	policy := hm.policy
+	state, ok := hm.checkerStates[name]
+	if !ok {
+		state = &checkerState{}
+		hm.checkerStates[name] = state
+	}
+	now := time.Now()
+	if !state.runningSince.IsZero() {
+		runningFor := now.Sub(state.runningSince)
+		hm.checkMutex.Unlock()
+		return ComponentHealth{
+			Name:        name,
+			Status:      HealthStatusUnhealthy,
+			Message:     fmt.Sprintf("Previous check still running after %s", runningFor.Round(time.Second)),
+			LastChecked: now,
+		}
+	}
+	// Half an interval of slack keeps jitter from pushing a run a whole check later
+	if interval, ok := policy.CheckerIntervals[name]; ok && state.last != nil &&
+		now.Sub(state.lastRun) < interval-policy.Interval/2 {
+		cached := *state.last
+		hm.checkMutex.Unlock()
+		return cached
+	}
+	state.runningSince = now
+	hm.checkMutex.Unlock()
+
+	timeout := policy.Timeout
+	if t, ok := policy.CheckerTimeouts[name]; ok {
+		timeout = t
+	}
+	ctx, cancel := context.WithTimeout(hm.routines.Context(), timeout)
+	defer cancel()
+
+	done := make(chan ComponentHealth, 1)
+	// The run stays registered until it returns, so an abandoned one shows
+	// up as a stalled goroutine
+	registration := utils.Goroutines.Register("health_check:"+name, 0)
+	go func() {
+		defer registration.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				utils.CapturePanic("health_check:"+name, r, debug.Stack(), nil)
+				done <- ComponentHealth{Name: name, Status: HealthStatusUnhealthy, Message: fmt.Sprintf("Check panicked: %v", r)}
+			}
+			hm.checkMutex.Lock()
+			state.runningSince = time.Time{}
+			hm.checkMutex.Unlock()
+		}()
+		done <- checker.Check(ctx)
+	}()
+
+	var result ComponentHealth
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		result = ComponentHealth{
+			Name:    name,
+			Status:  HealthStatusUnhealthy,
+			Message: fmt.Sprintf("Check timed out after %s", timeout),
+		}
+		hm.logger.WithField("component", name).WithField("timeout", timeout).Warn("Health checker timed out")
+	}
+	result.ResponseTimeMs = time.Since(now).Milliseconds()
+	result.LastChecked = time.Now()
+
+	hm.checkMutex.Lock()
+	state.last = &result
+	state.lastRun = now
+	hm.checkMutex.Unlock()
+	return result
+}
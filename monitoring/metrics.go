@@ -113,16 +113,24 @@ type SystemMetrics struct {
 	GoroutineCount  int       `json:"goroutine_count"`
 	FileDescriptors int       `json:"file_descriptors"`
 	DiskUsageBytes  int64     `json:"disk_usage_bytes"`
+	MemoryLimitMB      float64 `json:"memory_limit_mb"`
+	MemoryLimitPercent float64 `json:"memory_limit_percent"`
 	NetworkIO       NetworkIO `json:"network_io"`
+	DiskIO          map[string]DiskIOStats `json:"disk_io"` // By monitored volume
 	LastUpdated     time.Time `json:"last_updated"`
 }
 
-// NetworkIO tracks network statistics
+// NetworkIO tracks network statistics. The byte and packet counts are the
+// machine's since boot, the Bot API and download counts the process's since start
 type NetworkIO struct {
 	BytesReceived int64 `json:"bytes_received"`
 	BytesSent     int64 `json:"bytes_sent"`
 	PacketsReceived int64 `json:"packets_received"`
 	PacketsSent   int64 `json:"packets_sent"`
+	BotAPIBytesSent     int64 `json:"bot_api_bytes_sent"`
+	BotAPIBytesReceived int64 `json:"bot_api_bytes_received"`
+	BotAPIRequests      int64 `json:"bot_api_requests"`
+	DownloadedBytes     int64 `json:"downloaded_bytes"` // Files fetched for tasks
 }
 
 // TimingContext tracks timing for operations
@@ -229,6 +237,10 @@ func (pm *PerformanceMetrics) initializeMetrics() {
 		Name:        "active_conversions",
 		LastUpdated: time.Now(),
 	}
+	pm.gauges["memory_limit_percent"] = &GaugeMetric{
+		Name:        "memory_limit_percent",
+		LastUpdated: time.Now(),
+	}
 	
 	// Timing metrics
 	pm.timings["download_duration"] = &TimingMetric{
@@ -514,6 +526,42 @@ func (pm *PerformanceMetrics) GetProcessingMetrics() map[string]*ProcessingMetri
 	}
 }
 
+// UpdateSystemMetrics copies the latest resource snapshot into the system
+// metrics and the memory_limit_percent gauge
+func (pm *PerformanceMetrics) UpdateSystemMetrics(snapshot *SystemResourceSnapshot) {
+	if snapshot == nil {
+		return
+	}
+	pm.SetGauge("memory_limit_percent", snapshot.Memory.MemoryLimitPercent)
+	for volume, io := range snapshot.DiskIO {
+		pm.SetGauge("disk_io_util_percent_"+volume, io.UtilPercent)
+		pm.SetGauge("disk_io_latency_ms_"+volume, io.LatencyMs())
+		pm.SetGauge("disk_read_bytes_per_sec_"+volume, io.ReadBytesPerSec)
+		pm.SetGauge("disk_write_bytes_per_sec_"+volume, io.WriteBytesPerSec)
+	}
+
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+
+	pm.systemMetrics.CPUUsage = snapshot.CPU.TotalPercent
+	pm.systemMetrics.MemoryUsage = snapshot.Memory.AllocMB
+	pm.systemMetrics.MemoryLimitMB = snapshot.Memory.MemoryLimitMB
+	pm.systemMetrics.MemoryLimitPercent = snapshot.Memory.MemoryLimitPercent
+	pm.systemMetrics.GoroutineCount = snapshot.Process.Goroutines
+	pm.systemMetrics.FileDescriptors = snapshot.Process.FDs
+	pm.systemMetrics.DiskIO = snapshot.DiskIO
+	pm.systemMetrics.NetworkIO = snapshot.Network
+	pm.systemMetrics.LastUpdated = snapshot.Timestamp
+}
+
+// GetSystemMetrics returns a copy of the current system metrics
+func (pm *PerformanceMetrics) GetSystemMetrics() SystemMetrics {
+	pm.mutex.RLock()
+	defer pm.mutex.RUnlock()
+
+	return *pm.systemMetrics
+}
+
 // GetQueueMetrics returns current queue metrics
 func (pm *PerformanceMetrics) GetQueueMetrics() *QueueMetrics {
 	pm.mutex.RLock()
@@ -0,0 +1,55 @@
+package monitoring
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"telegram-archive-bot/utils"
+)
+
+// getNetworkStats sums the counters of every interface but loopback from
+// /proc/net/dev (Linux only) and adds the process's own traffic accounting.
+// The Local Bot API server is reached over loopback, so its traffic is only
+// in the Bot API counters
+func (srm *SystemResourceMonitor) getNetworkStats() (*NetworkIO, error) {
+	stats := &NetworkIO{
+		BotAPIBytesSent:     utils.Traffic.BotAPI.Sent(),
+		BotAPIBytesReceived: utils.Traffic.BotAPI.Received(),
+		BotAPIRequests:      utils.Traffic.BotAPI.Requests(),
+		DownloadedBytes:     utils.Traffic.Downloaded.Load(),
+	}
+
+	file, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return stats, fmt.Errorf("failed to open /proc/net/dev: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, counters, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+		// Receive bytes, packets, ... then transmit bytes, packets, ...
+		fields := strings.Fields(counters)
+		if len(fields) < 10 {
+			continue
+		}
+		values := make([]int64, 10)
+		for i := range values {
+			values[i], _ = strconv.ParseInt(fields[i], 10, 64)
+		}
+		stats.BytesReceived += values[0]
+		stats.PacketsReceived += values[1]
+		stats.BytesSent += values[8]
+		stats.PacketsSent += values[9]
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, fmt.Errorf("failed to read /proc/net/dev: %w", err)
+	}
+	return stats, nil
+}
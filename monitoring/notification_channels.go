@@ -0,0 +1,162 @@
+package monitoring
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"telegram-archive-bot/utils"
+)
+
+// notificationChannelTimeout bounds one delivery over a failover channel
+const notificationChannelTimeout = 30 * time.Second
+
+// NewFailoverChannels returns the failover channels enabled in the config
+func NewFailoverChannels(config *utils.Config) []NotificationChannel {
+	var channels []NotificationChannel
+	if config.AlertFailoverWebhookURL != "" {
+		channels = append(channels, NewWebhookChannel(config.AlertFailoverWebhookURL))
+	}
+	if config.AlertFailoverSMTPAddr != "" && len(config.AlertFailoverEmailTo) > 0 {
+		channels = append(channels, NewEmailChannel(config.AlertFailoverSMTPAddr, config.AlertFailoverSMTPUsername,
+			config.AlertFailoverSMTPPassword, config.AlertFailoverEmailFrom, config.AlertFailoverEmailTo))
+	}
+	return channels
+}
+
+// WebhookChannel posts notifications as JSON to a URL, such as a Slack or
+// Discord incoming webhook or a paging service
+type WebhookChannel struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookChannel creates a channel posting to url
+func NewWebhookChannel(url string) *WebhookChannel {
+	return &WebhookChannel{url: url, client: &http.Client{Timeout: notificationChannelTimeout}}
+}
+
+// Name implements NotificationChannel
+func (c *WebhookChannel) Name() string {
+	return "webhook"
+}
+
+// webhookNotification is the body posted by WebhookChannel; text is the
+// field Slack and most chat webhooks read
+type webhookNotification struct {
+	Text   string    `json:"text"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// Send implements NotificationChannel; any non-2xx response is an error
+func (c *WebhookChannel) Send(recipient, text string) error {
+	body, err := json.Marshal(webhookNotification{Text: text, SentAt: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	resp, err := c.client.Post(c.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post notification: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notification webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// EmailChannel mails notifications over SMTP, upgrading to TLS when the
+// server offers STARTTLS
+type EmailChannel struct {
+	addr     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+// NewEmailChannel creates a channel mailing to every address in to through
+// the SMTP server at addr (host:port). Without a username it does not log in
+func NewEmailChannel(addr, username, password, from string, to []string) *EmailChannel {
+	return &EmailChannel{addr: addr, username: username, password: password, from: from, to: to}
+}
+
+// Name implements NotificationChannel
+func (c *EmailChannel) Name() string {
+	return "email"
+}
+
+// Send implements NotificationChannel
+func (c *EmailChannel) Send(recipient, text string) error {
+	host, _, err := net.SplitHostPort(c.addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address %s: %w", c.addr, err)
+	}
+	conn, err := net.DialTimeout("tcp", c.addr, notificationChannelTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(notificationChannelTimeout))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if c.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.username, c.password, host)); err != nil {
+			return fmt.Errorf("SMTP login failed: %w", err)
+		}
+	}
+	if err := client.Mail(c.from); err != nil {
+		return fmt.Errorf("SMTP server refused sender: %w", err)
+	}
+	for _, to := range c.to {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("SMTP server refused recipient %s: %w", to, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start message: %w", err)
+	}
+	if _, err := writer.Write(c.message(text)); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return client.Quit()
+}
+
+// message renders the mail with the notification as its plain-text body
+func (c *EmailChannel) message(text string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", c.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(c.to, ", "))
+	b.WriteString("Subject: Telegram archive bot alert\r\n")
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(text, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
@@ -0,0 +1,216 @@
+package monitoring
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/utils"
+)
+
+// Pipeline graph formats
+const (
+	GraphFormatDOT     = "dot"
+	GraphFormatMermaid = "mermaid"
+)
+
+// Pipeline graph node states
+const (
+	NodeIdle    = "idle"    // Nothing waiting
+	NodeWaiting = "waiting" // Work waiting for its turn
+	NodeRunning = "running"
+	NodeBlocked = "blocked" // Work held up by something other than its turn
+)
+
+// PipelineGraphSource is what the pipeline graph reads from the orchestrator
+type PipelineGraphSource interface {
+	PipelineState
+	// StageBlockers explains why nodes with work waiting are held up, by node ID
+	StageBlockers() map[string]string
+}
+
+// PipelineNode is a stage of the pipeline graph with the work waiting in it
+type PipelineNode struct {
+	ID     string `json:"id"`
+	Count  int    `json:"count"`
+	Unit   string `json:"unit,omitempty"` // What Count counts: "tasks" or "files"
+	State  string `json:"state"`
+	Detail string `json:"detail,omitempty"` // What it runs or waits for
+}
+
+// PipelineEdge is a path work takes between two nodes
+type PipelineEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// PipelineGraph is a snapshot of where the work in the pipeline sits
+type PipelineGraph struct {
+	GeneratedAt time.Time               `json:"generated_at"`
+	Maintenance utils.MaintenanceStatus `json:"maintenance"`
+	Downloaded  int                     `json:"downloaded"` // Tasks whose files are in the processing stages
+	Nodes       []PipelineNode          `json:"nodes"`
+	Edges       []PipelineEdge          `json:"edges"`
+}
+
+// pipelineEdges is the path of a file: text files take the fast path, archives
+// are extracted and converted, and both end in the store stage
+var pipelineEdges = []PipelineEdge{
+	{From: "pending", To: "download"},
+	{From: "download", To: "fast_path"},
+	{From: "download", To: "extraction"},
+	{From: "extraction", To: "conversion"},
+	{From: "fast_path", To: "store"},
+	{From: "conversion", To: "store"},
+	{From: "store", To: "done"},
+}
+
+// BuildPipelineGraph collects the task counts and stage backlogs into a graph.
+// The processing stages run one at a time, so a stage with files waiting
+// while another runs is waiting for it
+func BuildPipelineGraph(tasks StatusTaskSource, pipeline PipelineGraphSource) (*PipelineGraph, error) {
+	graph := &PipelineGraph{
+		GeneratedAt: time.Now(),
+		Maintenance: utils.Maintenance.Status(),
+		Edges:       pipelineEdges,
+	}
+
+	counts := make(map[models.TaskStatus]int)
+	for _, status := range []models.TaskStatus{models.TaskStatusPending, models.TaskStatusDownloading, models.TaskStatusDownloaded} {
+		count, err := tasks.GetTaskCountByStatus(status)
+		if err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	graph.Downloaded = counts[models.TaskStatusDownloaded]
+
+	backlog := pipeline.StageBacklog()
+	blockers := pipeline.StageBlockers()
+	running := pipeline.StageProgress()
+
+	graph.Nodes = []PipelineNode{
+		{ID: "pending", Count: counts[models.TaskStatusPending], Unit: "tasks"},
+		{ID: "download", Count: counts[models.TaskStatusDownloading], Unit: "tasks"},
+		{ID: "fast_path", Count: backlog["fast_path"], Unit: "files"},
+		{ID: "extraction", Count: backlog["extraction"], Unit: "files"},
+		{ID: "conversion", Count: backlog["conversion"], Unit: "files"},
+		{ID: "store", Count: backlog["store"], Unit: "files"},
+		{ID: "done"},
+	}
+	for i := range graph.Nodes {
+		node := &graph.Nodes[i]
+		switch {
+		case running != nil && running.Stage == node.ID:
+			node.State = NodeRunning
+			if running.Total > 0 {
+				node.Detail = fmt.Sprintf("%d/%d files (%.0f%%)", running.Done, running.Total, running.Percent())
+			}
+		case node.ID == "download" && node.Count > 0:
+			node.State = NodeRunning
+		case node.Count > 0 && blockers[node.ID] != "":
+			node.State = NodeBlocked
+			node.Detail = blockers[node.ID]
+		case node.Count > 0:
+			node.State = NodeWaiting
+			if running != nil && node.ID != "pending" {
+				node.Detail = "behind " + running.Stage
+			}
+		default:
+			node.State = NodeIdle
+		}
+	}
+	return graph, nil
+}
+
+// Render returns the graph in a GraphFormat
+func (g *PipelineGraph) Render(format string) (string, error) {
+	switch format {
+	case GraphFormatDOT:
+		return g.DOT(), nil
+	case GraphFormatMermaid:
+		return g.Mermaid(), nil
+	}
+	return "", fmt.Errorf("unknown graph format %q, expected %s or %s", format, GraphFormatDOT, GraphFormatMermaid)
+}
+
+// nodeColors are the fill and border colours of each node state
+var nodeColors = map[string][2]string{
+	NodeIdle:    {"#eeeeee", "#9e9e9e"},
+	NodeWaiting: {"#fff3cd", "#f0ad4e"},
+	NodeRunning: {"#d4edda", "#28a745"},
+	NodeBlocked: {"#f8d7da", "#dc3545"},
+}
+
+// DOT renders the graph for Graphviz, e.g. `dot -Tsvg`
+func (g *PipelineGraph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph pipeline {\n")
+	b.WriteString("  rankdir=LR;\n")
+	fmt.Fprintf(&b, "  label=\"%s\";\n  labelloc=t;\n", dotEscape(g.title()))
+	b.WriteString("  node [shape=box, style=\"rounded,filled\", fontname=\"Helvetica\"];\n")
+	for _, node := range g.Nodes {
+		colors := nodeColors[node.State]
+		fmt.Fprintf(&b, "  %s [label=\"%s\", fillcolor=\"%s\", color=\"%s\"];\n",
+			node.ID, dotEscape(strings.Join(node.lines(), "\n")), colors[0], colors[1])
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "  %s -> %s;\n", edge.From, edge.To)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart
+func (g *PipelineGraph) Mermaid() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "---\ntitle: %q\n---\n", g.title())
+	b.WriteString("flowchart LR\n")
+	for _, node := range g.Nodes {
+		fmt.Fprintf(&b, "    %s[\"%s\"]:::%s\n", node.ID, mermaidEscape(strings.Join(node.lines(), "<br/>")), node.State)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "    %s --> %s\n", edge.From, edge.To)
+	}
+	for _, state := range []string{NodeIdle, NodeWaiting, NodeRunning, NodeBlocked} {
+		colors := nodeColors[state]
+		fmt.Fprintf(&b, "    classDef %s fill:%s,stroke:%s\n", state, colors[0], colors[1])
+	}
+	return b.String()
+}
+
+// title sums up the graph: when it was taken, the tasks in processing and
+// any maintenance in progress
+func (g *PipelineGraph) title() string {
+	title := fmt.Sprintf("Pipeline at %s, %d tasks in processing", g.GeneratedAt.Format("2006-01-02 15:04:05"), g.Downloaded)
+	if g.Maintenance.Active {
+		title += ", maintenance: " + strings.Join(g.Maintenance.Reasons, ", ")
+	}
+	return title
+}
+
+// lines are the label lines of a node
+func (n PipelineNode) lines() []string {
+	lines := []string{n.ID}
+	if n.Unit != "" {
+		lines = append(lines, fmt.Sprintf("%d %s", n.Count, n.Unit))
+	}
+	if n.State != NodeIdle {
+		lines = append(lines, n.State)
+	}
+	if n.Detail != "" {
+		lines = append(lines, n.Detail)
+	}
+	return lines
+}
+
+func dotEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
@@ -0,0 +1,179 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"telegram-archive-bot/app/extraction/progress"
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/utils"
+)
+
+// maxStatusFileTaskIDs caps the task IDs listed per status in the status file
+const maxStatusFileTaskIDs = 100
+
+// StatusTaskSource provides the task counts and IDs of the status file
+type StatusTaskSource interface {
+	GetTaskCountByStatus(status models.TaskStatus) (int, error)
+	GetTasksByStatus(status models.TaskStatus, limit int) ([]*models.Task, error)
+}
+
+// PipelineState is the processing orchestrator's view of the pipeline
+type PipelineState interface {
+	StageBacklog() map[string]int
+	StageProgress() *progress.Report
+}
+
+// StatusFilePolicy sets where the status file is written and how often
+type StatusFilePolicy struct {
+	Path     string // Empty disables the file
+	Interval time.Duration
+}
+
+// DefaultStatusFilePolicy returns the policy used when nothing is configured
+func DefaultStatusFilePolicy() StatusFilePolicy {
+	return StatusFilePolicy{
+		Path:     "data/status.json",
+		Interval: 30 * time.Second,
+	}
+}
+
+// StatusFileContent is what the status file holds
+type StatusFileContent struct {
+	UpdatedAt time.Time       `json:"updated_at"`
+	PID       int             `json:"pid"`
+	Build     utils.BuildInfo `json:"build"`
+	// Busy is set while tasks are downloading or files wait for or run
+	// through a processing stage, e.g. to hold off a host backup
+	Busy        bool                    `json:"busy"`
+	Maintenance utils.MaintenanceStatus `json:"maintenance"`
+	Health      StatusFileHealth        `json:"health"`
+	Queue       map[string]int          `json:"queue"`
+	// ActiveTasks are the IDs of the downloading and downloaded tasks, the
+	// most recent first, at most maxStatusFileTaskIDs each
+	ActiveTasks map[string][]string `json:"active_tasks"`
+	Stage       *progress.Report    `json:"stage,omitempty"`
+}
+
+// StatusFileHealth is the last health check in the status file
+type StatusFileHealth struct {
+	Status    HealthStatus `json:"status"`
+	CheckedAt time.Time    `json:"checked_at,omitempty"`
+	// Unhealthy lists the components that are not healthy
+	Unhealthy []string `json:"unhealthy,omitempty"`
+}
+
+// StatusFileWriter keeps a JSON status file up to date, so cron jobs and
+// other host tooling can check on the bot without calling the HTTP API
+type StatusFileWriter struct {
+	logger   *utils.Logger
+	policy   StatusFilePolicy
+	tasks    StatusTaskSource
+	health   *HealthMonitor
+	pipeline PipelineState
+}
+
+// NewStatusFileWriter creates a writer; pipeline may be nil, e.g. on a
+// process that does not run the orchestrator
+func NewStatusFileWriter(logger *utils.Logger, policy StatusFilePolicy, tasks StatusTaskSource, health *HealthMonitor, pipeline PipelineState) *StatusFileWriter {
+	return &StatusFileWriter{
+		logger:   logger,
+		policy:   policy,
+		tasks:    tasks,
+		health:   health,
+		pipeline: pipeline,
+	}
+}
+
+// Run rewrites the status file every interval until ctx is cancelled
+func (sw *StatusFileWriter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(sw.policy.Interval)
+	defer ticker.Stop()
+
+	for {
+		utils.Heartbeat(ctx)
+
+		if err := sw.write(); err != nil {
+			sw.logger.WithError(err).WithField("file", sw.policy.Path).Warn("Failed to write status file")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status collects the current content of the status file
+func (sw *StatusFileWriter) Status() (*StatusFileContent, error) {
+	status := &StatusFileContent{
+		UpdatedAt:   time.Now(),
+		PID:         os.Getpid(),
+		Build:       utils.CurrentBuild(),
+		Maintenance: utils.Maintenance.Status(),
+		Health:      StatusFileHealth{Status: "UNKNOWN"},
+		Queue:       make(map[string]int),
+		ActiveTasks: make(map[string][]string),
+	}
+
+	for _, taskStatus := range []models.TaskStatus{models.TaskStatusPending, models.TaskStatusDownloading, models.TaskStatusDownloaded} {
+		count, err := sw.tasks.GetTaskCountByStatus(taskStatus)
+		if err != nil {
+			return nil, err
+		}
+		status.Queue[statusFileKey(taskStatus)] = count
+	}
+	for _, taskStatus := range []models.TaskStatus{models.TaskStatusDownloading, models.TaskStatusDownloaded} {
+		tasks, err := sw.tasks.GetTasksByStatus(taskStatus, maxStatusFileTaskIDs)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(tasks))
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+		status.ActiveTasks[statusFileKey(taskStatus)] = ids
+	}
+	status.Busy = status.Queue[statusFileKey(models.TaskStatusDownloading)] > 0
+
+	if sw.pipeline != nil {
+		for stage, count := range sw.pipeline.StageBacklog() {
+			status.Queue["awaiting_"+stage] = count
+			status.Busy = status.Busy || count > 0
+		}
+		status.Stage = sw.pipeline.StageProgress()
+		status.Busy = status.Busy || status.Stage != nil
+	}
+
+	if check := sw.health.GetLastHealthCheck(); check != nil {
+		status.Health.Status = check.Status
+		status.Health.CheckedAt = check.Timestamp
+		for _, component := range check.Components {
+			if component.Status != HealthStatusHealthy {
+				status.Health.Unhealthy = append(status.Health.Unhealthy, component.Name)
+			}
+		}
+	}
+	return status, nil
+}
+
+func (sw *StatusFileWriter) write() error {
+	status, err := sw.Status()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(sw.policy.Path, append(data, '\n'), 0644)
+}
+
+// statusFileKey is the status file name of a task status, e.g. "downloading"
+func statusFileKey(status models.TaskStatus) string {
+	return strings.ToLower(string(status))
+}
@@ -0,0 +1,288 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"telegram-archive-bot/utils"
+)
+
+// Restart backoff bounds; the backoff resets once a component stays up for stableRunPeriod
+const (
+	supervisorMinBackoff = 1 * time.Second
+	supervisorMaxBackoff = 5 * time.Minute
+	stableRunPeriod      = 5 * time.Minute
+)
+
+// ComponentFunc runs a supervised component until ctx is cancelled. Long-running
+// loops should call utils.Heartbeat(ctx) regularly so deadlocks can be detected
+type ComponentFunc func(ctx context.Context) error
+
+// ComponentStatus describes the current state of a supervised component
+type ComponentStatus struct {
+	Name          string    `json:"name"`
+	Running       bool      `json:"running"`
+	Restarts      int       `json:"restarts"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	StartedAt     time.Time `json:"started_at"`
+}
+
+// Supervisor restarts components that exit unexpectedly, panic or stop
+// heartbeating, and raises a ComponentDown alert when it does
+type Supervisor struct {
+	logger       *utils.Logger
+	alertManager *AlertManager
+	mutex        sync.RWMutex
+	components   map[string]*supervisedComponent
+	routines     *utils.Routines
+}
+
+type supervisedComponent struct {
+	name             string
+	heartbeatTimeout time.Duration
+	run              ComponentFunc
+
+	lastHeartbeat atomic.Int64 // Unix nanoseconds
+	mutex         sync.Mutex
+	running       bool
+	restarts      int
+	lastError     string
+	startedAt     time.Time
+}
+
+// NewSupervisor creates a supervisor that reports failures through alertManager
+func NewSupervisor(logger *utils.Logger, alertManager *AlertManager) *Supervisor {
+	return &Supervisor{
+		logger:       logger,
+		alertManager: alertManager,
+		components:   make(map[string]*supervisedComponent),
+		routines:     utils.NewRoutines(context.Background()),
+	}
+}
+
+// Go starts run under supervision until ctx is cancelled or the supervisor is
+// shut down. A heartbeatTimeout of zero disables deadlock detection for the
+// component
+func (s *Supervisor) Go(ctx context.Context, name string, heartbeatTimeout time.Duration, run ComponentFunc) {
+	component := &supervisedComponent{
+		name:             name,
+		heartbeatTimeout: heartbeatTimeout,
+		run:              run,
+	}
+
+	s.mutex.Lock()
+	s.components[name] = component
+	s.mutex.Unlock()
+
+	started := s.routines.Go("supervise:"+name, 0, func(shutdownCtx context.Context) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(shutdownCtx, cancel)
+		defer stop()
+
+		s.supervise(ctx, component)
+	})
+	if !started {
+		s.logger.WithField("component", name).Debug("Supervisor shut down, component not started")
+	}
+}
+
+// Shutdown stops every supervised component and waits for them to return.
+// A stalled instance that ignores cancellation is not waited for
+func (s *Supervisor) Shutdown(ctx context.Context) error {
+	return s.routines.Stop(ctx)
+}
+
+// GetStatus returns the state of all supervised components sorted by name
+func (s *Supervisor) GetStatus() []ComponentStatus {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	statuses := make([]ComponentStatus, 0, len(s.components))
+	for _, c := range s.components {
+		c.mutex.Lock()
+		statuses = append(statuses, ComponentStatus{
+			Name:          c.name,
+			Running:       c.running,
+			Restarts:      c.restarts,
+			LastError:     c.lastError,
+			LastHeartbeat: time.Unix(0, c.lastHeartbeat.Load()),
+			StartedAt:     c.startedAt,
+		})
+		c.mutex.Unlock()
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// supervise runs the component, restarting it with exponential backoff until ctx is cancelled
+func (s *Supervisor) supervise(ctx context.Context, c *supervisedComponent) {
+	backoff := supervisorMinBackoff
+
+	for {
+		startedAt := time.Now()
+		err := s.runOnce(ctx, c)
+
+		if ctx.Err() != nil {
+			c.mutex.Lock()
+			c.running = false
+			c.mutex.Unlock()
+			s.logger.WithField("component", c.name).Debug("Supervised component stopped")
+			return
+		}
+
+		if err == nil {
+			err = fmt.Errorf("component exited unexpectedly")
+		}
+
+		if time.Since(startedAt) > stableRunPeriod {
+			backoff = supervisorMinBackoff
+		}
+
+		c.mutex.Lock()
+		c.running = false
+		c.restarts++
+		c.lastError = err.Error()
+		restarts := c.restarts
+		c.mutex.Unlock()
+
+		s.logger.WithField("component", c.name).
+			WithField("restarts", restarts).
+			WithField("backoff", backoff.String()).
+			WithError(err).
+			Error("Supervised component failed, restarting")
+		s.raiseComponentDown(c.name, err, restarts, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > supervisorMaxBackoff {
+			backoff = supervisorMaxBackoff
+		}
+	}
+}
+
+// runOnce runs the component until it returns, panics or misses its heartbeat deadline
+func (s *Supervisor) runOnce(ctx context.Context, c *supervisedComponent) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c.lastHeartbeat.Store(time.Now().UnixNano())
+
+	c.mutex.Lock()
+	c.running = true
+	c.startedAt = time.Now()
+	c.mutex.Unlock()
+
+	done := make(chan error, 1)
+	// The instance stays registered until it returns, so an abandoned one
+	// shows up as a stalled goroutine
+	registration := utils.Goroutines.Register(c.name, c.heartbeatTimeout)
+	runCtx = utils.WithHeartbeat(runCtx, func() {
+		c.lastHeartbeat.Store(time.Now().UnixNano())
+		registration.Beat()
+	})
+
+	go func() {
+		defer registration.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				utils.CapturePanic(c.name, r, debug.Stack(), nil)
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- c.run(runCtx)
+	}()
+
+	if c.heartbeatTimeout <= 0 {
+		return <-done
+	}
+
+	ticker := time.NewTicker(c.heartbeatTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-ticker.C:
+			last := time.Unix(0, c.lastHeartbeat.Load())
+			if time.Since(last) <= c.heartbeatTimeout {
+				continue
+			}
+
+			// Ask the stuck instance to stop
+			cancel()
+			missed := fmt.Errorf("missed heartbeats for %s (last heartbeat %s ago)",
+				c.heartbeatTimeout, time.Since(last).Round(time.Second))
+			select {
+			case <-done:
+				return missed
+			case <-time.After(10 * time.Second):
+			}
+
+			// A fresh instance would work on the same directories and process
+			// state alongside this one, e.g. an orchestrator still extracting a
+			// large archive, so it only starts once this one returns
+			s.logger.WithField("component", c.name).Warn("Stalled component did not stop, waiting for it before restarting")
+			s.raiseComponentStalled(c.name, missed)
+			select {
+			case <-done:
+			case <-ctx.Done():
+			}
+			return missed
+		}
+	}
+}
+
+// raiseComponentDown sends a ComponentDown alert through the alert manager
+func (s *Supervisor) raiseComponentDown(name string, err error, restarts int, backoff time.Duration) {
+	if s.alertManager == nil {
+		return
+	}
+
+	message := err.Error()
+	if len(message) > 300 {
+		message = message[:300] + "..."
+	}
+
+	s.alertManager.RaiseAlert(
+		AlertTypeComponentDown,
+		AlertLevelCritical,
+		name,
+		fmt.Sprintf("Component %s restarted", name),
+		fmt.Sprintf("%s (restart #%d in %s)", message, restarts, backoff),
+		map[string]interface{}{
+			"restarts": restarts,
+			"backoff":  backoff.String(),
+		},
+	)
+}
+
+// raiseComponentStalled alerts that a component missed its heartbeats and has
+// not stopped, so it cannot be restarted yet
+func (s *Supervisor) raiseComponentStalled(name string, err error) {
+	if s.alertManager == nil {
+		return
+	}
+
+	s.alertManager.RaiseAlert(
+		AlertTypeComponentDown,
+		AlertLevelCritical,
+		name,
+		fmt.Sprintf("Component %s stalled", name),
+		fmt.Sprintf("%s; it did not stop when asked and is restarted once it returns", err.Error()),
+		nil,
+	)
+}
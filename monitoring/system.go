@@ -3,6 +3,7 @@ package monitoring
 import (
 	"bufio"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -21,6 +22,18 @@ type SystemResourceMonitor struct {
 	lastCPUCheck     time.Time
 	processStartTime time.Time
 	monitoringActive bool
+	diskPaths        map[string]string // Directories whose volume is reported, by name
+	lastDiskIO       map[string]diskCounters
+	lastDiskIOCheck  time.Time
+}
+
+// defaultDiskPaths are the directories whose volume is always reported
+var defaultDiskPaths = map[string]string{
+	"root":       ".", // Current directory (project root)
+	"temp":       "temp",
+	"data":       "data",
+	"logs":       "logs",
+	"extraction": "app/extraction",
 }
 
 // CPUStats represents CPU utilization statistics
@@ -45,6 +58,11 @@ type MemoryStats struct {
 	NumGC          uint32  `json:"num_gc"`
 	PauseTotalNs   uint64  `json:"pause_total_ns"`
 	LastGC         time.Time `json:"last_gc"`
+	// Memory the runtime counts against GOMEMLIMIT and how close it is to it;
+	// the limit is 0 when none is set
+	RuntimeMB          float64 `json:"runtime_mb"`
+	MemoryLimitMB      float64 `json:"memory_limit_mb"`
+	MemoryLimitPercent float64 `json:"memory_limit_percent"`
 }
 
 // DiskStats represents disk usage statistics
@@ -78,18 +96,32 @@ type SystemResourceSnapshot struct {
 	CPU        CPUStats     `json:"cpu"`
 	Memory     MemoryStats  `json:"memory"`
 	Disk       map[string]DiskStats `json:"disk"`
+	DiskIO     map[string]DiskIOStats `json:"disk_io"` // By volume, like Disk; empty at the first snapshot
+	Network    NetworkIO    `json:"network"`
 	Process    ProcessStats `json:"process"`
 	LoadAvg    []float64    `json:"load_average"`
 }
 
 // NewSystemResourceMonitor creates a new system resource monitor
 func NewSystemResourceMonitor(logger *utils.Logger) *SystemResourceMonitor {
-	return &SystemResourceMonitor{
+	srm := &SystemResourceMonitor{
 		logger:           logger,
 		lastCPUTimes:     make(map[string]uint64),
 		processStartTime: time.Now(),
 		monitoringActive: true,
+		diskPaths:        make(map[string]string, len(defaultDiskPaths)),
 	}
+	for name, path := range defaultDiskPaths {
+		srm.diskPaths[name] = path
+	}
+	return srm
+}
+
+// SetDiskPath adds a directory whose volume is reported under name, such as
+// the Local Bot API working directory or the backup directory, which may be
+// separate mounts. Call it before the health monitor starts
+func (srm *SystemResourceMonitor) SetDiskPath(name, path string) {
+	srm.diskPaths[name] = path
 }
 
 // GetSystemSnapshot captures a complete system resource snapshot
@@ -112,27 +144,29 @@ func (srm *SystemResourceMonitor) GetSystemSnapshot() (*SystemResourceSnapshot,
 	memStats := srm.getMemoryStats()
 	snapshot.Memory = *memStats
 
-	// Get disk stats for important paths
-	importantPaths := []string{
-		".", // Current directory (project root)
-		"temp",
-		"data",
-		"logs",
-		"app/extraction",
-	}
-
-	for _, path := range importantPaths {
+	// Get disk stats for the monitored directories
+	for name, path := range srm.diskPaths {
 		if diskStats, err := srm.getDiskStats(path); err == nil {
-			cleanPath := strings.ReplaceAll(path, "/", "_")
-			if cleanPath == "." {
-				cleanPath = "root"
-			}
-			snapshot.Disk[cleanPath] = *diskStats
+			snapshot.Disk[name] = *diskStats
 		} else {
 			srm.logger.WithError(err).WithField("path", path).Debug("Failed to get disk stats")
 		}
 	}
 
+	// Get I/O of the monitored volumes' devices
+	if diskIO, err := srm.getDiskIOStats(); err == nil {
+		snapshot.DiskIO = diskIO
+	} else {
+		srm.logger.WithError(err).Debug("Failed to get disk I/O stats")
+	}
+
+	// Get network totals; the process's own accounting is kept even without /proc
+	networkStats, err := srm.getNetworkStats()
+	if err != nil {
+		srm.logger.WithError(err).Debug("Failed to get network stats")
+	}
+	snapshot.Network = *networkStats
+
 	// Get process stats
 	processStats, err := srm.getProcessStats()
 	if err != nil {
@@ -245,6 +279,14 @@ func (srm *SystemResourceMonitor) getMemoryStats() *MemoryStats {
 		stats.LastGC = time.Unix(0, int64(m.LastGC))
 	}
 
+	// The runtime's memory limit covers all mapped memory not returned to the OS
+	runtimeBytes := m.Sys - m.HeapReleased
+	stats.RuntimeMB = float64(runtimeBytes) / 1024 / 1024
+	if limit := utils.CurrentMemoryLimit(); limit != math.MaxInt64 && limit > 0 {
+		stats.MemoryLimitMB = float64(limit) / 1024 / 1024
+		stats.MemoryLimitPercent = float64(runtimeBytes) / float64(limit) * 100
+	}
+
 	return stats
 }
 
@@ -252,15 +294,9 @@ func (srm *SystemResourceMonitor) getMemoryStats() *MemoryStats {
 func (srm *SystemResourceMonitor) getDiskStats(path string) (*DiskStats, error) {
 	var stat syscall.Statfs_t
 	
-	// Get absolute path
-	absPath, err := filepath.Abs(path)
+	absPath, err := existingPath(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get absolute path: %w", err)
-	}
-
-	// Check if path exists
-	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("path does not exist: %s", absPath)
+		return nil, err
 	}
 
 	err = syscall.Statfs(absPath, &stat)
@@ -289,6 +325,26 @@ func (srm *SystemResourceMonitor) getDiskStats(path string) (*DiskStats, error)
 	return stats, nil
 }
 
+// existingPath returns the absolute path, or that of its nearest existing
+// parent: a directory not created yet, such as the backup directory before
+// the first backup, will land on that parent's volume
+func existingPath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	for {
+		if _, err := os.Stat(absPath); !os.IsNotExist(err) {
+			return absPath, nil
+		}
+		parent := filepath.Dir(absPath)
+		if parent == absPath {
+			return "", fmt.Errorf("path does not exist: %s", path)
+		}
+		absPath = parent
+	}
+}
+
 // getProcessStats gets process-specific statistics
 func (srm *SystemResourceMonitor) getProcessStats() (*ProcessStats, error) {
 	pid := os.Getpid()
@@ -422,6 +478,12 @@ func (srm *SystemResourceMonitor) GetResourceSummary() (string, error) {
 		return "", fmt.Errorf("failed to get system snapshot: %w", err)
 	}
 
+	memoryLimit := ""
+	if snapshot.Memory.MemoryLimitMB > 0 {
+		memoryLimit = fmt.Sprintf("\n• Memory limit: %.1f / %.1f MB (%.1f%%)",
+			snapshot.Memory.RuntimeMB, snapshot.Memory.MemoryLimitMB, snapshot.Memory.MemoryLimitPercent)
+	}
+
 	summary := fmt.Sprintf(`📊 **System Resources**
 
 💾 **Memory Usage:**
@@ -429,7 +491,7 @@ func (srm *SystemResourceMonitor) GetResourceSummary() (string, error) {
 • System: %.1f MB  
 • Heap: %.1f MB
 • Stack: %.1f MB
-• GC: %d collections
+• GC: %d collections%s
 
 ⚡ **CPU Usage:**
 • Total: %.1f%%
@@ -441,6 +503,7 @@ func (srm *SystemResourceMonitor) GetResourceSummary() (string, error) {
 		snapshot.Memory.HeapAllocMB,
 		snapshot.Memory.StackInUseMB,
 		snapshot.Memory.NumGC,
+		memoryLimit,
 		snapshot.CPU.TotalPercent,
 		snapshot.CPU.UserPercent,
 		snapshot.CPU.SystemPercent,
@@ -0,0 +1,110 @@
+package monitoring
+
+import (
+	"context"
+	"time"
+
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/utils"
+)
+
+// QueueCounter counts tasks by status for the systemd status line
+type QueueCounter interface {
+	GetTaskCountByStatus(status models.TaskStatus) (int, error)
+}
+
+// SystemdPolicy sets how often systemd is updated
+type SystemdPolicy struct {
+	// StatusInterval is how often STATUS= is refreshed; WATCHDOG=1 is sent at
+	// least twice per WatchdogSec regardless
+	StatusInterval time.Duration
+}
+
+// DefaultSystemdPolicy returns the policy used in production
+func DefaultSystemdPolicy() SystemdPolicy {
+	return SystemdPolicy{StatusInterval: 30 * time.Second}
+}
+
+// SystemdNotifier reports startup, queue depth and liveness to systemd, so
+// a Type=notify unit with WatchdogSec= restarts the bot when it hangs
+type SystemdNotifier struct {
+	logger   *utils.Logger
+	queue    QueueCounter
+	watchdog *Watchdog
+	policy   SystemdPolicy
+}
+
+// NewSystemdNotifier creates a notifier; WATCHDOG=1 is only sent while the
+// watchdog's loops are live
+func NewSystemdNotifier(logger *utils.Logger, queue QueueCounter, watchdog *Watchdog, policy SystemdPolicy) *SystemdNotifier {
+	return &SystemdNotifier{
+		logger:   logger,
+		queue:    queue,
+		watchdog: watchdog,
+		policy:   policy,
+	}
+}
+
+// Ready tells systemd that startup completed
+func (sn *SystemdNotifier) Ready() {
+	sn.notify(utils.SdNotifyReady + "\n" + sn.status())
+}
+
+// Stopping tells systemd that a graceful shutdown started
+func (sn *SystemdNotifier) Stopping() {
+	sn.notify(utils.SdNotifyStopping + "\n" + utils.SdStatus("Shutting down"))
+}
+
+// Run refreshes the status line and pets systemd's watchdog until ctx is
+// cancelled. Once a tracked loop is stale the pets stop, so systemd kills
+// and restarts the process after WatchdogSec
+func (sn *SystemdNotifier) Run(ctx context.Context) error {
+	interval := sn.policy.StatusInterval
+	watchdogInterval := utils.SdWatchdogInterval()
+	if watchdogInterval > 0 && watchdogInterval/2 < interval {
+		interval = watchdogInterval / 2
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		utils.Heartbeat(ctx)
+
+		state := sn.status()
+		if watchdogInterval > 0 {
+			if sn.watchdog == nil || sn.watchdog.Status().Healthy {
+				state = utils.SdNotifyWatchdog + "\n" + state
+			} else {
+				state = utils.SdStatus("Unhealthy: a processing loop stopped making progress")
+			}
+		}
+		sn.notify(state)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// status describes the queue for systemctl status
+func (sn *SystemdNotifier) status() string {
+	counts := make(map[models.TaskStatus]int)
+	for _, status := range []models.TaskStatus{models.TaskStatusPending, models.TaskStatusDownloading, models.TaskStatusDownloaded} {
+		count, err := sn.queue.GetTaskCountByStatus(status)
+		if err != nil {
+			return utils.SdStatus("Running (queue unavailable)")
+		}
+		counts[status] = count
+	}
+	return utils.SdStatus("Queue: %d pending, %d downloading, %d downloaded",
+		counts[models.TaskStatusPending], counts[models.TaskStatusDownloading], counts[models.TaskStatusDownloaded])
+}
+
+func (sn *SystemdNotifier) notify(state string) {
+	if _, err := utils.SdNotify(state); err != nil {
+		sn.logger.WithError(err).Warn("Failed to notify systemd")
+	}
+}
@@ -0,0 +1,220 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"telegram-archive-bot/utils"
+)
+
+// Loops the watchdog tracks
+const (
+	WatchdogOrchestratorLoop = "orchestrator_loop"
+	WatchdogTelegramPoll     = "telegram_poll"
+)
+
+// WatchdogPolicy sets where liveness is published and when a loop counts as wedged
+type WatchdogPolicy struct {
+	// StatusFile is rewritten every Interval while all loops are live; empty
+	// disables the file
+	StatusFile string
+	Interval   time.Duration
+}
+
+// DefaultWatchdogPolicy returns the policy used when nothing is configured
+func DefaultWatchdogPolicy() WatchdogPolicy {
+	return WatchdogPolicy{
+		StatusFile: "data/heartbeat.json",
+		Interval:   30 * time.Second,
+	}
+}
+
+// LoopStatus is the liveness of one tracked loop
+type LoopStatus struct {
+	Name       string        `json:"name"`
+	LastMark   time.Time     `json:"last_mark"`
+	Age        time.Duration `json:"age_ns"`
+	StaleAfter time.Duration `json:"stale_after_ns"`
+	Stale      bool          `json:"stale"`
+}
+
+// WatchdogStatus is what the status file and /healthz report
+type WatchdogStatus struct {
+	Healthy   bool            `json:"healthy"`
+	Build     utils.BuildInfo `json:"build"`
+	PID       int             `json:"pid"`
+	StartedAt time.Time       `json:"started_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Loops     []LoopStatus    `json:"loops"`
+}
+
+// Watchdog publishes when the main loops last made progress, so systemd or
+// an external monitor can restart the process when a loop wedges without
+// the process exiting. Unlike the supervisor, which restarts components from
+// inside, it covers hangs that take the whole process down with them
+type Watchdog struct {
+	logger    *utils.Logger
+	policy    WatchdogPolicy
+	startedAt time.Time
+
+	mutex sync.Mutex
+	loops map[string]*trackedLoop
+}
+
+type trackedLoop struct {
+	lastMark   time.Time
+	staleAfter time.Duration
+	// tracking counts the Track calls in effect; loops are only judged while
+	// they are supposed to run, e.g. not on an HA standby
+	tracking int
+}
+
+// NewWatchdog creates a watchdog; loops are judged once tracked
+func NewWatchdog(logger *utils.Logger, policy WatchdogPolicy) *Watchdog {
+	return &Watchdog{
+		logger:    logger,
+		policy:    policy,
+		startedAt: time.Now(),
+		loops:     make(map[string]*trackedLoop),
+	}
+}
+
+// Track judges the loop until ctx is cancelled: it is stale when it has not
+// been marked for staleAfter. Tracking starts the clock
+func (w *Watchdog) Track(ctx context.Context, name string, staleAfter time.Duration) {
+	w.mutex.Lock()
+	loop := w.loop(name)
+	loop.staleAfter = staleAfter
+	loop.tracking++
+	loop.lastMark = time.Now()
+	w.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		w.mutex.Lock()
+		loop.tracking--
+		w.mutex.Unlock()
+	}()
+}
+
+// Mark records that the loop made progress
+func (w *Watchdog) Mark(name string) {
+	w.mutex.Lock()
+	w.loop(name).lastMark = time.Now()
+	w.mutex.Unlock()
+}
+
+// loop returns the named loop, creating it; w.mutex must be held
+func (w *Watchdog) loop(name string) *trackedLoop {
+	loop, ok := w.loops[name]
+	if !ok {
+		loop = &trackedLoop{}
+		w.loops[name] = loop
+	}
+	return loop
+}
+
+// Status returns the liveness of the tracked loops
+func (w *Watchdog) Status() WatchdogStatus {
+	now := time.Now()
+	status := WatchdogStatus{
+		Healthy:   true,
+		Build:     utils.CurrentBuild(),
+		PID:       os.Getpid(),
+		StartedAt: w.startedAt,
+		UpdatedAt: now,
+		Loops:     []LoopStatus{},
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for name, loop := range w.loops {
+		if loop.tracking == 0 {
+			continue
+		}
+		age := now.Sub(loop.lastMark)
+		stale := loop.staleAfter > 0 && age > loop.staleAfter
+		if stale {
+			status.Healthy = false
+		}
+		status.Loops = append(status.Loops, LoopStatus{
+			Name:       name,
+			LastMark:   loop.lastMark,
+			Age:        age,
+			StaleAfter: loop.staleAfter,
+			Stale:      stale,
+		})
+	}
+	sort.Slice(status.Loops, func(i, j int) bool { return status.Loops[i].Name < status.Loops[j].Name })
+	return status
+}
+
+// Run rewrites the status file every interval while all loops are live. Once
+// one is stale the file is written a last time with "healthy": false and then
+// left alone, so monitors can go by either its content or its age
+func (w *Watchdog) Run(ctx context.Context) error {
+	if w.policy.StatusFile == "" {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ticker := time.NewTicker(w.policy.Interval)
+	defer ticker.Stop()
+
+	wasHealthy := true
+	for {
+		utils.Heartbeat(ctx)
+
+		status := w.Status()
+		if status.Healthy || wasHealthy {
+			if err := w.writeStatusFile(status); err != nil {
+				w.logger.WithError(err).WithField("file", w.policy.StatusFile).Warn("Failed to write heartbeat file")
+			}
+		}
+		if !status.Healthy && wasHealthy {
+			for _, loop := range status.Loops {
+				if loop.Stale {
+					w.logger.WithField("loop", loop.Name).
+						WithField("last_mark", loop.LastMark).
+						Error("Loop stopped making progress; heartbeat file no longer updated")
+				}
+			}
+		}
+		wasHealthy = status.Healthy
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watchdog) writeStatusFile(status WatchdogStatus) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(w.policy.StatusFile, append(data, '\n'), 0644)
+}
+
+// ServeHTTP answers /healthz: 200 with the status while all loops are live,
+// 503 once one is stale
+func (w *Watchdog) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	status := w.Status()
+	code := http.StatusOK
+	if !status.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(code)
+	json.NewEncoder(rw).Encode(status)
+}
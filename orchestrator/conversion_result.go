@@ -0,0 +1,102 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"telegram-archive-bot/app/extraction/convert"
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/storage"
+)
+
+// conversionManifestFile is where the convert stage writes its result manifest
+const conversionManifestFile = "conversion_manifest.json"
+
+// prepareConversionManifest points the convert stage at a fresh manifest file
+func (so *SequentialOrchestrator) prepareConversionManifest() (string, error) {
+	path, err := filepath.Abs(filepath.Join(progressDir, conversionManifestFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve manifest path: %w", err)
+	}
+
+	os.Remove(path)
+	os.Setenv(convert.ManifestEnvFile, path)
+	return path, nil
+}
+
+// downloadedTaskIDs returns the DOWNLOADED archive tasks: their files are
+// what the stage directories hold. Text files take the fast path instead
+func (so *SequentialOrchestrator) downloadedTaskIDs() ([]string, error) {
+	var taskIDs []string
+	err := so.taskStore.EachByStatus(models.TaskStatusDownloaded, func(task *models.Task) error {
+		if task.FileType != storage.FileTypeText {
+			taskIDs = append(taskIDs, task.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get downloaded tasks: %w", err)
+	}
+	return taskIDs, nil
+}
+
+// recordConversionResult parses the manifest of the pass that just finished
+// and stores it on the tasks whose files were converted, with the directory
+// its input was kept in, if any
+func (so *SequentialOrchestrator) recordConversionResult(path, inputDir string, taskIDs []string) (*storage.ConversionResult, error) {
+	defer os.Unsetenv(convert.ManifestEnvFile)
+	defer os.Remove(path)
+
+	manifest, err := convert.ReadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("convert stage wrote no result manifest")
+	}
+
+	// Per-domain counts go to their own table; the stored manifest stays small
+	domainCredentials := manifest.DomainCredentials
+	manifest.DomainCredentials = nil
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode result manifest: %w", err)
+	}
+
+	result := &storage.ConversionResult{
+		StartedAt:      manifest.StartedAt,
+		FinishedAt:     manifest.FinishedAt,
+		FilesProcessed: manifest.FilesProcessed,
+		FilesProduced:  len(manifest.FilesProduced),
+		Credentials:    manifest.Credentials,
+		Domains:        manifest.Domains,
+		Warnings:       len(manifest.Warnings) + manifest.DroppedWarnings,
+		Manifest:       string(data),
+		RecordedAt:     time.Now(),
+		ParserVersion:  manifest.ParserVersion,
+		InputDir:       inputDir,
+
+		DomainCredentials: domainCredentials,
+	}
+	if err := so.taskStore.SaveConversionResult(result, taskIDs); err != nil {
+		return nil, err
+	}
+
+	so.logger.WithFields(logrus.Fields{
+		"result_id":       result.ID,
+		"parser_version":  manifest.ParserVersion,
+		"tasks":           len(taskIDs),
+		"files_processed": manifest.FilesProcessed,
+		"credentials":     manifest.Credentials,
+		"domains":         manifest.Domains,
+		"warnings":        result.Warnings,
+		"outcomes":        manifest.Outcomes,
+	}).Info("Conversion result recorded")
+
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"telegram-archive-bot/app/extraction"
+	"telegram-archive-bot/app/extraction/convert"
+	"telegram-archive-bot/app/extraction/dedup"
+)
+
+// dedupRetryInterval spaces failed rebuilds of the duplicate filter, so an
+// unreachable store database is not retried after every store stage
+const dedupRetryInterval = time.Hour
+
+// configureLineDedup points the convert stage at the duplicate filter. A
+// missing filter, or one sized for other settings, is replaced by an empty
+// one that the next rebuild fills from the store
+func (so *SequentialOrchestrator) configureLineDedup() {
+	if !so.config.DedupEnabled {
+		os.Unsetenv(convert.DedupEnvFile)
+		return
+	}
+
+	path := so.config.DedupFilterPath
+	info, err := dedup.Inspect(path)
+	if err != nil || !info.Matches(so.config.DedupCapacity, so.config.DedupFalsePositive) {
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			so.logger.WithError(err).WithField("path", path).Warn("Replacing unreadable duplicate filter")
+		}
+		if err := so.createLineDedup(path); err != nil {
+			so.logger.WithError(err).WithField("path", path).Warn("Duplicate filter disabled for this pass")
+			os.Unsetenv(convert.DedupEnvFile)
+			return
+		}
+	}
+	os.Setenv(convert.DedupEnvFile, path)
+}
+
+// createLineDedup writes an empty duplicate filter sized from the config
+func (so *SequentialOrchestrator) createLineDedup(path string) error {
+	filter, err := dedup.New(so.config.DedupCapacity, so.config.DedupFalsePositive)
+	if err != nil {
+		return err
+	}
+	so.logger.WithFields(logrus.Fields{
+		"path":       path,
+		"capacity":   so.config.DedupCapacity,
+		"size_bytes": filter.SizeBytes(),
+	}).Info("Creating duplicate filter")
+	return filter.Save(path)
+}
+
+// rebuildLineDedupIfDue rebuilds the duplicate filter from the store database
+// once it is older than the rebuild interval. It runs right after a store
+// stage, when every converted line is in the database; rebuilding also sheds
+// the keys of lines that were converted but never stored
+func (so *SequentialOrchestrator) rebuildLineDedupIfDue(ctx context.Context) {
+	if !so.config.DedupEnabled {
+		return
+	}
+
+	path := so.config.DedupFilterPath
+	info, err := dedup.Inspect(path)
+	if err == nil && info.Matches(so.config.DedupCapacity, so.config.DedupFalsePositive) &&
+		!info.RebuiltAt.IsZero() && time.Since(info.RebuiltAt) < so.config.DedupRebuildInterval {
+		return
+	}
+	if time.Since(so.dedupAttemptAt) < dedupRetryInterval {
+		return
+	}
+	so.dedupAttemptAt = time.Now()
+
+	filter, err := dedup.New(so.config.DedupCapacity, so.config.DedupFalsePositive)
+	if err != nil {
+		so.logger.WithError(err).Warn("Failed to create duplicate filter")
+		return
+	}
+
+	so.logger.WithField("path", path).Info("Rebuilding duplicate filter from the store database")
+	startTime := time.Now()
+	logFunc := func(format string, args ...interface{}) {
+		so.logger.Debugf(format, args...)
+	}
+	lines, err := extraction.RebuildDedupFilter(ctx, filter, logFunc)
+	if err != nil {
+		// The current filter stays in use until a rebuild succeeds
+		so.logger.WithError(err).Warn("Failed to rebuild duplicate filter")
+		return
+	}
+	filter.MarkRebuilt(startTime)
+
+	if err := filter.Save(path); err != nil {
+		so.logger.WithError(err).WithField("path", path).Warn("Failed to save duplicate filter")
+		return
+	}
+
+	fields := logrus.Fields{
+		"lines":            lines,
+		"capacity":         so.config.DedupCapacity,
+		"duration_seconds": time.Since(startTime).Seconds(),
+	}
+	if filter.Full() {
+		so.logger.WithFields(fields).Warn("Store holds more lines than the duplicate filter is sized for, raise DEDUP_CAPACITY")
+		return
+	}
+	so.logger.WithFields(fields).Info("Duplicate filter rebuilt")
+}
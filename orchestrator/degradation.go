@@ -0,0 +1,96 @@
+package orchestrator
+
+import (
+	"fmt"
+	"time"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// StageOperation is the queued operation name for a stage deferred while one
+// of its directories was unavailable
+const StageOperation = "pipeline_stage"
+
+// stageDependencies are the directories each stage reads and writes
+var stageDependencies = map[string][]string{
+	storage.StageExtraction: {"app/extraction/files/all", "app/extraction/files/pass"},
+	storage.StageConversion: {"app/extraction/files/pass", "app/extraction/files/txt"},
+}
+
+// SetDegradation registers the stages' dependencies with gdm, which recovers
+// them when they fail. A stage whose directory stays down is queued instead
+// of run, and the loop runs it again once gdm sees the directory back
+func (so *SequentialOrchestrator) SetDegradation(gdm *utils.GracefulDegradationManager) {
+	so.degradation = gdm
+
+	registered := make(map[string]bool)
+	for _, stage := range []string{storage.StageExtraction, storage.StageConversion} {
+		for _, dir := range stageDependencies[stage] {
+			if !registered[dir] {
+				gdm.RegisterDependency(dir, "directory", time.Minute, utils.FallbackQueue)
+				registered[dir] = true
+			}
+		}
+	}
+	gdm.RegisterDependency(utils.PassFileDependency, "file", 5*time.Minute, utils.FallbackSkip)
+	gdm.RegisterOperationHandler(StageOperation, so.redispatchStage)
+}
+
+// stageDeferred reports whether stage has to wait for one of its
+// directories. The stage is queued once, however many cycles it waits
+func (so *SequentialOrchestrator) stageDeferred(stage string, fileCount int) bool {
+	if so.degradation == nil {
+		return false
+	}
+	for _, dir := range stageDependencies[stage] {
+		status, err := so.degradation.GetDependencyStatus(dir)
+		if err != nil || status == utils.StatusAvailable || status == utils.StatusUnknown {
+			continue
+		}
+		if !so.stageQueued(stage) {
+			err := so.degradation.HandleUnavailableDependency(dir, StageOperation, map[string]interface{}{
+				"stage":      stage,
+				"file_count": fileCount,
+			})
+			so.logger.WithError(err).
+				WithField("stage", stage).
+				WithField("dependency", dir).
+				Warn("Stage deferred until its directory is available")
+		}
+		return true
+	}
+	return false
+}
+
+// stageQueued reports whether a deferred run of stage is already queued
+func (so *SequentialOrchestrator) stageQueued(stage string) bool {
+	for _, op := range so.degradation.GetQueuedOperations() {
+		if op.Operation == StageOperation && op.Stage == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// redispatchStage wakes the processing loop for a deferred stage whose
+// directories are back. The loop runs it rather than the caller, since stages
+// share the process environment and must not run alongside a cycle
+func (so *SequentialOrchestrator) redispatchStage(op utils.QueuedOperation) error {
+	if _, ok := stageDependencies[op.Stage]; !ok {
+		return fmt.Errorf("unknown stage %q", op.Stage)
+	}
+	if !so.running.Load() {
+		return fmt.Errorf("orchestrator is not running")
+	}
+
+	select {
+	case so.wake <- struct{}{}:
+	default:
+		// A wake-up is already pending
+	}
+	so.logger.WithField("stage", op.Stage).
+		WithField("operation_id", op.ID).
+		Info("Re-dispatching deferred stage")
+	return nil
+}
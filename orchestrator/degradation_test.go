@@ -0,0 +1,69 @@
+package orchestrator
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// A stage deferred before a restart is restored from queued_operations the way
+// main.go wires the manager, and its re-dispatch wakes the processing loop
+func TestDeferredStageRestoredOnStart(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	taskStore := storage.NewTaskStore(db)
+
+	passDir := stageDependencies[storage.StageConversion][0]
+	for _, op := range []utils.QueuedOperation{
+		{ID: "op_1", QueuedAt: time.Now().Add(-time.Hour)},
+		{ID: "op_expired", QueuedAt: time.Now().Add(-25 * time.Hour)},
+	} {
+		op.DependencyName = passDir
+		op.Operation = StageOperation
+		op.Stage = storage.StageConversion
+		op.Parameters = map[string]interface{}{"stage": storage.StageConversion}
+		op.MaxWaitTime = 24 * time.Hour
+		if err := taskStore.SaveQueuedOperation(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	so := NewSequentialOrchestrator(logger, &utils.Config{}, taskStore, nil)
+	gdm := utils.NewGracefulDegradationManager(&utils.Logger{Logger: logger})
+	so.SetDegradation(gdm)
+	gdm.SetOperationStore(taskStore)
+
+	restored := gdm.GetQueuedOperations()
+	if len(restored) != 1 || restored[0].ID != "op_1" {
+		t.Fatalf("restored operations = %+v, want op_1", restored)
+	}
+	if !so.stageQueued(storage.StageConversion) {
+		t.Error("restored stage not seen as queued, so it would be queued twice")
+	}
+	if persisted, _ := taskStore.LoadQueuedOperations(passDir); len(persisted) != 1 {
+		t.Errorf("persisted operations = %+v, want the expired one deleted", persisted)
+	}
+
+	if err := so.redispatchStage(restored[0]); err == nil {
+		t.Error("stage re-dispatched while the orchestrator is not running")
+	}
+	so.running.Store(true)
+	if err := so.redispatchStage(restored[0]); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-so.wake:
+	default:
+		t.Fatal("re-dispatch did not wake the processing loop")
+	}
+}
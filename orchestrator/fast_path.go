@@ -0,0 +1,245 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+const (
+	// fastPathDir holds submitted text files, which skip extraction
+	fastPathDir = "app/extraction/files/fastpath"
+	// fastPathRejectDir is where text files that fail validation are moved
+	fastPathRejectDir = "app/extraction/files/errors"
+	// fastPathSampleSize is how much of a text file validation reads
+	fastPathSampleSize = 64 << 10
+	// fastPathConvertedShare is the share of sampled lines that must already
+	// be url:login:password lines for a file to go straight to the store
+	fastPathConvertedShare = 0.9
+)
+
+// convertedLine matches a credential line in the converted url:login:password
+// form; stealer log lines such as "URL: https://..." have a space after their label
+var convertedLine = regexp.MustCompile(`^\S+:\S+:\S`)
+
+// Routes of a validated text file
+const (
+	fastPathConvert = "convert" // Stealer log text, converted like extracted files
+	fastPathStore   = "store"   // Already converted, goes straight to the store stage
+)
+
+// runFastPathStage validates the submitted text files in files/fastpath/ and
+// converts them in a pass of their own, so they don't wait behind the
+// extraction of archives. Files already in the converted form are moved to
+// files/txt/ for the store stage, and files that are not text fail their task
+func (so *SequentialOrchestrator) runFastPathStage(ctx context.Context) error {
+	fileCount, err := so.countFilesInDirectory(fastPathDir)
+	if err != nil {
+		return fmt.Errorf("failed to count files in %s: %w", fastPathDir, err)
+	}
+	if fileCount == 0 {
+		return nil
+	}
+
+	inputBytes := so.directorySize(fastPathDir)
+	startTime := time.Now()
+
+	tasks, err := so.fastPathTasks()
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(fastPathDir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", fastPathDir, err)
+	}
+
+	var taskIDs []string
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") || utils.IsPartialFile(name) {
+			continue
+		}
+		path := filepath.Join(fastPathDir, name)
+		taskID := tasks[path]
+
+		route, err := validateTextFile(path)
+		switch {
+		case err != nil:
+			counts["rejected"]++
+			so.rejectTextFile(path, taskID, err)
+		case route == fastPathStore:
+			counts[fastPathStore]++
+			so.storeTextFile(path, taskID)
+		default:
+			counts[fastPathConvert]++
+			if taskID != "" {
+				taskIDs = append(taskIDs, taskID)
+			}
+		}
+	}
+
+	if counts[fastPathConvert] > 0 {
+		workers := so.stageWorkers(utils.StageConversions)
+		so.logger.WithField("file_count", counts[fastPathConvert]).
+			WithField("workers", workers).
+			Info("Converting text files on the fast path")
+		if err := so.convertPass(ctx, storage.StageFastPath, fastPathDir, taskIDs, workers); err != nil {
+			return err
+		}
+	}
+
+	duration := time.Since(startTime)
+	so.logger.WithFields(logrus.Fields{
+		"duration_seconds": duration.Seconds(),
+		"converted":        counts[fastPathConvert],
+		"stored":           counts[fastPathStore],
+		"rejected":         counts["rejected"],
+	}).Info("Fast path stage completed")
+
+	so.recordStageTiming(storage.StageFastPath, duration, fileCount, inputBytes)
+	return nil
+}
+
+// fastPathTasks maps the files in files/fastpath/ to their DOWNLOADED tasks
+func (so *SequentialOrchestrator) fastPathTasks() (map[string]string, error) {
+	tasks := make(map[string]string)
+	err := so.taskStore.EachByStatus(models.TaskStatusDownloaded, func(task *models.Task) error {
+		if task.FileType != storage.FileTypeText {
+			return nil
+		}
+		path, err := so.taskStore.GetTaskStoragePath(task.ID)
+		if err != nil {
+			return err
+		}
+		if path != "" {
+			tasks[filepath.Clean(path)] = task.ID
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fast path tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// validateTextFile reads the start of a submitted text file and returns its
+// route, or why it is not a text file
+func validateTextFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sample := make([]byte, fastPathSampleSize)
+	n, err := io.ReadFull(f, sample)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	sample = sample[:n]
+
+	// UTF-16 text is full of NUL bytes; the convert stage decodes it
+	if bytes.HasPrefix(sample, []byte{0xFF, 0xFE}) || bytes.HasPrefix(sample, []byte{0xFE, 0xFF}) {
+		return fastPathConvert, nil
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return "", fmt.Errorf("binary content in a .txt file")
+	}
+
+	lines := strings.Split(string(sample), "\n")
+	if n == fastPathSampleSize {
+		// The last line may be cut off
+		lines = lines[:len(lines)-1]
+	}
+	var total, converted int
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		total++
+		if convertedLine.MatchString(line) {
+			converted++
+		}
+	}
+	if total > 0 && float64(converted) >= float64(total)*fastPathConvertedShare {
+		return fastPathStore, nil
+	}
+	return fastPathConvert, nil
+}
+
+// storeTextFile moves an already converted text file to files/txt/, where
+// the store stage picks it up
+func (so *SequentialOrchestrator) storeTextFile(path, taskID string) {
+	dest, err := moveAside(path, "app/extraction/files/txt", taskID)
+	if err != nil {
+		so.logger.WithError(err).WithField("path", path).Warn("Failed to move converted text file to the store stage")
+		return
+	}
+	if taskID != "" {
+		if err := so.taskStore.SetTaskStoragePath(taskID, dest); err != nil {
+			so.logger.WithError(err).Warn("Failed to record task storage path")
+		}
+	}
+	so.logger.WithField("task_id", taskID).
+		WithField("path", dest).
+		Info("Text file already converted, sent to the store stage")
+}
+
+// rejectTextFile moves a text file that failed validation aside and fails its task
+func (so *SequentialOrchestrator) rejectTextFile(path, taskID string, reason error) {
+	entry := so.logger.WithField("task_id", taskID).WithField("path", path).WithError(reason)
+	dest, err := moveAside(path, fastPathRejectDir, taskID)
+	if err != nil {
+		entry.WithField("move_error", err.Error()).Warn("Failed to move rejected text file")
+	} else {
+		entry = entry.WithField("moved_to", dest)
+	}
+	entry.Warn("Text file rejected by fast path validation")
+
+	if taskID == "" {
+		return
+	}
+	if err := so.taskStore.RecordTaskError(storage.NewTaskError(taskID, storage.StageFastPath, 1, reason, 0)); err != nil {
+		so.logger.WithError(err).Warn("Failed to record task error")
+	}
+	if err := so.taskStore.UpdateStatus(taskID, models.TaskStatusFailed, reason.Error()); err != nil {
+		so.logger.WithError(err).WithField("task_id", taskID).Error("Failed to mark rejected text file's task as FAILED")
+	}
+}
+
+// moveAside moves a file into dir, adding the task ID to its name when the
+// name is taken
+func moveAside(path, dir, taskID string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := filepath.Base(path)
+	dest := filepath.Join(dir, name)
+	if _, err := os.Stat(dest); err == nil {
+		ext := filepath.Ext(name)
+		suffix := taskID
+		if suffix == "" {
+			suffix = time.Now().Format("20060102150405")
+		}
+		dest = filepath.Join(dir, fmt.Sprintf("%s_%s%s", strings.TrimSuffix(name, ext), suffix, ext))
+	}
+	if err := os.Rename(path, dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
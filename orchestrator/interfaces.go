@@ -0,0 +1,35 @@
+package orchestrator
+
+import (
+	"time"
+
+	"telegram-archive-bot/app/extraction"
+	"telegram-archive-bot/app/extraction/progress"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// Notifier is what the orchestrator needs from the Telegram bot
+type Notifier interface {
+	// SendDocument delivers the store stage's files to the admin
+	extraction.BotFileSender
+	SendCompletionNotifications() error
+	UpdateProgressMessages() error
+	SetStageProgress(report *progress.Report)
+}
+
+// Store is what the orchestrator reads from and records in the task store
+type Store interface {
+	storage.TaskRepository
+	RecordStageTiming(stage, taskID string, duration time.Duration, items int, bytes int64) error
+	SaveConversionResult(result *storage.ConversionResult, taskIDs []string) error
+	GetShortID(taskID string) (string, error)
+	GetUnrecordedLedgerRows(sink string, since time.Time, limit int) ([]utils.LedgerRow, error)
+	MarkLedgerRecorded(sink string, taskIDs []string) error
+	GetRunningReprocessCampaign() (*storage.ReprocessCampaign, error)
+	NextReprocessItem(campaignID int64) (*storage.ReprocessItem, error)
+	FinishReprocessItem(item *storage.ReprocessItem, newResultID int64, passErr error) error
+	GetTaskStoragePath(taskID string) (string, error)
+	SetTaskStoragePath(taskID, path string) error
+	RecordTaskError(taskErr *storage.TaskError) error
+}
@@ -0,0 +1,56 @@
+package orchestrator
+
+import (
+	"time"
+
+	"telegram-archive-bot/utils"
+)
+
+const (
+	// ledgerBatchSize is the most rows appended to a ledger per cycle
+	ledgerBatchSize = 100
+	// ledgerBackfillWindow limits a newly enabled ledger to recent completions
+	ledgerBackfillWindow = 24 * time.Hour
+)
+
+// SetLedgers enables appending a row per completed task to each ledger
+func (so *SequentialOrchestrator) SetLedgers(ledgers []utils.LedgerSink) {
+	so.ledgers = ledgers
+}
+
+// appendLedgers adds newly completed tasks to every ledger. A ledger that
+// fails keeps its rows pending and is retried on the next cycle
+func (so *SequentialOrchestrator) appendLedgers() {
+	since := time.Now().Add(-ledgerBackfillWindow)
+	for _, ledger := range so.ledgers {
+		rows, err := so.taskStore.GetUnrecordedLedgerRows(ledger.Name(), since, ledgerBatchSize)
+		if err != nil {
+			so.logger.WithError(err).WithField("ledger", ledger.Name()).Warn("Failed to load ledger rows")
+			continue
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		if err := ledger.Append(rows); err != nil {
+			so.logger.WithError(err).
+				WithField("ledger", ledger.Name()).
+				WithField("rows", len(rows)).
+				Warn("Failed to append to completion ledger, will retry")
+			continue
+		}
+
+		taskIDs := make([]string, 0, len(rows))
+		for _, row := range rows {
+			taskIDs = append(taskIDs, row.TaskID)
+		}
+		if err := so.taskStore.MarkLedgerRecorded(ledger.Name(), taskIDs); err != nil {
+			so.logger.WithError(err).WithField("ledger", ledger.Name()).Warn("Failed to mark ledger rows as recorded")
+			continue
+		}
+
+		so.logger.WithField("ledger", ledger.Name()).
+			WithField("rows", len(rows)).
+			Info("Appended completed tasks to ledger")
+	}
+}
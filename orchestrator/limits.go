@@ -0,0 +1,17 @@
+package orchestrator
+
+import (
+	"telegram-archive-bot/utils"
+)
+
+// SetStageLimits sets how many archives are extracted and files converted at
+// once; without limits both stages work through their files one at a time
+func (so *SequentialOrchestrator) SetStageLimits(limits *utils.StageLimits) {
+	so.limits = limits
+}
+
+// stageWorkers returns the current limit of a stage for its next pass, so a
+// change made with /limits applies from then on
+func (so *SequentialOrchestrator) stageWorkers(stage string) int {
+	return so.limits.Limit(stage)
+}
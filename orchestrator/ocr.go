@@ -0,0 +1,115 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"telegram-archive-bot/app/extraction/extract"
+	"telegram-archive-bot/app/extraction/progress"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// ocrImageDir holds the screenshots copied out of archives during extraction
+const ocrImageDir = "app/extraction/files/images"
+
+// SetOCR enables text recognition of screenshots found in archives
+func (so *SequentialOrchestrator) SetOCR(engine *utils.OCREngine) {
+	so.ocr = engine
+}
+
+// configureImageCollection tells the extractor whether to copy screenshots
+// out of archives for the OCR stage
+func (so *SequentialOrchestrator) configureImageCollection() {
+	if so.ocr == nil {
+		os.Unsetenv(extract.ImagesEnvDir)
+		return
+	}
+	os.Setenv(extract.ImagesEnvDir, ocrImageDir)
+	os.Setenv(extract.ImagesEnvMax, strconv.Itoa(so.config.OCRMaxImages))
+	os.Setenv(extract.ImagesEnvMaxBytes, strconv.FormatInt(so.config.OCRMaxImageSize, 10))
+}
+
+// runOCRStage recognizes the text of collected screenshots (files/images/ →
+// files/pass/), so it goes through conversion like any extracted text file.
+// Images are removed once read; one that tesseract cannot read is dropped
+func (so *SequentialOrchestrator) runOCRStage(ctx context.Context) error {
+	if so.ocr == nil {
+		return nil
+	}
+
+	imageCount, err := so.countFilesInDirectory(ocrImageDir)
+	if err != nil {
+		return fmt.Errorf("failed to count files in %s: %w", ocrImageDir, err)
+	}
+	if imageCount == 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(ocrImageDir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", ocrImageDir, err)
+	}
+
+	so.logger.WithField("file_count", imageCount).Info("Starting OCR stage")
+
+	inputBytes := so.directorySize(ocrImageDir)
+	startTime := time.Now()
+	stopProgress := so.watchStageProgress(ctx, storage.StageOCR)
+	defer stopProgress()
+	reporter := progress.NewReporter(storage.StageOCR, imageCount)
+
+	passDir := "app/extraction/files/pass"
+	recognized, failed, attempted := 0, 0, 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || utils.IsPartialFile(name) {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		reporter.Update(attempted, name)
+		attempted++
+		utils.Heartbeat(ctx)
+
+		imagePath := filepath.Join(ocrImageDir, name)
+		text, err := so.ocr.Recognize(ctx, imagePath)
+		if err != nil {
+			so.logger.WithError(err).WithField("image", name).Warn("Failed to recognize screenshot text")
+			failed++
+		} else if strings.TrimSpace(text) != "" {
+			textPath := filepath.Join(passDir, "ocr_"+strings.TrimSuffix(name, filepath.Ext(name))+".txt")
+			if err := utils.WriteFileAtomic(textPath, []byte(text), 0644); err != nil {
+				// Keep the image so the next cycle retries it
+				so.logger.WithError(err).WithField("image", name).Warn("Failed to write recognized text")
+				failed++
+				continue
+			}
+			recognized++
+		}
+
+		if err := os.Remove(imagePath); err != nil {
+			so.logger.WithError(err).WithField("image", name).Warn("Failed to remove screenshot")
+		}
+	}
+	reporter.Update(attempted, "")
+
+	duration := time.Since(startTime)
+	so.logger.WithFields(logrus.Fields{
+		"duration_seconds": duration.Seconds(),
+		"images":           attempted,
+		"recognized":       recognized,
+		"failed":           failed,
+	}).Info("OCR stage completed")
+
+	so.recordStageTiming(storage.StageOCR, duration, attempted, inputBytes)
+	return nil
+}
@@ -0,0 +1,26 @@
+package orchestrator
+
+import (
+	"telegram-archive-bot/app/extraction/convert"
+)
+
+// configureConversionOutput passes the chunking and naming settings to the
+// convert stage. {task} is the short ID of the archive when the pass converts
+// a single one, and "batch" when it converts several
+func (so *SequentialOrchestrator) configureConversionOutput(taskIDs []string) {
+	settings := convert.OutputSettings{
+		MaxLines:     so.config.OutputChunkMaxLines,
+		MaxBytes:     so.config.OutputChunkMaxSize,
+		NameTemplate: so.config.OutputNameTemplate,
+		Task:         "batch",
+		Tag:          so.config.OutputTag,
+	}
+
+	if len(taskIDs) == 1 {
+		settings.Task = taskIDs[0]
+		if shortID, err := so.taskStore.GetShortID(taskIDs[0]); err == nil {
+			settings.Task = shortID
+		}
+	}
+	settings.Apply()
+}
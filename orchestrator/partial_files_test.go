@@ -0,0 +1,74 @@
+package orchestrator
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"telegram-archive-bot/app/extraction/convert"
+)
+
+// A restart after an outage sweeps stale writes but keeps the chunk a crashed
+// conversion pass left open, which the next pass publishes
+func TestRestartPublishesStaleConvertedChunk(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	txtDir := "app/extraction/files/txt"
+	allDir := "app/extraction/files/all"
+	passDir := "app/extraction/files/pass"
+	for _, dir := range []string{txtDir, allDir, passDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chunk := filepath.Join(txtDir, "converted.txt.partial")
+	copied := filepath.Join(allDir, "logs.zip.partial")
+	outage := time.Now().Add(-time.Hour)
+	for _, path := range []string{chunk, copied} {
+		if err := os.WriteFile(path, []byte("https://example.com:user:secret\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, outage, outage); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	so := &SequentialOrchestrator{logger: logger}
+	so.cleanupPartialFiles()
+
+	if _, err := os.Stat(copied); !os.IsNotExist(err) {
+		t.Errorf("stale copy %s was not swept: %v", copied, err)
+	}
+	if _, err := os.Stat(chunk); err != nil {
+		t.Fatalf("converted chunk was swept: %v", err)
+	}
+
+	t.Setenv("CONVERT_INPUT_DIR", passDir)
+	t.Setenv("CONVERT_OUTPUT_FILE", filepath.Join(txtDir, "converted.txt"))
+	if err := convert.ConvertTextFiles(1); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(txtDir, "converted.txt"))
+	if err != nil {
+		t.Fatalf("chunk was not published: %v", err)
+	}
+	if string(content) != "https://example.com:user:secret\n" {
+		t.Errorf("published chunk = %q", content)
+	}
+	if _, err := os.Stat(chunk); !os.IsNotExist(err) {
+		t.Errorf("chunk still pending as %s: %v", chunk, err)
+	}
+}
@@ -0,0 +1,167 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"telegram-archive-bot/app/extraction/convert"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+const (
+	// conversionInputsDir holds the input of each conversion pass kept for
+	// reprocess campaigns, one directory per pass
+	conversionInputsDir = "app/extraction/files/conversion_inputs"
+	// reprocessDir is where a campaign stages a kept input, since the
+	// convert stage deletes the files it converts
+	reprocessDir = "app/extraction/files/reprocess"
+)
+
+// keepConversionInput links the files of a pass about to be converted into a
+// new directory under conversionInputsDir and returns it
+func (so *SequentialOrchestrator) keepConversionInput(passDir string) (string, error) {
+	// Named after when it was kept, which the conversion_inputs retention reads
+	dir := filepath.Join(conversionInputsDir, time.Now().Format("20060102-150405.000"))
+	if err := so.linkFiles(passDir, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to keep conversion input: %w", err)
+	}
+	return dir, nil
+}
+
+// linkFiles hard links the files directly in src, the ones the convert stage
+// reads, into dst. Files on another filesystem are copied instead
+func (so *SequentialOrchestrator) linkFiles(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasSuffix(entry.Name(), ".partial") {
+			continue
+		}
+		from, to := filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())
+		if err := os.Link(from, to); err == nil {
+			continue
+		}
+		if _, err := utils.CopyFileTuned(from, to, so.config.IOTuning()); err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// runReprocessStage converts one pass of the running reprocess campaign
+// again, once the regular stages have nothing left to convert. Its output
+// goes to files/txt for the store stage like any other pass
+func (so *SequentialOrchestrator) runReprocessStage(ctx context.Context) error {
+	campaign, err := so.taskStore.GetRunningReprocessCampaign()
+	if err != nil || campaign == nil {
+		return err
+	}
+	pending, err := so.countFilesInDirectory("app/extraction/files/pass")
+	if err != nil || pending > 0 {
+		return err
+	}
+
+	item, err := so.taskStore.NextReprocessItem(campaign.ID)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		so.logger.WithFields(logrus.Fields{
+			"campaign_id":    campaign.ID,
+			"parser_version": campaign.ParserVersion,
+			"passes":         campaign.Done,
+			"failed":         campaign.Failed,
+		}).Info("Reprocess campaign completed")
+		return nil
+	}
+
+	result, passErr := so.reprocessPass(ctx, item)
+	var newResultID int64
+	if result != nil {
+		newResultID = result.ID
+	}
+	if err := so.taskStore.FinishReprocessItem(item, newResultID, passErr); err != nil {
+		return err
+	}
+
+	entry := so.logger.WithFields(logrus.Fields{
+		"campaign_id": campaign.ID,
+		"result_id":   item.ResultID,
+		"tasks":       len(item.TaskIDs),
+		"from":        item.ParserVersion,
+		"to":          convert.ParserVersion,
+	})
+	if passErr != nil {
+		entry.WithError(passErr).Warn("Conversion pass not reprocessed")
+		return nil
+	}
+	entry.WithField("new_result_id", newResultID).
+		WithField("credentials", result.Credentials).
+		Info("Conversion pass reprocessed")
+	return nil
+}
+
+// reprocessPass converts a kept input again and records the result on the
+// pass's tasks, keeping the input for the next campaign
+func (so *SequentialOrchestrator) reprocessPass(ctx context.Context, item *storage.ReprocessItem) (*storage.ConversionResult, error) {
+	if _, err := os.Stat(item.InputDir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("kept input %s no longer exists", item.InputDir)
+		}
+		return nil, err
+	}
+
+	os.RemoveAll(reprocessDir)
+	defer os.RemoveAll(reprocessDir)
+	if err := so.linkFiles(item.InputDir, reprocessDir); err != nil {
+		return nil, fmt.Errorf("failed to stage kept input: %w", err)
+	}
+
+	fileCount, err := so.countFilesInDirectory(reprocessDir)
+	if err != nil {
+		return nil, err
+	}
+	workers := so.stageWorkers(utils.StageConversions)
+	so.logger.WithFields(logrus.Fields{
+		"result_id":  item.ResultID,
+		"file_count": fileCount,
+		"workers":    workers,
+	}).Info("Reprocessing conversion pass")
+
+	inputBytes := so.directorySize(reprocessDir)
+	startTime := time.Now()
+
+	os.Setenv("CONVERT_INPUT_DIR", reprocessDir)
+	os.Setenv("CONVERT_OUTPUT_FILE", "app/extraction/files/txt/converted.txt")
+	so.configureLineDedup()
+	so.configureConversionOutput(item.TaskIDs)
+
+	manifestPath, err := so.prepareConversionManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	stopProgress := so.watchStageProgress(ctx, storage.StageConversion)
+	err = convert.ConvertTextFiles(workers)
+	stopProgress()
+	if err != nil {
+		os.Unsetenv(convert.ManifestEnvFile)
+		return nil, fmt.Errorf("conversion failed: %w", err)
+	}
+
+	so.recordStageTiming(storage.StageConversion, time.Since(startTime), fileCount, inputBytes)
+	return so.recordConversionResult(manifestPath, item.InputDir, item.TaskIDs)
+}
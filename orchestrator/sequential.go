@@ -5,13 +5,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"telegram-archive-bot/app/extraction"
 	"telegram-archive-bot/app/extraction/convert"
 	"telegram-archive-bot/app/extraction/extract"
-	"telegram-archive-bot/bot"
+	"telegram-archive-bot/app/extraction/progress"
+	"telegram-archive-bot/cluster"
 	"telegram-archive-bot/models"
 	"telegram-archive-bot/storage"
 	"telegram-archive-bot/utils"
@@ -22,17 +25,41 @@ import (
 type SequentialOrchestrator struct {
 	logger       *logrus.Logger
 	config       *utils.Config
-	taskStore    *storage.TaskStore
-	telegramBot  *bot.TelegramBot
+	taskStore    Store
+	telegramBot  Notifier
+	coordinator  *cluster.Coordinator
+	fence        func() error
+	loopHook     func()
 	pollInterval time.Duration
+	ledgers      []utils.LedgerSink
+	ocr          *utils.OCREngine
+	taskFiles    TaskFileReferences
+	limits       *utils.StageLimits
+
+	// dedupAttemptAt is when the duplicate filter rebuild last ran
+	dedupAttemptAt time.Time
+
+	// stageProgress is the running extraction/conversion pass, nil when idle
+	stageProgress *progress.Report
+	progressMu    sync.RWMutex
+
+	// cycleRunning is set while a processing cycle runs
+	cycleRunning atomic.Bool
+
+	// degradation defers stages whose directories are down, nil when unset
+	degradation *utils.GracefulDegradationManager
+	// wake runs a cycle ahead of the ticker, for re-dispatched stages
+	wake chan struct{}
+	// running is set while Start's loop runs
+	running atomic.Bool
 }
 
 // NewSequentialOrchestrator creates a new sequential processing orchestrator
 func NewSequentialOrchestrator(
 	logger *logrus.Logger,
 	config *utils.Config,
-	taskStore *storage.TaskStore,
-	telegramBot *bot.TelegramBot,
+	taskStore Store,
+	telegramBot Notifier,
 ) *SequentialOrchestrator {
 	return &SequentialOrchestrator{
 		logger:       logger,
@@ -40,13 +67,66 @@ func NewSequentialOrchestrator(
 		taskStore:    taskStore,
 		telegramBot:  telegramBot,
 		pollInterval: 10 * time.Second, // Check every 10 seconds
+		wake:         make(chan struct{}, 1),
 	}
 }
 
+// SetCoordinator enables handing extraction and conversion to remote worker nodes
+func (so *SequentialOrchestrator) SetCoordinator(coordinator *cluster.Coordinator) {
+	so.coordinator = coordinator
+}
+
+// SetFence installs a check run before each cycle; when it fails (e.g. this
+// instance lost leadership) the cycle is skipped
+func (so *SequentialOrchestrator) SetFence(fence func() error) {
+	so.fence = fence
+}
+
+// SetLoopHook installs a callback run whenever the processing loop makes
+// progress, e.g. to feed the watchdog
+func (so *SequentialOrchestrator) SetLoopHook(hook func()) {
+	so.loopHook = hook
+}
+
+// markLoop reports progress of the processing loop
+func (so *SequentialOrchestrator) markLoop() {
+	if so.loopHook != nil {
+		so.loopHook()
+	}
+}
+
+// dispatchToCluster hands the stage's files to worker nodes when any are
+// connected and reports whether the local stage should be skipped
+func (so *SequentialOrchestrator) dispatchToCluster(kind, inputDir, outputDir string) bool {
+	if so.coordinator == nil {
+		return false
+	}
+
+	dispatched, err := so.coordinator.Dispatch(kind, inputDir, outputDir)
+	if err != nil {
+		so.logger.WithError(err).WithField("kind", kind).Warn("Failed to dispatch files to cluster")
+		return false
+	}
+	if dispatched > 0 {
+		so.logger.WithField("kind", kind).
+			WithField("dispatched", dispatched).
+			Info("Files dispatched to worker nodes")
+	}
+
+	// Anything left behind (e.g. files returned after remote failures) is processed locally
+	remaining, err := so.countFilesInDirectory(inputDir)
+	return err == nil && remaining == 0
+}
+
 // Start begins the sequential processing loop
 func (so *SequentialOrchestrator) Start(ctx context.Context) error {
 	so.logger.Info("Sequential orchestrator started")
 
+	so.cleanupPartialFiles()
+
+	so.running.Store(true)
+	defer so.running.Store(false)
+
 	ticker := time.NewTicker(so.pollInterval)
 	defer ticker.Stop()
 
@@ -57,34 +137,118 @@ func (so *SequentialOrchestrator) Start(ctx context.Context) error {
 			return ctx.Err()
 
 		case <-ticker.C:
-			// Run the processing stages sequentially
-			if err := so.runProcessingCycle(ctx); err != nil {
-				so.logger.WithError(err).Error("Processing cycle failed")
-				// Continue to next cycle even if this one failed
-			}
+			so.tick(ctx)
 
-			// Send notifications for completed tasks
-			if err := so.sendNotifications(); err != nil {
-				so.logger.WithError(err).Error("Failed to send notifications")
-			}
+		case <-so.wake:
+			so.logger.Info("Running processing cycle for a re-dispatched stage")
+			so.tick(ctx)
 		}
 	}
 }
 
+// tick runs a processing cycle unless fencing or maintenance holds it back
+func (so *SequentialOrchestrator) tick(ctx context.Context) {
+	utils.Heartbeat(ctx)
+	so.markLoop()
+
+	if so.fence != nil {
+		if err := so.fence(); err != nil {
+			so.logger.WithError(err).Warn("Fencing check failed, skipping processing cycle")
+			return
+		}
+	}
+
+	// A restore replaces the tasks under the cycle, so none starts
+	if utils.Maintenance.Paused() {
+		so.logger.Debug("Pipeline paused for maintenance, skipping processing cycle")
+		return
+	}
+
+	so.runCycle(ctx)
+}
+
+// runCycle runs the processing stages and the work that follows them
+func (so *SequentialOrchestrator) runCycle(ctx context.Context) {
+	so.cycleRunning.Store(true)
+	defer so.cycleRunning.Store(false)
+
+	// Run the processing stages sequentially
+	if err := so.runProcessingCycle(ctx); err != nil {
+		so.logger.WithError(err).Error("Processing cycle failed")
+		// Continue to next cycle even if this one failed
+	}
+
+	utils.Heartbeat(ctx)
+	so.markLoop()
+
+	// Send notifications for completed tasks
+	if err := so.sendNotifications(); err != nil {
+		so.logger.WithError(err).Error("Failed to send notifications")
+	}
+
+	// Record newly completed tasks in the completion ledgers
+	so.appendLedgers()
+
+	// Refresh queue position and ETA in progress messages
+	if err := so.updateProgressMessages(); err != nil {
+		so.logger.WithError(err).Warn("Failed to update progress messages")
+	}
+}
+
+// CycleRunning reports whether a processing cycle is running, which a
+// paused pipeline waits out
+func (so *SequentialOrchestrator) CycleRunning() bool {
+	return so.cycleRunning.Load()
+}
+
 // runProcessingCycle executes all three stages in sequence
 func (so *SequentialOrchestrator) runProcessingCycle(ctx context.Context) error {
+	if so.config.DryRun {
+		return so.runDryRunCycle(ctx)
+	}
+
+	// Stage 0: Validate and convert submitted text files (files/fastpath/ → files/txt/)
+	if err := so.runFastPathStage(ctx); err != nil {
+		so.logger.WithError(err).Error("Fast path stage failed")
+	}
+
+	utils.Heartbeat(ctx)
+	so.markLoop()
+
 	// Stage 1: Extract archives (files/all/ → files/pass/)
 	if err := so.runExtractionStage(ctx); err != nil {
 		so.logger.WithError(err).Error("Extraction stage failed")
 		// Continue to next stage even if extraction failed
 	}
 
+	utils.Heartbeat(ctx)
+	so.markLoop()
+
+	// Stage 1b: Read screenshots taken from the archives (files/images/ → files/pass/)
+	if err := so.runOCRStage(ctx); err != nil {
+		so.logger.WithError(err).Error("OCR stage failed")
+	}
+
+	utils.Heartbeat(ctx)
+	so.markLoop()
+
 	// Stage 2: Convert extracted files (files/pass/ → files/txt/)
 	if err := so.runConversionStage(ctx); err != nil {
 		so.logger.WithError(err).Error("Conversion stage failed")
 		// Continue to next stage even if conversion failed
 	}
 
+	utils.Heartbeat(ctx)
+	so.markLoop()
+
+	// Stage 2b: Convert a pass of a reprocess campaign again once idle (→ files/txt/)
+	if err := so.runReprocessStage(ctx); err != nil {
+		so.logger.WithError(err).Error("Reprocess stage failed")
+	}
+
+	utils.Heartbeat(ctx)
+	so.markLoop()
+
 	// Stage 3: Store text files (files/txt/ → database)
 	if err := so.runStoreStage(ctx); err != nil {
 		so.logger.WithError(err).Error("Store stage failed")
@@ -108,14 +272,36 @@ func (so *SequentialOrchestrator) runExtractionStage(ctx context.Context) error
 		return nil
 	}
 
+	if so.stageDeferred(storage.StageExtraction, fileCount) {
+		return nil
+	}
+
+	if so.dispatchToCluster(cluster.JobExtract, extractDir, "app/extraction/files/pass") {
+		return nil
+	}
+
+	workers := so.stageWorkers(utils.StageExtractions)
 	so.logger.WithField("file_count", fileCount).
+		WithField("workers", workers).
 		Info("Starting extraction stage")
 
+	inputBytes := so.directorySize(extractDir)
 	startTime := time.Now()
 
+	// Keep the tasks' temporary files however long a large archive takes
+	releaseTaskFiles := so.acquireTaskFiles()
+	defer releaseTaskFiles()
+
+	if err := utils.Faults.Inject(ctx, utils.FaultExtract); err != nil {
+		return fmt.Errorf("extraction failed: %w", err)
+	}
+
 	// Run extract.go's main function (BLOCKS until complete)
 	// This processes all files in app/extraction/files/all/
-	extract.ExtractArchives()
+	so.configureImageCollection()
+	stopProgress := so.watchStageProgress(ctx, storage.StageExtraction)
+	extract.ExtractArchives(workers)
+	stopProgress()
 
 	duration := time.Since(startTime)
 
@@ -124,6 +310,8 @@ func (so *SequentialOrchestrator) runExtractionStage(ctx context.Context) error
 		"files_processed":  fileCount,
 	}).Info("Extraction stage completed")
 
+	so.recordStageTiming(storage.StageExtraction, duration, fileCount, inputBytes)
+
 	// Update task statuses for extracted files
 	// Note: We can't easily track which specific files were extracted
 	// since extract.go doesn't return that info. Tasks will be marked
@@ -147,23 +335,33 @@ func (so *SequentialOrchestrator) runConversionStage(ctx context.Context) error
 		return nil
 	}
 
+	if so.stageDeferred(storage.StageConversion, fileCount) {
+		return nil
+	}
+
+	if so.dispatchToCluster(cluster.JobConvert, passDir, "app/extraction/files/txt") {
+		return nil
+	}
+
+	workers := so.stageWorkers(utils.StageConversions)
 	so.logger.WithField("file_count", fileCount).
+		WithField("workers", workers).
 		Info("Starting conversion stage")
 
+	inputBytes := so.directorySize(passDir)
 	startTime := time.Now()
 
-	// Set environment variables for convert.go
-	os.Setenv("CONVERT_INPUT_DIR", "app/extraction/files/pass")
-	os.Setenv("CONVERT_OUTPUT_FILE", "app/extraction/files/txt/converted.txt")
+	if err := utils.Faults.Inject(ctx, utils.FaultConvert); err != nil {
+		return fmt.Errorf("conversion failed: %w", err)
+	}
 
-	so.logger.WithFields(logrus.Fields{
-		"input_dir":   "app/extraction/files/pass",
-		"output_file": "app/extraction/files/txt/converted.txt",
-	}).Debug("Set conversion environment variables")
+	// Every DOWNLOADED archive task is in this pass: their files are what files/pass holds
+	taskIDs, err := so.downloadedTaskIDs()
+	if err != nil {
+		so.logger.WithError(err).Warn("Failed to get the tasks of the conversion pass")
+	}
 
-	// Run convert.go's main function (BLOCKS until complete)
-	// This processes all files in app/extraction/files/pass/
-	err = convert.ConvertTextFiles()
+	err = so.convertPass(ctx, storage.StageConversion, passDir, taskIDs, workers)
 
 	duration := time.Since(startTime)
 
@@ -172,7 +370,7 @@ func (so *SequentialOrchestrator) runConversionStage(ctx context.Context) error
 			"duration_seconds": duration.Seconds(),
 			"error":            err.Error(),
 		}).Error("Conversion stage failed")
-		return fmt.Errorf("conversion failed: %w", err)
+		return err
 	}
 
 	so.logger.WithFields(logrus.Fields{
@@ -180,6 +378,66 @@ func (so *SequentialOrchestrator) runConversionStage(ctx context.Context) error
 		"files_processed":  fileCount,
 	}).Info("Conversion stage completed")
 
+	so.recordStageTiming(storage.StageConversion, duration, fileCount, inputBytes)
+
+	return nil
+}
+
+// convertPass converts the files in dir into files/txt/ and records the
+// result on taskIDs with up to workers files converted at once, keeping the
+// input for reprocess campaigns when configured. stage names the pass in
+// progress reports
+func (so *SequentialOrchestrator) convertPass(ctx context.Context, stage, dir string, taskIDs []string, workers int) error {
+	// Set environment variables for convert.go
+	os.Setenv("CONVERT_INPUT_DIR", dir)
+	os.Setenv("CONVERT_OUTPUT_FILE", "app/extraction/files/txt/converted.txt")
+
+	so.logger.WithFields(logrus.Fields{
+		"input_dir":   dir,
+		"output_file": "app/extraction/files/txt/converted.txt",
+	}).Debug("Set conversion environment variables")
+
+	so.configureLineDedup()
+	so.configureConversionOutput(taskIDs)
+
+	manifestPath, manifestErr := so.prepareConversionManifest()
+	if manifestErr != nil {
+		so.logger.WithError(manifestErr).Warn("Conversion result manifest disabled")
+	}
+
+	// Reprocess campaigns convert the kept input again; without a manifest
+	// there is no result to keep it for
+	var inputDir string
+	var err error
+	if so.config.ConversionKeepInputs && manifestErr == nil {
+		if inputDir, err = so.keepConversionInput(dir); err != nil {
+			so.logger.WithError(err).Warn("Conversion input not kept for reprocessing")
+		}
+	}
+
+	// Run convert.go's main function (BLOCKS until complete)
+	// This processes all files in dir
+	stopProgress := so.watchStageProgress(ctx, stage)
+	err = convert.ConvertTextFiles(workers)
+	stopProgress()
+
+	if err != nil {
+		os.Unsetenv(convert.ManifestEnvFile)
+		if inputDir != "" {
+			os.RemoveAll(inputDir)
+		}
+		return fmt.Errorf("conversion failed: %w", err)
+	}
+
+	if manifestErr == nil {
+		if _, err := so.recordConversionResult(manifestPath, inputDir, taskIDs); err != nil {
+			so.logger.WithError(err).Warn("Failed to record conversion result")
+			if inputDir != "" {
+				os.RemoveAll(inputDir)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -201,6 +459,7 @@ func (so *SequentialOrchestrator) runStoreStage(ctx context.Context) error {
 	so.logger.WithField("file_count", fileCount).
 		Info("Starting store stage")
 
+	inputBytes := so.directorySize(txtDir)
 	startTime := time.Now()
 
 	// Create store service with logger function and bot integration
@@ -217,6 +476,7 @@ func (so *SequentialOrchestrator) runStoreStage(ctx context.Context) error {
 	// Create store service with bot integration for automatic file sending
 	storeService := extraction.NewStoreServiceWithBot(logFunc, so.telegramBot, adminChatID)
 	defer storeService.Close()
+	storeService.SetArchiveCompression(so.config.ArchiveCompression, so.config.ArchiveCompressionLevel)
 
 	// Create context with timeout (2 hours for large files)
 	storeCtx, cancel := context.WithTimeout(ctx, 2*time.Hour)
@@ -241,24 +501,27 @@ func (so *SequentialOrchestrator) runStoreStage(ctx context.Context) error {
 		"files_processed":  fileCount,
 	}).Info("Store stage completed")
 
+	so.recordStageTiming(storage.StageStore, duration, fileCount, inputBytes)
+
 	// Mark tasks as COMPLETED
-	// All tasks that reached this stage are considered successful
-	if err := so.markTasksCompleted(); err != nil {
+	// All tasks that reached this stage are considered successful, unless
+	// worker nodes are still processing some of their files
+	if so.coordinator != nil && so.coordinator.PendingJobs() > 0 {
+		so.logger.WithField("pending_jobs", so.coordinator.PendingJobs()).
+			Info("Deferring task completion until cluster jobs finish")
+	} else if err := so.markTasksCompleted(); err != nil {
 		so.logger.WithError(err).Error("Failed to mark tasks as completed")
 	}
 
+	so.rebuildLineDedupIfDue(ctx)
+
 	return nil
 }
 
 // markTasksCompleted marks all DOWNLOADED tasks as COMPLETED
 // This is called after the store stage successfully completes
 func (so *SequentialOrchestrator) markTasksCompleted() error {
-	tasks, err := so.taskStore.GetByStatus(models.TaskStatusDownloaded)
-	if err != nil {
-		return fmt.Errorf("failed to get downloaded tasks: %w", err)
-	}
-
-	for _, task := range tasks {
+	err := so.taskStore.EachByStatus(models.TaskStatusDownloaded, func(task *models.Task) error {
 		task.Status = models.TaskStatusCompleted
 		now := time.Now()
 		task.CompletedAt = &now
@@ -268,13 +531,17 @@ func (so *SequentialOrchestrator) markTasksCompleted() error {
 			so.logger.WithField("task_id", task.ID).
 				WithError(err).
 				Error("Failed to update task to COMPLETED")
-			continue
+			return nil
 		}
 
 		so.logger.WithFields(logrus.Fields{
 			"task_id":   task.ID,
 			"file_name": task.FileName,
 		}).Info("Task marked as COMPLETED")
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get downloaded tasks: %w", err)
 	}
 
 	return nil
@@ -289,6 +556,50 @@ func (so *SequentialOrchestrator) sendNotifications() error {
 	return so.telegramBot.SendCompletionNotifications()
 }
 
+// updateProgressMessages refreshes ETA estimates shown to submitters
+func (so *SequentialOrchestrator) updateProgressMessages() error {
+	if so.telegramBot == nil {
+		return nil
+	}
+
+	return so.telegramBot.UpdateProgressMessages()
+}
+
+// recordStageTiming persists a stage's duration and input size for ETA and
+// cost estimation
+func (so *SequentialOrchestrator) recordStageTiming(stage string, duration time.Duration, items int, bytes int64) {
+	if err := so.taskStore.RecordStageTiming(stage, "", duration, items, bytes); err != nil {
+		so.logger.WithError(err).
+			WithField("stage", stage).
+			Warn("Failed to record stage timing")
+	}
+}
+
+// cleanupPartialFiles removes .partial files left behind by writes that were
+// interrupted by a crash or restart. files/txt is left alone: a .partial there
+// is converted output whose inputs are already gone, and the next conversion
+// pass publishes it
+func (so *SequentialOrchestrator) cleanupPartialFiles() {
+	dirs := []string{
+		fastPathDir,
+		"app/extraction/files/all",
+		"app/extraction/files/pass",
+		ocrImageDir,
+	}
+	for _, dir := range dirs {
+		removed, err := utils.CleanupPartialFiles(dir, 10*time.Minute)
+		if err != nil {
+			so.logger.WithError(err).WithField("dir", dir).Warn("Failed to clean up partial files")
+			continue
+		}
+		if removed > 0 {
+			so.logger.WithField("dir", dir).
+				WithField("removed", removed).
+				Info("Removed stale partial files")
+		}
+	}
+}
+
 // countFilesInDirectory counts regular files in a directory (non-recursive)
 func (so *SequentialOrchestrator) countFilesInDirectory(dir string) (int, error) {
 	// Check if directory exists
@@ -308,9 +619,9 @@ func (so *SequentialOrchestrator) countFilesInDirectory(dir string) (int, error)
 	count := 0
 	for _, entry := range entries {
 		if !entry.IsDir() {
-			// Skip hidden files and .gitkeep files
+			// Skip hidden files, .gitkeep files and in-progress atomic writes
 			name := entry.Name()
-			if !filepath.HasPrefix(name, ".") && name != ".gitkeep" {
+			if !filepath.HasPrefix(name, ".") && name != ".gitkeep" && !utils.IsPartialFile(name) {
 				count++
 			}
 		}
@@ -319,18 +630,87 @@ func (so *SequentialOrchestrator) countFilesInDirectory(dir string) (int, error)
 	return count, nil
 }
 
+// directorySize sums the sizes of the files countFilesInDirectory counts,
+// 0 when the directory can't be read
+func (so *SequentialOrchestrator) directorySize(dir string) int64 {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+
+	var size int64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.HasPrefix(name, ".") || utils.IsPartialFile(name) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// IsIdle reports whether no stage is running and no files wait in the
+// stage directories, e.g. before a restart into an update
+func (so *SequentialOrchestrator) IsIdle() bool {
+	if so.StageProgress() != nil {
+		return false
+	}
+	for _, dir := range []string{fastPathDir, "app/extraction/files/all", "app/extraction/files/pass", "app/extraction/files/txt"} {
+		if count, err := so.countFilesInDirectory(dir); err != nil || count > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// StageBacklog counts the files waiting for each processing stage
+func (so *SequentialOrchestrator) StageBacklog() map[string]int {
+	backlog := make(map[string]int, 4)
+	backlog["fast_path"], _ = so.countFilesInDirectory(fastPathDir)
+	backlog["extraction"], _ = so.countFilesInDirectory("app/extraction/files/all")
+	backlog["conversion"], _ = so.countFilesInDirectory("app/extraction/files/pass")
+	backlog["store"], _ = so.countFilesInDirectory("app/extraction/files/txt")
+	return backlog
+}
+
+// StageBlockers explains why work waiting for a stage is held up by more
+// than the stage running before it, keyed by pipeline graph node
+func (so *SequentialOrchestrator) StageBlockers() map[string]string {
+	blockers := make(map[string]string)
+	if utils.Maintenance.Paused() {
+		for _, node := range []string{"pending", "fast_path", "extraction", "conversion", "store"} {
+			blockers[node] = "paused for maintenance"
+		}
+		return blockers
+	}
+	if limit := so.limits.Limit(utils.StageDownloads); so.limits.Active(utils.StageDownloads) >= limit {
+		blockers["pending"] = fmt.Sprintf("all %d download slots busy", limit)
+	}
+	if so.coordinator != nil {
+		if pending := so.coordinator.PendingJobs(); pending > 0 {
+			blockers["store"] = fmt.Sprintf("completion deferred until %d cluster jobs finish", pending)
+		}
+	}
+	return blockers
+}
+
 // GetStats returns current orchestrator statistics
 func (so *SequentialOrchestrator) GetStats() map[string]interface{} {
 	stats := make(map[string]interface{})
 
 	// Count files in each directory
-	allCount, _ := so.countFilesInDirectory("app/extraction/files/all")
-	passCount, _ := so.countFilesInDirectory("app/extraction/files/pass")
-	txtCount, _ := so.countFilesInDirectory("app/extraction/files/txt")
-
-	stats["files_awaiting_extraction"] = allCount
-	stats["files_awaiting_conversion"] = passCount
-	stats["files_awaiting_store"] = txtCount
+	backlog := so.StageBacklog()
+	stats["files_awaiting_fast_path"] = backlog["fast_path"]
+	stats["files_awaiting_extraction"] = backlog["extraction"]
+	stats["files_awaiting_conversion"] = backlog["conversion"]
+	stats["files_awaiting_store"] = backlog["store"]
+	stats["dry_run"] = so.config.DryRun
+
+	if report := so.StageProgress(); report != nil {
+		stats["stage_progress"] = report
+	}
 
 	// Get task counts by status
 	pending, _ := so.taskStore.GetTaskCountByStatus(models.TaskStatusPending)
@@ -0,0 +1,104 @@
+package orchestrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"telegram-archive-bot/app/extraction/progress"
+	"telegram-archive-bot/utils"
+)
+
+// progressDir holds the progress files written by the extract and convert stages
+const progressDir = "app/extraction/files/progress"
+
+// progressPollInterval is how often a running stage's progress file is read
+const progressPollInterval = 5 * time.Second
+
+// watchStageProgress points the extract/convert stage at a fresh progress file
+// and surfaces its reports until the returned stop function is called
+func (so *SequentialOrchestrator) watchStageProgress(ctx context.Context, stage string) func() {
+	path, err := filepath.Abs(filepath.Join(progressDir, stage+".json"))
+	if err != nil {
+		so.logger.WithError(err).WithField("stage", stage).Warn("Stage progress reporting disabled")
+		return func() {}
+	}
+
+	os.Remove(path)
+	os.Setenv(progress.EnvFile, path)
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(progressPollInterval)
+		defer ticker.Stop()
+
+		lastDone, lastTotal := -1, -1
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			report, err := progress.Read(path)
+			if err != nil {
+				so.logger.WithError(err).WithField("stage", stage).Debug("Failed to read stage progress")
+				continue
+			}
+			if report == nil || (report.Done == lastDone && report.Total == lastTotal) {
+				continue
+			}
+			lastDone, lastTotal = report.Done, report.Total
+
+			// Progress proves the stage is alive, so long passes are not mistaken for hangs
+			utils.Heartbeat(ctx)
+			so.setStageProgress(report)
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+		os.Unsetenv(progress.EnvFile)
+		os.Remove(path)
+		so.setStageProgress(nil)
+	}
+}
+
+// setStageProgress publishes stage progress to stats and task progress messages
+func (so *SequentialOrchestrator) setStageProgress(report *progress.Report) {
+	so.progressMu.Lock()
+	so.stageProgress = report
+	so.progressMu.Unlock()
+
+	if so.telegramBot != nil {
+		so.telegramBot.SetStageProgress(report)
+	}
+	if report == nil {
+		return
+	}
+
+	so.logger.WithFields(logrus.Fields{
+		"stage":   report.Stage,
+		"done":    report.Done,
+		"total":   report.Total,
+		"current": report.Current,
+	}).Info("Stage progress")
+
+	if err := so.updateProgressMessages(); err != nil {
+		so.logger.WithError(err).Warn("Failed to update progress messages")
+	}
+}
+
+// StageProgress returns the running extraction/conversion pass, or nil when idle
+func (so *SequentialOrchestrator) StageProgress() *progress.Report {
+	so.progressMu.RLock()
+	defer so.progressMu.RUnlock()
+	return so.stageProgress
+}
@@ -0,0 +1,42 @@
+package orchestrator
+
+import (
+	"telegram-archive-bot/models"
+)
+
+// TaskFileReferences keeps a task's temporary files from the age-based
+// cleaner while the task is processed
+type TaskFileReferences interface {
+	AcquireTask(taskID string)
+	ReleaseTask(taskID string)
+}
+
+// SetTaskFileReferences makes extraction hold references on the temporary
+// files of the tasks it processes
+func (so *SequentialOrchestrator) SetTaskFileReferences(refs TaskFileReferences) {
+	so.taskFiles = refs
+}
+
+// acquireTaskFiles takes a reference on the temporary files of every task
+// waiting for extraction and returns the function releasing them
+func (so *SequentialOrchestrator) acquireTaskFiles() func() {
+	if so.taskFiles == nil {
+		return func() {}
+	}
+
+	var taskIDs []string
+	err := so.taskStore.EachByStatus(models.TaskStatusDownloaded, func(task *models.Task) error {
+		so.taskFiles.AcquireTask(task.ID)
+		taskIDs = append(taskIDs, task.ID)
+		return nil
+	})
+	if err != nil {
+		// The files keep their own references, only age cleanup is unguarded
+		so.logger.WithError(err).Warn("Failed to reference temporary files of some tasks being extracted")
+	}
+	return func() {
+		for _, taskID := range taskIDs {
+			so.taskFiles.ReleaseTask(taskID)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"telegram-archive-bot/models"
 	"telegram-archive-bot/storage"
 	"telegram-archive-bot/utils"
@@ -29,13 +28,17 @@ type PipelineCoordinator struct {
 }
 
 func NewPipelineCoordinator(
-	bot *tgbotapi.BotAPI,
+	bot utils.FileFetcher,
 	config *utils.Config,
 	logger *utils.Logger,
 	taskStore *storage.TaskStore,
 ) *PipelineCoordinator {
 	// Create workers
-	downloadWorker := workers.NewDownloadWorker(bot, config, logger, taskStore)
+	botAPIPathManager := utils.NewBotAPIPathManager(config, logger)
+	if err := botAPIPathManager.EnsureDirectories(); err != nil {
+		logger.WithError(err).Fatal("Failed to ensure Local Bot API directories")
+	}
+	downloadWorker := workers.NewDownloadWorker(bot, botAPIPathManager, config, logger, taskStore, taskStore.GetDB())
 	extractWorker := workers.NewExtractionWorker(config, logger, taskStore)
 	convertWorker := workers.NewConversionWorker(config, logger, taskStore)
 
@@ -47,6 +50,14 @@ func NewPipelineCoordinator(
 	pipeline.extractionPool.SetWorkers([]Worker{&WorkerAdapter{worker: extractWorker}})
 	pipeline.conversionPool.SetWorkers([]Worker{&WorkerAdapter{worker: convertWorker}})
 
+	// Re-dispatch operations that were queued while extract.go/convert.go were unavailable
+	extractWorker.SetQueuedOperationHandler(func(op utils.QueuedOperation) error {
+		return pipeline.Redispatch(op.TaskID, JobTypeExtraction)
+	})
+	convertWorker.SetQueuedOperationHandler(func(op utils.QueuedOperation) error {
+		return pipeline.Redispatch(op.TaskID, JobTypeConversion)
+	})
+
 	return &PipelineCoordinator{
 		pipeline:       pipeline,
 		downloadWorker: downloadWorker,
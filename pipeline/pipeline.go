@@ -2,6 +2,8 @@ package pipeline
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -113,6 +115,35 @@ func (p *Pipeline) SubmitTask(task *models.Task) error {
 	return p.downloadPool.Submit(job)
 }
 
+// Redispatch puts a task back into the extraction or conversion stage, e.g.
+// after the stage's dependency recovered from an outage
+func (p *Pipeline) Redispatch(taskID string, jobType JobType) error {
+	if taskID == "" {
+		return fmt.Errorf("no task ID to redispatch")
+	}
+
+	task, err := p.taskStore.GetByID(taskID)
+	if err != nil {
+		return fmt.Errorf("failed to load task for redispatch: %w", err)
+	}
+
+	job := &Job{
+		ID:     task.ID,
+		Type:   jobType,
+		Task:   task,
+		Status: JobStatusPending,
+	}
+
+	switch jobType {
+	case JobTypeExtraction:
+		return p.extractionPool.Submit(job)
+	case JobTypeConversion:
+		return p.conversionPool.Submit(job)
+	default:
+		return fmt.Errorf("cannot redispatch job type: %s", jobType)
+	}
+}
+
 func (p *Pipeline) coordinator() {
 	defer p.wg.Done()
 	
@@ -182,6 +213,10 @@ func (p *Pipeline) processCompletedJobs() {
 		if job.Status == JobStatusCompleted {
 			p.taskStore.UpdateStatus(job.Task.ID, models.TaskStatusCompleted, "")
 		} else if job.Status == JobStatusFailed {
+			if err := p.taskStore.RecordTaskError(storage.NewTaskError(job.Task.ID, storage.StageConversion,
+				job.Task.RetryCount+1, errors.New(job.Error), 0)); err != nil {
+				p.logger.WithError(err).WithField("task_id", job.Task.ID).Warn("Failed to record task error")
+			}
 			p.taskStore.UpdateStatus(job.Task.ID, models.TaskStatusFailed, job.Error)
 		}
 	}
@@ -197,14 +232,14 @@ func (p *Pipeline) autoMoveDownloadedFiles() {
 	// This is just a monitoring function to ensure files don't get stuck
 	
 	// Get downloaded tasks count for monitoring
-	downloadedTasks, err := p.taskStore.GetByStatus(models.TaskStatusDownloaded)
+	downloadedTasks, err := p.taskStore.GetTaskCountByStatus(models.TaskStatusDownloaded)
 	if err != nil {
 		p.logger.WithError(err).Debug("Failed to get downloaded tasks count for auto-move monitoring")
 		return
 	}
 	
-	if len(downloadedTasks) > 0 {
-		p.logger.WithField("downloaded_tasks", len(downloadedTasks)).
+	if downloadedTasks > 0 {
+		p.logger.WithField("downloaded_tasks", downloadedTasks).
 			Debug("Found downloaded tasks that may need to be moved")
 		// The actual movement will be handled by the download worker's auto-move functionality
 		// or when extraction is triggered
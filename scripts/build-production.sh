@@ -160,7 +160,11 @@ compile_bot() {
     go mod download
     
     echo -e "${YELLOW}📦 Building production binary...${NC}"
-    go build -ldflags="-s -w" -o "$PROD_PATH/telegram-archive-bot" .
+    # Embed the version and commit shown by /version, /status, health checks and crash reports
+    BUILD_VERSION="$(git -C "$PROJECT_ROOT" describe --tags --always 2>/dev/null || echo unknown)"
+    BUILD_COMMIT="$(git -C "$PROJECT_ROOT" rev-parse HEAD 2>/dev/null || true)"
+    BUILD_TIME="$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+    go build -ldflags="-s -w -X telegram-archive-bot/utils.buildVersion=$BUILD_VERSION -X telegram-archive-bot/utils.buildCommit=$BUILD_COMMIT -X telegram-archive-bot/utils.buildTime=$BUILD_TIME" -o "$PROD_PATH/telegram-archive-bot" .
     
     if [ ! -f "$PROD_PATH/telegram-archive-bot" ]; then
         echo -e "${RED}❌ Failed to compile bot binary${NC}"
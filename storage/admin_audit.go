@@ -18,11 +18,18 @@ const (
 	AdminActionFileUpload      AdminAuditAction = "FILE_UPLOAD"
 	AdminActionFileDownload    AdminAuditAction = "FILE_DOWNLOAD"
 	AdminActionFileDelete      AdminAuditAction = "FILE_DELETE"
+	AdminActionTaskDelete      AdminAuditAction = "TASK_DELETE"
+	AdminActionTaskUndelete    AdminAuditAction = "TASK_UNDELETE"
+	AdminActionTaskPurge       AdminAuditAction = "TASK_PURGE"
+	AdminActionTaskRetry       AdminAuditAction = "TASK_RETRY"
+	AdminActionReprocess       AdminAuditAction = "REPROCESS_CAMPAIGN"
+	AdminActionRestore         AdminAuditAction = "DATABASE_RESTORE"
 	
 	// System actions
 	AdminActionExtract         AdminAuditAction = "EXTRACT"
 	AdminActionConvert         AdminAuditAction = "CONVERT"
 	AdminActionCleanup         AdminAuditAction = "CLEANUP"
+	AdminActionRetentionPurge  AdminAuditAction = "RETENTION_PURGE"
 	AdminActionStop            AdminAuditAction = "STOP"
 	AdminActionExit            AdminAuditAction = "EXIT"
 	
@@ -36,11 +43,13 @@ const (
 	AdminActionMetricsView     AdminAuditAction = "METRICS_VIEW"
 	AdminActionSystemDiag      AdminAuditAction = "SYSTEM_DIAGNOSTIC"
 	AdminActionConfigChange    AdminAuditAction = "CONFIG_CHANGE"
+	AdminActionDependencyRecovery AdminAuditAction = "DEPENDENCY_RECOVERY"
 	
 	// Authentication events
 	AdminActionLogin           AdminAuditAction = "LOGIN"
 	AdminActionUnauthorized    AdminAuditAction = "UNAUTHORIZED_ATTEMPT"
 	AdminActionRateLimit       AdminAuditAction = "RATE_LIMITED"
+	AdminActionAPIRequest      AdminAuditAction = "API_REQUEST"
 )
 
 // AdminAuditEntry represents a single audit log entry
@@ -65,6 +74,10 @@ type AdminAuditEntry struct {
 type AdminAuditLogger struct {
 	db     *sql.DB
 	logger *utils.Logger
+
+	// sessionID and clientInfo are stamped on entries that don't set their own
+	sessionID  string
+	clientInfo map[string]interface{}
 }
 
 // NewAdminAuditLogger creates a new admin audit logger
@@ -75,12 +88,29 @@ func NewAdminAuditLogger(db *sql.DB, logger *utils.Logger) *AdminAuditLogger {
 	}
 }
 
+// WithSession returns a logger that records its entries as part of an admin
+// session, with the client the admin used
+func (aal *AdminAuditLogger) WithSession(sessionID string, clientInfo map[string]interface{}) *AdminAuditLogger {
+	return &AdminAuditLogger{
+		db:         aal.db,
+		logger:     aal.logger,
+		sessionID:  sessionID,
+		clientInfo: clientInfo,
+	}
+}
+
 // LogAdminAction logs an admin action to the audit trail
 func (aal *AdminAuditLogger) LogAdminAction(entry AdminAuditEntry) error {
 	// Ensure timestamp is set
 	if entry.Timestamp.IsZero() {
 		entry.Timestamp = time.Now()
 	}
+	if entry.SessionID == "" {
+		entry.SessionID = aal.sessionID
+	}
+	if entry.ClientInfo == nil {
+		entry.ClientInfo = aal.clientInfo
+	}
 
 	// Serialize details and client info as JSON
 	detailsJSON, err := json.Marshal(entry.Details)
@@ -227,6 +257,25 @@ func (aal *AdminAuditLogger) LogSystemAction(userID int64, username string, acti
 	}
 }
 
+// LogRecoveryAttempt logs an automatic dependency recovery attempt as a system action
+func (aal *AdminAuditLogger) LogRecoveryAttempt(attempt utils.RecoveryAttempt) {
+	details := map[string]interface{}{
+		"action":      attempt.Action,
+		"recovered":   attempt.Recovered,
+		"duration_ms": attempt.Duration.Milliseconds(),
+	}
+
+	result := "SUCCESS"
+	var err error
+	if attempt.Error != "" {
+		err = fmt.Errorf("%s", attempt.Error)
+	} else if !attempt.Recovered {
+		result = "STILL_UNAVAILABLE"
+	}
+
+	aal.LogSystemAction(0, "system", AdminActionDependencyRecovery, attempt.Dependency, details, result, err)
+}
+
 // LogSecurityEvent logs security-related events
 func (aal *AdminAuditLogger) LogSecurityEvent(userID int64, username string, action AdminAuditAction, resource string, details map[string]interface{}, severity string) {
 	if details == nil {
@@ -296,70 +345,91 @@ func (aal *AdminAuditLogger) LogRateLimitEvent(userID int64, username, resource
 	}
 }
 
-// GetAuditEntries retrieves audit entries with filtering and pagination
-func (aal *AdminAuditLogger) GetAuditEntries(filters AuditFilters) ([]AdminAuditEntry, error) {
-	query := `
-		SELECT id, user_id, username, action, resource, details, client_info, 
-		       result, error_message, ip_address, user_agent, session_id, 
-		       timestamp, duration_ms
-		FROM admin_audit_log 
-		WHERE 1=1
-	`
-	args := []interface{}{}
-	argIndex := 1
-
-	// Apply filters
-	if filters.UserID != 0 {
-		query += fmt.Sprintf(" AND user_id = ?%d", argIndex)
-		args = append(args, filters.UserID)
-		argIndex++
-	}
+// APIRequest is a call to the HTTP API or the cluster job service
+type APIRequest struct {
+	Client    string // Authenticated client name, "" when unauthenticated
+	Method    string // HTTP method, or "gRPC"
+	Resource  string // Path or gRPC method
+	IPAddress string
+	UserAgent string
+	Status    int    // HTTP status, 0 for gRPC
+	Result    string // SUCCESS, FAILED or BLOCKED
+	Error     string
+	Duration  time.Duration
+}
 
-	if filters.Action != "" {
-		query += fmt.Sprintf(" AND action = ?%d", argIndex)
-		args = append(args, filters.Action)
-		argIndex++
+// LogAPIRequest logs a call to one of the network APIs
+func (aal *AdminAuditLogger) LogAPIRequest(req APIRequest) {
+	details := map[string]interface{}{"method": req.Method}
+	if req.Status != 0 {
+		details["status"] = req.Status
 	}
 
-	if !filters.StartTime.IsZero() {
-		query += fmt.Sprintf(" AND timestamp >= ?%d", argIndex)
-		args = append(args, filters.StartTime)
-		argIndex++
+	entry := AdminAuditEntry{
+		Username:  req.Client,
+		Action:    AdminActionAPIRequest,
+		Resource:  req.Resource,
+		Details:   details,
+		Result:    req.Result,
+		ErrorMsg:  req.Error,
+		IPAddress: req.IPAddress,
+		UserAgent: req.UserAgent,
+		Timestamp: time.Now(),
+		Duration:  req.Duration.Milliseconds(),
 	}
 
-	if !filters.EndTime.IsZero() {
-		query += fmt.Sprintf(" AND timestamp <= ?%d", argIndex)
-		args = append(args, filters.EndTime)
-		argIndex++
+	if logErr := aal.LogAdminAction(entry); logErr != nil {
+		aal.logger.WithError(logErr).Error("Failed to log API request audit entry")
 	}
+}
 
-	if filters.Resource != "" {
-		query += fmt.Sprintf(" AND resource LIKE ?%d", argIndex)
-		args = append(args, "%"+filters.Resource+"%")
-		argIndex++
-	}
+// auditSortColumns are the columns audit entries can be sorted by
+var auditSortColumns = map[string]string{
+	"timestamp": "timestamp",
+	"user":      "user_id",
+	"action":    "action",
+	"result":    "result",
+	"duration":  "duration_ms",
+}
 
-	if filters.Result != "" {
-		query += fmt.Sprintf(" AND result = ?%d", argIndex)
-		args = append(args, filters.Result)
-		argIndex++
-	}
+// auditQuery applies the filters to a query of admin_audit_log
+func (filters AuditFilters) auditQuery(columns string) *selectQuery {
+	return newSelectQuery("admin_audit_log", columns).
+		WhereIf(filters.UserID != 0, "user_id = ?", filters.UserID).
+		WhereIf(filters.Action != "", "action = ?", filters.Action).
+		WhereIf(!filters.StartTime.IsZero(), "timestamp >= ?", filters.StartTime).
+		WhereIf(!filters.EndTime.IsZero(), "timestamp <= ?", filters.EndTime).
+		WhereIf(filters.Resource != "", "resource LIKE ?", "%"+filters.Resource+"%").
+		WhereIf(filters.Result != "", "result = ? COLLATE NOCASE", filters.Result).
+		WhereIf(filters.SessionID != "", "session_id = ?", filters.SessionID)
+}
 
-	// Order by timestamp (newest first)
-	query += " ORDER BY timestamp DESC"
+// CountAuditEntries returns how many audit entries match the filters,
+// ignoring their limit and offset
+func (aal *AdminAuditLogger) CountAuditEntries(filters AuditFilters) (int, error) {
+	query, args := filters.auditQuery("").CountSQL()
 
-	// Apply limit
-	if filters.Limit > 0 {
-		query += fmt.Sprintf(" LIMIT ?%d", argIndex)
-		args = append(args, filters.Limit)
-		argIndex++
+	var count int
+	if err := aal.db.QueryRow(query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count audit entries: %w", err)
 	}
+	return count, nil
+}
 
-	if filters.Offset > 0 {
-		query += fmt.Sprintf(" OFFSET ?%d", argIndex)
-		args = append(args, filters.Offset)
+// GetAuditEntries retrieves audit entries with filtering, sorting and pagination
+func (aal *AdminAuditLogger) GetAuditEntries(filters AuditFilters) ([]AdminAuditEntry, error) {
+	sortBy, err := sortColumn(filters.SortBy, auditSortColumns, "timestamp")
+	if err != nil {
+		return nil, err
 	}
 
+	query, args := filters.auditQuery(`id, user_id, username, action, resource, details, client_info,
+		result, error_message, ip_address, user_agent, session_id,
+		timestamp, duration_ms`).
+		OrderBy(sortBy, filters.Ascending).
+		Page(filters.Limit, filters.Offset).
+		SQL()
+
 	rows, err := aal.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query audit entries: %w", err)
@@ -499,14 +569,55 @@ func (aal *AdminAuditLogger) GetAuditStats(timeRange time.Duration) (*AdminAudit
 	return stats, nil
 }
 
+// AdminActivity is an audit entry reduced to who did what when, for
+// behavior checks over many entries
+type AdminActivity struct {
+	UserID    int64
+	Action    AdminAuditAction
+	Timestamp time.Time
+}
+
+// GetAdminActivity returns the entries with any of the actions logged since
+// the given time, oldest first
+func (aal *AdminAuditLogger) GetAdminActivity(since time.Time, actions ...AdminAuditAction) ([]AdminActivity, error) {
+	values := make([]interface{}, len(actions))
+	for i, action := range actions {
+		values[i] = string(action)
+	}
+	query, args := newSelectQuery("admin_audit_log", "user_id, action, timestamp").
+		Where("timestamp >= ?", since).
+		WhereIn("action", values...).
+		OrderBy("timestamp", true).
+		SQL()
+
+	rows, err := aal.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query admin activity: %w", err)
+	}
+	defer rows.Close()
+
+	var activity []AdminActivity
+	for rows.Next() {
+		var a AdminActivity
+		if err := rows.Scan(&a.UserID, &a.Action, &a.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan admin activity: %w", err)
+		}
+		activity = append(activity, a)
+	}
+	return activity, rows.Err()
+}
+
 // AuditFilters defines filters for querying audit entries
 type AuditFilters struct {
 	UserID    int64
 	Action    string
 	Resource  string
 	Result    string
+	SessionID string
 	StartTime time.Time
 	EndTime   time.Time
+	SortBy    string // timestamp (default), user, action, result or duration
+	Ascending bool   // Oldest or smallest first; newest first by default
 	Limit     int
 	Offset    int
 }
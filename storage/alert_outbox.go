@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Alert outbox entry statuses
+const (
+	AlertOutboxPending    = "pending"
+	AlertOutboxDelivered  = "delivered"
+	AlertOutboxFailedOver = "failed_over" // Handed to the failover channels after the primary kept failing
+	AlertOutboxExpired    = "expired"     // Never delivered within the outbox's maximum age
+)
+
+// AlertOutboxEntry is an alert notification to deliver to one recipient over
+// one channel, retried until it is delivered, failed over or expired
+type AlertOutboxEntry struct {
+	ID            int64
+	MessageID     string // Shared by the copies of one notification across recipients and channels
+	Channel       string
+	Recipient     string // Empty for channels that deliver to their own configured destination
+	Text          string
+	Status        string
+	Attempts      int
+	LastError     string
+	CreatedAt     time.Time
+	NextAttemptAt time.Time
+	DeliveredAt   time.Time // Zero until delivered
+}
+
+// EnqueueAlertNotifications stores pending entries due right away. An entry
+// for a message, channel and recipient that is already stored is skipped, so
+// a notification fails over to each channel once
+func (ts *TaskStore) EnqueueAlertNotifications(entries []*AlertOutboxEntry) error {
+	tx, err := ts.db.DB().Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, entry := range entries {
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO alert_outbox (message_id, channel, recipient, text, status, created_at, next_attempt_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			entry.MessageID, entry.Channel, entry.Recipient, entry.Text, AlertOutboxPending,
+			entry.CreatedAt, entry.CreatedAt); err != nil {
+			return fmt.Errorf("failed to enqueue alert notification for %s: %w", entry.Channel, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit alert notifications: %w", err)
+	}
+	return nil
+}
+
+// GetDueAlertNotifications returns up to limit pending entries whose next
+// attempt is due, oldest first
+func (ts *TaskStore) GetDueAlertNotifications(now time.Time, limit int) ([]*AlertOutboxEntry, error) {
+	rows, err := ts.db.DB().Query(`
+		SELECT id, message_id, channel, recipient, text, status, attempts, last_error, created_at, next_attempt_at, delivered_at
+		FROM alert_outbox
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY created_at, id
+		LIMIT ?`, AlertOutboxPending, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due alert notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*AlertOutboxEntry
+	for rows.Next() {
+		entry := &AlertOutboxEntry{}
+		var deliveredAt sql.NullTime
+		if err := rows.Scan(&entry.ID, &entry.MessageID, &entry.Channel, &entry.Recipient, &entry.Text,
+			&entry.Status, &entry.Attempts, &entry.LastError, &entry.CreatedAt, &entry.NextAttemptAt,
+			&deliveredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan alert notification: %w", err)
+		}
+		if deliveredAt.Valid {
+			entry.DeliveredAt = deliveredAt.Time
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// MarkAlertNotificationDelivered records a successful delivery
+func (ts *TaskStore) MarkAlertNotificationDelivered(id int64, attempts int, at time.Time) error {
+	_, err := ts.db.DB().Exec(`UPDATE alert_outbox SET status = ?, attempts = ?, delivered_at = ? WHERE id = ?`,
+		AlertOutboxDelivered, attempts, at, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark alert notification %d delivered: %w", id, err)
+	}
+	return nil
+}
+
+// RetryAlertNotification records a failed attempt and when to try again
+func (ts *TaskStore) RetryAlertNotification(id int64, attempts int, lastError string, next time.Time) error {
+	_, err := ts.db.DB().Exec(`UPDATE alert_outbox SET attempts = ?, last_error = ?, next_attempt_at = ? WHERE id = ?`,
+		attempts, lastError, next, id)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule alert notification %d: %w", id, err)
+	}
+	return nil
+}
+
+// CloseAlertNotification ends an entry that will not be delivered, as
+// failed over or expired
+func (ts *TaskStore) CloseAlertNotification(id int64, status string, attempts int, lastError string) error {
+	_, err := ts.db.DB().Exec(`UPDATE alert_outbox SET status = ?, attempts = ?, last_error = ? WHERE id = ?`,
+		status, attempts, lastError, id)
+	if err != nil {
+		return fmt.Errorf("failed to close alert notification %d: %w", id, err)
+	}
+	return nil
+}
+
+// CountAlertNotifications returns the number of entries per status
+func (ts *TaskStore) CountAlertNotifications() (map[string]int, error) {
+	rows, err := ts.db.DB().Query(`SELECT status, COUNT(*) FROM alert_outbox GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count alert notifications: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan alert notification count: %w", err)
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+// PurgeAlertNotifications deletes finished entries created before the given time
+func (ts *TaskStore) PurgeAlertNotifications(before time.Time) (int64, error) {
+	res, err := ts.db.DB().Exec(`DELETE FROM alert_outbox WHERE status != ? AND created_at < ?`,
+		AlertOutboxPending, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge alert notifications: %w", err)
+	}
+	return res.RowsAffected()
+}
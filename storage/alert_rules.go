@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AlertRuleRecord is a stored alert rule. Threshold rules fire when Metric
+// compared with Threshold by Operator holds, composite rules when all their
+// Conditions do and anomaly rules when Metric strays Threshold standard
+// deviations from its Baseline average; each at most once per Cooldown.
+// Disk rules may compare each monitored volume with its own threshold
+type AlertRuleRecord struct {
+	Name           string
+	Kind           string
+	AlertType      string
+	Level          string
+	Metric         string
+	Operator       string
+	Threshold      float64
+	Conditions     string // JSON list of the conditions of a composite rule
+	PathThresholds string // JSON object of per-volume thresholds of a disk rule
+	For            time.Duration
+	Baseline       time.Duration
+	Message        string
+	Cooldown       time.Duration
+	Enabled        bool
+	Builtin        bool      // Shipped with the bot; can be edited and disabled but not deleted
+	LastFired      time.Time // Zero when the rule never fired
+	UpdatedAt      time.Time
+}
+
+// SaveAlertRule stores a rule definition, replacing the one with the same
+// name. LastFired is left as stored; SetAlertRuleLastFired records it
+func (ts *TaskStore) SaveAlertRule(rule *AlertRuleRecord) error {
+	_, err := ts.db.DB().Exec(`
+		INSERT INTO alert_rules (name, kind, alert_type, level, metric, operator, threshold, conditions,
+			path_thresholds, for_seconds, baseline_seconds, message, cooldown_seconds, enabled, builtin, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			kind = excluded.kind,
+			alert_type = excluded.alert_type,
+			level = excluded.level,
+			metric = excluded.metric,
+			operator = excluded.operator,
+			threshold = excluded.threshold,
+			conditions = excluded.conditions,
+			path_thresholds = excluded.path_thresholds,
+			for_seconds = excluded.for_seconds,
+			baseline_seconds = excluded.baseline_seconds,
+			message = excluded.message,
+			cooldown_seconds = excluded.cooldown_seconds,
+			enabled = excluded.enabled,
+			builtin = excluded.builtin,
+			updated_at = excluded.updated_at`,
+		rule.Name, rule.Kind, rule.AlertType, rule.Level, rule.Metric, rule.Operator, rule.Threshold, rule.Conditions,
+		rule.PathThresholds, int64(rule.For.Seconds()), int64(rule.Baseline.Seconds()), rule.Message,
+		int64(rule.Cooldown.Seconds()), rule.Enabled, rule.Builtin, rule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save alert rule %s: %w", rule.Name, err)
+	}
+	return nil
+}
+
+// SetAlertRuleLastFired records when a rule last fired, so its cooldown
+// holds across restarts
+func (ts *TaskStore) SetAlertRuleLastFired(name string, at time.Time) error {
+	_, err := ts.db.DB().Exec(`UPDATE alert_rules SET last_fired = ? WHERE name = ?`, at, name)
+	if err != nil {
+		return fmt.Errorf("failed to record alert rule %s firing: %w", name, err)
+	}
+	return nil
+}
+
+// DeleteAlertRule removes a rule, returning false if there was none
+func (ts *TaskStore) DeleteAlertRule(name string) (bool, error) {
+	res, err := ts.db.DB().Exec(`DELETE FROM alert_rules WHERE name = ?`, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete alert rule %s: %w", name, err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// GetAlertRules returns every stored rule by name
+func (ts *TaskStore) GetAlertRules() ([]*AlertRuleRecord, error) {
+	rows, err := ts.db.DB().Query(`
+		SELECT name, kind, alert_type, level, metric, operator, threshold, conditions, path_thresholds,
+			for_seconds, baseline_seconds, message, cooldown_seconds, enabled, builtin, last_fired, updated_at
+		FROM alert_rules
+		ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*AlertRuleRecord
+	for rows.Next() {
+		rule := &AlertRuleRecord{}
+		var forSeconds, baselineSeconds, cooldownSeconds int64
+		var lastFired sql.NullTime
+		if err := rows.Scan(&rule.Name, &rule.Kind, &rule.AlertType, &rule.Level, &rule.Metric, &rule.Operator,
+			&rule.Threshold, &rule.Conditions, &rule.PathThresholds, &forSeconds, &baselineSeconds, &rule.Message, &cooldownSeconds,
+			&rule.Enabled, &rule.Builtin, &lastFired, &rule.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		rule.For = time.Duration(forSeconds) * time.Second
+		rule.Baseline = time.Duration(baselineSeconds) * time.Second
+		rule.Cooldown = time.Duration(cooldownSeconds) * time.Second
+		if lastFired.Valid {
+			rule.LastFired = lastFired.Time
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
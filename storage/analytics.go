@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// DomainCount is the credentials found for one domain over a window
+type DomainCount struct {
+	Domain      string    `json:"domain"`
+	Credentials int       `json:"credentials"`
+	Passes      int       `json:"passes"`     // Conversion passes in the window with the domain
+	FirstSeen   time.Time `json:"first_seen"` // First pass with the domain, in or before the window
+}
+
+// ArchiveOverlap is how much of one conversion pass was already known: the
+// share of its domains, and of the credentials on them, that earlier passes
+// had found. A pass covers every archive extracted in that cycle
+type ArchiveOverlap struct {
+	ResultID          int64     `json:"result_id"`
+	RecordedAt        time.Time `json:"recorded_at"`
+	Archives          []string  `json:"archives"`
+	Domains           int       `json:"domains"`
+	KnownDomains      int       `json:"known_domains"`
+	Credentials       int       `json:"credentials"`
+	KnownCredentials  int       `json:"known_credentials"` // On domains seen before
+	DomainOverlap     float64   `json:"domain_overlap_percent"`
+	CredentialOverlap float64   `json:"credential_overlap_percent"`
+}
+
+// DomainAnalytics are the domain statistics of converted data over a window
+type DomainAnalytics struct {
+	Since          time.Time        `json:"since"`
+	Domains        int              `json:"domains"` // Distinct domains in the window
+	Credentials    int              `json:"credentials"`
+	TopDomains     []DomainCount    `json:"top_domains"`
+	NewDomainCount int              `json:"new_domain_count"`
+	NewDomains     []DomainCount    `json:"new_domains"` // First seen in the window, most credentials first
+	Overlaps       []ArchiveOverlap `json:"overlaps"`    // Newest passes first
+}
+
+// GetDomainAnalytics reports the top domains, the domains first seen since
+// the given time and the overlap of each pass with earlier ones. Each list is
+// capped at limit entries
+func (ts *TaskStore) GetDomainAnalytics(since time.Time, limit int) (*DomainAnalytics, error) {
+	analytics := &DomainAnalytics{Since: since}
+
+	err := ts.db.DB().QueryRow(`
+		SELECT COUNT(DISTINCT d.domain), COALESCE(SUM(d.credentials), 0)
+		FROM conversion_domains d JOIN conversion_results r ON r.id = d.result_id
+		WHERE r.recorded_at >= ?`, since).Scan(&analytics.Domains, &analytics.Credentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count domains: %w", err)
+	}
+
+	analytics.TopDomains, err = ts.queryDomainCounts(`
+		SELECT d.domain, SUM(d.credentials), COUNT(*), (
+			SELECT MIN(fr.recorded_at) FROM conversion_domains fd
+			JOIN conversion_results fr ON fr.id = fd.result_id
+			WHERE fd.domain = d.domain
+		)
+		FROM conversion_domains d JOIN conversion_results r ON r.id = d.result_id
+		WHERE r.recorded_at >= ?
+		GROUP BY d.domain
+		ORDER BY SUM(d.credentials) DESC, d.domain
+		LIMIT ?`, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top domains: %w", err)
+	}
+
+	newDomains := `
+		SELECT d.domain, SUM(d.credentials) AS credentials, COUNT(*), MIN(r.recorded_at)
+		FROM conversion_domains d JOIN conversion_results r ON r.id = d.result_id
+		GROUP BY d.domain
+		HAVING MIN(r.recorded_at) >= ?`
+	if err := ts.db.DB().QueryRow(`SELECT COUNT(*) FROM (`+newDomains+`)`, since).Scan(&analytics.NewDomainCount); err != nil {
+		return nil, fmt.Errorf("failed to count new domains: %w", err)
+	}
+	analytics.NewDomains, err = ts.queryDomainCounts(newDomains+`
+		ORDER BY credentials DESC, d.domain
+		LIMIT ?`, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get new domains: %w", err)
+	}
+
+	analytics.Overlaps, err = ts.getArchiveOverlaps(since, limit)
+	if err != nil {
+		return nil, err
+	}
+	return analytics, nil
+}
+
+// queryDomainCounts runs a query returning domain, credentials, passes and first seen
+func (ts *TaskStore) queryDomainCounts(query string, args ...interface{}) ([]DomainCount, error) {
+	rows, err := ts.db.DB().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []DomainCount
+	for rows.Next() {
+		var count DomainCount
+		var firstSeen string
+		if err := rows.Scan(&count.Domain, &count.Credentials, &count.Passes, &firstSeen); err != nil {
+			return nil, err
+		}
+		// MIN() loses the column's DATETIME type, so the driver returns text
+		count.FirstSeen = parseSQLiteTime(firstSeen)
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// getArchiveOverlaps computes the overlap of the newest passes in the window
+// with all passes recorded before them
+func (ts *TaskStore) getArchiveOverlaps(since time.Time, limit int) ([]ArchiveOverlap, error) {
+	rows, err := ts.db.DB().Query(`
+		WITH pass_domains AS (
+			SELECT d.result_id, d.credentials, EXISTS (
+				SELECT 1 FROM conversion_domains p
+				WHERE p.domain = d.domain AND p.result_id < d.result_id
+			) AS known
+			FROM conversion_domains d JOIN conversion_results r ON r.id = d.result_id
+			WHERE r.recorded_at >= ?
+		)
+		SELECT r.id, r.recorded_at, COUNT(*), SUM(pd.credentials), SUM(pd.known), SUM(pd.known * pd.credentials)
+		FROM pass_domains pd JOIN conversion_results r ON r.id = pd.result_id
+		GROUP BY r.id
+		ORDER BY r.recorded_at DESC
+		LIMIT ?`, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archive overlaps: %w", err)
+	}
+
+	var overlaps []ArchiveOverlap
+	for rows.Next() {
+		var o ArchiveOverlap
+		if err := rows.Scan(&o.ResultID, &o.RecordedAt, &o.Domains, &o.Credentials,
+			&o.KnownDomains, &o.KnownCredentials); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan archive overlap: %w", err)
+		}
+		if o.Domains > 0 {
+			o.DomainOverlap = float64(o.KnownDomains) / float64(o.Domains) * 100
+		}
+		if o.Credentials > 0 {
+			o.CredentialOverlap = float64(o.KnownCredentials) / float64(o.Credentials) * 100
+		}
+		overlaps = append(overlaps, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read archive overlaps: %w", err)
+	}
+
+	for i := range overlaps {
+		overlaps[i].Archives, err = ts.getResultArchives(overlaps[i].ResultID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return overlaps, nil
+}
+
+// getResultArchives returns the file names of the tasks converted in a pass
+func (ts *TaskStore) getResultArchives(resultID int64) ([]string, error) {
+	rows, err := ts.db.DB().Query(`
+		SELECT t.file_name FROM task_conversion_results tc
+		JOIN tasks t ON t.id = tc.task_id
+		WHERE tc.result_id = ?
+		ORDER BY t.created_at`, resultID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pass archives: %w", err)
+	}
+	defer rows.Close()
+
+	archives := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan pass archive: %w", err)
+		}
+		archives = append(archives, name)
+	}
+	return archives, rows.Err()
+}
+
+// parseSQLiteTime parses a timestamp stored by the sqlite3 driver, for
+// aggregates that are returned as text; it returns the zero time otherwise
+func parseSQLiteTime(value string) time.Time {
+	value = strings.TrimSuffix(value, "Z")
+	for _, format := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(format, value, time.UTC); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
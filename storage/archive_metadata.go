@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"telegram-archive-bot/utils"
+)
+
+// SaveArchiveMetadata stores the archive metadata of a task, replacing any
+// earlier inspection of the same task
+func (ts *TaskStore) SaveArchiveMetadata(taskID string, meta *utils.ArchiveMetadata) error {
+	methods, err := json.Marshal(meta.Methods)
+	if err != nil {
+		return fmt.Errorf("failed to encode compression methods: %w", err)
+	}
+
+	_, err = ts.db.DB().Exec(`
+		INSERT OR REPLACE INTO archive_metadata (task_id, format, entries, directories,
+			uncompressed_size, compressed_size, max_entry_ratio, methods, encrypted_entries,
+			encrypted, comment, entry_comments, partial, error, inspected_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		taskID, meta.Format, meta.Entries, meta.Directories, meta.UncompressedSize,
+		meta.CompressedSize, meta.MaxEntryRatio, string(methods), meta.EncryptedEntries,
+		meta.Encrypted, meta.Comment, meta.EntryComments, meta.Partial, meta.Error, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save archive metadata: %w", err)
+	}
+	return nil
+}
+
+// GetArchiveMetadata returns the archive metadata of a task, nil when the
+// task's file was never inspected
+func (ts *TaskStore) GetArchiveMetadata(taskID string) (*utils.ArchiveMetadata, error) {
+	var meta utils.ArchiveMetadata
+	var methods string
+	err := ts.db.DB().QueryRow(`
+		SELECT format, entries, directories, uncompressed_size, compressed_size, max_entry_ratio,
+			methods, encrypted_entries, encrypted, comment, entry_comments, partial, error
+		FROM archive_metadata WHERE task_id = ?`, taskID).Scan(
+		&meta.Format, &meta.Entries, &meta.Directories, &meta.UncompressedSize,
+		&meta.CompressedSize, &meta.MaxEntryRatio, &methods, &meta.EncryptedEntries,
+		&meta.Encrypted, &meta.Comment, &meta.EntryComments, &meta.Partial, &meta.Error)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archive metadata: %w", err)
+	}
+
+	if methods != "" && methods != "null" {
+		if err := json.Unmarshal([]byte(methods), &meta.Methods); err != nil {
+			return nil, fmt.Errorf("failed to decode compression methods: %w", err)
+		}
+	}
+	return &meta, nil
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Availability statuses recorded for component intervals; they match the
+// health check statuses
+const (
+	AvailabilityHealthy   = "HEALTHY"
+	AvailabilityDegraded  = "DEGRADED"
+	AvailabilityUnhealthy = "UNHEALTHY"
+)
+
+// ComponentAvailability is how long a component spent in each status over a period
+type ComponentAvailability struct {
+	Component       string
+	Healthy         time.Duration
+	Degraded        time.Duration
+	Unhealthy       time.Duration
+	Monitored       time.Duration
+	UptimePercent   float64 // Healthy or degraded share of the monitored time
+	CoveragePercent float64 // Monitored share of the period so far
+	Outages         int     // Unhealthy intervals
+}
+
+// AvailabilityReport summarizes component availability over a period
+type AvailabilityReport struct {
+	From       time.Time
+	To         time.Time
+	Components []ComponentAvailability
+}
+
+// StartAvailabilityInterval opens a new status interval for a component and returns its ID
+func (ts *TaskStore) StartAvailabilityInterval(component, status string, at time.Time) (int64, error) {
+	res, err := ts.db.DB().Exec(`
+		INSERT INTO availability_intervals (component, status, started_at, ended_at)
+		VALUES (?, ?, ?, ?)`, component, status, at, at)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start availability interval: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// ExtendAvailabilityInterval moves the end of an interval to the latest check
+func (ts *TaskStore) ExtendAvailabilityInterval(id int64, at time.Time) error {
+	_, err := ts.db.DB().Exec(`UPDATE availability_intervals SET ended_at = ? WHERE id = ?`, at, id)
+	if err != nil {
+		return fmt.Errorf("failed to extend availability interval: %w", err)
+	}
+	return nil
+}
+
+// GetAvailabilityReport totals the recorded intervals of each component that
+// overlap [from, to). Time without intervals, e.g. while the bot was not
+// running, counts as unmonitored rather than down
+func (ts *TaskStore) GetAvailabilityReport(from, to time.Time) (*AvailabilityReport, error) {
+	rows, err := ts.db.DB().Query(`
+		SELECT component, status, started_at, ended_at
+		FROM availability_intervals
+		WHERE ended_at > ? AND started_at < ?
+		ORDER BY started_at`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query availability intervals: %w", err)
+	}
+	defer rows.Close()
+
+	byComponent := make(map[string]*ComponentAvailability)
+	for rows.Next() {
+		var component, status string
+		var startedAt, endedAt time.Time
+		if err := rows.Scan(&component, &status, &startedAt, &endedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan availability interval: %w", err)
+		}
+
+		if startedAt.Before(from) {
+			startedAt = from
+		}
+		if endedAt.After(to) {
+			endedAt = to
+		}
+		duration := endedAt.Sub(startedAt)
+
+		availability, ok := byComponent[component]
+		if !ok {
+			availability = &ComponentAvailability{Component: component}
+			byComponent[component] = availability
+		}
+		switch status {
+		case AvailabilityHealthy:
+			availability.Healthy += duration
+		case AvailabilityDegraded:
+			availability.Degraded += duration
+		default:
+			availability.Unhealthy += duration
+			availability.Outages++
+		}
+		availability.Monitored += duration
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read availability intervals: %w", err)
+	}
+
+	// Coverage is measured against the part of the period that has passed
+	elapsed := to.Sub(from)
+	if now := time.Now(); now.Before(to) {
+		elapsed = now.Sub(from)
+	}
+
+	report := &AvailabilityReport{From: from, To: to}
+	for _, availability := range byComponent {
+		if availability.Monitored > 0 {
+			availability.UptimePercent = float64(availability.Healthy+availability.Degraded) * 100 / float64(availability.Monitored)
+		}
+		if elapsed > 0 {
+			availability.CoveragePercent = float64(availability.Monitored) * 100 / float64(elapsed)
+		}
+		report.Components = append(report.Components, *availability)
+	}
+	sort.Slice(report.Components, func(i, j int) bool {
+		return report.Components[i].Component < report.Components[j].Component
+	})
+	return report, nil
+}
+
+// MonthRange returns the start of the month containing t and of the month after it
+func MonthRange(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 1, 0)
+}
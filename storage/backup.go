@@ -1,13 +1,20 @@
 package storage
 
 import (
-	"compress/gzip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
+
+	"telegram-archive-bot/app/extraction/compression"
+	"telegram-archive-bot/utils"
 )
 
 // BackupService provides database backup and restore functionality
@@ -15,14 +22,19 @@ type BackupService struct {
 	db         *Database
 	backupDir  string
 	retention  time.Duration
+	codec      compression.Codec // Used for the backup taken before a restore
+	level      int
 }
 
 // BackupOptions configures backup behavior
 type BackupOptions struct {
-	BackupDir       string        // Directory to store backups
-	RetentionPeriod time.Duration // How long to keep backups
-	Compress        bool          // Whether to compress backups
-	VerifyBackup    bool          // Whether to verify backup integrity
+	BackupDir       string            // Directory to store backups
+	RetentionPeriod time.Duration     // How long to keep backups
+	Compress        bool              // Whether to compress backups, with gzip unless Codec is set
+	Codec           compression.Codec // Compression codec, overrides Compress when set
+	Level           int               // Compression level, 0 for the codec default
+	VerifyBackup    bool              // Whether to verify backup integrity
+	Progress        ProgressFunc      // Receives progress while dumping and verifying, may be nil
 }
 
 // RestoreOptions configures restore behavior
@@ -30,6 +42,8 @@ type RestoreOptions struct {
 	BackupFile      string // Path to backup file to restore from
 	VerifyRestore   bool   // Whether to verify restore integrity
 	CreateBackup    bool   // Whether to backup current DB before restore
+	Progress        ProgressFunc // Receives progress of the pre-restore backup and the restore, may be nil
+	ForceMigrate    bool   // Restore backups of unknown or newer schema versions anyway
 }
 
 // NewBackupService creates a new backup service instance
@@ -47,23 +61,38 @@ func NewBackupService(db *Database, opts BackupOptions) (*BackupService, error)
 		return nil, fmt.Errorf("failed to create backup directory: %w", err)
 	}
 
+	codec := opts.codec()
+	if err := codec.CheckLevel(opts.Level); err != nil {
+		return nil, err
+	}
+
 	return &BackupService{
 		db:        db,
 		backupDir: opts.BackupDir,
 		retention: opts.RetentionPeriod,
+		codec:     codec,
+		level:     opts.Level,
 	}, nil
 }
 
-// CreateBackup creates a new database backup
-func (bs *BackupService) CreateBackup(opts BackupOptions) (string, error) {
-	timestamp := time.Now().Format("20060102_150405")
-	backupName := fmt.Sprintf("bot_backup_%s.sql", timestamp)
-	
-	// Add .gz extension if compression is requested
+// codec returns the compression codec the options select
+func (opts BackupOptions) codec() compression.Codec {
+	if opts.Codec != "" {
+		return opts.Codec
+	}
 	if opts.Compress {
-		backupName += ".gz"
+		return compression.Gzip
 	}
-	
+	return compression.None
+}
+
+// CreateBackup creates a new database backup
+func (bs *BackupService) CreateBackup(opts BackupOptions) (string, error) {
+	startTime := time.Now()
+	timestamp := startTime.Format("20060102_150405")
+	codec := opts.codec()
+	backupName := fmt.Sprintf("bot_backup_%s.sql", timestamp) + codec.Extension()
+
 	backupPath := filepath.Join(bs.backupDir, backupName)
 
 	// Create the backup file
@@ -73,38 +102,90 @@ func (bs *BackupService) CreateBackup(opts BackupOptions) (string, error) {
 	}
 	defer backupFile.Close()
 
-	var writer io.Writer = backupFile
-	var gzipWriter *gzip.Writer
-
-	// Use compression if requested
-	if opts.Compress {
-		gzipWriter = gzip.NewWriter(backupFile)
-		writer = gzipWriter
+	// Hash the file as it is written, for the catalog
+	hasher := sha256.New()
+	reporter := newProgressReporter(opts.Progress, BackupPhaseDump)
+	writer, err := compression.NewWriter(io.MultiWriter(backupFile, hasher, reporter), codec, opts.Level)
+	if err != nil {
+		os.Remove(backupPath)
+		return "", fmt.Errorf("failed to create %s writer: %w", codec, err)
 	}
 
 	// Perform the backup
-	if err := bs.dumpDatabase(writer); err != nil {
+	rowCounts, schemaVersion, err := bs.dumpDatabase(writer, reporter)
+	if err != nil {
 		os.Remove(backupPath) // Clean up partial backup
 		return "", fmt.Errorf("failed to dump database: %w", err)
 	}
 
-	// Close gzip writer if used
-	if gzipWriter != nil {
-		if err := gzipWriter.Close(); err != nil {
-			return "", fmt.Errorf("failed to close gzip writer: %w", err)
-		}
+	// Flush the compressed stream
+	if err := writer.Close(); err != nil {
+		os.Remove(backupPath)
+		return "", fmt.Errorf("failed to close %s writer: %w", codec, err)
 	}
+	if err := backupFile.Sync(); err != nil {
+		os.Remove(backupPath)
+		return "", fmt.Errorf("failed to sync backup file: %w", err)
+	}
+	reporter.finish()
 
-	// Verify backup if requested
+	info, err := backupFile.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat backup file: %w", err)
+	}
+	host, _ := os.Hostname()
+	meta := BackupMetadata{
+		Name:          backupName,
+		Created:       startTime,
+		Host:          host,
+		SchemaVersion: schemaVersion,
+		AppVersion:    utils.BotVersion(),
+		Duration:      time.Since(startTime).Seconds(),
+		Size:          info.Size(),
+		SHA256:        hex.EncodeToString(hasher.Sum(nil)),
+		Codec:         codec,
+		Level:         opts.Level,
+		RowCounts:     rowCounts,
+	}
+
+	// Verify backup if requested; the result is recorded even when it fails
 	if opts.VerifyBackup {
-		if err := bs.verifyBackup(backupPath, opts.Compress); err != nil {
-			return "", fmt.Errorf("backup verification failed: %w", err)
-		}
+		verification := bs.verifyBackup(backupPath, rowCounts, opts.Progress)
+		meta.Verification = &verification
+	}
+	if err := writeBackupMetadata(backupPath, meta); err != nil {
+		return "", err
+	}
+	if meta.Verification != nil && !meta.Verification.OK {
+		return "", fmt.Errorf("backup verification failed: %s", meta.Verification.Error)
 	}
 
 	return backupPath, nil
 }
 
+// VerifyBackup verifies an existing backup and records the result in its
+// metadata. Row counts can only be compared for backups that have metadata
+func (bs *BackupService) VerifyBackup(backupPath string, progress ProgressFunc) (*BackupVerification, error) {
+	if _, err := os.Stat(backupPath); err != nil {
+		return nil, fmt.Errorf("backup file does not exist: %s", backupPath)
+	}
+
+	meta, err := ReadBackupMetadata(backupPath)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		meta = &BackupMetadata{Name: filepath.Base(backupPath), Codec: compression.FromName(backupPath)}
+	}
+
+	verification := bs.verifyBackup(backupPath, meta.RowCounts, progress)
+	meta.Verification = &verification
+	if err := writeBackupMetadata(backupPath, *meta); err != nil {
+		return &verification, err
+	}
+	return &verification, nil
+}
+
 // RestoreFromBackup restores database from a backup file
 func (bs *BackupService) RestoreFromBackup(opts RestoreOptions) error {
 	// Verify backup file exists
@@ -112,12 +193,35 @@ func (bs *BackupService) RestoreFromBackup(opts RestoreOptions) error {
 		return fmt.Errorf("backup file does not exist: %s", opts.BackupFile)
 	}
 
+	// Check the backup against its catalog entry before touching the database
+	meta, err := ReadBackupMetadata(opts.BackupFile)
+	if err != nil {
+		return err
+	}
+	if meta == nil {
+		fmt.Printf("Warning: %s has no catalog entry, restoring without integrity checks\n", opts.BackupFile)
+	} else if err := checkRestoreCompatibility(opts.BackupFile, meta); err != nil {
+		return fmt.Errorf("backup cannot be restored: %w", err)
+	}
+	if err := CheckSchemaCompatibility(meta); err != nil {
+		if !opts.ForceMigrate {
+			return fmt.Errorf("backup cannot be restored: %w (force the migration to restore it anyway)", err)
+		}
+		fmt.Printf("Warning: %v, restoring anyway as forced\n", err)
+	}
+
 	// Create backup of current database if requested
 	if opts.CreateBackup {
+		codec := bs.codec
+		if codec == compression.None {
+			codec = compression.Gzip
+		}
 		backupOpts := BackupOptions{
 			BackupDir:    bs.backupDir,
-			Compress:     true,
+			Codec:        codec,
+			Level:        bs.level,
 			VerifyBackup: false,
+			Progress:     opts.Progress,
 		}
 		currentBackup, err := bs.CreateBackup(backupOpts)
 		if err != nil {
@@ -133,23 +237,30 @@ func (bs *BackupService) RestoreFromBackup(opts RestoreOptions) error {
 	}
 	defer backupFile.Close()
 
-	var reader io.Reader = backupFile
-
-	// Handle compressed backups
-	if strings.HasSuffix(opts.BackupFile, ".gz") {
-		gzipReader, err := gzip.NewReader(backupFile)
-		if err != nil {
-			return fmt.Errorf("failed to create gzip reader: %w", err)
-		}
-		defer gzipReader.Close()
-		reader = gzipReader
+	// Compressed backups are detected by content, whatever their extension
+	reader, codec, err := compression.NewReader(backupFile)
+	if err != nil {
+		return fmt.Errorf("failed to create %s reader: %w", codec, err)
 	}
+	defer reader.Close()
 
 	// Restore the database
-	if err := bs.restoreDatabase(reader); err != nil {
+	if err := bs.restoreDatabase(reader, newProgressReporter(opts.Progress, BackupPhaseRestore)); err != nil {
 		return fmt.Errorf("failed to restore database: %w", err)
 	}
 
+	// Bring a backup of an older schema up to date
+	restoredVersion, err := bs.db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if err := bs.db.migrate(); err != nil {
+		return fmt.Errorf("failed to migrate restored database: %w", err)
+	}
+	if restoredVersion < LatestSchemaVersion() {
+		fmt.Printf("Migrated restored database from schema version %d to %d\n", restoredVersion, LatestSchemaVersion())
+	}
+
 	// Verify restore if requested
 	if opts.VerifyRestore {
 		if err := bs.verifyDatabaseIntegrity(); err != nil {
@@ -175,9 +286,9 @@ func (bs *BackupService) CleanupOldBackups() error {
 			continue
 		}
 		
-		// Check if it's a backup file
+		// Check if it's a backup file; metadata goes with its backup
 		name := entry.Name()
-		if !strings.HasPrefix(name, "bot_backup_") {
+		if !strings.HasPrefix(name, "bot_backup_") || strings.HasSuffix(name, metadataSuffix) {
 			continue
 		}
 		
@@ -191,6 +302,7 @@ func (bs *BackupService) CleanupOldBackups() error {
 			if err := os.Remove(backupPath); err != nil {
 				fmt.Printf("Warning: failed to remove old backup %s: %v\n", backupPath, err)
 			} else {
+				os.Remove(metadataPath(backupPath))
 				removed++
 			}
 		}
@@ -217,7 +329,7 @@ func (bs *BackupService) ListBackups() ([]BackupInfo, error) {
 		}
 		
 		name := entry.Name()
-		if !strings.HasPrefix(name, "bot_backup_") {
+		if !strings.HasPrefix(name, "bot_backup_") || strings.HasSuffix(name, metadataSuffix) {
 			continue
 		}
 		
@@ -231,7 +343,11 @@ func (bs *BackupService) ListBackups() ([]BackupInfo, error) {
 			Path:       filepath.Join(bs.backupDir, name),
 			Size:       info.Size(),
 			Created:    info.ModTime(),
-			Compressed: strings.HasSuffix(name, ".gz"),
+			Compressed: compression.FromName(name) != compression.None,
+			Codec:      compression.FromName(name),
+		}
+		if meta, err := ReadBackupMetadata(backup.Path); err == nil && meta != nil {
+			backup.Metadata = meta
 		}
 		
 		backups = append(backups, backup)
@@ -247,41 +363,215 @@ type BackupInfo struct {
 	Size       int64
 	Created    time.Time
 	Compressed bool
+	Codec      compression.Codec
+	// Metadata is the backup's catalog entry, nil for backups taken before
+	// the catalog existed
+	Metadata *BackupMetadata
+}
+
+// metadataSuffix names the catalog entry kept next to each backup
+const metadataSuffix = ".meta.json"
+
+// BackupMetadata is a backup's entry in the catalog. It is stored next to the
+// backup, so it moves and expires with it, and records what the live
+// database held when it was dumped
+type BackupMetadata struct {
+	Name          string              `json:"name"`
+	Created       time.Time           `json:"created"`
+	Host          string              `json:"host"`
+	SchemaVersion int                 `json:"schema_version"` // Latest migration applied to the dumped database
+	AppVersion    string              `json:"app_version"`    // Bot build that took the backup
+	Duration      float64             `json:"duration_seconds"`
+	Size          int64               `json:"size"`
+	SHA256        string              `json:"sha256"` // Of the backup file as written
+	Codec         compression.Codec   `json:"codec"`
+	Level         int                 `json:"level"`
+	Encrypted     bool                `json:"encrypted"` // Backups are never encrypted yet
+	RowCounts     map[string]int64    `json:"row_counts"`
+	Verification  *BackupVerification `json:"verification,omitempty"`
+}
+
+// TotalRows returns the rows the database held at dump time
+func (meta *BackupMetadata) TotalRows() int64 {
+	var total int64
+	for _, count := range meta.RowCounts {
+		total += count
+	}
+	return total
+}
+
+// BackupVerification is the result of restoring a backup into a scratch
+// database and checking it
+type BackupVerification struct {
+	VerifiedAt time.Time `json:"verified_at"`
+	OK         bool      `json:"ok"`
+	Integrity  string    `json:"integrity,omitempty"`  // PRAGMA integrity_check result
+	Tables     int       `json:"tables"`               // Tables in the restored database
+	Rows       int64     `json:"rows"`                 // Rows in the restored database
+	Mismatches []string  `json:"mismatches,omitempty"` // Tables whose row count differs from dump time
+	Error      string    `json:"error,omitempty"`
+}
+
+// metadataPath returns the metadata file of a backup
+func metadataPath(backupPath string) string {
+	return backupPath + metadataSuffix
+}
+
+// ReadBackupMetadata reads the metadata of a backup; it returns nil without
+// an error for backups taken before metadata was recorded
+func ReadBackupMetadata(backupPath string) (*BackupMetadata, error) {
+	data, err := os.ReadFile(metadataPath(backupPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup metadata: %w", err)
+	}
+
+	var meta BackupMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse backup metadata: %w", err)
+	}
+	return &meta, nil
+}
+
+// checkRestoreCompatibility refuses backups this tool cannot restore, or
+// whose file no longer matches its catalog entry
+func checkRestoreCompatibility(backupPath string, meta *BackupMetadata) error {
+	if meta.Encrypted {
+		return fmt.Errorf("backup is encrypted, which this version cannot restore")
+	}
+	if meta.SHA256 == "" {
+		return nil
+	}
+	sum, err := fileSHA256(backupPath)
+	if err != nil {
+		return fmt.Errorf("failed to hash backup file: %w", err)
+	}
+	if sum != meta.SHA256 {
+		return fmt.Errorf("backup file hash %s does not match the catalog (%s), the file was modified or is corrupt", sum, meta.SHA256)
+	}
+	return nil
+}
+
+// CheckSchemaCompatibility reports whether this binary can restore a backup.
+// Backups of the same or an older schema version are migrated after the
+// restore; backups of a newer version hold tables and columns this binary
+// does not know, and backups without a recorded version cannot be checked
+func CheckSchemaCompatibility(meta *BackupMetadata) error {
+	if meta == nil || meta.SchemaVersion == 0 {
+		return fmt.Errorf("backup has no recorded schema version")
+	}
+	if meta.SchemaVersion > LatestSchemaVersion() {
+		appVersion := meta.AppVersion
+		if appVersion == "" {
+			appVersion = "unknown"
+		}
+		return fmt.Errorf("backup schema version %d (bot %s) is newer than this binary's %d (bot %s)",
+			meta.SchemaVersion, appVersion, LatestSchemaVersion(), utils.BotVersion())
+	}
+	return nil
+}
+
+// fileSHA256 returns the hex SHA-256 of a file
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// writeBackupMetadata stores the metadata next to a backup
+func writeBackupMetadata(backupPath string, meta BackupMetadata) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backup metadata: %w", err)
+	}
+	if err := os.WriteFile(metadataPath(backupPath), data, 0644); err != nil {
+		return fmt.Errorf("failed to write backup metadata: %w", err)
+	}
+	return nil
 }
 
-// dumpDatabase performs the actual database dump
-func (bs *BackupService) dumpDatabase(writer io.Writer) error {
+// dumpDatabase performs the actual database dump and returns the row count of
+// each table and the schema version. Everything is read in one transaction,
+// so the counts match the dumped rows even while the bot keeps writing
+func (bs *BackupService) dumpDatabase(writer io.Writer, reporter *progressReporter) (map[string]int64, int, error) {
+	tx, err := bs.db.DB().Begin()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin read transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var schemaVersion sql.NullInt64
+	if err := tx.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&schemaVersion); err != nil {
+		return nil, 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
 	// Write backup header
 	fmt.Fprintf(writer, "-- Telegram Archive Bot Database Backup\n")
 	fmt.Fprintf(writer, "-- Created: %s\n", time.Now().Format(time.RFC3339))
-	fmt.Fprintf(writer, "-- Database: %s\n\n", "bot.db")
+	fmt.Fprintf(writer, "-- Database: %s\n", "bot.db")
+	fmt.Fprintf(writer, "-- Schema version: %d\n", schemaVersion.Int64)
+	fmt.Fprintf(writer, "-- Bot version: %s\n\n", utils.BotVersion())
 	
 	// Begin transaction for consistent backup
 	fmt.Fprintf(writer, "BEGIN TRANSACTION;\n\n")
 	
 	// Get all table names
-	tables, err := bs.getTables()
+	tables, err := getTables(tx)
 	if err != nil {
-		return fmt.Errorf("failed to get table list: %w", err)
+		return nil, 0, fmt.Errorf("failed to get table list: %w", err)
+	}
+	
+	// Count rows first so progress can be reported as a percentage
+	reporter.progress.TablesTotal = len(tables)
+	for _, table := range tables {
+		var count int64
+		if err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&count); err != nil {
+			return nil, 0, fmt.Errorf("failed to count rows of %s: %w", table, err)
+		}
+		reporter.progress.RowsTotal += count
 	}
+	reporter.report(true)
 	
 	// Dump each table
+	rowCounts := make(map[string]int64, len(tables))
 	for _, table := range tables {
-		if err := bs.dumpTable(writer, table); err != nil {
-			return fmt.Errorf("failed to dump table %s: %w", table, err)
+		count, err := bs.dumpTable(tx, writer, table, reporter)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to dump table %s: %w", table, err)
 		}
+		rowCounts[table] = count
+		reporter.progress.TablesDone++
+		reporter.report(false)
+	}
+	
+	// Dropping the tables on restore drops their indexes, and the migrations
+	// that created them will not run again
+	if err := dumpIndexes(tx, writer); err != nil {
+		return nil, 0, fmt.Errorf("failed to dump indexes: %w", err)
 	}
 	
 	// Commit transaction
 	fmt.Fprintf(writer, "COMMIT;\n")
 	
-	return nil
+	return rowCounts, int(schemaVersion.Int64), nil
 }
 
 // getTables returns list of all tables in the database
-func (bs *BackupService) getTables() ([]string, error) {
+func getTables(q interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}) ([]string, error) {
 	query := `SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name`
-	rows, err := bs.db.DB().Query(query)
+	rows, err := q.Query(query)
 	if err != nil {
 		return nil, err
 	}
@@ -299,13 +589,33 @@ func (bs *BackupService) getTables() ([]string, error) {
 	return tables, rows.Err()
 }
 
-// dumpTable dumps a single table's schema and data
-func (bs *BackupService) dumpTable(writer io.Writer, tableName string) error {
+// dumpIndexes writes the statements recreating every index
+func dumpIndexes(tx *sql.Tx, writer io.Writer) error {
+	rows, err := tx.Query(`SELECT sql FROM sqlite_master WHERE type='index' AND sql IS NOT NULL ORDER BY name`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	
+	fmt.Fprintf(writer, "-- Indexes\n")
+	for rows.Next() {
+		var stmt string
+		if err := rows.Scan(&stmt); err != nil {
+			return err
+		}
+		fmt.Fprintf(writer, "%s;\n", stmt)
+	}
+	fmt.Fprintf(writer, "\n")
+	return rows.Err()
+}
+
+// dumpTable dumps a single table's schema and data and returns the rows dumped
+func (bs *BackupService) dumpTable(tx *sql.Tx, writer io.Writer, tableName string, reporter *progressReporter) (int64, error) {
 	// Dump table schema
 	schemaQuery := fmt.Sprintf("SELECT sql FROM sqlite_master WHERE type='table' AND name='%s'", tableName)
 	var schema string
-	if err := bs.db.DB().QueryRow(schemaQuery).Scan(&schema); err != nil {
-		return err
+	if err := tx.QueryRow(schemaQuery).Scan(&schema); err != nil {
+		return 0, err
 	}
 	
 	fmt.Fprintf(writer, "-- Table: %s\n", tableName)
@@ -314,16 +624,16 @@ func (bs *BackupService) dumpTable(writer io.Writer, tableName string) error {
 	
 	// Dump table data
 	dataQuery := fmt.Sprintf("SELECT * FROM %s", tableName)
-	rows, err := bs.db.DB().Query(dataQuery)
+	rows, err := tx.Query(dataQuery)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer rows.Close()
 	
 	// Get column information
 	columns, err := rows.Columns()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	
 	// Create placeholders for row data
@@ -334,10 +644,14 @@ func (bs *BackupService) dumpTable(writer io.Writer, tableName string) error {
 	}
 	
 	// Dump each row
+	var count int64
 	for rows.Next() {
 		if err := rows.Scan(valuePtrs...); err != nil {
-			return err
+			return count, err
 		}
+		count++
+		reporter.progress.RowsDone++
+		reporter.report(false)
 		
 		// Build INSERT statement
 		fmt.Fprintf(writer, "INSERT INTO %s VALUES (", tableName)
@@ -367,11 +681,19 @@ func (bs *BackupService) dumpTable(writer io.Writer, tableName string) error {
 	}
 	
 	fmt.Fprintf(writer, "\n")
-	return rows.Err()
+	return count, rows.Err()
 }
 
 // restoreDatabase restores database from SQL dump
-func (bs *BackupService) restoreDatabase(reader io.Reader) error {
+func (bs *BackupService) restoreDatabase(reader io.Reader, reporter *progressReporter) error {
+	return restoreDump(bs.db.DB(), reader, reporter)
+}
+
+// restoreDump executes the statements of an SQL dump against db in a single
+// transaction, so a failed restore leaves the database as it was. Each table
+// is dropped and recreated with the backup's schema before its rows are
+// inserted, which lets migrations bring an older schema up to date afterwards
+func restoreDump(db *sql.DB, reader io.Reader, reporter *progressReporter) error {
 	// Read the entire SQL dump
 	sqlBytes, err := io.ReadAll(reader)
 	if err != nil {
@@ -383,20 +705,48 @@ func (bs *BackupService) restoreDatabase(reader io.Reader) error {
 	// Split into individual statements
 	statements := strings.Split(sqlContent, ";\n")
 	
+	reporter.progress.BytesTotal = int64(len(sqlContent))
+	for _, stmt := range statements {
+		if strings.HasPrefix(strings.TrimSpace(stmt), "CREATE TABLE") {
+			reporter.progress.TablesTotal++
+		}
+	}
+	reporter.report(true)
+	
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin restore transaction: %w", err)
+	}
+	defer tx.Rollback()
+	
 	// Execute each statement
+	tablesStarted := 0
 	for _, stmt := range statements {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" || strings.HasPrefix(stmt, "--") {
+		reporter.progress.Bytes = min(reporter.progress.Bytes+int64(len(stmt))+2, reporter.progress.BytesTotal)
+		reporter.report(false)
+		stmt = stripLeadingComments(stmt)
+		if stmt == "" {
+			continue
+		}
+		
+		// The dump's own transaction markers; the restore has its own
+		if stmt == "BEGIN TRANSACTION" || stmt == "COMMIT" {
 			continue
 		}
 		
+		// A table is done once the next one starts
+		if strings.HasPrefix(stmt, "CREATE TABLE") {
+			reporter.progress.TablesDone = tablesStarted
+			tablesStarted++
+		}
+		
 		// Skip statements that might cause conflicts during restore
 		if strings.Contains(stmt, "CREATE TABLE IF NOT EXISTS") {
 			continue // Skip IF NOT EXISTS statements as they may conflict
 		}
 		
 		// Execute the statement, ignoring some expected errors
-		if _, err := bs.db.DB().Exec(stmt); err != nil {
+		if _, err := tx.Exec(stmt); err != nil {
 			// Ignore some common restore errors
 			errStr := err.Error()
 			if strings.Contains(errStr, "already exists") ||
@@ -412,34 +762,174 @@ func (bs *BackupService) restoreDatabase(reader io.Reader) error {
 		}
 	}
 	
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit restore: %w", err)
+	}
+	reporter.finish()
 	return nil
 }
 
-// verifyBackup verifies the integrity of a backup file
-func (bs *BackupService) verifyBackup(backupPath string, compressed bool) error {
+// stripLeadingComments removes the comment lines in front of a statement,
+// such as the "-- Table:" line before each table's DROP TABLE
+func stripLeadingComments(stmt string) string {
+	stmt = strings.TrimSpace(stmt)
+	for strings.HasPrefix(stmt, "--") {
+		newline := strings.IndexByte(stmt, '\n')
+		if newline < 0 {
+			return ""
+		}
+		stmt = strings.TrimSpace(stmt[newline+1:])
+	}
+	return stmt
+}
+
+// verifyBackup restores a backup into a scratch SQLite database, runs an
+// integrity check on it and compares its row counts with the counts recorded
+// at dump time. A nil rowCounts skips the comparison
+func (bs *BackupService) verifyBackup(backupPath string, rowCounts map[string]int64, progress ProgressFunc) BackupVerification {
+	verification := BackupVerification{VerifiedAt: time.Now()}
+	reporter := newProgressReporter(progress, BackupPhaseVerify)
+	if err := bs.checkBackup(backupPath, rowCounts, &verification, reporter); err != nil {
+		verification.Error = err.Error()
+		return verification
+	}
+	verification.OK = true
+	return verification
+}
+
+// checkBackup does the work of verifyBackup, filling in what it finds
+func (bs *BackupService) checkBackup(backupPath string, rowCounts map[string]int64, verification *BackupVerification, reporter *progressReporter) error {
 	file, err := os.Open(backupPath)
 	if err != nil {
 		return fmt.Errorf("failed to open backup file for verification: %w", err)
 	}
 	defer file.Close()
 	
-	var reader io.Reader = file
-	
-	if compressed {
-		gzipReader, err := gzip.NewReader(file)
-		if err != nil {
-			return fmt.Errorf("failed to create gzip reader for verification: %w", err)
+	reader, codec, err := compression.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to create %s reader for verification: %w", codec, err)
+	}
+	defer reader.Close()
+
+	// The scratch database lives next to the backups, where there is room for them
+	scratchDir, err := os.MkdirTemp(bs.backupDir, ".verify-")
+	if err != nil {
+		return fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratchDir)
+
+	scratch, err := sql.Open("sqlite3", filepath.Join(scratchDir, "verify.db"))
+	if err != nil {
+		return fmt.Errorf("failed to open scratch database: %w", err)
+	}
+	defer scratch.Close()
+	// One connection, so the dump's BEGIN and COMMIT wrap all of its statements
+	scratch.SetMaxOpenConns(1)
+
+	if err := restoreDump(scratch, reader, reporter); err != nil {
+		return fmt.Errorf("failed to restore backup into scratch database: %w", err)
+	}
+
+	if err := scratch.QueryRow("PRAGMA integrity_check").Scan(&verification.Integrity); err != nil {
+		return fmt.Errorf("failed to run integrity check: %w", err)
+	}
+	if verification.Integrity != "ok" {
+		return fmt.Errorf("integrity check failed: %s", verification.Integrity)
+	}
+
+	tables, err := getTables(scratch)
+	if err != nil {
+		return fmt.Errorf("failed to list restored tables: %w", err)
+	}
+	restored := make(map[string]int64, len(tables))
+	for _, table := range tables {
+		var count int64
+		if err := scratch.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&count); err != nil {
+			return fmt.Errorf("failed to count rows of %s: %w", table, err)
 		}
-		defer gzipReader.Close()
-		reader = gzipReader
+		restored[table] = count
+		verification.Rows += count
 	}
-	
-	// Read the backup file to ensure it's not corrupted
-	_, err = io.ReadAll(reader)
+	verification.Tables = len(tables)
+
+	if rowCounts == nil {
+		return nil
+	}
+	for table, expected := range rowCounts {
+		if got, ok := restored[table]; !ok {
+			verification.Mismatches = append(verification.Mismatches, fmt.Sprintf("%s: missing, %d rows at dump time", table, expected))
+		} else if got != expected {
+			verification.Mismatches = append(verification.Mismatches, fmt.Sprintf("%s: %d rows, %d at dump time", table, got, expected))
+		}
+	}
+	for table, got := range restored {
+		if _, ok := rowCounts[table]; !ok {
+			verification.Mismatches = append(verification.Mismatches, fmt.Sprintf("%s: %d rows, not in the live database", table, got))
+		}
+	}
+	if len(verification.Mismatches) > 0 {
+		sort.Strings(verification.Mismatches)
+		return fmt.Errorf("row counts differ from dump time in %d table(s): %s",
+			len(verification.Mismatches), strings.Join(verification.Mismatches, "; "))
+	}
+	return nil
+}
+
+// VerifyRestoredDatabase checks the live database after a restore from a
+// backup with metadata: an integrity check, and the row counts of the
+// backup's tables against the counts recorded at dump time. The bot keeps
+// writing audit entries while it runs and migrations may add rows, so only
+// tables holding fewer rows than the backup count as mismatches
+func (bs *BackupService) VerifyRestoredDatabase(meta *BackupMetadata) BackupVerification {
+	verification := BackupVerification{VerifiedAt: time.Now()}
+	if err := bs.checkRestoredDatabase(meta, &verification); err != nil {
+		verification.Error = err.Error()
+		return verification
+	}
+	verification.OK = true
+	return verification
+}
+
+// checkRestoredDatabase does the work of VerifyRestoredDatabase, filling in what it finds
+func (bs *BackupService) checkRestoredDatabase(meta *BackupMetadata, verification *BackupVerification) error {
+	db := bs.db.DB()
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&verification.Integrity); err != nil {
+		return fmt.Errorf("failed to run integrity check: %w", err)
+	}
+	if verification.Integrity != "ok" {
+		return fmt.Errorf("integrity check failed: %s", verification.Integrity)
+	}
+
+	tables, err := getTables(db)
 	if err != nil {
-		return fmt.Errorf("backup file appears to be corrupted: %w", err)
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+	live := make(map[string]int64, len(tables))
+	for _, table := range tables {
+		var count int64
+		if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&count); err != nil {
+			return fmt.Errorf("failed to count rows of %s: %w", table, err)
+		}
+		live[table] = count
+		verification.Rows += count
+	}
+	verification.Tables = len(tables)
+
+	if meta == nil {
+		return nil
+	}
+	for table, expected := range meta.RowCounts {
+		if got, ok := live[table]; !ok {
+			verification.Mismatches = append(verification.Mismatches, fmt.Sprintf("%s: missing, %d rows in the backup", table, expected))
+		} else if got < expected {
+			verification.Mismatches = append(verification.Mismatches, fmt.Sprintf("%s: %d rows, %d in the backup", table, got, expected))
+		}
+	}
+	if len(verification.Mismatches) > 0 {
+		sort.Strings(verification.Mismatches)
+		return fmt.Errorf("rows missing after the restore in %d table(s): %s",
+			len(verification.Mismatches), strings.Join(verification.Mismatches, "; "))
 	}
-	
 	return nil
 }
 
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"strings"
+	"time"
+)
+
+// Phases of a backup or restore reported through ProgressFunc
+const (
+	BackupPhaseDump    = "backup"
+	BackupPhaseVerify  = "verify"
+	BackupPhaseRestore = "restore"
+)
+
+// backupProgressInterval limits how often ProgressFunc is called, so a
+// multi-GB dump does not spend its time reporting
+const backupProgressInterval = 500 * time.Millisecond
+
+// BackupProgress reports how far a backup, verification or restore has got
+type BackupProgress struct {
+	Phase       string
+	TablesDone  int
+	TablesTotal int
+	RowsDone    int64 // Set while dumping, when the row total is known
+	RowsTotal   int64
+	Bytes       int64 // Backup file bytes written while dumping, SQL bytes executed otherwise
+	BytesTotal  int64 // 0 while dumping, the size is only known at the end
+	Done        bool
+}
+
+// Percent returns how much of the phase is done, 0-100. Dumps are measured
+// in rows, restores in SQL bytes, since tables differ wildly in size
+func (p BackupProgress) Percent() float64 {
+	switch {
+	case p.Done:
+		return 100
+	case p.RowsTotal > 0:
+		return float64(p.RowsDone) * 100 / float64(p.RowsTotal)
+	case p.BytesTotal > 0:
+		return float64(p.Bytes) * 100 / float64(p.BytesTotal)
+	case p.TablesTotal > 0:
+		return float64(p.TablesDone) * 100 / float64(p.TablesTotal)
+	}
+	return 0
+}
+
+// Bar renders the percentage as a text progress bar of width characters
+func (p BackupProgress) Bar(width int) string {
+	filled := int(p.Percent() * float64(width) / 100)
+	if filled > width {
+		filled = width
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
+}
+
+// ProgressFunc receives progress updates. It is called from the goroutine
+// doing the work, so it should return quickly
+type ProgressFunc func(BackupProgress)
+
+// progressReporter rate-limits the calls to a ProgressFunc; a nil function
+// turns reporting off
+type progressReporter struct {
+	fn       ProgressFunc
+	progress BackupProgress
+	last     time.Time
+}
+
+func newProgressReporter(fn ProgressFunc, phase string) *progressReporter {
+	return &progressReporter{fn: fn, progress: BackupProgress{Phase: phase}}
+}
+
+// report passes the current progress on, at most once per interval unless forced
+func (r *progressReporter) report(force bool) {
+	if r.fn == nil {
+		return
+	}
+	if !force && time.Since(r.last) < backupProgressInterval {
+		return
+	}
+	r.last = time.Now()
+	r.fn(r.progress)
+}
+
+// finish reports the phase as complete
+func (r *progressReporter) finish() {
+	r.progress.Done = true
+	r.progress.TablesDone = r.progress.TablesTotal
+	r.report(true)
+}
+
+// Write counts the backup file bytes written, for use in an io.MultiWriter
+func (r *progressReporter) Write(p []byte) (int, error) {
+	r.progress.Bytes += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ConversionResult is the stored result manifest of one conversion pass
+type ConversionResult struct {
+	ID             int64
+	StartedAt      time.Time
+	FinishedAt     time.Time
+	FilesProcessed int
+	FilesProduced  int
+	Credentials    int
+	Domains        int
+	Warnings       int
+	Manifest       string // Full manifest JSON as emitted by the convert stage
+	RecordedAt     time.Time
+	// ParserVersion is the converter's convert.ParserVersion, 0 for passes
+	// recorded before versions were tracked
+	ParserVersion int
+	// InputDir holds a copy of the pass's input kept for reprocessing, ""
+	// when none was kept or it was handed on to a later pass
+	InputDir string
+
+	// DomainCredentials counts credentials per domain; stored in
+	// conversion_domains for analytics rather than in the manifest
+	DomainCredentials map[string]int
+}
+
+// SaveConversionResult stores a conversion result and links it to the tasks
+// whose files went through that pass
+func (ts *TaskStore) SaveConversionResult(result *ConversionResult, taskIDs []string) error {
+	tx, err := ts.db.DB().Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO conversion_results (started_at, finished_at, files_processed, files_produced,
+			credentials, domains, warnings, manifest, recorded_at, parser_version, input_dir)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		result.StartedAt, result.FinishedAt, result.FilesProcessed, result.FilesProduced,
+		result.Credentials, result.Domains, result.Warnings, result.Manifest, result.RecordedAt,
+		result.ParserVersion, result.InputDir)
+	if err != nil {
+		return fmt.Errorf("failed to save conversion result: %w", err)
+	}
+
+	result.ID, err = res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get conversion result id: %w", err)
+	}
+
+	for domain, credentials := range result.DomainCredentials {
+		if _, err := tx.Exec(`INSERT INTO conversion_domains (result_id, domain, credentials) VALUES (?, ?, ?)`,
+			result.ID, domain, credentials); err != nil {
+			return fmt.Errorf("failed to save domain %s of conversion result: %w", domain, err)
+		}
+	}
+
+	for _, taskID := range taskIDs {
+		_, err := tx.Exec(`
+			INSERT INTO task_conversion_results (task_id, result_id) VALUES (?, ?)
+			ON CONFLICT(task_id) DO UPDATE SET result_id = excluded.result_id`,
+			taskID, result.ID)
+		if err != nil {
+			return fmt.Errorf("failed to link conversion result to task %s: %w", taskID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit conversion result: %w", err)
+	}
+	return nil
+}
+
+// GetTaskConversionResult returns the conversion result of a task, or nil if
+// its files were not converted yet
+func (ts *TaskStore) GetTaskConversionResult(taskID string) (*ConversionResult, error) {
+	result := &ConversionResult{}
+	err := ts.db.DB().QueryRow(`
+		SELECT r.id, r.started_at, r.finished_at, r.files_processed, r.files_produced,
+			r.credentials, r.domains, r.warnings, r.manifest, r.recorded_at,
+			COALESCE(r.parser_version, 0), COALESCE(r.input_dir, '')
+		FROM task_conversion_results t
+		JOIN conversion_results r ON r.id = t.result_id
+		WHERE t.task_id = ?`, taskID).
+		Scan(&result.ID, &result.StartedAt, &result.FinishedAt, &result.FilesProcessed, &result.FilesProduced,
+			&result.Credentials, &result.Domains, &result.Warnings, &result.Manifest, &result.RecordedAt,
+			&result.ParserVersion, &result.InputDir)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task conversion result: %w", err)
+	}
+	return result, nil
+}
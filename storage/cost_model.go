@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Cost model fitting
+const (
+	costModelSamples    = 200 // Most recent timings each stage is fitted on
+	costModelMinSamples = 5   // With fewer, a stage falls back to its mean duration
+)
+
+// File types as detected on submission
+const (
+	FileTypeArchive = "archive"
+	FileTypeText    = "txt"
+)
+
+// processingStages are the stages a file of each type passes through after
+// its download; text files take the fast path instead of extraction
+var processingStages = map[string][]string{
+	FileTypeArchive: {StageExtraction, StageOCR, StageConversion, StageStore},
+	FileTypeText:    {StageFastPath, StageStore},
+}
+
+// defaultInputRatios are used for a stage's input size until both it and
+// extraction have recorded their input sizes
+var defaultInputRatios = map[string]float64{
+	StageExtraction: 1,
+	StageOCR:        0,
+	StageConversion: 1,
+	StageStore:      1,
+	StageFastPath:   1, // Text files are their own input
+}
+
+// StageCost predicts how long a stage takes from the bytes it is given
+type StageCost struct {
+	Samples int           `json:"samples"`
+	Fitted  bool          `json:"fitted"` // False while the mean or default duration stands in
+	Fixed   time.Duration `json:"fixed"`
+	PerMB   time.Duration `json:"per_mb"`
+}
+
+// Predict returns the stage's duration for bytes of input
+func (c StageCost) Predict(bytes int64) time.Duration {
+	return c.Fixed + time.Duration(float64(c.PerMB)*float64(bytes)/(1<<20))
+}
+
+// CostModel predicts the processing time and disk use of a file from its
+// type and size, fitted on the recorded stage timings
+type CostModel struct {
+	FittedAt time.Time `json:"fitted_at"`
+	// Stages holds every stage, and downloads per file type as
+	// "download/<type>" once a type has enough samples of its own
+	Stages map[string]StageCost `json:"stages"`
+	// InputRatios relate each processing stage's input size to the size of
+	// the archives extracted, e.g. how many bytes of text a byte of archive
+	// turns into for conversion
+	InputRatios map[string]float64 `json:"input_ratios"`
+}
+
+// TaskCost is the predicted cost of one file
+type TaskCost struct {
+	Download   time.Duration
+	Processing time.Duration
+	// DiskBytes is the most disk the file takes at once: the download plus
+	// the text extracted from it
+	DiskBytes int64
+	Fitted    bool // Every stage the file passes through was fitted on history
+}
+
+// Total is the download and processing time together
+func (c TaskCost) Total() time.Duration {
+	return c.Download + c.Processing
+}
+
+// Estimate predicts the cost of a file of the given type and size
+func (m *CostModel) Estimate(fileType string, size int64) TaskCost {
+	download := m.downloadCost(fileType)
+	cost := TaskCost{
+		Download:  download.Predict(size),
+		DiskBytes: size,
+		Fitted:    download.Fitted,
+	}
+	for _, stage := range m.stagesOf(fileType) {
+		stageCost := m.Stages[stage]
+		cost.Processing += stageCost.Predict(int64(float64(size) * m.InputRatios[stage]))
+		// A stage that never ran and gets no input, e.g. OCR without images, is not missing history
+		if stageCost.Samples > 0 || m.InputRatios[stage] > 0 {
+			cost.Fitted = cost.Fitted && stageCost.Fitted
+		}
+	}
+	if fileType == FileTypeArchive {
+		cost.DiskBytes += int64(float64(size) * m.InputRatios[StageConversion])
+	}
+	return cost
+}
+
+// ExtractedBytes predicts how much text an archive of size bytes extracts to
+func (m *CostModel) ExtractedBytes(fileType string, size int64) int64 {
+	if fileType != FileTypeArchive {
+		return 0
+	}
+	return int64(float64(size) * m.InputRatios[StageConversion])
+}
+
+// downloadCost is the download cost of a file type, or of all downloads
+// while the type has too few samples
+func (m *CostModel) downloadCost(fileType string) StageCost {
+	if cost, ok := m.Stages[StageDownload+"/"+fileType]; ok && cost.Fitted {
+		return cost
+	}
+	return m.Stages[StageDownload]
+}
+
+// stagesOf returns the processing stages of a file type; unknown types are
+// routed to extraction like archives
+func (m *CostModel) stagesOf(fileType string) []string {
+	if stages, ok := processingStages[fileType]; ok {
+		return stages
+	}
+	return processingStages[FileTypeArchive]
+}
+
+// FitCostModel fits each stage's duration to its input size over the most
+// recent timings. A stage with too few timings that recorded their size
+// falls back to its mean duration, or the default until it has any
+func (ts *TaskStore) FitCostModel() (*CostModel, error) {
+	model := &CostModel{
+		FittedAt:    time.Now(),
+		Stages:      make(map[string]StageCost),
+		InputRatios: make(map[string]float64),
+	}
+
+	for _, stage := range []string{StageDownload, StageFastPath, StageExtraction, StageOCR, StageConversion, StageStore} {
+		cost, err := ts.fitStageCost(stage, "")
+		if err != nil {
+			return nil, err
+		}
+		model.Stages[stage] = cost
+	}
+	for _, fileType := range []string{FileTypeArchive, FileTypeText} {
+		cost, err := ts.fitStageCost(StageDownload, fileType)
+		if err != nil {
+			return nil, err
+		}
+		if cost.Fitted {
+			model.Stages[StageDownload+"/"+fileType] = cost
+		}
+	}
+
+	for stage, ratio := range defaultInputRatios {
+		model.InputRatios[stage] = ratio
+	}
+	if err := ts.fitInputRatios(model.InputRatios); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// fitStageCost fits a stage by least squares, restricted to downloads of
+// fileType when it is set
+func (ts *TaskStore) fitStageCost(stage, fileType string) (StageCost, error) {
+	query := `SELECT duration_ms, bytes FROM stage_timings WHERE stage = ? AND bytes > 0 ORDER BY recorded_at DESC LIMIT ?`
+	args := []interface{}{stage, costModelSamples}
+	if fileType != "" {
+		query = `SELECT s.duration_ms, s.bytes FROM stage_timings s JOIN tasks t ON t.id = s.task_id
+			WHERE s.stage = ? AND s.bytes > 0 AND t.file_type = ? ORDER BY s.recorded_at DESC LIMIT ?`
+		args = []interface{}{stage, fileType, costModelSamples}
+	}
+
+	rows, err := ts.db.DB().Query(query, args...)
+	if err != nil {
+		return StageCost{}, fmt.Errorf("failed to query %s timings: %w", stage, err)
+	}
+	defer rows.Close()
+
+	var xs, ys []float64
+	for rows.Next() {
+		var ms, bytes int64
+		if err := rows.Scan(&ms, &bytes); err != nil {
+			return StageCost{}, fmt.Errorf("failed to scan %s timing: %w", stage, err)
+		}
+		xs = append(xs, float64(bytes)/(1<<20))
+		ys = append(ys, float64(ms))
+	}
+	if err := rows.Err(); err != nil {
+		return StageCost{}, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	if len(xs) >= costModelMinSamples {
+		fixed, perMB := fitLinear(xs, ys)
+		return StageCost{
+			Samples: len(xs),
+			Fitted:  true,
+			Fixed:   time.Duration(fixed * float64(time.Millisecond)),
+			PerMB:   time.Duration(perMB * float64(time.Millisecond)),
+		}, nil
+	}
+	if fileType != "" {
+		return StageCost{Samples: len(xs)}, nil
+	}
+
+	// Timings from before sizes were recorded still give the mean
+	avg, count, err := ts.GetAverageStageDuration(stage, stageTimingSampleSize)
+	if err != nil {
+		return StageCost{}, err
+	}
+	if count == 0 {
+		avg = defaultStageDurations[stage]
+	}
+	return StageCost{Samples: count, Fixed: avg}, nil
+}
+
+// fitInputRatios sets each processing stage's input ratio from the bytes
+// the stages recorded since the oldest extraction in the sample window
+func (ts *TaskStore) fitInputRatios(ratios map[string]float64) error {
+	var since sql.NullString
+	var extracted sql.NullInt64
+	err := ts.db.DB().QueryRow(`
+		SELECT MIN(recorded_at), SUM(bytes) FROM (
+			SELECT recorded_at, bytes FROM stage_timings
+			WHERE stage = ? AND bytes > 0
+			ORDER BY recorded_at DESC
+			LIMIT ?
+		)`, StageExtraction, costModelSamples).Scan(&since, &extracted)
+	if err != nil {
+		return fmt.Errorf("failed to sum extraction input: %w", err)
+	}
+	if !since.Valid || extracted.Int64 <= 0 {
+		return nil
+	}
+
+	for _, stage := range []string{StageOCR, StageConversion, StageStore} {
+		var total sql.NullInt64
+		err := ts.db.DB().QueryRow(`SELECT SUM(bytes) FROM stage_timings WHERE stage = ? AND bytes > 0 AND recorded_at >= ?`,
+			stage, since.String).Scan(&total)
+		if err != nil {
+			return fmt.Errorf("failed to sum %s input: %w", stage, err)
+		}
+		ratios[stage] = float64(total.Int64) / float64(extracted.Int64)
+	}
+	return nil
+}
+
+// fitLinear fits y = fixed + slope*x by least squares. A fit that would
+// make larger inputs faster keeps the mean instead, and one that would make
+// small inputs take negative time is forced through the origin
+func fitLinear(xs, ys []float64) (fixed, slope float64) {
+	n := float64(len(xs))
+	var sumX, sumY, sumXX, sumXY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXX += xs[i] * xs[i]
+		sumXY += xs[i] * ys[i]
+	}
+
+	denom := n*sumXX - sumX*sumX
+	if denom <= 0 {
+		return sumY / n, 0
+	}
+	slope = (n*sumXY - sumX*sumY) / denom
+	fixed = (sumY - slope*sumX) / n
+	if slope < 0 {
+		return sumY / n, 0
+	}
+	if fixed < 0 {
+		return 0, sumY / sumX
+	}
+	return fixed, slope
+}
+
+// CostEstimator keeps a cost model fitted on recent history, refitting it
+// once it is older than the refresh interval
+type CostEstimator struct {
+	store   *TaskStore
+	refresh time.Duration
+
+	mu    sync.Mutex
+	model *CostModel
+}
+
+// NewCostEstimator creates an estimator; the model is fitted on first use
+func NewCostEstimator(store *TaskStore, refresh time.Duration) *CostEstimator {
+	return &CostEstimator{store: store, refresh: refresh}
+}
+
+// Model returns the current model, refitting it when it is stale. When a
+// refit fails the previous model is returned with the error, if there is one
+func (ce *CostEstimator) Model() (*CostModel, error) {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+
+	if ce.model != nil && time.Since(ce.model.FittedAt) < ce.refresh {
+		return ce.model, nil
+	}
+	model, err := ce.store.FitCostModel()
+	if err != nil {
+		return ce.model, err
+	}
+	ce.model = model
+	return model, nil
+}
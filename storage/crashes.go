@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"telegram-archive-bot/utils"
+)
+
+// SaveCrashReport persists a crash report to the crash_reports table
+func (ts *TaskStore) SaveCrashReport(report *utils.CrashReport) error {
+	contextJSON, err := json.Marshal(report.Context)
+	if err != nil {
+		contextJSON = []byte("{}")
+	}
+
+	_, err = ts.db.DB().Exec(`
+		INSERT INTO crash_reports (id, component, task_id, user_id, panic_value, stack, context, hostname, created_at,
+			bot_version, schema_version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		report.ID, report.Component, report.TaskID, report.UserID, report.PanicValue,
+		report.Stack, string(contextJSON), report.Hostname, report.Timestamp,
+		report.Build.String(), report.SchemaVersion)
+	if err != nil {
+		return fmt.Errorf("failed to save crash report: %w", err)
+	}
+	return nil
+}
+
+// GetRecentCrashReports returns the most recent crash reports, newest first
+func (ts *TaskStore) GetRecentCrashReports(limit int) ([]*utils.CrashReport, error) {
+	rows, err := ts.db.DB().Query(`
+		SELECT id, component, task_id, user_id, panic_value, stack, context, hostname, created_at,
+			bot_version, schema_version
+		FROM crash_reports
+		ORDER BY created_at DESC
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query crash reports: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []*utils.CrashReport
+	for rows.Next() {
+		report := &utils.CrashReport{}
+		var contextJSON string
+		if err := rows.Scan(&report.ID, &report.Component, &report.TaskID, &report.UserID,
+			&report.PanicValue, &report.Stack, &contextJSON, &report.Hostname, &report.Timestamp,
+			&report.Build.Version, &report.SchemaVersion); err != nil {
+			return nil, fmt.Errorf("failed to scan crash report: %w", err)
+		}
+		json.Unmarshal([]byte(contextJSON), &report.Context)
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -14,13 +15,24 @@ type Database struct {
 	db *sql.DB
 }
 
+// MemoryDatabasePath as DATABASE_PATH keeps the database in memory, e.g. for
+// integration tests in CI
+const MemoryDatabasePath = ":memory:"
+
+// memoryDatabases numbers in-memory databases so each one is separate
+var memoryDatabases atomic.Int64
+
 func NewDatabase(dbPath string) (*Database, error) {
+	if dbPath == MemoryDatabasePath {
+		return NewMemoryDatabase()
+	}
+
 	dbDir := filepath.Dir(dbPath)
 	if err := os.MkdirAll(dbDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
-	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_timeout=5000")
+	db, err := sql.Open(sqliteHookDriver, dbPath+"?_journal_mode=WAL&_timeout=5000")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -38,6 +50,31 @@ func NewDatabase(dbPath string) (*Database, error) {
 	return database, nil
 }
 
+// NewMemoryDatabase opens a migrated database that only lives in memory and
+// is gone once closed. Every call returns a separate database
+func NewMemoryDatabase() (*Database, error) {
+	name := fmt.Sprintf("file:memdb%d?mode=memory&cache=shared", memoryDatabases.Add(1))
+	db, err := sql.Open(sqliteHookDriver, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open in-memory database: %w", err)
+	}
+
+	// A single connection serializes access the way the file lock does for
+	// on-disk databases, and keeps the database alive while it is open
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+
+	database := &Database{db: db}
+
+	if err := database.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migration failed: %w", err)
+	}
+
+	return database, nil
+}
+
 func (d *Database) Close() error {
 	return d.db.Close()
 }
@@ -46,6 +83,437 @@ func (d *Database) DB() *sql.DB {
 	return d.db
 }
 
+// schemaMigrations are applied in order; the last version is the schema
+// this binary expects
+var schemaMigrations = []struct {
+	version int
+	sql     string
+}{
+	{1, `CREATE TABLE IF NOT EXISTS tasks (
+		id TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		file_name TEXT NOT NULL,
+		file_size INTEGER NOT NULL,
+		file_type TEXT NOT NULL,
+		file_hash TEXT NOT NULL,
+		status TEXT NOT NULL,
+		error_message TEXT,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		completed_at DATETIME
+	)`},
+	{2, `CREATE INDEX IF NOT EXISTS idx_tasks_user_id ON tasks(user_id)`},
+	{3, `CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status)`},
+	{4, `CREATE INDEX IF NOT EXISTS idx_tasks_file_hash ON tasks(file_hash)`},
+	{5, `CREATE INDEX IF NOT EXISTS idx_tasks_created_at ON tasks(created_at)`},
+	{6, `CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id TEXT,
+		user_id INTEGER,
+		action TEXT NOT NULL,
+		details TEXT,
+		old_status TEXT,
+		new_status TEXT,
+		timestamp DATETIME NOT NULL
+	)`},
+	{7, `CREATE INDEX IF NOT EXISTS idx_audit_task_id ON audit_log(task_id)`},
+	{8, `CREATE INDEX IF NOT EXISTS idx_audit_user_id ON audit_log(user_id)`},
+	{9, `CREATE INDEX IF NOT EXISTS idx_audit_timestamp ON audit_log(timestamp)`},
+	{10, `CREATE INDEX IF NOT EXISTS idx_audit_action ON audit_log(action)`},
+	{11, `ALTER TABLE tasks ADD COLUMN chat_id INTEGER DEFAULT 0`},
+	{12, `ALTER TABLE tasks ADD COLUMN telegram_file_id TEXT DEFAULT ''`},
+	{13, `ALTER TABLE tasks ADD COLUMN error_category TEXT DEFAULT ''`},
+	{14, `ALTER TABLE tasks ADD COLUMN error_severity TEXT DEFAULT ''`},
+	{15, `ALTER TABLE tasks ADD COLUMN retry_count INTEGER DEFAULT 0`},
+	{16, `CREATE TABLE IF NOT EXISTS dead_letter_queue (
+		id TEXT PRIMARY KEY,
+		original_task_id TEXT NOT NULL,
+		user_id INTEGER NOT NULL,
+		chat_id INTEGER NOT NULL,
+		file_name TEXT NOT NULL,
+		file_size INTEGER NOT NULL,
+		file_type TEXT NOT NULL,
+		file_hash TEXT NOT NULL,
+		telegram_file_id TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		final_error TEXT NOT NULL,
+		error_category TEXT DEFAULT '',
+		error_severity TEXT DEFAULT '',
+		retry_count INTEGER DEFAULT 0,
+		task_context TEXT DEFAULT '',
+		created_at DATETIME NOT NULL,
+		dead_letter_at DATETIME NOT NULL,
+		last_attempt_at DATETIME,
+		can_retry BOOLEAN DEFAULT false,
+		requires_manual BOOLEAN DEFAULT false
+	)`},
+	{17, `CREATE INDEX IF NOT EXISTS idx_dead_letter_reason ON dead_letter_queue(reason)`},
+	{18, `CREATE INDEX IF NOT EXISTS idx_dead_letter_original_task ON dead_letter_queue(original_task_id)`},
+	{19, `CREATE INDEX IF NOT EXISTS idx_dead_letter_user_id ON dead_letter_queue(user_id)`},
+	{20, `CREATE INDEX IF NOT EXISTS idx_dead_letter_dead_letter_at ON dead_letter_queue(dead_letter_at)`},
+	{21, `CREATE INDEX IF NOT EXISTS idx_dead_letter_can_retry ON dead_letter_queue(can_retry)`},
+	{22, `CREATE INDEX IF NOT EXISTS idx_dead_letter_requires_manual ON dead_letter_queue(requires_manual)`},
+	{23, `CREATE TABLE IF NOT EXISTS security_audit (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id TEXT,
+		event_type TEXT NOT NULL,
+		threat_level INTEGER NOT NULL,
+		description TEXT NOT NULL,
+		file_name TEXT,
+		file_hash TEXT,
+		user_id INTEGER,
+		warnings TEXT DEFAULT '[]',
+		action_taken TEXT NOT NULL,
+		metadata TEXT DEFAULT '{}',
+		timestamp DATETIME NOT NULL
+	)`},
+	{24, `CREATE INDEX IF NOT EXISTS idx_security_audit_task_id ON security_audit(task_id)`},
+	{25, `CREATE INDEX IF NOT EXISTS idx_security_audit_event_type ON security_audit(event_type)`},
+	{26, `CREATE INDEX IF NOT EXISTS idx_security_audit_threat_level ON security_audit(threat_level)`},
+	{27, `CREATE INDEX IF NOT EXISTS idx_security_audit_user_id ON security_audit(user_id)`},
+	{28, `CREATE INDEX IF NOT EXISTS idx_security_audit_timestamp ON security_audit(timestamp)`},
+	{29, `CREATE INDEX IF NOT EXISTS idx_security_audit_action_taken ON security_audit(action_taken)`},
+	{30, `CREATE TABLE IF NOT EXISTS admin_audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		username TEXT NOT NULL,
+		action TEXT NOT NULL,
+		resource TEXT NOT NULL,
+		details TEXT DEFAULT '{}',
+		client_info TEXT DEFAULT '{}',
+		result TEXT NOT NULL,
+		error_message TEXT DEFAULT '',
+		ip_address TEXT DEFAULT '',
+		user_agent TEXT DEFAULT '',
+		session_id TEXT DEFAULT '',
+		timestamp DATETIME NOT NULL,
+		duration_ms INTEGER DEFAULT 0
+	)`},
+	{31, `CREATE INDEX IF NOT EXISTS idx_admin_audit_user_id ON admin_audit_log(user_id)`},
+	{32, `CREATE INDEX IF NOT EXISTS idx_admin_audit_action ON admin_audit_log(action)`},
+	{33, `CREATE INDEX IF NOT EXISTS idx_admin_audit_resource ON admin_audit_log(resource)`},
+	{34, `CREATE INDEX IF NOT EXISTS idx_admin_audit_result ON admin_audit_log(result)`},
+	{35, `CREATE INDEX IF NOT EXISTS idx_admin_audit_timestamp ON admin_audit_log(timestamp)`},
+	{36, `CREATE INDEX IF NOT EXISTS idx_admin_audit_username ON admin_audit_log(username)`},
+	{37, `CREATE INDEX IF NOT EXISTS idx_admin_audit_session_id ON admin_audit_log(session_id)`},
+	{38, `ALTER TABLE tasks ADD COLUMN local_api_path TEXT DEFAULT ''`},
+	{39, `ALTER TABLE tasks ADD COLUMN notified INTEGER DEFAULT 0`},
+	{40, `CREATE TABLE IF NOT EXISTS stage_timings (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		stage TEXT NOT NULL,
+		task_id TEXT DEFAULT '',
+		duration_ms INTEGER NOT NULL,
+		items INTEGER DEFAULT 1,
+		recorded_at DATETIME NOT NULL
+	)`},
+	{41, `CREATE INDEX IF NOT EXISTS idx_stage_timings_stage_recorded ON stage_timings(stage, recorded_at)`},
+	{42, `CREATE TABLE IF NOT EXISTS task_progress_messages (
+		task_id TEXT PRIMARY KEY,
+		chat_id INTEGER NOT NULL,
+		message_id INTEGER NOT NULL,
+		last_text TEXT DEFAULT '',
+		updated_at DATETIME NOT NULL
+	)`},
+	{43, `CREATE TABLE IF NOT EXISTS leader_leases (
+		name TEXT PRIMARY KEY,
+		holder_id TEXT NOT NULL,
+		fencing_token INTEGER NOT NULL DEFAULT 1,
+		expires_at INTEGER NOT NULL,
+		acquired_at DATETIME NOT NULL,
+		renewed_at DATETIME NOT NULL
+	)`},
+	{44, `CREATE TABLE IF NOT EXISTS crash_reports (
+		id TEXT PRIMARY KEY,
+		component TEXT NOT NULL,
+		task_id TEXT DEFAULT '',
+		user_id INTEGER DEFAULT 0,
+		panic_value TEXT NOT NULL,
+		stack TEXT NOT NULL,
+		context TEXT DEFAULT '{}',
+		hostname TEXT DEFAULT '',
+		created_at DATETIME NOT NULL
+	)`},
+	{45, `CREATE INDEX IF NOT EXISTS idx_crash_reports_created ON crash_reports(created_at)`},
+	{46, `CREATE TABLE IF NOT EXISTS task_short_ids (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id TEXT NOT NULL UNIQUE,
+		short_id TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL
+	)`},
+	{47, `ALTER TABLE tasks ADD COLUMN priority INTEGER DEFAULT 0`},
+	{48, `CREATE INDEX IF NOT EXISTS idx_tasks_status_priority ON tasks(status, priority, created_at)`},
+	{49, `CREATE TABLE IF NOT EXISTS task_origins (
+		task_id TEXT PRIMARY KEY,
+		chat_id INTEGER NOT NULL,
+		message_thread_id INTEGER DEFAULT 0,
+		message_id INTEGER DEFAULT 0,
+		created_at DATETIME NOT NULL
+	)`},
+	{50, `ALTER TABLE task_origins ADD COLUMN chat_username TEXT DEFAULT ''`},
+	{51, `CREATE TABLE IF NOT EXISTS task_messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id TEXT NOT NULL,
+		chat_id INTEGER NOT NULL,
+		message_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	)`},
+	{52, `CREATE INDEX IF NOT EXISTS idx_task_messages_chat_message ON task_messages(chat_id, message_id)`},
+	{53, `CREATE INDEX IF NOT EXISTS idx_task_messages_task ON task_messages(task_id)`},
+	{54, `CREATE TABLE IF NOT EXISTS scheduled_reports (
+		name TEXT PRIMARY KEY,
+		last_sent_at DATETIME NOT NULL
+	)`},
+	{55, `CREATE TABLE IF NOT EXISTS queued_operations (
+		id TEXT PRIMARY KEY,
+		dependency_name TEXT NOT NULL,
+		operation TEXT NOT NULL,
+		task_id TEXT DEFAULT '',
+		stage TEXT DEFAULT '',
+		parameters TEXT DEFAULT '{}',
+		queued_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		attempts INTEGER DEFAULT 0,
+		last_error TEXT DEFAULT ''
+	)`},
+	{56, `CREATE INDEX IF NOT EXISTS idx_queued_operations_dependency ON queued_operations(dependency_name, queued_at)`},
+	{57, `CREATE TABLE IF NOT EXISTS processor_builds (
+		name TEXT PRIMARY KEY,
+		source_hash TEXT NOT NULL,
+		bot_version TEXT DEFAULT '',
+		first_seen_at DATETIME NOT NULL,
+		verified_at DATETIME NOT NULL
+	)`},
+	{58, `CREATE TABLE IF NOT EXISTS conversion_results (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME NOT NULL,
+		files_processed INTEGER DEFAULT 0,
+		files_produced INTEGER DEFAULT 0,
+		credentials INTEGER DEFAULT 0,
+		domains INTEGER DEFAULT 0,
+		warnings INTEGER DEFAULT 0,
+		manifest TEXT NOT NULL,
+		recorded_at DATETIME NOT NULL
+	)`},
+	{59, `CREATE TABLE IF NOT EXISTS task_conversion_results (
+		task_id TEXT PRIMARY KEY,
+		result_id INTEGER NOT NULL
+	)`},
+	{60, `CREATE TABLE IF NOT EXISTS quarantine_queue (
+		task_id TEXT PRIMARY KEY,
+		file_name TEXT NOT NULL,
+		file_hash TEXT DEFAULT '',
+		user_id INTEGER DEFAULT 0,
+		source_path TEXT NOT NULL,
+		quarantine_path TEXT DEFAULT '',
+		reason TEXT DEFAULT '',
+		status TEXT NOT NULL,
+		attempts INTEGER DEFAULT 0,
+		last_error TEXT DEFAULT '',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	)`},
+	{61, `CREATE INDEX IF NOT EXISTS idx_quarantine_queue_status ON quarantine_queue(status, updated_at)`},
+	{62, `CREATE TABLE IF NOT EXISTS scan_cache (
+		file_hash TEXT NOT NULL,
+		declared_type TEXT NOT NULL,
+		definitions_fingerprint TEXT NOT NULL,
+		result TEXT NOT NULL,
+		scanned_at DATETIME NOT NULL,
+		hits INTEGER DEFAULT 0,
+		PRIMARY KEY (file_hash, declared_type)
+	)`},
+	{63, `ALTER TABLE quarantine_queue ADD COLUMN threat_level TEXT DEFAULT ''`},
+	{64, `ALTER TABLE quarantine_queue ADD COLUMN findings TEXT DEFAULT '[]'`},
+	{65, `CREATE TABLE IF NOT EXISTS availability_intervals (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		component TEXT NOT NULL,
+		status TEXT NOT NULL,
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME NOT NULL
+	)`},
+	{66, `CREATE INDEX IF NOT EXISTS idx_availability_intervals_time ON availability_intervals(ended_at, started_at)`},
+	{67, `CREATE TABLE IF NOT EXISTS health_records (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		status TEXT NOT NULL,
+		checked_at DATETIME NOT NULL,
+		components TEXT NOT NULL,
+		result TEXT NOT NULL
+	)`},
+	{68, `CREATE INDEX IF NOT EXISTS idx_health_records_kind ON health_records(kind, checked_at)`},
+	{69, `CREATE TABLE IF NOT EXISTS task_errors (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id TEXT NOT NULL,
+		attempt INTEGER NOT NULL,
+		stage TEXT NOT NULL,
+		error_message TEXT NOT NULL,
+		error_category TEXT DEFAULT '',
+		error_severity TEXT DEFAULT '',
+		retry_strategy TEXT DEFAULT '',
+		retry_delay_ms INTEGER DEFAULT 0,
+		occurred_at DATETIME NOT NULL
+	)`},
+	{70, `CREATE INDEX IF NOT EXISTS idx_task_errors_task ON task_errors(task_id, id)`},
+	{71, `CREATE TABLE IF NOT EXISTS bot_update_offsets (
+		bot_id INTEGER PRIMARY KEY,
+		next_offset INTEGER NOT NULL,
+		updated_at DATETIME NOT NULL
+	)`},
+	{72, `CREATE TABLE IF NOT EXISTS processed_updates (
+		bot_id INTEGER NOT NULL,
+		update_id INTEGER NOT NULL,
+		processed_at DATETIME NOT NULL,
+		PRIMARY KEY (bot_id, update_id)
+	)`},
+	{73, `CREATE INDEX IF NOT EXISTS idx_processed_updates_time ON processed_updates(processed_at)`},
+	{74, `CREATE TABLE IF NOT EXISTS ledger_entries (
+		task_id TEXT NOT NULL,
+		sink TEXT NOT NULL,
+		recorded_at DATETIME NOT NULL,
+		PRIMARY KEY (task_id, sink)
+	)`},
+	{75, `CREATE TABLE IF NOT EXISTS archive_metadata (
+		task_id TEXT PRIMARY KEY,
+		format TEXT NOT NULL,
+		entries INTEGER NOT NULL DEFAULT 0,
+		directories INTEGER NOT NULL DEFAULT 0,
+		uncompressed_size INTEGER NOT NULL DEFAULT 0,
+		compressed_size INTEGER NOT NULL DEFAULT 0,
+		max_entry_ratio REAL NOT NULL DEFAULT 0,
+		methods TEXT NOT NULL DEFAULT '{}',
+		encrypted_entries INTEGER NOT NULL DEFAULT 0,
+		encrypted BOOLEAN NOT NULL DEFAULT 0,
+		comment TEXT NOT NULL DEFAULT '',
+		entry_comments INTEGER NOT NULL DEFAULT 0,
+		partial BOOLEAN NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		inspected_at DATETIME NOT NULL
+	)`},
+	{76, `CREATE TABLE IF NOT EXISTS conversion_domains (
+		result_id INTEGER NOT NULL,
+		domain TEXT NOT NULL,
+		credentials INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (result_id, domain)
+	)`},
+	{77, `CREATE INDEX IF NOT EXISTS idx_conversion_domains_domain ON conversion_domains(domain, result_id)`},
+	{78, `ALTER TABLE crash_reports ADD COLUMN bot_version TEXT DEFAULT ''`},
+	{79, `ALTER TABLE crash_reports ADD COLUMN schema_version INTEGER DEFAULT 0`},
+	{80, `ALTER TABLE tasks ADD COLUMN claimed_by TEXT DEFAULT ''`},
+	{81, `ALTER TABLE tasks ADD COLUMN lease_expires INTEGER DEFAULT 0`},
+	{82, `CREATE INDEX IF NOT EXISTS idx_tasks_status_lease ON tasks(status, lease_expires)`},
+	{83, `ALTER TABLE tasks ADD COLUMN telegram_file_unique_id TEXT DEFAULT ''`},
+	{84, `CREATE INDEX IF NOT EXISTS idx_tasks_file_unique_id ON tasks(telegram_file_unique_id)`},
+	{85, `ALTER TABLE tasks ADD COLUMN storage_path TEXT DEFAULT ''`},
+	{86, `CREATE TABLE IF NOT EXISTS task_deletions (
+		task_id TEXT PRIMARY KEY,
+		deleted_by INTEGER DEFAULT 0,
+		deleted_by_name TEXT DEFAULT '',
+		deleted_at DATETIME NOT NULL,
+		purge_after DATETIME NOT NULL,
+		purged_at DATETIME,
+		files_removed INTEGER DEFAULT 0,
+		purge_error TEXT DEFAULT ''
+	)`},
+	{87, `CREATE INDEX IF NOT EXISTS idx_task_deletions_purge ON task_deletions(purged_at, purge_after)`},
+	{88, `CREATE INDEX IF NOT EXISTS idx_tasks_status_created ON tasks(status, created_at, id)`},
+	{89, `CREATE INDEX IF NOT EXISTS idx_tasks_status_completed ON tasks(status, completed_at)`},
+	{90, `DROP INDEX IF EXISTS idx_tasks_status`},
+	{91, `CREATE TABLE IF NOT EXISTS reconciliation_reports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		trigger_name TEXT NOT NULL,
+		instance_id TEXT DEFAULT '',
+		started_at DATETIME NOT NULL,
+		completed_at DATETIME NOT NULL,
+		tasks_reset INTEGER DEFAULT 0,
+		tasks_relinked INTEGER DEFAULT 0,
+		tasks_failed INTEGER DEFAULT 0,
+		orphaned_files INTEGER DEFAULT 0,
+		quarantine_candidates INTEGER DEFAULT 0,
+		report TEXT NOT NULL
+	)`},
+	{92, `CREATE INDEX IF NOT EXISTS idx_reconciliation_reports_started ON reconciliation_reports(started_at)`},
+	{93, `CREATE TABLE IF NOT EXISTS alert_rules (
+		name TEXT PRIMARY KEY,
+		alert_type TEXT NOT NULL,
+		level TEXT NOT NULL,
+		metric TEXT NOT NULL,
+		operator TEXT NOT NULL,
+		threshold REAL NOT NULL,
+		message TEXT DEFAULT '',
+		cooldown_seconds INTEGER NOT NULL,
+		enabled BOOLEAN NOT NULL DEFAULT 1,
+		builtin BOOLEAN NOT NULL DEFAULT 0,
+		last_fired DATETIME,
+		updated_at DATETIME NOT NULL
+	)`},
+	{94, `ALTER TABLE alert_rules ADD COLUMN kind TEXT DEFAULT 'threshold'`},
+	{95, `ALTER TABLE alert_rules ADD COLUMN conditions TEXT DEFAULT ''`},
+	{96, `ALTER TABLE alert_rules ADD COLUMN for_seconds INTEGER DEFAULT 0`},
+	{97, `ALTER TABLE alert_rules ADD COLUMN baseline_seconds INTEGER DEFAULT 0`},
+	{98, `CREATE TABLE IF NOT EXISTS alert_outbox (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		message_id TEXT NOT NULL,
+		channel TEXT NOT NULL,
+		recipient TEXT NOT NULL DEFAULT '',
+		text TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT DEFAULT '',
+		created_at DATETIME NOT NULL,
+		next_attempt_at DATETIME NOT NULL,
+		delivered_at DATETIME
+	)`},
+	{99, `CREATE UNIQUE INDEX IF NOT EXISTS idx_alert_outbox_delivery ON alert_outbox(message_id, channel, recipient)`},
+	{100, `CREATE INDEX IF NOT EXISTS idx_alert_outbox_due ON alert_outbox(status, next_attempt_at)`},
+	{101, `ALTER TABLE alert_rules ADD COLUMN path_thresholds TEXT DEFAULT ''`},
+	{102, `ALTER TABLE tasks ADD COLUMN downloaded_bytes INTEGER DEFAULT 0`},
+	{103, `ALTER TABLE tasks ADD COLUMN bot_api_bytes INTEGER DEFAULT 0`},
+	{104, `ALTER TABLE tasks ADD COLUMN downloaded_at DATETIME`},
+	{105, `CREATE INDEX IF NOT EXISTS idx_tasks_downloaded_at ON tasks(downloaded_at)`},
+	{106, `ALTER TABLE stage_timings ADD COLUMN bytes INTEGER DEFAULT 0`},
+	{107, `ALTER TABLE tasks ADD COLUMN estimated_cost_ms INTEGER DEFAULT 0`},
+	{108, `ALTER TABLE tasks ADD COLUMN retry_of TEXT DEFAULT ''`},
+	{109, `CREATE INDEX IF NOT EXISTS idx_tasks_retry_of ON tasks(retry_of)`},
+	{110, `ALTER TABLE conversion_results ADD COLUMN parser_version INTEGER DEFAULT 0`},
+	{111, `ALTER TABLE conversion_results ADD COLUMN input_dir TEXT DEFAULT ''`},
+	{112, `CREATE TABLE IF NOT EXISTS reprocess_campaigns (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		parser_version INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		started_by INTEGER DEFAULT 0,
+		started_by_name TEXT DEFAULT '',
+		created_at DATETIME NOT NULL,
+		finished_at DATETIME
+	)`},
+	{113, `CREATE TABLE IF NOT EXISTS reprocess_items (
+		campaign_id INTEGER NOT NULL,
+		result_id INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		new_result_id INTEGER DEFAULT 0,
+		error TEXT DEFAULT '',
+		updated_at DATETIME NOT NULL,
+		PRIMARY KEY (campaign_id, result_id)
+	)`},
+	// Claims recorded before this column counted as handled
+	{114, `ALTER TABLE processed_updates ADD COLUMN completed INTEGER NOT NULL DEFAULT 1`},
+}
+
+// LatestSchemaVersion returns the schema version this binary migrates to
+func LatestSchemaVersion() int {
+	return schemaMigrations[len(schemaMigrations)-1].version
+}
+
+// SchemaVersion returns the latest migration applied to the database
+func (d *Database) SchemaVersion() (int, error) {
+	var version sql.NullInt64
+	if err := d.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
 func (d *Database) migrate() error {
 	// Create migration tracking table first
 	_, err := d.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
@@ -56,123 +524,8 @@ func (d *Database) migrate() error {
 		return fmt.Errorf("failed to create migrations table: %w", err)
 	}
 
-	migrations := []struct {
-		version int
-		sql     string
-	}{
-		{1, `CREATE TABLE IF NOT EXISTS tasks (
-			id TEXT PRIMARY KEY,
-			user_id INTEGER NOT NULL,
-			file_name TEXT NOT NULL,
-			file_size INTEGER NOT NULL,
-			file_type TEXT NOT NULL,
-			file_hash TEXT NOT NULL,
-			status TEXT NOT NULL,
-			error_message TEXT,
-			created_at DATETIME NOT NULL,
-			updated_at DATETIME NOT NULL,
-			completed_at DATETIME
-		)`},
-		{2, `CREATE INDEX IF NOT EXISTS idx_tasks_user_id ON tasks(user_id)`},
-		{3, `CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status)`},
-		{4, `CREATE INDEX IF NOT EXISTS idx_tasks_file_hash ON tasks(file_hash)`},
-		{5, `CREATE INDEX IF NOT EXISTS idx_tasks_created_at ON tasks(created_at)`},
-		{6, `CREATE TABLE IF NOT EXISTS audit_log (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			task_id TEXT,
-			user_id INTEGER,
-			action TEXT NOT NULL,
-			details TEXT,
-			old_status TEXT,
-			new_status TEXT,
-			timestamp DATETIME NOT NULL
-		)`},
-		{7, `CREATE INDEX IF NOT EXISTS idx_audit_task_id ON audit_log(task_id)`},
-		{8, `CREATE INDEX IF NOT EXISTS idx_audit_user_id ON audit_log(user_id)`},
-		{9, `CREATE INDEX IF NOT EXISTS idx_audit_timestamp ON audit_log(timestamp)`},
-		{10, `CREATE INDEX IF NOT EXISTS idx_audit_action ON audit_log(action)`},
-		{11, `ALTER TABLE tasks ADD COLUMN chat_id INTEGER DEFAULT 0`},
-		{12, `ALTER TABLE tasks ADD COLUMN telegram_file_id TEXT DEFAULT ''`},
-		{13, `ALTER TABLE tasks ADD COLUMN error_category TEXT DEFAULT ''`},
-		{14, `ALTER TABLE tasks ADD COLUMN error_severity TEXT DEFAULT ''`},
-		{15, `ALTER TABLE tasks ADD COLUMN retry_count INTEGER DEFAULT 0`},
-		{16, `CREATE TABLE IF NOT EXISTS dead_letter_queue (
-			id TEXT PRIMARY KEY,
-			original_task_id TEXT NOT NULL,
-			user_id INTEGER NOT NULL,
-			chat_id INTEGER NOT NULL,
-			file_name TEXT NOT NULL,
-			file_size INTEGER NOT NULL,
-			file_type TEXT NOT NULL,
-			file_hash TEXT NOT NULL,
-			telegram_file_id TEXT NOT NULL,
-			reason TEXT NOT NULL,
-			final_error TEXT NOT NULL,
-			error_category TEXT DEFAULT '',
-			error_severity TEXT DEFAULT '',
-			retry_count INTEGER DEFAULT 0,
-			task_context TEXT DEFAULT '',
-			created_at DATETIME NOT NULL,
-			dead_letter_at DATETIME NOT NULL,
-			last_attempt_at DATETIME,
-			can_retry BOOLEAN DEFAULT false,
-			requires_manual BOOLEAN DEFAULT false
-		)`},
-		{17, `CREATE INDEX IF NOT EXISTS idx_dead_letter_reason ON dead_letter_queue(reason)`},
-		{18, `CREATE INDEX IF NOT EXISTS idx_dead_letter_original_task ON dead_letter_queue(original_task_id)`},
-		{19, `CREATE INDEX IF NOT EXISTS idx_dead_letter_user_id ON dead_letter_queue(user_id)`},
-		{20, `CREATE INDEX IF NOT EXISTS idx_dead_letter_dead_letter_at ON dead_letter_queue(dead_letter_at)`},
-		{21, `CREATE INDEX IF NOT EXISTS idx_dead_letter_can_retry ON dead_letter_queue(can_retry)`},
-		{22, `CREATE INDEX IF NOT EXISTS idx_dead_letter_requires_manual ON dead_letter_queue(requires_manual)`},
-		{23, `CREATE TABLE IF NOT EXISTS security_audit (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			task_id TEXT,
-			event_type TEXT NOT NULL,
-			threat_level INTEGER NOT NULL,
-			description TEXT NOT NULL,
-			file_name TEXT,
-			file_hash TEXT,
-			user_id INTEGER,
-			warnings TEXT DEFAULT '[]',
-			action_taken TEXT NOT NULL,
-			metadata TEXT DEFAULT '{}',
-			timestamp DATETIME NOT NULL
-		)`},
-		{24, `CREATE INDEX IF NOT EXISTS idx_security_audit_task_id ON security_audit(task_id)`},
-		{25, `CREATE INDEX IF NOT EXISTS idx_security_audit_event_type ON security_audit(event_type)`},
-		{26, `CREATE INDEX IF NOT EXISTS idx_security_audit_threat_level ON security_audit(threat_level)`},
-		{27, `CREATE INDEX IF NOT EXISTS idx_security_audit_user_id ON security_audit(user_id)`},
-		{28, `CREATE INDEX IF NOT EXISTS idx_security_audit_timestamp ON security_audit(timestamp)`},
-		{29, `CREATE INDEX IF NOT EXISTS idx_security_audit_action_taken ON security_audit(action_taken)`},
-		{30, `CREATE TABLE IF NOT EXISTS admin_audit_log (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			username TEXT NOT NULL,
-			action TEXT NOT NULL,
-			resource TEXT NOT NULL,
-			details TEXT DEFAULT '{}',
-			client_info TEXT DEFAULT '{}',
-			result TEXT NOT NULL,
-			error_message TEXT DEFAULT '',
-			ip_address TEXT DEFAULT '',
-			user_agent TEXT DEFAULT '',
-			session_id TEXT DEFAULT '',
-			timestamp DATETIME NOT NULL,
-			duration_ms INTEGER DEFAULT 0
-		)`},
-		{31, `CREATE INDEX IF NOT EXISTS idx_admin_audit_user_id ON admin_audit_log(user_id)`},
-		{32, `CREATE INDEX IF NOT EXISTS idx_admin_audit_action ON admin_audit_log(action)`},
-		{33, `CREATE INDEX IF NOT EXISTS idx_admin_audit_resource ON admin_audit_log(resource)`},
-		{34, `CREATE INDEX IF NOT EXISTS idx_admin_audit_result ON admin_audit_log(result)`},
-		{35, `CREATE INDEX IF NOT EXISTS idx_admin_audit_timestamp ON admin_audit_log(timestamp)`},
-		{36, `CREATE INDEX IF NOT EXISTS idx_admin_audit_username ON admin_audit_log(username)`},
-		{37, `CREATE INDEX IF NOT EXISTS idx_admin_audit_session_id ON admin_audit_log(session_id)`},
-		{38, `ALTER TABLE tasks ADD COLUMN local_api_path TEXT DEFAULT ''`},
-		{39, `ALTER TABLE tasks ADD COLUMN notified INTEGER DEFAULT 0`},
-	}
-
 	// Apply migrations that haven't been applied yet
-	for _, migration := range migrations {
+	for _, migration := range schemaMigrations {
 		var count int
 		err := d.db.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = ?", migration.version).Scan(&count)
 		if err != nil {
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"fmt"
+)
+
+// DatabaseStats describes the size and contents of the database
+type DatabaseStats struct {
+	SchemaVersion int              `json:"schema_version"`
+	PageSize      int64            `json:"page_size"`
+	PageCount     int64            `json:"page_count"`
+	FreePages     int64            `json:"free_pages"`
+	SizeBytes     int64            `json:"size_bytes"` // PageSize × PageCount, without the WAL
+	TableRows     map[string]int64 `json:"table_rows"`
+	TaskStatuses  map[string]int   `json:"task_statuses"`
+}
+
+// GetDatabaseStats returns the schema version, page usage and the row count
+// of every table
+func (ts *TaskStore) GetDatabaseStats() (*DatabaseStats, error) {
+	db := ts.db.DB()
+	stats := &DatabaseStats{TableRows: make(map[string]int64)}
+
+	var err error
+	if stats.SchemaVersion, err = ts.db.SchemaVersion(); err != nil {
+		return nil, err
+	}
+	for pragma, dest := range map[string]*int64{
+		"page_size":      &stats.PageSize,
+		"page_count":     &stats.PageCount,
+		"freelist_count": &stats.FreePages,
+	} {
+		if err := db.QueryRow("PRAGMA " + pragma).Scan(dest); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", pragma, err)
+		}
+	}
+	stats.SizeBytes = stats.PageSize * stats.PageCount
+
+	tables, err := getTables(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table list: %w", err)
+	}
+	for _, table := range tables {
+		var count int64
+		if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to count rows of %s: %w", table, err)
+		}
+		stats.TableRows[table] = count
+	}
+
+	if stats.TaskStatuses, err = ts.GetStats(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
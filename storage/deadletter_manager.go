@@ -30,11 +30,26 @@ func NewDeadLetterManager(dlq *DeadLetterQueue, taskStore *TaskStore, logger *ut
 func (dlm *DeadLetterManager) MoveToDeadLetter(task *models.Task, finalError error, context map[string]interface{}) error {
 	// Categorize the error to determine the reason for dead lettering
 	categorizedError := dlm.errorHandler.Handle(finalError, context)
-	
-	reason := dlm.determineDeadLetterReason(task, categorizedError)
+
+	// Decide on the whole failure history, not just the final error
+	history, err := dlm.taskStore.GetTaskErrors(task.ID)
+	if err != nil {
+		dlm.logger.WithField("task_id", task.ID).WithError(err).Warn("Failed to load task error history")
+	}
+	summary := SummarizeTaskErrors(history)
+	if summary.Failures > 0 {
+		withHistory := make(map[string]interface{}, len(context)+1)
+		for k, v := range context {
+			withHistory[k] = v
+		}
+		withHistory["error_history"] = summary
+		context = withHistory
+	}
+
+	reason := dlm.determineDeadLetterReason(task, categorizedError, summary)
 	
 	// Add to dead letter queue
-	err := dlm.deadLetterQueue.Add(task, reason, finalError.Error(), context)
+	err = dlm.deadLetterQueue.Add(task, reason, finalError.Error(), context)
 	if err != nil {
 		dlm.logger.WithField("task_id", task.ID).
 			WithField("error", err.Error()).
@@ -212,29 +227,37 @@ func (dlm *DeadLetterManager) ManualMove(taskID string, reason string) error {
 	return dlm.MoveToDeadLetter(task, manualError, context)
 }
 
-// determineDeadLetterReason analyzes the task and error to determine the appropriate dead letter reason
-func (dlm *DeadLetterManager) determineDeadLetterReason(task *models.Task, categorizedError *utils.CategorizedError) DeadLetterReason {
-	// Check retry count first
-	if task.RetryCount >= 5 { // Configurable max retry threshold
+// determineDeadLetterReason analyzes the task, its error history and final
+// error to determine the appropriate dead letter reason
+func (dlm *DeadLetterManager) determineDeadLetterReason(task *models.Task, categorizedError *utils.CategorizedError, history *TaskErrorSummary) DeadLetterReason {
+	// Check retry count first; the history also counts attempts within a run
+	failures := task.RetryCount
+	if history.Failures > failures {
+		failures = history.Failures
+	}
+	if failures >= 5 { // Configurable max retry threshold
 		return DeadLetterReasonMaxRetriesExceeded
 	}
 
 	// Check error severity
-	switch categorizedError.Severity {
-	case utils.SeverityCritical:
+	if categorizedError.Severity == utils.SeverityCritical || history.HighestSeverity == string(utils.SeverityCritical) {
 		return DeadLetterReasonCriticalError
 	}
 
-	// Check retry strategy
-	switch categorizedError.Retry {
-	case utils.RetryNever:
+	// Check retry strategy; any earlier non-retryable failure makes the task non-retryable
+	switch {
+	case categorizedError.Retry == utils.RetryNever || history.NonRetryable:
 		return DeadLetterReasonNonRetryableError
-	case utils.RetryManual:
+	case categorizedError.Retry == utils.RetryManual:
 		return DeadLetterReasonSystemFailure
 	}
 
-	// Check error category
-	switch categorizedError.Category {
+	// Check error category, preferring the category behind most failures
+	category := categorizedError.Category
+	if history.DominantCategory != "" {
+		category = utils.ErrorCategory(history.DominantCategory)
+	}
+	switch category {
 	case utils.ErrorCategoryValidation:
 		return DeadLetterReasonNonRetryableError
 	case utils.ErrorCategoryAuth:
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"database/sql"
+	"sync/atomic"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// TaskDispatch wakes idle download workers as soon as a task becomes PENDING,
+// so they don't have to poll the task table to notice it
+type TaskDispatch struct {
+	wake chan struct{}
+}
+
+// NewTaskDispatch creates a dispatch able to wake up to workers at once
+func NewTaskDispatch(workers int) *TaskDispatch {
+	if workers < 1 {
+		workers = 1
+	}
+	return &TaskDispatch{wake: make(chan struct{}, workers)}
+}
+
+// Notify wakes one idle worker. It never blocks; once every worker has a
+// wake-up pending, further ones are dropped, since each woken worker claims
+// tasks until none are left
+func (d *TaskDispatch) Notify() {
+	if d == nil {
+		return
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Wake delivers a value for each wake-up. On a nil dispatch it never does
+func (d *TaskDispatch) Wake() <-chan struct{} {
+	if d == nil {
+		return nil
+	}
+	return d.wake
+}
+
+// sqliteHookDriver is the sqlite3 driver the task database is opened with. It
+// installs an update hook on every connection, which wakes the hooked
+// dispatch on each insert or update of the tasks table
+const sqliteHookDriver = "sqlite3_task_hook"
+
+// hookedDispatch is woken by the update hook; nil leaves the hook idle
+var hookedDispatch atomic.Pointer[TaskDispatch]
+
+func init() {
+	sql.Register(sqliteHookDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			conn.RegisterUpdateHook(func(op int, _ string, table string, _ int64) {
+				if table != "tasks" || (op != sqlite3.SQLITE_INSERT && op != sqlite3.SQLITE_UPDATE) {
+					return
+				}
+				hookedDispatch.Load().Notify()
+			})
+			return nil
+		},
+	})
+}
+
+// SetDispatch makes the store notify dispatch whenever it makes a task
+// PENDING. With updateHook every write to the tasks table notifies it too,
+// including tasks requeued with raw SQL outside the store, at the cost of
+// waking idle workers for changes that don't queue anything
+func (ts *TaskStore) SetDispatch(dispatch *TaskDispatch, updateHook bool) {
+	ts.dispatch = dispatch
+	if updateHook {
+		hookedDispatch.Store(dispatch)
+	}
+}
+
+// notifyPending wakes an idle download worker for a newly PENDING task
+func (ts *TaskStore) notifyPending() {
+	ts.dispatch.Notify()
+}
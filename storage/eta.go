@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/utils"
+)
+
+// Pipeline stage names used for timing metrics
+const (
+	StageDownload   = "download"
+	StageExtraction = "extraction"
+	StageOCR        = "ocr"
+	StageConversion = "conversion"
+	StageStore      = "store"
+	// StageFastPath validates and converts text files, which skip extraction
+	StageFastPath = "fast_path"
+)
+
+// Number of recent samples used when averaging stage durations
+const stageTimingSampleSize = 20
+
+// Fallback durations used until enough history has been recorded
+var defaultStageDurations = map[string]time.Duration{
+	StageDownload:   1 * time.Minute,
+	StageExtraction: 2 * time.Minute,
+	StageConversion: 1 * time.Minute,
+	StageStore:      1 * time.Minute,
+	StageFastPath:   10 * time.Second,
+}
+
+// QueueEstimate describes a task's position in the queue and its expected completion
+type QueueEstimate struct {
+	Status           models.TaskStatus
+	Position         int // 1-based position among PENDING tasks (0 once download started)
+	PendingAhead     int
+	DownloadingCount int
+	ETA              time.Duration
+	EstimatedAt      time.Time
+	HasHistory       bool // false when defaults were used for any stage
+}
+
+// EstimateOptions controls how queue estimates are calculated
+type EstimateOptions struct {
+	DownloadWorkers      int
+	DownloadPollInterval time.Duration
+	ProcessPollInterval  time.Duration
+	// Costs, when set, estimates from the task's and the queue's sizes and
+	// types instead of the mean stage durations
+	Costs *CostModel
+}
+
+// DefaultEstimateOptions matches the Option 1 worker layout
+func DefaultEstimateOptions() EstimateOptions {
+	return EstimateOptions{
+		DownloadWorkers:      3,
+		DownloadPollInterval: 5 * time.Second,
+		ProcessPollInterval:  10 * time.Second,
+	}
+}
+
+// RecordStageTiming persists how long a pipeline stage took for items
+// files of bytes in total; the cost model is fitted on these
+func (ts *TaskStore) RecordStageTiming(stage, taskID string, duration time.Duration, items int, bytes int64) error {
+	query := `
+		INSERT INTO stage_timings (stage, task_id, duration_ms, items, bytes, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	_, err := ts.db.DB().Exec(query, stage, taskID, duration.Milliseconds(), items, bytes, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to record stage timing: %w", err)
+	}
+	return nil
+}
+
+// GetAverageStageDuration returns the mean duration of the most recent samples for a stage
+func (ts *TaskStore) GetAverageStageDuration(stage string, samples int) (time.Duration, int, error) {
+	query := `
+		SELECT AVG(duration_ms), COUNT(*) FROM (
+			SELECT duration_ms FROM stage_timings
+			WHERE stage = ?
+			ORDER BY recorded_at DESC
+			LIMIT ?
+		)
+	`
+	var avg sql.NullFloat64
+	var count int
+	if err := ts.db.DB().QueryRow(query, stage, samples).Scan(&avg, &count); err != nil {
+		return 0, 0, fmt.Errorf("failed to get average stage duration: %w", err)
+	}
+	if !avg.Valid || count == 0 {
+		return 0, 0, nil
+	}
+	return time.Duration(avg.Float64) * time.Millisecond, count, nil
+}
+
+// GetQueuePosition returns the 1-based position of a PENDING task in the
+// download queue, in the order tasks are claimed
+func (ts *TaskStore) GetQueuePosition(task *models.Task) (int, error) {
+	if ts.order.Mode == utils.QueueOrderShortestFirst {
+		ahead, err := ts.pendingAhead(task.ID)
+		if err != nil {
+			return 0, err
+		}
+		return len(ahead) + 1, nil
+	}
+
+	priority, err := ts.GetPriority(task.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `SELECT COUNT(*) FROM tasks WHERE status = ? AND
+		(COALESCE(priority, 0) > ? OR (COALESCE(priority, 0) = ? AND created_at < ?))`
+	var ahead int
+	if err := ts.db.DB().QueryRow(query, models.TaskStatusPending, priority, priority, task.CreatedAt).Scan(&ahead); err != nil {
+		return 0, fmt.Errorf("failed to get queue position: %w", err)
+	}
+	return ahead + 1, nil
+}
+
+// EstimateCompletion calculates queue position and ETA for a task from recent stage timings
+func (ts *TaskStore) EstimateCompletion(task *models.Task, opts EstimateOptions) (*QueueEstimate, error) {
+	if opts.DownloadWorkers <= 0 {
+		opts.DownloadWorkers = 1
+	}
+
+	estimate := &QueueEstimate{
+		Status:      task.Status,
+		EstimatedAt: time.Now(),
+		HasHistory:  true,
+	}
+	if opts.Costs != nil {
+		return ts.estimateFromCosts(task, opts, estimate)
+	}
+
+	stageAverage := func(stage string) (time.Duration, error) {
+		avg, count, err := ts.GetAverageStageDuration(stage, stageTimingSampleSize)
+		if err != nil {
+			return 0, err
+		}
+		if count == 0 {
+			estimate.HasHistory = false
+			return defaultStageDurations[stage], nil
+		}
+		return avg, nil
+	}
+
+	downloadAvg, err := stageAverage(StageDownload)
+	if err != nil {
+		return nil, err
+	}
+
+	// Extraction, OCR, conversion and store run as one sequential cycle over
+	// all downloaded files; OCR has no fallback duration as it is usually off
+	var processing time.Duration
+	for _, stage := range []string{StageExtraction, StageOCR, StageConversion, StageStore} {
+		avg, err := stageAverage(stage)
+		if err != nil {
+			return nil, err
+		}
+		processing += avg
+	}
+	processing += opts.ProcessPollInterval
+
+	downloading, err := ts.GetTaskCountByStatus(models.TaskStatusDownloading)
+	if err != nil {
+		return nil, err
+	}
+	estimate.DownloadingCount = downloading
+
+	switch task.Status {
+	case models.TaskStatusPending:
+		position, err := ts.GetQueuePosition(task)
+		if err != nil {
+			return nil, err
+		}
+		estimate.Position = position
+		estimate.PendingAhead = position - 1
+
+		// Downloads happen in waves of DownloadWorkers tasks
+		waves := (position + opts.DownloadWorkers - 1) / opts.DownloadWorkers
+		if downloading >= opts.DownloadWorkers && position <= opts.DownloadWorkers {
+			waves++
+		}
+		estimate.ETA = time.Duration(waves)*downloadAvg + opts.DownloadPollInterval + processing
+
+	case models.TaskStatusDownloading:
+		remaining := downloadAvg - time.Since(task.UpdatedAt)
+		if remaining < 0 {
+			remaining = 0
+		}
+		estimate.ETA = remaining + processing
+
+	case models.TaskStatusDownloaded:
+		estimate.ETA = processing
+
+	default:
+		estimate.ETA = 0
+	}
+
+	return estimate, nil
+}
+
+// estimateFromCosts estimates with the cost model: the tasks ahead take
+// their predicted download time, shared among the download workers
+func (ts *TaskStore) estimateFromCosts(task *models.Task, opts EstimateOptions, estimate *QueueEstimate) (*QueueEstimate, error) {
+	cost := opts.Costs.Estimate(task.FileType, task.FileSize)
+	estimate.HasHistory = cost.Fitted
+	processing := cost.Processing + opts.ProcessPollInterval
+
+	queued, err := ts.GetQueuedFiles()
+	if err != nil {
+		return nil, err
+	}
+	var downloading time.Duration
+	for _, f := range queued {
+		if f.Status == models.TaskStatusDownloading {
+			estimate.DownloadingCount++
+			downloading += opts.Costs.Estimate(f.FileType, f.FileSize).Download
+		}
+	}
+
+	switch task.Status {
+	case models.TaskStatusPending:
+		ahead, err := ts.pendingAhead(task.ID)
+		if err != nil {
+			return nil, err
+		}
+		estimate.Position = len(ahead) + 1
+		estimate.PendingAhead = len(ahead)
+
+		// Running downloads are assumed half done
+		wait := downloading / 2
+		for _, f := range ahead {
+			wait += opts.Costs.Estimate(f.FileType, f.FileSize).Download
+		}
+		wait /= time.Duration(opts.DownloadWorkers)
+		estimate.ETA = wait + cost.Download + opts.DownloadPollInterval + processing
+
+	case models.TaskStatusDownloading:
+		remaining := cost.Download - time.Since(task.UpdatedAt)
+		if remaining < 0 {
+			remaining = 0
+		}
+		estimate.ETA = remaining + processing
+
+	case models.TaskStatusDownloaded:
+		estimate.ETA = processing
+
+	default:
+		estimate.ETA = 0
+	}
+	return estimate, nil
+}
+
+// ProgressMessage links a task to the Telegram message reporting its progress
+type ProgressMessage struct {
+	TaskID    string
+	ChatID    int64
+	MessageID int
+	LastText  string
+	UpdatedAt time.Time
+}
+
+// SaveProgressMessage stores the message used to report a task's progress
+func (ts *TaskStore) SaveProgressMessage(taskID string, chatID int64, messageID int, text string) error {
+	query := `
+		INSERT OR REPLACE INTO task_progress_messages (task_id, chat_id, message_id, last_text, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	_, err := ts.db.DB().Exec(query, taskID, chatID, messageID, text, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save progress message: %w", err)
+	}
+	return nil
+}
+
+// GetProgressMessages returns all tracked progress messages
+func (ts *TaskStore) GetProgressMessages() ([]*ProgressMessage, error) {
+	query := `
+		SELECT task_id, chat_id, message_id, last_text, updated_at
+		FROM task_progress_messages
+		ORDER BY updated_at ASC
+	`
+	rows, err := ts.db.DB().Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query progress messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*ProgressMessage
+	for rows.Next() {
+		msg := &ProgressMessage{}
+		if err := rows.Scan(&msg.TaskID, &msg.ChatID, &msg.MessageID, &msg.LastText, &msg.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan progress message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return messages, nil
+}
+
+// DeleteProgressMessage stops tracking the progress message for a task
+func (ts *TaskStore) DeleteProgressMessage(taskID string) error {
+	_, err := ts.db.DB().Exec(`DELETE FROM task_progress_messages WHERE task_id = ?`, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to delete progress message: %w", err)
+	}
+	return nil
+}
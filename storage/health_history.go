@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Kinds of stored health records
+const (
+	HealthRecordCheck       = "health_check"
+	HealthRecordDiagnostics = "diagnostics"
+)
+
+// HealthComponentStatus is one component's or diagnostic's result in a health record
+type HealthComponentStatus struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// HealthRecord is a stored health check or diagnostic suite. Result holds
+// the full original JSON; Components the per-component summary used to
+// find status changes
+type HealthRecord struct {
+	ID         int64
+	Kind       string
+	Status     string
+	CheckedAt  time.Time
+	Components []HealthComponentStatus
+	Result     json.RawMessage
+}
+
+// HealthStatusChange is a component whose status differs from its previous record
+type HealthStatusChange struct {
+	Kind      string    `json:"kind"`
+	Component string    `json:"component"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Message   string    `json:"message,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// SaveHealthRecord stores a health record and drops the oldest records of the
+// same kind beyond the newest keep, so the table works as a ring buffer
+func (ts *TaskStore) SaveHealthRecord(record *HealthRecord, keep int) error {
+	components, err := json.Marshal(record.Components)
+	if err != nil {
+		return fmt.Errorf("failed to encode health record components: %w", err)
+	}
+
+	res, err := ts.db.DB().Exec(`
+		INSERT INTO health_records (kind, status, checked_at, components, result)
+		VALUES (?, ?, ?, ?, ?)`,
+		record.Kind, record.Status, record.CheckedAt, string(components), string(record.Result))
+	if err != nil {
+		return fmt.Errorf("failed to save health record: %w", err)
+	}
+	record.ID, _ = res.LastInsertId()
+
+	if keep > 0 {
+		// IDs are shared across kinds, so the newest keep are found by rank
+		if _, err := ts.db.DB().Exec(`
+			DELETE FROM health_records
+			WHERE kind = ? AND id NOT IN (
+				SELECT id FROM health_records WHERE kind = ? ORDER BY id DESC LIMIT ?
+			)`, record.Kind, record.Kind, keep); err != nil {
+			return fmt.Errorf("failed to trim health records: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetHealthRecords returns the records of a kind checked since the given
+// time, oldest first; with withResult false the full result is not loaded
+func (ts *TaskStore) GetHealthRecords(kind string, since time.Time, withResult bool) ([]*HealthRecord, error) {
+	result := "''"
+	if withResult {
+		result = "result"
+	}
+	rows, err := ts.db.DB().Query(`
+		SELECT id, kind, status, checked_at, components, `+result+`
+		FROM health_records WHERE kind = ? AND checked_at >= ? ORDER BY id`, kind, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query health records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*HealthRecord
+	for rows.Next() {
+		record := &HealthRecord{}
+		var components, raw string
+		if err := rows.Scan(&record.ID, &record.Kind, &record.Status, &record.CheckedAt, &components, &raw); err != nil {
+			return nil, fmt.Errorf("failed to scan health record: %w", err)
+		}
+		if err := json.Unmarshal([]byte(components), &record.Components); err != nil {
+			return nil, fmt.Errorf("failed to decode health record components: %w", err)
+		}
+		if raw != "" {
+			record.Result = json.RawMessage(raw)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// GetHealthStatusChanges returns every component status change of the given
+// kind since the given time, oldest first. The first record in the window is
+// compared with the one before it, so a change right at the start is kept
+func (ts *TaskStore) GetHealthStatusChanges(kind string, since time.Time) ([]HealthStatusChange, error) {
+	records, err := ts.GetHealthRecords(kind, since, false)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := make(map[string]string)
+	var baseline string
+	err = ts.db.DB().QueryRow(`
+		SELECT components FROM health_records
+		WHERE kind = ? AND checked_at < ? ORDER BY id DESC LIMIT 1`, kind, since).Scan(&baseline)
+	if err == nil {
+		var components []HealthComponentStatus
+		if json.Unmarshal([]byte(baseline), &components) == nil {
+			for _, c := range components {
+				previous[c.Name] = c.Status
+			}
+		}
+	}
+
+	var changes []HealthStatusChange
+	for _, record := range records {
+		for _, c := range record.Components {
+			from, seen := previous[c.Name]
+			previous[c.Name] = c.Status
+			if !seen || from == c.Status {
+				continue
+			}
+			changes = append(changes, HealthStatusChange{
+				Kind:      kind,
+				Component: c.Name,
+				From:      from,
+				To:        c.Status,
+				Message:   c.Message,
+				At:        record.CheckedAt,
+			})
+		}
+	}
+	return changes, nil
+}
+
+// GetAllHealthStatusChanges returns the status changes of health checks and
+// diagnostics since the given time, merged in time order
+func (ts *TaskStore) GetAllHealthStatusChanges(since time.Time) ([]HealthStatusChange, error) {
+	var all []HealthStatusChange
+	for _, kind := range []string{HealthRecordCheck, HealthRecordDiagnostics} {
+		changes, err := ts.GetHealthStatusChanges(kind, since)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, changes...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].At.Before(all[j].At)
+	})
+	return all, nil
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"telegram-archive-bot/utils"
+)
+
+// ErrNotLeader is returned by Fence when this instance no longer holds the lease
+var ErrNotLeader = errors.New("not the current leader")
+
+// LeaderElector elects a single active instance among bot processes sharing
+// the same database using a renewable lease with a fencing token
+type LeaderElector struct {
+	db       *Database
+	name     string
+	holderID string
+	ttl      time.Duration
+	logger   *utils.Logger
+
+	mutex        sync.RWMutex
+	isLeader     bool
+	fencingToken int64
+	lastRenewal  time.Time
+}
+
+// NewLeaderElector creates an elector for the named lease
+func NewLeaderElector(db *Database, name, holderID string, ttl time.Duration, logger *utils.Logger) *LeaderElector {
+	if ttl <= 0 {
+		ttl = 15 * time.Second
+	}
+	return &LeaderElector{
+		db:       db,
+		name:     name,
+		holderID: holderID,
+		ttl:      ttl,
+		logger:   logger,
+	}
+}
+
+// TryAcquire takes the lease if it is free or expired, or renews it if already
+// held; the fencing token increases every time leadership changes hands
+func (le *LeaderElector) TryAcquire() (bool, error) {
+	now := time.Now()
+	expiresAt := now.Add(le.ttl).UnixMilli()
+
+	_, err := le.db.DB().Exec(`
+		INSERT INTO leader_leases (name, holder_id, fencing_token, expires_at, acquired_at, renewed_at)
+		VALUES (?, ?, 1, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			fencing_token = CASE WHEN leader_leases.holder_id = excluded.holder_id
+				THEN leader_leases.fencing_token ELSE leader_leases.fencing_token + 1 END,
+			acquired_at = CASE WHEN leader_leases.holder_id = excluded.holder_id
+				THEN leader_leases.acquired_at ELSE excluded.acquired_at END,
+			holder_id = excluded.holder_id,
+			expires_at = excluded.expires_at,
+			renewed_at = excluded.renewed_at
+		WHERE leader_leases.holder_id = excluded.holder_id OR leader_leases.expires_at < ?`,
+		le.name, le.holderID, expiresAt, now, now, now.UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire leader lease: %w", err)
+	}
+
+	holder, token, err := le.currentHolder()
+	if err != nil {
+		return false, err
+	}
+
+	le.mutex.Lock()
+	defer le.mutex.Unlock()
+
+	le.isLeader = holder == le.holderID
+	if le.isLeader {
+		le.fencingToken = token
+		le.lastRenewal = now
+	}
+	return le.isLeader, nil
+}
+
+// Release gives up the lease so another instance can take over immediately
+func (le *LeaderElector) Release() error {
+	le.mutex.Lock()
+	le.isLeader = false
+	le.mutex.Unlock()
+
+	_, err := le.db.DB().Exec(`UPDATE leader_leases SET expires_at = 0 WHERE name = ? AND holder_id = ?`,
+		le.name, le.holderID)
+	if err != nil {
+		return fmt.Errorf("failed to release leader lease: %w", err)
+	}
+	return nil
+}
+
+// IsLeader reports whether this instance currently believes it holds the lease
+func (le *LeaderElector) IsLeader() bool {
+	le.mutex.RLock()
+	defer le.mutex.RUnlock()
+	return le.isLeader && time.Since(le.lastRenewal) < le.ttl
+}
+
+// FencingToken returns the token issued when this instance became leader
+func (le *LeaderElector) FencingToken() int64 {
+	le.mutex.RLock()
+	defer le.mutex.RUnlock()
+	return le.fencingToken
+}
+
+// Fence verifies against the database that this instance still holds the lease
+// with the same fencing token; call it before side effects that must not be
+// performed by a deposed leader
+func (le *LeaderElector) Fence() error {
+	le.mutex.RLock()
+	token := le.fencingToken
+	le.mutex.RUnlock()
+
+	if !le.IsLeader() {
+		return ErrNotLeader
+	}
+
+	var holder string
+	var currentToken, expiresAt int64
+	err := le.db.DB().QueryRow(`SELECT holder_id, fencing_token, expires_at FROM leader_leases WHERE name = ?`, le.name).
+		Scan(&holder, &currentToken, &expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to check leader lease: %w", err)
+	}
+
+	if holder != le.holderID || currentToken != token || expiresAt < time.Now().UnixMilli() {
+		return ErrNotLeader
+	}
+	return nil
+}
+
+// Run campaigns for leadership until ctx is cancelled. onElected is started in
+// its own goroutine with a context that is cancelled as soon as leadership is lost
+func (le *LeaderElector) Run(ctx context.Context, onElected func(ctx context.Context)) {
+	interval := le.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var term *leaderTerm
+
+	demote := func(reason string) {
+		if term == nil {
+			return
+		}
+		le.logger.WithField("holder_id", le.holderID).
+			WithField("reason", reason).
+			Warn("Leadership lost, stopping leader-only components")
+		term.cancel()
+		<-term.done
+		term = nil
+	}
+
+	for {
+		leader, err := le.TryAcquire()
+		if err != nil {
+			le.logger.WithError(err).Warn("Leader lease renewal failed")
+			// Keep leading only while the last successful renewal is still valid
+			if term != nil && !le.IsLeader() {
+				demote("lease renewal failed")
+			}
+		} else if leader && term == nil {
+			le.logger.WithField("holder_id", le.holderID).
+				WithField("fencing_token", le.FencingToken()).
+				Info("Elected leader")
+			term = startLeaderTerm(ctx, onElected)
+		} else if !leader && term != nil {
+			demote("lease taken by another instance")
+		}
+
+		select {
+		case <-ctx.Done():
+			demote("shutting down")
+			if le.IsLeader() {
+				if err := le.Release(); err != nil {
+					le.logger.WithError(err).Warn("Failed to release leader lease")
+				}
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// leaderTerm tracks the leader-only work started for one period of leadership
+type leaderTerm struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func startLeaderTerm(ctx context.Context, onElected func(ctx context.Context)) *leaderTerm {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	term := &leaderTerm{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(term.done)
+		onElected(leaderCtx)
+	}()
+	return term
+}
+
+// currentHolder reads the lease holder and fencing token
+func (le *LeaderElector) currentHolder() (string, int64, error) {
+	var holder string
+	var token int64
+	err := le.db.DB().QueryRow(`SELECT holder_id, fencing_token FROM leader_leases WHERE name = ?`, le.name).
+		Scan(&holder, &token)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read leader lease: %w", err)
+	}
+	return holder, token, nil
+}
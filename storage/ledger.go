@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/utils"
+)
+
+// GetUnrecordedLedgerRows returns up to limit tasks completed since the given
+// time that have not been appended to the named ledger, oldest first
+func (ts *TaskStore) GetUnrecordedLedgerRows(sink string, since time.Time, limit int) ([]utils.LedgerRow, error) {
+	rows, err := ts.db.DB().Query(`
+		SELECT t.id, COALESCE(s.short_id, ''), t.file_name, t.file_size, t.created_at, t.completed_at,
+			COALESCE(r.credentials, 0),
+			(SELECT COUNT(*) FROM task_conversion_results x WHERE x.result_id = r.id)
+		FROM tasks t
+		LEFT JOIN task_short_ids s ON s.task_id = t.id
+		LEFT JOIN task_conversion_results tc ON tc.task_id = t.id
+		LEFT JOIN conversion_results r ON r.id = tc.result_id
+		LEFT JOIN ledger_entries l ON l.task_id = t.id AND l.sink = ?
+		WHERE t.status = ? AND t.completed_at >= ? AND l.task_id IS NULL
+		ORDER BY t.completed_at
+		LIMIT ?`,
+		sink, models.TaskStatusCompleted, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unrecorded ledger rows: %w", err)
+	}
+	defer rows.Close()
+
+	var result []utils.LedgerRow
+	for rows.Next() {
+		var row utils.LedgerRow
+		var createdAt time.Time
+		if err := rows.Scan(&row.TaskID, &row.ShortID, &row.FileName, &row.SizeBytes, &createdAt,
+			&row.CompletedAt, &row.Credentials, &row.PassTasks); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger row: %w", err)
+		}
+		row.Duration = row.CompletedAt.Sub(createdAt)
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// MarkLedgerRecorded records that the tasks were appended to the named ledger
+func (ts *TaskStore) MarkLedgerRecorded(sink string, taskIDs []string) error {
+	tx, err := ts.db.DB().Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, taskID := range taskIDs {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO ledger_entries (task_id, sink, recorded_at) VALUES (?, ?, ?)`,
+			taskID, sink, now); err != nil {
+			return fmt.Errorf("failed to mark ledger row for task %s: %w", taskID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit ledger rows: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Kinds of bot messages linked to a task
+const (
+	TaskMessageConfirmation = "confirmation"
+	TaskMessageCompletion   = "completion"
+	TaskMessageFailure      = "failure"
+)
+
+// TaskOrigin records where a task was submitted so that every later message
+// about it can be routed back to the same chat and forum topic
+type TaskOrigin struct {
+	TaskID          string
+	ChatID          int64
+	ChatUsername    string
+	MessageThreadID int
+	MessageID       int
+	CreatedAt       time.Time
+}
+
+// SaveTaskOrigin stores the chat, forum topic and message a task came from
+func (ts *TaskStore) SaveTaskOrigin(origin *TaskOrigin) error {
+	if origin.CreatedAt.IsZero() {
+		origin.CreatedAt = time.Now()
+	}
+
+	_, err := ts.db.DB().Exec(`
+		INSERT INTO task_origins (task_id, chat_id, chat_username, message_thread_id, message_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(task_id) DO UPDATE SET
+			chat_id = excluded.chat_id,
+			chat_username = excluded.chat_username,
+			message_thread_id = excluded.message_thread_id,
+			message_id = excluded.message_id`,
+		origin.TaskID, origin.ChatID, origin.ChatUsername, origin.MessageThreadID, origin.MessageID, origin.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save task origin: %w", err)
+	}
+	return nil
+}
+
+// GetTaskOrigin returns where a task was submitted, or nil for tasks created
+// before origins were tracked
+func (ts *TaskStore) GetTaskOrigin(taskID string) (*TaskOrigin, error) {
+	origin := &TaskOrigin{}
+	err := ts.db.DB().QueryRow(`
+		SELECT task_id, chat_id, COALESCE(chat_username, ''), message_thread_id, message_id, created_at
+		FROM task_origins WHERE task_id = ?`, taskID).
+		Scan(&origin.TaskID, &origin.ChatID, &origin.ChatUsername, &origin.MessageThreadID, &origin.MessageID, &origin.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task origin: %w", err)
+	}
+	return origin, nil
+}
+
+// SaveTaskMessage links a message the bot sent to the task it is about
+func (ts *TaskStore) SaveTaskMessage(taskID string, chatID int64, messageID int, kind string) error {
+	_, err := ts.db.DB().Exec(`
+		INSERT INTO task_messages (task_id, chat_id, message_id, kind, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		taskID, chatID, messageID, kind, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save task message link: %w", err)
+	}
+	return nil
+}
+
+// GetTaskIDByMessage finds the task a message belongs to, whether it is the
+// uploaded document itself or a message the bot sent about it. Batched
+// completion notices cover several tasks; the first linked task is returned
+func (ts *TaskStore) GetTaskIDByMessage(chatID int64, messageID int) (string, error) {
+	var taskID string
+	err := ts.db.DB().QueryRow(`
+		SELECT task_id FROM task_origins WHERE chat_id = ? AND message_id = ?
+		UNION ALL
+		SELECT task_id FROM (
+			SELECT task_id FROM task_messages WHERE chat_id = ? AND message_id = ? ORDER BY id ASC
+		)
+		LIMIT 1`, chatID, messageID, chatID, messageID).Scan(&taskID)
+	if err == sql.ErrNoRows {
+		return "", ErrTaskNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up task by message: %w", err)
+	}
+	return taskID, nil
+}
+
+// CountTaskMessages returns how many tasks a bot message is linked to
+func (ts *TaskStore) CountTaskMessages(chatID int64, messageID int) (int, error) {
+	var count int
+	err := ts.db.DB().QueryRow(`SELECT COUNT(DISTINCT task_id) FROM task_messages WHERE chat_id = ? AND message_id = ?`,
+		chatID, messageID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count task message links: %w", err)
+	}
+	return count, nil
+}
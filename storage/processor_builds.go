@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ProcessorBuildRecord is the last verified build of an extraction stage
+type ProcessorBuildRecord struct {
+	Name        string
+	SourceHash  string
+	BotVersion  string
+	FirstSeenAt time.Time
+	VerifiedAt  time.Time
+}
+
+// GetProcessorBuild returns the recorded build of a processor, or nil if it was never recorded
+func (ts *TaskStore) GetProcessorBuild(name string) (*ProcessorBuildRecord, error) {
+	record := &ProcessorBuildRecord{Name: name}
+	err := ts.db.DB().QueryRow(`
+		SELECT source_hash, bot_version, first_seen_at, verified_at
+		FROM processor_builds WHERE name = ?`, name).
+		Scan(&record.SourceHash, &record.BotVersion, &record.FirstSeenAt, &record.VerifiedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get processor build: %w", err)
+	}
+	return record, nil
+}
+
+// SaveProcessorBuild records a verified processor build; first_seen_at only
+// changes when the source hash does
+func (ts *TaskStore) SaveProcessorBuild(name, sourceHash, botVersion string, verifiedAt time.Time) error {
+	_, err := ts.db.DB().Exec(`
+		INSERT INTO processor_builds (name, source_hash, bot_version, first_seen_at, verified_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			first_seen_at = CASE WHEN processor_builds.source_hash = excluded.source_hash
+				THEN processor_builds.first_seen_at ELSE excluded.first_seen_at END,
+			source_hash = excluded.source_hash,
+			bot_version = excluded.bot_version,
+			verified_at = excluded.verified_at`,
+		name, sourceHash, botVersion, verifiedAt, verifiedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save processor build: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// QuarantineStatus is where a flagged file is in the quarantine retry queue
+type QuarantineStatus string
+
+const (
+	QuarantineStatusPending     QuarantineStatus = "PENDING"     // Not moved yet, will be retried
+	QuarantineStatusQuarantined QuarantineStatus = "QUARANTINED" // Moved to a quarantine location
+	QuarantineStatusMissing     QuarantineStatus = "MISSING"     // Source vanished before it could be moved
+	QuarantineStatusFailed      QuarantineStatus = "FAILED"      // Retries exhausted, needs manual action
+)
+
+// QuarantineEntry tracks a flagged file until it is safely quarantined
+type QuarantineEntry struct {
+	TaskID         string
+	FileName       string
+	FileHash       string
+	UserID         int64
+	SourcePath     string
+	QuarantinePath string
+	Reason         string
+	ThreatLevel    string
+	Findings       []string // Security warnings that flagged the file
+	Status         QuarantineStatus
+	Attempts       int
+	LastError      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// SaveQuarantineEntry records a flagged file, replacing any earlier entry of the task
+func (ts *TaskStore) SaveQuarantineEntry(entry *QuarantineEntry) error {
+	now := time.Now()
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = now
+	}
+	entry.UpdatedAt = now
+
+	findings, err := json.Marshal(entry.Findings)
+	if err != nil {
+		return fmt.Errorf("failed to encode quarantine findings: %w", err)
+	}
+
+	_, err = ts.db.DB().Exec(`
+		INSERT INTO quarantine_queue (task_id, file_name, file_hash, user_id, source_path,
+			quarantine_path, reason, threat_level, findings, status, attempts, last_error,
+			created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(task_id) DO UPDATE SET
+			source_path = excluded.source_path,
+			quarantine_path = excluded.quarantine_path,
+			reason = excluded.reason,
+			threat_level = excluded.threat_level,
+			findings = excluded.findings,
+			status = excluded.status,
+			attempts = excluded.attempts,
+			last_error = excluded.last_error,
+			updated_at = excluded.updated_at`,
+		entry.TaskID, entry.FileName, entry.FileHash, entry.UserID, entry.SourcePath,
+		entry.QuarantinePath, entry.Reason, entry.ThreatLevel, string(findings), string(entry.Status),
+		entry.Attempts, entry.LastError, entry.CreatedAt, entry.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save quarantine entry: %w", err)
+	}
+	return nil
+}
+
+// GetQuarantineEntries returns the entries with the given status, oldest first
+func (ts *TaskStore) GetQuarantineEntries(status QuarantineStatus) ([]*QuarantineEntry, error) {
+	rows, err := ts.db.DB().Query(`
+		SELECT task_id, file_name, file_hash, user_id, source_path, quarantine_path, reason,
+			threat_level, findings, status, attempts, last_error, created_at, updated_at
+		FROM quarantine_queue WHERE status = ? ORDER BY created_at`, string(status))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quarantine queue: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*QuarantineEntry
+	for rows.Next() {
+		entry := &QuarantineEntry{}
+		var status, findings string
+		if err := rows.Scan(&entry.TaskID, &entry.FileName, &entry.FileHash, &entry.UserID,
+			&entry.SourcePath, &entry.QuarantinePath, &entry.Reason, &entry.ThreatLevel, &findings,
+			&status, &entry.Attempts, &entry.LastError, &entry.CreatedAt, &entry.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan quarantine entry: %w", err)
+		}
+		entry.Status = QuarantineStatus(status)
+		if findings != "" {
+			if err := json.Unmarshal([]byte(findings), &entry.Findings); err != nil {
+				return nil, fmt.Errorf("failed to decode quarantine findings: %w", err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// CountQuarantineEntries returns the number of entries per status
+func (ts *TaskStore) CountQuarantineEntries() (map[QuarantineStatus]int, error) {
+	rows, err := ts.db.DB().Query(`SELECT status, COUNT(*) FROM quarantine_queue GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count quarantine queue: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[QuarantineStatus]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan quarantine count: %w", err)
+		}
+		counts[QuarantineStatus(status)] = count
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrUnsupportedSort is returned when a query asks to sort by an unknown key
+var ErrUnsupportedSort = errors.New("unsupported sort key")
+
+// selectQuery builds a SELECT from composable filters, an ordering and a
+// page, and the matching COUNT(*). Values are always bound with plain ?
+// placeholders; only column names chosen by the caller are written into the SQL
+type selectQuery struct {
+	table   string
+	columns string
+	where   []string
+	args    []interface{}
+	orderBy string
+	limit   int
+	offset  int
+}
+
+// newSelectQuery starts a query of columns from table
+func newSelectQuery(table, columns string) *selectQuery {
+	return &selectQuery{table: table, columns: columns}
+}
+
+// Where adds a condition with one ? per argument; conditions are ANDed
+func (q *selectQuery) Where(condition string, args ...interface{}) *selectQuery {
+	q.where = append(q.where, condition)
+	q.args = append(q.args, args...)
+	return q
+}
+
+// WhereIf adds the condition only when ok, for optional filters
+func (q *selectQuery) WhereIf(ok bool, condition string, args ...interface{}) *selectQuery {
+	if ok {
+		q.Where(condition, args...)
+	}
+	return q
+}
+
+// WhereIn adds "column IN (...)" with one ? per value; no values adds nothing
+func (q *selectQuery) WhereIn(column string, values ...interface{}) *selectQuery {
+	if len(values) == 0 {
+		return q
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	return q.Where(column+" IN ("+placeholders+")", values...)
+}
+
+// OrderBy sorts by column, breaking ties by id so pages never overlap.
+// column must come from the caller's list of sortable columns
+func (q *selectQuery) OrderBy(column string, ascending bool) *selectQuery {
+	direction := "DESC"
+	if ascending {
+		direction = "ASC"
+	}
+	q.orderBy = fmt.Sprintf("%s %s, id %s", column, direction, direction)
+	return q
+}
+
+// Page limits the rows returned; a limit of 0 returns every row after offset
+func (q *selectQuery) Page(limit, offset int) *selectQuery {
+	q.limit, q.offset = limit, offset
+	return q
+}
+
+// whereClause renders the conditions, "" when there are none
+func (q *selectQuery) whereClause() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.where, " AND ")
+}
+
+// SQL returns the SELECT and its arguments
+func (q *selectQuery) SQL() (string, []interface{}) {
+	query := fmt.Sprintf("SELECT %s FROM %s%s", q.columns, q.table, q.whereClause())
+	args := append([]interface{}{}, q.args...)
+
+	if q.orderBy != "" {
+		query += " ORDER BY " + q.orderBy
+	}
+	// SQLite only accepts OFFSET after a LIMIT; -1 means no limit
+	if q.limit > 0 || q.offset > 0 {
+		limit := q.limit
+		if limit <= 0 {
+			limit = -1
+		}
+		query += " LIMIT ?"
+		args = append(args, limit)
+		if q.offset > 0 {
+			query += " OFFSET ?"
+			args = append(args, q.offset)
+		}
+	}
+	return query, args
+}
+
+// CountSQL returns a COUNT(*) over the same filters, ignoring order and page
+func (q *selectQuery) CountSQL() (string, []interface{}) {
+	return fmt.Sprintf("SELECT COUNT(*) FROM %s%s", q.table, q.whereClause()), q.args
+}
+
+// sortColumn maps a requested sort key to its column, falling back to
+// defaultColumn for ""; unknown keys are an error rather than SQL
+func sortColumn(sortBy string, columns map[string]string, defaultColumn string) (string, error) {
+	if sortBy == "" {
+		return defaultColumn, nil
+	}
+	column, ok := columns[sortBy]
+	if !ok {
+		keys := make([]string, 0, len(columns))
+		for key := range columns {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return "", fmt.Errorf("%w %q (supported: %s)", ErrUnsupportedSort, sortBy, strings.Join(keys, ", "))
+	}
+	return column, nil
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/utils"
+)
+
+// QueueOrder sets the order download workers claim PENDING tasks in
+type QueueOrder struct {
+	Mode string // utils.QueueOrderFIFO or utils.QueueOrderShortestFirst; empty is FIFO
+	// MaxWait bounds how long shortest first holds a task back: once it has
+	// waited that long it goes ahead of the tasks estimated to be shorter
+	MaxWait time.Duration
+}
+
+// SetQueueOrder sets the claim order; call before the download workers start
+func (ts *TaskStore) SetQueueOrder(order QueueOrder) {
+	ts.order = order
+}
+
+// GetQueueOrder returns the claim order
+func (ts *TaskStore) GetQueueOrder() QueueOrder {
+	return ts.order
+}
+
+// SetEstimatedCost stores a task's estimated processing cost, which orders
+// the queue under shortest first
+func (ts *TaskStore) SetEstimatedCost(taskID string, cost time.Duration) error {
+	_, err := ts.db.DB().Exec(`UPDATE tasks SET estimated_cost_ms = ? WHERE id = ?`, cost.Milliseconds(), taskID)
+	if err != nil {
+		return fmt.Errorf("failed to set estimated cost: %w", err)
+	}
+	return nil
+}
+
+// pendingOrder returns the ORDER BY expression for PENDING tasks and its
+// arguments. Under shortest first, tasks past MaxWait and tasks without an
+// estimate sort as cost 0, so they keep their FIFO order ahead of the rest
+func (ts *TaskStore) pendingOrder() (string, []interface{}) {
+	if ts.order.Mode != utils.QueueOrderShortestFirst {
+		return "priority DESC, created_at ASC", nil
+	}
+	return `priority DESC,
+		CASE WHEN created_at <= ? OR COALESCE(estimated_cost_ms, 0) <= 0 THEN 0 ELSE estimated_cost_ms END ASC,
+		created_at ASC`, []interface{}{time.Now().Add(-ts.order.MaxWait)}
+}
+
+// QueuedFile is the type and size of a task still to be processed
+type QueuedFile struct {
+	TaskID   string
+	Status   models.TaskStatus
+	FileType string
+	FileSize int64
+}
+
+// GetQueuedFiles returns the PENDING tasks in claim order, followed by the
+// DOWNLOADING and DOWNLOADED ones
+func (ts *TaskStore) GetQueuedFiles() ([]QueuedFile, error) {
+	pending, err := ts.pendingFiles()
+	if err != nil {
+		return nil, err
+	}
+	active, err := ts.queryQueuedFiles(`SELECT id, status, file_type, file_size FROM tasks WHERE status IN (?, ?) ORDER BY created_at ASC`,
+		models.TaskStatusDownloading, models.TaskStatusDownloaded)
+	if err != nil {
+		return nil, err
+	}
+	return append(pending, active...), nil
+}
+
+// pendingFiles returns the PENDING tasks in claim order
+func (ts *TaskStore) pendingFiles() ([]QueuedFile, error) {
+	order, args := ts.pendingOrder()
+	return ts.queryQueuedFiles(`SELECT id, status, file_type, file_size FROM tasks WHERE status = ? ORDER BY `+order,
+		append([]interface{}{models.TaskStatusPending}, args...)...)
+}
+
+func (ts *TaskStore) queryQueuedFiles(query string, args ...interface{}) ([]QueuedFile, error) {
+	rows, err := ts.db.DB().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query queued files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []QueuedFile
+	for rows.Next() {
+		var f QueuedFile
+		if err := rows.Scan(&f.TaskID, &f.Status, &f.FileType, &f.FileSize); err != nil {
+			return nil, fmt.Errorf("failed to scan queued file: %w", err)
+		}
+		files = append(files, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return files, nil
+}
+
+// pendingAhead returns the PENDING tasks claimed before a task, all of them
+// when the task is not PENDING
+func (ts *TaskStore) pendingAhead(taskID string) ([]QueuedFile, error) {
+	pending, err := ts.pendingFiles()
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range pending {
+		if f.TaskID == taskID {
+			return pending[:i], nil
+		}
+	}
+	return pending, nil
+}
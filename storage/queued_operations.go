@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"telegram-archive-bot/utils"
+)
+
+// SaveQueuedOperation inserts or updates an operation deferred by a dependency
+// outage; it expires MaxWaitTime after it was first queued
+func (ts *TaskStore) SaveQueuedOperation(op utils.QueuedOperation) error {
+	parameters, err := json.Marshal(op.Parameters)
+	if err != nil {
+		return fmt.Errorf("failed to encode operation parameters: %w", err)
+	}
+
+	_, err = ts.db.DB().Exec(`
+		INSERT INTO queued_operations
+			(id, dependency_name, operation, task_id, stage, parameters, queued_at, expires_at, attempts, last_error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET attempts = excluded.attempts, last_error = excluded.last_error`,
+		op.ID, op.DependencyName, op.Operation, op.TaskID, op.Stage, string(parameters),
+		op.QueuedAt, op.QueuedAt.Add(op.MaxWaitTime), op.Attempts, op.LastError)
+	if err != nil {
+		return fmt.Errorf("failed to save queued operation: %w", err)
+	}
+	return nil
+}
+
+// DeleteQueuedOperation removes an operation that ran, failed for good or expired
+func (ts *TaskStore) DeleteQueuedOperation(id string) error {
+	if _, err := ts.db.DB().Exec(`DELETE FROM queued_operations WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete queued operation: %w", err)
+	}
+	return nil
+}
+
+// LoadQueuedOperations returns the operations waiting on a dependency, oldest first
+func (ts *TaskStore) LoadQueuedOperations(dependencyName string) ([]utils.QueuedOperation, error) {
+	rows, err := ts.db.DB().Query(`
+		SELECT id, dependency_name, operation, task_id, stage, parameters, queued_at, expires_at, attempts, last_error
+		FROM queued_operations
+		WHERE dependency_name = ?
+		ORDER BY queued_at`, dependencyName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load queued operations: %w", err)
+	}
+	defer rows.Close()
+
+	var ops []utils.QueuedOperation
+	for rows.Next() {
+		var op utils.QueuedOperation
+		var parameters string
+		var expiresAt time.Time
+		if err := rows.Scan(&op.ID, &op.DependencyName, &op.Operation, &op.TaskID, &op.Stage,
+			&parameters, &op.QueuedAt, &expiresAt, &op.Attempts, &op.LastError); err != nil {
+			return nil, fmt.Errorf("failed to scan queued operation: %w", err)
+		}
+		if err := json.Unmarshal([]byte(parameters), &op.Parameters); err != nil {
+			return nil, fmt.Errorf("failed to decode parameters of queued operation %s: %w", op.ID, err)
+		}
+		op.MaxWaitTime = expiresAt.Sub(op.QueuedAt)
+		ops = append(ops, op)
+	}
+	return ops, rows.Err()
+}
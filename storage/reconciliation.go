@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/utils"
+)
+
+// Reconciliation triggers
+const (
+	ReconcileStartup  = "startup"
+	ReconcileFailover = "failover" // This instance was elected leader
+	ReconcileRestore  = "restore"  // The database was restored from a backup
+)
+
+// reconcileFileLimit caps the files listed per kind in a report; the counts
+// always cover all of them
+const reconcileFileLimit = 500
+
+// reconcileCandidateDirs are scanned for files no unfinished task accounts for
+var reconcileCandidateDirs = []string{
+	"app/extraction/files/all",
+	"app/extraction/files/fastpath",
+	"app/extraction/files/txt",
+}
+
+// ReconciledTask is a task whose status or files recovery changed
+type ReconciledTask struct {
+	TaskID     string            `json:"task_id"`
+	FileName   string            `json:"file_name,omitempty"`
+	FromStatus models.TaskStatus `json:"from_status"`
+	ToStatus   models.TaskStatus `json:"to_status"`
+	Path       string            `json:"path,omitempty"` // File the task was re-linked to
+	Reason     string            `json:"reason"`
+}
+
+// ReconciledFile is a file recovery found without a task
+type ReconciledFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Reason  string    `json:"reason"`
+}
+
+// ReconciliationReport is the state recovery brought the system into: which
+// tasks it reset, re-linked to their files or failed, which leftover files it
+// removed and which files no task accounts for, to be reviewed and quarantined
+type ReconciliationReport struct {
+	ID                   int64            `json:"id"`
+	Trigger              string           `json:"trigger"`
+	InstanceID           string           `json:"instance_id,omitempty"`
+	StartedAt            time.Time        `json:"started_at"`
+	CompletedAt          time.Time        `json:"completed_at"`
+	TasksExamined        int              `json:"tasks_examined"`
+	TasksReady           int              `json:"tasks_ready"`  // DOWNLOADED tasks whose file was where it should be
+	TasksQueued          int              `json:"tasks_queued"` // PENDING tasks left for the download workers
+	TasksReset           []ReconciledTask `json:"tasks_reset,omitempty"`
+	TasksRelinked        []ReconciledTask `json:"tasks_relinked,omitempty"`
+	TasksFailed          []ReconciledTask `json:"tasks_failed,omitempty"`
+	OrphanedFiles        []ReconciledFile `json:"orphaned_files,omitempty"`
+	OrphanedFileCount    int              `json:"orphaned_file_count"`
+	QuarantineCandidates []ReconciledFile `json:"quarantine_candidates,omitempty"`
+	CandidateCount       int              `json:"candidate_count"`
+	Errors               []string         `json:"errors,omitempty"`
+}
+
+// Changed reports whether recovery changed or found anything worth reviewing
+func (r *ReconciliationReport) Changed() bool {
+	return len(r.TasksReset) > 0 || len(r.TasksRelinked) > 0 || len(r.TasksFailed) > 0 ||
+		r.OrphanedFileCount > 0 || r.CandidateCount > 0 || len(r.Errors) > 0
+}
+
+// taskChanged records a task recovery moved to another status
+func (r *ReconciliationReport) taskChanged(task *models.Task, to models.TaskStatus, reason string) {
+	if r == nil {
+		return
+	}
+	entry := ReconciledTask{TaskID: task.ID, FileName: task.FileName, FromStatus: task.Status, ToStatus: to, Reason: reason}
+	if to == models.TaskStatusFailed {
+		r.TasksFailed = append(r.TasksFailed, entry)
+	} else {
+		r.TasksReset = append(r.TasksReset, entry)
+	}
+}
+
+// taskRelinked records a task recovery connected to a file it found
+func (r *ReconciliationReport) taskRelinked(task *models.Task, to models.TaskStatus, path, reason string) {
+	if r == nil {
+		return
+	}
+	r.TasksRelinked = append(r.TasksRelinked, ReconciledTask{
+		TaskID: task.ID, FileName: task.FileName, FromStatus: task.Status, ToStatus: to, Path: path, Reason: reason,
+	})
+}
+
+// fileOrphaned records a leftover file recovery cleaned up
+func (r *ReconciliationReport) fileOrphaned(path string, info os.FileInfo, reason string) {
+	if r == nil {
+		return
+	}
+	r.OrphanedFileCount++
+	if len(r.OrphanedFiles) < reconcileFileLimit {
+		r.OrphanedFiles = append(r.OrphanedFiles, ReconciledFile{Path: path, Size: info.Size(), ModTime: info.ModTime(), Reason: reason})
+	}
+}
+
+// Reconcile runs crash recovery and orphaned file cleanup, looks for files no
+// unfinished task accounts for and saves the report of all of it. A step that
+// fails is noted in the report and the others still run
+func (rs *RecoveryService) Reconcile(ctx context.Context, trigger, instanceID string) *ReconciliationReport {
+	report := &ReconciliationReport{Trigger: trigger, InstanceID: instanceID, StartedAt: time.Now()}
+
+	if err := rs.recoverIncompleteTasks(ctx, report); err != nil {
+		rs.logger.WithError(err).Error("Crash recovery failed, continuing with startup")
+		report.Errors = append(report.Errors, fmt.Sprintf("task recovery: %v", err))
+	}
+	if err := rs.cleanupOrphanedFiles(report); err != nil {
+		rs.logger.WithError(err).Warn("Orphaned file cleanup failed")
+		report.Errors = append(report.Errors, fmt.Sprintf("orphaned file cleanup: %v", err))
+	}
+	if err := rs.findQuarantineCandidates(report); err != nil {
+		rs.logger.WithError(err).Warn("Failed to look for unaccounted files")
+		report.Errors = append(report.Errors, fmt.Sprintf("unaccounted files: %v", err))
+	}
+	report.CompletedAt = time.Now()
+
+	if err := rs.taskStore.SaveReconciliationReport(report); err != nil {
+		rs.logger.WithError(err).Warn("Failed to save reconciliation report")
+	}
+
+	rs.logger.WithField("trigger", trigger).
+		WithField("tasks_examined", report.TasksExamined).
+		WithField("tasks_reset", len(report.TasksReset)).
+		WithField("tasks_relinked", len(report.TasksRelinked)).
+		WithField("tasks_failed", len(report.TasksFailed)).
+		WithField("orphaned_files", report.OrphanedFileCount).
+		WithField("quarantine_candidates", report.CandidateCount).
+		Info("Reconciliation completed")
+	return report
+}
+
+// findQuarantineCandidates lists the files in the Local Bot API temp
+// directory and the extraction inputs that no unfinished task refers to.
+// They are left in place: processing them would mix content of unknown
+// origin into the output, so an admin decides what happens to them
+func (rs *RecoveryService) findQuarantineCandidates(report *ReconciliationReport) error {
+	refs, err := rs.taskStore.unfinishedTaskFileRefs()
+	if err != nil {
+		return err
+	}
+
+	dirs := append([]string(nil), reconcileCandidateDirs...)
+	if tempPath, err := rs.botAPIPathManager.GetTempPath(); err == nil {
+		dirs = append(dirs, tempPath)
+	}
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if refs.accounts(path) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			report.CandidateCount++
+			if len(report.QuarantineCandidates) < reconcileFileLimit {
+				report.QuarantineCandidates = append(report.QuarantineCandidates, ReconciledFile{
+					Path: path, Size: info.Size(), ModTime: info.ModTime(), Reason: "not referenced by any unfinished task",
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// taskFileRefs are the files unfinished tasks refer to
+type taskFileRefs struct {
+	paths   map[string]bool
+	names   map[string]bool
+	taskIDs []string
+}
+
+// accounts reports whether a file belongs to an unfinished task: by its
+// recorded path, its stored name, or a name carrying the task ID as the
+// Local Bot API temp files and renamed duplicates do
+func (r *taskFileRefs) accounts(path string) bool {
+	if r.paths[filepath.Clean(path)] || r.names[filepath.Base(path)] {
+		return true
+	}
+	name := filepath.Base(path)
+	for _, id := range r.taskIDs {
+		if strings.Contains(name, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// unfinishedTaskFileRefs collects the files of PENDING, DOWNLOADING and
+// DOWNLOADED tasks
+func (ts *TaskStore) unfinishedTaskFileRefs() (*taskFileRefs, error) {
+	rows, err := ts.db.DB().Query(`
+		SELECT id, file_name, COALESCE(local_api_path, ''), COALESCE(storage_path, '')
+		FROM tasks WHERE status IN (?, ?, ?)`,
+		models.TaskStatusPending, models.TaskStatusDownloading, models.TaskStatusDownloaded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unfinished tasks: %w", err)
+	}
+	defer rows.Close()
+
+	refs := &taskFileRefs{paths: make(map[string]bool), names: make(map[string]bool)}
+	for rows.Next() {
+		var id, fileName, localPath, storagePath string
+		if err := rows.Scan(&id, &fileName, &localPath, &storagePath); err != nil {
+			return nil, fmt.Errorf("failed to scan unfinished task: %w", err)
+		}
+		refs.taskIDs = append(refs.taskIDs, id)
+		refs.names[fileName] = true
+		refs.names[utils.SanitizeFileName(fileName)] = true
+		for _, path := range []string{localPath, storagePath} {
+			if path != "" {
+				refs.paths[filepath.Clean(path)] = true
+			}
+		}
+	}
+	return refs, rows.Err()
+}
+
+// SaveReconciliationReport stores a report and sets its ID
+func (ts *TaskStore) SaveReconciliationReport(report *ReconciliationReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode reconciliation report: %w", err)
+	}
+
+	result, err := ts.db.DB().Exec(`
+		INSERT INTO reconciliation_reports (trigger_name, instance_id, started_at, completed_at,
+			tasks_reset, tasks_relinked, tasks_failed, orphaned_files, quarantine_candidates, report)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		report.Trigger, report.InstanceID, report.StartedAt, report.CompletedAt,
+		len(report.TasksReset), len(report.TasksRelinked), len(report.TasksFailed),
+		report.OrphanedFileCount, report.CandidateCount, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save reconciliation report: %w", err)
+	}
+	report.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// GetRecentReconciliationReports returns the most recent reports, newest first
+func (ts *TaskStore) GetRecentReconciliationReports(limit int) ([]*ReconciliationReport, error) {
+	rows, err := ts.db.DB().Query(`
+		SELECT id, report FROM reconciliation_reports
+		ORDER BY started_at DESC
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reconciliation reports: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []*ReconciliationReport
+	for rows.Next() {
+		var id int64
+		var data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan reconciliation report: %w", err)
+		}
+		report := &ReconciliationReport{}
+		if err := json.Unmarshal([]byte(data), report); err != nil {
+			return nil, fmt.Errorf("failed to decode reconciliation report %d: %w", id, err)
+		}
+		report.ID = id
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
@@ -25,44 +25,64 @@ func NewRecoveryService(taskStore *TaskStore, logger *utils.Logger, botAPIPathMa
 	}
 }
 
-func (rs *RecoveryService) RecoverIncompleteTasks(ctx context.Context) error {
+// recoverIncompleteTasks brings PENDING and DOWNLOADED tasks in line with
+// the files on disk, recording what it changed in report
+func (rs *RecoveryService) recoverIncompleteTasks(ctx context.Context, report *ReconciliationReport) error {
 	rs.logger.Info("Starting crash recovery - checking for incomplete tasks")
 
-	// Get tasks that are not completed or failed
-	pendingTasks, err := rs.taskStore.GetByStatus(models.TaskStatusPending)
-	if err != nil {
-		return fmt.Errorf("failed to get pending tasks: %w", err)
+	// Requeue downloads whose worker died before the crash
+	if _, err := rs.reclaimExpiredLeases(report); err != nil {
+		rs.logger.WithError(err).Warn("Failed to reclaim expired task leases")
 	}
 
-	downloadedTasks, err := rs.taskStore.GetByStatus(models.TaskStatusDownloaded)
+	// Count tasks that are not completed or failed
+	pendingCount, err := rs.taskStore.GetTaskCountByStatus(models.TaskStatusPending)
 	if err != nil {
-		return fmt.Errorf("failed to get downloaded tasks: %w", err)
+		return fmt.Errorf("failed to count pending tasks: %w", err)
 	}
 
-	allIncompleteTasks := append(pendingTasks, downloadedTasks...)
+	downloadedCount, err := rs.taskStore.GetTaskCountByStatus(models.TaskStatusDownloaded)
+	if err != nil {
+		return fmt.Errorf("failed to count downloaded tasks: %w", err)
+	}
 
-	if len(allIncompleteTasks) == 0 {
+	if pendingCount+downloadedCount == 0 {
 		rs.logger.Info("No incomplete tasks found - system is clean")
 		return nil
 	}
 
-	rs.logger.WithField("incomplete_tasks", len(allIncompleteTasks)).
+	rs.logger.WithField("incomplete_tasks", pendingCount+downloadedCount).
 		Info("Found incomplete tasks, starting recovery process")
 
 	recoveredCount := 0
 	failedCount := 0
 
-	for _, task := range allIncompleteTasks {
+	// Tasks are streamed a page at a time. Recovering a task can move it
+	// between PENDING and DOWNLOADED, so each is only recovered once
+	recovered := make(map[string]bool)
+	recoverOnce := func(task *models.Task) error {
+		if recovered[task.ID] {
+			return nil
+		}
+		recovered[task.ID] = true
+
 		rs.logger.WithField("task_id", task.ID).
 			WithField("status", task.Status).
 			WithField("file_name", task.FileName).
 			Info("Recovering task")
 
-		if err := rs.recoverTask(ctx, task); err != nil {
+		report.TasksExamined++
+		if err := rs.recoverTask(ctx, task, report); err != nil {
 			rs.logger.WithField("task_id", task.ID).
 				WithError(err).
 				Error("Failed to recover task")
 			
+			if recordErr := rs.taskStore.RecordTaskError(NewTaskError(task.ID, StageRecovery, task.RetryCount+1, err, 0)); recordErr != nil {
+				rs.logger.WithField("task_id", task.ID).
+					WithError(recordErr).
+					Warn("Failed to record task error during recovery")
+			}
+
 			// Mark task as failed
 			if updateErr := rs.taskStore.UpdateStatus(task.ID, models.TaskStatusFailed, 
 				fmt.Sprintf("Recovery failed: %v", err)); updateErr != nil {
@@ -70,32 +90,40 @@ func (rs *RecoveryService) RecoverIncompleteTasks(ctx context.Context) error {
 					WithError(updateErr).
 					Error("Failed to update task status during recovery")
 			}
+			report.taskChanged(task, models.TaskStatusFailed, err.Error())
 			failedCount++
 		} else {
 			recoveredCount++
 		}
+		return nil
+	}
+
+	for _, status := range []models.TaskStatus{models.TaskStatusPending, models.TaskStatusDownloaded} {
+		if err := rs.taskStore.EachByStatus(status, recoverOnce); err != nil {
+			return fmt.Errorf("failed to get %s tasks: %w", status, err)
+		}
 	}
 
 	rs.logger.WithField("recovered", recoveredCount).
 		WithField("failed", failedCount).
-		WithField("total", len(allIncompleteTasks)).
+		WithField("total", len(recovered)).
 		Info("Crash recovery completed")
 
 	return nil
 }
 
-func (rs *RecoveryService) recoverTask(ctx context.Context, task *models.Task) error {
+func (rs *RecoveryService) recoverTask(ctx context.Context, task *models.Task, report *ReconciliationReport) error {
 	switch task.Status {
 	case models.TaskStatusPending:
-		return rs.recoverPendingTask(ctx, task)
+		return rs.recoverPendingTask(ctx, task, report)
 	case models.TaskStatusDownloaded:
-		return rs.recoverDownloadedTask(ctx, task)
+		return rs.recoverDownloadedTask(ctx, task, report)
 	default:
 		return fmt.Errorf("unknown task status for recovery: %s", task.Status)
 	}
 }
 
-func (rs *RecoveryService) recoverPendingTask(ctx context.Context, task *models.Task) error {
+func (rs *RecoveryService) recoverPendingTask(ctx context.Context, task *models.Task, report *ReconciliationReport) error {
 	rs.logger.WithField("task_id", task.ID).Info("Recovering pending task")
 
 	// Get Local Bot API paths
@@ -125,7 +153,11 @@ func (rs *RecoveryService) recoverPendingTask(ctx context.Context, task *models.
 		}
 		
 		// File exists in temp, update status to DOWNLOADED
-		return rs.taskStore.UpdateStatus(task.ID, models.TaskStatusDownloaded, "")
+		if err := rs.taskStore.UpdateStatus(task.ID, models.TaskStatusDownloaded, ""); err != nil {
+			return err
+		}
+		report.taskRelinked(task, models.TaskStatusDownloaded, tempFilePath, "downloaded file found in the Local Bot API temp directory")
+		return nil
 	}
 	
 	// Check if file is still in documents directory (not yet moved to temp)
@@ -142,11 +174,12 @@ func (rs *RecoveryService) recoverPendingTask(ctx context.Context, task *models.
 	// File not found in temp or documents - will need to be re-downloaded
 	rs.logger.WithField("task_id", task.ID).
 		Info("File not found in Local Bot API directories, task will be re-queued for download")
+	report.TasksQueued++
 	
 	return nil // Task remains PENDING and will be picked up by pipeline
 }
 
-func (rs *RecoveryService) recoverDownloadedTask(ctx context.Context, task *models.Task) error {
+func (rs *RecoveryService) recoverDownloadedTask(ctx context.Context, task *models.Task, report *ReconciliationReport) error {
 	rs.logger.WithField("task_id", task.ID).Info("Recovering downloaded task")
 
 	// Get Local Bot API paths
@@ -167,8 +200,12 @@ func (rs *RecoveryService) recoverDownloadedTask(ctx context.Context, task *mode
 			task.LocalAPIPath = tempFilePath
 			if updateErr := rs.taskStore.UpdateTask(task); updateErr != nil {
 				rs.logger.WithError(updateErr).Error("Failed to update task with temp path")
+			} else {
+				report.taskRelinked(task, models.TaskStatusDownloaded, tempFilePath, "temp file path restored")
+				return nil
 			}
 		}
+		report.TasksReady++
 		
 		return nil // Task will be picked up by extraction pipeline
 	}
@@ -178,6 +215,7 @@ func (rs *RecoveryService) recoverDownloadedTask(ctx context.Context, task *mode
 	if _, err := os.Stat(extractionFilePath); err == nil {
 		rs.logger.WithField("task_id", task.ID).
 			Info("File found in extraction directory, ready for processing")
+		report.TasksReady++
 		return nil // File is ready for extraction
 	}
 	
@@ -186,6 +224,7 @@ func (rs *RecoveryService) recoverDownloadedTask(ctx context.Context, task *mode
 	if _, err := os.Stat(txtFilePath); err == nil {
 		rs.logger.WithField("task_id", task.ID).
 			Info("File found in txt extraction directory, ready for processing")
+		report.TasksReady++
 		return nil // File is ready for extraction
 	}
 
@@ -201,6 +240,7 @@ func (rs *RecoveryService) recoverDownloadedTask(ctx context.Context, task *mode
 						WithField("pass_file", passFile).
 						Info("Found extracted files in pass directory, task may have been processed")
 					// We could mark as completed, but let pipeline handle it
+					report.TasksReady++
 					return nil
 				}
 			}
@@ -217,7 +257,11 @@ func (rs *RecoveryService) recoverDownloadedTask(ctx context.Context, task *mode
 				WithField("documents_files", len(documentFiles)).
 				Info("Found files in documents directory, task may need to be re-processed")
 			// Reset task to PENDING so it can be re-downloaded and moved properly
-			return rs.taskStore.UpdateStatus(task.ID, models.TaskStatusPending, "File found in documents, re-processing")
+			if err := rs.taskStore.UpdateStatus(task.ID, models.TaskStatusPending, "File found in documents, re-processing"); err != nil {
+				return err
+			}
+			report.taskChanged(task, models.TaskStatusPending, "downloaded file missing, re-downloading")
+			return nil
 		}
 	}
 
@@ -225,7 +269,48 @@ func (rs *RecoveryService) recoverDownloadedTask(ctx context.Context, task *mode
 	return fmt.Errorf("downloaded file not found in any expected location")
 }
 
-func (rs *RecoveryService) CleanupOrphanedFiles() error {
+// ReclaimExpiredLeases returns downloads whose worker lease expired to PENDING
+func (rs *RecoveryService) ReclaimExpiredLeases() (int, error) {
+	return rs.reclaimExpiredLeases(nil)
+}
+
+func (rs *RecoveryService) reclaimExpiredLeases(report *ReconciliationReport) (int, error) {
+	ids, err := rs.taskStore.ReclaimExpiredLeases()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		rs.logger.WithField("task_id", id).
+			Warn("Download lease expired, task returned to PENDING")
+		report.taskChanged(&models.Task{ID: id, Status: models.TaskStatusDownloading}, models.TaskStatusPending, "download lease expired")
+	}
+	return len(ids), nil
+}
+
+// RunLeaseReclaim reclaims expired task leases every interval until ctx is
+// cancelled, so a crashed or hung download worker's task is picked up again
+// without waiting for a restart
+func (rs *RecoveryService) RunLeaseReclaim(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			utils.Heartbeat(ctx)
+			if _, err := rs.ReclaimExpiredLeases(); err != nil {
+				rs.logger.WithError(err).Warn("Failed to reclaim expired task leases")
+			}
+		}
+	}
+}
+
+// cleanupOrphanedFiles removes files left behind in the working directories
+// for longer than any task keeps them, recording each in report
+func (rs *RecoveryService) cleanupOrphanedFiles(report *ReconciliationReport) error {
 	rs.logger.Info("Starting cleanup of orphaned files")
 
 	// Clean up Local Bot API temp directory of files older than 24 hours
@@ -233,7 +318,7 @@ func (rs *RecoveryService) CleanupOrphanedFiles() error {
 	if err != nil {
 		rs.logger.WithError(err).Warn("Failed to get Local Bot API temp path for cleanup")
 	} else {
-		if err := rs.cleanupDirectory(tempPath, 24*time.Hour); err != nil {
+		if err := rs.cleanupDirectory(tempPath, 24*time.Hour, report); err != nil {
 			rs.logger.WithError(err).Warn("Failed to cleanup Local Bot API temp directory")
 		}
 	}
@@ -241,6 +326,7 @@ func (rs *RecoveryService) CleanupOrphanedFiles() error {
 	// Clean up extraction directories of very old files
 	extractionDirs := []string{
 		"app/extraction/files/all",
+		"app/extraction/files/fastpath",
 		"app/extraction/files/txt",
 		"app/extraction/files/pass",
 		"app/extraction/files/errors",
@@ -248,7 +334,7 @@ func (rs *RecoveryService) CleanupOrphanedFiles() error {
 	}
 
 	for _, dir := range extractionDirs {
-		if err := rs.cleanupDirectory(dir, 7*24*time.Hour); err != nil {
+		if err := rs.cleanupDirectory(dir, 7*24*time.Hour, report); err != nil {
 			rs.logger.WithError(err).
 				WithField("directory", dir).
 				Warn("Failed to cleanup extraction directory")
@@ -260,7 +346,7 @@ func (rs *RecoveryService) CleanupOrphanedFiles() error {
 	if docErr != nil {
 		rs.logger.WithError(docErr).Warn("Failed to get Local Bot API documents path for cleanup")
 	} else {
-		if err := rs.cleanupDirectory(documentsPath, 48*time.Hour); err != nil {
+		if err := rs.cleanupDirectory(documentsPath, 48*time.Hour, report); err != nil {
 			rs.logger.WithError(err).Warn("Failed to cleanup Local Bot API documents directory")
 		}
 	}
@@ -269,7 +355,7 @@ func (rs *RecoveryService) CleanupOrphanedFiles() error {
 	return nil
 }
 
-func (rs *RecoveryService) cleanupDirectory(dir string, maxAge time.Duration) error {
+func (rs *RecoveryService) cleanupDirectory(dir string, maxAge time.Duration, report *ReconciliationReport) error {
 	files, err := filepath.Glob(filepath.Join(dir, "*"))
 	if err != nil {
 		return err
@@ -285,7 +371,9 @@ func (rs *RecoveryService) cleanupDirectory(dir string, maxAge time.Duration) er
 					rs.logger.WithError(err).
 						WithField("file", file).
 						Warn("Failed to remove old file")
+					report.fileOrphaned(file, info, fmt.Sprintf("older than %s, removal failed: %v", maxAge, err))
 				} else {
+					report.fileOrphaned(file, info, fmt.Sprintf("older than %s, removed", maxAge))
 					cleanedCount++
 				}
 			}
@@ -305,20 +393,20 @@ func (rs *RecoveryService) GetRecoveryStats() RecoveryStats {
 	stats := RecoveryStats{}
 
 	// Count tasks by status
-	if pendingTasks, err := rs.taskStore.GetByStatus(models.TaskStatusPending); err == nil {
-		stats.PendingTasks = len(pendingTasks)
+	if pendingTasks, err := rs.taskStore.GetTaskCountByStatus(models.TaskStatusPending); err == nil {
+		stats.PendingTasks = pendingTasks
 	}
 
-	if downloadedTasks, err := rs.taskStore.GetByStatus(models.TaskStatusDownloaded); err == nil {
-		stats.DownloadedTasks = len(downloadedTasks)
+	if downloadedTasks, err := rs.taskStore.GetTaskCountByStatus(models.TaskStatusDownloaded); err == nil {
+		stats.DownloadedTasks = downloadedTasks
 	}
 
-	if completedTasks, err := rs.taskStore.GetByStatus(models.TaskStatusCompleted); err == nil {
-		stats.CompletedTasks = len(completedTasks)
+	if completedTasks, err := rs.taskStore.GetTaskCountByStatus(models.TaskStatusCompleted); err == nil {
+		stats.CompletedTasks = completedTasks
 	}
 
-	if failedTasks, err := rs.taskStore.GetByStatus(models.TaskStatusFailed); err == nil {
-		stats.FailedTasks = len(failedTasks)
+	if failedTasks, err := rs.taskStore.GetTaskCountByStatus(models.TaskStatusFailed); err == nil {
+		stats.FailedTasks = failedTasks
 	}
 
 	return stats
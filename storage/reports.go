@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// GetReportLastSent returns when a scheduled report was last sent, or the zero
+// time if it never was
+func (ts *TaskStore) GetReportLastSent(name string) (time.Time, error) {
+	var sentAt time.Time
+	err := ts.db.DB().QueryRow(`SELECT last_sent_at FROM scheduled_reports WHERE name = ?`, name).Scan(&sentAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get report schedule: %w", err)
+	}
+	return sentAt, nil
+}
+
+// MarkReportSent records that a scheduled report was sent, so restarts do not
+// send it again
+func (ts *TaskStore) MarkReportSent(name string, sentAt time.Time) error {
+	_, err := ts.db.DB().Exec(`
+		INSERT INTO scheduled_reports (name, last_sent_at) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET last_sent_at = excluded.last_sent_at`,
+		name, sentAt)
+	if err != nil {
+		return fmt.Errorf("failed to record report schedule: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"time"
+
+	"telegram-archive-bot/models"
+)
+
+// TaskRepository is the task lifecycle part of TaskStore: creating tasks and
+// moving them through their statuses. Workers and the orchestrator depend on
+// it rather than on *TaskStore, so they can run against a mock or another
+// storage backend
+type TaskRepository interface {
+	Create(task *models.Task) error
+	GetByID(id string) (*models.Task, error)
+	GetByStatus(status models.TaskStatus) ([]*models.Task, error)
+	EachByStatus(status models.TaskStatus, fn func(task *models.Task) error) error
+	GetByFileHash(fileHash string) (*models.Task, error)
+	GetPendingTasks(limit int) ([]*models.Task, error)
+	GetTaskCountByStatus(status models.TaskStatus) (int, error)
+	UpdateStatus(id string, status models.TaskStatus, errorMessage string) error
+	UpdateTask(task *models.Task) error
+	MarkDownloading(taskID string) error
+	MarkDownloaded(taskID string) error
+	ClaimPendingTask(owner string, ttl time.Duration) (*models.Task, error)
+	RenewTaskLease(taskID, owner string, ttl time.Duration) error
+	ReleaseTaskLease(taskID, owner string) error
+	MarkClaimedDownloaded(taskID, owner string) error
+	UpdateClaimedTask(task *models.Task, owner string) error
+}
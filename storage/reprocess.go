@@ -0,0 +1,327 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Reprocess campaign and item states
+const (
+	ReprocessRunning   = "running"
+	ReprocessCompleted = "completed"
+	ReprocessCancelled = "cancelled"
+
+	reprocessItemPending = "pending"
+	reprocessItemDone    = "done"
+	reprocessItemFailed  = "failed"
+)
+
+var (
+	// ErrCampaignRunning is returned when starting a campaign while one runs
+	ErrCampaignRunning = errors.New("a reprocess campaign is already running")
+	// ErrNothingToReprocess is returned when no older pass kept its input
+	ErrNothingToReprocess = errors.New("no conversion passes to reprocess")
+	// ErrNoCampaignRunning is returned when cancelling without a running campaign
+	ErrNoCampaignRunning = errors.New("no reprocess campaign is running")
+)
+
+// ReprocessCampaign converts again the kept input of every conversion pass
+// made with a parser older than ParserVersion
+type ReprocessCampaign struct {
+	ID            int64
+	ParserVersion int
+	Status        string
+	StartedBy     int64
+	StartedByName string
+	CreatedAt     time.Time
+	FinishedAt    *time.Time
+
+	// Passes of the campaign by state, and the tasks they cover
+	Total   int
+	Done    int
+	Failed  int
+	Tasks   int
+	LastErr string // Error of the most recent failed pass
+}
+
+// Pending returns how many passes are left
+func (c *ReprocessCampaign) Pending() int {
+	return c.Total - c.Done - c.Failed
+}
+
+// ReprocessItem is one conversion pass of a campaign to convert again
+type ReprocessItem struct {
+	CampaignID    int64
+	ResultID      int64
+	ParserVersion int
+	InputDir      string
+	TaskIDs       []string
+}
+
+// ReprocessBacklog is what a campaign at a parser version would cover
+type ReprocessBacklog struct {
+	Passes int // Older passes with their input kept
+	Tasks  int
+	// Unavailable counts tasks converted by an older parser whose input was
+	// not kept, so only /retry can reprocess them
+	Unavailable int
+}
+
+// StartReprocessCampaign starts a campaign over every pass converted by a
+// parser older than version whose input was kept
+func (ts *TaskStore) StartReprocessCampaign(version int, startedBy int64, startedByName string) (*ReprocessCampaign, error) {
+	tx, err := ts.db.DB().Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var running int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM reprocess_campaigns WHERE status = ?`, ReprocessRunning).Scan(&running); err != nil {
+		return nil, fmt.Errorf("failed to check running campaigns: %w", err)
+	}
+	if running > 0 {
+		return nil, ErrCampaignRunning
+	}
+
+	now := time.Now()
+	res, err := tx.Exec(`INSERT INTO reprocess_campaigns (parser_version, status, started_by, started_by_name, created_at)
+		VALUES (?, ?, ?, ?, ?)`, version, ReprocessRunning, startedBy, startedByName, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reprocess campaign: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaign id: %w", err)
+	}
+
+	res, err = tx.Exec(`
+		INSERT INTO reprocess_items (campaign_id, result_id, status, updated_at)
+		SELECT ?, id, ?, ? FROM conversion_results
+		WHERE COALESCE(parser_version, 0) < ? AND COALESCE(input_dir, '') != ''
+		ORDER BY id`, id, reprocessItemPending, now, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to queue campaign passes: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	} else if n == 0 {
+		return nil, ErrNothingToReprocess
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit reprocess campaign: %w", err)
+	}
+	return ts.GetReprocessCampaign(id)
+}
+
+// GetReprocessCampaign returns a campaign with its progress
+func (ts *TaskStore) GetReprocessCampaign(id int64) (*ReprocessCampaign, error) {
+	c := &ReprocessCampaign{}
+	var finishedAt sql.NullTime
+	err := ts.db.DB().QueryRow(`
+		SELECT id, parser_version, status, started_by, started_by_name, created_at, finished_at
+		FROM reprocess_campaigns WHERE id = ?`, id).
+		Scan(&c.ID, &c.ParserVersion, &c.Status, &c.StartedBy, &c.StartedByName, &c.CreatedAt, &finishedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reprocess campaign: %w", err)
+	}
+	if finishedAt.Valid {
+		c.FinishedAt = &finishedAt.Time
+	}
+
+	err = ts.db.DB().QueryRow(`
+		SELECT COUNT(*),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0)
+		FROM reprocess_items WHERE campaign_id = ?`, reprocessItemDone, reprocessItemFailed, id).
+		Scan(&c.Total, &c.Done, &c.Failed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count campaign passes: %w", err)
+	}
+	err = ts.db.DB().QueryRow(`
+		SELECT COUNT(DISTINCT t.task_id) FROM reprocess_items i
+		JOIN task_conversion_results t ON t.result_id IN (i.result_id, i.new_result_id)
+		WHERE i.campaign_id = ?`, id).Scan(&c.Tasks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count campaign tasks: %w", err)
+	}
+	err = ts.db.DB().QueryRow(`SELECT error FROM reprocess_items WHERE campaign_id = ? AND status = ?
+		ORDER BY updated_at DESC LIMIT 1`, id, reprocessItemFailed).Scan(&c.LastErr)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get campaign error: %w", err)
+	}
+	return c, nil
+}
+
+// GetLatestReprocessCampaign returns the most recent campaign, or nil
+func (ts *TaskStore) GetLatestReprocessCampaign() (*ReprocessCampaign, error) {
+	var id int64
+	err := ts.db.DB().QueryRow(`SELECT id FROM reprocess_campaigns ORDER BY id DESC LIMIT 1`).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest reprocess campaign: %w", err)
+	}
+	return ts.GetReprocessCampaign(id)
+}
+
+// GetRunningReprocessCampaign returns the running campaign, or nil
+func (ts *TaskStore) GetRunningReprocessCampaign() (*ReprocessCampaign, error) {
+	var id int64
+	err := ts.db.DB().QueryRow(`SELECT id FROM reprocess_campaigns WHERE status = ? LIMIT 1`, ReprocessRunning).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get running reprocess campaign: %w", err)
+	}
+	return ts.GetReprocessCampaign(id)
+}
+
+// CancelReprocessCampaign stops the running campaign; the pass being
+// converted, if any, still finishes
+func (ts *TaskStore) CancelReprocessCampaign() (*ReprocessCampaign, error) {
+	campaign, err := ts.GetRunningReprocessCampaign()
+	if err != nil {
+		return nil, err
+	}
+	if campaign == nil {
+		return nil, ErrNoCampaignRunning
+	}
+	if err := ts.finishReprocessCampaign(campaign.ID, ReprocessCancelled); err != nil {
+		return nil, err
+	}
+	return ts.GetReprocessCampaign(campaign.ID)
+}
+
+// NextReprocessItem returns the next pass of a running campaign, or nil once
+// none are left, when the campaign is marked completed
+func (ts *TaskStore) NextReprocessItem(campaignID int64) (*ReprocessItem, error) {
+	item := &ReprocessItem{CampaignID: campaignID}
+	err := ts.db.DB().QueryRow(`
+		SELECT i.result_id, COALESCE(r.parser_version, 0), COALESCE(r.input_dir, '')
+		FROM reprocess_items i JOIN conversion_results r ON r.id = i.result_id
+		WHERE i.campaign_id = ? AND i.status = ?
+		ORDER BY i.result_id LIMIT 1`, campaignID, reprocessItemPending).
+		Scan(&item.ResultID, &item.ParserVersion, &item.InputDir)
+	if err == sql.ErrNoRows {
+		return nil, ts.finishReprocessCampaign(campaignID, ReprocessCompleted)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get next reprocess pass: %w", err)
+	}
+
+	rows, err := ts.db.DB().Query(`SELECT task_id FROM task_conversion_results WHERE result_id = ?`, item.ResultID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks of conversion pass: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var taskID string
+		if err := rows.Scan(&taskID); err != nil {
+			return nil, fmt.Errorf("failed to scan task of conversion pass: %w", err)
+		}
+		item.TaskIDs = append(item.TaskIDs, taskID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return item, nil
+}
+
+// FinishReprocessItem records the outcome of converting a pass again. On
+// success the kept input passes to the new result, so a later campaign
+// converts it from there
+func (ts *TaskStore) FinishReprocessItem(item *ReprocessItem, newResultID int64, passErr error) error {
+	tx, err := ts.db.DB().Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	status, message := reprocessItemDone, ""
+	if passErr != nil {
+		status, message = reprocessItemFailed, passErr.Error()
+	}
+	_, err = tx.Exec(`UPDATE reprocess_items SET status = ?, new_result_id = ?, error = ?, updated_at = ?
+		WHERE campaign_id = ? AND result_id = ?`, status, newResultID, message, time.Now(), item.CampaignID, item.ResultID)
+	if err != nil {
+		return fmt.Errorf("failed to update reprocess pass: %w", err)
+	}
+	if passErr == nil && newResultID != 0 {
+		if _, err := tx.Exec(`UPDATE conversion_results SET input_dir = '' WHERE id = ?`, item.ResultID); err != nil {
+			return fmt.Errorf("failed to hand on kept input: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit reprocess pass: %w", err)
+	}
+	return nil
+}
+
+func (ts *TaskStore) finishReprocessCampaign(id int64, status string) error {
+	_, err := ts.db.DB().Exec(`UPDATE reprocess_campaigns SET status = ?, finished_at = ? WHERE id = ? AND status = ?`,
+		status, time.Now(), id, ReprocessRunning)
+	if err != nil {
+		return fmt.Errorf("failed to finish reprocess campaign: %w", err)
+	}
+	return nil
+}
+
+// GetParserVersionCounts returns how many tasks were last converted by each
+// parser version; 0 counts passes from before versions were tracked
+func (ts *TaskStore) GetParserVersionCounts() (map[int]int, error) {
+	rows, err := ts.db.DB().Query(`
+		SELECT COALESCE(r.parser_version, 0), COUNT(*)
+		FROM task_conversion_results t JOIN conversion_results r ON r.id = t.result_id
+		GROUP BY 1`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tasks by parser version: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var version, count int
+		if err := rows.Scan(&version, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan parser version count: %w", err)
+		}
+		counts[version] = count
+	}
+	return counts, rows.Err()
+}
+
+// GetReprocessBacklog returns what a campaign at version would cover
+func (ts *TaskStore) GetReprocessBacklog(version int) (*ReprocessBacklog, error) {
+	backlog := &ReprocessBacklog{}
+	err := ts.db.DB().QueryRow(`
+		SELECT COUNT(DISTINCT r.id),
+			COUNT(t.task_id),
+			COALESCE(SUM(CASE WHEN COALESCE(r.input_dir, '') = '' THEN 1 ELSE 0 END), 0)
+		FROM conversion_results r JOIN task_conversion_results t ON t.result_id = r.id
+		WHERE COALESCE(r.parser_version, 0) < ?`, version).
+		Scan(&backlog.Passes, &backlog.Tasks, &backlog.Unavailable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reprocess backlog: %w", err)
+	}
+	// Only passes with their input kept can be converted again
+	err = ts.db.DB().QueryRow(`SELECT COUNT(*) FROM conversion_results
+		WHERE COALESCE(parser_version, 0) < ? AND COALESCE(input_dir, '') != ''`, version).Scan(&backlog.Passes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count reprocessable passes: %w", err)
+	}
+	backlog.Tasks -= backlog.Unavailable
+	return backlog, nil
+}
+
+// ClearConversionInput forgets a kept conversion input once it is deleted
+func (ts *TaskStore) ClearConversionInput(dir string) error {
+	if _, err := ts.db.DB().Exec(`UPDATE conversion_results SET input_dir = '' WHERE input_dir = ?`, dir); err != nil {
+		return fmt.Errorf("failed to clear kept conversion input: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"telegram-archive-bot/models"
+)
+
+// retentionTaskTables are the tables holding records about a task, deleted
+// with it once it is past retention. Short IDs, the audit logs and the dead
+// letter queue are kept so earlier references stay readable
+var retentionTaskTables = []string{
+	"task_progress_messages",
+	"task_origins",
+	"task_messages",
+	"task_conversion_results",
+	"task_errors",
+	"archive_metadata",
+	"ledger_entries",
+	"task_deletions",
+}
+
+// ExpiredTaskPurge is what PurgeExpiredTasks deleted
+type ExpiredTaskPurge struct {
+	TaskIDs []string
+	Rows    map[string]int64 // Deleted rows per table
+}
+
+// PurgeExpiredTasks deletes up to limit finished tasks completed before cutoff,
+// oldest first, with the records kept about them. Tasks deleted with /delete
+// wait until their files are purged
+func (ts *TaskStore) PurgeExpiredTasks(cutoff time.Time, limit int) (*ExpiredTaskPurge, error) {
+	tx, err := ts.db.DB().Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id FROM tasks
+		WHERE status IN (?, ?) AND completed_at IS NOT NULL AND completed_at < ?
+			AND id NOT IN (SELECT task_id FROM task_deletions WHERE purged_at IS NULL)
+		ORDER BY completed_at ASC
+		LIMIT ?`,
+		models.TaskStatusCompleted, models.TaskStatusFailed, cutoff, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired tasks: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan expired task: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query expired tasks: %w", err)
+	}
+
+	purge := &ExpiredTaskPurge{TaskIDs: ids, Rows: make(map[string]int64)}
+	if len(ids) == 0 {
+		return purge, nil
+	}
+
+	for _, id := range ids {
+		for _, table := range retentionTaskTables {
+			result, err := tx.Exec(`DELETE FROM `+table+` WHERE task_id = ?`, id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to delete %s of task %s: %w", table, id, err)
+			}
+			if n, err := result.RowsAffected(); err == nil {
+				purge.Rows[table] += n
+			}
+		}
+		if _, err := tx.Exec(`DELETE FROM tasks WHERE id = ?`, id); err != nil {
+			return nil, fmt.Errorf("failed to delete task %s: %w", id, err)
+		}
+		purge.Rows["tasks"]++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit expired task purge: %w", err)
+	}
+	return purge, nil
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// GetScanResult returns a cached security scan result for a file hash, or nil
+// if there is none made with the given definitions within maxAge
+func (ts *TaskStore) GetScanResult(fileHash, declaredType, fingerprint string, maxAge time.Duration) ([]byte, error) {
+	var result string
+	err := ts.db.DB().QueryRow(`
+		SELECT result FROM scan_cache
+		WHERE file_hash = ? AND declared_type = ? AND definitions_fingerprint = ? AND scanned_at > ?`,
+		fileHash, declaredType, fingerprint, time.Now().Add(-maxAge)).Scan(&result)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached scan result: %w", err)
+	}
+
+	if _, err := ts.db.DB().Exec(`UPDATE scan_cache SET hits = hits + 1 WHERE file_hash = ? AND declared_type = ?`,
+		fileHash, declaredType); err != nil {
+		return nil, fmt.Errorf("failed to count scan cache hit: %w", err)
+	}
+	return []byte(result), nil
+}
+
+// SaveScanResult caches a security scan result, replacing any earlier one for the file
+func (ts *TaskStore) SaveScanResult(fileHash, declaredType, fingerprint string, result []byte) error {
+	_, err := ts.db.DB().Exec(`
+		INSERT INTO scan_cache (file_hash, declared_type, definitions_fingerprint, result, scanned_at, hits)
+		VALUES (?, ?, ?, ?, ?, 0)
+		ON CONFLICT(file_hash, declared_type) DO UPDATE SET
+			definitions_fingerprint = excluded.definitions_fingerprint,
+			result = excluded.result,
+			scanned_at = excluded.scanned_at,
+			hits = 0`,
+		fileHash, declaredType, fingerprint, string(result), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save scan result: %w", err)
+	}
+	return nil
+}
+
+// PruneScanResults drops cached results older than maxAge or made with other definitions
+func (ts *TaskStore) PruneScanResults(fingerprint string, maxAge time.Duration) (int64, error) {
+	res, err := ts.db.DB().Exec(`DELETE FROM scan_cache WHERE scanned_at <= ? OR definitions_fingerprint != ?`,
+		time.Now().Add(-maxAge), fingerprint)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune scan cache: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -179,89 +179,102 @@ func (sal *SecurityAuditLogger) LogQuarantineEvent(taskID, fileName, fileHash, r
 	return sal.LogSecurityEvent(event)
 }
 
-// GetSecurityEvents retrieves security events with pagination
-func (sal *SecurityAuditLogger) GetSecurityEvents(limit, offset int) ([]*SecurityEvent, error) {
-	query := `
-		SELECT id, task_id, event_type, threat_level, description, file_name, file_hash,
-			   user_id, warnings, action_taken, metadata, timestamp
-		FROM security_audit
-		ORDER BY timestamp DESC
-		LIMIT ? OFFSET ?
-	`
-	
-	rows, err := sal.db.Query(query, limit, offset)
+// securityEventColumns are the columns scanSecurityEvents reads
+const securityEventColumns = `id, task_id, event_type, threat_level, description, file_name, file_hash,
+	user_id, warnings, action_taken, metadata, timestamp`
+
+// securitySortColumns are the columns security events can be sorted by
+var securitySortColumns = map[string]string{
+	"timestamp": "timestamp",
+	"threat":    "threat_level",
+	"type":      "event_type",
+	"user":      "user_id",
+}
+
+// SecurityEventFilters defines filters for querying security events
+type SecurityEventFilters struct {
+	TaskID         string
+	EventType      SecurityEventType
+	MinThreatLevel utils.ThreatLevel
+	ActionTaken    SecurityAction
+	UserID         int64
+	StartTime      time.Time
+	EndTime        time.Time
+	SortBy         string // timestamp (default), threat, type or user
+	Ascending      bool   // Oldest or lowest first; newest first by default
+	Limit          int
+	Offset         int
+}
+
+// securityQuery applies the filters to a query of security_audit
+func (filters SecurityEventFilters) securityQuery(columns string) *selectQuery {
+	return newSelectQuery("security_audit", columns).
+		WhereIf(filters.TaskID != "", "task_id = ?", filters.TaskID).
+		WhereIf(filters.EventType != "", "event_type = ?", string(filters.EventType)).
+		WhereIf(filters.MinThreatLevel > 0, "threat_level >= ?", int(filters.MinThreatLevel)).
+		WhereIf(filters.ActionTaken != "", "action_taken = ?", string(filters.ActionTaken)).
+		WhereIf(filters.UserID != 0, "user_id = ?", filters.UserID).
+		WhereIf(!filters.StartTime.IsZero(), "timestamp >= ?", filters.StartTime).
+		WhereIf(!filters.EndTime.IsZero(), "timestamp <= ?", filters.EndTime)
+}
+
+// QuerySecurityEvents retrieves security events with filtering, sorting and pagination
+func (sal *SecurityAuditLogger) QuerySecurityEvents(filters SecurityEventFilters) ([]*SecurityEvent, error) {
+	sortBy, err := sortColumn(filters.SortBy, securitySortColumns, "timestamp")
+	if err != nil {
+		return nil, err
+	}
+
+	query, args := filters.securityQuery(securityEventColumns).
+		OrderBy(sortBy, filters.Ascending).
+		Page(filters.Limit, filters.Offset).
+		SQL()
+
+	rows, err := sal.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query security events: %w", err)
 	}
 	defer rows.Close()
-	
-	events := make([]*SecurityEvent, 0)
-	
-	for rows.Next() {
-		event := &SecurityEvent{}
-		var warningsJSON, metadataJSON string
-		var threatLevelInt int
-		
-		err := rows.Scan(
-			&event.ID,
-			&event.TaskID,
-			&event.EventType,
-			&threatLevelInt,
-			&event.Description,
-			&event.FileName,
-			&event.FileHash,
-			&event.UserID,
-			&warningsJSON,
-			&event.ActionTaken,
-			&metadataJSON,
-			&event.Timestamp,
-		)
-		
-		if err != nil {
-			sal.logger.WithError(err).Warn("Failed to scan security event row")
-			continue
-		}
-		
-		event.ThreatLevel = utils.ThreatLevel(threatLevelInt)
-		
-		// Deserialize JSON fields
-		if err := json.Unmarshal([]byte(warningsJSON), &event.Warnings); err != nil {
-			event.Warnings = []string{}
-		}
-		
-		if err := json.Unmarshal([]byte(metadataJSON), &event.Metadata); err != nil {
-			event.Metadata = make(map[string]interface{})
-		}
-		
-		events = append(events, event)
+
+	return sal.scanSecurityEvents(rows), nil
+}
+
+// CountSecurityEvents returns how many security events match the filters,
+// ignoring their limit and offset
+func (sal *SecurityAuditLogger) CountSecurityEvents(filters SecurityEventFilters) (int, error) {
+	query, args := filters.securityQuery("").CountSQL()
+
+	var count int
+	if err := sal.db.QueryRow(query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count security events: %w", err)
 	}
-	
-	return events, nil
+	return count, nil
+}
+
+// GetSecurityEvents retrieves security events with pagination
+func (sal *SecurityAuditLogger) GetSecurityEvents(limit, offset int) ([]*SecurityEvent, error) {
+	return sal.QuerySecurityEvents(SecurityEventFilters{Limit: limit, Offset: offset})
 }
 
 // GetSecurityEventsByTaskID retrieves security events for a specific task
 func (sal *SecurityAuditLogger) GetSecurityEventsByTaskID(taskID string) ([]*SecurityEvent, error) {
-	query := `
-		SELECT id, task_id, event_type, threat_level, description, file_name, file_hash,
-			   user_id, warnings, action_taken, metadata, timestamp
-		FROM security_audit
-		WHERE task_id = ?
-		ORDER BY timestamp ASC
-	`
-	
-	rows, err := sal.db.Query(query, taskID)
+	events, err := sal.QuerySecurityEvents(SecurityEventFilters{TaskID: taskID, Ascending: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to query security events for task: %w", err)
 	}
-	defer rows.Close()
-	
+	return events, nil
+}
+
+// scanSecurityEvents reads rows of securityEventColumns, skipping rows
+// that cannot be scanned
+func (sal *SecurityAuditLogger) scanSecurityEvents(rows *sql.Rows) []*SecurityEvent {
 	events := make([]*SecurityEvent, 0)
-	
+
 	for rows.Next() {
 		event := &SecurityEvent{}
 		var warningsJSON, metadataJSON string
 		var threatLevelInt int
-		
+
 		err := rows.Scan(
 			&event.ID,
 			&event.TaskID,
@@ -276,27 +289,27 @@ func (sal *SecurityAuditLogger) GetSecurityEventsByTaskID(taskID string) ([]*Sec
 			&metadataJSON,
 			&event.Timestamp,
 		)
-		
+
 		if err != nil {
 			sal.logger.WithError(err).Warn("Failed to scan security event row")
 			continue
 		}
-		
+
 		event.ThreatLevel = utils.ThreatLevel(threatLevelInt)
-		
+
 		// Deserialize JSON fields
 		if err := json.Unmarshal([]byte(warningsJSON), &event.Warnings); err != nil {
 			event.Warnings = []string{}
 		}
-		
+
 		if err := json.Unmarshal([]byte(metadataJSON), &event.Metadata); err != nil {
 			event.Metadata = make(map[string]interface{})
 		}
-		
+
 		events = append(events, event)
 	}
-	
-	return events, nil
+
+	return events
 }
 
 // GetSecurityStats returns security statistics
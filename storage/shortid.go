@@ -0,0 +1,253 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"telegram-archive-bot/models"
+)
+
+// shortIDAlphabet is Crockford's base32 alphabet (no I, L, O or U)
+const shortIDAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// shortIDMinDigits pads the counter so early IDs are not confusingly short
+const shortIDMinDigits = 3
+
+// minTaskIDPrefix is the shortest full-ID prefix accepted when resolving a task
+const minTaskIDPrefix = 6
+
+var (
+	// ErrTaskNotFound is returned when an ID does not match any task
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrAmbiguousTaskID is returned when an ID prefix matches more than one task
+	ErrAmbiguousTaskID = errors.New("task ID prefix matches more than one task")
+)
+
+// EncodeShortID converts a sequence number into a human-friendly short ID:
+// Crockford base32 digits followed by a single checksum character
+func EncodeShortID(seq int64) string {
+	var digits []byte
+	for n := seq; n > 0; n /= 32 {
+		digits = append([]byte{shortIDAlphabet[n%32]}, digits...)
+	}
+	for len(digits) < shortIDMinDigits {
+		digits = append([]byte{'0'}, digits...)
+	}
+	return string(digits) + string(shortIDAlphabet[shortIDChecksum(string(digits))])
+}
+
+// shortIDChecksum weights each digit by its position so that both single
+// character typos and swapped neighbours change the check character
+func shortIDChecksum(digits string) int {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		sum += (i + 1) * strings.IndexByte(shortIDAlphabet, digits[i])
+	}
+	return sum % 31
+}
+
+// NormalizeShortID upper-cases the input, strips separators and maps
+// commonly confused characters (O→0, I/L→1) the way Crockford base32 does
+func NormalizeShortID(input string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(strings.TrimSpace(input)) {
+		switch r {
+		case '-', ' ', '#':
+			continue
+		case 'O':
+			r = '0'
+		case 'I', 'L':
+			r = '1'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// IsValidShortID reports whether a normalized short ID has a correct checksum
+func IsValidShortID(shortID string) bool {
+	if len(shortID) < shortIDMinDigits+1 {
+		return false
+	}
+	digits, check := shortID[:len(shortID)-1], shortID[len(shortID)-1]
+	for i := 0; i < len(digits); i++ {
+		if strings.IndexByte(shortIDAlphabet, digits[i]) < 0 {
+			return false
+		}
+	}
+	return shortIDAlphabet[shortIDChecksum(digits)] == check
+}
+
+// AssignShortID allocates the next short ID for a task, or returns the one it
+// already has
+func (ts *TaskStore) AssignShortID(taskID string) (string, error) {
+	tx, err := ts.db.DB().Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin short ID transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var existing string
+	err = tx.QueryRow(`SELECT short_id FROM task_short_ids WHERE task_id = ?`, taskID).Scan(&existing)
+	if err == nil {
+		return existing, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to look up short ID: %w", err)
+	}
+
+	// The task ID is a unique placeholder until the sequence number is known
+	result, err := tx.Exec(`INSERT INTO task_short_ids (task_id, short_id, created_at) VALUES (?, ?, ?)`,
+		taskID, taskID, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("failed to allocate short ID: %w", err)
+	}
+	seq, err := result.LastInsertId()
+	if err != nil {
+		return "", fmt.Errorf("failed to get short ID sequence: %w", err)
+	}
+
+	shortID := EncodeShortID(seq)
+	if _, err := tx.Exec(`UPDATE task_short_ids SET short_id = ? WHERE seq = ?`, shortID, seq); err != nil {
+		return "", fmt.Errorf("failed to store short ID: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit short ID: %w", err)
+	}
+	return shortID, nil
+}
+
+// GetShortID returns the short ID assigned to a task, allocating one for tasks
+// created before short IDs existed
+func (ts *TaskStore) GetShortID(taskID string) (string, error) {
+	var shortID string
+	err := ts.db.DB().QueryRow(`SELECT short_id FROM task_short_ids WHERE task_id = ?`, taskID).Scan(&shortID)
+	if err == sql.ErrNoRows {
+		return ts.AssignShortID(taskID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get short ID: %w", err)
+	}
+	return shortID, nil
+}
+
+// ResolveTaskID maps user input to a full task ID. It accepts a short ID
+// (case-insensitive, with or without separators), a full task ID, or an
+// unambiguous prefix of a full task ID
+func (ts *TaskStore) ResolveTaskID(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", ErrTaskNotFound
+	}
+
+	if shortID := NormalizeShortID(input); IsValidShortID(shortID) {
+		var taskID string
+		err := ts.db.DB().QueryRow(`SELECT task_id FROM task_short_ids WHERE short_id = ?`, shortID).Scan(&taskID)
+		if err == nil {
+			return taskID, nil
+		}
+		if err != sql.ErrNoRows {
+			return "", fmt.Errorf("failed to resolve short ID: %w", err)
+		}
+	}
+
+	var count int
+	if err := ts.db.DB().QueryRow(`SELECT COUNT(*) FROM tasks WHERE id = ?`, input).Scan(&count); err != nil {
+		return "", fmt.Errorf("failed to resolve task ID: %w", err)
+	}
+	if count == 1 {
+		return input, nil
+	}
+
+	if len(input) < minTaskIDPrefix {
+		return "", ErrTaskNotFound
+	}
+
+	rows, err := ts.db.DB().Query(`SELECT id FROM tasks WHERE id LIKE ? ESCAPE '\' LIMIT 2`,
+		escapeLike(input)+"%")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve task ID prefix: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return "", fmt.Errorf("failed to scan task ID: %w", err)
+		}
+		matches = append(matches, id)
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", ErrTaskNotFound
+	case 1:
+		return matches[0], nil
+	default:
+		return "", ErrAmbiguousTaskID
+	}
+}
+
+// escapeLike escapes LIKE wildcards in user input
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+// SearchTasks finds tasks for a free-text lookup: an exact short or full ID
+// match comes first, followed by tasks whose file name contains the query,
+// newest first. An empty query returns the most recent tasks
+func (ts *TaskStore) SearchTasks(query string, limit int) ([]*models.Task, error) {
+	query = strings.TrimSpace(query)
+
+	var tasks []*models.Task
+	seen := make(map[string]bool)
+
+	if query != "" {
+		if taskID, err := ts.ResolveTaskID(query); err == nil {
+			if task, err := ts.GetByID(taskID); err == nil {
+				tasks = append(tasks, task)
+				seen[task.ID] = true
+			}
+		}
+	}
+
+	rows, err := ts.db.DB().Query(`
+		SELECT id, user_id, chat_id, file_name, file_size, file_type, file_hash,
+		       telegram_file_id, local_api_path, status, error_message, error_category,
+		       error_severity, retry_count, created_at, updated_at, completed_at
+		FROM tasks
+		WHERE file_name LIKE ? ESCAPE '\'
+		ORDER BY created_at DESC
+		LIMIT ?`,
+		"%"+escapeLike(query)+"%", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tasks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		task := &models.Task{}
+		err := rows.Scan(
+			&task.ID, &task.UserID, &task.ChatID, &task.FileName,
+			&task.FileSize, &task.FileType, &task.FileHash,
+			&task.TelegramFileID, &task.LocalAPIPath, &task.Status,
+			&task.ErrorMessage, &task.ErrorCategory, &task.ErrorSeverity,
+			&task.RetryCount, &task.CreatedAt, &task.UpdatedAt, &task.CompletedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task: %w", err)
+		}
+		if seen[task.ID] || len(tasks) >= limit {
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// ErrTaskAlreadyDeleted is returned when deleting a task twice
+	ErrTaskAlreadyDeleted = errors.New("task already deleted")
+	// ErrTaskNotDeleted is returned when undeleting a task that was not deleted
+	ErrTaskNotDeleted = errors.New("task not deleted")
+	// ErrTaskPurged is returned when undeleting a task whose files are gone
+	ErrTaskPurged = errors.New("task already purged")
+)
+
+// TaskDeletion is a soft-deleted task; its files are purged once PurgeAfter passes
+type TaskDeletion struct {
+	TaskID        string
+	DeletedBy     int64
+	DeletedByName string
+	DeletedAt     time.Time
+	PurgeAfter    time.Time
+	PurgedAt      *time.Time
+	FilesRemoved  int
+	PurgeError    string // Last failed purge attempt, retried on the next run
+}
+
+// SoftDeleteTask marks a task deleted; its files are purged after grace,
+// until then UndeleteTask takes it back
+func (ts *TaskStore) SoftDeleteTask(taskID string, userID int64, username string, grace time.Duration) (*TaskDeletion, error) {
+	now := time.Now()
+	deletion := &TaskDeletion{
+		TaskID:        taskID,
+		DeletedBy:     userID,
+		DeletedByName: username,
+		DeletedAt:     now,
+		PurgeAfter:    now.Add(grace),
+	}
+
+	result, err := ts.db.DB().Exec(`
+		INSERT OR IGNORE INTO task_deletions (task_id, deleted_by, deleted_by_name, deleted_at, purge_after)
+		VALUES (?, ?, ?, ?, ?)`,
+		deletion.TaskID, deletion.DeletedBy, deletion.DeletedByName, deletion.DeletedAt, deletion.PurgeAfter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete task: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, ErrTaskAlreadyDeleted
+	}
+	return deletion, nil
+}
+
+// UndeleteTask takes back a deletion whose files were not purged yet
+func (ts *TaskStore) UndeleteTask(taskID string) error {
+	deletion, err := ts.GetTaskDeletion(taskID)
+	if err != nil {
+		return err
+	}
+	if deletion == nil {
+		return ErrTaskNotDeleted
+	}
+	if deletion.PurgedAt != nil {
+		return ErrTaskPurged
+	}
+
+	result, err := ts.db.DB().Exec(`DELETE FROM task_deletions WHERE task_id = ? AND purged_at IS NULL`, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to undelete task: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		// Purged between the check and the delete
+		return ErrTaskPurged
+	}
+	return nil
+}
+
+// GetTaskDeletion returns the deletion of a task, nil when it is not deleted
+func (ts *TaskStore) GetTaskDeletion(taskID string) (*TaskDeletion, error) {
+	deletion, err := scanTaskDeletion(ts.db.DB().QueryRow(`
+		SELECT task_id, deleted_by, deleted_by_name, deleted_at, purge_after, purged_at, files_removed, purge_error
+		FROM task_deletions WHERE task_id = ?`, taskID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task deletion: %w", err)
+	}
+	return deletion, nil
+}
+
+// GetDuePurges returns up to limit deletions whose grace period is over and
+// whose files were not purged yet, oldest first
+func (ts *TaskStore) GetDuePurges(now time.Time, limit int) ([]*TaskDeletion, error) {
+	rows, err := ts.db.DB().Query(`
+		SELECT task_id, deleted_by, deleted_by_name, deleted_at, purge_after, purged_at, files_removed, purge_error
+		FROM task_deletions
+		WHERE purged_at IS NULL AND purge_after <= ?
+		ORDER BY purge_after ASC
+		LIMIT ?`, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due purges: %w", err)
+	}
+	defer rows.Close()
+
+	var deletions []*TaskDeletion
+	for rows.Next() {
+		deletion, err := scanTaskDeletion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task deletion: %w", err)
+		}
+		deletions = append(deletions, deletion)
+	}
+	return deletions, rows.Err()
+}
+
+// MarkTaskPurged records a purge attempt. A failed attempt only records the
+// error, so the purge is retried
+func (ts *TaskStore) MarkTaskPurged(taskID string, filesRemoved int, purgeErr error) error {
+	var err error
+	if purgeErr != nil {
+		_, err = ts.db.DB().Exec(`
+			UPDATE task_deletions SET files_removed = files_removed + ?, purge_error = ?
+			WHERE task_id = ?`, filesRemoved, purgeErr.Error(), taskID)
+	} else {
+		_, err = ts.db.DB().Exec(`
+			UPDATE task_deletions SET files_removed = files_removed + ?, purge_error = '', purged_at = ?
+			WHERE task_id = ?`, filesRemoved, time.Now(), taskID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record task purge: %w", err)
+	}
+	return nil
+}
+
+// TaskArtifact is a place a task's file may be on disk
+type TaskArtifact struct {
+	Path string
+	// Shared paths are named after the upload rather than the task, so
+	// another task's file with the same name may be there now
+	Shared bool
+}
+
+// GetTaskArtifacts returns every place a task's file may be on disk: the
+// Local Bot API copy, the extraction input and what extraction renames it to,
+// and a quarantined copy. Paths may not exist. Extracted and converted data
+// is merged across tasks and cannot be told apart
+func (ts *TaskStore) GetTaskArtifacts(taskID string) ([]TaskArtifact, error) {
+	var localAPIPath, storagePath string
+	err := ts.db.DB().QueryRow(`SELECT local_api_path, storage_path FROM tasks WHERE id = ?`, taskID).
+		Scan(&localAPIPath, &storagePath)
+	if err == sql.ErrNoRows {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task paths: %w", err)
+	}
+
+	var artifacts []TaskArtifact
+	if localAPIPath != "" {
+		artifacts = append(artifacts, TaskArtifact{Path: localAPIPath})
+	}
+	if storagePath != "" {
+		for _, path := range []string{
+			storagePath,
+			storagePath + ".processed",
+			storagePath + ".failed",
+			filepath.Join("app/extraction/files/nopass", filepath.Base(storagePath)),
+		} {
+			artifacts = append(artifacts, TaskArtifact{Path: path, Shared: true})
+		}
+	}
+
+	var sourcePath, quarantinePath string
+	err = ts.db.DB().QueryRow(`SELECT source_path, quarantine_path FROM quarantine_queue WHERE task_id = ?`, taskID).
+		Scan(&sourcePath, &quarantinePath)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get quarantine paths: %w", err)
+	}
+	for _, path := range []string{sourcePath, quarantinePath} {
+		if path != "" && path != localAPIPath {
+			artifacts = append(artifacts, TaskArtifact{Path: path})
+		}
+	}
+	return artifacts, nil
+}
+
+// SetTaskStoragePath records where a downloaded file was moved for extraction
+func (ts *TaskStore) SetTaskStoragePath(taskID, path string) error {
+	if _, err := ts.db.DB().Exec(`UPDATE tasks SET storage_path = ? WHERE id = ?`, path, taskID); err != nil {
+		return fmt.Errorf("failed to set task storage path: %w", err)
+	}
+	return nil
+}
+
+// GetTaskStoragePath returns where a downloaded file was moved, empty when
+// it was not moved
+func (ts *TaskStore) GetTaskStoragePath(taskID string) (string, error) {
+	var path string
+	err := ts.db.DB().QueryRow(`SELECT COALESCE(storage_path, '') FROM tasks WHERE id = ?`, taskID).Scan(&path)
+	if err == sql.ErrNoRows {
+		return "", ErrTaskNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get task storage path: %w", err)
+	}
+	return path, nil
+}
+
+// DeleteTaskDetails drops what was recorded about a task's file contents,
+// keeping the task row for the audit trail
+func (ts *TaskStore) DeleteTaskDetails(taskID string) error {
+	if _, err := ts.db.DB().Exec(`DELETE FROM archive_metadata WHERE task_id = ?`, taskID); err != nil {
+		return fmt.Errorf("failed to delete archive metadata: %w", err)
+	}
+	return nil
+}
+
+func scanTaskDeletion(row interface{ Scan(...interface{}) error }) (*TaskDeletion, error) {
+	deletion := &TaskDeletion{}
+	var purgedAt sql.NullTime
+	err := row.Scan(&deletion.TaskID, &deletion.DeletedBy, &deletion.DeletedByName, &deletion.DeletedAt,
+		&deletion.PurgeAfter, &purgedAt, &deletion.FilesRemoved, &deletion.PurgeError)
+	if err != nil {
+		return nil, err
+	}
+	if purgedAt.Valid {
+		deletion.PurgedAt = &purgedAt.Time
+	}
+	return deletion, nil
+}
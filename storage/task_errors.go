@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"telegram-archive-bot/utils"
+)
+
+// Task error stages beyond the processing stages in eta.go
+const (
+	StageRecovery   = "recovery"
+	StageDeadLetter = "dead_letter"
+)
+
+// TaskError is one failure in a task's history
+type TaskError struct {
+	ID            int64         `json:"id"`
+	TaskID        string        `json:"task_id"`
+	Attempt       int           `json:"attempt"`
+	Stage         string        `json:"stage"`
+	ErrorMessage  string        `json:"error_message"`
+	ErrorCategory string        `json:"error_category,omitempty"`
+	ErrorSeverity string        `json:"error_severity,omitempty"`
+	RetryStrategy string        `json:"retry_strategy,omitempty"`
+	RetryDelay    time.Duration `json:"-"`
+	OccurredAt    time.Time     `json:"occurred_at"`
+}
+
+// taskErrorClassifier categorizes errors without the logging side effects of utils.ErrorHandler
+var taskErrorClassifier = utils.NewErrorClassifier()
+
+// NewTaskError categorizes err as a failure of the given task, attempt and
+// stage; retryDelay is the delay chosen before the next attempt, 0 for none
+func NewTaskError(taskID, stage string, attempt int, err error, retryDelay time.Duration) *TaskError {
+	categorized := taskErrorClassifier.Categorize(err)
+	return &TaskError{
+		TaskID:        taskID,
+		Attempt:       attempt,
+		Stage:         stage,
+		ErrorMessage:  err.Error(),
+		ErrorCategory: string(categorized.Category),
+		ErrorSeverity: string(categorized.Severity),
+		RetryStrategy: string(categorized.Retry),
+		RetryDelay:    retryDelay,
+		OccurredAt:    time.Now(),
+	}
+}
+
+// RecordTaskError appends a failure to the task's error history
+func (ts *TaskStore) RecordTaskError(taskErr *TaskError) error {
+	if taskErr.OccurredAt.IsZero() {
+		taskErr.OccurredAt = time.Now()
+	}
+
+	res, err := ts.db.DB().Exec(`
+		INSERT INTO task_errors (task_id, attempt, stage, error_message, error_category, error_severity,
+			retry_strategy, retry_delay_ms, occurred_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		taskErr.TaskID, taskErr.Attempt, taskErr.Stage, taskErr.ErrorMessage, taskErr.ErrorCategory,
+		taskErr.ErrorSeverity, taskErr.RetryStrategy, taskErr.RetryDelay.Milliseconds(), taskErr.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("failed to record task error: %w", err)
+	}
+	taskErr.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// GetTaskErrors returns a task's failures, oldest first
+func (ts *TaskStore) GetTaskErrors(taskID string) ([]*TaskError, error) {
+	rows, err := ts.db.DB().Query(`
+		SELECT id, task_id, attempt, stage, error_message, error_category, error_severity,
+			retry_strategy, retry_delay_ms, occurred_at
+		FROM task_errors WHERE task_id = ? ORDER BY id`, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task errors: %w", err)
+	}
+	defer rows.Close()
+
+	var history []*TaskError
+	for rows.Next() {
+		taskErr := &TaskError{}
+		var delayMs int64
+		if err := rows.Scan(&taskErr.ID, &taskErr.TaskID, &taskErr.Attempt, &taskErr.Stage, &taskErr.ErrorMessage,
+			&taskErr.ErrorCategory, &taskErr.ErrorSeverity, &taskErr.RetryStrategy, &delayMs, &taskErr.OccurredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan task error: %w", err)
+		}
+		taskErr.RetryDelay = time.Duration(delayMs) * time.Millisecond
+		history = append(history, taskErr)
+	}
+	return history, rows.Err()
+}
+
+// TaskErrorSummary condenses a task's failure history for retry and dead
+// letter decisions
+type TaskErrorSummary struct {
+	Failures         int            `json:"failures"`
+	ByCategory       map[string]int `json:"by_category"`
+	ByStage          map[string]int `json:"by_stage"`
+	DominantCategory string         `json:"dominant_category,omitempty"`
+	NonRetryable     bool           `json:"non_retryable"`
+	HighestSeverity  string         `json:"highest_severity,omitempty"`
+	FirstAt          time.Time      `json:"first_at"`
+	LastAt           time.Time      `json:"last_at"`
+}
+
+// severityRank orders error severities from least to most severe
+var severityRank = map[string]int{
+	string(utils.SeverityLow):      1,
+	string(utils.SeverityMedium):   2,
+	string(utils.SeverityHigh):     3,
+	string(utils.SeverityCritical): 4,
+}
+
+// SummarizeTaskErrors counts failures by category and stage. The dominant
+// category is the one behind more than half of the failures, if any
+func SummarizeTaskErrors(history []*TaskError) *TaskErrorSummary {
+	summary := &TaskErrorSummary{
+		Failures:   len(history),
+		ByCategory: make(map[string]int),
+		ByStage:    make(map[string]int),
+	}
+	for _, taskErr := range history {
+		summary.ByCategory[taskErr.ErrorCategory]++
+		summary.ByStage[taskErr.Stage]++
+		if taskErr.RetryStrategy == string(utils.RetryNever) {
+			summary.NonRetryable = true
+		}
+		if severityRank[taskErr.ErrorSeverity] > severityRank[summary.HighestSeverity] {
+			summary.HighestSeverity = taskErr.ErrorSeverity
+		}
+		if summary.FirstAt.IsZero() {
+			summary.FirstAt = taskErr.OccurredAt
+		}
+		summary.LastAt = taskErr.OccurredAt
+	}
+	for category, count := range summary.ByCategory {
+		if count*2 > summary.Failures {
+			summary.DominantCategory = category
+		}
+	}
+	return summary
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/utils"
+)
+
+// ErrTaskLeaseLost is returned when renewing a task lease that was reclaimed
+// or claimed by another worker
+var ErrTaskLeaseLost = errors.New("task lease lost")
+
+// ClaimPendingTask atomically takes the next PENDING task for owner, moving it
+// to DOWNLOADING with a lease that expires after ttl. Returns nil when no task
+// is pending. The select and update are one statement, so two workers (or two
+// instances sharing the database) can never claim the same task
+func (ts *TaskStore) ClaimPendingTask(owner string, ttl time.Duration) (*models.Task, error) {
+	now := time.Now()
+	order, orderArgs := ts.pendingOrder()
+
+	args := []interface{}{models.TaskStatusDownloading, owner, now.Add(ttl).UnixMilli(), now, models.TaskStatusPending}
+	args = append(append(args, orderArgs...), models.TaskStatusPending)
+
+	var id string
+	err := ts.db.DB().QueryRow(`
+		UPDATE tasks
+		SET status = ?, claimed_by = ?, lease_expires = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM tasks
+			WHERE status = ?
+			ORDER BY `+order+`
+			LIMIT 1
+		) AND status = ?
+		RETURNING id`, args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim pending task: %w", err)
+	}
+
+	return ts.GetByID(id)
+}
+
+// RenewTaskLease extends owner's lease on a task by ttl. It fails once the
+// lease was reclaimed or the task claimed by someone else, in which case the
+// owner must stop working on it (ErrTaskLeaseLost)
+func (ts *TaskStore) RenewTaskLease(taskID, owner string, ttl time.Duration) error {
+	result, err := ts.db.DB().Exec(`
+		UPDATE tasks SET lease_expires = ?
+		WHERE id = ? AND claimed_by = ?`,
+		time.Now().Add(ttl).UnixMilli(), taskID, owner)
+	if err != nil {
+		return fmt.Errorf("failed to renew task lease: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrTaskLeaseLost
+	}
+	return nil
+}
+
+// ReleaseTaskLease drops owner's claim once it is done with a task, whatever
+// status it left the task in. A lease that was already reclaimed is left alone
+func (ts *TaskStore) ReleaseTaskLease(taskID, owner string) error {
+	_, err := ts.db.DB().Exec(`
+		UPDATE tasks SET claimed_by = '', lease_expires = 0
+		WHERE id = ? AND claimed_by = ?`,
+		taskID, owner)
+	if err != nil {
+		return fmt.Errorf("failed to release task lease: %w", err)
+	}
+	return nil
+}
+
+// MarkClaimedDownloaded moves owner's task to DOWNLOADED. It fails with
+// ErrTaskLeaseLost once the task was reclaimed, so a worker whose lease ran
+// out cannot overwrite the status the new owner sets
+func (ts *TaskStore) MarkClaimedDownloaded(taskID, owner string) error {
+	if err := utils.Faults.Inject(context.Background(), utils.FaultDB); err != nil {
+		return fmt.Errorf("failed to update task status: %w", err)
+	}
+
+	result, err := ts.db.DB().Exec(`
+		UPDATE tasks SET status = ?, error_message = '', updated_at = ?
+		WHERE id = ? AND claimed_by = ?`,
+		models.TaskStatusDownloaded, time.Now(), taskID, owner)
+	if err != nil {
+		return fmt.Errorf("failed to update task status: %w", err)
+	}
+	return leaseHeld(result)
+}
+
+// UpdateClaimedTask saves owner's task like UpdateTask, failing with
+// ErrTaskLeaseLost once the task was reclaimed
+func (ts *TaskStore) UpdateClaimedTask(task *models.Task, owner string) error {
+	task.UpdatedAt = time.Now()
+
+	if err := utils.Faults.Inject(context.Background(), utils.FaultDB); err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+
+	result, err := ts.db.DB().Exec(`
+		UPDATE tasks
+		SET user_id=?, chat_id=?, file_name=?, file_size=?, file_type=?, file_hash=?,
+		    telegram_file_id=?, local_api_path=?, status=?, error_message=?, error_category=?,
+		    error_severity=?, retry_count=?, updated_at=?, completed_at=?
+		WHERE id = ? AND claimed_by = ?`,
+		task.UserID, task.ChatID, task.FileName, task.FileSize, task.FileType, task.FileHash,
+		task.TelegramFileID, task.LocalAPIPath, task.Status, task.ErrorMessage, task.ErrorCategory,
+		task.ErrorSeverity, task.RetryCount, task.UpdatedAt, task.CompletedAt, task.ID, owner)
+	if err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+	return leaseHeld(result)
+}
+
+// leaseHeld turns an update fenced by claimed_by that matched no row into
+// ErrTaskLeaseLost
+func leaseHeld(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrTaskLeaseLost
+	}
+	return nil
+}
+
+// ReclaimExpiredLeases returns DOWNLOADING tasks whose lease expired, because
+// their worker crashed or hung, to PENDING so another worker picks them up.
+// DOWNLOADING tasks without a lease, left over from before leases existed,
+// count as expired
+func (ts *TaskStore) ReclaimExpiredLeases() ([]string, error) {
+	now := time.Now()
+
+	rows, err := ts.db.DB().Query(`
+		UPDATE tasks
+		SET status = ?, claimed_by = '', lease_expires = 0, updated_at = ?
+		WHERE status = ? AND lease_expires < ?
+		RETURNING id`,
+		models.TaskStatusPending, now, models.TaskStatusDownloading, now.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("failed to reclaim expired task leases: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan reclaimed task: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	for range ids {
+		ts.notifyPending()
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"telegram-archive-bot/models"
+)
+
+var (
+	// ErrTaskNotFinished is returned when retrying a task still in the pipeline
+	ErrTaskNotFinished = errors.New("task not finished")
+	// ErrTaskNoFileID is returned when retrying a task without a stored Telegram file ID
+	ErrTaskNoFileID = errors.New("task has no telegram file id")
+)
+
+// RetryInProgressError is returned when another retry of the same upload is
+// still in the pipeline
+type RetryInProgressError struct {
+	Task *models.Task
+}
+
+func (e *RetryInProgressError) Error() string {
+	return fmt.Sprintf("retry %s of the same upload is %s", e.Task.ID, e.Task.Status)
+}
+
+// RetryTask clones a COMPLETED or FAILED task into a new PENDING task that
+// downloads the same Telegram file again, with the same priority and origin
+// so its messages go back to the original upload. The clone records the
+// task the upload was first submitted as, so the download's duplicate check
+// lets it through while still rejecting copies submitted separately
+func (ts *TaskStore) RetryTask(taskID string) (*models.Task, error) {
+	source, err := ts.GetByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if !source.IsCompleted() {
+		return nil, ErrTaskNotFinished
+	}
+	if deletion, err := ts.GetTaskDeletion(taskID); err != nil {
+		return nil, err
+	} else if deletion != nil {
+		return nil, ErrTaskAlreadyDeleted
+	}
+
+	var uniqueID, retryOf string
+	var priority int
+	err = ts.db.DB().QueryRow(`SELECT COALESCE(telegram_file_unique_id, ''), COALESCE(retry_of, ''), COALESCE(priority, 0) FROM tasks WHERE id = ?`,
+		taskID).Scan(&uniqueID, &retryOf, &priority)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task retry details: %w", err)
+	}
+	if source.TelegramFileID == "" {
+		return nil, ErrTaskNoFileID
+	}
+	if retryOf == "" {
+		retryOf = source.ID
+	}
+
+	running, err := ts.unfinishedRetry(retryOf)
+	if err != nil {
+		return nil, err
+	}
+	if running != nil {
+		return nil, &RetryInProgressError{Task: running}
+	}
+
+	now := time.Now()
+	clone := &models.Task{
+		UserID:               source.UserID,
+		ChatID:               source.ChatID,
+		FileName:             source.FileName,
+		FileSize:             source.FileSize,
+		FileType:             source.FileType,
+		TelegramFileID:       source.TelegramFileID,
+		TelegramFileUniqueID: uniqueID,
+		RetryOf:              retryOf,
+		Status:               models.TaskStatusPending,
+		CreatedAt:            now,
+		UpdatedAt:            now,
+	}
+	if err := ts.Create(clone); err != nil {
+		return nil, err
+	}
+	if priority != models.TaskPriorityNormal {
+		if err := ts.SetPriority(clone.ID, priority); err != nil {
+			// Already claimed, the priority no longer matters
+			return clone, nil
+		}
+	}
+
+	origin, err := ts.GetTaskOrigin(source.ID)
+	if err != nil {
+		return clone, err
+	}
+	if origin != nil {
+		origin.TaskID = clone.ID
+		origin.CreatedAt = now
+		if err := ts.SaveTaskOrigin(origin); err != nil {
+			return clone, err
+		}
+	}
+	return clone, nil
+}
+
+// unfinishedRetry returns the task of an upload, first submitted as
+// originalID, that is still in the pipeline, or nil
+func (ts *TaskStore) unfinishedRetry(originalID string) (*models.Task, error) {
+	var id string
+	err := ts.db.DB().QueryRow(`SELECT id FROM tasks WHERE (id = ? OR retry_of = ?) AND status NOT IN (?, ?) LIMIT 1`,
+		originalID, originalID, models.TaskStatusCompleted, models.TaskStatusFailed).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for running retries: %w", err)
+	}
+	return ts.GetByID(id)
+}
+
+// GetDuplicateByFileHash returns a task other than taskID with the same file
+// hash, or nil. Retries of the same upload, and the upload itself, are not
+// duplicates of each other; deleted tasks are ignored
+func (ts *TaskStore) GetDuplicateByFileHash(fileHash, taskID string) (*models.Task, error) {
+	var id string
+	err := ts.db.DB().QueryRow(`
+		WITH upload AS (SELECT COALESCE(NULLIF(retry_of, ''), id) AS original FROM tasks WHERE id = ?)
+		SELECT id FROM tasks WHERE file_hash = ? AND id != ?
+			AND id NOT IN (SELECT original FROM upload)
+			AND COALESCE(retry_of, '') NOT IN (SELECT original FROM upload)
+			AND id NOT IN (SELECT task_id FROM task_deletions)
+		LIMIT 1`, taskID, fileHash, taskID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get duplicate by hash: %w", err)
+	}
+	return ts.GetByID(id)
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"telegram-archive-bot/models"
+)
+
+// StatsWindows are the time windows accepted by /stats detailed
+var StatsWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+// statsStages are the stages reported by GetTaskStats, in pipeline order
+var statsStages = []string{StageDownload, StageFastPath, StageExtraction, StageOCR, StageConversion, StageStore}
+
+// StageLatency summarizes the recorded runs of one pipeline stage
+type StageLatency struct {
+	Stage    string
+	Runs     int // Successful runs recorded in stage_timings
+	Items    int // Files handled by those runs; batch stages handle several per run
+	Failures int // Failed attempts recorded in task_errors
+	P50      time.Duration
+	P90      time.Duration
+	P99      time.Duration
+}
+
+// SuccessRate is the percentage of attempts that succeeded, 100 without attempts
+func (sl StageLatency) SuccessRate() float64 {
+	attempts := sl.Runs + sl.Failures
+	if attempts == 0 {
+		return 100
+	}
+	return float64(sl.Runs) / float64(attempts) * 100
+}
+
+// TaskStats are the task outcomes and stage latencies over a time window
+type TaskStats struct {
+	From           time.Time
+	To             time.Time
+	Completed      int
+	Failed         int
+	BytesProcessed int64 // Size of the files of completed tasks
+	Stages         []StageLatency
+}
+
+// SuccessRate is the percentage of finished tasks that completed, 100 without any
+func (s *TaskStats) SuccessRate() float64 {
+	finished := s.Completed + s.Failed
+	if finished == 0 {
+		return 100
+	}
+	return float64(s.Completed) / float64(finished) * 100
+}
+
+// ThroughputPerHour is the number of completed tasks per hour of the window
+func (s *TaskStats) ThroughputPerHour() float64 {
+	hours := s.To.Sub(s.From).Hours()
+	if hours <= 0 {
+		return 0
+	}
+	return float64(s.Completed) / hours
+}
+
+// GetTaskStats computes task outcomes and per-stage latency percentiles
+// between from and to from the persisted tasks, stage_timings and
+// task_errors tables, so the figures survive restarts
+func (ts *TaskStore) GetTaskStats(from, to time.Time) (*TaskStats, error) {
+	stats := &TaskStats{From: from, To: to}
+
+	rows, err := ts.db.DB().Query(`
+		SELECT status, COUNT(*), COALESCE(SUM(file_size), 0)
+		FROM tasks
+		WHERE completed_at >= ? AND completed_at < ? AND status IN (?, ?)
+		GROUP BY status`,
+		from, to, models.TaskStatusCompleted, models.TaskStatusFailed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task outcomes: %w", err)
+	}
+	for rows.Next() {
+		var status string
+		var count int
+		var bytes int64
+		if err := rows.Scan(&status, &count, &bytes); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan task outcomes: %w", err)
+		}
+		if models.TaskStatus(status) == models.TaskStatusCompleted {
+			stats.Completed = count
+			stats.BytesProcessed = bytes
+		} else {
+			stats.Failed = count
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read task outcomes: %w", err)
+	}
+
+	failures, err := ts.countStageFailures(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stage := range statsStages {
+		latency, err := ts.getStageLatency(stage, from, to)
+		if err != nil {
+			return nil, err
+		}
+		latency.Failures = failures[stage]
+		stats.Stages = append(stats.Stages, latency)
+	}
+	return stats, nil
+}
+
+// getStageLatency loads the stage's durations in the window and computes percentiles
+func (ts *TaskStore) getStageLatency(stage string, from, to time.Time) (StageLatency, error) {
+	latency := StageLatency{Stage: stage}
+
+	rows, err := ts.db.DB().Query(`
+		SELECT duration_ms, items FROM stage_timings
+		WHERE stage = ? AND recorded_at >= ? AND recorded_at < ?`,
+		stage, from, to)
+	if err != nil {
+		return latency, fmt.Errorf("failed to get %s timings: %w", stage, err)
+	}
+	defer rows.Close()
+
+	var durations []int64
+	for rows.Next() {
+		var durationMS int64
+		var items int
+		if err := rows.Scan(&durationMS, &items); err != nil {
+			return latency, fmt.Errorf("failed to scan %s timing: %w", stage, err)
+		}
+		durations = append(durations, durationMS)
+		latency.Items += items
+	}
+	if err := rows.Err(); err != nil {
+		return latency, fmt.Errorf("failed to read %s timings: %w", stage, err)
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	latency.Runs = len(durations)
+	latency.P50 = percentile(durations, 50)
+	latency.P90 = percentile(durations, 90)
+	latency.P99 = percentile(durations, 99)
+	return latency, nil
+}
+
+// countStageFailures counts failed attempts per stage in the window
+func (ts *TaskStore) countStageFailures(from, to time.Time) (map[string]int, error) {
+	rows, err := ts.db.DB().Query(`
+		SELECT stage, COUNT(*) FROM task_errors
+		WHERE occurred_at >= ? AND occurred_at < ?
+		GROUP BY stage`,
+		from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count stage failures: %w", err)
+	}
+	defer rows.Close()
+
+	failures := make(map[string]int)
+	for rows.Next() {
+		var stage string
+		var count int
+		if err := rows.Scan(&stage, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan stage failures: %w", err)
+		}
+		failures[stage] = count
+	}
+	return failures, rows.Err()
+}
+
+// percentile returns the nearest-rank percentile of sorted millisecond durations
+func percentile(sorted []int64, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return time.Duration(sorted[rank-1]) * time.Millisecond
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
@@ -8,10 +9,13 @@ import (
 	"time"
 
 	"telegram-archive-bot/models"
+	"telegram-archive-bot/utils"
 )
 
 type TaskStore struct {
-	db *Database
+	db       *Database
+	dispatch *TaskDispatch // Woken when a task becomes PENDING, may be nil
+	order    QueueOrder    // Order PENDING tasks are claimed in
 }
 
 func NewTaskStore(db *Database) *TaskStore {
@@ -24,18 +28,30 @@ func (ts *TaskStore) Create(task *models.Task) error {
 		task.ID = generateTaskID()
 	}
 	
+	if err := utils.Faults.Inject(context.Background(), utils.FaultDB); err != nil {
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+
 	query := `
-		INSERT INTO tasks (id, user_id, chat_id, file_name, file_size, file_type, file_hash, telegram_file_id, local_api_path, status, error_message, error_category, error_severity, retry_count, created_at, updated_at, completed_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO tasks (id, user_id, chat_id, file_name, file_size, file_type, file_hash, telegram_file_id, telegram_file_unique_id, local_api_path, retry_of, status, error_message, error_category, error_severity, retry_count, created_at, updated_at, completed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	_, err := ts.db.DB().Exec(query, 
 		task.ID, task.UserID, task.ChatID, task.FileName, task.FileSize, task.FileType, 
-		task.FileHash, task.TelegramFileID, task.LocalAPIPath, task.Status, task.ErrorMessage, task.ErrorCategory, 
+		task.FileHash, task.TelegramFileID, task.TelegramFileUniqueID, task.LocalAPIPath, task.RetryOf, task.Status, task.ErrorMessage, task.ErrorCategory, 
 		task.ErrorSeverity, task.RetryCount, task.CreatedAt, task.UpdatedAt, task.CompletedAt)
 	
 	if err != nil {
 		return fmt.Errorf("failed to create task: %w", err)
 	}
+
+	// A missing short ID is not fatal, GetShortID allocates one on first display
+	if shortID, err := ts.AssignShortID(task.ID); err == nil {
+		task.ShortID = shortID
+	}
+	if task.Status == models.TaskStatusPending {
+		ts.notifyPending()
+	}
 	return nil
 }
 
@@ -53,7 +69,7 @@ func (ts *TaskStore) GetByID(id string) (*models.Task, error) {
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("task not found")
+			return nil, ErrTaskNotFound
 		}
 		return nil, fmt.Errorf("failed to get task: %w", err)
 	}
@@ -68,6 +84,10 @@ func (ts *TaskStore) UpdateStatus(id string, status models.TaskStatus, errorMess
 		completedAt = &now
 	}
 	
+	if err := utils.Faults.Inject(context.Background(), utils.FaultDB); err != nil {
+		return fmt.Errorf("failed to update task status: %w", err)
+	}
+
 	query := `
 		UPDATE tasks 
 		SET status = ?, error_message = ?, updated_at = ?, completed_at = ?
@@ -86,6 +106,9 @@ func (ts *TaskStore) UpdateStatus(id string, status models.TaskStatus, errorMess
 	if rowsAffected == 0 {
 		return fmt.Errorf("task not found")
 	}
+	if status == models.TaskStatusPending {
+		ts.notifyPending()
+	}
 	
 	return nil
 }
@@ -99,6 +122,10 @@ func (ts *TaskStore) UpdateWithErrorInfo(id string, status models.TaskStatus, er
 		completedAt = &now
 	}
 	
+	if err := utils.Faults.Inject(context.Background(), utils.FaultDB); err != nil {
+		return fmt.Errorf("failed to update task with error info: %w", err)
+	}
+
 	query := `
 		UPDATE tasks 
 		SET status = ?, error_message = ?, error_category = ?, error_severity = ?, retry_count = ?, updated_at = ?, completed_at = ?
@@ -145,6 +172,8 @@ func (ts *TaskStore) IncrementRetryCount(id string) error {
 	return nil
 }
 
+// GetByStatus loads every task with the status at once; EachByStatus reads
+// them a page at a time
 func (ts *TaskStore) GetByStatus(status models.TaskStatus) ([]*models.Task, error) {
 	query := `
 		SELECT id, user_id, chat_id, file_name, file_size, file_type, file_hash, telegram_file_id, local_api_path, status, error_message, error_category, error_severity, retry_count, created_at, updated_at, completed_at
@@ -175,6 +204,78 @@ func (ts *TaskStore) GetByStatus(status models.TaskStatus) ([]*models.Task, erro
 	return tasks, nil
 }
 
+// TaskCursor marks where a page of GetByStatusPage ended. The zero cursor
+// starts at the oldest task
+type TaskCursor struct {
+	createdAt string
+	id        string
+}
+
+// taskPageSize is how many tasks EachByStatus reads at a time
+const taskPageSize = 200
+
+// GetByStatusPage returns up to limit tasks with the status that come after
+// cursor, oldest first, and the cursor to continue from. A page shorter than
+// limit is the last one. Pages are keyed on creation time and ID rather than
+// an offset, so tasks leaving the status between pages don't shift later
+// pages and every page is a range scan of idx_tasks_status_created
+func (ts *TaskStore) GetByStatusPage(status models.TaskStatus, cursor TaskCursor, limit int) ([]*models.Task, TaskCursor, error) {
+	query := `
+		SELECT id, user_id, chat_id, file_name, file_size, file_type, file_hash, telegram_file_id, local_api_path, status, error_message, error_category, error_severity, retry_count, created_at, updated_at, completed_at, CAST(created_at AS TEXT)
+		FROM tasks WHERE status = ? AND (created_at, id) > (?, ?)
+		ORDER BY created_at ASC, id ASC LIMIT ?
+	`
+	rows, err := ts.db.DB().Query(query, status, cursor.createdAt, cursor.id, limit)
+	if err != nil {
+		return nil, cursor, fmt.Errorf("failed to query page of tasks by status: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []*models.Task
+	for rows.Next() {
+		task := &models.Task{}
+		err := rows.Scan(&task.ID, &task.UserID, &task.ChatID, &task.FileName, &task.FileSize,
+			&task.FileType, &task.FileHash, &task.TelegramFileID, &task.LocalAPIPath, &task.Status, &task.ErrorMessage,
+			&task.ErrorCategory, &task.ErrorSeverity, &task.RetryCount, &task.CreatedAt, &task.UpdatedAt, &task.CompletedAt,
+			&cursor.createdAt)
+		if err != nil {
+			return nil, cursor, fmt.Errorf("failed to scan task: %w", err)
+		}
+		cursor.id = task.ID
+		tasks = append(tasks, task)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, cursor, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return tasks, cursor, nil
+}
+
+// EachByStatus calls fn for every task with the status, oldest first,
+// holding one page of tasks in memory at a time. fn may change the task's
+// status; no task is visited twice. An error from fn stops the iteration
+// and is returned
+func (ts *TaskStore) EachByStatus(status models.TaskStatus, fn func(task *models.Task) error) error {
+	var cursor TaskCursor
+	for {
+		tasks, next, err := ts.GetByStatusPage(status, cursor, taskPageSize)
+		if err != nil {
+			return err
+		}
+		// The page's rows are closed, so fn is free to write to the database
+		for _, task := range tasks {
+			if err := fn(task); err != nil {
+				return err
+			}
+		}
+		if len(tasks) < taskPageSize {
+			return nil
+		}
+		cursor = next
+	}
+}
+
 func (ts *TaskStore) GetTasksByStatus(status models.TaskStatus, limit int) ([]*models.Task, error) {
 	query := `
 		SELECT id, user_id, chat_id, file_name, file_size, file_type, file_hash, telegram_file_id, local_api_path, status, error_message, error_category, error_severity, retry_count, created_at, updated_at, completed_at
@@ -208,7 +309,7 @@ func (ts *TaskStore) GetTasksByStatus(status models.TaskStatus, limit int) ([]*m
 func (ts *TaskStore) GetByFileHash(fileHash string) (*models.Task, error) {
 	query := `
 		SELECT id, user_id, chat_id, file_name, file_size, file_type, file_hash, telegram_file_id, local_api_path, status, error_message, error_category, error_severity, retry_count, created_at, updated_at, completed_at
-		FROM tasks WHERE file_hash = ? LIMIT 1
+		FROM tasks WHERE file_hash = ? AND id NOT IN (SELECT task_id FROM task_deletions) LIMIT 1
 	`
 	row := ts.db.DB().QueryRow(query, fileHash)
 	
@@ -226,6 +327,37 @@ func (ts *TaskStore) GetByFileHash(fileHash string) (*models.Task, error) {
 	return task, nil
 }
 
+// GetByFileUniqueID returns the latest task that was not FAILED for a Telegram
+// file_unique_id, or nil. It catches re-forwards of the same file before
+// anything is downloaded; failed and deleted tasks are ignored so a file can
+// be resent
+func (ts *TaskStore) GetByFileUniqueID(fileUniqueID string) (*models.Task, error) {
+	if fileUniqueID == "" {
+		return nil, nil
+	}
+
+	query := `
+		SELECT id, user_id, chat_id, file_name, file_size, file_type, file_hash, telegram_file_id, telegram_file_unique_id, local_api_path, status, error_message, error_category, error_severity, retry_count, created_at, updated_at, completed_at
+		FROM tasks WHERE telegram_file_unique_id = ? AND status != ?
+			AND id NOT IN (SELECT task_id FROM task_deletions)
+		ORDER BY created_at DESC LIMIT 1
+	`
+	row := ts.db.DB().QueryRow(query, fileUniqueID, models.TaskStatusFailed)
+
+	task := &models.Task{}
+	err := row.Scan(&task.ID, &task.UserID, &task.ChatID, &task.FileName, &task.FileSize,
+		&task.FileType, &task.FileHash, &task.TelegramFileID, &task.TelegramFileUniqueID, &task.LocalAPIPath, &task.Status, &task.ErrorMessage,
+		&task.ErrorCategory, &task.ErrorSeverity, &task.RetryCount, &task.CreatedAt, &task.UpdatedAt, &task.CompletedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // No duplicate found
+		}
+		return nil, fmt.Errorf("failed to get task by file unique ID: %w", err)
+	}
+	return task, nil
+}
+
 func (ts *TaskStore) GetStats() (map[string]int, error) {
 	query := `
 		SELECT status, COUNT(*) as count
@@ -259,6 +391,10 @@ func (ts *TaskStore) GetStats() (map[string]int, error) {
 func (ts *TaskStore) UpdateTask(task *models.Task) error {
 	task.UpdatedAt = time.Now()
 	
+	if err := utils.Faults.Inject(context.Background(), utils.FaultDB); err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+
 	query := `
 		UPDATE tasks 
 		SET user_id=?, chat_id=?, file_name=?, file_size=?, file_type=?, file_hash=?, 
@@ -282,6 +418,11 @@ func (ts *TaskStore) GetDB() *sql.DB {
 	return ts.db.DB()
 }
 
+// SchemaVersion returns the latest migration applied to the task database
+func (ts *TaskStore) SchemaVersion() (int, error) {
+	return ts.db.SchemaVersion()
+}
+
 // generateTaskID creates a random task ID
 func generateTaskID() string {
 	bytes := make([]byte, 8)
@@ -289,19 +430,22 @@ func generateTaskID() string {
 	return hex.EncodeToString(bytes)
 }
 
-// GetPendingTasks returns up to 'limit' tasks with PENDING status, ordered by creation time
+// GetPendingTasks returns up to 'limit' tasks with PENDING status, in the
+// order they are claimed in
 func (ts *TaskStore) GetPendingTasks(limit int) ([]*models.Task, error) {
+	order, orderArgs := ts.pendingOrder()
 	query := `
 		SELECT id, user_id, chat_id, file_name, file_size, file_type, file_hash,
 		       telegram_file_id, local_api_path, status, error_message, error_category,
 		       error_severity, retry_count, created_at, updated_at, completed_at
 		FROM tasks
 		WHERE status = ?
-		ORDER BY created_at ASC
+		ORDER BY ` + order + `
 		LIMIT ?
 	`
 
-	rows, err := ts.db.DB().Query(query, models.TaskStatusPending, limit)
+	args := append(append([]interface{}{models.TaskStatusPending}, orderArgs...), limit)
+	rows, err := ts.db.DB().Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query pending tasks: %w", err)
 	}
@@ -391,4 +535,59 @@ func (ts *TaskStore) GetTaskCountByStatus(status models.TaskStatus) (int, error)
 		return 0, fmt.Errorf("failed to count tasks by status: %w", err)
 	}
 	return count, nil
-}
\ No newline at end of file
+}
+// SetPriority changes the download priority of a task that is still queued
+func (ts *TaskStore) SetPriority(taskID string, priority int) error {
+	query := `UPDATE tasks SET priority = ?, updated_at = ? WHERE id = ? AND status = ?`
+	result, err := ts.db.DB().Exec(query, priority, time.Now(), taskID, models.TaskStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to set task priority: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("task is not pending")
+	}
+
+	return nil
+}
+
+// GetPriority returns the download priority of a task
+func (ts *TaskStore) GetPriority(taskID string) (int, error) {
+	var priority int
+	err := ts.db.DB().QueryRow(`SELECT COALESCE(priority, 0) FROM tasks WHERE id = ?`, taskID).Scan(&priority)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get task priority: %w", err)
+	}
+	return priority, nil
+}
+
+// CancelTask marks a queued task as failed so it is never downloaded
+func (ts *TaskStore) CancelTask(taskID string) error {
+	now := time.Now()
+	query := `
+		UPDATE tasks
+		SET status = ?, error_message = ?, error_category = ?, updated_at = ?, completed_at = ?
+		WHERE id = ? AND status = ?
+	`
+	result, err := ts.db.DB().Exec(query, models.TaskStatusFailed, "Cancelled by admin", "cancelled",
+		now, now, taskID, models.TaskStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to cancel task: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("task is not pending")
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DownloadTraffic is the network usage of a task's downloads
+type DownloadTraffic struct {
+	DownloadedBytes int64 // File bytes fetched through the Bot API server
+	BotAPIBytes     int64 // HTTP exchanged with the Bot API server for the task
+}
+
+// BandwidthUsage is the download traffic of the tasks downloaded in a window
+type BandwidthUsage struct {
+	From            time.Time
+	To              time.Time
+	Tasks           int
+	DownloadedBytes int64
+	BotAPIBytes     int64
+}
+
+// RecordDownloadTraffic adds a download's traffic to the task's totals, so a
+// task downloaded again after a failure counts every transfer
+func (ts *TaskStore) RecordDownloadTraffic(taskID string, traffic DownloadTraffic) error {
+	_, err := ts.db.DB().Exec(`
+		UPDATE tasks SET downloaded_bytes = downloaded_bytes + ?, bot_api_bytes = bot_api_bytes + ?, downloaded_at = ?
+		WHERE id = ?`,
+		traffic.DownloadedBytes, traffic.BotAPIBytes, time.Now(), taskID)
+	if err != nil {
+		return fmt.Errorf("failed to record download traffic: %w", err)
+	}
+	return nil
+}
+
+// GetTaskTraffic returns a task's download traffic, nil if it was never downloaded
+func (ts *TaskStore) GetTaskTraffic(taskID string) (*DownloadTraffic, error) {
+	traffic := &DownloadTraffic{}
+	var downloadedAt sql.NullTime
+	err := ts.db.DB().QueryRow(`
+		SELECT COALESCE(downloaded_bytes, 0), COALESCE(bot_api_bytes, 0), downloaded_at
+		FROM tasks WHERE id = ?`, taskID).Scan(&traffic.DownloadedBytes, &traffic.BotAPIBytes, &downloadedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task traffic: %w", err)
+	}
+	if !downloadedAt.Valid {
+		return nil, nil
+	}
+	return traffic, nil
+}
+
+// GetBandwidthUsage sums the traffic of the tasks last downloaded between from and to
+func (ts *TaskStore) GetBandwidthUsage(from, to time.Time) (*BandwidthUsage, error) {
+	usage := &BandwidthUsage{From: from, To: to}
+	err := ts.db.DB().QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(downloaded_bytes), 0), COALESCE(SUM(bot_api_bytes), 0)
+		FROM tasks WHERE downloaded_at >= ? AND downloaded_at < ?`, from, to).
+		Scan(&usage.Tasks, &usage.DownloadedBytes, &usage.BotAPIBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bandwidth usage: %w", err)
+	}
+	return usage, nil
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ProcessedUpdateRetention is how long handled update IDs are kept for replay
+// protection; Telegram itself drops unconfirmed updates after 24 hours
+const ProcessedUpdateRetention = 48 * time.Hour
+
+// GetUpdateOffset returns the getUpdates offset saved for a bot, 0 when none
+// has been saved yet
+func (ts *TaskStore) GetUpdateOffset(botID int64) (int, error) {
+	var offset int
+	err := ts.db.DB().QueryRow(`SELECT next_offset FROM bot_update_offsets WHERE bot_id = ?`, botID).Scan(&offset)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get update offset: %w", err)
+	}
+	return offset, nil
+}
+
+// SaveUpdateOffset stores the next getUpdates offset for a bot. The offset
+// only moves forward, so a lagging instance cannot rewind it
+func (ts *TaskStore) SaveUpdateOffset(botID int64, offset int) error {
+	_, err := ts.db.DB().Exec(`
+		INSERT INTO bot_update_offsets (bot_id, next_offset, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(bot_id) DO UPDATE SET
+			next_offset = MAX(next_offset, excluded.next_offset),
+			updated_at = excluded.updated_at`,
+		botID, offset, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save update offset: %w", err)
+	}
+	return nil
+}
+
+// UpdateClaimTimeout is how long an unfinished claim keeps an update from
+// being handled elsewhere. A claim left behind by a process that died while
+// handling the update is taken over after it
+const UpdateClaimTimeout = 10 * time.Minute
+
+// UpdateClaim is the outcome of claiming an update
+type UpdateClaim int
+
+const (
+	// UpdateClaimed means the caller claimed the update and should handle it
+	UpdateClaimed UpdateClaim = iota
+	// UpdateHandled means the update was handled already
+	UpdateHandled
+	// UpdateBusy means another handler claimed the update and has not finished
+	UpdateBusy
+)
+
+// ClaimUpdate claims an update for handling. The claim holds until
+// CompleteUpdate records the update as handled or ReleaseUpdate gives it up
+func (ts *TaskStore) ClaimUpdate(botID int64, updateID int) (UpdateClaim, error) {
+	now := time.Now()
+	result, err := ts.db.DB().Exec(`
+		INSERT INTO processed_updates (bot_id, update_id, processed_at, completed)
+		VALUES (?, ?, ?, 0)
+		ON CONFLICT(bot_id, update_id) DO UPDATE SET processed_at = excluded.processed_at
+		WHERE completed = 0 AND processed_at < ?`,
+		botID, updateID, now, now.Add(-UpdateClaimTimeout))
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim update: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim update: %w", err)
+	}
+	if rows == 1 {
+		return UpdateClaimed, nil
+	}
+
+	var completed bool
+	err = ts.db.DB().QueryRow(`SELECT completed FROM processed_updates WHERE bot_id = ? AND update_id = ?`,
+		botID, updateID).Scan(&completed)
+	if err == sql.ErrNoRows {
+		// Released between the two statements
+		return UpdateBusy, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to check update claim: %w", err)
+	}
+	if completed {
+		return UpdateHandled, nil
+	}
+	return UpdateBusy, nil
+}
+
+// CompleteUpdate records a claimed update as handled, so it is skipped when
+// Telegram delivers it again
+func (ts *TaskStore) CompleteUpdate(botID int64, updateID int) error {
+	_, err := ts.db.DB().Exec(`
+		UPDATE processed_updates SET completed = 1, processed_at = ?
+		WHERE bot_id = ? AND update_id = ?`,
+		time.Now(), botID, updateID)
+	if err != nil {
+		return fmt.Errorf("failed to complete update: %w", err)
+	}
+	return nil
+}
+
+// ReleaseUpdate gives up an unfinished claim, e.g. after its handler failed,
+// so the update can be claimed again
+func (ts *TaskStore) ReleaseUpdate(botID int64, updateID int) error {
+	_, err := ts.db.DB().Exec(`
+		DELETE FROM processed_updates WHERE bot_id = ? AND update_id = ? AND completed = 0`,
+		botID, updateID)
+	if err != nil {
+		return fmt.Errorf("failed to release update: %w", err)
+	}
+	return nil
+}
+
+// PruneProcessedUpdates deletes claims older than retention and returns how
+// many were removed
+func (ts *TaskStore) PruneProcessedUpdates(retention time.Duration) (int64, error) {
+	result, err := ts.db.DB().Exec(`DELETE FROM processed_updates WHERE processed_at < ?`, time.Now().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune processed updates: %w", err)
+	}
+	return result.RowsAffected()
+}
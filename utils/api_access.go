@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Permission scopes of HTTP API clients
+const (
+	APIScopeReports = "reports" // SLA, health history, archive metadata and analytics
+	APIScopeAudit   = "audit"   // Admin audit trail and security events
+	APIScopePprof   = "pprof"   // /debug/pprof/
+	APIScopeAll     = "*"
+)
+
+var apiScopes = map[string]bool{APIScopeReports: true, APIScopeAudit: true, APIScopePprof: true, APIScopeAll: true}
+
+// APIClient is a caller of the HTTP API, identified by an API key or by the
+// common name of a verified client certificate
+type APIClient struct {
+	Name     string
+	Key      string
+	CertName string
+	Scopes   []string
+}
+
+// HasScope reports whether the client was granted the scope
+func (c APIClient) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope || s == APIScopeAll {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAPIKeys parses comma-separated "name:key:scope|scope" entries
+func ParseAPIKeys(v string) ([]APIClient, error) {
+	var clients []APIClient
+	for _, entry := range splitList(v) {
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("expected name:key:scopes, got %q", entry)
+		}
+		scopes, err := parseAPIScopes(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", parts[0], err)
+		}
+		clients = append(clients, APIClient{Name: parts[0], Key: parts[1], Scopes: scopes})
+	}
+	return clients, nil
+}
+
+// ParseAPIClientCerts parses comma-separated "common-name:scope|scope"
+// entries; the common name is also the client's name
+func ParseAPIClientCerts(v string) ([]APIClient, error) {
+	var clients []APIClient
+	for _, entry := range splitList(v) {
+		name, scopeList, ok := strings.Cut(entry, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("expected common-name:scopes, got %q", entry)
+		}
+		scopes, err := parseAPIScopes(scopeList)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		clients = append(clients, APIClient{Name: name, CertName: name, Scopes: scopes})
+	}
+	return clients, nil
+}
+
+func parseAPIScopes(v string) ([]string, error) {
+	scopes := strings.Split(v, "|")
+	for _, scope := range scopes {
+		if !apiScopes[scope] {
+			return nil, fmt.Errorf("unknown scope %q (use reports, audit, pprof or *)", scope)
+		}
+	}
+	return scopes, nil
+}
+
+// splitList splits a comma-separated setting, dropping empty entries
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// IPAllowlist is a set of addresses and networks allowed to connect
+type IPAllowlist struct {
+	networks []*net.IPNet
+}
+
+// ParseIPAllowlist parses comma-separated IPs and CIDR networks; an empty
+// list returns nil, which allows every address
+func ParseIPAllowlist(v string) (*IPAllowlist, error) {
+	entries := splitList(v)
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	list := &IPAllowlist{}
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", entry)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			entry = fmt.Sprintf("%s/%d", entry, bits)
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", entry)
+		}
+		list.networks = append(list.networks, network)
+	}
+	return list, nil
+}
+
+// Allows reports whether addr, an IP or host:port, is on the list
+func (l *IPAllowlist) Allows(addr string) bool {
+	if l == nil {
+		return true
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range l.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadServerTLS loads a server certificate. With clientCAFile, client
+// certificates signed by it are verified, and required when requireClientCert is set
+func LoadServerTLS(certFile, keyFile, clientCAFile string, requireClientCert bool) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	if clientCAFile != "" {
+		pool, err := loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if requireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return config, nil
+}
+
+// LoadClientTLS builds a client TLS config that trusts caFile (the system
+// roots when empty) and presents certFile/keyFile when set
+func LoadClientTLS(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nwaples/rardecode"
+)
+
+const (
+	// maxArchiveInspectEntries stops inspection of archives with absurd entry counts
+	maxArchiveInspectEntries = 200000
+	// maxArchiveCommentLength caps the archive comment kept in the metadata
+	maxArchiveCommentLength = 1024
+)
+
+// zipMethodNames names the ZIP compression methods seen in practice
+var zipMethodNames = map[uint16]string{
+	0:  "store",
+	8:  "deflate",
+	9:  "deflate64",
+	12: "bzip2",
+	14: "lzma",
+	93: "zstd",
+	95: "xz",
+	98: "ppmd",
+	99: "aes",
+}
+
+// ArchiveMetadata describes an archive's entries without extracting them.
+// RAR archives do not expose compression methods or per-entry encryption,
+// so Methods and EncryptedEntries are only filled in for ZIP
+type ArchiveMetadata struct {
+	Format           string         `json:"format"`
+	Entries          int            `json:"entries"`
+	Directories      int            `json:"directories"`
+	UncompressedSize int64          `json:"uncompressed_size"`
+	CompressedSize   int64          `json:"compressed_size"`
+	MaxEntryRatio    float64        `json:"max_entry_ratio"` // Highest uncompressed/compressed ratio of one entry
+	Methods          map[string]int `json:"methods,omitempty"`
+	EncryptedEntries int            `json:"encrypted_entries"`
+	Encrypted        bool           `json:"encrypted"` // Entries or headers need a password
+	Comment          string         `json:"comment,omitempty"`
+	EntryComments    int            `json:"entry_comments"`
+	Partial          bool           `json:"partial"` // Inspection stopped early, see Error
+	Error            string         `json:"error,omitempty"`
+}
+
+// Ratio is the overall uncompressed/compressed size ratio, 0 when unknown
+func (m *ArchiveMetadata) Ratio() float64 {
+	if m.CompressedSize <= 0 {
+		return 0
+	}
+	return float64(m.UncompressedSize) / float64(m.CompressedSize)
+}
+
+// InspectArchive reads the metadata of a ZIP or RAR archive. ZIP only needs
+// its central directory; RAR headers are read in sequence, so a damaged or
+// password-protected RAR returns what was read so far with Partial set
+func InspectArchive(path, fileType string) (*ArchiveMetadata, error) {
+	switch strings.ToLower(fileType) {
+	case "zip":
+		return inspectZip(path)
+	case "rar":
+		return inspectRar(path)
+	default:
+		return nil, fmt.Errorf("unsupported archive type: %s", fileType)
+	}
+}
+
+func inspectZip(path string) (*ArchiveMetadata, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zip directory: %w", err)
+	}
+	defer reader.Close()
+
+	meta := &ArchiveMetadata{
+		Format:  "zip",
+		Methods: make(map[string]int),
+		Comment: truncateArchiveComment(reader.Comment),
+	}
+	for i, file := range reader.File {
+		if i >= maxArchiveInspectEntries {
+			meta.Partial = true
+			meta.Error = fmt.Sprintf("stopped after %d entries", maxArchiveInspectEntries)
+			break
+		}
+
+		meta.Entries++
+		if file.FileInfo().IsDir() {
+			meta.Directories++
+			continue
+		}
+
+		method, ok := zipMethodNames[file.Method]
+		if !ok {
+			method = fmt.Sprintf("method-%d", file.Method)
+		}
+		meta.Methods[method]++
+		if file.Flags&0x1 != 0 {
+			meta.EncryptedEntries++
+			meta.Encrypted = true
+		}
+		if file.Comment != "" {
+			meta.EntryComments++
+		}
+		meta.addEntry(int64(file.UncompressedSize64), int64(file.CompressedSize64))
+	}
+	return meta, nil
+}
+
+func inspectRar(path string) (*ArchiveMetadata, error) {
+	reader, err := rardecode.OpenReader(path, "")
+	if err != nil {
+		if isRarPasswordError(err) {
+			return &ArchiveMetadata{Format: "rar", Encrypted: true, Partial: true, Error: err.Error()}, nil
+		}
+		return nil, fmt.Errorf("failed to open rar: %w", err)
+	}
+	defer reader.Close()
+
+	meta := &ArchiveMetadata{Format: "rar"}
+	for {
+		if meta.Entries >= maxArchiveInspectEntries {
+			meta.Partial = true
+			meta.Error = fmt.Sprintf("stopped after %d entries", maxArchiveInspectEntries)
+			break
+		}
+
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// Encrypted solid archives fail once data has to be decoded
+			meta.Partial = true
+			meta.Error = err.Error()
+			meta.Encrypted = meta.Encrypted || isRarPasswordError(err)
+			break
+		}
+
+		meta.Entries++
+		if header.IsDir {
+			meta.Directories++
+			continue
+		}
+		if !header.UnKnownSize {
+			meta.addEntry(header.UnPackedSize, header.PackedSize)
+		} else {
+			meta.CompressedSize += header.PackedSize
+		}
+	}
+	return meta, nil
+}
+
+// addEntry adds a file entry's sizes and tracks the highest ratio
+func (m *ArchiveMetadata) addEntry(uncompressed, compressed int64) {
+	m.UncompressedSize += uncompressed
+	m.CompressedSize += compressed
+	if compressed > 0 {
+		if ratio := float64(uncompressed) / float64(compressed); ratio > m.MaxEntryRatio {
+			m.MaxEntryRatio = ratio
+		}
+	}
+}
+
+func isRarPasswordError(err error) bool {
+	text := strings.ToLower(err.Error())
+	return strings.Contains(text, "password") || strings.Contains(text, "encrypt")
+}
+
+func truncateArchiveComment(comment string) string {
+	runes := []rune(comment)
+	if len(runes) <= maxArchiveCommentLength {
+		return comment
+	}
+	return string(runes[:maxArchiveCommentLength]) + "…"
+}
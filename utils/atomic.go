@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PartialSuffix marks files that are still being written and must be ignored by directory scans
+const PartialSuffix = ".partial"
+
+// AtomicFile writes to a .partial file next to its destination and only
+// renames it into place on Commit, so readers never see partial content
+type AtomicFile struct {
+	*os.File
+	path        string
+	partialPath string
+	done        bool
+}
+
+// CreateAtomic creates an AtomicFile that will be placed at path on Commit
+func CreateAtomic(path string, perm os.FileMode) (*AtomicFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	partialPath := PartialPath(path)
+	f, err := os.OpenFile(partialPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create partial file: %w", err)
+	}
+
+	return &AtomicFile{File: f, path: path, partialPath: partialPath}, nil
+}
+
+// Path returns the final destination of the file
+func (af *AtomicFile) Path() string {
+	return af.path
+}
+
+// Commit syncs the partial file and renames it to its final destination
+func (af *AtomicFile) Commit() error {
+	if af.done {
+		return fmt.Errorf("atomic file %s already finished", af.path)
+	}
+	af.done = true
+
+	if err := af.File.Sync(); err != nil {
+		af.File.Close()
+		os.Remove(af.partialPath)
+		return fmt.Errorf("failed to sync partial file: %w", err)
+	}
+	if err := af.File.Close(); err != nil {
+		os.Remove(af.partialPath)
+		return fmt.Errorf("failed to close partial file: %w", err)
+	}
+	if err := os.Rename(af.partialPath, af.path); err != nil {
+		os.Remove(af.partialPath)
+		return fmt.Errorf("failed to rename partial file into place: %w", err)
+	}
+	syncDir(filepath.Dir(af.path))
+
+	return nil
+}
+
+// Abort discards the partial file; it is a no-op after Commit
+func (af *AtomicFile) Abort() {
+	if af.done {
+		return
+	}
+	af.done = true
+	af.File.Close()
+	os.Remove(af.partialPath)
+}
+
+// WriteFileAtomic writes data to path via a .partial file and rename
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	af, err := CreateAtomic(path, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := af.Write(data); err != nil {
+		af.Abort()
+		return fmt.Errorf("failed to write partial file: %w", err)
+	}
+	return af.Commit()
+}
+
+// PartialPath returns the in-progress name used while writing path
+func PartialPath(path string) string {
+	return path + PartialSuffix
+}
+
+// IsPartialFile reports whether name is an in-progress atomic write
+func IsPartialFile(name string) bool {
+	return strings.HasSuffix(name, PartialSuffix)
+}
+
+// CleanupPartialFiles removes .partial files older than maxAge left behind by
+// interrupted writes and returns how many were removed
+func CleanupPartialFiles(dir string, maxAge time.Duration) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	removed := 0
+	cutoff := time.Now().Add(-maxAge)
+	for _, entry := range entries {
+		if entry.IsDir() || !IsPartialFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err == nil {
+			removed++
+		}
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,313 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BandwidthWindow limits transfers to Rate bytes per second between Start and
+// End (minutes after midnight) on the given weekdays; a window whose end is
+// before its start runs past midnight. Rate 0 means unlimited
+type BandwidthWindow struct {
+	Weekdays []time.Weekday // Empty for every day
+	Start    int
+	End      int
+	Rate     int64
+}
+
+// BandwidthSchedule is the bandwidth limit by time of day; the first matching
+// window applies, otherwise Default
+type BandwidthSchedule struct {
+	Windows []BandwidthWindow
+	Default int64
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseBandwidthSchedule parses a comma-separated list such as
+// "mon-fri 09:00-18:00=20MB,default=100MB". Each window is an optional day
+// or day range, a time range and a rate per second; "unlimited" or 0 lifts
+// the limit. The optional default applies outside all windows
+func ParseBandwidthSchedule(s string) (*BandwidthSchedule, error) {
+	schedule := &BandwidthSchedule{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		spec, rateText, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid bandwidth limit %q: expected <window>=<rate>", entry)
+		}
+		rate, err := ParseBandwidthRate(rateText)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate in %q: %w", entry, err)
+		}
+
+		spec = strings.ToLower(strings.TrimSpace(spec))
+		if spec == "default" {
+			schedule.Default = rate
+			continue
+		}
+
+		window := BandwidthWindow{Rate: rate}
+		fields := strings.Fields(spec)
+		switch len(fields) {
+		case 1:
+		case 2:
+			window.Weekdays, err = parseWeekdayRange(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid days in %q: %w", entry, err)
+			}
+		default:
+			return nil, fmt.Errorf("invalid bandwidth window %q", entry)
+		}
+
+		startText, endText, ok := strings.Cut(fields[len(fields)-1], "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid time range in %q: expected HH:MM-HH:MM", entry)
+		}
+		if window.Start, err = parseClock(startText); err != nil {
+			return nil, fmt.Errorf("invalid start time in %q: %w", entry, err)
+		}
+		if window.End, err = parseClock(endText); err != nil {
+			return nil, fmt.Errorf("invalid end time in %q: %w", entry, err)
+		}
+		if window.Start == window.End {
+			return nil, fmt.Errorf("empty time range in %q", entry)
+		}
+		schedule.Windows = append(schedule.Windows, window)
+	}
+	return schedule, nil
+}
+
+// ParseBandwidthRate parses a rate per second such as "20MB", "512KB/s" or
+// "unlimited" (0)
+func ParseBandwidthRate(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "/s")
+	if s == "unlimited" || s == "off" {
+		return 0, nil
+	}
+	rate, err := parseByteSize(s)
+	if err != nil || rate < 0 {
+		return 0, fmt.Errorf("expected a size per second such as 20MB, or unlimited")
+	}
+	return rate, nil
+}
+
+// FormatBandwidthRate renders a rate per second for messages
+func FormatBandwidthRate(rate int64) string {
+	switch {
+	case rate <= 0:
+		return "unlimited"
+	case rate >= 1<<30:
+		return fmt.Sprintf("%.1f GB/s", float64(rate)/(1<<30))
+	case rate >= 1<<20:
+		return fmt.Sprintf("%.1f MB/s", float64(rate)/(1<<20))
+	default:
+		return fmt.Sprintf("%.1f KB/s", float64(rate)/(1<<10))
+	}
+}
+
+// RateAt returns the limit in effect at t and the window that set it, nil for the default
+func (bs *BandwidthSchedule) RateAt(t time.Time) (int64, *BandwidthWindow) {
+	minute := t.Hour()*60 + t.Minute()
+	for i := range bs.Windows {
+		w := &bs.Windows[i]
+		day := t.Weekday()
+		inRange := minute >= w.Start && minute < w.End
+		if w.End < w.Start {
+			// After midnight the window still belongs to the day it started
+			inRange = minute >= w.Start || minute < w.End
+			if minute < w.End {
+				day = (day + 6) % 7
+			}
+		}
+		if inRange && w.onDay(day) {
+			return w.Rate, w
+		}
+	}
+	return bs.Default, nil
+}
+
+// String renders the window in the format ParseBandwidthSchedule accepts
+func (w BandwidthWindow) String() string {
+	days := ""
+	if len(w.Weekdays) > 0 {
+		first := strings.ToLower(w.Weekdays[0].String()[:3])
+		last := strings.ToLower(w.Weekdays[len(w.Weekdays)-1].String()[:3])
+		days = first + " "
+		if first != last {
+			days = first + "-" + last + " "
+		}
+	}
+	return fmt.Sprintf("%s%02d:%02d-%02d:%02d", days, w.Start/60, w.Start%60, w.End/60, w.End%60)
+}
+
+func (w *BandwidthWindow) onDay(day time.Weekday) bool {
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range w.Weekdays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseWeekdayRange parses "mon" or a range such as "mon-fri" or "fri-mon"
+func parseWeekdayRange(s string) ([]time.Weekday, error) {
+	firstText, lastText, isRange := strings.Cut(s, "-")
+	first, ok := weekdayNames[firstText]
+	if !ok {
+		return nil, fmt.Errorf("unknown day %q", firstText)
+	}
+	if !isRange {
+		return []time.Weekday{first}, nil
+	}
+	last, ok := weekdayNames[lastText]
+	if !ok {
+		return nil, fmt.Errorf("unknown day %q", lastText)
+	}
+
+	days := []time.Weekday{first}
+	for d := first; d != last; {
+		d = (d + 1) % 7
+		days = append(days, d)
+	}
+	return days, nil
+}
+
+// parseClock parses HH:MM into minutes after midnight; 24:00 is allowed as an end
+func parseClock(s string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(strings.TrimSpace(s), "%d:%d", &hour, &minute); err != nil {
+		return 0, fmt.Errorf("expected HH:MM")
+	}
+	if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("time out of range")
+	}
+	return hour*60 + minute, nil
+}
+
+// BandwidthLimiter is a token bucket shared by all throttled copy loops. The
+// rate follows the schedule unless an admin override is active
+type BandwidthLimiter struct {
+	mu            sync.Mutex
+	schedule      *BandwidthSchedule
+	override      *int64
+	overrideUntil time.Time // Zero while the override has no expiry
+	tokens        float64
+	last          time.Time
+}
+
+// NewBandwidthLimiter creates a limiter following the schedule; nil means unlimited
+func NewBandwidthLimiter(schedule *BandwidthSchedule) *BandwidthLimiter {
+	if schedule == nil {
+		schedule = &BandwidthSchedule{}
+	}
+	return &BandwidthLimiter{schedule: schedule, last: time.Now()}
+}
+
+// Wait blocks until n bytes may be transferred. Callers may overdraw the
+// bucket by one chunk; the next caller then waits until it is repaid
+func (bl *BandwidthLimiter) Wait(n int) {
+	if bl == nil {
+		return
+	}
+
+	bl.mu.Lock()
+	now := time.Now()
+	rate, _ := bl.rateLocked(now)
+	elapsed := now.Sub(bl.last).Seconds()
+	bl.last = now
+	if rate <= 0 {
+		bl.tokens = 0
+		bl.mu.Unlock()
+		return
+	}
+
+	// Allow up to one second of burst
+	bl.tokens += elapsed * float64(rate)
+	if bl.tokens > float64(rate) {
+		bl.tokens = float64(rate)
+	}
+	bl.tokens -= float64(n)
+	var wait time.Duration
+	if bl.tokens < 0 {
+		wait = time.Duration(-bl.tokens / float64(rate) * float64(time.Second))
+	}
+	bl.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// Rate returns the current limit and where it comes from: "override",
+// "default" or the matching window
+func (bl *BandwidthLimiter) Rate() (int64, string) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	return bl.rateLocked(time.Now())
+}
+
+func (bl *BandwidthLimiter) rateLocked(now time.Time) (int64, string) {
+	if bl.override != nil {
+		if bl.overrideUntil.IsZero() || now.Before(bl.overrideUntil) {
+			return *bl.override, "override"
+		}
+		bl.override = nil
+		bl.overrideUntil = time.Time{}
+	}
+	rate, window := bl.schedule.RateAt(now)
+	if window == nil {
+		return rate, "default"
+	}
+	return rate, window.String()
+}
+
+// SetOverride replaces the scheduled limit with rate (0 for unlimited) for
+// the given duration, or until cleared when duration is 0
+func (bl *BandwidthLimiter) SetOverride(rate int64, duration time.Duration) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	bl.override = &rate
+	bl.overrideUntil = time.Time{}
+	if duration > 0 {
+		bl.overrideUntil = time.Now().Add(duration)
+	}
+}
+
+// ClearOverride returns to the scheduled limits
+func (bl *BandwidthLimiter) ClearOverride() {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	bl.override = nil
+	bl.overrideUntil = time.Time{}
+}
+
+// Override returns the active override and when it expires (zero for never)
+func (bl *BandwidthLimiter) Override() (rate int64, until time.Time, active bool) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	bl.rateLocked(time.Now()) // Drops an expired override
+	if bl.override == nil {
+		return 0, time.Time{}, false
+	}
+	return *bl.override, bl.overrideUntil, true
+}
+
+// Schedule returns the configured schedule
+func (bl *BandwidthLimiter) Schedule() *BandwidthSchedule {
+	return bl.schedule
+}
@@ -2,11 +2,26 @@ package utils
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"telegram-archive-bot/app/extraction/compression"
+)
+
+// What happens to files sent while the queue is full (QUEUE_FULL_ACTION)
+const (
+	QueueFullReject = "reject" // Ask the user to resubmit later
+	QueueFullDefer  = "defer"  // Queue the file and tell the user its position
+)
+
+// Orders download workers claim queued files in, within a priority (QUEUE_ORDER)
+const (
+	QueueOrderFIFO          = "fifo"           // Oldest first
+	QueueOrderShortestFirst = "shortest_first" // Lowest estimated cost first
 )
 
 type Config struct {
@@ -20,6 +35,169 @@ type Config struct {
 	UseLocalBotAPI      bool
 	LocalBotAPIURL      string
 	LocalBotAPIEnabled  bool
+	// Large file I/O tuning (hashing and file moves)
+	IOBufferSizeKB      int
+	IOReadAheadMB       int
+	IODropPageCache     bool
+	IODirectIO          bool
+	// Distributed processing (coordinator side)
+	ClusterListenAddr   string
+	ClusterToken        string
+	ClusterJobLease     time.Duration
+	ClusterAllowedIPs   *IPAllowlist
+	ClusterTLSCert      string
+	ClusterTLSKey       string
+	ClusterTLSClientCA  string // Worker certificates must be signed by it when set
+	// High availability (leader election over the shared database)
+	HAEnabled           bool
+	HAInstanceID        string
+	HALeaseTTL          time.Duration
+	TaskLeaseTTL        time.Duration // How long a download worker's claim on a task lasts without renewal
+	// Download dispatch
+	DownloadPollInterval   time.Duration // Fallback polling for tasks queued without the dispatch
+	TaskDispatchUpdateHook bool
+	// Queue backpressure; 0 disables a limit
+	QueueMaxDepth       int
+	QueueMaxDiskPercent float64
+	QueueFullAction     string
+	// Stage concurrency; /limits changes it at runtime
+	MaxConcurrentDownloads   int
+	MaxConcurrentExtractions int
+	MaxConcurrentConversions int
+	// Cost estimation; 0 disables an admission limit
+	CostModelRefresh          time.Duration
+	QueueOrder                string
+	QueueShortestFirstMaxWait time.Duration
+	AdmissionMaxTaskTime      time.Duration
+	AdmissionMinFreeDisk      int64 // Bytes
+	// Crash reporting
+	CrashReportDir      string
+	SentryDSN           string
+	SentryEnvironment   string
+	// Dead letter queue alerting
+	DLQWarningAge       time.Duration
+	DLQCriticalAge      time.Duration
+	DLQWarningCount     int
+	DLQCriticalCount    int
+	DLQDigestWeekday    time.Weekday
+	DLQDigestHour       int
+	// Alert notifications
+	AlertDigestWindow time.Duration
+	// Alert delivery: outbox retries and failover channels
+	AlertFailoverWebhookURL   string
+	AlertFailoverSMTPAddr     string
+	AlertFailoverSMTPUsername string
+	AlertFailoverSMTPPassword string
+	AlertFailoverEmailFrom    string
+	AlertFailoverEmailTo      []string
+	AlertFailoverAfter        int // Consecutive failures of the primary channel before failing over
+	AlertOutboxMaxAge         time.Duration
+	// Admin behavior anomaly detection
+	AdminAnomalyWindow               time.Duration
+	AdminAnomalyDownloadBurst        int
+	AdminAnomalyUnauthorizedAttempts int
+	// Goroutine leak detection
+	GoroutineLeakMargin  int
+	GoroutineLeakSustain time.Duration
+	// HTTP API
+	APIListenAddr     string
+	APIAuthToken      string
+	APIKeys           []APIClient
+	APIClientCerts    []APIClient
+	APIAllowedIPs     *IPAllowlist
+	APITLSCert        string
+	APITLSKey         string
+	APITLSClientCA    string
+	// Health history
+	HealthHistorySize int
+	// Health check schedule
+	HealthCheckInterval    time.Duration
+	HealthCheckJitter      float64
+	HealthCheckTimeout     time.Duration
+	HealthCheckerTimeouts  map[string]time.Duration
+	HealthCheckerIntervals map[string]time.Duration
+	DailyReportHour   int // -1 disables the daily resource report
+	// Profiling
+	ProfileDir string
+	// Password of diagnostics bundles, random per bundle when empty
+	DiagBundlePassword string
+	// Download bandwidth limits
+	BandwidthSchedule *BandwidthSchedule
+	// Garbage collector tuning
+	GCPercent     int
+	MemoryLimit   int64
+	MemoryBallast int64
+	// Completion ledger
+	LedgerCSVPath    string
+	LedgerWebhookURL string
+	// Screenshot OCR
+	OCREnabled       bool
+	OCRTesseractPath string
+	OCRLanguages     string
+	OCRTimeout       time.Duration
+	OCRMaxImages     int   // Per archive
+	OCRMaxImageSize  int64 // Bytes
+	// Conversion duplicate filter
+	DedupEnabled         bool
+	DedupFilterPath      string
+	DedupCapacity        uint64 // Lines the filter is sized for
+	DedupFalsePositive   float64
+	DedupRebuildInterval time.Duration
+	// Keep each conversion pass's input for reprocess campaigns
+	ConversionKeepInputs bool
+	// Converted output files
+	OutputChunkMaxLines int64 // 0 for no limit
+	OutputChunkMaxSize  int64 // Bytes, 0 for no limit
+	OutputNameTemplate  string
+	OutputTag           string
+	// Database backups
+	BackupDir string // Where cmd/backup and /backup write backups
+	// Compression of database backups and archived output files
+	BackupCompression       compression.Codec
+	BackupCompressionLevel  int // 0 for the codec default
+	ArchiveCompression      compression.Codec
+	ArchiveCompressionLevel int
+	// Dependency recovery
+	PasswordStorePath          string
+	LocalBotAPIRestartCommand  string
+	DependencyRecoveryCooldown time.Duration
+	// Quarantine retry
+	QuarantineFallbackDirs  []string
+	QuarantineRetryInterval time.Duration
+	QuarantineMaxAttempts   int
+	QuarantineKeyPath       string
+	QuarantineKey           string // Hex key from a secret store, replaces the key file
+	// Files of tasks deleted with /delete are purged after this grace period
+	TaskPurgeGrace time.Duration
+	// Data older than its category's policy is deleted every RetentionInterval
+	RetentionPolicies []RetentionPolicy
+	RetentionInterval time.Duration
+	// Signature definitions
+	SignatureDefinitionsPath string
+	ScanCacheTTL             time.Duration
+	// Content scanning
+	ContentScanTiers []ContentScanTier
+	// Secret references are re-fetched this often (0 disables rotation)
+	SecretsRefreshInterval time.Duration
+	// Dry run: extraction and conversion are simulated
+	DryRun             bool
+	DryRunThroughput   int64 // Simulated bytes per second
+	DryRunMaxStageTime time.Duration
+	// Watchdog heartbeat file and /healthz
+	WatchdogFile           string
+	WatchdogInterval       time.Duration
+	WatchdogLoopStaleAfter time.Duration
+	WatchdogPollStaleAfter time.Duration
+	// Status file for host tooling
+	StatusFile         string
+	StatusFileInterval time.Duration
+	// Self-update from a signed release feed (off when UpdateFeedURL is empty)
+	UpdateFeedURL       string
+	UpdatePublicKey     string
+	UpdateChannel       string
+	UpdateCheckInterval time.Duration
+	UpdateStagingDir    string
+	UpdateRestartWindow ClockWindow
 }
 
 func LoadConfig() (*Config, error) {
@@ -29,19 +207,21 @@ func LoadConfig() (*Config, error) {
 	}
 
 	config := &Config{}
+	// Every problem is collected so they can all be fixed in one go
+	problems := &ConfigError{}
 
-	config.TelegramBotToken = os.Getenv("TELEGRAM_BOT_TOKEN")
-	if config.TelegramBotToken == "" {
-		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN is required")
-	}
-
-	adminIDsStr := os.Getenv("ADMIN_IDS")
-	if adminIDsStr == "" {
-		return nil, fmt.Errorf("ADMIN_IDS is required")
+	// Secret settings may reference Docker secrets, files, Vault or AWS SSM
+	config.TelegramBotToken, err = SecretEnv("TELEGRAM_BOT_TOKEN")
+	if err != nil {
+		problems.add("invalid TELEGRAM_BOT_TOKEN: %w", err)
+	} else if config.TelegramBotToken == "" {
+		problems.add("TELEGRAM_BOT_TOKEN is required")
 	}
 
+	adminIDsStr := configEnv("ADMIN_IDS")
 	adminIDStrs := strings.Split(adminIDsStr, ",")
 	config.AdminIDs = make([]int64, 0, len(adminIDStrs))
+	invalidIDs := 0
 	for _, idStr := range adminIDStrs {
 		idStr = strings.TrimSpace(idStr)
 		if idStr == "" {
@@ -49,56 +229,763 @@ func LoadConfig() (*Config, error) {
 		}
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			return nil, fmt.Errorf("invalid admin ID '%s': %w", idStr, err)
+			problems.add("invalid admin ID '%s' in ADMIN_IDS: %w", idStr, err)
+			invalidIDs++
+			continue
 		}
 		config.AdminIDs = append(config.AdminIDs, id)
 	}
 
-	if len(config.AdminIDs) == 0 {
-		return nil, fmt.Errorf("at least one valid admin ID is required")
+	if adminIDsStr == "" {
+		problems.add("ADMIN_IDS is required")
+	} else if len(config.AdminIDs) == 0 && invalidIDs == 0 {
+		problems.add("at least one valid admin ID is required")
+	}
+
+	config.MaxFileSizeMB, err = strconv.ParseInt(configEnv("MAX_FILE_SIZE_MB"), 10, 64)
+	if err != nil {
+		problems.add("invalid MAX_FILE_SIZE_MB: %w", err)
+	} else if config.MaxFileSizeMB <= 0 {
+		problems.add("MAX_FILE_SIZE_MB must be positive")
+	}
+
+	config.DatabasePath = configEnv("DATABASE_PATH")
+
+	config.LogLevel = configEnv("LOG_LEVEL")
+
+	config.LogFilePath = configEnv("LOG_FILE_PATH")
+
+	// Load Local Bot API Server configuration
+	config.UseLocalBotAPI = configEnv("USE_LOCAL_BOT_API") == "true"
+	config.LocalBotAPIEnabled = configEnv("LOCAL_BOT_API_ENABLED") == "true"
+	
+	config.LocalBotAPIURL = configEnv("LOCAL_BOT_API_URL")
+
+	// Load large file I/O tuning
+	if v := configEnv("IO_BUFFER_SIZE_KB"); v != "" {
+		config.IOBufferSizeKB, err = strconv.Atoi(v)
+		if err != nil || config.IOBufferSizeKB <= 0 {
+			problems.add("invalid IO_BUFFER_SIZE_KB: %s", v)
+		}
+	}
+
+	if v := configEnv("IO_READAHEAD_MB"); v != "" {
+		config.IOReadAheadMB, err = strconv.Atoi(v)
+		if err != nil || config.IOReadAheadMB < 0 {
+			problems.add("invalid IO_READAHEAD_MB: %s", v)
+		}
 	}
 
+	config.IODropPageCache = configEnv("IO_DROP_PAGE_CACHE") != "false"
+	config.IODirectIO = configEnv("IO_DIRECT") == "true"
 
-	maxFileSizeStr := os.Getenv("MAX_FILE_SIZE_MB")
-	if maxFileSizeStr == "" {
-		config.MaxFileSizeMB = 4096 // Default 4GB
-	} else {
-		config.MaxFileSizeMB, err = strconv.ParseInt(maxFileSizeStr, 10, 64)
+	// Load distributed processing configuration
+	config.ClusterListenAddr = configEnv("CLUSTER_LISTEN_ADDR")
+	config.ClusterToken, err = SecretEnv("CLUSTER_TOKEN")
+	if err != nil {
+		problems.add("invalid CLUSTER_TOKEN: %w", err)
+	} else if config.ClusterListenAddr != "" && config.ClusterToken == "" {
+		problems.add("CLUSTER_TOKEN is required when CLUSTER_LISTEN_ADDR is set")
+	}
+	if v := configEnv("CLUSTER_JOB_LEASE"); v != "" {
+		config.ClusterJobLease, err = time.ParseDuration(v)
+		if err != nil || config.ClusterJobLease <= 0 {
+			problems.add("invalid CLUSTER_JOB_LEASE: %s", v)
+		}
+	}
+	config.ClusterAllowedIPs, err = ParseIPAllowlist(configEnv("CLUSTER_ALLOWED_IPS"))
+	if err != nil {
+		problems.add("invalid CLUSTER_ALLOWED_IPS: %w", err)
+	}
+	config.ClusterTLSCert = configEnv("CLUSTER_TLS_CERT")
+	config.ClusterTLSKey = configEnv("CLUSTER_TLS_KEY")
+	config.ClusterTLSClientCA = configEnv("CLUSTER_TLS_CLIENT_CA")
+	if (config.ClusterTLSCert == "") != (config.ClusterTLSKey == "") {
+		problems.add("CLUSTER_TLS_CERT and CLUSTER_TLS_KEY must be set together")
+	}
+	if config.ClusterTLSClientCA != "" && config.ClusterTLSCert == "" {
+		problems.add("CLUSTER_TLS_CLIENT_CA needs CLUSTER_TLS_CERT and CLUSTER_TLS_KEY")
+	}
+
+	// Load high availability configuration
+	config.HAEnabled = configEnv("HA_ENABLED") == "true"
+	config.HAInstanceID = configEnv("HA_INSTANCE_ID")
+	if config.HAInstanceID == "" {
+		hostname, _ := os.Hostname()
+		config.HAInstanceID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if v := configEnv("HA_LEASE_TTL"); v != "" {
+		config.HALeaseTTL, err = time.ParseDuration(v)
+		if err != nil || config.HALeaseTTL < 3*time.Second {
+			problems.add("invalid HA_LEASE_TTL (minimum 3s): %s", v)
+		}
+	}
+	if v := configEnv("TASK_LEASE_TTL"); v != "" {
+		config.TaskLeaseTTL, err = time.ParseDuration(v)
+		if err != nil || config.TaskLeaseTTL < 30*time.Second {
+			problems.add("invalid TASK_LEASE_TTL (minimum 30s): %s", v)
+		}
+	}
+
+	// Load download dispatch configuration
+	if v := configEnv("DOWNLOAD_POLL_INTERVAL"); v != "" {
+		config.DownloadPollInterval, err = time.ParseDuration(v)
+		if err != nil || config.DownloadPollInterval < time.Second {
+			problems.add("invalid DOWNLOAD_POLL_INTERVAL (minimum 1s): %s", v)
+		}
+	}
+	config.TaskDispatchUpdateHook = configEnv("TASK_DISPATCH_UPDATE_HOOK") == "true"
+
+	// Load queue backpressure configuration
+	if v := configEnv("QUEUE_MAX_DEPTH"); v != "" {
+		config.QueueMaxDepth, err = strconv.Atoi(v)
+		if err != nil || config.QueueMaxDepth < 0 {
+			problems.add("invalid QUEUE_MAX_DEPTH: %s", v)
+		}
+	}
+	if v := configEnv("QUEUE_MAX_DISK_PERCENT"); v != "" {
+		config.QueueMaxDiskPercent, err = strconv.ParseFloat(v, 64)
+		if err != nil || config.QueueMaxDiskPercent < 0 || config.QueueMaxDiskPercent > 100 {
+			problems.add("invalid QUEUE_MAX_DISK_PERCENT (0-100): %s", v)
+		}
+	}
+	config.QueueFullAction = strings.ToLower(configEnv("QUEUE_FULL_ACTION"))
+	if config.QueueFullAction != QueueFullReject && config.QueueFullAction != QueueFullDefer {
+		problems.add("invalid QUEUE_FULL_ACTION (reject or defer): %s", config.QueueFullAction)
+	}
+
+	// Load stage concurrency configuration
+	for _, limit := range []struct {
+		name  string
+		value *int
+	}{
+		{"MAX_CONCURRENT_DOWNLOADS", &config.MaxConcurrentDownloads},
+		{"MAX_CONCURRENT_EXTRACTIONS", &config.MaxConcurrentExtractions},
+		{"MAX_CONCURRENT_CONVERSIONS", &config.MaxConcurrentConversions},
+	} {
+		v := configEnv(limit.name)
+		*limit.value, err = strconv.Atoi(v)
+		if err != nil || *limit.value < 1 || *limit.value > MaxStageConcurrency {
+			problems.add("invalid %s (1-%d): %s", limit.name, MaxStageConcurrency, v)
+		}
+	}
+
+	// Load cost estimation configuration
+	if v := configEnv("COST_MODEL_REFRESH"); v != "" {
+		config.CostModelRefresh, err = time.ParseDuration(v)
+		if err != nil || config.CostModelRefresh < time.Minute {
+			problems.add("invalid COST_MODEL_REFRESH (minimum 1m): %s", v)
+		}
+	}
+	config.QueueOrder = strings.ToLower(configEnv("QUEUE_ORDER"))
+	if config.QueueOrder != QueueOrderFIFO && config.QueueOrder != QueueOrderShortestFirst {
+		problems.add("invalid QUEUE_ORDER (fifo or shortest_first): %s", config.QueueOrder)
+	}
+	if v := configEnv("QUEUE_SHORTEST_FIRST_MAX_WAIT"); v != "" {
+		config.QueueShortestFirstMaxWait, err = time.ParseDuration(v)
+		if err != nil || config.QueueShortestFirstMaxWait <= 0 {
+			problems.add("invalid QUEUE_SHORTEST_FIRST_MAX_WAIT: %s", v)
+		}
+	}
+	if v := configEnv("ADMISSION_MAX_TASK_TIME"); v != "" {
+		config.AdmissionMaxTaskTime, err = time.ParseDuration(v)
+		if err != nil || config.AdmissionMaxTaskTime < 0 {
+			problems.add("invalid ADMISSION_MAX_TASK_TIME: %s", v)
+		}
+	}
+	if v := configEnv("ADMISSION_MIN_FREE_DISK"); v != "" {
+		config.AdmissionMinFreeDisk, err = parseByteSize(v)
+		if err != nil || config.AdmissionMinFreeDisk < 0 {
+			problems.add("invalid ADMISSION_MIN_FREE_DISK: %s", v)
+		}
+	}
+
+	// Load crash reporting configuration
+	config.CrashReportDir = configEnv("CRASH_REPORT_DIR")
+	config.SentryDSN, err = SecretEnv("SENTRY_DSN")
+	if err != nil {
+		problems.add("invalid SENTRY_DSN: %w", err)
+	}
+	config.SentryEnvironment = configEnv("SENTRY_ENVIRONMENT")
+
+	// Load dead letter queue alerting configuration
+	if v := configEnv("DLQ_ALERT_WARNING_AGE"); v != "" {
+		config.DLQWarningAge, err = time.ParseDuration(v)
+		if err != nil || config.DLQWarningAge <= 0 {
+			problems.add("invalid DLQ_ALERT_WARNING_AGE: %s", v)
+		}
+	}
+	if v := configEnv("DLQ_ALERT_CRITICAL_AGE"); v != "" {
+		config.DLQCriticalAge, err = time.ParseDuration(v)
+		if err != nil || config.DLQCriticalAge < config.DLQWarningAge {
+			problems.add("invalid DLQ_ALERT_CRITICAL_AGE (must be at least the warning age): %s", v)
+		}
+	}
+	if v := configEnv("DLQ_ALERT_WARNING_COUNT"); v != "" {
+		config.DLQWarningCount, err = strconv.Atoi(v)
+		if err != nil || config.DLQWarningCount <= 0 {
+			problems.add("invalid DLQ_ALERT_WARNING_COUNT: %s", v)
+		}
+	}
+	if v := configEnv("DLQ_ALERT_CRITICAL_COUNT"); v != "" {
+		config.DLQCriticalCount, err = strconv.Atoi(v)
+		if err != nil || config.DLQCriticalCount < config.DLQWarningCount {
+			problems.add("invalid DLQ_ALERT_CRITICAL_COUNT (must be at least the warning count): %s", v)
+		}
+	}
+	if v := configEnv("DLQ_DIGEST_WEEKDAY"); v != "" {
+		config.DLQDigestWeekday, err = parseWeekday(v)
 		if err != nil {
-			return nil, fmt.Errorf("invalid MAX_FILE_SIZE_MB: %w", err)
+			problems.add("invalid DLQ_DIGEST_WEEKDAY: %w", err)
+		}
+	}
+	if v := configEnv("DLQ_DIGEST_HOUR"); v != "" {
+		config.DLQDigestHour, err = strconv.Atoi(v)
+		if err != nil || config.DLQDigestHour < 0 || config.DLQDigestHour > 23 {
+			problems.add("invalid DLQ_DIGEST_HOUR (0-23): %s", v)
+		}
+	}
+	if v := configEnv("ALERT_DIGEST_WINDOW"); v != "" {
+		config.AlertDigestWindow, err = time.ParseDuration(v)
+		if err != nil || config.AlertDigestWindow < 0 {
+			problems.add("invalid ALERT_DIGEST_WINDOW: %s", v)
+		}
+	}
+
+	// Alerts are sent to the admins over Telegram and fail over to these
+	// channels when it keeps failing; each is off when unset
+	config.AlertFailoverWebhookURL, err = SecretEnv("ALERT_FAILOVER_WEBHOOK_URL")
+	if err != nil {
+		problems.add("invalid ALERT_FAILOVER_WEBHOOK_URL: %w", err)
+	}
+	if config.AlertFailoverWebhookURL != "" && !strings.HasPrefix(config.AlertFailoverWebhookURL, "https://") &&
+		!strings.HasPrefix(config.AlertFailoverWebhookURL, "http://") {
+		problems.add("invalid ALERT_FAILOVER_WEBHOOK_URL: %s", config.AlertFailoverWebhookURL)
+	}
+	config.AlertFailoverSMTPAddr = configEnv("ALERT_FAILOVER_SMTP_ADDR")
+	config.AlertFailoverSMTPUsername = configEnv("ALERT_FAILOVER_SMTP_USERNAME")
+	config.AlertFailoverSMTPPassword, err = SecretEnv("ALERT_FAILOVER_SMTP_PASSWORD")
+	if err != nil {
+		problems.add("invalid ALERT_FAILOVER_SMTP_PASSWORD: %w", err)
+	}
+	config.AlertFailoverEmailFrom = configEnv("ALERT_FAILOVER_EMAIL_FROM")
+	config.AlertFailoverEmailTo = splitList(configEnv("ALERT_FAILOVER_EMAIL_TO"))
+	if config.AlertFailoverSMTPAddr != "" {
+		if _, _, err := net.SplitHostPort(config.AlertFailoverSMTPAddr); err != nil {
+			problems.add("invalid ALERT_FAILOVER_SMTP_ADDR (host:port): %s", config.AlertFailoverSMTPAddr)
+		}
+		if config.AlertFailoverEmailFrom == "" || len(config.AlertFailoverEmailTo) == 0 {
+			problems.add("ALERT_FAILOVER_SMTP_ADDR needs ALERT_FAILOVER_EMAIL_FROM and ALERT_FAILOVER_EMAIL_TO")
+		}
+	}
+	if v := configEnv("ALERT_FAILOVER_AFTER"); v != "" {
+		config.AlertFailoverAfter, err = strconv.Atoi(v)
+		if err != nil || config.AlertFailoverAfter <= 0 {
+			problems.add("invalid ALERT_FAILOVER_AFTER: %s", v)
+		}
+	}
+	if v := configEnv("ALERT_OUTBOX_MAX_AGE"); v != "" {
+		config.AlertOutboxMaxAge, err = time.ParseDuration(v)
+		if err != nil || config.AlertOutboxMaxAge <= 0 {
+			problems.add("invalid ALERT_OUTBOX_MAX_AGE: %s", v)
+		}
+	}
+
+	// Load admin behavior anomaly thresholds
+	if v := configEnv("ADMIN_ANOMALY_WINDOW"); v != "" {
+		config.AdminAnomalyWindow, err = time.ParseDuration(v)
+		if err != nil || config.AdminAnomalyWindow <= 0 {
+			problems.add("invalid ADMIN_ANOMALY_WINDOW: %s", v)
+		}
+	}
+	if v := configEnv("ADMIN_ANOMALY_DOWNLOAD_BURST"); v != "" {
+		config.AdminAnomalyDownloadBurst, err = strconv.Atoi(v)
+		if err != nil || config.AdminAnomalyDownloadBurst <= 0 {
+			problems.add("invalid ADMIN_ANOMALY_DOWNLOAD_BURST: %s", v)
 		}
-		if config.MaxFileSizeMB <= 0 {
-			return nil, fmt.Errorf("MAX_FILE_SIZE_MB must be positive")
+	}
+	if v := configEnv("ADMIN_ANOMALY_UNAUTHORIZED_ATTEMPTS"); v != "" {
+		config.AdminAnomalyUnauthorizedAttempts, err = strconv.Atoi(v)
+		if err != nil || config.AdminAnomalyUnauthorizedAttempts <= 0 {
+			problems.add("invalid ADMIN_ANOMALY_UNAUTHORIZED_ATTEMPTS: %s", v)
 		}
 	}
 
-	config.DatabasePath = os.Getenv("DATABASE_PATH")
-	if config.DatabasePath == "" {
-		config.DatabasePath = "data/bot.db"
+	// Load goroutine leak thresholds
+	if v := configEnv("GOROUTINE_LEAK_MARGIN"); v != "" {
+		config.GoroutineLeakMargin, err = strconv.Atoi(v)
+		if err != nil || config.GoroutineLeakMargin <= 0 {
+			problems.add("invalid GOROUTINE_LEAK_MARGIN: %s", v)
+		}
+	}
+	if v := configEnv("GOROUTINE_LEAK_SUSTAIN"); v != "" {
+		config.GoroutineLeakSustain, err = time.ParseDuration(v)
+		if err != nil || config.GoroutineLeakSustain < 0 {
+			problems.add("invalid GOROUTINE_LEAK_SUSTAIN: %s", v)
+		}
 	}
 
-	config.LogLevel = os.Getenv("LOG_LEVEL")
-	if config.LogLevel == "" {
-		config.LogLevel = "info"
+	// The HTTP API is disabled unless a listen address is set
+	config.APIListenAddr = configEnv("API_LISTEN_ADDR")
+	// Protected endpoints such as pprof are only served when a token is set
+	config.APIAuthToken, err = SecretEnv("API_AUTH_TOKEN")
+	if err != nil {
+		problems.add("invalid API_AUTH_TOKEN: %w", err)
+	}
+	apiKeys, err := SecretEnv("API_KEYS")
+	if err == nil {
+		config.APIKeys, err = ParseAPIKeys(apiKeys)
+	}
+	if err != nil {
+		problems.add("invalid API_KEYS: %w", err)
+	}
+	config.APIClientCerts, err = ParseAPIClientCerts(configEnv("API_CLIENT_CERTS"))
+	if err != nil {
+		problems.add("invalid API_CLIENT_CERTS: %w", err)
+	}
+	config.APIAllowedIPs, err = ParseIPAllowlist(configEnv("API_ALLOWED_IPS"))
+	if err != nil {
+		problems.add("invalid API_ALLOWED_IPS: %w", err)
+	}
+	config.APITLSCert = configEnv("API_TLS_CERT")
+	config.APITLSKey = configEnv("API_TLS_KEY")
+	config.APITLSClientCA = configEnv("API_TLS_CLIENT_CA")
+	if (config.APITLSCert == "") != (config.APITLSKey == "") {
+		problems.add("API_TLS_CERT and API_TLS_KEY must be set together")
+	}
+	if len(config.APIClientCerts) > 0 && config.APITLSClientCA == "" {
+		problems.add("API_CLIENT_CERTS needs API_TLS_CLIENT_CA to verify the certificates")
+	}
+	if config.APITLSClientCA != "" && config.APITLSCert == "" {
+		problems.add("API_TLS_CLIENT_CA needs API_TLS_CERT and API_TLS_KEY")
 	}
 
-	config.LogFilePath = os.Getenv("LOG_FILE_PATH")
-	if config.LogFilePath == "" {
-		config.LogFilePath = "logs/bot.log"
+	// Stored health checks and diagnostic suites per kind; 0 disables the history
+	if v := configEnv("HEALTH_HISTORY_SIZE"); v != "" {
+		config.HealthHistorySize, err = strconv.Atoi(v)
+		if err != nil || config.HealthHistorySize < 0 {
+			problems.add("invalid HEALTH_HISTORY_SIZE: %s", v)
+		}
+	}
+	if v := configEnv("HEALTH_CHECK_INTERVAL"); v != "" {
+		config.HealthCheckInterval, err = time.ParseDuration(v)
+		if err != nil || config.HealthCheckInterval < time.Second {
+			problems.add("invalid HEALTH_CHECK_INTERVAL (minimum 1s): %s", v)
+		}
+	}
+	if v := configEnv("HEALTH_CHECK_JITTER"); v != "" {
+		config.HealthCheckJitter, err = strconv.ParseFloat(v, 64)
+		if err != nil || config.HealthCheckJitter < 0 || config.HealthCheckJitter > 1 {
+			problems.add("invalid HEALTH_CHECK_JITTER (0-1): %s", v)
+		}
+	}
+	if v := configEnv("HEALTH_CHECK_TIMEOUT"); v != "" {
+		config.HealthCheckTimeout, err = time.ParseDuration(v)
+		if err != nil || config.HealthCheckTimeout <= 0 {
+			problems.add("invalid HEALTH_CHECK_TIMEOUT: %s", v)
+		}
+	}
+	config.HealthCheckerTimeouts, err = parseDurationMap(configEnv("HEALTH_CHECKER_TIMEOUTS"))
+	if err != nil {
+		problems.add("invalid HEALTH_CHECKER_TIMEOUTS: %w", err)
+	}
+	config.HealthCheckerIntervals, err = parseDurationMap(configEnv("HEALTH_CHECKER_INTERVALS"))
+	if err != nil {
+		problems.add("invalid HEALTH_CHECKER_INTERVALS: %w", err)
+	}
+	if v := configEnv("DAILY_REPORT_HOUR"); v != "" {
+		config.DailyReportHour, err = strconv.Atoi(v)
+		if err != nil || config.DailyReportHour < -1 || config.DailyReportHour > 23 {
+			problems.add("invalid DAILY_REPORT_HOUR (0-23, or -1): %s", v)
+		}
 	}
 
-	// Load Local Bot API Server configuration
-	config.UseLocalBotAPI = os.Getenv("USE_LOCAL_BOT_API") == "true"
-	config.LocalBotAPIEnabled = os.Getenv("LOCAL_BOT_API_ENABLED") == "true"
-	
-	config.LocalBotAPIURL = os.Getenv("LOCAL_BOT_API_URL")
-	if config.LocalBotAPIURL == "" {
-		config.LocalBotAPIURL = "http://localhost:8081"
+	// Profiles captured with /profile capture
+	config.ProfileDir = configEnv("PROFILE_DIR")
+
+	// Diagnostics bundles written by /diag bundle
+	config.DiagBundlePassword, err = SecretEnv("DIAG_BUNDLE_PASSWORD")
+	if err != nil {
+		problems.add("invalid DIAG_BUNDLE_PASSWORD: %w", err)
+	}
+
+	// Bandwidth limits for hashing and moving downloads; unlimited when unset
+	config.BandwidthSchedule, err = ParseBandwidthSchedule(configEnv("BANDWIDTH_LIMITS"))
+	if err != nil {
+		problems.add("invalid BANDWIDTH_LIMITS: %w", err)
+	}
+
+	// GC tuning; unset values keep the runtime defaults (GOGC, GOMEMLIMIT)
+	if v := configEnv("GC_PERCENT"); v != "" {
+		config.GCPercent, err = parseGCPercent(v)
+		if err != nil {
+			problems.add("invalid GC_PERCENT: %w", err)
+		}
+	}
+	if v := configEnv("MEMORY_LIMIT"); v != "" {
+		if strings.EqualFold(v, "auto") {
+			config.MemoryLimit = MemoryLimitAuto
+		} else {
+			config.MemoryLimit, err = parseByteSize(v)
+			if err != nil || config.MemoryLimit <= 0 {
+				problems.add("invalid MEMORY_LIMIT (size such as 3GB, or auto): %s", v)
+			}
+		}
+	}
+	if v := configEnv("MEMORY_BALLAST"); v != "" {
+		config.MemoryBallast, err = parseByteSize(v)
+		if err != nil || config.MemoryBallast < 0 {
+			problems.add("invalid MEMORY_BALLAST: %s", v)
+		}
+		if config.MemoryLimit > 0 && config.MemoryBallast >= config.MemoryLimit {
+			problems.add("MEMORY_BALLAST must be smaller than MEMORY_LIMIT")
+		}
+	}
+
+	// Completed tasks are appended to these ledgers; each is off when unset
+	config.LedgerCSVPath = configEnv("LEDGER_CSV_PATH")
+	config.LedgerWebhookURL, err = SecretEnv("LEDGER_WEBHOOK_URL")
+	if err != nil {
+		problems.add("invalid LEDGER_WEBHOOK_URL: %w", err)
+	}
+	if config.LedgerWebhookURL != "" && !strings.HasPrefix(config.LedgerWebhookURL, "https://") &&
+		!strings.HasPrefix(config.LedgerWebhookURL, "http://") {
+		problems.add("invalid LEDGER_WEBHOOK_URL: %s", config.LedgerWebhookURL)
+	}
+
+	// OCR of screenshots found in archives; off by default because it is CPU-heavy
+	config.OCREnabled = configEnv("OCR_ENABLED") == "true"
+	config.OCRTesseractPath = configEnv("OCR_TESSERACT_PATH")
+	config.OCRLanguages = configEnv("OCR_LANGUAGES")
+	if v := configEnv("OCR_TIMEOUT"); v != "" {
+		config.OCRTimeout, err = time.ParseDuration(v)
+		if err != nil || config.OCRTimeout <= 0 {
+			problems.add("invalid OCR_TIMEOUT: %s", v)
+		}
+	}
+	if v := configEnv("OCR_MAX_IMAGES"); v != "" {
+		config.OCRMaxImages, err = strconv.Atoi(v)
+		if err != nil || config.OCRMaxImages <= 0 {
+			problems.add("invalid OCR_MAX_IMAGES: %s", v)
+		}
+	}
+	if v := configEnv("OCR_MAX_IMAGE_SIZE"); v != "" {
+		config.OCRMaxImageSize, err = parseByteSize(v)
+		if err != nil || config.OCRMaxImageSize <= 0 {
+			problems.add("invalid OCR_MAX_IMAGE_SIZE: %s", v)
+		}
+	}
+
+	// Bloom filter of converted lines; off by default because it needs
+	// DEDUP_CAPACITY * 1.8 bytes of memory and disk at the default rate
+	config.DedupEnabled = configEnv("DEDUP_ENABLED") == "true"
+	config.DedupFilterPath = configEnv("DEDUP_FILTER_PATH")
+	if v := configEnv("DEDUP_CAPACITY"); v != "" {
+		config.DedupCapacity, err = strconv.ParseUint(v, 10, 64)
+		if err != nil || config.DedupCapacity == 0 {
+			problems.add("invalid DEDUP_CAPACITY: %s", v)
+		}
+	}
+	if v := configEnv("DEDUP_FALSE_POSITIVE_RATE"); v != "" {
+		config.DedupFalsePositive, err = strconv.ParseFloat(v, 64)
+		if err != nil || config.DedupFalsePositive <= 0 || config.DedupFalsePositive >= 1 {
+			problems.add("invalid DEDUP_FALSE_POSITIVE_RATE: %s", v)
+		}
+	}
+	if v := configEnv("DEDUP_REBUILD_INTERVAL"); v != "" {
+		config.DedupRebuildInterval, err = time.ParseDuration(v)
+		if err != nil || config.DedupRebuildInterval <= 0 {
+			problems.add("invalid DEDUP_REBUILD_INTERVAL: %s", v)
+		}
 	}
 
+	// Reprocess campaigns can only convert again what was kept
+	config.ConversionKeepInputs = configEnv("CONVERSION_KEEP_INPUTS") == "true"
+
+	// Chunking and naming of the converted credential files; by default each
+	// conversion pass writes one converted.txt
+	if v := configEnv("OUTPUT_CHUNK_MAX_LINES"); v != "" {
+		config.OutputChunkMaxLines, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || config.OutputChunkMaxLines < 0 {
+			problems.add("invalid OUTPUT_CHUNK_MAX_LINES: %s", v)
+		}
+	}
+	if v := configEnv("OUTPUT_CHUNK_MAX_SIZE"); v != "" {
+		config.OutputChunkMaxSize, err = parseByteSize(v)
+		if err != nil || config.OutputChunkMaxSize < 0 {
+			problems.add("invalid OUTPUT_CHUNK_MAX_SIZE: %s", v)
+		}
+	}
+	config.OutputNameTemplate = configEnv("OUTPUT_NAME_TEMPLATE")
+	if err := validateOutputNameTemplate(config.OutputNameTemplate); err != nil {
+		problems.add("invalid OUTPUT_NAME_TEMPLATE: %w", err)
+	}
+	config.OutputTag = configEnv("OUTPUT_TAG")
+
+	config.BackupDir = configEnv("BACKUP_DIR")
+
+	// Database backups are gzipped by default; archived outputs, the copies in
+	// app/extraction/files/backups, are kept uncompressed
+	if config.BackupCompression, err = compression.Parse(configEnv("BACKUP_COMPRESSION")); err != nil {
+		problems.add("invalid BACKUP_COMPRESSION: %w", err)
+	}
+	if config.BackupCompressionLevel, err = loadCompressionLevel("BACKUP_COMPRESSION_LEVEL", config.BackupCompression); err != nil {
+		problems.addErr(err)
+	}
+	if config.ArchiveCompression, err = compression.Parse(configEnv("ARCHIVE_COMPRESSION")); err != nil {
+		problems.add("invalid ARCHIVE_COMPRESSION: %w", err)
+	}
+	if config.ArchiveCompressionLevel, err = loadCompressionLevel("ARCHIVE_COMPRESSION_LEVEL", config.ArchiveCompression); err != nil {
+		problems.addErr(err)
+	}
+
+	// Dependency recovery settings
+	config.PasswordStorePath = configEnv("PASSWORD_STORE_PATH")
+	config.LocalBotAPIRestartCommand = configEnv("LOCAL_BOT_API_RESTART_COMMAND")
+	if v := configEnv("DEPENDENCY_RECOVERY_COOLDOWN"); v != "" {
+		config.DependencyRecoveryCooldown, err = time.ParseDuration(v)
+		if err != nil || config.DependencyRecoveryCooldown <= 0 {
+			problems.add("invalid DEPENDENCY_RECOVERY_COOLDOWN: %s", v)
+		}
+	}
+
+	// Unlike other settings, an empty list disables the fallbacks
+	fallbackDirs := configEnv("QUARANTINE_FALLBACK_DIRS")
+	if v, ok := os.LookupEnv("QUARANTINE_FALLBACK_DIRS"); ok {
+		fallbackDirs = v
+	}
+	config.QuarantineFallbackDirs = splitList(fallbackDirs)
+	if v := configEnv("QUARANTINE_RETRY_INTERVAL"); v != "" {
+		config.QuarantineRetryInterval, err = time.ParseDuration(v)
+		if err != nil || config.QuarantineRetryInterval <= 0 {
+			problems.add("invalid QUARANTINE_RETRY_INTERVAL: %s", v)
+		}
+	}
+	if v := configEnv("QUARANTINE_MAX_ATTEMPTS"); v != "" {
+		config.QuarantineMaxAttempts, err = strconv.Atoi(v)
+		if err != nil || config.QuarantineMaxAttempts < 1 {
+			problems.add("invalid QUARANTINE_MAX_ATTEMPTS: %s", v)
+		}
+	}
+	config.QuarantineKeyPath = configEnv("QUARANTINE_KEY_PATH")
+	// A key from a secret store replaces the key file
+	config.QuarantineKey, err = SecretEnv("QUARANTINE_KEY")
+	if err == nil && config.QuarantineKey != "" {
+		_, err = ParseQuarantineKey(config.QuarantineKey)
+	}
+	if err != nil {
+		problems.add("invalid QUARANTINE_KEY: %w", err)
+	}
+
+	// Load task deletion configuration
+	if v := configEnv("TASK_PURGE_GRACE"); v != "" {
+		config.TaskPurgeGrace, err = time.ParseDuration(v)
+		if err != nil || config.TaskPurgeGrace < 0 {
+			problems.add("invalid TASK_PURGE_GRACE: %s", v)
+		}
+	}
+
+	// Load data retention configuration
+	config.RetentionPolicies, err = ParseRetentionPolicies(configEnv("RETENTION_POLICIES"))
+	if err != nil {
+		problems.add("invalid RETENTION_POLICIES: %w", err)
+	}
+	if v := configEnv("RETENTION_INTERVAL"); v != "" {
+		config.RetentionInterval, err = time.ParseDuration(v)
+		if err != nil || config.RetentionInterval < time.Minute {
+			problems.add("invalid RETENTION_INTERVAL (minimum 1m): %s", v)
+		}
+	}
+
+	config.SignatureDefinitionsPath = configEnv("SIGNATURE_DEFINITIONS_PATH")
+	if v := configEnv("SCAN_CACHE_TTL"); v != "" {
+		config.ScanCacheTTL, err = time.ParseDuration(v)
+		if err != nil || config.ScanCacheTTL < 0 {
+			problems.add("invalid SCAN_CACHE_TTL: %s", v)
+		}
+	}
+
+	config.ContentScanTiers, err = ParseContentScanTiers(configEnv("CONTENT_SCAN_TIERS"))
+	if err != nil {
+		problems.add("invalid CONTENT_SCAN_TIERS: %w", err)
+	}
+
+	// The store database credentials are read by app/extraction; resolve them
+	// here too so a broken secret reference stops startup
+	for _, name := range []string{"MYSQL_USER", "MYSQL_PASSWORD"} {
+		if _, err := SecretEnv(name); err != nil {
+			problems.add("invalid %s: %w", name, err)
+		}
+	}
+	if v := configEnv("SECRETS_REFRESH_INTERVAL"); v != "" {
+		config.SecretsRefreshInterval, err = time.ParseDuration(v)
+		if err != nil || config.SecretsRefreshInterval < 0 {
+			problems.add("invalid SECRETS_REFRESH_INTERVAL: %s", v)
+		}
+	}
+
+	// Load dry-run mode
+	config.DryRun = configEnv("DRY_RUN") == "true"
+	if v := configEnv("DRY_RUN_THROUGHPUT"); v != "" {
+		config.DryRunThroughput, err = parseByteSize(v)
+		if err != nil || config.DryRunThroughput <= 0 {
+			problems.add("invalid DRY_RUN_THROUGHPUT: %s", v)
+		}
+	}
+	if v := configEnv("DRY_RUN_MAX_STAGE_TIME"); v != "" {
+		config.DryRunMaxStageTime, err = time.ParseDuration(v)
+		if err != nil || config.DryRunMaxStageTime < 0 {
+			problems.add("invalid DRY_RUN_MAX_STAGE_TIME: %s", v)
+		}
+	}
+
+	// Load watchdog settings; like the quarantine fallbacks, an empty file disables it
+	config.WatchdogFile = configEnv("WATCHDOG_FILE")
+	if v, ok := os.LookupEnv("WATCHDOG_FILE"); ok {
+		config.WatchdogFile = v
+	}
+	for _, setting := range []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"WATCHDOG_INTERVAL", &config.WatchdogInterval},
+		{"WATCHDOG_LOOP_STALE_AFTER", &config.WatchdogLoopStaleAfter},
+		{"WATCHDOG_POLL_STALE_AFTER", &config.WatchdogPollStaleAfter},
+	} {
+		v := configEnv(setting.name)
+		*setting.target, err = time.ParseDuration(v)
+		if err != nil || *setting.target <= 0 {
+			problems.add("invalid %s: %s", setting.name, v)
+		}
+	}
+
+	// Status file for host tooling; an empty file disables it, like the watchdog's
+	config.StatusFile = configEnv("STATUS_FILE")
+	if v, ok := os.LookupEnv("STATUS_FILE"); ok {
+		config.StatusFile = v
+	}
+	v := configEnv("STATUS_FILE_INTERVAL")
+	config.StatusFileInterval, err = time.ParseDuration(v)
+	if err != nil || config.StatusFileInterval <= 0 {
+		problems.add("invalid STATUS_FILE_INTERVAL: %s", v)
+	}
+
+	// Load self-update settings
+	config.UpdateFeedURL = configEnv("UPDATE_FEED_URL")
+	config.UpdatePublicKey = configEnv("UPDATE_PUBLIC_KEY")
+	config.UpdateChannel = configEnv("UPDATE_CHANNEL")
+	config.UpdateStagingDir = configEnv("UPDATE_STAGING_DIR")
+	if v := configEnv("UPDATE_CHECK_INTERVAL"); v != "" {
+		config.UpdateCheckInterval, err = time.ParseDuration(v)
+		if err != nil || config.UpdateCheckInterval < time.Minute {
+			problems.add("invalid UPDATE_CHECK_INTERVAL (at least 1m): %s", v)
+		}
+	}
+	if v := configEnv("UPDATE_RESTART_WINDOW"); v != "" {
+		config.UpdateRestartWindow, err = ParseClockWindow(v)
+		if err != nil {
+			problems.add("invalid UPDATE_RESTART_WINDOW: %v", err)
+		}
+	}
+
+	// Fault injection for resilience tests, only in -tags faultinject builds
+	loadFaultInjection(problems)
+
+	validateConfig(config, problems)
+	if len(problems.Problems) > 0 {
+		return nil, problems
+	}
 	return config, nil
 }
 
+// parseDurationMap parses a comma-separated list of name=duration entries
+func parseDurationMap(s string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%q: expected <name>=<duration>", entry)
+		}
+		if _, seen := durations[name]; seen {
+			return nil, fmt.Errorf("%q: %s is set twice", entry, name)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%q: expected a positive duration", entry)
+		}
+		durations[name] = d
+	}
+	return durations, nil
+}
+
+// parseWeekday accepts full or three-letter English weekday names
+func parseWeekday(s string) (time.Weekday, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := strings.ToLower(d.String())
+		if name == full || name == full[:3] {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", s)
+}
+
+// loadCompressionLevel reads a compression level valid for codec; unset is
+// the codec default
+func loadCompressionLevel(name string, codec compression.Codec) (int, error) {
+	v := configEnv(name)
+	if v == "" {
+		return 0, nil
+	}
+	level, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, v)
+	}
+	if err := codec.CheckLevel(level); err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return level, nil
+}
+
+// outputNamePlaceholders are the placeholders OUTPUT_NAME_TEMPLATE may use
+var outputNamePlaceholders = map[string]bool{
+	"{date}": true, "{time}": true, "{task}": true, "{tag}": true, "{seq}": true,
+}
+
+// validateOutputNameTemplate checks that a template names .txt files in the
+// output directory, which is what the store stage picks up, and only uses
+// known placeholders
+func validateOutputNameTemplate(template string) error {
+	if template == "" {
+		return nil
+	}
+	if strings.ContainsAny(template, `/\`) {
+		return fmt.Errorf("must be a file name, not a path: %s", template)
+	}
+	if !strings.HasSuffix(template, ".txt") {
+		return fmt.Errorf("must end in .txt: %s", template)
+	}
+	for rest := template; ; {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			return nil
+		}
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return fmt.Errorf("unclosed placeholder in %s", template)
+		}
+		if placeholder := rest[start : start+end+1]; !outputNamePlaceholders[placeholder] {
+			return fmt.Errorf("unknown placeholder %s", placeholder)
+		}
+		rest = rest[start+end+1:]
+	}
+}
+
 func (c *Config) IsAdmin(userID int64) bool {
 	for _, adminID := range c.AdminIDs {
 		if adminID == userID {
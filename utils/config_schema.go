@@ -0,0 +1,539 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SettingKind is the type of a setting's value, shown in the sample env file
+type SettingKind string
+
+const (
+	KindString   SettingKind = "string"
+	KindBool     SettingKind = "true/false"
+	KindInt      SettingKind = "integer"
+	KindFloat    SettingKind = "number"
+	KindDuration SettingKind = "duration, e.g. 90s, 10m, 24h"
+	KindSize     SettingKind = "size, e.g. 512KB, 20MB, 3GB"
+	KindList     SettingKind = "comma-separated list"
+)
+
+// ConfigSetting describes one environment setting
+type ConfigSetting struct {
+	Name    string
+	Kind    SettingKind
+	Default string // In env form; empty when the setting is unset by default
+	// Description is one line; a computed default is described here
+	Description string
+	// Secret values are masked by -print-config and may be secret references
+	Secret bool
+	// Required settings have no default and must be set
+	Required bool
+}
+
+// ConfigGroup is a section of related settings
+type ConfigGroup struct {
+	Title string
+	// Notes explain the group in the sample env file, one line each
+	Notes    []string
+	Settings []ConfigSetting
+}
+
+// ConfigSchema lists every setting LoadConfig and the worker node read, with
+// its default. LoadConfig takes defaults from here, and the sample env file
+// and -print-config are generated from it, so they can't drift apart
+var ConfigSchema = []ConfigGroup{
+	{
+		Title: "Secrets",
+		Notes: []string{
+			"Secret settings may hold a reference instead of the value: docker:<name> (/run/secrets/<name>),",
+			"file:<path>, vault:<mount>/<path>#<field> or ssm:<parameter>, e.g. vault:secret/telegram-bot#token.",
+			"References are re-fetched every SECRETS_REFRESH_INTERVAL and rotated values are applied.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "SECRETS_REFRESH_INTERVAL", Kind: KindDuration, Default: "5m", Description: "How often secret references are re-fetched, 0 disables rotation"},
+			{Name: "VAULT_ADDR", Kind: KindString, Description: "HashiCorp Vault address for vault: references"},
+			{Name: "VAULT_TOKEN", Kind: KindString, Secret: true, Description: "Vault token"},
+			{Name: "VAULT_TOKEN_FILE", Kind: KindString, Description: "File holding the Vault token, used when VAULT_TOKEN is empty"},
+			{Name: "VAULT_NAMESPACE", Kind: KindString, Description: "Vault Enterprise namespace"},
+			{Name: "AWS_REGION", Kind: KindString, Description: "AWS region for ssm: references; AWS_DEFAULT_REGION also works"},
+			{Name: "AWS_ACCESS_KEY_ID", Kind: KindString, Description: "AWS access key for ssm: references"},
+			{Name: "AWS_SECRET_ACCESS_KEY", Kind: KindString, Secret: true, Description: "AWS secret key"},
+			{Name: "AWS_SESSION_TOKEN", Kind: KindString, Secret: true, Description: "AWS session token of temporary credentials"},
+		},
+	},
+	{
+		Title: "Telegram",
+		Settings: []ConfigSetting{
+			{Name: "TELEGRAM_BOT_TOKEN", Kind: KindString, Secret: true, Required: true, Description: "Bot token from @BotFather"},
+			{Name: "ADMIN_IDS", Kind: KindList, Required: true, Description: "Telegram user IDs allowed to use the bot"},
+			{Name: "USE_LOCAL_BOT_API", Kind: KindBool, Default: "false", Description: "Talk to a Local Bot API server, needed for files over 20MB"},
+			{Name: "LOCAL_BOT_API_ENABLED", Kind: KindBool, Default: "false", Description: "Download through the Local Bot API server's file paths; must match USE_LOCAL_BOT_API"},
+			{Name: "LOCAL_BOT_API_URL", Kind: KindString, Default: "http://localhost:8081", Description: "Local Bot API server address"},
+			{Name: "MAX_FILE_SIZE_MB", Kind: KindInt, Default: "4096", Description: "Largest accepted upload in MB"},
+		},
+	},
+	{
+		Title: "Storage and logging",
+		Settings: []ConfigSetting{
+			{Name: "DATABASE_PATH", Kind: KindString, Default: "data/bot.db", Description: "SQLite task database, or :memory: for a throwaway in-memory one"},
+			{Name: "LOG_LEVEL", Kind: KindString, Default: "info", Description: "trace, debug, info, warn or error"},
+			{Name: "LOG_FILE_PATH", Kind: KindString, Default: "logs/bot.log", Description: "Log file"},
+			{Name: "MYSQL_HOST", Kind: KindString, Description: "Store database host:port (default: built-in store database)"},
+			{Name: "MYSQL_USER", Kind: KindString, Secret: true, Description: "Store database user (default: built-in)"},
+			{Name: "MYSQL_PASSWORD", Kind: KindString, Secret: true, Description: "Store database password (default: built-in)"},
+			{Name: "MYSQL_DATABASE", Kind: KindString, Description: "Store database name (default: built-in)"},
+		},
+	},
+	{
+		Title: "Large file I/O tuning",
+		Notes: []string{"Hashing and file moves of multi-GB archives"},
+		Settings: []ConfigSetting{
+			{Name: "IO_BUFFER_SIZE_KB", Kind: KindInt, Default: "1024", Description: "Buffer for hashing and copying large files"},
+			{Name: "IO_READAHEAD_MB", Kind: KindInt, Default: "8", Description: "Read-ahead hint for sequential reads"},
+			{Name: "IO_DROP_PAGE_CACHE", Kind: KindBool, Default: "true", Description: "Release processed pages from the page cache"},
+			{Name: "IO_DIRECT", Kind: KindBool, Default: "false", Description: "Read with O_DIRECT on Linux"},
+		},
+	},
+	{
+		Title: "Distributed processing",
+		Notes: []string{
+			"Remote worker nodes (./telegram-archive-bot -role=worker) pull extraction and conversion jobs",
+			"from this node over gRPC. Leave CLUSTER_LISTEN_ADDR empty to disable.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "CLUSTER_LISTEN_ADDR", Kind: KindString, Description: "gRPC address worker nodes connect to"},
+			{Name: "CLUSTER_TOKEN", Kind: KindString, Secret: true, Description: "Shared token worker nodes must present (required with CLUSTER_LISTEN_ADDR)"},
+			{Name: "CLUSTER_JOB_LEASE", Kind: KindDuration, Default: "5m", Description: "Time before an unresponsive worker's job is requeued"},
+			{Name: "CLUSTER_ALLOWED_IPS", Kind: KindList, Description: "IPs and CIDR networks worker nodes may connect from (empty allows all)"},
+			{Name: "CLUSTER_TLS_CERT", Kind: KindString, Description: "Coordinator certificate, or a worker node's client certificate"},
+			{Name: "CLUSTER_TLS_KEY", Kind: KindString, Description: "Key of CLUSTER_TLS_CERT"},
+			{Name: "CLUSTER_TLS_CLIENT_CA", Kind: KindString, Description: "CA every worker certificate must be signed by (mTLS)"},
+			{Name: "CLUSTER_COORDINATOR_ADDR", Kind: KindString, Description: "Worker nodes: coordinator address (or -coordinator host:port)"},
+			{Name: "CLUSTER_TLS_CA", Kind: KindString, Description: "Worker nodes: CA that verifies the coordinator"},
+		},
+	},
+	{
+		Title: "High availability",
+		Notes: []string{
+			"Run two instances against the same DATABASE_PATH; only the elected leader polls Telegram",
+			"and processes files, the other takes over on failure.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "HA_ENABLED", Kind: KindBool, Default: "false", Description: "Leader election between instances sharing the database"},
+			{Name: "HA_INSTANCE_ID", Kind: KindString, Description: "Identity used for the leader lease (default: hostname-pid)"},
+			{Name: "HA_LEASE_TTL", Kind: KindDuration, Default: "15s", Description: "Leader lease duration, at least 3s; failover happens after it expires"},
+			{Name: "TASK_LEASE_TTL", Kind: KindDuration, Default: "5m", Description: "Download claim duration, at least 30s; a dead worker's task is requeued after it expires"},
+		},
+	},
+	{
+		Title: "Download dispatch",
+		Notes: []string{
+			"New tasks are handed to idle download workers right away; polling only catches tasks",
+			"queued by other processes.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "DOWNLOAD_POLL_INTERVAL", Kind: KindDuration, Default: "30s", Description: "How often idle download workers check for tasks, at least 1s"},
+			{Name: "TASK_DISPATCH_UPDATE_HOOK", Kind: KindBool, Default: "false", Description: "Also wake idle workers on every write to the tasks table, via a SQLite update hook"},
+		},
+	},
+	{
+		Title: "Queue backpressure",
+		Notes: []string{
+			"While the queue is full, new files are rejected with a request to resubmit later, or deferred:",
+			"queued with a notice of their position. Disk usage is the fullest monitored volume.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "QUEUE_MAX_DEPTH", Kind: KindInt, Default: "0", Description: "Pending, downloading and downloaded tasks from which the queue is full, 0 for no limit"},
+			{Name: "QUEUE_MAX_DISK_PERCENT", Kind: KindFloat, Default: "0", Description: "Disk usage from which the queue is full, 0 for no limit"},
+			{Name: "QUEUE_FULL_ACTION", Kind: KindString, Default: "defer", Description: "What happens to files sent while the queue is full: reject or defer"},
+		},
+	},
+	{
+		Title: "Stage concurrency",
+		Notes: []string{
+			"How many tasks each stage works on at once, 1-8. /limits changes them until the next restart;",
+			"extraction and conversion pick up a change at their next pass.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "MAX_CONCURRENT_DOWNLOADS", Kind: KindInt, Default: "3", Description: "Concurrent downloads; mind the Telegram API rate limits"},
+			{Name: "MAX_CONCURRENT_EXTRACTIONS", Kind: KindInt, Default: "1", Description: "Archives extracted at once"},
+			{Name: "MAX_CONCURRENT_CONVERSIONS", Kind: KindInt, Default: "1", Description: "Files converted at once"},
+		},
+	},
+	{
+		Title: "Cost estimation",
+		Notes: []string{
+			"Each stage's duration is fitted to its input size from the recorded stage timings, downloads per",
+			"file type. The model gives ETAs, orders the queue under shortest_first and admits new files.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "COST_MODEL_REFRESH", Kind: KindDuration, Default: "10m", Description: "How often the model is refitted, at least 1m"},
+			{Name: "QUEUE_ORDER", Kind: KindString, Default: "fifo", Description: "Download order within a priority: fifo, or shortest_first by estimated cost"},
+			{Name: "QUEUE_SHORTEST_FIRST_MAX_WAIT", Kind: KindDuration, Default: "2h", Description: "Under shortest_first, files waiting longer go first regardless of cost"},
+			{Name: "ADMISSION_MAX_TASK_TIME", Kind: KindDuration, Default: "0", Description: "Reject files estimated to take longer to download and process, 0 for no limit"},
+			{Name: "ADMISSION_MIN_FREE_DISK", Kind: KindSize, Default: "0", Description: "Reject files that would leave less disk free once the queue is processed, 0 for no limit"},
+		},
+	},
+	{
+		Title: "Crash reporting",
+		Notes: []string{"Recovered panics are saved as JSON and in the crash_reports table, and sent to admins."},
+		Settings: []ConfigSetting{
+			{Name: "CRASH_REPORT_DIR", Kind: KindString, Default: "logs/crashes", Description: "Directory of JSON crash reports"},
+			{Name: "SENTRY_DSN", Kind: KindString, Secret: true, Description: "Also forward crash reports to Sentry"},
+			{Name: "SENTRY_ENVIRONMENT", Kind: KindString, Default: "production", Description: "Sentry environment tag"},
+		},
+	},
+	{
+		Title: "Alerting",
+		Notes: []string{
+			"Dead letter entries needing manual intervention raise a WARNING alert past either warning",
+			"threshold and escalate to CRITICAL past either critical one; a digest of unresolved entries is",
+			"sent weekly. Non-critical alerts are batched into one message per ALERT_DIGEST_WINDOW.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "DLQ_ALERT_WARNING_AGE", Kind: KindDuration, Default: "24h", Description: "Age of the oldest unresolved dead letter entry that raises a warning"},
+			{Name: "DLQ_ALERT_CRITICAL_AGE", Kind: KindDuration, Default: "72h", Description: "Age that raises a critical alert, at least the warning age"},
+			{Name: "DLQ_ALERT_WARNING_COUNT", Kind: KindInt, Default: "5", Description: "Unresolved dead letter entries that raise a warning"},
+			{Name: "DLQ_ALERT_CRITICAL_COUNT", Kind: KindInt, Default: "20", Description: "Entries that raise a critical alert, at least the warning count"},
+			{Name: "DLQ_DIGEST_WEEKDAY", Kind: KindString, Default: "monday", Description: "Weekday the unresolved-DLQ digest is sent"},
+			{Name: "DLQ_DIGEST_HOUR", Kind: KindInt, Default: "9", Description: "Hour (0-23) the digest is sent"},
+			{Name: "ALERT_DIGEST_WINDOW", Kind: KindDuration, Default: "10m", Description: "How long non-critical alerts are collected, 0 sends each immediately"},
+		},
+	},
+	{
+		Title: "Alert delivery",
+		Notes: []string{
+			"Alert notifications are stored in an outbox and retried with backoff until Telegram accepts",
+			"them. After ALERT_FAILOVER_AFTER failures in a row they are sent to the failover webhook and",
+			"email instead. Notifications not delivered within ALERT_OUTBOX_MAX_AGE expire.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "ALERT_FAILOVER_WEBHOOK_URL", Kind: KindString, Secret: true, Description: "URL alerts are posted to as JSON {\"text\": ...} when Telegram fails"},
+			{Name: "ALERT_FAILOVER_SMTP_ADDR", Kind: KindString, Description: "SMTP server (host:port) alerts are mailed through when Telegram fails"},
+			{Name: "ALERT_FAILOVER_SMTP_USERNAME", Kind: KindString, Description: "SMTP login, none when unset"},
+			{Name: "ALERT_FAILOVER_SMTP_PASSWORD", Kind: KindString, Secret: true, Description: "SMTP password"},
+			{Name: "ALERT_FAILOVER_EMAIL_FROM", Kind: KindString, Description: "Sender address of alert mails"},
+			{Name: "ALERT_FAILOVER_EMAIL_TO", Kind: KindList, Description: "Addresses alert mails are sent to"},
+			{Name: "ALERT_FAILOVER_AFTER", Kind: KindInt, Default: "3", Description: "Failed Telegram deliveries in a row before failing over"},
+			{Name: "ALERT_OUTBOX_MAX_AGE", Kind: KindDuration, Default: "24h", Description: "How long an undelivered alert is retried"},
+		},
+	},
+	{
+		Title: "Admin behavior anomalies",
+		Notes: []string{
+			"More files sent by one admin within the window than the burst, or that many refused messages",
+			"from one non-admin user (twice as many is critical), raise security alerts.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "ADMIN_ANOMALY_WINDOW", Kind: KindDuration, Default: "10m", Description: "Recent period admin behavior is judged over"},
+			{Name: "ADMIN_ANOMALY_DOWNLOAD_BURST", Kind: KindInt, Default: "30", Description: "Files sent by one admin within the window that raise an alert"},
+			{Name: "ADMIN_ANOMALY_UNAUTHORIZED_ATTEMPTS", Kind: KindInt, Default: "5", Description: "Refused messages from one user within the window that raise an alert"},
+		},
+	},
+	{
+		Title: "Goroutine monitoring",
+		Notes: []string{
+			"Long-lived background goroutines are registered with a heartbeat. One that stops beating",
+			"raises an alert, and so does a goroutine count that stays above the registered ones plus",
+			"GOROUTINE_LEAK_MARGIN for GOROUTINE_LEAK_SUSTAIN.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "GOROUTINE_LEAK_MARGIN", Kind: KindInt, Default: "500", Description: "Goroutines allowed beyond the registered ones"},
+			{Name: "GOROUTINE_LEAK_SUSTAIN", Kind: KindDuration, Default: "10m", Description: "How long the count has to stay above the margin to raise an alert"},
+		},
+	},
+	{
+		Title: "HTTP API",
+		Notes: []string{
+			"Disabled when API_LISTEN_ADDR is empty. Without API_KEYS or API_CLIENT_CERTS the report",
+			"endpoints need no authentication, so bind it to localhost or a private network.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "API_LISTEN_ADDR", Kind: KindString, Description: "Listen address, e.g. 127.0.0.1:8080"},
+			{Name: "API_AUTH_TOKEN", Kind: KindString, Secret: true, Description: "Bearer token with access to every endpoint, including /debug/pprof/"},
+			{Name: "API_KEYS", Kind: KindList, Secret: true, Description: "Clients as name:key:scopes (reports, audit, pprof or *, joined with |)"},
+			{Name: "API_CLIENT_CERTS", Kind: KindList, Description: "Clients identified by certificate common name, as common-name:scopes"},
+			{Name: "API_ALLOWED_IPS", Kind: KindList, Description: "IPs and CIDR networks allowed to connect (empty allows all)"},
+			{Name: "API_TLS_CERT", Kind: KindString, Description: "Serve HTTPS with this certificate"},
+			{Name: "API_TLS_KEY", Kind: KindString, Description: "Key of API_TLS_CERT"},
+			{Name: "API_TLS_CLIENT_CA", Kind: KindString, Description: "CA that verifies the certificates of API_CLIENT_CERTS"},
+		},
+	},
+	{
+		Title: "Diagnostics",
+		Notes: []string{
+			"Checker names are database, filesystem, memory, external_dependencies and notifications. A",
+			"checker that outlives its timeout is reported unhealthy until its run returns.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "HEALTH_CHECK_INTERVAL", Kind: KindDuration, Default: "30s", Description: "How often the health checks run"},
+			{Name: "HEALTH_CHECK_JITTER", Kind: KindFloat, Default: "0.1", Description: "Share of the interval each wait is spread over, 0 disables"},
+			{Name: "HEALTH_CHECK_TIMEOUT", Kind: KindDuration, Default: "10s", Description: "How long one checker may take"},
+			{Name: "HEALTH_CHECKER_TIMEOUTS", Kind: KindList, Description: "Per-checker timeouts as name=duration, e.g. database=30s"},
+			{Name: "HEALTH_CHECKER_INTERVALS", Kind: KindList, Description: "Checkers run less often, as name=duration, e.g. external_dependencies=5m"},
+			{Name: "HEALTH_HISTORY_SIZE", Kind: KindInt, Default: "5760", Description: "Health checks and diagnostic runs kept for /healthlog, each kind; 0 disables"},
+			{Name: "DAILY_REPORT_HOUR", Kind: KindInt, Default: "8", Description: "Hour (0-23) the daily throughput and CPU/disk I/O report is sent, -1 disables"},
+			{Name: "PROFILE_DIR", Kind: KindString, Default: "logs/profiles", Description: "Where /profile capture writes profiles"},
+			{Name: "DIAG_BUNDLE_PASSWORD", Kind: KindString, Secret: true, Description: "Password of /diag bundle zips; a random one per bundle when empty"},
+		},
+	},
+	{
+		Title: "Memory and bandwidth",
+		Notes: []string{"Unset garbage collector values keep the Go runtime defaults (GOGC/GOMEMLIMIT)."},
+		Settings: []ConfigSetting{
+			{Name: "GC_PERCENT", Kind: KindString, Description: "Garbage collector target percentage, or off"},
+			{Name: "MEMORY_LIMIT", Kind: KindSize, Description: "Soft memory limit, or auto for 90% of the container's cgroup limit"},
+			{Name: "MEMORY_BALLAST", Kind: KindSize, Description: "Unused heap allocation that makes the collector run less often"},
+			{Name: "BANDWIDTH_LIMITS", Kind: KindList, Description: "[days] HH:MM-HH:MM=<rate> windows plus default=<rate>, e.g. mon-fri 09:00-18:00=20MB,default=unlimited"},
+		},
+	},
+	{
+		Title: "Completion ledger",
+		Notes: []string{"A row per completed task (date, file, size, credentials, duration); each ledger is off when empty."},
+		Settings: []ConfigSetting{
+			{Name: "LEDGER_CSV_PATH", Kind: KindString, Description: "CSV file rows are appended to"},
+			{Name: "LEDGER_WEBHOOK_URL", Kind: KindString, Secret: true, Description: "URL rows are posted to as JSON, e.g. a Google Apps Script web app"},
+		},
+	},
+	{
+		Title: "Screenshot OCR",
+		Notes: []string{"CPU-heavy, so off by default; needs tesseract installed."},
+		Settings: []ConfigSetting{
+			{Name: "OCR_ENABLED", Kind: KindBool, Default: "false", Description: "Read the text of screenshots in archives"},
+			{Name: "OCR_TESSERACT_PATH", Kind: KindString, Default: "tesseract", Description: "Tesseract executable"},
+			{Name: "OCR_LANGUAGES", Kind: KindString, Default: "eng", Description: "Tesseract languages, e.g. eng+rus"},
+			{Name: "OCR_TIMEOUT", Kind: KindDuration, Default: "1m", Description: "Longest tesseract run per image"},
+			{Name: "OCR_MAX_IMAGES", Kind: KindInt, Default: "20", Description: "Most screenshots read from one archive"},
+			{Name: "OCR_MAX_IMAGE_SIZE", Kind: KindSize, Default: "10MB", Description: "Larger images are skipped"},
+		},
+	},
+	{
+		Title: "Duplicate filter",
+		Notes: []string{"A Bloom filter of converted lines needing about 1.8 bytes per line of DEDUP_CAPACITY."},
+		Settings: []ConfigSetting{
+			{Name: "DEDUP_ENABLED", Kind: KindBool, Default: "false", Description: "Skip credential lines already converted"},
+			{Name: "DEDUP_FILTER_PATH", Kind: KindString, Default: "data/dedup.bloom", Description: "Filter file"},
+			{Name: "DEDUP_CAPACITY", Kind: KindInt, Default: "100000000", Description: "Lines the filter is sized for"},
+			{Name: "DEDUP_FALSE_POSITIVE_RATE", Kind: KindFloat, Default: "0.001", Description: "Share of new lines wrongly skipped once the filter is full"},
+			{Name: "DEDUP_REBUILD_INTERVAL", Kind: KindDuration, Default: "24h", Description: "How often the filter is rebuilt from the store database"},
+		},
+	},
+	{
+		Title: "Converted output files",
+		Settings: []ConfigSetting{
+			{Name: "OUTPUT_CHUNK_MAX_LINES", Kind: KindInt, Default: "0", Description: "Most lines per converted file, 0 for no limit"},
+			{Name: "OUTPUT_CHUNK_MAX_SIZE", Kind: KindSize, Default: "0", Description: "Largest converted file, 0 for no limit"},
+			{Name: "OUTPUT_NAME_TEMPLATE", Kind: KindString, Description: "File names with {date}, {time}, {task}, {tag} and {seq}, ending in .txt (default: converted.txt)"},
+			{Name: "OUTPUT_TAG", Kind: KindString, Description: "Value of {tag}, e.g. the instance name"},
+		},
+	},
+	{
+		Title: "Reprocessing",
+		Notes: []string{
+			"Kept inputs are hard links into app/extraction/files/conversion_inputs, copies across filesystems.",
+			"The conversion_inputs retention category deletes them.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "CONVERSION_KEEP_INPUTS", Kind: KindBool, Default: "false", Description: "Keep each conversion pass's input so /reprocess can convert it with a newer parser"},
+		},
+	},
+	{
+		Title: "Backups",
+		Notes: []string{"Codecs are gzip, zstd or none; level 0 is the codec default (gzip 1-9, zstd 1-22)."},
+		Settings: []ConfigSetting{
+			{Name: "BACKUP_DIR", Kind: KindString, Default: "backups", Description: "Where cmd/backup and /backup write database backups"},
+			{Name: "BACKUP_COMPRESSION", Kind: KindString, Default: "gzip", Description: "Codec of database backups"},
+			{Name: "BACKUP_COMPRESSION_LEVEL", Kind: KindInt, Default: "0", Description: "Level of BACKUP_COMPRESSION"},
+			{Name: "ARCHIVE_COMPRESSION", Kind: KindString, Default: "none", Description: "Codec of the output file copies in app/extraction/files/backups"},
+			{Name: "ARCHIVE_COMPRESSION_LEVEL", Kind: KindInt, Default: "0", Description: "Level of ARCHIVE_COMPRESSION"},
+		},
+	},
+	{
+		Title: "Dependency recovery",
+		Notes: []string{"Each dependency is repaired at most once per cooldown before it is marked degraded."},
+		Settings: []ConfigSetting{
+			{Name: "PASSWORD_STORE_PATH", Kind: KindString, Default: "data/passwords", Description: "File or directory of *.txt files pass.txt is rebuilt from"},
+			{Name: "LOCAL_BOT_API_RESTART_COMMAND", Kind: KindString, Default: "./scripts/start-native-api.sh restart", Description: "Command run when the Local Bot API stops answering"},
+			{Name: "DEPENDENCY_RECOVERY_COOLDOWN", Kind: KindDuration, Default: "5m", Description: "Minimum time between recovery attempts for one dependency"},
+		},
+	},
+	{
+		Title: "Quarantine",
+		Notes: []string{
+			"A flagged file that can't be moved to app/extraction/files/errors goes to the first fallback",
+			"directory that works, or is retried. Quarantined files are encrypted; back the key up.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "QUARANTINE_FALLBACK_DIRS", Kind: KindList, Default: "data/quarantine", Description: "Fallback quarantine directories; set it empty to disable"},
+			{Name: "QUARANTINE_RETRY_INTERVAL", Kind: KindDuration, Default: "1m", Description: "How often a failed quarantine move is retried"},
+			{Name: "QUARANTINE_MAX_ATTEMPTS", Kind: KindInt, Default: "20", Description: "How many times a failed quarantine move is retried"},
+			{Name: "QUARANTINE_KEY_PATH", Kind: KindString, Default: "data/quarantine.key", Description: "Key file of quarantine containers, generated if missing"},
+			{Name: "QUARANTINE_KEY", Kind: KindString, Secret: true, Description: "64 hex character key, usually a secret reference; replaces the key file"},
+		},
+	},
+	{
+		Title: "Task deletion",
+		Notes: []string{
+			"/delete hides a task and purges its downloaded, queued and quarantined files after the",
+			"grace period; /undelete takes it back until then. Extracted credentials are not removed.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "TASK_PURGE_GRACE", Kind: KindDuration, Default: "24h", Description: "Time between /delete and purging the task's files, 0 for the next purge run"},
+		},
+	},
+	{
+		Title: "Data retention",
+		Notes: []string{
+			"Data older than its category's maximum age is deleted: output_files (merged credential, betting",
+			"and backup files, overwritten before removal), store_lines (credential lines by entry date) and",
+			"task_metadata (finished tasks and their records). Each run leaves a deletion certificate in the",
+			"admin audit log. Categories not listed are kept forever.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "RETENTION_POLICIES", Kind: KindList, Description: "<category>=<max age> pairs, e.g. output_files=30d,task_metadata=90d"},
+			{Name: "RETENTION_INTERVAL", Kind: KindDuration, Default: "6h", Description: "How often retention policies are enforced"},
+		},
+	},
+	{
+		Title: "Security scanning",
+		Settings: []ConfigSetting{
+			{Name: "SIGNATURE_DEFINITIONS_PATH", Kind: KindString, Default: "data/signatures.json", Description: "Signature definitions, created from the built-in ones if missing"},
+			{Name: "SCAN_CACHE_TTL", Kind: KindDuration, Default: "24h", Description: "How long a scan result is reused for the same SHA-256, 0 disables"},
+			{Name: "CONTENT_SCAN_TIERS", Kind: KindList, Default: DefaultContentScanTiers, Description: "<size>=full or <size>=<window>/<samples> tiers, the last one max=..."},
+		},
+	},
+	{
+		Title: "Dry run",
+		Notes: []string{
+			"For staging: submissions are validated, hashed and queued as usual, but extraction and conversion",
+			"only wait a synthetic time and then discard their input, so nothing is stored.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "DRY_RUN", Kind: KindBool, Default: "false", Description: "Simulate extraction and conversion"},
+			{Name: "DRY_RUN_THROUGHPUT", Kind: KindSize, Default: "50MB", Description: "Simulated processing speed per second; a stage takes its input size divided by it"},
+			{Name: "DRY_RUN_MAX_STAGE_TIME", Kind: KindDuration, Default: "2m", Description: "Longest a simulated stage takes, 0 for no limit"},
+		},
+	},
+	{
+		Title: "Watchdog",
+		Notes: []string{
+			"The heartbeat file is rewritten while the orchestrator loop and Telegram polling make progress,",
+			"and left alone once either is stale; /healthz on the HTTP API answers 503 then.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "WATCHDOG_FILE", Kind: KindString, Default: "data/heartbeat.json", Description: "Heartbeat status file, empty disables it"},
+			{Name: "WATCHDOG_INTERVAL", Kind: KindDuration, Default: "30s", Description: "How often the heartbeat file is rewritten"},
+			{Name: "WATCHDOG_LOOP_STALE_AFTER", Kind: KindDuration, Default: "150m", Description: "Orchestrator loop silence after which the process is unhealthy; the store stage may take 2h"},
+			{Name: "WATCHDOG_POLL_STALE_AFTER", Kind: KindDuration, Default: "5m", Description: "Time without a successful Telegram poll after which the process is unhealthy"},
+		},
+	},
+	{
+		Title: "Status file",
+		Notes: []string{"A JSON snapshot of queue depths, active tasks, health and maintenance for cron jobs and host tooling."},
+		Settings: []ConfigSetting{
+			{Name: "STATUS_FILE", Kind: KindString, Default: "data/status.json", Description: "Status file, empty disables it"},
+			{Name: "STATUS_FILE_INTERVAL", Kind: KindDuration, Default: "30s", Description: "How often the status file is rewritten"},
+		},
+	},
+	{
+		Title: "Self-update",
+		Notes: []string{
+			"Off when UPDATE_FEED_URL is empty. Newer releases of the channel are downloaded, verified against",
+			"UPDATE_PUBLIC_KEY and installed when the queue is idle within the restart window.",
+		},
+		Settings: []ConfigSetting{
+			{Name: "UPDATE_FEED_URL", Kind: KindString, Description: "Release feed URL (JSON with the latest release per channel)"},
+			{Name: "UPDATE_PUBLIC_KEY", Kind: KindString, Description: "Ed25519 public key releases are signed with, hex or base64"},
+			{Name: "UPDATE_CHANNEL", Kind: KindString, Default: "stable", Description: "Channel followed, e.g. stable or beta"},
+			{Name: "UPDATE_CHECK_INTERVAL", Kind: KindDuration, Default: "6h", Description: "How often the feed is checked, at least 1m"},
+			{Name: "UPDATE_STAGING_DIR", Kind: KindString, Default: "data/updates", Description: "Where downloaded releases are verified before installing"},
+			{Name: "UPDATE_RESTART_WINDOW", Kind: KindString, Description: "HH:MM-HH:MM when the bot may restart into an update, empty for any time"},
+		},
+	},
+}
+
+// configSettings indexes ConfigSchema by name
+var configSettings = func() map[string]ConfigSetting {
+	settings := make(map[string]ConfigSetting)
+	for _, group := range ConfigSchema {
+		for _, setting := range group.Settings {
+			settings[setting.Name] = setting
+		}
+	}
+	return settings
+}()
+
+// configEnv returns the setting from the environment, or its schema default
+// when it is unset or empty. Reading a setting missing from the schema is a
+// programming error
+func configEnv(name string) string {
+	setting, ok := configSettings[name]
+	if !ok {
+		panic(fmt.Sprintf("setting %s is not in ConfigSchema", name))
+	}
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return setting.Default
+}
+
+// WriteSampleEnv writes an annotated sample env file with every setting at
+// its default
+func WriteSampleEnv(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Generated by ./telegram-archive-bot -print-env-sample; every setting is shown at its default.\n")
+	for _, group := range ConfigSchema {
+		fmt.Fprintf(&b, "\n# --- %s ---\n", group.Title)
+		for _, note := range group.Notes {
+			fmt.Fprintf(&b, "# %s\n", note)
+		}
+		for _, setting := range group.Settings {
+			fmt.Fprintf(&b, "\n# %s [%s", setting.Description, setting.Kind)
+			if setting.Required {
+				b.WriteString(", required")
+			}
+			b.WriteString("]\n")
+			fmt.Fprintf(&b, "%s=%s\n", setting.Name, quoteEnvValue(setting.Default))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteEffectiveConfig writes every setting with the value in effect and
+// where it came from; secrets are masked unless they are secret references
+func WriteEffectiveConfig(w io.Writer) error {
+	var b strings.Builder
+	for _, group := range ConfigSchema {
+		fmt.Fprintf(&b, "# --- %s ---\n", group.Title)
+		for _, setting := range group.Settings {
+			value, source := os.Getenv(setting.Name), "env"
+			if value == "" {
+				value, source = setting.Default, "default"
+			}
+			if value == "" {
+				source = "unset"
+			}
+			if setting.Secret && value != "" {
+				if provider, _ := Secrets.provider(value); provider == nil {
+					value = "********"
+				}
+			}
+			fmt.Fprintf(&b, "%s=%s # %s\n", setting.Name, quoteEnvValue(value), source)
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// quoteEnvValue quotes values that godotenv would otherwise split or trim
+func quoteEnvValue(v string) string {
+	if strings.ContainsAny(v, " #\"'") {
+		return `"` + strings.ReplaceAll(v, `"`, `\"`) + `"`
+	}
+	return v
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ConfigError lists every problem found while loading the configuration, so
+// a misconfigured deployment reports all of them at startup
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	var b strings.Builder
+	if len(e.Problems) == 1 {
+		b.WriteString("invalid configuration, 1 problem:")
+	} else {
+		fmt.Fprintf(&b, "invalid configuration, %d problems:", len(e.Problems))
+	}
+	for _, problem := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(problem)
+	}
+	return b.String()
+}
+
+func (e *ConfigError) add(format string, args ...interface{}) {
+	e.Problems = append(e.Problems, fmt.Errorf(format, args...).Error())
+}
+
+func (e *ConfigError) addErr(err error) {
+	e.Problems = append(e.Problems, err.Error())
+}
+
+// validateConfig checks what the individual settings can't: values that
+// depend on each other, options that exclude each other and paths that must
+// exist or be creatable
+func validateConfig(config *Config, problems *ConfigError) {
+	if _, err := logrus.ParseLevel(config.LogLevel); err != nil {
+		problems.add("invalid LOG_LEVEL (trace, debug, info, warn or error): %s", config.LogLevel)
+	}
+
+	for _, id := range config.AdminIDs {
+		if id <= 0 {
+			problems.add("invalid admin ID %d in ADMIN_IDS: Telegram user IDs are positive", id)
+		}
+	}
+
+	if config.UseLocalBotAPI {
+		if u, err := url.Parse(config.LocalBotAPIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems.add("invalid LOCAL_BOT_API_URL (e.g. http://localhost:8081): %s", config.LocalBotAPIURL)
+		}
+	}
+
+	checkListenAddr(problems, "CLUSTER_LISTEN_ADDR", config.ClusterListenAddr)
+	checkListenAddr(problems, "API_LISTEN_ADDR", config.APIListenAddr)
+
+	// Each instance of an HA pair must see the other's lease
+	if config.HAEnabled && (config.DatabasePath == ":memory:" || strings.Contains(config.DatabasePath, "mode=memory")) {
+		problems.add("HA_ENABLED needs a DATABASE_PATH shared by both instances, not an in-memory database")
+	}
+
+	// The bot talks to the Local Bot API with USE_LOCAL_BOT_API alone, but
+	// downloads only use its file paths when both are set
+	if config.UseLocalBotAPI != config.LocalBotAPIEnabled {
+		problems.add("USE_LOCAL_BOT_API and LOCAL_BOT_API_ENABLED must both be true or both false")
+	}
+
+	// A key or name used by two clients would make the audit trail ambiguous
+	keys := make(map[string]string)
+	names := make(map[string]bool)
+	clients := append(append([]APIClient{}, config.APIKeys...), config.APIClientCerts...)
+	if config.APIAuthToken != "" {
+		clients = append(clients, APIClient{Name: "API_AUTH_TOKEN", Key: config.APIAuthToken})
+	}
+	for _, client := range clients {
+		if names[client.Name] {
+			problems.add("API client %q is listed more than once in API_KEYS/API_CLIENT_CERTS", client.Name)
+		}
+		names[client.Name] = true
+		if client.Key == "" {
+			continue
+		}
+		if other, ok := keys[client.Key]; ok {
+			problems.add("API clients %q and %q use the same key", other, client.Name)
+		}
+		keys[client.Key] = client.Name
+	}
+
+	// Certificates and CAs are only read when the listener starts, which for
+	// the coordinator happens after leader election
+	for _, setting := range [][2]string{
+		{"API_TLS_CERT", config.APITLSCert},
+		{"API_TLS_KEY", config.APITLSKey},
+		{"API_TLS_CLIENT_CA", config.APITLSClientCA},
+		{"CLUSTER_TLS_CERT", config.ClusterTLSCert},
+		{"CLUSTER_TLS_KEY", config.ClusterTLSKey},
+		{"CLUSTER_TLS_CLIENT_CA", config.ClusterTLSClientCA},
+	} {
+		checkFileExists(problems, setting[0], setting[1])
+	}
+
+	// Updates are only installed when signed by the configured key
+	if config.UpdateFeedURL != "" {
+		if u, err := url.Parse(config.UpdateFeedURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems.add("invalid UPDATE_FEED_URL: %s", config.UpdateFeedURL)
+		}
+		if _, err := ParseUpdatePublicKey(config.UpdatePublicKey); err != nil {
+			problems.add("UPDATE_FEED_URL needs UPDATE_PUBLIC_KEY: %v", err)
+		}
+	}
+
+	if config.OCREnabled {
+		if _, err := exec.LookPath(config.OCRTesseractPath); err != nil {
+			problems.add("OCR_ENABLED needs tesseract, but OCR_TESSERACT_PATH %q was not found", config.OCRTesseractPath)
+		}
+	}
+
+	// Directories the bot creates on demand; they fail much later otherwise
+	for _, setting := range [][2]string{
+		{"DATABASE_PATH", filepath.Dir(config.DatabasePath)},
+		{"LOG_FILE_PATH", filepath.Dir(config.LogFilePath)},
+		{"CRASH_REPORT_DIR", config.CrashReportDir},
+		{"PROFILE_DIR", config.ProfileDir},
+		{"BACKUP_DIR", config.BackupDir},
+		{"QUARANTINE_KEY_PATH", filepath.Dir(config.QuarantineKeyPath)},
+		{"DEDUP_FILTER_PATH", filepath.Dir(config.DedupFilterPath)},
+		{"UPDATE_STAGING_DIR", config.UpdateStagingDir},
+	} {
+		checkDirCreatable(problems, setting[0], setting[1])
+	}
+	for _, dir := range config.QuarantineFallbackDirs {
+		checkDirCreatable(problems, "QUARANTINE_FALLBACK_DIRS", dir)
+	}
+}
+
+// checkListenAddr requires host:port when addr is set
+func checkListenAddr(problems *ConfigError, name, addr string) {
+	if addr == "" {
+		return
+	}
+	if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+		problems.add("invalid %s (host:port, e.g. 127.0.0.1:8080): %s", name, addr)
+	}
+}
+
+// checkFileExists requires path to be a readable file when set
+func checkFileExists(problems *ConfigError, name, path string) {
+	if path == "" {
+		return
+	}
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		problems.add("%s %s: %w", name, path, errors.Unwrap(err))
+	case info.IsDir():
+		problems.add("%s %s is a directory, not a file", name, path)
+	}
+}
+
+// checkDirCreatable requires dir to be a directory or creatable as one, i.e.
+// its nearest existing ancestor is a directory
+func checkDirCreatable(problems *ConfigError, name, dir string) {
+	if dir == "" || dir == "." {
+		return
+	}
+	for path := filepath.Clean(dir); ; path = filepath.Dir(path) {
+		info, err := os.Stat(path)
+		if err == nil {
+			if !info.IsDir() {
+				problems.add("%s: %s is a file, so %s can't be created", name, path, dir)
+			}
+			return
+		}
+		// A missing parent, or a file in the way of one, is checked further up
+		if filepath.Dir(path) == path {
+			return
+		}
+	}
+}
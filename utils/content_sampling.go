@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// contentScanChunk is how much is read at a time during a content scan
+const contentScanChunk = 1024 * 1024
+
+// contentScanOverlap is carried between chunks so patterns spanning a chunk
+// boundary are still found
+const contentScanOverlap = 4096
+
+// DefaultContentScanTiers fully scans files up to 64 MB and samples larger ones
+const DefaultContentScanTiers = "64MB=full,1GB=1MB/32,max=2MB/48"
+
+// ContentScanTier sets how files up to MaxSize are content scanned
+type ContentScanTier struct {
+	MaxSize    int64 // Largest file size in the tier; 0 means no limit
+	FullScan   bool  // Scan the whole file
+	WindowSize int64 // Size of the head, tail and interior sample windows
+	Samples    int   // Random interior windows besides head and tail
+}
+
+// String renders the tier in CONTENT_SCAN_TIERS syntax
+func (t ContentScanTier) String() string {
+	limit := "max"
+	if t.MaxSize > 0 {
+		limit = formatByteSize(t.MaxSize)
+	}
+	if t.FullScan {
+		return limit + "=full"
+	}
+	return fmt.Sprintf("%s=%s/%d", limit, formatByteSize(t.WindowSize), t.Samples)
+}
+
+// ParseContentScanTiers parses a comma-separated tier list such as
+// "64MB=full,1GB=1MB/32,max=2MB/48": files up to each size are either scanned
+// fully or sampled with head, tail and N interior windows of the given size.
+// Tiers must be in ascending order and end with "max"
+func ParseContentScanTiers(spec string) ([]ContentScanTier, error) {
+	var tiers []ContentScanTier
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		limit, mode, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("tier %q: expected <size>=full or <size>=<window>/<samples>", part)
+		}
+
+		var tier ContentScanTier
+		if strings.TrimSpace(limit) != "max" {
+			size, err := parseByteSize(limit)
+			if err != nil || size <= 0 {
+				return nil, fmt.Errorf("tier %q: invalid size %q", part, limit)
+			}
+			tier.MaxSize = size
+		}
+		if len(tiers) > 0 {
+			last := tiers[len(tiers)-1]
+			if last.MaxSize == 0 || (tier.MaxSize != 0 && tier.MaxSize <= last.MaxSize) {
+				return nil, fmt.Errorf("tier %q: tiers must be in ascending size order", part)
+			}
+		}
+
+		mode = strings.TrimSpace(mode)
+		if mode == "full" {
+			tier.FullScan = true
+		} else {
+			window, samples, ok := strings.Cut(mode, "/")
+			if !ok {
+				return nil, fmt.Errorf("tier %q: expected full or <window>/<samples>", part)
+			}
+			size, err := parseByteSize(window)
+			if err != nil || size <= 0 {
+				return nil, fmt.Errorf("tier %q: invalid window size %q", part, window)
+			}
+			tier.WindowSize = size
+			tier.Samples, err = strconv.Atoi(strings.TrimSpace(samples))
+			if err != nil || tier.Samples < 0 {
+				return nil, fmt.Errorf("tier %q: invalid sample count %q", part, samples)
+			}
+		}
+		tiers = append(tiers, tier)
+	}
+
+	if len(tiers) == 0 || tiers[len(tiers)-1].MaxSize != 0 {
+		return nil, fmt.Errorf("the last tier must be max=...")
+	}
+	return tiers, nil
+}
+
+// parseByteSize parses sizes like 512KB, 64MB or 2GB (binary units)
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSuffix(s, unit.suffix)
+			multiplier = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * multiplier, nil
+}
+
+// formatByteSize renders a size in the largest whole binary unit
+func formatByteSize(n int64) string {
+	switch {
+	case n%(1<<30) == 0:
+		return fmt.Sprintf("%dGB", n>>30)
+	case n%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", n>>20)
+	case n%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", n>>10)
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
+
+// contentScanTier picks the tier for a file size
+func contentScanTier(tiers []ContentScanTier, size int64) ContentScanTier {
+	for _, tier := range tiers {
+		if tier.MaxSize == 0 || size <= tier.MaxSize {
+			return tier
+		}
+	}
+	return ContentScanTier{FullScan: true}
+}
+
+// scanWindow is a byte range of a file to content scan
+type scanWindow struct {
+	offset int64
+	length int64
+}
+
+// sampleWindows returns the head, tail and random interior windows to scan
+// for tier, merged and in file order. The interior is split into one stratum
+// per sample so the windows spread across the whole file
+func sampleWindows(size int64, tier ContentScanTier, rng *rand.Rand) []scanWindow {
+	if tier.FullScan || size <= 2*tier.WindowSize {
+		return []scanWindow{{0, size}}
+	}
+
+	windows := []scanWindow{{0, tier.WindowSize}, {size - tier.WindowSize, tier.WindowSize}}
+
+	interiorStart := tier.WindowSize
+	interior := size - 2*tier.WindowSize
+	if tier.Samples > 0 && interior > tier.WindowSize {
+		stratum := interior / int64(tier.Samples)
+		for i := 0; i < tier.Samples; i++ {
+			start := interiorStart + int64(i)*stratum
+			span := stratum - tier.WindowSize
+			if span < 1 {
+				span = 1
+			}
+			windows = append(windows, scanWindow{start + rng.Int63n(span), tier.WindowSize})
+		}
+	}
+
+	// Merge overlapping windows
+	sort.Slice(windows, func(i, j int) bool { return windows[i].offset < windows[j].offset })
+	merged := windows[:1]
+	for _, w := range windows[1:] {
+		last := &merged[len(merged)-1]
+		if w.offset <= last.offset+last.length {
+			if end := w.offset + w.length; end > last.offset+last.length {
+				last.length = end - last.offset
+			}
+			continue
+		}
+		merged = append(merged, w)
+	}
+	return merged
+}
+
+// scanWindows reads the windows of file chunk by chunk and calls match with
+// each chunk and its file offset; it returns the number of bytes read
+func scanWindows(file *os.File, windows []scanWindow, match func(chunk []byte, offset int64)) (int64, error) {
+	buf := make([]byte, contentScanChunk+contentScanOverlap)
+	var scanned int64
+
+	for _, w := range windows {
+		pos := w.offset
+		end := w.offset + w.length
+		carried := 0
+		for pos < end {
+			n := int64(contentScanChunk)
+			if end-pos < n {
+				n = end - pos
+			}
+			read, err := file.ReadAt(buf[carried:carried+int(n)], pos)
+			if err != nil && err != io.EOF {
+				return scanned, fmt.Errorf("failed to read file content at offset %d: %w", pos, err)
+			}
+			if read == 0 {
+				break
+			}
+			scanned += int64(read)
+
+			chunk := buf[:carried+read]
+			match(chunk, pos-int64(carried))
+			pos += int64(read)
+
+			// Keep the tail of this chunk for patterns crossing into the next
+			carried = contentScanOverlap
+			if carried > len(chunk) {
+				carried = len(chunk)
+			}
+			copy(buf, chunk[len(chunk)-carried:])
+		}
+	}
+	return scanned, nil
+}
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CrashReport captures a recovered panic together with the task it happened in
+type CrashReport struct {
+	ID         string                 `json:"id"`
+	Component  string                 `json:"component"`
+	TaskID     string                 `json:"task_id,omitempty"`
+	UserID     int64                  `json:"user_id,omitempty"`
+	PanicValue string                 `json:"panic_value"`
+	Stack      string                 `json:"stack"`
+	Context    map[string]interface{} `json:"context,omitempty"`
+	Hostname   string                 `json:"hostname"`
+	GoVersion  string                 `json:"go_version"`
+	Goroutines int                    `json:"goroutines"`
+	Timestamp  time.Time              `json:"timestamp"`
+	// Build and SchemaVersion match the report to the exact code and database
+	Build         BuildInfo `json:"build"`
+	SchemaVersion int       `json:"schema_version,omitempty"`
+}
+
+// CrashSink receives every captured crash report (database, admin notification, Sentry)
+type CrashSink func(report *CrashReport)
+
+// CrashReporter writes crash reports to a directory and fans them out to sinks
+type CrashReporter struct {
+	logger        *Logger
+	dir           string
+	mutex         sync.RWMutex
+	sinks         []CrashSink
+	schemaVersion int
+}
+
+var (
+	defaultCrashReporter   *CrashReporter
+	defaultCrashReporterMu sync.RWMutex
+)
+
+// NewCrashReporter creates a reporter that stores JSON reports under dir
+func NewCrashReporter(logger *Logger, dir string) *CrashReporter {
+	return &CrashReporter{
+		logger: logger,
+		dir:    dir,
+	}
+}
+
+// SetDefaultCrashReporter installs the reporter used by RecoverPanic and SafeGo
+func SetDefaultCrashReporter(reporter *CrashReporter) {
+	defaultCrashReporterMu.Lock()
+	defer defaultCrashReporterMu.Unlock()
+	defaultCrashReporter = reporter
+}
+
+// AddSink registers a destination for crash reports
+func (cr *CrashReporter) AddSink(sink CrashSink) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	cr.sinks = append(cr.sinks, sink)
+}
+
+// SetSchemaVersion sets the database schema version recorded in reports,
+// once the database is migrated
+func (cr *CrashReporter) SetSchemaVersion(version int) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	cr.schemaVersion = version
+}
+
+// Capture builds, persists and dispatches a crash report for a recovered panic
+func (cr *CrashReporter) Capture(component string, panicValue interface{}, stack []byte, fields map[string]interface{}) *CrashReport {
+	hostname, _ := os.Hostname()
+	report := &CrashReport{
+		ID:         uuid.New().String(),
+		Component:  component,
+		PanicValue: fmt.Sprintf("%v", panicValue),
+		Stack:      string(stack),
+		Context:    fields,
+		Hostname:   hostname,
+		GoVersion:  runtime.Version(),
+		Goroutines: runtime.NumGoroutine(),
+		Timestamp:  time.Now(),
+		Build:      CurrentBuild(),
+	}
+	cr.mutex.RLock()
+	report.SchemaVersion = cr.schemaVersion
+	cr.mutex.RUnlock()
+	if taskID, ok := fields["task_id"].(string); ok {
+		report.TaskID = taskID
+	}
+	if userID, ok := fields["user_id"].(int64); ok {
+		report.UserID = userID
+	}
+
+	cr.logger.WithField("crash_id", report.ID).
+		WithField("component", component).
+		WithField("task_id", report.TaskID).
+		WithField("panic", report.PanicValue).
+		Error("Recovered from panic")
+
+	if err := cr.writeReport(report); err != nil {
+		cr.logger.WithError(err).WithField("crash_id", report.ID).Warn("Failed to write crash report file")
+	}
+
+	cr.mutex.RLock()
+	sinks := make([]CrashSink, len(cr.sinks))
+	copy(sinks, cr.sinks)
+	cr.mutex.RUnlock()
+
+	for _, sink := range sinks {
+		func() {
+			// A failing sink must never take the recovering goroutine down with it
+			defer func() {
+				if r := recover(); r != nil {
+					cr.logger.WithField("panic", r).Error("Crash report sink panicked")
+				}
+			}()
+			sink(report)
+		}()
+	}
+
+	return report
+}
+
+// writeReport stores the report as JSON in the crash directory
+func (cr *CrashReporter) writeReport(report *CrashReport) error {
+	if cr.dir == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode crash report: %w", err)
+	}
+
+	name := fmt.Sprintf("crash_%s_%s_%s.json", report.Timestamp.Format("20060102_150405"), report.Component, report.ID[:8])
+	return WriteFileAtomic(filepath.Join(cr.dir, name), data, 0644)
+}
+
+// CapturePanic reports an already-recovered panic through the default reporter
+func CapturePanic(component string, panicValue interface{}, stack []byte, fields map[string]interface{}) *CrashReport {
+	defaultCrashReporterMu.RLock()
+	reporter := defaultCrashReporter
+	defaultCrashReporterMu.RUnlock()
+
+	if reporter == nil {
+		fmt.Fprintf(os.Stderr, "panic in %s: %v\n%s\n", component, panicValue, stack)
+		return nil
+	}
+	return reporter.Capture(component, panicValue, stack, fields)
+}
+
+// RecoverPanic must be deferred directly; it recovers a panic in the current
+// goroutine and reports it with the given task context
+func RecoverPanic(component string, fields map[string]interface{}) {
+	if r := recover(); r != nil {
+		CapturePanic(component, r, debug.Stack(), fields)
+	}
+}
+
+// SafeGo runs fn in a new goroutine with panic recovery and crash reporting
+func SafeGo(component string, fields map[string]interface{}, fn func()) {
+	go func() {
+		defer RecoverPanic(component, fields)
+		fn()
+	}()
+}
@@ -0,0 +1,294 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Well-known dependency names that have built-in recovery actions
+const (
+	PassFileDependency    = "app/extraction/pass.txt"
+	LocalBotAPIDependency = "local_bot_api"
+)
+
+// recoveryTimeout bounds a single recovery action such as a go build
+const recoveryTimeout = 2 * time.Minute
+
+// RecoveryAction tries to bring a failed dependency back
+type RecoveryAction func(ctx context.Context) error
+
+// recoveryStep is a named recovery action registered for a dependency
+type recoveryStep struct {
+	name   string
+	action RecoveryAction
+}
+
+// RecoveryAttempt records one recovery action run against a dependency
+type RecoveryAttempt struct {
+	Dependency  string        `json:"dependency"`
+	Action      string        `json:"action"`
+	Error       string        `json:"error,omitempty"`
+	Success     bool          `json:"success"`
+	Recovered   bool          `json:"recovered"`
+	Duration    time.Duration `json:"duration"`
+	AttemptedAt time.Time     `json:"attempted_at"`
+}
+
+// RecoveryAuditFunc receives every recovery attempt for the audit log
+type RecoveryAuditFunc func(attempt RecoveryAttempt)
+
+// RegisterEndpoint registers an HTTP service dependency that is healthy while
+// it answers requests to url
+func (gdm *GracefulDegradationManager) RegisterEndpoint(name, url string, checkInterval time.Duration, fallbackMode FallbackMode) {
+	gdm.RegisterDependency(name, "endpoint", checkInterval, fallbackMode)
+
+	gdm.mutex.Lock()
+	gdm.dependencies[name].Target = url
+	gdm.mutex.Unlock()
+}
+
+// RegisterRecoveryAction adds a recovery action that runs, in registration
+// order, before a failing dependency is marked degraded
+func (gdm *GracefulDegradationManager) RegisterRecoveryAction(dependencyName, actionName string, action RecoveryAction) {
+	gdm.mutex.Lock()
+	defer gdm.mutex.Unlock()
+
+	gdm.recoveryActions[dependencyName] = append(gdm.recoveryActions[dependencyName], recoveryStep{
+		name:   actionName,
+		action: action,
+	})
+}
+
+// SetRecoveryAuditor sets where recovery attempts are audit-logged
+func (gdm *GracefulDegradationManager) SetRecoveryAuditor(auditor RecoveryAuditFunc) {
+	gdm.mutex.Lock()
+	defer gdm.mutex.Unlock()
+	gdm.recoveryAuditor = auditor
+}
+
+// RegisterDefaultRecoveryActions attaches the built-in recovery actions to the
+// already registered dependencies that have one
+func (gdm *GracefulDegradationManager) RegisterDefaultRecoveryActions(config *Config) {
+	gdm.mutex.RLock()
+	deps := make(map[string]string, len(gdm.dependencies))
+	for name, dep := range gdm.dependencies {
+		deps[name] = dep.Type
+	}
+	gdm.mutex.RUnlock()
+
+	gdm.mutex.Lock()
+	gdm.recoveryCooldown = config.DependencyRecoveryCooldown
+	gdm.mutex.Unlock()
+
+	for name, depType := range deps {
+		switch {
+		case depType == "directory":
+			gdm.RegisterRecoveryAction(name, "recreate_directory", RecreateDirectory(name))
+		case name == PassFileDependency:
+			gdm.RegisterRecoveryAction(name, "regenerate_pass_file", RegeneratePassFile(config.PasswordStorePath, name))
+		case name == LocalBotAPIDependency && config.LocalBotAPIRestartCommand != "":
+			gdm.RegisterRecoveryAction(name, "restart_local_bot_api", RunCommand(config.LocalBotAPIRestartCommand))
+		}
+	}
+}
+
+// attemptRecovery runs the recovery actions of a failing dependency and
+// reports whether it passes its check afterwards. Attempts are rate limited
+// by the recovery cooldown. The actions run without gdm.mutex held
+func (gdm *GracefulDegradationManager) attemptRecovery(dep DependencyInfo) (bool, string) {
+	name := dep.Name
+
+	gdm.mutex.Lock()
+	steps := gdm.recoveryActions[name]
+	if len(steps) == 0 {
+		gdm.mutex.Unlock()
+		return false, ""
+	}
+	if last, ok := gdm.lastRecovery[name]; ok && time.Since(last) < gdm.recoveryCooldown {
+		gdm.mutex.Unlock()
+		return false, ""
+	}
+	gdm.lastRecovery[name] = time.Now()
+	gdm.mutex.Unlock()
+
+	var lastError string
+	for _, step := range steps {
+		ctx, cancel := context.WithTimeout(context.Background(), recoveryTimeout)
+		start := time.Now()
+		err := step.action(ctx)
+		cancel()
+
+		attempt := RecoveryAttempt{
+			Dependency:  name,
+			Action:      step.name,
+			Success:     err == nil,
+			Duration:    time.Since(start),
+			AttemptedAt: start,
+		}
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+
+		var available bool
+		var errorMsg string
+		if err == nil {
+			available, errorMsg = gdm.runCheck(name, &dep)
+			attempt.Recovered = available
+		}
+
+		gdm.auditRecovery(attempt)
+
+		if available {
+			return true, ""
+		}
+		if err != nil {
+			errorMsg = err.Error()
+		}
+		lastError = errorMsg
+	}
+
+	return false, lastError
+}
+
+// auditRecovery logs a recovery attempt and hands it to the auditor
+func (gdm *GracefulDegradationManager) auditRecovery(attempt RecoveryAttempt) {
+	entry := gdm.logger.WithField("dependency", attempt.Dependency).
+		WithField("action", attempt.Action).
+		WithField("duration", attempt.Duration).
+		WithField("recovered", attempt.Recovered)
+	if attempt.Error != "" {
+		entry.WithField("error", attempt.Error).Warn("Dependency recovery action failed")
+	} else if attempt.Recovered {
+		entry.Info("Dependency recovered by recovery action")
+	} else {
+		entry.Warn("Dependency recovery action ran but dependency is still unavailable")
+	}
+
+	gdm.mutex.RLock()
+	auditor := gdm.recoveryAuditor
+	gdm.mutex.RUnlock()
+	if auditor != nil {
+		auditor(attempt)
+	}
+}
+
+// checkEndpoint verifies that an HTTP service answers; any non-5xx status counts
+func (gdm *GracefulDegradationManager) checkEndpoint(url string) (bool, string) {
+	if url == "" {
+		return false, "endpoint has no URL"
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return false, fmt.Sprintf("endpoint %s unreachable: %v", url, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return false, fmt.Sprintf("endpoint %s returned %s", url, resp.Status)
+	}
+	return true, ""
+}
+
+// RecreateDirectory recreates a missing directory
+func RecreateDirectory(path string) RecoveryAction {
+	return func(ctx context.Context) error {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return fmt.Errorf("failed to recreate directory %s: %w", path, err)
+		}
+		return nil
+	}
+}
+
+// RegeneratePassFile rebuilds pass.txt from the password store, which is either
+// a single file or a directory of *.txt files with one password per line
+func RegeneratePassFile(storePath, passFile string) RecoveryAction {
+	return func(ctx context.Context) error {
+		info, err := os.Stat(storePath)
+		if err != nil {
+			return fmt.Errorf("password store unavailable: %w", err)
+		}
+
+		sources := []string{storePath}
+		if info.IsDir() {
+			sources, err = filepath.Glob(filepath.Join(storePath, "*.txt"))
+			if err != nil {
+				return fmt.Errorf("failed to list password store: %w", err)
+			}
+			sort.Strings(sources)
+		}
+
+		seen := make(map[string]bool)
+		var passwords []string
+		for _, source := range sources {
+			if err := readPasswordLines(source, seen, &passwords); err != nil {
+				return err
+			}
+		}
+		if len(passwords) == 0 {
+			return fmt.Errorf("password store %s contains no passwords", storePath)
+		}
+
+		tmpPath := passFile + ".tmp"
+		content := strings.Join(passwords, "\n") + "\n"
+		if err := os.WriteFile(tmpPath, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", tmpPath, err)
+		}
+		if err := os.Rename(tmpPath, passFile); err != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to replace %s: %w", passFile, err)
+		}
+		return nil
+	}
+}
+
+// readPasswordLines appends the unique non-empty lines of a password file
+func readPasswordLines(path string, seen map[string]bool, passwords *[]string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open password store file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		*passwords = append(*passwords, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read password store file %s: %w", path, err)
+	}
+	return nil
+}
+
+// RunCommand runs a shell command, e.g. to restart a local service
+func RunCommand(command string) RecoveryAction {
+	return func(ctx context.Context) error {
+		output, err := exec.CommandContext(ctx, "sh", "-c", command).CombinedOutput()
+		if err != nil {
+			return commandError(command, err, output)
+		}
+		return nil
+	}
+}
+
+// commandError wraps a command failure with the command's output, if any
+func commandError(command string, err error, output []byte) error {
+	if out := strings.TrimSpace(string(output)); out != "" {
+		return fmt.Errorf("%q failed: %w: %s", command, err, out)
+	}
+	return fmt.Errorf("%q failed: %w", command, err)
+}
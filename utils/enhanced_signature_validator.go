@@ -11,11 +11,8 @@ import (
 
 // EnhancedSignatureValidator provides advanced file signature verification
 type EnhancedSignatureValidator struct {
-	logger              *Logger
-	allowedSignatures   map[string][]FileSignatureRule
-	malwareSignatures   []MalwareSignature
-	polyglotPatterns    []PolyglotPattern
-	suspiciousPatterns  []SuspiciousPattern
+	logger   *Logger
+	registry *SignatureRegistry
 }
 
 // FileSignatureRule represents a comprehensive file signature rule
@@ -70,261 +67,15 @@ type SignatureValidationResult struct {
 	IsGenuineFileType   bool
 	SecurityWarnings    []string
 	ThreatAssessment    ThreatLevel
+	Anomaly             *AnomalyScore
 }
 
 // NewEnhancedSignatureValidator creates a new enhanced signature validator
-func NewEnhancedSignatureValidator(logger *Logger) *EnhancedSignatureValidator {
-	esv := &EnhancedSignatureValidator{
-		logger: logger,
-	}
-	
-	esv.initializeAllowedSignatures()
-	esv.initializeMalwareSignatures()
-	esv.initializePolyglotPatterns()
-	esv.initializeSuspiciousPatterns()
-	
-	return esv
-}
-
-// initializeAllowedSignatures sets up comprehensive file signature rules
-func (esv *EnhancedSignatureValidator) initializeAllowedSignatures() {
-	esv.allowedSignatures = map[string][]FileSignatureRule{
-		"zip": {
-			{
-				Name:        "ZIP Local File Header",
-				Magic:       []byte{0x50, 0x4B, 0x03, 0x04},
-				Offset:      0,
-				Extension:   "zip",
-				MimeType:    "application/zip",
-				Description: "Standard ZIP archive",
-				MinSize:     22, // Minimum ZIP file size
-				MaxSize:     0,  // No max limit
-				Required:    true,
-			},
-			{
-				Name:        "ZIP Empty Archive",
-				Magic:       []byte{0x50, 0x4B, 0x05, 0x06},
-				Offset:      0,
-				Extension:   "zip",
-				MimeType:    "application/zip",
-				Description: "Empty ZIP archive",
-				MinSize:     22,
-				MaxSize:     0,
-				Required:    false,
-				Alternative: true,
-			},
-			{
-				Name:        "ZIP Spanned Archive",
-				Magic:       []byte{0x50, 0x4B, 0x07, 0x08},
-				Offset:      0,
-				Extension:   "zip",
-				MimeType:    "application/zip",
-				Description: "ZIP spanned archive",
-				MinSize:     22,
-				MaxSize:     0,
-				Required:    false,
-				Alternative: true,
-			},
-		},
-		"rar": {
-			{
-				Name:        "RAR v4.x Archive",
-				Magic:       []byte{0x52, 0x61, 0x72, 0x21, 0x1A, 0x07, 0x00},
-				Offset:      0,
-				Extension:   "rar",
-				MimeType:    "application/vnd.rar",
-				Description: "RAR version 4.x archive",
-				MinSize:     20,
-				MaxSize:     0,
-				Required:    true,
-			},
-			{
-				Name:        "RAR v5.x Archive",
-				Magic:       []byte{0x52, 0x61, 0x72, 0x21, 0x1A, 0x07, 0x01, 0x00},
-				Offset:      0,
-				Extension:   "rar",
-				MimeType:    "application/vnd.rar",
-				Description: "RAR version 5.x archive",
-				MinSize:     20,
-				MaxSize:     0,
-				Required:    false,
-				Alternative: true,
-			},
-		},
-		"txt": {
-			{
-				Name:        "Text File (UTF-8 BOM)",
-				Magic:       []byte{0xEF, 0xBB, 0xBF},
-				Offset:      0,
-				Extension:   "txt",
-				MimeType:    "text/plain",
-				Description: "UTF-8 text file with BOM",
-				MinSize:     3,
-				MaxSize:     0,
-				Required:    false,
-				Alternative: true,
-			},
-			{
-				Name:        "Text File (UTF-16 LE BOM)",
-				Magic:       []byte{0xFF, 0xFE},
-				Offset:      0,
-				Extension:   "txt",
-				MimeType:    "text/plain",
-				Description: "UTF-16 Little Endian text file with BOM",
-				MinSize:     2,
-				MaxSize:     0,
-				Required:    false,
-				Alternative: true,
-			},
-			{
-				Name:        "Text File (UTF-16 BE BOM)",
-				Magic:       []byte{0xFE, 0xFF},
-				Offset:      0,
-				Extension:   "txt",
-				MimeType:    "text/plain",
-				Description: "UTF-16 Big Endian text file with BOM",
-				MinSize:     2,
-				MaxSize:     0,
-				Required:    false,
-				Alternative: true,
-			},
-		},
-	}
-}
-
-// initializeMalwareSignatures sets up known malware signatures
-func (esv *EnhancedSignatureValidator) initializeMalwareSignatures() {
-	esv.malwareSignatures = []MalwareSignature{
-		{
-			Name:        "PE Executable Header",
-			Pattern:     []byte{0x4D, 0x5A}, // "MZ"
-			Offset:      0,
-			Description: "Windows PE executable embedded in file",
-			ThreatLevel: ThreatLevelCritical,
-		},
-		{
-			Name:        "ELF Executable Header",
-			Pattern:     []byte{0x7F, 0x45, 0x4C, 0x46}, // "\x7FELF"
-			Offset:      0,
-			Description: "Linux ELF executable embedded in file",
-			ThreatLevel: ThreatLevelCritical,
-		},
-		{
-			Name:        "Mach-O Executable (32-bit)",
-			Pattern:     []byte{0xFE, 0xED, 0xFA, 0xCE},
-			Offset:      0,
-			Description: "macOS Mach-O executable embedded in file",
-			ThreatLevel: ThreatLevelCritical,
-		},
-		{
-			Name:        "Mach-O Executable (64-bit)",
-			Pattern:     []byte{0xFE, 0xED, 0xFA, 0xCF},
-			Offset:      0,
-			Description: "macOS Mach-O 64-bit executable embedded in file",
-			ThreatLevel: ThreatLevelCritical,
-		},
-		{
-			Name:        "Java Class File",
-			Pattern:     []byte{0xCA, 0xFE, 0xBA, 0xBE},
-			Offset:      0,
-			Description: "Java class file (potential malware)",
-			ThreatLevel: ThreatLevelHigh,
-		},
-		{
-			Name:        "PDF with JavaScript",
-			Pattern:     []byte("/JavaScript"),
-			Offset:      -1, // Can appear anywhere
-			Description: "PDF with potentially malicious JavaScript",
-			ThreatLevel: ThreatLevelHigh,
-		},
-		{
-			Name:        "HTML Script Tag",
-			Pattern:     []byte("<script"),
-			Offset:      -1,
-			Description: "HTML with script tags in archive",
-			ThreatLevel: ThreatLevelMedium,
-		},
-		{
-			Name:        "VBS Script",
-			Pattern:     []byte("WScript.Shell"),
-			Offset:      -1,
-			Description: "Visual Basic Script with shell access",
-			ThreatLevel: ThreatLevelHigh,
-		},
-		{
-			Name:        "PowerShell Command",
-			Pattern:     []byte("powershell"),
-			Offset:      -1,
-			Description: "PowerShell command execution",
-			ThreatLevel: ThreatLevelHigh,
-		},
-	}
-}
-
-// initializePolyglotPatterns sets up polyglot file detection
-func (esv *EnhancedSignatureValidator) initializePolyglotPatterns() {
-	esv.polyglotPatterns = []PolyglotPattern{
-		{
-			Name: "ZIP-PDF Polyglot",
-			Signatures: [][]byte{
-				{0x50, 0x4B, 0x03, 0x04}, // ZIP signature
-				{0x25, 0x50, 0x44, 0x46}, // "%PDF"
-			},
-			Description: "File that can be interpreted as both ZIP and PDF",
-			RiskLevel:   ThreatLevelHigh,
-		},
-		{
-			Name: "ZIP-HTML Polyglot",
-			Signatures: [][]byte{
-				{0x50, 0x4B, 0x03, 0x04}, // ZIP signature
-				[]byte("<html"),
-			},
-			Description: "File that can be interpreted as both ZIP and HTML",
-			RiskLevel:   ThreatLevelMedium,
-		},
-		{
-			Name: "RAR-EXE Polyglot",
-			Signatures: [][]byte{
-				{0x52, 0x61, 0x72, 0x21}, // RAR signature
-				{0x4D, 0x5A},             // EXE signature
-			},
-			Description: "File that can be interpreted as both RAR and executable",
-			RiskLevel:   ThreatLevelCritical,
-		},
-	}
-}
-
-// initializeSuspiciousPatterns sets up suspicious file characteristic detection
-func (esv *EnhancedSignatureValidator) initializeSuspiciousPatterns() {
-	esv.suspiciousPatterns = []SuspiciousPattern{
-		{
-			Name:        "Double Extension Pattern",
-			Pattern:     []byte(".txt.exe"),
-			Offset:      -1,
-			Description: "File name with double extension (social engineering)",
-			Action:      "quarantine",
-		},
-		{
-			Name:        "Hidden Extension Pattern",
-			Pattern:     []byte(".scr"),
-			Offset:      -1,
-			Description: "Screen saver file extension (often malware)",
-			Action:      "reject",
-		},
-		{
-			Name:        "Macro Signature",
-			Pattern:     []byte("macroEnabled"),
-			Offset:      -1,
-			Description: "Document contains macros (potential threat)",
-			Action:      "monitor",
-		},
-		{
-			Name:        "Zip Bomb Indicator",
-			Pattern:     []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // Long sequence of zeros
-			Offset:      -1,
-			Description: "Potential zip bomb (highly compressed data)",
-			Action:      "inspect",
-		},
+// using the definitions held by registry
+func NewEnhancedSignatureValidator(logger *Logger, registry *SignatureRegistry) *EnhancedSignatureValidator {
+	return &EnhancedSignatureValidator{
+		logger:   logger,
+		registry: registry,
 	}
 }
 
@@ -365,20 +116,23 @@ func (esv *EnhancedSignatureValidator) ValidateFileSignature(filePath, declaredT
 	}
 	header = header[:n]
 	
+	// Use one version of the definitions throughout, even if they are reloaded meanwhile
+	defs := esv.registry.Current()
+	
 	// Step 1: Validate against allowed signatures
-	esv.validateAllowedSignatures(header, declaredType, result)
+	esv.validateAllowedSignatures(defs, header, declaredType, result)
 	
 	// Step 2: Check for malware signatures
-	esv.detectMalwareSignatures(header, result)
+	esv.detectMalwareSignatures(defs, header, result)
 	
 	// Step 3: Detect polyglot files
-	esv.detectPolyglotPatterns(header, result)
+	esv.detectPolyglotPatterns(defs, header, result)
 	
 	// Step 4: Check for suspicious patterns
-	esv.detectSuspiciousPatterns(header, filePath, result)
+	esv.detectSuspiciousPatterns(defs, header, filePath, result)
 	
 	// Step 5: Perform anti-spoofing checks
-	esv.performAntiSpoofingChecks(header, declaredType, stat.Size(), result)
+	esv.performAntiSpoofingChecks(defs, header, declaredType, stat.Size(), result)
 	
 	// Step 6: Calculate overall threat assessment
 	esv.calculateThreatAssessment(result)
@@ -388,14 +142,16 @@ func (esv *EnhancedSignatureValidator) ValidateFileSignature(filePath, declaredT
 		WithField("confidence", result.ConfidenceLevel).
 		WithField("threat_level", result.ThreatAssessment).
 		WithField("genuine", result.IsGenuineFileType).
+		WithField("anomaly_score", result.Anomaly.Score).
+		WithField("definitions_version", defs.Version).
 		Info("Enhanced signature validation completed")
 	
 	return result, nil
 }
 
 // validateAllowedSignatures checks if file matches allowed signature patterns
-func (esv *EnhancedSignatureValidator) validateAllowedSignatures(header []byte, declaredType string, result *SignatureValidationResult) {
-	rules, exists := esv.allowedSignatures[declaredType]
+func (esv *EnhancedSignatureValidator) validateAllowedSignatures(defs *SignatureSet, header []byte, declaredType string, result *SignatureValidationResult) {
+	rules, exists := defs.AllowedSignatures[declaredType]
 	if !exists {
 		result.SecurityWarnings = append(result.SecurityWarnings, 
 			fmt.Sprintf("No signature rules defined for file type: %s", declaredType))
@@ -444,8 +200,8 @@ func (esv *EnhancedSignatureValidator) matchesSignature(header []byte, rule File
 }
 
 // detectMalwareSignatures scans for known malware patterns
-func (esv *EnhancedSignatureValidator) detectMalwareSignatures(header []byte, result *SignatureValidationResult) {
-	for _, signature := range esv.malwareSignatures {
+func (esv *EnhancedSignatureValidator) detectMalwareSignatures(defs *SignatureSet, header []byte, result *SignatureValidationResult) {
+	for _, signature := range defs.MalwareSignatures {
 		if esv.findPattern(header, signature.Pattern, signature.Offset) {
 			result.DetectedMalware = append(result.DetectedMalware, signature.Name)
 			result.SecurityWarnings = append(result.SecurityWarnings,
@@ -460,8 +216,8 @@ func (esv *EnhancedSignatureValidator) detectMalwareSignatures(header []byte, re
 }
 
 // detectPolyglotPatterns checks for files that can be interpreted as multiple types
-func (esv *EnhancedSignatureValidator) detectPolyglotPatterns(header []byte, result *SignatureValidationResult) {
-	for _, pattern := range esv.polyglotPatterns {
+func (esv *EnhancedSignatureValidator) detectPolyglotPatterns(defs *SignatureSet, header []byte, result *SignatureValidationResult) {
+	for _, pattern := range defs.PolyglotPatterns {
 		matchCount := 0
 		for _, signature := range pattern.Signatures {
 			if esv.findPattern(header, signature, 0) {
@@ -483,9 +239,9 @@ func (esv *EnhancedSignatureValidator) detectPolyglotPatterns(header []byte, res
 }
 
 // detectSuspiciousPatterns looks for potentially dangerous file characteristics
-func (esv *EnhancedSignatureValidator) detectSuspiciousPatterns(header []byte, filePath string, result *SignatureValidationResult) {
+func (esv *EnhancedSignatureValidator) detectSuspiciousPatterns(defs *SignatureSet, header []byte, filePath string, result *SignatureValidationResult) {
 	// Check header content
-	for _, pattern := range esv.suspiciousPatterns {
+	for _, pattern := range defs.SuspiciousPatterns {
 		if esv.findPattern(header, pattern.Pattern, pattern.Offset) {
 			result.SuspiciousFeatures = append(result.SuspiciousFeatures, pattern.Name)
 			result.SecurityWarnings = append(result.SecurityWarnings,
@@ -505,9 +261,9 @@ func (esv *EnhancedSignatureValidator) detectSuspiciousPatterns(header []byte, f
 }
 
 // performAntiSpoofingChecks validates file authenticity
-func (esv *EnhancedSignatureValidator) performAntiSpoofingChecks(header []byte, declaredType string, fileSize int64, result *SignatureValidationResult) {
+func (esv *EnhancedSignatureValidator) performAntiSpoofingChecks(defs *SignatureSet, header []byte, declaredType string, fileSize int64, result *SignatureValidationResult) {
 	// Check 1: File size consistency
-	rules, exists := esv.allowedSignatures[declaredType]
+	rules, exists := defs.AllowedSignatures[declaredType]
 	if exists {
 		for _, rule := range rules {
 			if rule.MinSize > 0 && fileSize < rule.MinSize {
@@ -529,12 +285,14 @@ func (esv *EnhancedSignatureValidator) performAntiSpoofingChecks(header []byte,
 		esv.validateRarHeaderConsistency(header, result)
 	}
 	
-	// Check 3: Entropy analysis for potential encryption/packing
-	entropy := esv.calculateEntropy(header)
-	if entropy > 7.5 { // High entropy might indicate encryption or packing
-		result.AntiSpoofingChecks = append(result.AntiSpoofingChecks,
-			fmt.Sprintf("High entropy detected (%.2f) - possible encryption or packing", entropy))
-		result.ThreatAssessment = ThreatLevelMedium
+	// Check 3: Entropy and compression-ratio anomaly scoring
+	anomaly := ScoreAnomaly(header, declaredType)
+	result.Anomaly = &anomaly
+	if anomaly.Score >= AnomalyScoreLow {
+		for _, reason := range anomaly.Reasons {
+			result.AntiSpoofingChecks = append(result.AntiSpoofingChecks,
+				fmt.Sprintf("%s (anomaly score %.2f)", reason, anomaly.Score))
+		}
 	}
 }
 
@@ -578,32 +336,6 @@ func (esv *EnhancedSignatureValidator) validateRarHeaderConsistency(header []byt
 	}
 }
 
-// calculateEntropy calculates Shannon entropy of data
-func (esv *EnhancedSignatureValidator) calculateEntropy(data []byte) float64 {
-	if len(data) == 0 {
-		return 0.0
-	}
-	
-	// Count frequency of each byte value
-	freq := make(map[byte]int)
-	for _, b := range data {
-		freq[b]++
-	}
-	
-	// Calculate entropy
-	entropy := 0.0
-	dataLen := float64(len(data))
-	
-	for _, count := range freq {
-		if count > 0 {
-			p := float64(count) / dataLen
-			entropy -= p * (float64(count) / dataLen) // Simplified calculation
-		}
-	}
-	
-	return entropy * 8.0 // Scale to bits
-}
-
 // findPattern searches for a pattern in data at specified offset
 func (esv *EnhancedSignatureValidator) findPattern(data, pattern []byte, offset int) bool {
 	if len(pattern) == 0 {
@@ -648,16 +380,27 @@ func (esv *EnhancedSignatureValidator) calculateThreatAssessment(result *Signatu
 		}
 	}
 	
+	// Escalate based on the composite entropy/compression anomaly score
+	if result.Anomaly != nil && result.Anomaly.ThreatLevel() > maxThreat {
+		maxThreat = result.Anomaly.ThreatLevel()
+	}
+	
 	result.ThreatAssessment = maxThreat
 }
 
 // GetSignatureInfo returns detailed information about supported file signatures
 func (esv *EnhancedSignatureValidator) GetSignatureInfo() map[string]interface{} {
 	info := make(map[string]interface{})
+	defs := esv.registry.Current()
+	
+	// Definitions version and where they were loaded from
+	info["definitions_version"] = defs.Version
+	info["definitions_source"] = defs.Source
+	info["definitions_loaded_at"] = defs.LoadedAt
 	
 	// Allowed signatures info
 	allowedInfo := make(map[string]interface{})
-	for fileType, rules := range esv.allowedSignatures {
+	for fileType, rules := range defs.AllowedSignatures {
 		ruleInfo := make([]map[string]interface{}, 0)
 		for _, rule := range rules {
 			ruleInfo = append(ruleInfo, map[string]interface{}{
@@ -675,13 +418,13 @@ func (esv *EnhancedSignatureValidator) GetSignatureInfo() map[string]interface{}
 	info["allowed_signatures"] = allowedInfo
 	
 	// Malware signatures count
-	info["malware_signatures_count"] = len(esv.malwareSignatures)
+	info["malware_signatures_count"] = len(defs.MalwareSignatures)
 	
 	// Polyglot patterns count
-	info["polyglot_patterns_count"] = len(esv.polyglotPatterns)
+	info["polyglot_patterns_count"] = len(defs.PolyglotPatterns)
 	
 	// Suspicious patterns count
-	info["suspicious_patterns_count"] = len(esv.suspiciousPatterns)
+	info["suspicious_patterns_count"] = len(defs.SuspiciousPatterns)
 	
 	return info
 }
\ No newline at end of file
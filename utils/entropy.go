@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"math"
+)
+
+// anomalySectionSize is the size of the sections whose entropy is compared
+const anomalySectionSize = 1024
+
+// Composite anomaly score thresholds used in threat assessment
+const (
+	AnomalyScoreLow    = 0.4
+	AnomalyScoreMedium = 0.7
+)
+
+// compressedFileTypes are declared types whose content is expected to be
+// compressed, so high entropy is normal for them
+var compressedFileTypes = map[string]bool{
+	"zip": true,
+	"rar": true,
+}
+
+// AnomalyScore is the entropy and compression analysis of a file sample
+type AnomalyScore struct {
+	Entropy          float64   `json:"entropy"`           // Shannon entropy in bits per byte, 0-8
+	SectionEntropies []float64 `json:"section_entropies"` // Entropy of each 1 KB section
+	EntropySpread    float64   `json:"entropy_spread"`    // Highest minus lowest section entropy
+	CompressionRatio float64   `json:"compression_ratio"` // Deflated size / original size
+	Score            float64   `json:"score"`             // Composite score, 0 (normal) - 1 (anomalous)
+	Reasons          []string  `json:"reasons,omitempty"`
+}
+
+// ShannonEntropy returns the Shannon entropy of data in bits per byte (0-8)
+func ShannonEntropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	var freq [256]int
+	for _, b := range data {
+		freq[b]++
+	}
+
+	entropy := 0.0
+	n := float64(len(data))
+	for _, count := range freq {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// SectionEntropies returns the entropy of each sectionSize chunk of data; a
+// trailing chunk shorter than half a section is folded into the previous one
+func SectionEntropies(data []byte, sectionSize int) []float64 {
+	if len(data) == 0 || sectionSize <= 0 {
+		return nil
+	}
+
+	var entropies []float64
+	for start := 0; start < len(data); start += sectionSize {
+		end := start + sectionSize
+		if end > len(data) || len(data)-end < sectionSize/2 {
+			end = len(data)
+		}
+		entropies = append(entropies, ShannonEntropy(data[start:end]))
+		if end == len(data) {
+			break
+		}
+	}
+	return entropies
+}
+
+// CompressionRatio returns the deflated size of data relative to its size;
+// close to 1 means incompressible (compressed or encrypted), close to 0 highly redundant
+func CompressionRatio(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return 0
+	}
+	w.Write(data)
+	w.Close()
+	return float64(buf.Len()) / float64(len(data))
+}
+
+// ScoreAnomaly combines overall entropy, per-section entropy and compression
+// ratio into a composite anomaly score, judged against what is normal for the
+// declared file type
+func ScoreAnomaly(data []byte, declaredType string) AnomalyScore {
+	score := AnomalyScore{
+		Entropy:          ShannonEntropy(data),
+		SectionEntropies: SectionEntropies(data, anomalySectionSize),
+		CompressionRatio: CompressionRatio(data),
+	}
+	if len(data) < anomalySectionSize {
+		// Too little data for meaningful statistics
+		return score
+	}
+
+	minEntropy, maxEntropy := 8.0, 0.0
+	for _, e := range score.SectionEntropies {
+		minEntropy = math.Min(minEntropy, e)
+		maxEntropy = math.Max(maxEntropy, e)
+	}
+	score.EntropySpread = maxEntropy - minEntropy
+
+	compressed := compressedFileTypes[declaredType]
+
+	// Entropy outside the range expected for the type
+	if compressed && score.Entropy < 3.0 {
+		score.add(0.2*clamp01((3.0-score.Entropy)/3.0),
+			fmt.Sprintf("Low entropy (%.2f) for a compressed %s archive", score.Entropy, declaredType))
+	} else if !compressed && score.Entropy > 6.5 {
+		score.add(0.45*clamp01((score.Entropy-6.5)/1.5),
+			fmt.Sprintf("High entropy (%.2f) - possible encryption or packing", score.Entropy))
+	}
+
+	// Incompressible content is expected inside archives but not elsewhere
+	if score.CompressionRatio > 0.98 && score.Entropy > 7.8 {
+		weight := 0.3
+		if compressed {
+			weight = 0.15
+		}
+		score.add(weight, fmt.Sprintf("Incompressible content (ratio %.2f) - encrypted or packed data", score.CompressionRatio))
+	}
+
+	// Extremely redundant content, e.g. the padding of a decompression bomb
+	if score.CompressionRatio < 0.05 {
+		score.add(0.3*clamp01((0.05-score.CompressionRatio)/0.05),
+			fmt.Sprintf("Highly redundant content (compression ratio %.3f)", score.CompressionRatio))
+	}
+
+	// Sharp entropy changes between sections suggest an embedded or appended payload
+	if score.EntropySpread > 3.0 {
+		score.add(0.3*clamp01((score.EntropySpread-3.0)/3.0),
+			fmt.Sprintf("Entropy varies by %.2f bits between sections - possible embedded payload", score.EntropySpread))
+	}
+
+	score.Score = math.Round(score.Score*100) / 100
+	return score
+}
+
+// ThreatLevel maps the composite score to the threat level it warrants
+func (s AnomalyScore) ThreatLevel() ThreatLevel {
+	switch {
+	case s.Score >= AnomalyScoreMedium:
+		return ThreatLevelMedium
+	case s.Score >= AnomalyScoreLow:
+		return ThreatLevelLow
+	default:
+		return ThreatLevelSafe
+	}
+}
+
+// add raises the score by a weighted component and records why
+func (s *AnomalyScore) add(component float64, reason string) {
+	if component <= 0 {
+		return
+	}
+	s.Score = math.Min(1, s.Score+component)
+	s.Reasons = append(s.Reasons, reason)
+}
+
+// clamp01 limits v to the range 0-1
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -225,7 +225,8 @@ func (ec *ErrorClassifier) getHandlingStrategy(category ErrorCategory, errorText
 		return SeverityHigh, RetryDelayed, true
 
 	case ErrorCategoryTelegramAPI:
-		if strings.Contains(errorText, "flood control") || strings.Contains(errorText, "rate limit") {
+		if strings.Contains(errorText, "flood control") || strings.Contains(errorText, "rate limit") ||
+			strings.Contains(errorText, "too many requests") || strings.Contains(errorText, "retry after") {
 			return SeverityLow, RetryDelayed, true
 		}
 		if strings.Contains(errorText, "unauthorized") || strings.Contains(errorText, "forbidden") {
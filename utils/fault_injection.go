@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fault points the workers, orchestrator and task store inject faults at
+const (
+	FaultDownload = "download"
+	FaultExtract  = "extract"
+	FaultConvert  = "convert"
+	FaultDB       = "db"
+)
+
+// faultPoints are the points that take FAIL_<POINT>_PERCENT and
+// DELAY_<POINT>_SECONDS; the database only takes DB_BUSY_PROBABILITY
+var faultPoints = []string{FaultDownload, FaultExtract, FaultConvert}
+
+// ErrInjectedFault marks failures caused by fault injection
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultInjector fails or delays operations at fault points, so retries, the
+// dead letter queue and alerting can be exercised in integration tests and
+// chaos drills. The zero value injects nothing
+type FaultInjector struct {
+	failRate map[string]float64 // Probability in [0, 1]
+	delay    map[string]time.Duration
+	random   RandomSource
+}
+
+// Faults is the process-wide injector. It only injects in binaries built
+// with -tags faultinject; see loadFaultInjection
+var Faults = &FaultInjector{}
+
+// ParseFaultInjection reads FAIL_<POINT>_PERCENT (0-100),
+// DELAY_<POINT>_SECONDS, DB_BUSY_PROBABILITY (0-1) and FAULT_SEED, which
+// makes the injected sequence reproducible
+func ParseFaultInjection(getenv func(string) string) (*FaultInjector, error) {
+	f := &FaultInjector{
+		failRate: make(map[string]float64),
+		delay:    make(map[string]time.Duration),
+		random:   DefaultRandomSource,
+	}
+	var problems []string
+
+	for _, point := range faultPoints {
+		upper := strings.ToUpper(point)
+
+		name := "FAIL_" + upper + "_PERCENT"
+		if v := getenv(name); v != "" {
+			percent, err := strconv.ParseFloat(v, 64)
+			if err != nil || percent < 0 || percent > 100 {
+				problems = append(problems, fmt.Sprintf("invalid %s (0-100): %s", name, v))
+			} else if percent > 0 {
+				f.failRate[point] = percent / 100
+			}
+		}
+
+		name = "DELAY_" + upper + "_SECONDS"
+		if v := getenv(name); v != "" {
+			seconds, err := strconv.ParseFloat(v, 64)
+			if err != nil || seconds < 0 {
+				problems = append(problems, fmt.Sprintf("invalid %s: %s", name, v))
+			} else if seconds > 0 {
+				f.delay[point] = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+
+	if v := getenv("DB_BUSY_PROBABILITY"); v != "" {
+		probability, err := strconv.ParseFloat(v, 64)
+		if err != nil || probability < 0 || probability > 1 {
+			problems = append(problems, fmt.Sprintf("invalid DB_BUSY_PROBABILITY (0-1): %s", v))
+		} else if probability > 0 {
+			f.failRate[FaultDB] = probability
+		}
+	}
+
+	if v := getenv("FAULT_SEED"); v != "" {
+		seed, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid FAULT_SEED: %s", v))
+		} else {
+			f.random = NewSeededRandomSource(seed)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+	return f, nil
+}
+
+// Enabled reports whether any fault is configured
+func (f *FaultInjector) Enabled() bool {
+	return len(f.failRate) > 0 || len(f.delay) > 0
+}
+
+// Inject waits the configured delay of point, then fails with its configured
+// probability. Database faults look like SQLITE_BUSY so they take the same
+// retry paths as real lock contention
+func (f *FaultInjector) Inject(ctx context.Context, point string) error {
+	if delay := f.delay[point]; delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
+	rate := f.failRate[point]
+	if rate <= 0 || f.random.Float64() >= rate {
+		return nil
+	}
+	if point == FaultDB {
+		return fmt.Errorf("database is locked (SQLITE_BUSY): %w", ErrInjectedFault)
+	}
+	return fmt.Errorf("%s failed: %w", point, ErrInjectedFault)
+}
+
+// String lists the configured faults, e.g. "download: fail 10%, delay 2s"
+func (f *FaultInjector) String() string {
+	points := make(map[string]bool)
+	for point := range f.failRate {
+		points[point] = true
+	}
+	for point := range f.delay {
+		points[point] = true
+	}
+	names := make([]string, 0, len(points))
+	for point := range points {
+		names = append(names, point)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, point := range names {
+		var faults []string
+		if rate := f.failRate[point]; rate > 0 {
+			faults = append(faults, fmt.Sprintf("fail %g%%", rate*100))
+		}
+		if delay := f.delay[point]; delay > 0 {
+			faults = append(faults, "delay "+delay.String())
+		}
+		parts = append(parts, point+": "+strings.Join(faults, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
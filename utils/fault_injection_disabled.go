@@ -0,0 +1,10 @@
+//go:build !faultinject
+
+package utils
+
+// FaultInjectionBuild reports whether this binary honors the fault settings
+const FaultInjectionBuild = false
+
+// loadFaultInjection ignores the fault settings, so a stray variable can't
+// break a production deployment
+func loadFaultInjection(problems *ConfigError) {}
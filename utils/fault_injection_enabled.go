@@ -0,0 +1,18 @@
+//go:build faultinject
+
+package utils
+
+import "os"
+
+// FaultInjectionBuild reports whether this binary honors the fault settings
+const FaultInjectionBuild = true
+
+// loadFaultInjection installs the faults configured in the environment
+func loadFaultInjection(problems *ConfigError) {
+	faults, err := ParseFaultInjection(os.Getenv)
+	if err != nil {
+		problems.addErr(err)
+		return
+	}
+	Faults = faults
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxStorageNameBytes keeps sanitized names well below common filesystem limits
+// so task ID and timestamp prefixes can still be added
+const maxStorageNameBytes = 180
+
+// maxExtensionBytes caps the preserved extension, dot included, so a crafted
+// name cannot spend the whole budget on it
+const maxExtensionBytes = 16
+
+// transliterations maps letters without a decomposition to ASCII equivalents
+var transliterations = map[rune]string{
+	// Cyrillic (Russian, Ukrainian, Belarusian)
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'ґ': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'є': "ye", 'ж': "zh", 'з': "z", 'и': "i", 'і': "i", 'ї': "yi", 'й': "y", 'к': "k",
+	'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ў': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+	// Latin letters that do not decompose into base letter + accent
+	'ß': "ss", 'æ': "ae", 'ø': "o", 'œ': "oe", 'ð': "d", 'þ': "th", 'ł': "l", 'đ': "d",
+	'ı': "i",
+}
+
+// SanitizeFileName converts a user-supplied file name into a name that is safe
+// for on-disk storage and shell tooling: Unicode is normalized, accents are
+// stripped, Cyrillic and Greek are transliterated and anything outside
+// [A-Za-z0-9._-] becomes an underscore. The extension is preserved in lower case
+func SanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	ext := strings.ToLower(filepath.Ext(name))
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+
+	safeExt := sanitizeComponent(ext)
+	if safeExt != "" && !strings.HasPrefix(safeExt, ".") {
+		safeExt = "." + safeExt
+	}
+	if len(safeExt) > maxExtensionBytes {
+		safeExt = strings.TrimRight(safeExt[:maxExtensionBytes], "._-")
+	}
+	if safeExt == "." {
+		safeExt = ""
+	}
+
+	safeBase := strings.Trim(sanitizeComponent(base), "._-")
+	if safeBase == "" {
+		safeBase = "file"
+	}
+
+	if len(safeBase)+len(safeExt) > maxStorageNameBytes {
+		safeBase = strings.TrimRight(safeBase[:maxStorageNameBytes-len(safeExt)], "._-")
+	}
+	return safeBase + safeExt
+}
+
+// IsSafeFileName reports whether a name is already in the storage charset
+func IsSafeFileName(name string) bool {
+	return name != "" && SanitizeFileName(name) == name
+}
+
+// sanitizeComponent transliterates and filters a name, collapsing runs of
+// replaced characters into a single underscore
+func sanitizeComponent(s string) string {
+	var b strings.Builder
+	lastUnderscore := false
+
+	writeSafe := func(r rune) {
+		switch {
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-'):
+			b.WriteRune(r)
+			lastUnderscore = false
+		default:
+			if !lastUnderscore {
+				b.WriteByte('_')
+				lastUnderscore = true
+			}
+		}
+	}
+
+	for _, r := range norm.NFC.String(s) {
+		lower := unicode.ToLower(r)
+		if t, ok := transliterations[lower]; ok {
+			if unicode.IsUpper(r) && t != "" {
+				t = strings.ToUpper(t[:1]) + t[1:]
+			}
+			for _, tr := range t {
+				writeSafe(tr)
+			}
+			continue
+		}
+
+		// NFKD splits accented letters into base letter + combining mark and
+		// folds compatibility forms such as full-width digits and ligatures
+		for _, d := range norm.NFKD.String(string(r)) {
+			if unicode.Is(unicode.Mn, d) {
+				continue
+			}
+			if t, ok := transliterations[unicode.ToLower(d)]; ok {
+				for _, tr := range t {
+					writeSafe(tr)
+				}
+				continue
+			}
+			writeSafe(d)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "report.pdf", "report.pdf"},
+		{"transliterated", "Привет мир.TXT", "Privet_mir.txt"},
+		{"path stripped", `C:\Users\me\logs.zip`, "logs.zip"},
+		{"extension only", ".bashrc", "file.bashrc"},
+		{"empty", "", "file"},
+		{"long extension", "a." + strings.Repeat("b", 200), "a." + strings.Repeat("b", 15)},
+		{"long extension only", "." + strings.Repeat("b", 200), "file." + strings.Repeat("b", 15)},
+		{"long extension cut at a dash", "a." + strings.Repeat("b", 14) + "--cc", "a." + strings.Repeat("b", 14)},
+		{"long base", strings.Repeat("x", 300) + ".zip", strings.Repeat("x", maxStorageNameBytes-4) + ".zip"},
+		{"long base and extension", strings.Repeat("x", 300) + "." + strings.Repeat("y", 300), strings.Repeat("x", maxStorageNameBytes-maxExtensionBytes) + "." + strings.Repeat("y", 15)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SanitizeFileName(tt.in)
+			if got != tt.want {
+				t.Errorf("SanitizeFileName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if len(got) > maxStorageNameBytes {
+				t.Errorf("SanitizeFileName(%q) is %d bytes, over %d", tt.in, len(got), maxStorageNameBytes)
+			}
+			if !IsSafeFileName(got) {
+				t.Errorf("SanitizeFileName(%q) = %q, which is not stable", tt.in, got)
+			}
+		})
+	}
+}
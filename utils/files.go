@@ -1,9 +1,7 @@
 package utils
 
 import (
-	"crypto/sha256"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,33 +10,38 @@ import (
 
 type FileManager struct {
 	logger *Logger
+	tuning IOTuning
 }
 
 func NewFileManager(logger *Logger) *FileManager {
 	return &FileManager{
 		logger: logger,
+		tuning: DefaultIOTuning(),
 	}
 }
 
+// SetIOTuning overrides the buffer and cache hints used for copies and hashing
+func (fm *FileManager) SetIOTuning(tuning IOTuning) {
+	fm.tuning = tuning
+}
+
 func (fm *FileManager) MoveFile(src, dst string) error {
 	fm.logger.WithField("source", src).
 		WithField("destination", dst).
 		Debug("Moving file")
 
-	// Ensure destination directory exists
-	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
-		return fmt.Errorf("failed to create destination directory: %w", err)
+	opts := MoveOptions{
+		Tuning: fm.tuning,
+		Verify: true,
+		Progress: func(copied, total int64) {
+			fm.logger.WithField("source", src).
+				WithField("copied", copied).
+				WithField("total", total).
+				Debug("Cross-filesystem move in progress")
+		},
 	}
-
-	// Try rename first (fastest if on same filesystem)
-	if err := os.Rename(src, dst); err != nil {
-		// If rename fails, copy and delete
-		if err := fm.CopyFile(src, dst); err != nil {
-			return fmt.Errorf("failed to copy file: %w", err)
-		}
-		if err := os.Remove(src); err != nil {
-			fm.logger.WithError(err).WithField("file", src).Warn("Failed to remove source file after copy")
-		}
+	if err := MoveFile(src, dst, opts); err != nil {
+		return fmt.Errorf("failed to move file: %w", err)
 	}
 
 	fm.logger.WithField("source", src).
@@ -49,26 +52,14 @@ func (fm *FileManager) MoveFile(src, dst string) error {
 }
 
 func (fm *FileManager) CopyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return fmt.Errorf("failed to open source file: %w", err)
-	}
-	defer sourceFile.Close()
-
 	// Ensure destination directory exists
 	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
 		return fmt.Errorf("failed to create destination directory: %w", err)
 	}
 
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
-	}
-	defer destFile.Close()
-
-	bytesWritten, err := io.Copy(destFile, sourceFile)
+	bytesWritten, err := CopyFileTuned(src, dst, fm.tuning)
 	if err != nil {
-		return fmt.Errorf("failed to copy file contents: %w", err)
+		return err
 	}
 
 	fm.logger.WithField("source", src).
@@ -80,18 +71,12 @@ func (fm *FileManager) CopyFile(src, dst string) error {
 }
 
 func (fm *FileManager) CalculateFileHash(filePath string) (string, error) {
-	file, err := os.Open(filePath)
+	hash, _, err := HashFileTuned(filePath, fm.tuning)
 	if err != nil {
-		return "", fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
-
-	hasher := sha256.New()
-	if _, err := io.Copy(hasher, file); err != nil {
 		return "", fmt.Errorf("failed to calculate hash: %w", err)
 	}
 
-	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+	return hash, nil
 }
 
 func (fm *FileManager) ValidateFileType(fileName string) (string, error) {
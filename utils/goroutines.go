@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Goroutines is the registry of the process's long-lived background goroutines
+var Goroutines = NewGoroutineRegistry()
+
+// GoroutineRegistry tracks long-lived goroutines by name and heartbeat, so
+// stalled ones can be spotted and the total goroutine count compared with
+// what is expected to run
+type GoroutineRegistry struct {
+	mutex   sync.Mutex
+	nextID  uint64
+	entries map[uint64]*RegisteredGoroutine
+}
+
+// RegisteredGoroutine is a goroutine's entry in the registry
+type RegisteredGoroutine struct {
+	registry   *GoroutineRegistry
+	id         uint64
+	name       string
+	staleAfter time.Duration
+	startedAt  time.Time
+	lastBeat   atomic.Int64
+}
+
+// GoroutineInfo describes a registered goroutine
+type GoroutineInfo struct {
+	Name          string        `json:"name"`
+	StartedAt     time.Time     `json:"started_at"`
+	LastHeartbeat time.Time     `json:"last_heartbeat"`
+	StaleAfter    time.Duration `json:"stale_after,omitempty"`
+	Stale         bool          `json:"stale"`
+}
+
+// NewGoroutineRegistry creates an empty registry
+func NewGoroutineRegistry() *GoroutineRegistry {
+	return &GoroutineRegistry{entries: make(map[uint64]*RegisteredGoroutine)}
+}
+
+// Register adds the calling goroutine under name. It counts as stalled when
+// it hasn't called Beat for staleAfter; 0 only counts it. Call Done when the
+// goroutine returns
+func (r *GoroutineRegistry) Register(name string, staleAfter time.Duration) *RegisteredGoroutine {
+	now := time.Now()
+	g := &RegisteredGoroutine{registry: r, name: name, staleAfter: staleAfter, startedAt: now}
+	g.lastBeat.Store(now.UnixNano())
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.nextID++
+	g.id = r.nextID
+	r.entries[g.id] = g
+	return g
+}
+
+// Track registers a goroutine and returns a context whose Heartbeat also
+// beats the registration, next to any heartbeat ctx already carries
+func (r *GoroutineRegistry) Track(ctx context.Context, name string, staleAfter time.Duration) (context.Context, *RegisteredGoroutine) {
+	g := r.Register(name, staleAfter)
+	return WithHeartbeat(ctx, func() {
+		g.Beat()
+		Heartbeat(ctx)
+	}), g
+}
+
+// Beat records that the goroutine is making progress
+func (g *RegisteredGoroutine) Beat() {
+	g.lastBeat.Store(time.Now().UnixNano())
+}
+
+// Done removes the goroutine from the registry
+func (g *RegisteredGoroutine) Done() {
+	g.registry.mutex.Lock()
+	defer g.registry.mutex.Unlock()
+	delete(g.registry.entries, g.id)
+}
+
+// Len returns how many goroutines are registered
+func (r *GoroutineRegistry) Len() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.entries)
+}
+
+// Snapshot lists the registered goroutines by name, then start time
+func (r *GoroutineRegistry) Snapshot() []GoroutineInfo {
+	r.mutex.Lock()
+	entries := make([]*RegisteredGoroutine, 0, len(r.entries))
+	for _, g := range r.entries {
+		entries = append(entries, g)
+	}
+	r.mutex.Unlock()
+
+	now := time.Now()
+	infos := make([]GoroutineInfo, 0, len(entries))
+	for _, g := range entries {
+		lastBeat := time.Unix(0, g.lastBeat.Load())
+		infos = append(infos, GoroutineInfo{
+			Name:          g.name,
+			StartedAt:     g.startedAt,
+			LastHeartbeat: lastBeat,
+			StaleAfter:    g.staleAfter,
+			Stale:         g.staleAfter > 0 && now.Sub(lastBeat) > g.staleAfter,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Name != infos[j].Name {
+			return infos[i].Name < infos[j].Name
+		}
+		return infos[i].StartedAt.Before(infos[j].StartedAt)
+	})
+	return infos
+}
+
+// Stale lists the registered goroutines that stopped heartbeating
+func (r *GoroutineRegistry) Stale() []GoroutineInfo {
+	var stale []GoroutineInfo
+	for _, info := range r.Snapshot() {
+		if info.Stale {
+			stale = append(stale, info)
+		}
+	}
+	return stale
+}
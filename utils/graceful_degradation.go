@@ -24,6 +24,7 @@ const (
 type DependencyInfo struct {
 	Name            string           `json:"name"`
 	Type            string           `json:"type"`
+	Target          string           `json:"target,omitempty"`
 	Status          DependencyStatus `json:"status"`
 	LastCheck       time.Time        `json:"last_check"`
 	LastAvailable   time.Time        `json:"last_available"`
@@ -46,12 +47,19 @@ const (
 type GracefulDegradationManager struct {
 	dependencies      map[string]*DependencyInfo
 	fallbackModes     map[string]FallbackMode
-	checkTicker       *time.Ticker
-	stopChan          chan struct{}
+	routines          *Routines
 	mutex             sync.RWMutex
 	logger            *Logger
 	queuedOperations  []QueuedOperation
 	notificationsSent map[string]time.Time
+	recoveryActions   map[string][]recoveryStep
+	lastRecovery      map[string]time.Time
+	recoveryCooldown  time.Duration
+	recoveryAuditor   RecoveryAuditFunc
+	operationHandlers map[string]OperationHandler
+	operationStore    OperationStore
+	operationsRun     int
+	operationsFailed  int
 }
 
 // QueuedOperation represents an operation waiting for dependency recovery
@@ -59,11 +67,35 @@ type QueuedOperation struct {
 	ID           string                 `json:"id"`
 	DependencyName string               `json:"dependency_name"`
 	Operation    string                 `json:"operation"`
+	TaskID       string                 `json:"task_id,omitempty"`
+	Stage        string                 `json:"stage,omitempty"`
 	Parameters   map[string]interface{} `json:"parameters"`
 	QueuedAt     time.Time              `json:"queued_at"`
 	MaxWaitTime  time.Duration          `json:"max_wait_time"`
+	Attempts     int                    `json:"attempts"`
+	LastError    string                 `json:"last_error,omitempty"`
 }
 
+// OperationHandler re-dispatches a queued operation once its dependency recovers
+type OperationHandler func(op QueuedOperation) error
+
+// OperationStore persists queued operations so they survive a restart
+type OperationStore interface {
+	SaveQueuedOperation(op QueuedOperation) error
+	DeleteQueuedOperation(id string) error
+	LoadQueuedOperations(dependencyName string) ([]QueuedOperation, error)
+}
+
+// maxOperationAttempts is how often a queued operation is re-dispatched before it is dropped
+const maxOperationAttempts = 3
+
+// dependencyCheckInterval is how often the monitoring loop checks dependencies
+const dependencyCheckInterval = 30 * time.Second
+
+// dependencyMonitorStaleAfter is how long a check, including recovery
+// actions, may run before the monitoring loop counts as stalled
+const dependencyMonitorStaleAfter = 10 * time.Minute
+
 // NewGracefulDegradationManager creates a new degradation manager
 func NewGracefulDegradationManager(logger *Logger) *GracefulDegradationManager {
 	return &GracefulDegradationManager{
@@ -72,7 +104,10 @@ func NewGracefulDegradationManager(logger *Logger) *GracefulDegradationManager {
 		logger:            logger,
 		queuedOperations:  make([]QueuedOperation, 0),
 		notificationsSent: make(map[string]time.Time),
-		stopChan:          make(chan struct{}),
+		recoveryActions:   make(map[string][]recoveryStep),
+		lastRecovery:      make(map[string]time.Time),
+		recoveryCooldown:  5 * time.Minute,
+		operationHandlers: make(map[string]OperationHandler),
 	}
 }
 
@@ -98,68 +133,170 @@ func (gdm *GracefulDegradationManager) RegisterDependency(name, depType string,
 		Info("Registered dependency for graceful degradation")
 }
 
-// StartMonitoring begins continuous monitoring of dependencies
-func (gdm *GracefulDegradationManager) StartMonitoring(ctx context.Context) {
-	gdm.checkTicker = time.NewTicker(30 * time.Second) // Check every 30 seconds
+// RegisterOperationHandler sets how queued operations of a kind are executed
+// once their dependency is available again
+func (gdm *GracefulDegradationManager) RegisterOperationHandler(operation string, handler OperationHandler) {
+	gdm.mutex.Lock()
+	defer gdm.mutex.Unlock()
+	gdm.operationHandlers[operation] = handler
+}
+
+// SetOperationStore persists queued operations in store and restores the
+// unexpired ones queued before a restart for this manager's queue dependencies
+func (gdm *GracefulDegradationManager) SetOperationStore(store OperationStore) {
+	gdm.mutex.Lock()
+	defer gdm.mutex.Unlock()
+	
+	gdm.operationStore = store
 	
-	go func() {
+	queued := make(map[string]bool, len(gdm.queuedOperations))
+	for _, op := range gdm.queuedOperations {
+		queued[op.ID] = true
+	}
+	
+	restored := 0
+	for name, mode := range gdm.fallbackModes {
+		if mode != FallbackQueue {
+			continue
+		}
+		
+		ops, err := store.LoadQueuedOperations(name)
+		if err != nil {
+			gdm.logger.WithField("dependency", name).
+				WithError(err).
+				Error("Failed to restore queued operations")
+			continue
+		}
+		
+		for _, op := range ops {
+			if time.Since(op.QueuedAt) > op.MaxWaitTime {
+				gdm.logger.WithField("operation_id", op.ID).
+					WithField("dependency", op.DependencyName).
+					WithField("queued_at", op.QueuedAt).
+					Warn("Dropping queued operation that expired while the bot was down")
+				gdm.forgetOperation(op.ID)
+				continue
+			}
+			if !queued[op.ID] {
+				gdm.queuedOperations = append(gdm.queuedOperations, op)
+				queued[op.ID] = true
+				restored++
+			}
+		}
+	}
+	
+	if restored > 0 {
+		gdm.logger.WithField("restored_count", restored).
+			Info("Restored queued operations from previous run")
+	}
+}
+
+// persistOperation saves a queued operation if a store is configured
+func (gdm *GracefulDegradationManager) persistOperation(op QueuedOperation) {
+	if gdm.operationStore == nil {
+		return
+	}
+	if err := gdm.operationStore.SaveQueuedOperation(op); err != nil {
+		gdm.logger.WithField("operation_id", op.ID).
+			WithError(err).
+			Warn("Failed to persist queued operation")
+	}
+}
+
+// forgetOperation removes a queued operation from the store if one is configured
+func (gdm *GracefulDegradationManager) forgetOperation(id string) {
+	if gdm.operationStore == nil {
+		return
+	}
+	if err := gdm.operationStore.DeleteQueuedOperation(id); err != nil {
+		gdm.logger.WithField("operation_id", id).
+			WithError(err).
+			Warn("Failed to delete persisted queued operation")
+	}
+}
+
+// StartMonitoring begins continuous monitoring of dependencies until ctx is
+// cancelled or Shutdown is called
+func (gdm *GracefulDegradationManager) StartMonitoring(ctx context.Context) {
+	gdm.routines = NewRoutines(ctx)
+	gdm.routines.Go("dependency_monitor", dependencyMonitorStaleAfter, func(ctx context.Context) {
+		ticker := time.NewTicker(dependencyCheckInterval)
+		defer ticker.Stop()
+		
 		for {
 			select {
-			case <-gdm.checkTicker.C:
+			case <-ticker.C:
+				Heartbeat(ctx)
 				gdm.checkAllDependencies()
 				gdm.processQueuedOperations()
 				gdm.cleanupExpiredOperations()
-			case <-gdm.stopChan:
-				return
 			case <-ctx.Done():
 				return
 			}
 		}
-	}()
+	})
 	
 	gdm.logger.Info("Started graceful degradation monitoring")
 }
 
-// StopMonitoring stops dependency monitoring
-func (gdm *GracefulDegradationManager) StopMonitoring() {
-	if gdm.checkTicker != nil {
-		gdm.checkTicker.Stop()
+// Shutdown stops dependency monitoring and waits for a running check, which
+// may be running a recovery action, to finish
+func (gdm *GracefulDegradationManager) Shutdown(ctx context.Context) error {
+	if gdm.routines == nil {
+		return nil
+	}
+	if err := gdm.routines.Stop(ctx); err != nil {
+		return err
 	}
-	
-	close(gdm.stopChan)
 	gdm.logger.Info("Stopped graceful degradation monitoring")
+	return nil
 }
 
-// checkAllDependencies performs health checks on all registered dependencies
+// checkAllDependencies performs health checks on all registered dependencies.
+// The checks and recovery actions, which may run a command for minutes, run
+// without the lock so IsAvailable and the other readers are not held up
 func (gdm *GracefulDegradationManager) checkAllDependencies() {
 	gdm.mutex.Lock()
-	defer gdm.mutex.Unlock()
-	
-	for name, dep := range gdm.dependencies {
+	var due []DependencyInfo
+	for _, dep := range gdm.dependencies {
 		if time.Since(dep.LastCheck) >= dep.CheckInterval {
-			gdm.checkDependency(name, dep)
+			dep.LastCheck = time.Now()
+			due = append(due, *dep)
 		}
 	}
+	gdm.mutex.Unlock()
+	
+	for _, dep := range due {
+		isAvailable, errorMsg := gdm.checkDependency(dep)
+		gdm.applyCheck(dep.Name, isAvailable, errorMsg)
+	}
 }
 
 // checkDependency performs a health check on a specific dependency
-func (gdm *GracefulDegradationManager) checkDependency(name string, dep *DependencyInfo) {
-	dep.LastCheck = time.Now()
-	oldStatus := dep.Status
-	
-	var isAvailable bool
-	var errorMsg string
+func (gdm *GracefulDegradationManager) checkDependency(dep DependencyInfo) (bool, string) {
+	isAvailable, errorMsg := gdm.runCheck(dep.Name, &dep)
+	
+	// Try to repair the dependency before counting the failure
+	if !isAvailable {
+		if recovered, recoveryMsg := gdm.attemptRecovery(dep); recovered {
+			isAvailable = true
+		} else if recoveryMsg != "" {
+			errorMsg = recoveryMsg
+		}
+	}
+	return isAvailable, errorMsg
+}
+
+// applyCheck records the result of a dependency check
+func (gdm *GracefulDegradationManager) applyCheck(name string, isAvailable bool, errorMsg string) {
+	gdm.mutex.Lock()
+	defer gdm.mutex.Unlock()
 	
-	switch dep.Type {
-	case "executable":
-		isAvailable, errorMsg = gdm.checkExecutable(name)
-	case "file":
-		isAvailable, errorMsg = gdm.checkFile(name)
-	case "directory":
-		isAvailable, errorMsg = gdm.checkDirectory(name)
-	default:
-		isAvailable, errorMsg = false, "unknown dependency type"
+	dep, exists := gdm.dependencies[name]
+	if !exists {
+		return
 	}
+	oldStatus := dep.Status
 	
 	if isAvailable {
 		dep.Status = StatusAvailable
@@ -188,13 +325,28 @@ func (gdm *GracefulDegradationManager) checkDependency(name string, dep *Depende
 	}
 }
 
+// runCheck runs the availability check for the dependency's type
+func (gdm *GracefulDegradationManager) runCheck(name string, dep *DependencyInfo) (bool, string) {
+	switch dep.Type {
+	case "executable":
+		return gdm.checkExecutable(name)
+	case "file":
+		return gdm.checkFile(name)
+	case "directory":
+		return gdm.checkDirectory(name)
+	case "endpoint":
+		return gdm.checkEndpoint(dep.Target)
+	case "builtin":
+		// Compiled into the bot binary, so always present
+		return true, ""
+	default:
+		return false, "unknown dependency type"
+	}
+}
+
 // checkExecutable verifies if an executable dependency is available
 func (gdm *GracefulDegradationManager) checkExecutable(name string) (bool, string) {
 	switch name {
-	case "extract":
-		return gdm.checkGoFile("app/extraction/extract/extract.go")
-	case "convert":
-		return gdm.checkGoFile("app/extraction/convert/convert.go")
 	case "go":
 		_, err := exec.LookPath("go")
 		if err != nil {
@@ -210,25 +362,6 @@ func (gdm *GracefulDegradationManager) checkExecutable(name string) (bool, strin
 	}
 }
 
-// checkGoFile verifies if a Go source file is available and syntactically correct
-func (gdm *GracefulDegradationManager) checkGoFile(filepath string) (bool, string) {
-	// Check if file exists
-	if _, err := os.Stat(filepath); os.IsNotExist(err) {
-		return false, fmt.Sprintf("Go file %s does not exist", filepath)
-	}
-	
-	// Quick syntax check using go fmt
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
-	cmd := exec.CommandContext(ctx, "go", "fmt", "-n", filepath)
-	if err := cmd.Run(); err != nil {
-		return false, fmt.Sprintf("Go file %s has syntax errors: %v", filepath, err)
-	}
-	
-	return true, ""
-}
-
 // checkFile verifies if a file dependency exists
 func (gdm *GracefulDegradationManager) checkFile(filename string) (bool, string) {
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
@@ -321,12 +454,15 @@ func (gdm *GracefulDegradationManager) queueOperation(dependencyName, operation
 		ID:             generateOperationID(),
 		DependencyName: dependencyName,
 		Operation:      operation,
+		TaskID:         stringParameter(parameters, "task_id"),
+		Stage:          stringParameter(parameters, "stage"),
 		Parameters:     parameters,
 		QueuedAt:       time.Now(),
 		MaxWaitTime:    24 * time.Hour, // Queue for up to 24 hours
 	}
 	
 	gdm.queuedOperations = append(gdm.queuedOperations, op)
+	gdm.persistOperation(op)
 	
 	gdm.logger.WithField("operation_id", op.ID).
 		WithField("dependency", dependencyName).
@@ -378,7 +514,7 @@ func (gdm *GracefulDegradationManager) alternateExtraction(parameters map[string
 			filename := filepath.Base(file)
 			destPath := filepath.Join(errorsDir, "needs_manual_extraction_"+filename)
 			
-			if err := os.Rename(file, destPath); err != nil {
+			if err := MoveFile(file, destPath, DefaultMoveOptions()); err != nil {
 				gdm.logger.WithField("file", filename).
 					WithError(err).
 					Warn("Failed to move file for manual extraction")
@@ -435,40 +571,96 @@ func (gdm *GracefulDegradationManager) requireManualIntervention(dependencyName,
 	return fmt.Errorf("dependency %s unavailable, manual intervention required for operation %s", dependencyName, operation)
 }
 
-// processQueuedOperations attempts to execute queued operations when dependencies recover
+// processQueuedOperations re-dispatches queued operations whose dependency has
+// recovered. Handlers run without the lock held so they can use the manager
 func (gdm *GracefulDegradationManager) processQueuedOperations() {
-	if len(gdm.queuedOperations) == 0 {
+	gdm.mutex.Lock()
+	var ready []QueuedOperation
+	var waiting []QueuedOperation
+	for _, op := range gdm.queuedOperations {
+		dep, exists := gdm.dependencies[op.DependencyName]
+		if exists && dep.Status == StatusAvailable && gdm.operationHandlers[op.Operation] != nil {
+			ready = append(ready, op)
+		} else {
+			waiting = append(waiting, op)
+		}
+	}
+	gdm.queuedOperations = waiting
+	handlers := make(map[string]OperationHandler, len(gdm.operationHandlers))
+	for operation, handler := range gdm.operationHandlers {
+		handlers[operation] = handler
+	}
+	gdm.mutex.Unlock()
+	
+	if len(ready) == 0 {
 		return
 	}
 	
-	var remainingOps []QueuedOperation
-	processedCount := 0
+	var retry []QueuedOperation
+	succeeded, failed := 0, 0
 	
-	for _, op := range gdm.queuedOperations {
-		if gdm.IsAvailable(op.DependencyName) {
-			gdm.logger.WithField("operation_id", op.ID).
-				WithField("dependency", op.DependencyName).
-				Info("Dependency recovered, processing queued operation")
-			
-			// Here you would trigger the actual operation
-			// For now, we just log that it would be processed
-			processedCount++
-		} else {
-			remainingOps = append(remainingOps, op)
+	for _, op := range ready {
+		op.Attempts++
+		gdm.logger.WithField("operation_id", op.ID).
+			WithField("dependency", op.DependencyName).
+			WithField("operation", op.Operation).
+			WithField("task_id", op.TaskID).
+			WithField("attempt", op.Attempts).
+			Info("Dependency recovered, executing queued operation")
+		
+		if err := handlers[op.Operation](op); err != nil {
+			op.LastError = err.Error()
+			if op.Attempts >= maxOperationAttempts {
+				failed++
+				gdm.logger.WithField("operation_id", op.ID).
+					WithField("task_id", op.TaskID).
+					WithField("attempts", op.Attempts).
+					WithError(err).
+					Error("Queued operation failed permanently, dropping it")
+				gdm.forgetOperation(op.ID)
+			} else {
+				retry = append(retry, op)
+				gdm.persistOperation(op)
+				gdm.logger.WithField("operation_id", op.ID).
+					WithField("task_id", op.TaskID).
+					WithError(err).
+					Warn("Queued operation failed, will retry")
+			}
+			continue
 		}
+		gdm.forgetOperation(op.ID)
+		succeeded++
 	}
 	
-	gdm.queuedOperations = remainingOps
+	gdm.mutex.Lock()
+	gdm.queuedOperations = append(gdm.queuedOperations, retry...)
+	gdm.operationsRun += succeeded
+	gdm.operationsFailed += failed
+	remaining := len(gdm.queuedOperations)
+	gdm.mutex.Unlock()
+	
+	gdm.logger.WithField("succeeded", succeeded).
+		WithField("failed", failed).
+		WithField("retrying", len(retry)).
+		WithField("remaining_count", remaining).
+		Info("Processed queued operations after dependency recovery")
+}
+
+// GetQueuedOperations returns a copy of the operations waiting for recovery
+func (gdm *GracefulDegradationManager) GetQueuedOperations() []QueuedOperation {
+	gdm.mutex.RLock()
+	defer gdm.mutex.RUnlock()
 	
-	if processedCount > 0 {
-		gdm.logger.WithField("processed_count", processedCount).
-			WithField("remaining_count", len(remainingOps)).
-			Info("Processed queued operations after dependency recovery")
-	}
+	ops := make([]QueuedOperation, len(gdm.queuedOperations))
+	copy(ops, gdm.queuedOperations)
+	return ops
 }
 
 // cleanupExpiredOperations removes operations that have exceeded their max wait time
 func (gdm *GracefulDegradationManager) cleanupExpiredOperations() {
+	gdm.mutex.Lock()
+	defer gdm.mutex.Unlock()
+	
 	if len(gdm.queuedOperations) == 0 {
 		return
 	}
@@ -482,6 +674,7 @@ func (gdm *GracefulDegradationManager) cleanupExpiredOperations() {
 				WithField("dependency", op.DependencyName).
 				WithField("queued_at", op.QueuedAt).
 				Warn("Removing expired queued operation")
+			gdm.forgetOperation(op.ID)
 			expiredCount++
 		} else {
 			validOps = append(validOps, op)
@@ -541,11 +734,15 @@ func (gdm *GracefulDegradationManager) GetDependencyReport() map[string]interfac
 			"error_message":     dep.ErrorMessage,
 			"consecutive_fails": dep.ConsecutiveFails,
 			"fallback_mode":     gdm.fallbackModes[name],
+			"recovery_actions":  len(gdm.recoveryActions[name]),
+			"last_recovery":     gdm.lastRecovery[name],
 		}
 	}
 	
 	report["dependencies"] = dependencies
 	report["queued_operations"] = len(gdm.queuedOperations)
+	report["operations_executed"] = gdm.operationsRun
+	report["operations_failed"] = gdm.operationsFailed
 	report["report_time"] = time.Now()
 	
 	healthy, issues := gdm.GetSystemHealth()
@@ -555,6 +752,14 @@ func (gdm *GracefulDegradationManager) GetDependencyReport() map[string]interfac
 	return report
 }
 
+// stringParameter returns a string operation parameter, or "" if it is missing
+func stringParameter(parameters map[string]interface{}, key string) string {
+	if value, ok := parameters[key].(string); ok {
+		return value
+	}
+	return ""
+}
+
 // generateOperationID creates a unique ID for queued operations
 func generateOperationID() string {
 	return fmt.Sprintf("op_%d", time.Now().UnixNano())
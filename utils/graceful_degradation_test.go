@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newTestDegradationManager returns a manager that logs nowhere
+func newTestDegradationManager() *GracefulDegradationManager {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewGracefulDegradationManager(&Logger{Logger: logger})
+}
+
+func TestRecoveryDoesNotBlockReaders(t *testing.T) {
+	gdm := newTestDegradationManager()
+	dir := filepath.Join(t.TempDir(), "missing")
+	gdm.RegisterDependency(dir, "directory", time.Minute, FallbackQueue)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	gdm.RegisterRecoveryAction(dir, "slow_restart", func(ctx context.Context) error {
+		close(started)
+		<-release
+		return RecreateDirectory(dir)(ctx)
+	})
+
+	checked := make(chan struct{})
+	go func() {
+		defer close(checked)
+		gdm.checkAllDependencies()
+	}()
+	<-started
+
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		gdm.IsAvailable(dir)
+		gdm.GetSystemHealth()
+	}()
+	select {
+	case <-read:
+	case <-time.After(5 * time.Second):
+		t.Fatal("readers blocked while a recovery action runs")
+	}
+
+	close(release)
+	<-checked
+	if !gdm.IsAvailable(dir) {
+		t.Fatal("dependency not available after its recovery action succeeded")
+	}
+}
+
+// memoryOperationStore is an OperationStore kept in a map
+type memoryOperationStore map[string]QueuedOperation
+
+func (s memoryOperationStore) SaveQueuedOperation(op QueuedOperation) error {
+	s[op.ID] = op
+	return nil
+}
+
+func (s memoryOperationStore) DeleteQueuedOperation(id string) error {
+	delete(s, id)
+	return nil
+}
+
+func (s memoryOperationStore) LoadQueuedOperations(dependencyName string) ([]QueuedOperation, error) {
+	var ops []QueuedOperation
+	for _, op := range s {
+		if op.DependencyName == dependencyName {
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+func TestQueuedOperationSurvivesRestart(t *testing.T) {
+	store := memoryOperationStore{}
+	dir := filepath.Join(t.TempDir(), "pass")
+
+	// The first run queues an operation while the directory is missing
+	first := newTestDegradationManager()
+	first.RegisterDependency(dir, "directory", time.Minute, FallbackQueue)
+	first.SetOperationStore(store)
+	first.checkAllDependencies()
+	if err := first.HandleUnavailableDependency(dir, "pipeline_stage", map[string]interface{}{"stage": "conversion"}); err == nil {
+		t.Fatal("operation ran while its dependency was unavailable")
+	}
+	if len(store) != 1 {
+		t.Fatalf("persisted operations = %d, want 1", len(store))
+	}
+
+	// After a restart the operation is restored and runs once the directory is back
+	second := newTestDegradationManager()
+	second.RegisterDependency(dir, "directory", time.Minute, FallbackQueue)
+	second.SetOperationStore(store)
+	restored := second.GetQueuedOperations()
+	if len(restored) != 1 || restored[0].Stage != "conversion" {
+		t.Fatalf("restored operations = %+v", restored)
+	}
+
+	var dispatched []QueuedOperation
+	second.RegisterOperationHandler("pipeline_stage", func(op QueuedOperation) error {
+		dispatched = append(dispatched, op)
+		return nil
+	})
+	if err := RecreateDirectory(dir)(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	second.checkAllDependencies()
+	second.processQueuedOperations()
+
+	if len(dispatched) != 1 || dispatched[0].ID != restored[0].ID {
+		t.Fatalf("dispatched operations = %+v, want %s", dispatched, restored[0].ID)
+	}
+	if len(store) != 0 || len(second.GetQueuedOperations()) != 0 {
+		t.Fatalf("operation still queued after it ran: %+v", store)
+	}
+}
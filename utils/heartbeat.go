@@ -0,0 +1,18 @@
+package utils
+
+import "context"
+
+type heartbeatKey struct{}
+
+// WithHeartbeat returns a context carrying a heartbeat callback for a supervised component
+func WithHeartbeat(ctx context.Context, beat func()) context.Context {
+	return context.WithValue(ctx, heartbeatKey{}, beat)
+}
+
+// Heartbeat signals liveness to the component's supervisor; it is a no-op
+// when the component is not supervised
+func Heartbeat(ctx context.Context) {
+	if beat, ok := ctx.Value(heartbeatKey{}).(func()); ok {
+		beat()
+	}
+}
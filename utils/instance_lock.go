@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errLockHeld is returned by lockFile when another process holds the lock
+var errLockHeld = errors.New("lock held by another process")
+
+// InstanceLock is an exclusive lock on a file, held until Release or until
+// the process exits, so a crashed instance never leaves a stale lock behind
+type InstanceLock struct {
+	path string
+	file *os.File
+}
+
+// AcquireInstanceLock takes the lock at path, which records the holder's PID,
+// host and start time. It fails with the holder's details when another
+// process already holds it
+func AcquireInstanceLock(path string) (*InstanceLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := lockFile(file); err != nil {
+		holder := "unknown process"
+		if content, readErr := os.ReadFile(path); readErr == nil && len(strings.TrimSpace(string(content))) > 0 {
+			holder = strings.Join(strings.Fields(string(content)), " ")
+		}
+		file.Close()
+		if errors.Is(err, errLockHeld) {
+			return nil, fmt.Errorf("another instance is already running (%s holds %s); stop it first, or set HA_ENABLED=true to run a standby", holder, path)
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	hostname, _ := os.Hostname()
+	record := fmt.Sprintf("pid=%d host=%s started=%s\n", os.Getpid(), hostname, time.Now().Format(time.RFC3339))
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(record), 0)
+		file.Sync()
+	}
+
+	return &InstanceLock{path: path, file: file}, nil
+}
+
+// Path returns the lock file
+func (l *InstanceLock) Path() string {
+	return l.path
+}
+
+// Release clears the holder record and releases the lock. The file is kept:
+// removing it could let two later instances lock different files
+func (l *InstanceLock) Release() error {
+	l.file.Truncate(0)
+	if err := unlockFile(l.file); err != nil {
+		l.file.Close()
+		return fmt.Errorf("failed to unlock %s: %w", l.path, err)
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// RandomSource supplies the randomness for retry jitter. Implementations must
+// be safe for concurrent use
+type RandomSource interface {
+	// Float64 returns a number in [0.0, 1.0)
+	Float64() float64
+}
+
+// runtimeRandomSource uses the runtime's randomly seeded generator, which
+// gives every goroutine independent values
+type runtimeRandomSource struct{}
+
+func (runtimeRandomSource) Float64() float64 {
+	return rand.Float64()
+}
+
+// DefaultRandomSource is the source retry services use unless another is set
+var DefaultRandomSource RandomSource = runtimeRandomSource{}
+
+// seededRandomSource is a deterministic generator guarded by a mutex
+type seededRandomSource struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewSeededRandomSource returns a deterministic source, so the same seed
+// always yields the same jitter sequence
+func NewSeededRandomSource(seed uint64) RandomSource {
+	return &seededRandomSource{rng: rand.New(rand.NewPCG(seed, seed))}
+}
+
+func (s *seededRandomSource) Float64() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Float64()
+}
+
+// applyJitter spreads delay uniformly over ±jitterFactor/2 of its length
+func applyJitter(delay time.Duration, jitterFactor float64, source RandomSource) time.Duration {
+	if jitterFactor <= 0 || jitterFactor > 1 {
+		return delay // Invalid jitter factor, return original delay
+	}
+	if source == nil {
+		source = DefaultRandomSource
+	}
+
+	jitterRange := float64(delay) * jitterFactor
+	jitter := (source.Float64() - 0.5) * jitterRange
+
+	newDelay := time.Duration(float64(delay) + jitter)
+	if newDelay < 0 {
+		newDelay = delay / 2
+	}
+	return newDelay
+}
+
+// Jitter spreads delay uniformly over ±jitterFactor/2 of its length using
+// the default source, e.g. to keep periodic loops of several instances apart
+func Jitter(delay time.Duration, jitterFactor float64) time.Duration {
+	return applyJitter(delay, jitterFactor, nil)
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeededRandomSource(t *testing.T) {
+	// The sequences are pinned: a change of generator would make FAULT_SEED
+	// runs and seeded retry tests irreproducible across versions
+	tests := []struct {
+		seed uint64
+		want []float64
+	}{
+		{1, []float64{0.3402859786606234, 0.9099579380225021, 0.8287848564104272}},
+		{42, []float64{0.3050145593494096, 0.3861315708136316, 0.8620846508736288}},
+	}
+	for _, tt := range tests {
+		source := NewSeededRandomSource(tt.seed)
+		for i, want := range tt.want {
+			if got := source.Float64(); got != want {
+				t.Errorf("seed %d, value %d = %v, want %v", tt.seed, i, got, want)
+			}
+		}
+	}
+}
+
+func TestApplyJitter(t *testing.T) {
+	tests := []struct {
+		name   string
+		delay  time.Duration
+		factor float64
+		seed   uint64
+		want   []time.Duration // Pinned output of the seeded source, when set
+	}{
+		{name: "pinned", delay: 10 * time.Second, factor: 0.2, seed: 7,
+			want: []time.Duration{9362817714, 10722046133, 9970876352}},
+		{name: "small factor", delay: time.Second, factor: 0.1, seed: 1},
+		{name: "full factor", delay: time.Minute, factor: 1, seed: 42},
+		{name: "short delay", delay: 3 * time.Millisecond, factor: 0.5, seed: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := NewSeededRandomSource(tt.seed)
+			spread := time.Duration(float64(tt.delay) * tt.factor / 2)
+			low, high := tt.delay-spread, tt.delay+spread
+
+			for i := 0; i < 1000; i++ {
+				got := applyJitter(tt.delay, tt.factor, source)
+				if i < len(tt.want) && got != tt.want[i] {
+					t.Errorf("value %d = %v, want %v", i, got, tt.want[i])
+				}
+				if got < low || got > high {
+					t.Fatalf("value %d = %v, outside [%v, %v]", i, got, low, high)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyJitterInvalidFactor(t *testing.T) {
+	for _, factor := range []float64{0, -0.1, 1.5} {
+		if got := applyJitter(time.Second, factor, NewSeededRandomSource(1)); got != time.Second {
+			t.Errorf("factor %v: delay %v, want it unchanged", factor, got)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LedgerRow is one completed task in the completion ledger
+type LedgerRow struct {
+	CompletedAt time.Time
+	TaskID      string
+	ShortID     string
+	FileName    string
+	SizeBytes   int64
+	Credentials int           // Found by the conversion pass the file went through
+	PassTasks   int           // Tasks sharing that pass, 0 when it is unknown
+	Duration    time.Duration // From upload to completion
+}
+
+// LedgerHeader names the ledger columns in the order of LedgerRow.Fields
+var LedgerHeader = []string{"date", "task_id", "short_id", "file", "size_bytes", "credentials", "pass_tasks", "duration_seconds"}
+
+// Fields renders the row as ledger cells. Text that a spreadsheet would
+// evaluate as a formula is prefixed with a quote
+func (r LedgerRow) Fields() []string {
+	return []string{
+		r.CompletedAt.Format("2006-01-02 15:04:05"),
+		r.TaskID,
+		r.ShortID,
+		spreadsheetSafe(r.FileName),
+		strconv.FormatInt(r.SizeBytes, 10),
+		strconv.Itoa(r.Credentials),
+		strconv.Itoa(r.PassTasks),
+		strconv.FormatInt(int64(r.Duration.Seconds()), 10),
+	}
+}
+
+// spreadsheetSafe stops file names such as "=HYPERLINK(...).zip" from being
+// run as formulas when the ledger is opened in a spreadsheet
+func spreadsheetSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// LedgerSink appends rows to a completion ledger
+type LedgerSink interface {
+	// Name identifies the sink; delivery is tracked per name
+	Name() string
+	Append(rows []LedgerRow) error
+}
+
+// NewLedgerSinks returns the ledgers enabled in the config
+func NewLedgerSinks(config *Config) []LedgerSink {
+	var sinks []LedgerSink
+	if config.LedgerCSVPath != "" {
+		sinks = append(sinks, NewCSVLedger(config.LedgerCSVPath))
+	}
+	if config.LedgerWebhookURL != "" {
+		sinks = append(sinks, NewWebhookLedger(config.LedgerWebhookURL))
+	}
+	return sinks
+}
+
+// CSVLedger appends rows to a CSV file, writing the header when the file is new
+type CSVLedger struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewCSVLedger creates a ledger writing to path
+func NewCSVLedger(path string) *CSVLedger {
+	return &CSVLedger{path: path}
+}
+
+// Name implements LedgerSink
+func (l *CSVLedger) Name() string {
+	return "csv"
+}
+
+// Append implements LedgerSink
+func (l *CSVLedger) Append(rows []LedgerRow) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat ledger: %w", err)
+	}
+
+	writer := csv.NewWriter(file)
+	if info.Size() == 0 {
+		writer.Write(LedgerHeader)
+	}
+	for _, row := range rows {
+		writer.Write(row.Fields())
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+	return file.Sync()
+}
+
+// WebhookLedger posts rows as JSON to a URL, such as a Google Apps Script
+// web app that appends them to a sheet
+type WebhookLedger struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookLedger creates a ledger posting to url
+func NewWebhookLedger(url string) *WebhookLedger {
+	return &WebhookLedger{url: url, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Name implements LedgerSink
+func (l *WebhookLedger) Name() string {
+	return "webhook"
+}
+
+// ledgerPayload is the body posted by WebhookLedger
+type ledgerPayload struct {
+	Header []string   `json:"header"`
+	Rows   [][]string `json:"rows"`
+}
+
+// Append implements LedgerSink; any non-2xx response is an error
+func (l *WebhookLedger) Append(rows []LedgerRow) error {
+	payload := ledgerPayload{Header: LedgerHeader}
+	for _, row := range rows {
+		payload.Rows = append(payload.Rows, row.Fields())
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode ledger rows: %w", err)
+	}
+
+	resp, err := l.client.Post(l.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post ledger rows: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("ledger webhook returned %s", resp.Status)
+	}
+	return nil
+}
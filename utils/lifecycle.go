@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Shutdowner is a component with background routines. Shutdown stops them and
+// returns once they have exited, or with ctx's error when they don't in time
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// ShutdownFunc adapts a function to Shutdowner
+type ShutdownFunc func(ctx context.Context) error
+
+// Shutdown calls f
+func (f ShutdownFunc) Shutdown(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Routines runs a component's background goroutines under one context, so
+// they can all be stopped and waited for
+type Routines struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mutex   sync.Mutex
+	stopped bool
+}
+
+// NewRoutines creates routines that also stop when parent is cancelled
+func NewRoutines(parent context.Context) *Routines {
+	ctx, cancel := context.WithCancel(parent)
+	return &Routines{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in a goroutine with the routines' context, registered in the
+// goroutine registry under name. A loop calls Heartbeat on the context at
+// least every staleAfter, or passes 0 when it has no regular beat. Once Stop
+// was called nothing is started and Go returns false
+func (r *Routines) Go(name string, staleAfter time.Duration, fn func(ctx context.Context)) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.stopped {
+		return false
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ctx, registration := Goroutines.Track(r.ctx, name, staleAfter)
+		defer registration.Done()
+		fn(ctx)
+	}()
+	return true
+}
+
+// Context is cancelled once the routines are stopped
+func (r *Routines) Context() context.Context {
+	return r.ctx
+}
+
+// Stop cancels the routines and waits until they have returned or ctx is done
+func (r *Routines) Stop(ctx context.Context) error {
+	r.mutex.Lock()
+	r.stopped = true
+	r.mutex.Unlock()
+
+	r.cancel()
+	return WaitGroupContext(ctx, &r.wg)
+}
+
+// WaitGroupContext waits for wg, giving up with ctx's error when ctx is done first
+func WaitGroupContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ShutdownSequence shuts components down one at a time in the order they
+// were added, so each can still use the ones after it while stopping
+type ShutdownSequence struct {
+	logger *Logger
+	steps  []shutdownStep
+}
+
+type shutdownStep struct {
+	name      string
+	timeout   time.Duration
+	component Shutdowner
+}
+
+// NewShutdownSequence creates an empty shutdown sequence
+func NewShutdownSequence(logger *Logger) *ShutdownSequence {
+	return &ShutdownSequence{logger: logger}
+}
+
+// Add appends a component to the sequence; it gets up to timeout to shut down
+func (s *ShutdownSequence) Add(name string, timeout time.Duration, component Shutdowner) {
+	s.steps = append(s.steps, shutdownStep{name: name, timeout: timeout, component: component})
+}
+
+// Shutdown stops every component in order. A component that fails or runs
+// out of time is reported and the sequence moves on to the next one
+func (s *ShutdownSequence) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, step := range s.steps {
+		startTime := time.Now()
+		stepCtx, cancel := context.WithTimeout(ctx, step.timeout)
+		err := step.component.Shutdown(stepCtx)
+		cancel()
+
+		logger := s.logger.WithField("component", step.name).
+			WithField("duration_ms", time.Since(startTime).Milliseconds())
+		if err != nil {
+			logger.WithError(err).Warn("Component did not shut down cleanly")
+			errs = append(errs, fmt.Errorf("%s: %w", step.name, err))
+			continue
+		}
+		logger.Debug("Component shut down")
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Maintenance is the process-wide maintenance state: set while work runs
+// that host tooling should leave alone, such as a database backup. Pausing
+// operations, such as a restore, also stop the bot taking on new work
+var Maintenance = &MaintenanceState{}
+
+// MaintenanceState tracks the maintenance operations in progress; the
+// process is in maintenance while any of them runs
+type MaintenanceState struct {
+	mutex      sync.Mutex
+	next       int
+	operations map[int]maintenanceOperation
+}
+
+type maintenanceOperation struct {
+	reason string
+	since  time.Time
+	pause  bool
+}
+
+// MaintenanceStatus describes the maintenance operations in progress
+type MaintenanceStatus struct {
+	Active  bool      `json:"active"`
+	Reasons []string  `json:"reasons,omitempty"`
+	Since   time.Time `json:"since,omitempty"`  // Start of the oldest operation
+	Paused  bool      `json:"paused,omitempty"` // Downloads and processing are held until it ends
+}
+
+// Begin records an operation in progress until the returned func is called
+func (m *MaintenanceState) Begin(reason string) (end func()) {
+	return m.begin(reason, false)
+}
+
+// Pause records an operation that needs the pipeline to stand still, such as
+// a database restore; downloads and processing cycles don't start until the
+// returned func is called
+func (m *MaintenanceState) Pause(reason string) (end func()) {
+	return m.begin(reason, true)
+}
+
+// Paused reports whether an operation holds the pipeline
+func (m *MaintenanceState) Paused() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, op := range m.operations {
+		if op.pause {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MaintenanceState) begin(reason string, pause bool) (end func()) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.operations == nil {
+		m.operations = make(map[int]maintenanceOperation)
+	}
+	id := m.next
+	m.next++
+	m.operations[id] = maintenanceOperation{reason: reason, since: time.Now(), pause: pause}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mutex.Lock()
+			delete(m.operations, id)
+			m.mutex.Unlock()
+		})
+	}
+}
+
+// Status returns the operations in progress, oldest first
+func (m *MaintenanceState) Status() MaintenanceStatus {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	operations := make([]maintenanceOperation, 0, len(m.operations))
+	for _, op := range m.operations {
+		operations = append(operations, op)
+	}
+	sort.Slice(operations, func(i, j int) bool { return operations[i].since.Before(operations[j].since) })
+
+	status := MaintenanceStatus{Active: len(operations) > 0}
+	for _, op := range operations {
+		status.Reasons = append(status.Reasons, op.reason)
+		status.Paused = status.Paused || op.pause
+	}
+	if status.Active {
+		status.Since = operations[0].since
+	}
+	return status
+}
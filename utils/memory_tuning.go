@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+)
+
+// MemoryLimitAuto sets the memory limit from the container's cgroup limit
+const MemoryLimitAuto int64 = -1
+
+// autoMemoryLimitFraction leaves headroom below the cgroup limit for memory
+// the Go runtime does not account for, e.g. cgo and subprocess pipes
+const autoMemoryLimitFraction = 0.9
+
+// cgroupMemoryLimitFiles are the cgroup v2 and v1 memory limit files
+var cgroupMemoryLimitFiles = []string{
+	"/sys/fs/cgroup/memory.max",
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+}
+
+// ballast is a never-used allocation that raises the heap size the garbage
+// collector targets; it is only referenced to keep it alive
+var ballast []byte
+
+// MemoryTuning is the effective garbage collector configuration
+type MemoryTuning struct {
+	GCPercent    int   // -1 when the GC only runs to stay under the memory limit
+	MemoryLimit  int64 // math.MaxInt64 when unlimited
+	BallastBytes int64
+}
+
+// ApplyMemoryTuning applies the GC percent, memory limit and ballast from the
+// config. Zero values keep the runtime defaults, including GOGC and GOMEMLIMIT
+// from the environment. If the container limit can't be detected for
+// MEMORY_LIMIT=auto the other settings are still applied
+func ApplyMemoryTuning(config *Config) (*MemoryTuning, error) {
+	if config.GCPercent != 0 {
+		debug.SetGCPercent(config.GCPercent)
+	}
+
+	var err error
+	limit := config.MemoryLimit
+	if limit == MemoryLimitAuto {
+		var cgroupLimit int64
+		cgroupLimit, err = cgroupMemoryLimit()
+		if err != nil {
+			err = fmt.Errorf("failed to detect container memory limit: %w", err)
+		}
+		limit = int64(float64(cgroupLimit) * autoMemoryLimitFraction)
+	}
+	if limit > 0 {
+		debug.SetMemoryLimit(limit)
+	}
+
+	if config.MemoryBallast > 0 && int64(len(ballast)) != config.MemoryBallast {
+		ballast = make([]byte, config.MemoryBallast)
+	}
+
+	return CurrentMemoryTuning(), err
+}
+
+// CurrentMemoryTuning reads the GC settings in effect
+func CurrentMemoryTuning() *MemoryTuning {
+	samples := []metrics.Sample{{Name: "/gc/gogc:percent"}}
+	metrics.Read(samples)
+
+	// GOGC=off is reported as the maximum uint64, i.e. -1
+	return &MemoryTuning{
+		GCPercent:    int(int64(samples[0].Value.Uint64())),
+		MemoryLimit:  CurrentMemoryLimit(),
+		BallastBytes: int64(len(ballast)),
+	}
+}
+
+// CurrentMemoryLimit returns the runtime memory limit, math.MaxInt64 when unset
+func CurrentMemoryLimit() int64 {
+	return debug.SetMemoryLimit(-1)
+}
+
+// String describes the settings for logs and status messages
+func (mt *MemoryTuning) String() string {
+	gc := "off"
+	if mt.GCPercent >= 0 {
+		gc = strconv.Itoa(mt.GCPercent)
+	}
+	limit := "none"
+	if mt.MemoryLimit != math.MaxInt64 {
+		limit = formatByteSize(mt.MemoryLimit)
+	}
+	return fmt.Sprintf("GOGC=%s GOMEMLIMIT=%s ballast=%s", gc, limit, formatByteSize(mt.BallastBytes))
+}
+
+// cgroupMemoryLimit reads the memory limit of the cgroup the bot runs in
+func cgroupMemoryLimit() (int64, error) {
+	for _, path := range cgroupMemoryLimitFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		value := strings.TrimSpace(string(data))
+		if value == "max" {
+			return 0, fmt.Errorf("cgroup memory is unlimited (%s)", path)
+		}
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid cgroup memory limit in %s: %w", path, err)
+		}
+		// cgroup v1 reports a huge page-aligned number when unlimited
+		if limit <= 0 || limit >= 1<<62 {
+			return 0, fmt.Errorf("cgroup memory is unlimited (%s)", path)
+		}
+		return limit, nil
+	}
+	return 0, fmt.Errorf("no cgroup memory limit found")
+}
+
+// parseGCPercent parses a GOGC-style value: a percentage or "off"
+func parseGCPercent(s string) (int, error) {
+	if strings.EqualFold(strings.TrimSpace(s), "off") {
+		return -1, nil
+	}
+	percent, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || percent <= 0 {
+		return 0, fmt.Errorf("must be a positive percentage or off")
+	}
+	return percent, nil
+}
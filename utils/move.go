@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// MoveOptions controls how files are moved between directories and filesystems
+type MoveOptions struct {
+	Tuning   IOTuning
+	Verify   bool                      // Compare SHA-256 of source and copy before removing the source
+	Progress func(copied, total int64) // Called periodically during cross-filesystem copies
+}
+
+// DefaultMoveOptions returns verified moves with the default I/O tuning
+func DefaultMoveOptions() MoveOptions {
+	return MoveOptions{
+		Tuning: DefaultIOTuning(),
+		Verify: true,
+	}
+}
+
+// MoveFile moves src to dst, falling back to copy+fsync+rename+remove when
+// the two paths are on different filesystems (rename fails with EXDEV)
+func MoveFile(src, dst string, opts MoveOptions) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	if !IsCrossDeviceError(err) {
+		return err
+	}
+
+	return moveAcrossFilesystems(src, dst, opts)
+}
+
+// IsCrossDeviceError reports whether err is a rename failure across mount points
+func IsCrossDeviceError(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
+
+func moveAcrossFilesystems(src, dst string, opts MoveOptions) error {
+	in, err := OpenForRead(src, opts.Tuning)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat source file: %w", err)
+	}
+
+	// Copy into a .partial file next to the destination so the final rename is atomic
+	out, err := CreateAtomic(dst, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	var writer io.Writer = out
+	if opts.Progress != nil {
+		writer = &progressWriter{w: out, total: info.Size(), progress: opts.Progress}
+	}
+
+	sourceHash := sha256.New()
+	if opts.Verify {
+		writer = io.MultiWriter(writer, sourceHash)
+	}
+
+	copied, err := CopyTuned(writer, in, opts.Tuning)
+	if err != nil {
+		out.Abort()
+		return fmt.Errorf("failed to copy file contents: %w", err)
+	}
+	if copied != info.Size() {
+		out.Abort()
+		return fmt.Errorf("size mismatch after copy: expected %d, got %d", info.Size(), copied)
+	}
+
+	if opts.Verify {
+		if err := out.Sync(); err != nil {
+			out.Abort()
+			return fmt.Errorf("failed to sync destination file: %w", err)
+		}
+		copyHash, _, err := HashFileTuned(PartialPath(dst), opts.Tuning)
+		if err != nil {
+			out.Abort()
+			return fmt.Errorf("failed to verify copied file: %w", err)
+		}
+		if copyHash != fmt.Sprintf("%x", sourceHash.Sum(nil)) {
+			out.Abort()
+			return fmt.Errorf("checksum mismatch after copying %s", src)
+		}
+	}
+
+	if err := out.Commit(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(src); err != nil {
+		return fmt.Errorf("file copied to %s but failed to remove source: %w", dst, err)
+	}
+
+	return nil
+}
+
+// syncDir flushes directory metadata so a completed rename survives a crash
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// progressWriter reports copy progress roughly every 1% (and at least every 64MB)
+type progressWriter struct {
+	w        io.Writer
+	total    int64
+	written  int64
+	reported int64
+	progress func(copied, total int64)
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.written += int64(n)
+
+	step := pw.total / 100
+	if step <= 0 || step > 64*1024*1024 {
+		step = 64 * 1024 * 1024
+	}
+	if pw.written-pw.reported >= step || pw.written == pw.total {
+		pw.reported = pw.written
+		pw.progress(pw.written, pw.total)
+	}
+	return n, err
+}
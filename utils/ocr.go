@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// maxOCROutput caps the text kept from one image; screenshots with more
+// text than this are not credential screens
+const maxOCROutput = 1 << 20
+
+// OCREngine recognizes text in images by running the tesseract CLI
+type OCREngine struct {
+	path      string
+	languages string
+	timeout   time.Duration
+}
+
+// NewOCREngine creates an engine from the OCR settings of the config
+func NewOCREngine(config *Config) *OCREngine {
+	return &OCREngine{
+		path:      config.OCRTesseractPath,
+		languages: config.OCRLanguages,
+		timeout:   config.OCRTimeout,
+	}
+}
+
+// Available checks that the tesseract executable can be found
+func (e *OCREngine) Available() error {
+	if _, err := exec.LookPath(e.path); err != nil {
+		return fmt.Errorf("tesseract not found at %s: %w", e.path, err)
+	}
+	return nil
+}
+
+// Recognize returns the text tesseract reads from an image. Tesseract is
+// limited to one thread so OCR stays within the bot's CPU budget
+func (e *OCREngine) Recognize(ctx context.Context, imagePath string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, e.path, imagePath, "stdout", "-l", e.languages)
+	cmd.Env = append(os.Environ(), "OMP_THREAD_LIMIT=1")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &limitedBuffer{buf: &stdout, limit: maxOCROutput}
+	cmd.Stderr = &limitedBuffer{buf: &stderr, limit: 4096}
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("tesseract timed out after %s", e.timeout)
+		}
+		return "", fmt.Errorf("tesseract failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// limitedBuffer keeps the first limit bytes written and discards the rest
+type limitedBuffer struct {
+	buf   *bytes.Buffer
+	limit int
+}
+
+func (l *limitedBuffer) Write(p []byte) (int, error) {
+	if room := l.limit - l.buf.Len(); room > 0 {
+		if len(p) > room {
+			l.buf.Write(p[:room])
+		} else {
+			l.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
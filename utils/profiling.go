@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// MaxCPUProfileDuration bounds how long a captured CPU profile runs
+const MaxCPUProfileDuration = 2 * time.Minute
+
+// ErrCPUProfileActive is returned when a CPU profile is already running,
+// e.g. one requested through the HTTP pprof endpoint
+var ErrCPUProfileActive = errors.New("a CPU profile is already being captured")
+
+// CaptureProfiles writes heap, goroutine and, when cpuDuration is positive,
+// CPU profiles into dir and returns their paths. Profiles that were written
+// are returned even when a later one fails
+func CaptureProfiles(dir string, cpuDuration time.Duration) ([]string, error) {
+	if cpuDuration > MaxCPUProfileDuration {
+		cpuDuration = MaxCPUProfileDuration
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create profile directory: %w", err)
+	}
+
+	stamp := time.Now().Format("20060102-150405")
+	var paths []string
+
+	// Heap statistics are only as fresh as the last GC
+	runtime.GC()
+	for _, name := range []string{"heap", "goroutine"} {
+		path := filepath.Join(dir, fmt.Sprintf("%s-%s.pprof", name, stamp))
+		if err := writeProfile(path, func(f *os.File) error {
+			return pprof.Lookup(name).WriteTo(f, 0)
+		}); err != nil {
+			return paths, fmt.Errorf("failed to write %s profile: %w", name, err)
+		}
+		paths = append(paths, path)
+	}
+
+	if cpuDuration <= 0 {
+		return paths, nil
+	}
+	path := filepath.Join(dir, fmt.Sprintf("cpu-%s.pprof", stamp))
+	if err := writeProfile(path, func(f *os.File) error {
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return ErrCPUProfileActive
+		}
+		time.Sleep(cpuDuration)
+		pprof.StopCPUProfile()
+		return nil
+	}); err != nil {
+		return paths, fmt.Errorf("failed to write CPU profile: %w", err)
+	}
+	return append(paths, path), nil
+}
+
+// writeProfile creates path and fills it with write, removing it on failure
+func writeProfile(path string, write func(*os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
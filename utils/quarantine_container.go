@@ -0,0 +1,295 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// QuarantineContainerExt is appended to quarantined files so they are never
+// opened by the handler of their original type
+const QuarantineContainerExt = ".quarantine"
+
+// quarantineMagic starts every quarantine container
+var quarantineMagic = []byte("TABQUAR\x01")
+
+const (
+	quarantineFormatVersion = 1
+	quarantineSaltSize      = 32
+	quarantineChunkSize     = 64 * 1024
+	quarantineMaxMetadata   = 1024 * 1024
+)
+
+// QuarantineMetadata describes a quarantined file. It is stored unencrypted
+// in the container header so it can be inspected without the key, and is
+// authenticated together with the encrypted content
+type QuarantineMetadata struct {
+	Format        int       `json:"format"`
+	Cipher        string    `json:"cipher"`
+	TaskID        string    `json:"task_id"`
+	FileName      string    `json:"file_name"`
+	FileHash      string    `json:"file_hash,omitempty"`
+	FileSize      int64     `json:"file_size"`
+	UserID        int64     `json:"user_id"`
+	SourcePath    string    `json:"source_path"`
+	ThreatLevel   string    `json:"threat_level,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	Findings      []string  `json:"findings,omitempty"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// LoadQuarantineKey reads the hex-encoded 256-bit quarantine key at path,
+// generating and saving a new one (mode 0600) if the file does not exist
+func LoadQuarantineKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate quarantine key: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create quarantine key directory: %w", err)
+		}
+		if err := WriteFileAtomic(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+			return nil, fmt.Errorf("failed to save quarantine key: %w", err)
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quarantine key: %w", err)
+	}
+
+	key, err := ParseQuarantineKey(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("quarantine key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParseQuarantineKey decodes a hex-encoded 256-bit quarantine key
+func ParseQuarantineKey(v string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(v))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("must be 64 hex characters")
+	}
+	return key, nil
+}
+
+// WriteQuarantineContainer encrypts src into a quarantine container at dst.
+// The content is split into chunks sealed with AES-256-GCM under a key
+// derived from key and a random per-container salt; each chunk is bound to
+// its position, the final chunk and the header, so truncation, reordering
+// and metadata edits are all detected on extraction
+func WriteQuarantineContainer(dst string, src io.Reader, key []byte, meta QuarantineMetadata) error {
+	meta.Format = quarantineFormatVersion
+	meta.Cipher = "AES-256-GCM"
+
+	salt := make([]byte, quarantineSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate container salt: %w", err)
+	}
+	header, err := encodeQuarantineHeader(meta, salt)
+	if err != nil {
+		return err
+	}
+	aead, err := quarantineCipher(key, salt)
+	if err != nil {
+		return err
+	}
+	headerHash := sha256.Sum256(header)
+
+	// Containers are never executable and only readable by the bot user
+	out, err := CreateAtomic(dst, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(header); err != nil {
+		out.Abort()
+		return fmt.Errorf("failed to write container header: %w", err)
+	}
+
+	reader := bufio.NewReaderSize(src, quarantineChunkSize)
+	plain := make([]byte, quarantineChunkSize)
+	var sealed []byte
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(reader, plain)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			out.Abort()
+			return fmt.Errorf("failed to read quarantined file: %w", err)
+		}
+		final := n < quarantineChunkSize
+		if !final {
+			if _, err := reader.Peek(1); err == io.EOF {
+				final = true
+			}
+		}
+
+		sealed = aead.Seal(sealed[:0], quarantineNonce(counter, final), plain[:n], headerHash[:])
+		if _, err := out.Write(sealed); err != nil {
+			out.Abort()
+			return fmt.Errorf("failed to write container content: %w", err)
+		}
+		if final {
+			break
+		}
+	}
+
+	return out.Commit()
+}
+
+// ReadQuarantineMetadata returns the metadata of a container without decrypting it
+func ReadQuarantineMetadata(path string) (*QuarantineMetadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open quarantine container: %w", err)
+	}
+	defer file.Close()
+
+	meta, _, _, err := readQuarantineHeader(bufio.NewReader(file))
+	return meta, err
+}
+
+// ExtractQuarantineContainer decrypts a container into w and verifies the
+// content against the recorded hash. Nothing written to w may be trusted
+// unless it returns nil
+func ExtractQuarantineContainer(path string, key []byte, w io.Writer) (*QuarantineMetadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open quarantine container: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, quarantineChunkSize+aes.BlockSize)
+	meta, header, salt, err := readQuarantineHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := quarantineCipher(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	headerHash := sha256.Sum256(header)
+
+	contentHash := sha256.New()
+	out := io.MultiWriter(w, contentHash)
+	sealed := make([]byte, quarantineChunkSize+aead.Overhead())
+	var plain []byte
+	var size int64
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(reader, sealed)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return meta, fmt.Errorf("failed to read container content: %w", err)
+		}
+		final := n < len(sealed)
+		if !final {
+			if _, err := reader.Peek(1); err == io.EOF {
+				final = true
+			}
+		}
+
+		plain, err = aead.Open(plain[:0], quarantineNonce(counter, final), sealed[:n], headerHash[:])
+		if err != nil {
+			return meta, fmt.Errorf("container is corrupt or the key is wrong (chunk %d)", counter)
+		}
+		if _, err := out.Write(plain); err != nil {
+			return meta, fmt.Errorf("failed to write extracted content: %w", err)
+		}
+		size += int64(len(plain))
+		if final {
+			break
+		}
+	}
+
+	if size != meta.FileSize {
+		return meta, fmt.Errorf("extracted %d bytes, container records %d", size, meta.FileSize)
+	}
+	if meta.FileHash != "" && hex.EncodeToString(contentHash.Sum(nil)) != meta.FileHash {
+		return meta, fmt.Errorf("extracted content does not match recorded hash %s", meta.FileHash)
+	}
+	return meta, nil
+}
+
+// encodeQuarantineHeader builds magic, metadata length, metadata and salt
+func encodeQuarantineHeader(meta QuarantineMetadata, salt []byte) ([]byte, error) {
+	metadata, err := json.Marshal(meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode quarantine metadata: %w", err)
+	}
+
+	header := make([]byte, 0, len(quarantineMagic)+4+len(metadata)+len(salt))
+	header = append(header, quarantineMagic...)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(metadata)))
+	header = append(header, metadata...)
+	header = append(header, salt...)
+	return header, nil
+}
+
+// readQuarantineHeader parses a container header and returns it raw for authentication
+func readQuarantineHeader(r io.Reader) (*QuarantineMetadata, []byte, []byte, error) {
+	prefix := make([]byte, len(quarantineMagic)+4)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, nil, nil, fmt.Errorf("not a quarantine container: %w", err)
+	}
+	if string(prefix[:len(quarantineMagic)]) != string(quarantineMagic) {
+		return nil, nil, nil, fmt.Errorf("not a quarantine container")
+	}
+
+	length := binary.BigEndian.Uint32(prefix[len(quarantineMagic):])
+	if length > quarantineMaxMetadata {
+		return nil, nil, nil, fmt.Errorf("quarantine metadata too large: %d bytes", length)
+	}
+	rest := make([]byte, int(length)+quarantineSaltSize)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, nil, nil, fmt.Errorf("truncated quarantine header: %w", err)
+	}
+
+	meta := &QuarantineMetadata{}
+	if err := json.Unmarshal(rest[:length], meta); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode quarantine metadata: %w", err)
+	}
+	if meta.Format != quarantineFormatVersion {
+		return nil, nil, nil, fmt.Errorf("unsupported quarantine container format %d", meta.Format)
+	}
+
+	return meta, append(prefix, rest...), rest[length:], nil
+}
+
+// quarantineCipher derives the per-container key from the quarantine key and salt
+func quarantineCipher(key, salt []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("quarantine key must be 32 bytes, got %d", len(key))
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("quarantine container"))
+	mac.Write(salt)
+
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quarantine cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// quarantineNonce encodes the chunk position and whether it is the last one
+func quarantineNonce(counter uint64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"sync"
 	"time"
 )
@@ -35,7 +36,7 @@ type RateLimiter struct {
 	logger      *Logger
 	userStates  map[int64]*UserRateState
 	mutex       sync.RWMutex
-	stopCleanup chan struct{}
+	routines    *Routines
 }
 
 // NewRateLimiter creates a new usage tracker (no actual limiting)
@@ -48,11 +49,11 @@ func NewRateLimiter(config *RateLimitConfig, logger *Logger) *RateLimiter {
 		config:      config,
 		logger:      logger,
 		userStates:  make(map[int64]*UserRateState),
-		stopCleanup: make(chan struct{}),
+		routines:    NewRoutines(context.Background()),
 	}
 
 	// Start background cleanup routine
-	go rl.startCleanupRoutine()
+	rl.routines.Go("rate_limiter_cleanup", 3*rl.config.CleanupInterval, rl.startCleanupRoutine)
 
 	logger.Info("Usage tracker initialized (no rate limiting)")
 
@@ -197,16 +198,17 @@ func (rl *RateLimiter) IsUserBlocked(userID int64) (bool, time.Time) {
 	return false, time.Time{}
 }
 
-// startCleanupRoutine runs background cleanup of old user states
-func (rl *RateLimiter) startCleanupRoutine() {
+// startCleanupRoutine runs background cleanup of old user states until ctx is cancelled
+func (rl *RateLimiter) startCleanupRoutine(ctx context.Context) {
 	ticker := time.NewTicker(rl.config.CleanupInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
+			Heartbeat(ctx)
 			rl.performCleanup()
-		case <-rl.stopCleanup:
+		case <-ctx.Done():
 			rl.logger.Info("Stopping rate limiter cleanup routine")
 			return
 		}
@@ -238,10 +240,10 @@ func (rl *RateLimiter) performCleanup() {
 	}
 }
 
-// Shutdown gracefully shuts down the rate limiter
-func (rl *RateLimiter) Shutdown() {
+// Shutdown stops the cleanup routine and waits for it to return
+func (rl *RateLimiter) Shutdown(ctx context.Context) error {
 	rl.logger.Info("Shutting down rate limiter")
-	close(rl.stopCleanup)
+	return rl.routines.Stop(ctx)
 }
 
 // RateLimitStats provides overall usage statistics
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package utils
+
+import "errors"
+
+// Reexec is unsupported without exec(2); the caller exits and relies on its
+// service manager to start the new binary
+func Reexec() error {
+	return errors.New("re-executing is not supported on this platform")
+}
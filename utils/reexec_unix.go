@@ -0,0 +1,18 @@
+//go:build unix
+
+package utils
+
+import (
+	"os"
+	"syscall"
+)
+
+// Reexec replaces the process with a fresh start of its executable, keeping
+// the PID so service managers see no exit. Only returns on failure
+func Reexec() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(executable, os.Args, os.Environ())
+}
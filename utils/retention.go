@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Data categories a retention policy can apply to
+const (
+	// RetentionOutputFiles are merged credential files, betting files and
+	// store backups under app/extraction/files
+	RetentionOutputFiles = "output_files"
+	// RetentionStoreLines are credential lines in the store database
+	RetentionStoreLines = "store_lines"
+	// RetentionTaskMetadata are finished tasks and the records kept about them
+	RetentionTaskMetadata = "task_metadata"
+	// RetentionConversionInputs are the conversion inputs kept for reprocess
+	// campaigns (CONVERSION_KEEP_INPUTS)
+	RetentionConversionInputs = "conversion_inputs"
+)
+
+// RetentionCategories lists every category in the order they are enforced
+var RetentionCategories = []string{RetentionOutputFiles, RetentionStoreLines, RetentionTaskMetadata, RetentionConversionInputs}
+
+// RetentionPolicy is the longest a category of data is kept
+type RetentionPolicy struct {
+	Category string
+	MaxAge   time.Duration
+}
+
+// ParseRetentionPolicies parses comma-separated <category>=<max age> pairs
+// such as "output_files=30d,task_metadata=90d". Ages are Go durations or
+// whole days. Categories not listed are kept forever
+func ParseRetentionPolicies(spec string) ([]RetentionPolicy, error) {
+	var policies []RetentionPolicy
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		category, age, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("policy %q: expected <category>=<max age>", part)
+		}
+
+		category = strings.TrimSpace(category)
+		known := false
+		for _, c := range RetentionCategories {
+			known = known || c == category
+		}
+		if !known {
+			return nil, fmt.Errorf("policy %q: unknown category %q (expected one of %s)",
+				part, category, strings.Join(RetentionCategories, ", "))
+		}
+		if seen[category] {
+			return nil, fmt.Errorf("policy %q: %s is set twice", part, category)
+		}
+		seen[category] = true
+
+		maxAge, err := ParseRetentionAge(strings.TrimSpace(age))
+		if err != nil {
+			return nil, fmt.Errorf("policy %q: %w", part, err)
+		}
+		policies = append(policies, RetentionPolicy{Category: category, MaxAge: maxAge})
+	}
+	return policies, nil
+}
+
+// ParseRetentionAge accepts Go durations plus whole days, e.g. 12h or 30d
+func ParseRetentionAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	} else if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("max age must be a positive duration such as 72h or 30d, got %q", value)
+}
+
+// String renders the policy as it is configured, e.g. "task_metadata=90d"
+func (p RetentionPolicy) String() string {
+	if p.MaxAge%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%s=%dd", p.Category, p.MaxAge/(24*time.Hour))
+	}
+	return fmt.Sprintf("%s=%s", p.Category, p.MaxAge)
+}
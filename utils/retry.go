@@ -32,6 +32,7 @@ const (
 type RetryService struct {
 	config *RetryConfig
 	logger *Logger
+	random RandomSource
 }
 
 func NewRetryService(logger *Logger) *RetryService {
@@ -55,6 +56,7 @@ func NewRetryService(logger *Logger) *RetryService {
 			},
 		},
 		logger: logger,
+		random: DefaultRandomSource,
 	}
 }
 
@@ -63,6 +65,13 @@ func (rs *RetryService) WithConfig(config *RetryConfig) *RetryService {
 	return rs
 }
 
+// WithRandomSource replaces the jitter randomness, e.g. with a seeded source
+// to make delays reproducible
+func (rs *RetryService) WithRandomSource(source RandomSource) *RetryService {
+	rs.random = source
+	return rs
+}
+
 func (rs *RetryService) Execute(ctx context.Context, operation func() error, description string) error {
 	return rs.ExecuteWithCallback(ctx, operation, description, nil)
 }
@@ -166,6 +175,11 @@ func (rs *RetryService) isRetryable(err error) bool {
 		return categorizedErr.Retry == RetryImmediate || categorizedErr.Retry == RetryDelayed
 	}
 
+	// A flood wait always clears once its retry_after has passed
+	if _, ok := TelegramRetryAfter(err); ok {
+		return true
+	}
+
 	// Fallback to original pattern matching
 	errorText := err.Error()
 	for _, retryableError := range rs.config.RetryableErrors {
@@ -208,6 +222,11 @@ func (rs *RetryService) calculateDelay(attempt int) time.Duration {
 }
 
 func (rs *RetryService) calculateDelayForError(attempt int, err error) time.Duration {
+	// Telegram states exactly how long to wait; retrying sooner extends the flood wait
+	if wait, ok := TelegramRetryAfter(err); ok {
+		return wait
+	}
+
 	baseDelay := rs.calculateDelay(attempt)
 	
 	// Adjust delay based on error category
@@ -255,25 +274,7 @@ func pow(base float64, exp float64) float64 {
 
 // applyJitter adds randomization to delay to prevent thundering herd
 func (rs *RetryService) applyJitter(delay time.Duration) time.Duration {
-	if rs.config.JitterFactor <= 0 || rs.config.JitterFactor > 1 {
-		return delay // Invalid jitter factor, return original delay
-	}
-	
-	// Calculate jitter range
-	jitterRange := float64(delay) * rs.config.JitterFactor
-	
-	// Generate random jitter between -jitterRange/2 and +jitterRange/2
-	// This creates a more distributed spread around the original delay
-	jitter := (randomFloat() - 0.5) * jitterRange
-	
-	newDelay := time.Duration(float64(delay) + jitter)
-	
-	// Ensure delay doesn't go negative
-	if newDelay < 0 {
-		newDelay = delay / 2 // Fallback to half the original delay
-	}
-	
-	return newDelay
+	return applyJitter(delay, rs.config.JitterFactor, rs.random)
 }
 
 // fibonacci calculates nth fibonacci number for fibonacci backoff
@@ -292,15 +293,6 @@ func (rs *RetryService) fibonacci(n int) int {
 	return b
 }
 
-// randomFloat generates a random float between 0 and 1
-// Simple linear congruential generator for deterministic testing
-func randomFloat() float64 {
-	// Using a simple method to avoid importing crypto/rand or math/rand
-	// This is sufficient for jitter purposes
-	seed := time.Now().UnixNano()
-	return float64((seed*1103515245+12345)&0x7fffffff) / float64(0x7fffffff)
-}
-
 // FileOperationRetry provides specialized retry logic for file operations
 type FileOperationRetry struct {
 	retryService *RetryService
@@ -555,6 +547,12 @@ func NewEnhancedRetryService(logger *Logger) *EnhancedRetryService {
 	}
 }
 
+// WithRandomSource replaces the jitter randomness of the underlying retry service
+func (ers *EnhancedRetryService) WithRandomSource(source RandomSource) *EnhancedRetryService {
+	ers.retryService.WithRandomSource(source)
+	return ers
+}
+
 // ExecuteWithCategoryOptimization automatically selects optimal retry configuration based on error category
 func (ers *EnhancedRetryService) ExecuteWithCategoryOptimization(ctx context.Context, operation func() error, description string, operationContext map[string]interface{}) error {
 	var lastCategorizedErr *CategorizedError
@@ -648,6 +646,10 @@ func (ers *EnhancedRetryService) ExecuteWithCategoryOptimization(ctx context.Con
 }
 
 func (ers *EnhancedRetryService) calculateCategoryOptimizedDelay(attempt int, categorizedErr *CategorizedError, config *RetryConfig) time.Duration {
+	if wait, ok := TelegramRetryAfter(categorizedErr); ok {
+		return wait
+	}
+
 	if config == nil {
 		// Fallback to standard calculation
 		return ers.calculateCategoryDelay(attempt, categorizedErr)
@@ -695,19 +697,7 @@ func (ers *EnhancedRetryService) calculateCategoryOptimizedDelay(attempt int, ca
 }
 
 func (ers *EnhancedRetryService) applyJitter(delay time.Duration, jitterFactor float64) time.Duration {
-	if jitterFactor <= 0 || jitterFactor > 1 {
-		return delay
-	}
-	
-	jitterRange := float64(delay) * jitterFactor
-	jitter := (randomFloat() - 0.5) * jitterRange
-	newDelay := time.Duration(float64(delay) + jitter)
-	
-	if newDelay < 0 {
-		newDelay = delay / 2
-	}
-	
-	return newDelay
+	return applyJitter(delay, jitterFactor, ers.retryService.random)
 }
 
 func (ers *EnhancedRetryService) fibonacci(n int) int {
@@ -786,6 +776,10 @@ func (ers *EnhancedRetryService) ExecuteWithErrorHandling(ctx context.Context, o
 }
 
 func (ers *EnhancedRetryService) calculateCategoryDelay(attempt int, categorizedErr *CategorizedError) time.Duration {
+	if wait, ok := TelegramRetryAfter(categorizedErr); ok {
+		return wait
+	}
+
 	baseDelay := ers.retryService.calculateDelay(attempt)
 	
 	switch categorizedErr.Category {
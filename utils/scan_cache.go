@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scanLogicVersion is part of every cache key; bump it when the validation
+// logic changes so results computed by older code are not reused
+const scanLogicVersion = "2"
+
+// scanCachePruneInterval is how often stale cache entries are deleted
+const scanCachePruneInterval = time.Hour
+
+// ScanResultStore persists security scan results keyed by file hash
+type ScanResultStore interface {
+	GetScanResult(fileHash, declaredType, fingerprint string, maxAge time.Duration) ([]byte, error)
+	SaveScanResult(fileHash, declaredType, fingerprint string, result []byte) error
+	PruneScanResults(fingerprint string, maxAge time.Duration) (int64, error)
+}
+
+// scanCache reuses validation results of files that were already scanned with
+// the current signature definitions
+type scanCache struct {
+	store     ScanResultStore
+	ttl       time.Duration
+	mutex     sync.Mutex
+	lastPrune time.Time
+}
+
+// SetScanCache makes ValidateFileWithHash reuse results from store for up to
+// ttl; a zero ttl disables caching
+func (sv *SecurityValidator) SetScanCache(store ScanResultStore, ttl time.Duration) {
+	if store == nil || ttl <= 0 {
+		sv.scanCache = nil
+		return
+	}
+	sv.scanCache = &scanCache{store: store, ttl: ttl}
+}
+
+// ValidateFileWithHash validates a file whose SHA-256 is already known,
+// returning a cached result when the same content was scanned before with the
+// same signature definitions. cached reports whether the scan was skipped
+func (sv *SecurityValidator) ValidateFileWithHash(filePath, declaredType, fileHash string) (result *ValidationResult, cached bool, err error) {
+	if sv.scanCache == nil || fileHash == "" {
+		result, err = sv.ValidateFile(filePath, declaredType)
+		return result, false, err
+	}
+
+	fingerprint := sv.scanFingerprint()
+	if data, err := sv.scanCache.store.GetScanResult(fileHash, declaredType, fingerprint, sv.scanCache.ttl); err != nil {
+		sv.logger.WithError(err).WithField("file_hash", fileHash).Warn("Failed to read scan cache")
+	} else if data != nil {
+		var cachedResult ValidationResult
+		if err := json.Unmarshal(data, &cachedResult); err == nil {
+			sv.logger.WithField("file_hash", fileHash).
+				WithField("threat_level", cachedResult.ThreatLevel.String()).
+				Info("Using cached security scan result")
+			return &cachedResult, true, nil
+		}
+		sv.logger.WithField("file_hash", fileHash).Warn("Ignoring undecodable cached scan result")
+	}
+
+	result, err = sv.ValidateFile(filePath, declaredType)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if data, err := json.Marshal(result); err != nil {
+		sv.logger.WithError(err).Warn("Failed to encode scan result for caching")
+	} else if err := sv.scanCache.store.SaveScanResult(fileHash, declaredType, fingerprint, data); err != nil {
+		sv.logger.WithError(err).WithField("file_hash", fileHash).Warn("Failed to cache scan result")
+	}
+	sv.pruneScanCache(fingerprint)
+
+	return result, false, nil
+}
+
+// scanFingerprint identifies the definitions, content scan tiers and logic a
+// result was computed with
+func (sv *SecurityValidator) scanFingerprint() string {
+	tiers := make([]string, 0, len(sv.contentScanTiers()))
+	for _, tier := range sv.contentScanTiers() {
+		tiers = append(tiers, tier.String())
+	}
+	return scanLogicVersion + ":" + sv.SignatureRegistry().Current().Fingerprint + ":" + strings.Join(tiers, ",")
+}
+
+// pruneScanCache drops expired results and those of replaced definitions, at
+// most once per scanCachePruneInterval
+func (sv *SecurityValidator) pruneScanCache(fingerprint string) {
+	sv.scanCache.mutex.Lock()
+	if time.Since(sv.scanCache.lastPrune) < scanCachePruneInterval {
+		sv.scanCache.mutex.Unlock()
+		return
+	}
+	sv.scanCache.lastPrune = time.Now()
+	sv.scanCache.mutex.Unlock()
+
+	pruned, err := sv.scanCache.store.PruneScanResults(fingerprint, sv.scanCache.ttl)
+	if err != nil {
+		sv.logger.WithError(err).Warn("Failed to prune scan cache")
+		return
+	}
+	if pruned > 0 {
+		sv.logger.WithField("pruned", pruned).Debug("Pruned stale scan cache entries")
+	}
+}
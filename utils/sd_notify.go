@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notification states understood by systemd, see sd_notify(3)
+const (
+	SdNotifyReady    = "READY=1"
+	SdNotifyStopping = "STOPPING=1"
+	SdNotifyWatchdog = "WATCHDOG=1"
+)
+
+// SdNotifyEnabled reports whether the process was started by systemd with a
+// notification socket, i.e. under Type=notify or with NotifyAccess set
+func SdNotifyEnabled() bool {
+	return os.Getenv("NOTIFY_SOCKET") != ""
+}
+
+// SdNotify sends newline-separated states such as "READY=1\nSTATUS=..." to
+// systemd. It is a no-op returning false when not run by systemd
+func SdNotify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// Abstract socket names are given with a leading @
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to systemd notification socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	return true, nil
+}
+
+// SdStatus formats a STATUS= line shown by systemctl status; newlines would
+// start another state, so they are replaced
+func SdStatus(format string, args ...interface{}) string {
+	return "STATUS=" + strings.ReplaceAll(fmt.Sprintf(format, args...), "\n", " ")
+}
+
+// SdWatchdogInterval returns how often systemd expects WATCHDOG=1 (WatchdogSec=),
+// or 0 when its watchdog is off or meant for another process
+func SdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
@@ -0,0 +1,396 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretProvider fetches secrets referenced from settings as "<scheme>:<ref>",
+// e.g. TELEGRAM_BOT_TOKEN=vault:secret/telegram-bot#token
+type SecretProvider interface {
+	// Scheme is the reference prefix the provider handles
+	Scheme() string
+	Fetch(ctx context.Context, ref string) (string, error)
+}
+
+// secretFetchTimeout bounds each fetch from a provider
+const secretFetchTimeout = 30 * time.Second
+
+// SecretResolver resolves settings holding secret references and re-fetches
+// them periodically, calling the rotation hooks of values that changed.
+// Settings without a known scheme are used as they are
+type SecretResolver struct {
+	mu        sync.Mutex
+	providers map[string]SecretProvider
+	values    map[string]string // Resolved value per setting name
+	hooks     map[string][]func(value string)
+}
+
+// Secrets resolves the secret settings read by LoadConfig
+var Secrets = NewSecretResolver(DefaultSecretProviders()...)
+
+// NewSecretResolver creates a resolver over providers
+func NewSecretResolver(providers ...SecretProvider) *SecretResolver {
+	r := &SecretResolver{
+		providers: make(map[string]SecretProvider),
+		values:    make(map[string]string),
+		hooks:     make(map[string][]func(value string)),
+	}
+	for _, p := range providers {
+		r.providers[p.Scheme()] = p
+	}
+	return r
+}
+
+// DefaultSecretProviders returns the Docker secrets, file, Vault and AWS SSM
+// providers. They read their own settings (VAULT_ADDR, AWS_REGION, ...) when
+// fetching, so they work with values loaded from .env
+func DefaultSecretProviders() []SecretProvider {
+	client := &http.Client{Timeout: secretFetchTimeout}
+	return []SecretProvider{
+		fileSecretProvider{scheme: "docker", dir: "/run/secrets"},
+		fileSecretProvider{scheme: "file"},
+		&vaultSecretProvider{client: client},
+		&ssmSecretProvider{client: client},
+	}
+}
+
+// SecretEnv returns the setting name with a secret reference resolved
+func SecretEnv(name string) (string, error) {
+	return Secrets.Get(name)
+}
+
+// Get returns the setting name, fetching it from its provider the first time
+func (r *SecretResolver) Get(name string) (string, error) {
+	raw := os.Getenv(name)
+	provider, ref := r.provider(raw)
+	if provider == nil {
+		return raw, nil
+	}
+
+	r.mu.Lock()
+	value, ok := r.values[name]
+	r.mu.Unlock()
+	if ok {
+		return value, nil
+	}
+
+	value, err := fetchSecret(provider, ref)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	r.values[name] = value
+	r.mu.Unlock()
+	return value, nil
+}
+
+// OnRotate calls hook with the new value whenever the secret behind the
+// setting name changes
+func (r *SecretResolver) OnRotate(name string, hook func(value string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks[name] = append(r.hooks[name], hook)
+}
+
+// Refresh re-fetches every resolved secret and calls the rotation hooks of
+// those that changed. It returns the names of the rotated settings
+func (r *SecretResolver) Refresh() ([]string, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.values))
+	for name := range r.values {
+		names = append(names, name)
+	}
+	r.mu.Unlock()
+	sort.Strings(names)
+
+	var rotated, failed []string
+	for _, name := range names {
+		provider, ref := r.provider(os.Getenv(name))
+		if provider == nil {
+			continue
+		}
+		value, err := fetchSecret(provider, ref)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+
+		r.mu.Lock()
+		changed := r.values[name] != value
+		r.values[name] = value
+		hooks := append([]func(string){}, r.hooks[name]...)
+		r.mu.Unlock()
+
+		if changed {
+			rotated = append(rotated, name)
+			for _, hook := range hooks {
+				hook(value)
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return rotated, fmt.Errorf("failed to refresh secrets: %s", strings.Join(failed, "; "))
+	}
+	return rotated, nil
+}
+
+// Run refreshes the secrets every interval until ctx is cancelled
+func (r *SecretResolver) Run(ctx context.Context, interval time.Duration, logger *Logger) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		Heartbeat(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		rotated, err := r.Refresh()
+		if err != nil {
+			logger.WithError(err).Warn("Secrets not refreshed")
+		}
+		for _, name := range rotated {
+			logger.WithField("setting", name).Info("Secret rotated")
+		}
+	}
+}
+
+// provider returns the provider of a "<scheme>:<ref>" value, or nil when the
+// value is not a secret reference
+func (r *SecretResolver) provider(raw string) (SecretProvider, string) {
+	scheme, ref, ok := strings.Cut(raw, ":")
+	if !ok || ref == "" {
+		return nil, ""
+	}
+	return r.providers[scheme], ref
+}
+
+func fetchSecret(provider SecretProvider, ref string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretFetchTimeout)
+	defer cancel()
+
+	value, err := provider.Fetch(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("%s secret %s: %w", provider.Scheme(), ref, err)
+	}
+	return value, nil
+}
+
+// fileSecretProvider reads a secret from a file, relative to dir when set
+// (Docker and Kubernetes mount secrets as files under /run/secrets)
+type fileSecretProvider struct {
+	scheme string
+	dir    string
+}
+
+func (p fileSecretProvider) Scheme() string { return p.scheme }
+
+func (p fileSecretProvider) Fetch(ctx context.Context, ref string) (string, error) {
+	path := ref
+	if p.dir != "" {
+		if strings.ContainsAny(ref, `/\`) {
+			return "", fmt.Errorf("invalid secret name")
+		}
+		path = filepath.Join(p.dir, ref)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// vaultSecretProvider reads a field of a HashiCorp Vault KV version 2 secret,
+// referenced as "<mount>/<path>#<field>", with VAULT_ADDR and VAULT_TOKEN
+// (or VAULT_TOKEN_FILE) and the optional VAULT_NAMESPACE
+type vaultSecretProvider struct {
+	client *http.Client
+}
+
+func (p *vaultSecretProvider) Scheme() string { return "vault" }
+
+func (p *vaultSecretProvider) Fetch(ctx context.Context, ref string) (string, error) {
+	addr := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if path := os.Getenv("VAULT_TOKEN_FILE"); token == "" && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read VAULT_TOKEN_FILE: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token == "" {
+		return "", fmt.Errorf("VAULT_TOKEN is not set")
+	}
+
+	secretPath, field, ok := strings.Cut(ref, "#")
+	mount, path, hasPath := strings.Cut(secretPath, "/")
+	if !ok || field == "" || !hasPath || path == "" {
+		return "", fmt.Errorf("expected <mount>/<path>#<field>")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/v1/%s/data/%s", addr, mount, path), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+		Errors []string `json:"errors"`
+	}
+	if err := doSecretRequest(p.client, req, &body); err != nil {
+		if len(body.Errors) > 0 {
+			return "", fmt.Errorf("%w: %s", err, strings.Join(body.Errors, "; "))
+		}
+		return "", err
+	}
+	value, ok := body.Data.Data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("field %q not found", field)
+	}
+	return value, nil
+}
+
+// ssmSecretProvider reads an AWS Systems Manager parameter, decrypting
+// SecureString parameters, with AWS_REGION and the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and optional AWS_SESSION_TOKEN credentials
+type ssmSecretProvider struct {
+	client *http.Client
+}
+
+func (p *ssmSecretProvider) Scheme() string { return "ssm" }
+
+func (p *ssmSecretProvider) Fetch(ctx context.Context, ref string) (string, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if region == "" || accessKey == "" || secretKey == "" {
+		return "", fmt.Errorf("AWS_REGION, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required")
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{"Name": ref, "WithDecryption": true})
+	if err != nil {
+		return "", err
+	}
+	host := fmt.Sprintf("ssm.%s.amazonaws.com", region)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+host+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AmazonSSM.GetParameter")
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	signAWSRequest(req, payload, host, region, "ssm", accessKey, secretKey, time.Now().UTC())
+
+	var body struct {
+		Parameter struct {
+			Value string `json:"Value"`
+		} `json:"Parameter"`
+		Type    string `json:"__type"`
+		Message string `json:"message"`
+	}
+	if err := doSecretRequest(p.client, req, &body); err != nil {
+		if body.Type != "" {
+			return "", fmt.Errorf("%w: %s %s", err, body.Type, body.Message)
+		}
+		return "", err
+	}
+	return body.Parameter.Value, nil
+}
+
+// signAWSRequest adds an AWS Signature Version 4 Authorization header
+func signAWSRequest(req *http.Request, payload []byte, host, region, service, accessKey, secretKey string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("Host", host)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{
+		req.Method, "/", "", canonicalHeaders.String(), signedHeaders, hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, region, service)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// doSecretRequest sends req and decodes the JSON response into out, which is
+// also filled in for error responses
+func doSecretRequest(client *http.Client, req *http.Request, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	decodeErr := json.Unmarshal(data, out)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("invalid response: %w", decodeErr)
+	}
+	return nil
+}
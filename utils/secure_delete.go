@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// secureDeleteChunkSize is the write size of each overwrite pass
+const secureDeleteChunkSize = 64 * 1024
+
+// ErrRemovedWithoutOverwrite is returned by RemoveSecurely when a file could
+// not be overwritten and was only unlinked
+var ErrRemovedWithoutOverwrite = errors.New("removed without overwrite")
+
+// SecureDelete overwrites a file with zeros, ones and random data, syncing
+// after each pass, and then removes it. A file that can't be overwritten is
+// left in place. Symlinks are removed without touching their target.
+// Overwriting only reaches the blocks the file occupies now; copy-on-write
+// filesystems, snapshots and SSD wear levelling may keep older copies
+func SecureDelete(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().IsRegular() {
+		if err := overwriteFile(path); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove file after secure overwrite: %w", err)
+	}
+	return nil
+}
+
+// RemoveSecurely deletes a file with SecureDelete and falls back to unlinking
+// it when it can't be overwritten, e.g. when it is read-only to this user. The
+// fallback is reported as ErrRemovedWithoutOverwrite with the reason, so the
+// caller can warn about it; a missing file is os.ErrNotExist
+func RemoveSecurely(path string) error {
+	err := SecureDelete(path)
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if removeErr := os.Remove(path); removeErr != nil {
+		return fmt.Errorf("%v; %w", err, removeErr)
+	}
+	return fmt.Errorf("%w: %v", ErrRemovedWithoutOverwrite, err)
+}
+
+// overwriteFile replaces the content of a file in three synced passes
+func overwriteFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open file for secure deletion: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get file stats for secure deletion: %w", err)
+	}
+	fileSize := stat.Size()
+
+	// Zeros, ones, then random data (DoD 5220.22-M)
+	passes := [][]byte{
+		make([]byte, secureDeleteChunkSize),
+		make([]byte, secureDeleteChunkSize),
+		make([]byte, secureDeleteChunkSize),
+	}
+	for i := range passes[1] {
+		passes[1][i] = 0xFF
+	}
+
+	for passNum, pattern := range passes {
+		if _, err := file.Seek(0, 0); err != nil {
+			return fmt.Errorf("secure deletion pass %d: %w", passNum+1, err)
+		}
+
+		for written := int64(0); written < fileSize; {
+			toWrite := int64(len(pattern))
+			if written+toWrite > fileSize {
+				toWrite = fileSize - written
+			}
+			if passNum == 2 {
+				rand.Read(pattern[:toWrite])
+			}
+
+			n, err := file.Write(pattern[:toWrite])
+			if err != nil {
+				return fmt.Errorf("secure deletion pass %d: %w", passNum+1, err)
+			}
+			written += int64(n)
+		}
+
+		// Force each pass to disk so later passes don't just replace it in the page cache
+		if err := file.Sync(); err != nil {
+			return fmt.Errorf("secure deletion pass %d: %w", passNum+1, err)
+		}
+	}
+	return file.Close()
+}
@@ -1,8 +1,10 @@
 package utils
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -19,12 +21,12 @@ type SecureTempManager struct {
 	baseTempDir      string
 	sessionID        string
 	activeFiles      map[string]*TempFileInfo
+	taskReferences   map[string]int // Tasks whose files are in use, see AcquireTask
 	mutex            sync.RWMutex
 	cleanupInterval  time.Duration
 	maxFileAge       time.Duration
 	secureDelete     bool
-	stopCleanup      chan struct{}
-	cleanupRunning   bool
+	routines         *Routines
 }
 
 // TempFileInfo tracks information about temporary files
@@ -82,15 +84,15 @@ func NewSecureTempManager(logger *Logger, baseTempDir string) (*SecureTempManage
 		baseTempDir:     secureBaseDir,
 		sessionID:       sessionID,
 		activeFiles:     make(map[string]*TempFileInfo),
+		taskReferences:  make(map[string]int),
 		cleanupInterval: 5 * time.Minute,
 		maxFileAge:      30 * time.Minute,
 		secureDelete:    true,
-		stopCleanup:     make(chan struct{}),
-		cleanupRunning:  false,
+		routines:        NewRoutines(context.Background()),
 	}
 
 	// Start background cleanup routine
-	go stm.startCleanupRoutine()
+	stm.routines.Go("temp_file_cleanup", 3*stm.cleanupInterval, stm.startCleanupRoutine)
 
 	logger.WithField("session_id", sessionID).
 		WithField("temp_dir", secureBaseDir).
@@ -272,19 +274,54 @@ func (stm *SecureTempManager) RemoveReference(fileID string) error {
 	return nil
 }
 
-// startCleanupRoutine runs the background cleanup process
-func (stm *SecureTempManager) startCleanupRoutine() {
-	stm.cleanupRunning = true
-	defer func() { stm.cleanupRunning = false }()
-	
+// AcquireTask takes a reference on every temporary file of a task, including
+// files created while it is held, so the age-based cleaner leaves them alone
+// however long the task's processing takes. Each call needs a ReleaseTask
+func (stm *SecureTempManager) AcquireTask(taskID string) {
+	stm.mutex.Lock()
+	defer stm.mutex.Unlock()
+
+	stm.taskReferences[taskID]++
+	stm.logger.WithField("task_id", taskID).
+		WithField("references", stm.taskReferences[taskID]).
+		Debug("Added reference to task temporary files")
+}
+
+// ReleaseTask drops a reference taken with AcquireTask
+func (stm *SecureTempManager) ReleaseTask(taskID string) {
+	stm.mutex.Lock()
+	defer stm.mutex.Unlock()
+
+	if stm.taskReferences[taskID] <= 1 {
+		delete(stm.taskReferences, taskID)
+	} else {
+		stm.taskReferences[taskID]--
+	}
+	stm.logger.WithField("task_id", taskID).
+		WithField("references", stm.taskReferences[taskID]).
+		Debug("Removed reference from task temporary files")
+}
+
+// inUseUnsafe reports whether a file is referenced, directly through an open
+// handle or AddReference, or through its task (must be called with mutex held)
+func (stm *SecureTempManager) inUseUnsafe(info *TempFileInfo) bool {
+	if info.References > 0 {
+		return true
+	}
+	return info.TaskID != "" && stm.taskReferences[info.TaskID] > 0
+}
+
+// startCleanupRoutine runs the background cleanup process until ctx is cancelled
+func (stm *SecureTempManager) startCleanupRoutine(ctx context.Context) {
 	ticker := time.NewTicker(stm.cleanupInterval)
 	defer ticker.Stop()
 	
 	for {
 		select {
 		case <-ticker.C:
+			Heartbeat(ctx)
 			stm.performCleanup()
-		case <-stm.stopCleanup:
+		case <-ctx.Done():
 			stm.logger.Info("Stopping secure temp file cleanup routine")
 			return
 		}
@@ -307,8 +344,11 @@ func (stm *SecureTempManager) performCleanup() {
 		if info.Locked {
 			continue // Skip locked files
 		}
+		if stm.inUseUnsafe(info) {
+			continue // Skip files in use, however old
+		}
 		
-		if info.References <= 0 && info.CleanupMethod == CleanupImmediate {
+		if info.CleanupMethod == CleanupImmediate {
 			shouldCleanup = true
 			reason = "immediate cleanup requested"
 		} else if now.Sub(info.CreatedAt) > stm.maxFileAge {
@@ -365,76 +405,19 @@ func (stm *SecureTempManager) cleanupFileUnsafe(fileID string, info *TempFileInf
 
 // secureDeleteFile performs secure file deletion by overwriting content
 func (stm *SecureTempManager) secureDeleteFile(filePath string) {
-	// Open file for overwriting
-	file, err := os.OpenFile(filePath, os.O_WRONLY, 0)
-	if err != nil {
+	if err := RemoveSecurely(filePath); errors.Is(err, ErrRemovedWithoutOverwrite) {
 		stm.logger.WithError(err).
 			WithField("file_path", filePath).
-			Warn("Failed to open file for secure deletion")
-		// Fall back to standard deletion
-		os.Remove(filePath)
+			Warn("Secure deletion failed, removed file without overwrite")
 		return
-	}
-	defer file.Close()
-	
-	// Get file size
-	stat, err := file.Stat()
-	if err != nil {
-		stm.logger.WithError(err).Warn("Failed to get file stats for secure deletion")
-		os.Remove(filePath)
-		return
-	}
-	
-	fileSize := stat.Size()
-	
-	// Perform multiple pass overwrite (DoD 5220.22-M standard)
-	passes := [][]byte{
-		make([]byte, 1024), // Pass 1: zeros
-		make([]byte, 1024), // Pass 2: ones  
-		make([]byte, 1024), // Pass 3: random
-	}
-	
-	// Fill pass patterns
-	for i := range passes[1] {
-		passes[1][i] = 0xFF // All ones
-	}
-	rand.Read(passes[2]) // Random data
-	
-	// Perform overwrite passes
-	for passNum, pattern := range passes {
-		file.Seek(0, 0)
-		written := int64(0)
-		
-		for written < fileSize {
-			toWrite := int64(len(pattern))
-			if written+toWrite > fileSize {
-				toWrite = fileSize - written
-			}
-			
-			n, err := file.Write(pattern[:toWrite])
-			if err != nil {
-				stm.logger.WithError(err).
-					WithField("pass", passNum+1).
-					Warn("Error during secure deletion pass")
-				break
-			}
-			written += int64(n)
-		}
-		
-		// Force write to disk
-		file.Sync()
-	}
-	
-	// Finally remove the file
-	file.Close()
-	if err := os.Remove(filePath); err != nil {
+	} else if err != nil && !os.IsNotExist(err) {
 		stm.logger.WithError(err).
 			WithField("file_path", filePath).
-			Warn("Failed to remove file after secure overwrite")
+			Warn("Failed to remove temporary file")
+		return
 	}
-	
+
 	stm.logger.WithField("file_path", filePath).
-		WithField("size", fileSize).
 		Debug("Secure file deletion completed")
 }
 
@@ -456,7 +439,7 @@ func (stm *SecureTempManager) moveToLogsDirectory(info *TempFileInfo) {
 	
 	logPath := filepath.Join(logsDir, logFileName)
 	
-	if err := os.Rename(info.Path, logPath); err != nil {
+	if err := MoveFile(info.Path, logPath, DefaultMoveOptions()); err != nil {
 		stm.logger.WithError(err).Warn("Failed to move file to logs directory")
 		os.Remove(info.Path) // Fall back to deletion
 	} else {
@@ -517,12 +500,16 @@ func (stm *SecureTempManager) GetStats() TempFileStats {
 	
 	var totalSize int64
 	lockedCount := 0
+	referencedCount := 0
 	
 	for _, info := range stm.activeFiles {
 		totalSize += info.Size
 		if info.Locked {
 			lockedCount++
 		}
+		if stm.inUseUnsafe(info) {
+			referencedCount++
+		}
 		
 		age := time.Since(info.CreatedAt)
 		if age > stats.OldestFileAge {
@@ -532,20 +519,19 @@ func (stm *SecureTempManager) GetStats() TempFileStats {
 	
 	stats.TotalSize = totalSize
 	stats.LockedFiles = lockedCount
+	stats.ReferencedFiles = referencedCount
+	stats.ReferencedTasks = len(stm.taskReferences)
 	
 	return stats
 }
 
-// Shutdown gracefully shuts down the secure temp manager
-func (stm *SecureTempManager) Shutdown() error {
+// Shutdown stops the cleanup routine, waiting for a cleanup in progress, and
+// then removes all temporary files
+func (stm *SecureTempManager) Shutdown(ctx context.Context) error {
 	stm.logger.Info("Shutting down secure temporary file manager")
 	
-	// Stop cleanup routine
-	if stm.cleanupRunning {
-		close(stm.stopCleanup)
-		
-		// Wait a bit for cleanup routine to stop
-		time.Sleep(100 * time.Millisecond)
+	if err := stm.routines.Stop(ctx); err != nil {
+		return fmt.Errorf("cleanup routine did not stop: %w", err)
 	}
 	
 	// Perform final cleanup
@@ -554,14 +540,16 @@ func (stm *SecureTempManager) Shutdown() error {
 
 // TempFileStats provides statistics about temporary file usage
 type TempFileStats struct {
-	TotalFiles    int
-	LockedFiles   int
-	TotalSize     int64
-	OldestFileAge time.Duration
-	SessionID     string
-	BaseDirectory string
-	MaxAge        time.Duration
-	SecureDelete  bool
+	TotalFiles      int
+	LockedFiles     int
+	ReferencedFiles int // Open or referenced files, exempt from age cleanup
+	ReferencedTasks int // Tasks holding references through AcquireTask
+	TotalSize       int64
+	OldestFileAge   time.Duration
+	SessionID       string
+	BaseDirectory   string
+	MaxAge          time.Duration
+	SecureDelete    bool
 }
 
 // SecureTempFile represents a secure temporary file
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
 	"regexp"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -26,6 +28,7 @@ type SecurityValidator struct {
 	dangerousPatterns         []*regexp.Regexp
 	config                    *Config
 	enhancedSignatureValidator *EnhancedSignatureValidator
+	scanCache                 *scanCache
 }
 
 // NewSecurityValidator creates a new security validator
@@ -43,11 +46,16 @@ func NewSecurityValidator(logger *Logger, config *Config) *SecurityValidator {
 	sv.initializeDangerousPatterns()
 	
 	// Initialize enhanced signature validator
-	sv.enhancedSignatureValidator = NewEnhancedSignatureValidator(logger)
+	sv.enhancedSignatureValidator = NewEnhancedSignatureValidator(logger, NewSignatureRegistry(config.SignatureDefinitionsPath, logger))
 	
 	return sv
 }
 
+// SignatureRegistry returns the signature definitions used by the validator
+func (sv *SecurityValidator) SignatureRegistry() *SignatureRegistry {
+	return sv.enhancedSignatureValidator.registry
+}
+
 // initializeFileSignatures sets up file signature validation
 func (sv *SecurityValidator) initializeFileSignatures() {
 	sv.allowedTypes = map[string]FileSignature{
@@ -211,6 +219,9 @@ func (sv *SecurityValidator) ValidateFile(filePath, declaredType string) (*Valid
 		result.EnhancedSecurityChecks["polyglot_risks"] = signatureResult.PolyglotRisks
 		result.EnhancedSecurityChecks["suspicious_features"] = signatureResult.SuspiciousFeatures
 		result.EnhancedSecurityChecks["anti_spoofing_checks"] = signatureResult.AntiSpoofingChecks
+		if signatureResult.Anomaly != nil {
+			result.EnhancedSecurityChecks["anomaly_score"] = signatureResult.Anomaly
+		}
 		
 		sv.logger.WithField("confidence", signatureResult.ConfidenceLevel).
 			WithField("genuine", signatureResult.IsGenuineFileType).
@@ -320,7 +331,9 @@ func (sv *SecurityValidator) validateTextEncoding(buffer []byte, result *Validat
 	return nil
 }
 
-// scanFileContent scans file content for dangerous patterns
+// scanFileContent scans file content for dangerous patterns. Files are scanned
+// fully or by sampling head, tail and interior windows depending on their
+// size tier, so payloads appended past the start of a large file are not missed
 func (sv *SecurityValidator) scanFileContent(filePath string, result *ValidationResult) error {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -328,36 +341,60 @@ func (sv *SecurityValidator) scanFileContent(filePath string, result *Validation
 	}
 	defer file.Close()
 	
-	// For large files, only scan first 1MB to avoid performance issues
-	scanSize := int64(1024 * 1024) // 1MB
-	if result.FileSize < scanSize {
-		scanSize = result.FileSize
-	}
-	
-	content := make([]byte, scanSize)
-	n, err := file.Read(content)
-	if err != nil && err != io.EOF {
-		return fmt.Errorf("failed to read file content: %w", err)
-	}
-	
-	content = content[:n]
+	tier := contentScanTier(sv.contentScanTiers(), result.FileSize)
+	windows := sampleWindows(result.FileSize, tier, rand.New(rand.NewSource(time.Now().UnixNano())))
 	
-	// Scan for dangerous patterns
-	for _, pattern := range sv.dangerousPatterns {
-		if pattern.Match(content) {
-			warning := fmt.Sprintf("Detected potentially dangerous pattern: %s", pattern.String())
+	// Report each pattern once, at the first offset it was seen
+	matched := make(map[*regexp.Regexp]bool)
+	scanned, err := scanWindows(file, windows, func(chunk []byte, offset int64) {
+		for _, pattern := range sv.dangerousPatterns {
+			if matched[pattern] {
+				continue
+			}
+			loc := pattern.FindIndex(chunk)
+			if loc == nil {
+				continue
+			}
+			matched[pattern] = true
+			
+			warning := fmt.Sprintf("Detected potentially dangerous pattern: %s (at offset %d)", pattern.String(), offset+int64(loc[0]))
 			result.SecurityWarnings = append(result.SecurityWarnings, warning)
 			
 			// Escalate threat level based on pattern severity
 			if strings.Contains(pattern.String(), "script") || strings.Contains(pattern.String(), "executable") {
 				result.ThreatLevel = ThreatLevelHigh
-			} else {
+			} else if result.ThreatLevel != ThreatLevelHigh {
 				result.ThreatLevel = ThreatLevelMedium
 			}
 		}
+	})
+	
+	mode := "full"
+	if len(windows) > 1 || (len(windows) == 1 && windows[0].length < result.FileSize) {
+		mode = "sampled"
+	}
+	coverage := 1.0
+	if result.FileSize > 0 {
+		coverage = float64(scanned) / float64(result.FileSize)
+	}
+	result.EnhancedSecurityChecks["content_scan"] = map[string]interface{}{
+		"mode":          mode,
+		"tier":          tier.String(),
+		"windows":       len(windows),
+		"bytes_scanned": scanned,
+		"coverage":      coverage,
 	}
 	
-	return nil
+	return err
+}
+
+// contentScanTiers returns the configured content scan tiers or the defaults
+func (sv *SecurityValidator) contentScanTiers() []ContentScanTier {
+	if sv.config != nil && len(sv.config.ContentScanTiers) > 0 {
+		return sv.config.ContentScanTiers
+	}
+	tiers, _ := ParseContentScanTiers(DefaultContentScanTiers)
+	return tiers
 }
 
 // validateArchiveStructure performs basic archive structure validation
@@ -613,7 +650,7 @@ func (sv *SecurityValidator) GetValidationSummary() map[string]interface{} {
 		"Content pattern analysis",
 		"Archive structure validation",
 		"Text encoding validation",
-		"Entropy analysis",
+		"Entropy and compression-ratio anomaly scoring",
 		"File sanitization",
 		"Automatic quarantine",
 	}
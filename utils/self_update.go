@@ -0,0 +1,504 @@
+package utils
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits on what the release feed may make the updater download
+const (
+	maxReleaseFeedSize   = 1 << 20
+	maxReleaseBinarySize = 512 << 20
+	updateFetchTimeout   = 10 * time.Minute
+	// updateRestartPoll is how often a staged update checks for an idle window
+	updateRestartPoll = time.Minute
+)
+
+// ReleaseFeed is the document at UPDATE_FEED_URL: the latest release of each
+// update channel, e.g. {"channels": {"stable": {...}, "beta": {...}}}
+type ReleaseFeed struct {
+	Channels map[string]*Release `json:"channels"`
+}
+
+// Release is one published build. Binaries are keyed by GOOS/GOARCH, e.g. "linux/amd64"
+type Release struct {
+	Version     string                    `json:"version"`
+	PublishedAt time.Time                 `json:"published_at"`
+	Notes       string                    `json:"notes,omitempty"`
+	Binaries    map[string]*ReleaseBinary `json:"binaries"`
+}
+
+// ReleaseBinary is a downloadable executable. Signature is the base64
+// Ed25519 signature of its ReleaseManifest made with the key of
+// UPDATE_PUBLIC_KEY, so the version, channel and platform it was published
+// for can't be changed without the key
+type ReleaseBinary struct {
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature"`
+}
+
+// ReleaseManifest returns the bytes a release binary's signature covers. The
+// feed itself is not signed, so everything the updater trusts from it is
+// bound here: a signed old build can't be relabelled as a newer version,
+// moved to another channel or offered to another platform
+func ReleaseManifest(version, channel, platform, sha256 string) []byte {
+	return []byte(fmt.Sprintf("telegram-archive-bot release\nversion: %s\nchannel: %s\nplatform: %s\nsha256: %s\n",
+		version, channel, platform, strings.ToLower(sha256)))
+}
+
+// stagedBinaryName is the file a verified release is staged as; the version
+// comes from the feed, so it never becomes part of a path
+const stagedBinaryName = "telegram-archive-bot.staged"
+
+// Self-update states shown by /version
+const (
+	UpdateStateDisabled   = "disabled"
+	UpdateStateUpToDate   = "up to date"
+	UpdateStateStaged     = "staged"
+	UpdateStateRestarting = "restarting"
+	UpdateStateFailed     = "failed"
+)
+
+// UpdateStatus is what the updater knows about available releases
+type UpdateStatus struct {
+	State          string
+	Channel        string
+	CurrentVersion string
+	Latest         *Release
+	// Staged is the verified release waiting to be installed, nil when none
+	Staged    *StagedRelease
+	LastCheck time.Time
+	LastError string
+}
+
+// StagedRelease is a downloaded release binary whose checksum and signed
+// manifest were verified. It is kept apart from Latest, which the next
+// check replaces, so the install checks the file against what was verified
+type StagedRelease struct {
+	Path    string
+	Version string
+	SHA256  string
+}
+
+// ClockWindow is a daily time range in minutes after midnight; a window whose
+// end is before its start runs past midnight. The zero value is always open
+type ClockWindow struct {
+	Start int
+	End   int
+}
+
+// ParseClockWindow parses "HH:MM-HH:MM"; empty means any time
+func ParseClockWindow(s string) (ClockWindow, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ClockWindow{}, nil
+	}
+	startText, endText, ok := strings.Cut(s, "-")
+	if !ok {
+		return ClockWindow{}, fmt.Errorf("expected HH:MM-HH:MM")
+	}
+	start, err := parseClock(startText)
+	if err != nil {
+		return ClockWindow{}, fmt.Errorf("invalid start time: %w", err)
+	}
+	end, err := parseClock(endText)
+	if err != nil {
+		return ClockWindow{}, fmt.Errorf("invalid end time: %w", err)
+	}
+	if start == end {
+		return ClockWindow{}, fmt.Errorf("empty time range")
+	}
+	return ClockWindow{Start: start, End: end}, nil
+}
+
+// Contains reports whether t falls within the window
+func (w ClockWindow) Contains(t time.Time) bool {
+	if w.Start == w.End {
+		return true
+	}
+	minute := t.Hour()*60 + t.Minute()
+	if w.End < w.Start {
+		return minute >= w.Start || minute < w.End
+	}
+	return minute >= w.Start && minute < w.End
+}
+
+// String renders the window in the format ParseClockWindow accepts
+func (w ClockWindow) String() string {
+	if w.Start == w.End {
+		return "any time"
+	}
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.Start/60, w.Start%60, w.End/60, w.End%60)
+}
+
+// ParseUpdatePublicKey decodes an Ed25519 public key given in hex or base64
+func ParseUpdatePublicKey(s string) (ed25519.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		key, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("expected a %d-byte Ed25519 public key in hex or base64", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// CompareVersions compares "vMAJOR.MINOR.PATCH" versions, ignoring build
+// suffixes such as "+abcdef". ok is false when either is not such a version,
+// e.g. for "(devel)" builds
+func CompareVersions(a, b string) (result int, ok bool) {
+	pa, okA := parseReleaseVersion(a)
+	pb, okB := parseReleaseVersion(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	for i := range pa {
+		switch {
+		case pa[i] < pb[i]:
+			return -1, true
+		case pa[i] > pb[i]:
+			return 1, true
+		}
+	}
+	return 0, true
+}
+
+func parseReleaseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v, _, _ = strings.Cut(v, "+")
+	v, _, _ = strings.Cut(v, "-")
+	fields := strings.Split(strings.TrimPrefix(v, "v"), ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// SelfUpdater checks a release feed for newer builds, stages and verifies
+// them, and replaces the running binary once the bot is idle within the
+// restart window. The restart itself is left to main, which shuts down
+// gracefully when Restart is closed
+type SelfUpdater struct {
+	config    *Config
+	logger    *Logger
+	publicKey ed25519.PublicKey
+	client    *http.Client
+	idle      func() bool
+	notify    func(title, message string)
+	restart   chan struct{}
+
+	mutex  sync.Mutex
+	status UpdateStatus
+}
+
+// NewSelfUpdater creates an updater for UPDATE_FEED_URL; config validation
+// guarantees a valid UPDATE_PUBLIC_KEY when the feed is set
+func NewSelfUpdater(config *Config, logger *Logger) *SelfUpdater {
+	su := &SelfUpdater{
+		config:  config,
+		logger:  logger,
+		client:  &http.Client{Timeout: updateFetchTimeout},
+		idle:    func() bool { return true },
+		restart: make(chan struct{}),
+		status: UpdateStatus{
+			State:          UpdateStateDisabled,
+			Channel:        config.UpdateChannel,
+			CurrentVersion: BotVersion(),
+		},
+	}
+	if config.UpdateFeedURL != "" {
+		su.publicKey, _ = ParseUpdatePublicKey(config.UpdatePublicKey)
+		su.status.State = UpdateStateUpToDate
+	}
+	return su
+}
+
+// SetIdleCheck sets how the updater decides that no work would be interrupted
+func (su *SelfUpdater) SetIdleCheck(idle func() bool) {
+	su.idle = idle
+}
+
+// SetNotifier sets where staged updates and failed checks are reported
+func (su *SelfUpdater) SetNotifier(notify func(title, message string)) {
+	su.notify = notify
+}
+
+// Restart is closed once a staged update replaced the binary; the process
+// should then shut down gracefully and start the new binary
+func (su *SelfUpdater) Restart() <-chan struct{} {
+	return su.restart
+}
+
+// Status returns what the updater last found
+func (su *SelfUpdater) Status() UpdateStatus {
+	su.mutex.Lock()
+	defer su.mutex.Unlock()
+	return su.status
+}
+
+// Run checks the feed every UPDATE_CHECK_INTERVAL and applies a staged update
+// in the first idle moment within UPDATE_RESTART_WINDOW
+func (su *SelfUpdater) Run(ctx context.Context) error {
+	ticker := time.NewTicker(updateRestartPoll)
+	defer ticker.Stop()
+
+	var nextCheck time.Time
+	for {
+		Heartbeat(ctx)
+
+		if time.Now().After(nextCheck) {
+			if err := su.Check(ctx); err != nil && ctx.Err() == nil {
+				su.logger.WithError(err).Warn("Self-update check failed")
+			}
+			nextCheck = time.Now().Add(su.config.UpdateCheckInterval)
+		}
+
+		if su.Status().State == UpdateStateStaged && su.config.UpdateRestartWindow.Contains(time.Now()) && su.idle() {
+			if err := su.apply(); err != nil {
+				// Drop the staged binary; the next check downloads it again
+				su.setState(UpdateStateFailed, nil, "")
+				su.fail(fmt.Errorf("failed to install update: %w", err))
+			} else {
+				close(su.restart)
+				<-ctx.Done()
+				return ctx.Err()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check fetches the feed and stages the channel's release when it is newer
+// than the running build
+func (su *SelfUpdater) Check(ctx context.Context) error {
+	if su.config.UpdateFeedURL == "" {
+		return nil
+	}
+
+	release, err := su.fetchRelease(ctx)
+	su.mutex.Lock()
+	su.status.LastCheck = time.Now()
+	if err == nil {
+		su.status.Latest = release
+	}
+	current := su.status.CurrentVersion
+	alreadyStaged := err == nil && su.status.State == UpdateStateStaged && su.status.Staged != nil &&
+		su.status.Staged.Version == release.Version
+	su.mutex.Unlock()
+	if err != nil {
+		return su.fail(err)
+	}
+
+	if newer, ok := CompareVersions(release.Version, current); !ok || newer <= 0 {
+		if !ok {
+			su.logger.WithField("current", current).WithField("latest", release.Version).
+				Debug("Running build has no release version; not updating")
+		}
+		su.setState(UpdateStateUpToDate, nil, "")
+		return nil
+	}
+	if alreadyStaged {
+		return nil
+	}
+
+	staged, err := su.stage(ctx, release)
+	if err != nil {
+		return su.fail(fmt.Errorf("release %s rejected: %w", release.Version, err))
+	}
+	su.setState(UpdateStateStaged, staged, "")
+
+	su.logger.WithField("version", release.Version).WithField("path", staged.Path).
+		Info("Verified update staged; restarting when idle")
+	su.report("Update staged", fmt.Sprintf("Version %s (%s channel) was downloaded and verified. The bot restarts into it when idle (restart window: %s).",
+		release.Version, su.config.UpdateChannel, su.config.UpdateRestartWindow))
+	return nil
+}
+
+func (su *SelfUpdater) setState(state string, staged *StagedRelease, lastError string) {
+	su.mutex.Lock()
+	defer su.mutex.Unlock()
+	su.status.State = state
+	su.status.Staged = staged
+	su.status.LastError = lastError
+}
+
+// fail records the error, reports it once and returns it. A staged update
+// stays staged, e.g. when a later check cannot reach the feed
+func (su *SelfUpdater) fail(err error) error {
+	su.mutex.Lock()
+	repeated := su.status.LastError == err.Error()
+	su.status.LastError = err.Error()
+	if su.status.State != UpdateStateStaged {
+		su.status.State = UpdateStateFailed
+	}
+	su.mutex.Unlock()
+
+	if !repeated {
+		su.report("Update failed", err.Error())
+	}
+	return err
+}
+
+func (su *SelfUpdater) report(title, message string) {
+	if su.notify != nil {
+		su.notify(title, message)
+	}
+}
+
+// fetchRelease returns the latest release of the configured channel
+func (su *SelfUpdater) fetchRelease(ctx context.Context) (*Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, su.config.UpdateFeedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := su.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch release feed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("release feed returned %s", resp.Status)
+	}
+
+	var feed ReleaseFeed
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxReleaseFeedSize)).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("invalid release feed: %w", err)
+	}
+	release, ok := feed.Channels[su.config.UpdateChannel]
+	if !ok || release == nil || release.Version == "" {
+		return nil, fmt.Errorf("release feed has no %q channel", su.config.UpdateChannel)
+	}
+	// The version is shown to admins and signed, never used as a path, but
+	// anything that isn't a plain release version is refused outright
+	if _, ok := parseReleaseVersion(release.Version); !ok || strings.ContainsAny(release.Version, "/\\") || strings.Contains(release.Version, "..") {
+		return nil, fmt.Errorf("release feed has an invalid version %q", release.Version)
+	}
+	return release, nil
+}
+
+// stage downloads the release binary for this platform to the staging
+// directory and keeps it only when its checksum is right and its signed
+// manifest names this version, channel and platform
+func (su *SelfUpdater) stage(ctx context.Context, release *Release) (*StagedRelease, error) {
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	binary, ok := release.Binaries[platform]
+	if !ok || binary == nil {
+		return nil, fmt.Errorf("no binary for %s", platform)
+	}
+	signature, err := base64.StdEncoding.DecodeString(binary.Signature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("invalid signature encoding")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, binary.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := su.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download returned %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxReleaseBinarySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	if len(data) > maxReleaseBinarySize {
+		return nil, fmt.Errorf("binary exceeds %d bytes", maxReleaseBinarySize)
+	}
+
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+	if !strings.EqualFold(checksum, binary.SHA256) {
+		return nil, fmt.Errorf("checksum mismatch")
+	}
+	manifest := ReleaseManifest(release.Version, su.config.UpdateChannel, platform, checksum)
+	if !ed25519.Verify(su.publicKey, manifest, signature) {
+		return nil, fmt.Errorf("signature verification failed for %s on the %s channel", release.Version, su.config.UpdateChannel)
+	}
+
+	if err := os.MkdirAll(su.config.UpdateStagingDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	path := filepath.Join(su.config.UpdateStagingDir, stagedBinaryName)
+	if err := WriteFileAtomic(path, data, 0755); err != nil {
+		return nil, fmt.Errorf("failed to stage binary: %w", err)
+	}
+	return &StagedRelease{Path: path, Version: release.Version, SHA256: checksum}, nil
+}
+
+// apply replaces the running executable with the staged binary, keeping
+// the previous one as <executable>.previous for a manual rollback
+func (su *SelfUpdater) apply() error {
+	status := su.Status()
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return err
+	}
+
+	staged := status.Staged
+	if staged == nil {
+		return errors.New("no staged release")
+	}
+	data, err := os.ReadFile(staged.Path)
+	if err != nil {
+		return err
+	}
+	// The staged file was verified when downloaded; make sure it was not swapped since
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != staged.SHA256 {
+		return errors.New("staged binary changed since it was verified")
+	}
+
+	current, err := os.ReadFile(executable)
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(executable+".previous", current, 0755); err != nil {
+		return fmt.Errorf("failed to keep previous binary: %w", err)
+	}
+	// Written next to the executable so the final rename stays on one filesystem
+	if err := WriteFileAtomic(executable, data, 0755); err != nil {
+		return err
+	}
+
+	su.setState(UpdateStateRestarting, staged, "")
+	su.logger.WithField("version", staged.Version).WithField("executable", executable).
+		Warn("Update installed; restarting")
+	return nil
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// releaseServer serves a feed offering binary as version on channel, with
+// the signature of the manifest signedVersion/signedChannel
+func releaseServer(t *testing.T, key ed25519.PrivateKey, binary []byte, version, channel, signedVersion, signedChannel string) *httptest.Server {
+	t.Helper()
+	sum := sha256.Sum256(binary)
+	checksum := hex.EncodeToString(sum[:])
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	signature := ed25519.Sign(key, ReleaseManifest(signedVersion, signedChannel, platform, checksum))
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	mux.HandleFunc("/feed.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ReleaseFeed{Channels: map[string]*Release{channel: {
+			Version: version,
+			Binaries: map[string]*ReleaseBinary{platform: {
+				URL:       server.URL + "/bot",
+				SHA256:    checksum,
+				Signature: base64.StdEncoding.EncodeToString(signature),
+			}},
+		}}})
+	})
+	mux.HandleFunc("/bot", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binary)
+	})
+	return server
+}
+
+func testUpdater(t *testing.T, feedURL string, key ed25519.PublicKey) *SelfUpdater {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	config := &Config{
+		UpdateFeedURL:    feedURL,
+		UpdateChannel:    "stable",
+		UpdatePublicKey:  hex.EncodeToString(key),
+		UpdateStagingDir: t.TempDir(),
+	}
+	su := NewSelfUpdater(config, &Logger{Logger: logger})
+	su.status.CurrentVersion = "v1.0.0"
+	return su
+}
+
+func TestSelfUpdateSignedManifest(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := []byte("release binary")
+
+	tests := []struct {
+		name          string
+		version       string
+		channel       string
+		signedVersion string
+		signedChannel string
+		wantStaged    bool
+	}{
+		{"signed release", "v1.1.0", "stable", "v1.1.0", "stable", true},
+		{"old build relabelled as newer", "v1.1.0", "stable", "v0.9.0", "stable", false},
+		{"beta build offered on stable", "v1.1.0", "stable", "v1.1.0", "beta", false},
+		{"version with a path", "v1.1.0+/../../bot", "stable", "v1.1.0+/../../bot", "stable", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := releaseServer(t, private, binary, tt.version, tt.channel, tt.signedVersion, tt.signedChannel)
+			su := testUpdater(t, server.URL+"/feed.json", public)
+
+			err := su.Check(context.Background())
+			status := su.Status()
+			if !tt.wantStaged {
+				if err == nil || status.Staged != nil {
+					t.Fatalf("release staged: err=%v staged=%+v", err, status.Staged)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.State != UpdateStateStaged || status.Staged == nil {
+				t.Fatalf("state %s, staged %+v", status.State, status.Staged)
+			}
+			if filepath.Base(status.Staged.Path) != stagedBinaryName || status.Staged.Version != tt.version {
+				t.Fatalf("staged %+v", status.Staged)
+			}
+		})
+	}
+}
+
+func TestSelfUpdateApplyChecksStagedHash(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := releaseServer(t, private, []byte("release binary"), "v1.1.0", "stable", "v1.1.0", "stable")
+	su := testUpdater(t, server.URL+"/feed.json", public)
+	if err := su.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A later check must not change what the staged file is checked against
+	su.mutex.Lock()
+	su.status.Latest = &Release{Version: "v9.9.9"}
+	su.mutex.Unlock()
+
+	staged := su.Status().Staged
+	if err := os.WriteFile(staged.Path, []byte("swapped binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := su.apply(); err == nil || !strings.Contains(err.Error(), "changed since it was verified") {
+		t.Fatalf("apply of a swapped binary: %v", err)
+	}
+}
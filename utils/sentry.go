@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// NewSentrySink initializes the Sentry client and returns a crash sink that
+// forwards crash reports to it
+func NewSentrySink(dsn, environment string) (CrashSink, error) {
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:         dsn,
+		Environment: environment,
+		Release:     BotVersion(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Sentry: %w", err)
+	}
+
+	return func(report *CrashReport) {
+		sentry.WithScope(func(scope *sentry.Scope) {
+			scope.SetLevel(sentry.LevelFatal)
+			scope.SetTag("component", report.Component)
+			scope.SetTag("crash_id", report.ID)
+			scope.SetTag("schema_version", fmt.Sprint(report.SchemaVersion))
+			if report.TaskID != "" {
+				scope.SetTag("task_id", report.TaskID)
+			}
+			scope.SetContext("crash", map[string]interface{}{
+				"stack":      report.Stack,
+				"goroutines": report.Goroutines,
+				"hostname":   report.Hostname,
+			})
+			if len(report.Context) > 0 {
+				scope.SetContext("task", report.Context)
+			}
+			sentry.CaptureException(fmt.Errorf("panic in %s: %s", report.Component, report.PanicValue))
+		})
+		sentry.Flush(5 * time.Second)
+	}, nil
+}
@@ -0,0 +1,347 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultSignatureDefinitions are the built-in definitions, written out as the
+// definitions file on first start and used whenever that file is unusable
+//
+//go:embed signature_definitions.json
+var defaultSignatureDefinitions []byte
+
+// defaultSignatureSource names the built-in definitions in reports
+const defaultSignatureSource = "built-in defaults"
+
+// suspiciousPatternActions are the actions a suspicious pattern may request
+var suspiciousPatternActions = map[string]bool{
+	"quarantine": true,
+	"reject":     true,
+	"monitor":    true,
+	"inspect":    true,
+}
+
+// signatureDefinitionsFile is the JSON layout of the definitions file
+type signatureDefinitionsFile struct {
+	Version            string                          `json:"version"`
+	AllowedSignatures  map[string][]signatureRuleEntry `json:"allowed_signatures"`
+	MalwareSignatures  []patternEntry                  `json:"malware_signatures"`
+	PolyglotPatterns   []polyglotEntry                 `json:"polyglot_patterns"`
+	SuspiciousPatterns []patternEntry                  `json:"suspicious_patterns"`
+}
+
+type signatureRuleEntry struct {
+	Name        string `json:"name"`
+	MagicHex    string `json:"magic_hex"`
+	Offset      int    `json:"offset"`
+	Extension   string `json:"extension"`
+	MimeType    string `json:"mime_type"`
+	Description string `json:"description"`
+	MinSize     int64  `json:"min_size"`
+	MaxSize     int64  `json:"max_size"`
+	Required    bool   `json:"required"`
+	Alternative bool   `json:"alternative"`
+}
+
+// patternEntry is a malware or suspicious pattern, given as text or hex bytes
+type patternEntry struct {
+	Name        string `json:"name"`
+	Pattern     string `json:"pattern"`
+	PatternHex  string `json:"pattern_hex"`
+	Offset      int    `json:"offset"` // -1 matches anywhere
+	Description string `json:"description"`
+	ThreatLevel string `json:"threat_level"` // Malware signatures only
+	Action      string `json:"action"`       // Suspicious patterns only
+}
+
+type polyglotEntry struct {
+	Name          string   `json:"name"`
+	SignaturesHex []string `json:"signatures_hex"`
+	Description   string   `json:"description"`
+	RiskLevel     string   `json:"risk_level"`
+}
+
+// SignatureSet is one validated version of the signature definitions
+type SignatureSet struct {
+	Version            string
+	Fingerprint        string // SHA-256 of the definitions file, changes with any edit
+	Source             string
+	LoadedAt           time.Time
+	AllowedSignatures  map[string][]FileSignatureRule
+	MalwareSignatures  []MalwareSignature
+	PolyglotPatterns   []PolyglotPattern
+	SuspiciousPatterns []SuspiciousPattern
+}
+
+// ParseSignatureDefinitions decodes and validates a definitions file; every
+// problem found is reported, not just the first
+func ParseSignatureDefinitions(data []byte, source string) (*SignatureSet, error) {
+	var file signatureDefinitionsFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode signature definitions: %w", err)
+	}
+
+	fingerprint := sha256.Sum256(data)
+	set := &SignatureSet{
+		Version:           strings.TrimSpace(file.Version),
+		Fingerprint:       hex.EncodeToString(fingerprint[:]),
+		Source:            source,
+		LoadedAt:          time.Now(),
+		AllowedSignatures: make(map[string][]FileSignatureRule),
+	}
+
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if set.Version == "" {
+		problem("version is required")
+	}
+	if len(file.AllowedSignatures) == 0 {
+		problem("allowed_signatures must define at least one file type")
+	}
+
+	for fileType, entries := range file.AllowedSignatures {
+		seen := make(map[string]bool)
+		for i, e := range entries {
+			where := fmt.Sprintf("allowed_signatures.%s[%d]", fileType, i)
+			if e.Name == "" {
+				problem("%s: name is required", where)
+			} else if seen[e.Name] {
+				problem("%s: duplicate name %q", where, e.Name)
+			}
+			seen[e.Name] = true
+
+			magic, err := hex.DecodeString(e.MagicHex)
+			if err != nil {
+				problem("%s: invalid magic_hex: %v", where, err)
+			}
+			if e.Offset < 0 {
+				problem("%s: offset must not be negative", where)
+			}
+			if e.MinSize < 0 || e.MaxSize < 0 || (e.MaxSize > 0 && e.MaxSize < e.MinSize) {
+				problem("%s: invalid size limits %d-%d", where, e.MinSize, e.MaxSize)
+			}
+
+			set.AllowedSignatures[fileType] = append(set.AllowedSignatures[fileType], FileSignatureRule{
+				Name:        e.Name,
+				Magic:       magic,
+				Offset:      e.Offset,
+				Extension:   e.Extension,
+				MimeType:    e.MimeType,
+				Description: e.Description,
+				MinSize:     e.MinSize,
+				MaxSize:     e.MaxSize,
+				Required:    e.Required,
+				Alternative: e.Alternative,
+			})
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, e := range file.MalwareSignatures {
+		where := fmt.Sprintf("malware_signatures[%d]", i)
+		checkPatternName(where, e.Name, seen, problem)
+		pattern := decodePattern(where, e, problem)
+		level, err := ParseThreatLevel(e.ThreatLevel)
+		if err != nil {
+			problem("%s: %v", where, err)
+		}
+		set.MalwareSignatures = append(set.MalwareSignatures, MalwareSignature{
+			Name:        e.Name,
+			Pattern:     pattern,
+			Offset:      e.Offset,
+			Description: e.Description,
+			ThreatLevel: level,
+		})
+	}
+
+	seen = make(map[string]bool)
+	for i, e := range file.PolyglotPatterns {
+		where := fmt.Sprintf("polyglot_patterns[%d]", i)
+		checkPatternName(where, e.Name, seen, problem)
+		if len(e.SignaturesHex) < 2 {
+			problem("%s: at least two signatures are required", where)
+		}
+		var signatures [][]byte
+		for j, s := range e.SignaturesHex {
+			signature, err := hex.DecodeString(s)
+			if err != nil || len(signature) == 0 {
+				problem("%s: invalid signatures_hex[%d]", where, j)
+			}
+			signatures = append(signatures, signature)
+		}
+		level, err := ParseThreatLevel(e.RiskLevel)
+		if err != nil {
+			problem("%s: %v", where, err)
+		}
+		set.PolyglotPatterns = append(set.PolyglotPatterns, PolyglotPattern{
+			Name:        e.Name,
+			Signatures:  signatures,
+			Description: e.Description,
+			RiskLevel:   level,
+		})
+	}
+
+	seen = make(map[string]bool)
+	for i, e := range file.SuspiciousPatterns {
+		where := fmt.Sprintf("suspicious_patterns[%d]", i)
+		checkPatternName(where, e.Name, seen, problem)
+		pattern := decodePattern(where, e, problem)
+		if !suspiciousPatternActions[e.Action] {
+			problem("%s: unknown action %q", where, e.Action)
+		}
+		set.SuspiciousPatterns = append(set.SuspiciousPatterns, SuspiciousPattern{
+			Name:        e.Name,
+			Pattern:     pattern,
+			Offset:      e.Offset,
+			Description: e.Description,
+			Action:      e.Action,
+		})
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid signature definitions: %s", strings.Join(problems, "; "))
+	}
+	return set, nil
+}
+
+// checkPatternName reports a missing or duplicate pattern name
+func checkPatternName(where, name string, seen map[string]bool, problem func(string, ...interface{})) {
+	if name == "" {
+		problem("%s: name is required", where)
+	} else if seen[name] {
+		problem("%s: duplicate name %q", where, name)
+	}
+	seen[name] = true
+}
+
+// decodePattern returns the bytes of a text or hex pattern
+func decodePattern(where string, e patternEntry, problem func(string, ...interface{})) []byte {
+	if e.Offset < -1 {
+		problem("%s: offset must be -1 (anywhere) or a position", where)
+	}
+	if (e.Pattern == "") == (e.PatternHex == "") {
+		problem("%s: exactly one of pattern and pattern_hex is required", where)
+		return nil
+	}
+	if e.Pattern != "" {
+		return []byte(e.Pattern)
+	}
+	pattern, err := hex.DecodeString(e.PatternHex)
+	if err != nil {
+		problem("%s: invalid pattern_hex: %v", where, err)
+	}
+	return pattern
+}
+
+// ParseThreatLevel parses a threat level name such as "HIGH"
+func ParseThreatLevel(s string) (ThreatLevel, error) {
+	for level := ThreatLevelSafe; level <= ThreatLevelCritical; level++ {
+		if strings.EqualFold(s, level.String()) {
+			return level, nil
+		}
+	}
+	return ThreatLevelSafe, fmt.Errorf("unknown threat level %q", s)
+}
+
+// SignatureRegistry holds the active signature definitions, loaded from the
+// definitions file at startup and replaced atomically on reload
+type SignatureRegistry struct {
+	path    string
+	logger  *Logger
+	mutex   sync.RWMutex
+	current *SignatureSet
+}
+
+// NewSignatureRegistry loads the definitions file at path. A missing file is
+// created from the built-in definitions; an invalid one is reported and the
+// built-in definitions are used until it is fixed and reloaded
+func NewSignatureRegistry(path string, logger *Logger) *SignatureRegistry {
+	sr := &SignatureRegistry{path: path, logger: logger}
+
+	defaults, err := ParseSignatureDefinitions(defaultSignatureDefinitions, defaultSignatureSource)
+	if err != nil {
+		panic(fmt.Sprintf("built-in signature definitions are invalid: %v", err))
+	}
+	sr.current = defaults
+
+	if path == "" {
+		return sr
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := writeDefaultSignatureDefinitions(path); err != nil {
+			logger.WithError(err).WithField("path", path).Warn("Failed to write default signature definitions")
+			return sr
+		}
+		logger.WithField("path", path).Info("Wrote default signature definitions")
+	}
+
+	if _, err := sr.Reload(); err != nil {
+		logger.WithError(err).WithField("path", path).Error("Failed to load signature definitions, using built-in defaults")
+	}
+	return sr
+}
+
+// writeDefaultSignatureDefinitions creates the definitions file from the built-in definitions
+func writeDefaultSignatureDefinitions(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, defaultSignatureDefinitions, 0644)
+}
+
+// Current returns the active definitions; callers must not modify them
+func (sr *SignatureRegistry) Current() *SignatureSet {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
+	return sr.current
+}
+
+// Path returns the definitions file path
+func (sr *SignatureRegistry) Path() string {
+	return sr.path
+}
+
+// Reload re-reads the definitions file. Invalid definitions are rejected and
+// the active ones stay in place
+func (sr *SignatureRegistry) Reload() (*SignatureSet, error) {
+	if sr.path == "" {
+		return nil, fmt.Errorf("no signature definitions file configured")
+	}
+
+	data, err := os.ReadFile(sr.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature definitions: %w", err)
+	}
+
+	set, err := ParseSignatureDefinitions(data, sr.path)
+	if err != nil {
+		return nil, err
+	}
+
+	sr.mutex.Lock()
+	previous := sr.current
+	sr.current = set
+	sr.mutex.Unlock()
+
+	sr.logger.WithField("version", set.Version).
+		WithField("previous_version", previous.Version).
+		WithField("path", sr.path).
+		Info("Signature definitions loaded")
+	return set, nil
+}
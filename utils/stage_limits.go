@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Pipeline stages with a concurrency limit
+const (
+	StageDownloads   = "downloads"
+	StageExtractions = "extractions"
+	StageConversions = "conversions"
+)
+
+// MaxStageConcurrency is the highest concurrency any stage may be given; it
+// is also how many download workers are started, most of them idle unless
+// the download limit is raised
+const MaxStageConcurrency = 8
+
+// StageLimits holds how many downloads, extractions and conversions may run
+// at once. Downloads take a slot per task, so a change applies as soon as
+// running tasks finish; extraction and conversion read their limit at the
+// start of each pass
+type StageLimits struct {
+	mu     sync.Mutex
+	limits map[string]int
+	active map[string]int
+}
+
+// NewStageLimits creates limits for the three stages
+func NewStageLimits(downloads, extractions, conversions int) *StageLimits {
+	return &StageLimits{
+		limits: map[string]int{
+			StageDownloads:   downloads,
+			StageExtractions: extractions,
+			StageConversions: conversions,
+		},
+		active: make(map[string]int),
+	}
+}
+
+// Limit returns a stage's limit, 1 for an unknown stage or nil limits
+func (sl *StageLimits) Limit(stage string) int {
+	if sl == nil {
+		return 1
+	}
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if limit, ok := sl.limits[stage]; ok {
+		return limit
+	}
+	return 1
+}
+
+// Set changes a stage's limit and returns the previous one
+func (sl *StageLimits) Set(stage string, limit int) (int, error) {
+	if limit < 1 || limit > MaxStageConcurrency {
+		return 0, fmt.Errorf("limit must be between 1 and %d", MaxStageConcurrency)
+	}
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	previous, ok := sl.limits[stage]
+	if !ok {
+		return 0, fmt.Errorf("unknown stage %q", stage)
+	}
+	sl.limits[stage] = limit
+	return previous, nil
+}
+
+// TryAcquire takes a slot of the stage if one is free; the caller must
+// Release it when done. Nil limits always grant a slot
+func (sl *StageLimits) TryAcquire(stage string) bool {
+	if sl == nil {
+		return true
+	}
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if sl.active[stage] >= sl.limits[stage] {
+		return false
+	}
+	sl.active[stage]++
+	return true
+}
+
+// Release returns a slot taken with TryAcquire
+func (sl *StageLimits) Release(stage string) {
+	if sl == nil {
+		return
+	}
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if sl.active[stage] > 0 {
+		sl.active[stage]--
+	}
+}
+
+// Active returns how many slots of a stage are taken
+func (sl *StageLimits) Active(stage string) int {
+	if sl == nil {
+		return 0
+	}
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	return sl.active[stage]
+}
+
+// Stages returns the names of the limited stages, sorted
+func (sl *StageLimits) Stages() []string {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	stages := make([]string, 0, len(sl.limits))
+	for stage := range sl.limits {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+	return stages
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MessageSender sends messages, documents and other requests to Telegram
+type MessageSender interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error)
+}
+
+// FileFetcher looks up uploaded files, which the download workers then read
+// from the Local Bot API server's directory
+type FileFetcher interface {
+	GetFile(config tgbotapi.FileConfig) (tgbotapi.File, error)
+}
+
+// TelegramClient is the part of *tgbotapi.BotAPI the bot calls, so
+// integration tests can substitute a fake client (see bot/bottest) and run
+// without network access or a bot token
+type TelegramClient interface {
+	MessageSender
+	FileFetcher
+	StopReceivingUpdates()
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MaxTelegramRetryAfter caps how long a single flood wait may block a caller
+const MaxTelegramRetryAfter = 5 * time.Minute
+
+// retryAfterPattern matches the wait in descriptions such as
+// "Too Many Requests: retry after 35", for errors that lost their parameters
+var retryAfterPattern = regexp.MustCompile(`(?i)retry after (\d+)`)
+
+// TelegramRetryAfter returns the wait Telegram asked for in a 429 response,
+// capped at MaxTelegramRetryAfter. It looks through wrapped errors, including
+// CategorizedError
+func TelegramRetryAfter(err error) (time.Duration, bool) {
+	seconds := telegramRetryAfterSeconds(err)
+	if seconds <= 0 {
+		return 0, false
+	}
+	wait := time.Duration(seconds) * time.Second
+	if wait > MaxTelegramRetryAfter {
+		wait = MaxTelegramRetryAfter
+	}
+	return wait, true
+}
+
+func telegramRetryAfterSeconds(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter
+	}
+	var apiErrValue tgbotapi.Error
+	if errors.As(err, &apiErrValue) && apiErrValue.RetryAfter > 0 {
+		return apiErrValue.RetryAfter
+	}
+
+	if match := retryAfterPattern.FindStringSubmatch(err.Error()); match != nil {
+		if seconds, convErr := strconv.Atoi(match[1]); convErr == nil {
+			return seconds
+		}
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Traffic is the process-wide network accounting that the health monitor
+// reports in the system metrics
+var Traffic = &TrafficAccounts{}
+
+// TrafficAccounts are the byte counts kept for the whole process
+type TrafficAccounts struct {
+	// BotAPI is the HTTP traffic exchanged with the (Local) Bot API server
+	BotAPI TrafficMeter
+	// Downloaded counts the bytes of files fetched for tasks
+	Downloaded atomic.Int64
+}
+
+// TrafficMeter counts the bytes and requests of HTTP exchanges; request
+// bytes are the bodies sent, received bytes the response bodies read
+type TrafficMeter struct {
+	sent     atomic.Int64
+	received atomic.Int64
+	requests atomic.Int64
+}
+
+// Sent returns the request bytes counted so far
+func (m *TrafficMeter) Sent() int64 {
+	return m.sent.Load()
+}
+
+// Received returns the response bytes counted so far
+func (m *TrafficMeter) Received() int64 {
+	return m.received.Load()
+}
+
+// Requests returns the number of requests counted so far
+func (m *TrafficMeter) Requests() int64 {
+	return m.requests.Load()
+}
+
+// Total returns the bytes counted in both directions
+func (m *TrafficMeter) Total() int64 {
+	return m.Sent() + m.Received()
+}
+
+// Client wraps an HTTP client so its exchanges are counted by m
+func (m *TrafficMeter) Client(client tgbotapi.HTTPClient) tgbotapi.HTTPClient {
+	return &meteredClient{client: client, meter: m}
+}
+
+// meteredClient counts the traffic of the requests it passes on
+type meteredClient struct {
+	client tgbotapi.HTTPClient
+	meter  *TrafficMeter
+}
+
+func (c *meteredClient) Do(req *http.Request) (*http.Response, error) {
+	c.meter.requests.Add(1)
+	if req.ContentLength > 0 {
+		c.meter.sent.Add(req.ContentLength)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &meteredBody{ReadCloser: resp.Body, meter: c.meter}
+	return resp, nil
+}
+
+// meteredBody counts the response bytes as they are read
+type meteredBody struct {
+	io.ReadCloser
+	meter *TrafficMeter
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.meter.received.Add(int64(n))
+	return n, err
+}
+
+// MeteredFetcher returns a fetcher whose requests are also counted by meter,
+// to attribute them to one task. Only a *tgbotapi.BotAPI can be metered; any
+// other fetcher, such as a test fake, is returned unchanged
+func MeteredFetcher(fetcher FileFetcher, meter *TrafficMeter) FileFetcher {
+	api, ok := fetcher.(*tgbotapi.BotAPI)
+	if !ok {
+		return fetcher
+	}
+	metered := *api
+	metered.Client = meter.Client(api.Client)
+	return &metered
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"unsafe"
+)
+
+// Pages are released from the page cache in chunks of this size while streaming
+const dropCacheChunk = 64 * 1024 * 1024
+
+// Alignment required for O_DIRECT buffers
+const directIOAlignment = 4096
+
+// IOTuning controls buffering and kernel cache hints for large file operations
+type IOTuning struct {
+	BufferSize int               // Read/write buffer size in bytes
+	ReadAhead  int64             // Bytes to prefetch ahead of the reader (0 = kernel default)
+	Sequential bool              // Advise the kernel that access is sequential
+	DropCache  bool              // Release processed pages from the page cache
+	DirectIO   bool              // Read with O_DIRECT where supported (falls back silently)
+	Limiter    *BandwidthLimiter // Throttles the copy loop; nil for no limit
+}
+
+// DefaultIOTuning returns settings suited to hashing and moving multi-GB archives
+func DefaultIOTuning() IOTuning {
+	return IOTuning{
+		BufferSize: 1024 * 1024,
+		ReadAhead:  8 * 1024 * 1024,
+		Sequential: true,
+		DropCache:  true,
+	}
+}
+
+// IOTuning returns the I/O tuning described by the configuration
+func (c *Config) IOTuning() IOTuning {
+	tuning := DefaultIOTuning()
+	if c.IOBufferSizeKB > 0 {
+		tuning.BufferSize = c.IOBufferSizeKB * 1024
+	}
+	tuning.ReadAhead = int64(c.IOReadAheadMB) * 1024 * 1024
+	tuning.DropCache = c.IODropPageCache
+	tuning.DirectIO = c.IODirectIO
+	return tuning
+}
+
+// OpenForRead opens a file for streaming reads and applies access hints
+func OpenForRead(path string, tuning IOTuning) (*os.File, error) {
+	if tuning.DirectIO {
+		if f, err := openDirect(path); err == nil {
+			return f, nil
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if tuning.Sequential {
+		adviseSequential(f)
+	}
+	if tuning.ReadAhead > 0 {
+		adviseWillNeed(f, 0, tuning.ReadAhead)
+	}
+
+	return f, nil
+}
+
+// CopyTuned streams src into dst using the tuned buffer, prefetching ahead and
+// dropping already-consumed pages so large copies don't evict the page cache
+func CopyTuned(dst io.Writer, src *os.File, tuning IOTuning) (int64, error) {
+	buf := alignedBuffer(tuning.BufferSize)
+
+	var written int64
+	var dropped int64
+	for {
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			tuning.Limiter.Wait(n)
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return written, err
+			}
+			written += int64(n)
+
+			if tuning.ReadAhead > 0 && written%tuning.ReadAhead < int64(n) {
+				adviseWillNeed(src, written, tuning.ReadAhead)
+			}
+			if tuning.DropCache && written-dropped >= dropCacheChunk {
+				adviseDontNeed(src, dropped, written-dropped)
+				dropped = written
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return written, readErr
+		}
+	}
+
+	if tuning.DropCache && written > dropped {
+		adviseDontNeed(src, dropped, written-dropped)
+	}
+
+	return written, nil
+}
+
+// HashFileTuned calculates the SHA-256 of a file using tuned I/O and returns the bytes read
+func HashFileTuned(path string, tuning IOTuning) (string, int64, error) {
+	f, err := OpenForRead(path, tuning)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	n, err := CopyTuned(hasher, f, tuning)
+	if err != nil {
+		return "", n, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return fmt.Sprintf("%x", hasher.Sum(nil)), n, nil
+}
+
+// CopyFileTuned copies src to dst with tuned I/O, syncing the destination and
+// releasing its pages from the cache afterwards when DropCache is set
+func CopyFileTuned(src, dst string, tuning IOTuning) (int64, error) {
+	in, err := OpenForRead(src, tuning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create destination file: %w", err)
+	}
+
+	n, err := CopyTuned(out, in, tuning)
+	if err != nil {
+		out.Close()
+		return n, fmt.Errorf("failed to copy file contents: %w", err)
+	}
+
+	if tuning.DropCache {
+		// Dirty pages can only be dropped once they are on disk
+		if err := out.Sync(); err != nil {
+			out.Close()
+			return n, fmt.Errorf("failed to sync destination file: %w", err)
+		}
+		adviseDontNeed(out, 0, n)
+	}
+
+	if err := out.Close(); err != nil {
+		return n, fmt.Errorf("failed to close destination file: %w", err)
+	}
+
+	return n, nil
+}
+
+// alignedBuffer returns a buffer whose start and length satisfy O_DIRECT alignment
+func alignedBuffer(size int) []byte {
+	if size <= 0 {
+		size = DefaultIOTuning().BufferSize
+	}
+	size = (size + directIOAlignment - 1) / directIOAlignment * directIOAlignment
+
+	raw := make([]byte, size+directIOAlignment)
+	offset := 0
+	if rem := int(uintptr(unsafe.Pointer(&raw[0])) % directIOAlignment); rem != 0 {
+		offset = directIOAlignment - rem
+	}
+	return raw[offset : offset+size]
+}
+
+// DropFileCache syncs a file and asks the kernel to release its cached pages
+func DropFileCache(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	adviseDontNeed(f, 0, 0)
+	return nil
+}
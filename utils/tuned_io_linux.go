@@ -0,0 +1,26 @@
+//go:build linux
+
+package utils
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openDirect opens a file for reading with O_DIRECT, bypassing the page cache
+func openDirect(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDONLY|unix.O_DIRECT, 0)
+}
+
+func adviseSequential(f *os.File) {
+	unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_SEQUENTIAL)
+}
+
+func adviseWillNeed(f *os.File, offset, length int64) {
+	unix.Fadvise(int(f.Fd()), offset, length, unix.FADV_WILLNEED)
+}
+
+func adviseDontNeed(f *os.File, offset, length int64) {
+	unix.Fadvise(int(f.Fd()), offset, length, unix.FADV_DONTNEED)
+}
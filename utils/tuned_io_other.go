@@ -0,0 +1,19 @@
+//go:build !linux
+
+package utils
+
+import (
+	"fmt"
+	"os"
+)
+
+// openDirect is unsupported outside Linux; callers fall back to buffered reads
+func openDirect(path string) (*os.File, error) {
+	return nil, fmt.Errorf("direct I/O not supported on this platform")
+}
+
+func adviseSequential(f *os.File) {}
+
+func adviseWillNeed(f *os.File, offset, length int64) {}
+
+func adviseDontNeed(f *os.File, offset, length int64) {}
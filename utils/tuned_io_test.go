@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchFileSize is large enough for read-ahead and cache drops to take effect
+const benchFileSize = 32 * 1024 * 1024
+
+// writeRandomFile creates a file of size random bytes in dir
+func writeRandomFile(tb testing.TB, dir string, size int) string {
+	tb.Helper()
+	content := make([]byte, size)
+	if _, err := rand.Read(content); err != nil {
+		tb.Fatal(err)
+	}
+	path := filepath.Join(dir, "input.bin")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+// hashFileUntuned is the plain io.Copy path HashFileTuned replaced
+func hashFileUntuned(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	n, err := io.Copy(hasher, f)
+	return fmt.Sprintf("%x", hasher.Sum(nil)), n, err
+}
+
+// copyFileUntuned is the plain io.Copy path CopyFileTuned replaced
+func copyFileUntuned(src, dst string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// The benchmarks compare like for like only if both paths produce the same output
+func TestTunedIOMatchesUntuned(t *testing.T) {
+	dir := t.TempDir()
+	src := writeRandomFile(t, dir, 3*1024*1024+123)
+
+	wantHash, wantSize, err := hashFileUntuned(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, size, err := HashFileTuned(src, DefaultIOTuning())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != wantHash || size != wantSize {
+		t.Fatalf("HashFileTuned = %s, %d; want %s, %d", hash, size, wantHash, wantSize)
+	}
+
+	dst := filepath.Join(dir, "copy.bin")
+	if _, err := CopyFileTuned(src, dst, DefaultIOTuning()); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := os.ReadFile(src)
+	got, _ := os.ReadFile(dst)
+	if !bytes.Equal(got, want) {
+		t.Fatal("CopyFileTuned output differs from its input")
+	}
+}
+
+func BenchmarkHashFile(b *testing.B) {
+	src := writeRandomFile(b, b.TempDir(), benchFileSize)
+	strategies := []struct {
+		name string
+		hash func(path string) (string, int64, error)
+	}{
+		{"untuned", hashFileUntuned},
+		{"tuned", func(path string) (string, int64, error) { return HashFileTuned(path, DefaultIOTuning()) }},
+	}
+	for _, strategy := range strategies {
+		b.Run(strategy.name, func(b *testing.B) {
+			b.SetBytes(benchFileSize)
+			for i := 0; i < b.N; i++ {
+				if _, _, err := strategy.hash(src); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCopyFile(b *testing.B) {
+	dir := b.TempDir()
+	src := writeRandomFile(b, dir, benchFileSize)
+	dst := filepath.Join(dir, "copy.bin")
+	strategies := []struct {
+		name string
+		copy func(src, dst string) (int64, error)
+	}{
+		{"untuned", copyFileUntuned},
+		{"tuned", func(src, dst string) (int64, error) { return CopyFileTuned(src, dst, DefaultIOTuning()) }},
+	}
+	for _, strategy := range strategies {
+		b.Run(strategy.name, func(b *testing.B) {
+			b.SetBytes(benchFileSize)
+			for i := 0; i < b.N; i++ {
+				if _, err := strategy.copy(src, dst); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
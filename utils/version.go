@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Release builds set these with -ldflags "-X telegram-archive-bot/utils.buildVersion=v1.5.0 ..."
+// (see scripts/build-production.sh); otherwise they come from the Go build info
+var (
+	buildVersion string
+	buildCommit  string
+	buildTime    string
+)
+
+// BuildInfo identifies the code a binary was built from, for health output,
+// crash reports and backups
+type BuildInfo struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit,omitempty"`
+	BuiltAt   time.Time `json:"built_at,omitempty"` // Build time, or the commit time when not set at build
+	Modified  bool      `json:"modified,omitempty"` // Built from a working tree with uncommitted changes
+	GoVersion string    `json:"go_version"`
+}
+
+// CurrentBuild returns the build info of the running binary
+func CurrentBuild() BuildInfo {
+	build := BuildInfo{
+		Version:   "unknown",
+		GoVersion: runtime.Version(),
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		build.Version = info.Main.Version
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				build.Commit = setting.Value
+			case "vcs.time":
+				build.BuiltAt, _ = time.Parse(time.RFC3339, setting.Value)
+			case "vcs.modified":
+				build.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	if buildVersion != "" {
+		build.Version = buildVersion
+	}
+	if buildCommit != "" {
+		build.Commit = buildCommit
+	}
+	if buildTime != "" {
+		if t, err := time.Parse(time.RFC3339, buildTime); err == nil {
+			build.BuiltAt = t
+		}
+	}
+	return build
+}
+
+// ShortCommit returns the first 12 characters of the commit
+func (b BuildInfo) ShortCommit() string {
+	if len(b.Commit) > 12 {
+		return b.Commit[:12]
+	}
+	return b.Commit
+}
+
+// String renders the version with the short commit, e.g. "v1.5.0+0123456789ab"
+func (b BuildInfo) String() string {
+	version := b.Version
+	if commit := b.ShortCommit(); len(commit) == 12 {
+		version += "+" + commit
+	}
+	if b.Modified {
+		version += "-dirty"
+	}
+	return version
+}
+
+// BotVersion identifies the running binary by version and VCS revision
+func BotVersion() string {
+	return CurrentBuild().String()
+}
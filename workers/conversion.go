@@ -2,6 +2,7 @@ package workers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,6 +14,9 @@ import (
 	"telegram-archive-bot/utils"
 )
 
+// ConversionOperation is the queued operation name for conversions deferred by an outage
+const ConversionOperation = "file_conversion"
+
 type ConversionWorker struct {
 	config             *utils.Config
 	logger             *utils.Logger
@@ -27,11 +31,17 @@ type ConversionWorker struct {
 func NewConversionWorker(config *utils.Config, logger *utils.Logger, taskStore *storage.TaskStore) *ConversionWorker {
 	degradationManager := utils.NewGracefulDegradationManager(logger)
 	
-	// Register convert.go and dependencies
-	degradationManager.RegisterDependency("convert", "executable", 2*time.Minute, utils.FallbackQueue)
-	degradationManager.RegisterDependency("go", "executable", 5*time.Minute, utils.FallbackManual)
+	// Register the compiled-in converter and its dependencies
+	degradationManager.RegisterDependency("convert", "builtin", 2*time.Minute, utils.FallbackQueue)
 	degradationManager.RegisterDependency("app/extraction/files/pass", "directory", 1*time.Minute, utils.FallbackManual)
 	
+	// Try to repair missing dependencies before degrading, audit-logging each attempt
+	degradationManager.RegisterDefaultRecoveryActions(config)
+	degradationManager.SetRecoveryAuditor(storage.NewAdminAuditLogger(taskStore.GetDB(), logger).LogRecoveryAttempt)
+	
+	// Keep operations deferred by an outage across restarts
+	degradationManager.SetOperationStore(taskStore)
+	
 	return &ConversionWorker{
 		config:             config,
 		logger:             logger,
@@ -49,9 +59,17 @@ func (cw *ConversionWorker) StartMonitoring(ctx context.Context) {
 	cw.degradationManager.StartMonitoring(ctx)
 }
 
-// StopMonitoring stops dependency monitoring
+// StopMonitoring stops dependency monitoring and waits for a running check
 func (cw *ConversionWorker) StopMonitoring() {
-	cw.degradationManager.StopMonitoring()
+	if err := cw.degradationManager.Shutdown(context.Background()); err != nil {
+		cw.logger.WithError(err).Warn("Failed to stop dependency monitoring")
+	}
+}
+
+// SetQueuedOperationHandler sets how file conversions queued during an outage of
+// their dependencies are re-dispatched once they recover
+func (cw *ConversionWorker) SetQueuedOperationHandler(handler utils.OperationHandler) {
+	cw.degradationManager.RegisterOperationHandler(ConversionOperation, handler)
 }
 
 // GetDependencyHealth returns health status of conversion dependencies
@@ -73,6 +91,10 @@ func (cw *ConversionWorker) Process(ctx context.Context, job Job) error {
 	// Generate output filename with task ID for uniqueness
 	outputFileName := fmt.Sprintf("output_%s_%s.txt", task.ID, time.Now().Format("20060102_150405"))
 
+	if err := utils.Faults.Inject(conversionCtx, utils.FaultConvert); err != nil {
+		return fmt.Errorf("conversion failed: %w", err)
+	}
+
 	// Execute convert.go subprocess
 	if err := cw.runConversion(conversionCtx, task, outputFileName); err != nil {
 		return fmt.Errorf("conversion failed: %w", err)
@@ -102,11 +124,12 @@ func (cw *ConversionWorker) runConversion(ctx context.Context, task *models.Task
 		
 		parameters := map[string]interface{}{
 			"task_id":     task.ID,
+			"stage":       "conversion",
 			"output_file": outputFileName,
 			"file_name":   task.FileName,
 		}
 		
-		degradationErr := cw.degradationManager.HandleUnavailableDependency("convert", "file_conversion", parameters)
+		degradationErr := cw.degradationManager.HandleUnavailableDependency("convert", ConversionOperation, parameters)
 		if degradationErr != nil {
 			return fmt.Errorf("graceful degradation for convert.go: %w", degradationErr)
 		}
@@ -144,9 +167,17 @@ func (cw *ConversionWorker) runConversion(ctx context.Context, task *models.Task
 			// Set environment variables for the conversion function
 			os.Setenv("CONVERT_INPUT_DIR", "files/pass")
 			os.Setenv("CONVERT_OUTPUT_FILE", filepath.Join("files/txt", outputFileName))
+			os.Setenv(convert.ManifestEnvFile, cw.manifestPath(task))
+			convert.OutputSettings{
+				MaxLines:     cw.config.OutputChunkMaxLines,
+				MaxBytes:     cw.config.OutputChunkMaxSize,
+				NameTemplate: cw.config.OutputNameTemplate,
+				Task:         task.ID,
+				Tag:          cw.config.OutputTag,
+			}.Apply()
 			
 			// Call the conversion function directly
-			err := convert.ConvertTextFiles()
+			err := convert.ConvertTextFiles(1)
 			done <- err
 		}()
 
@@ -159,8 +190,9 @@ func (cw *ConversionWorker) runConversion(ctx context.Context, task *models.Task
 					Error("conversion function execution failed")
 				
 				// Mark dependency as potentially unavailable for degradation handling
-				cw.degradationManager.HandleUnavailableDependency("convert", "file_conversion", map[string]interface{}{
+				cw.degradationManager.HandleUnavailableDependency("convert", ConversionOperation, map[string]interface{}{
 					"task_id": task.ID,
+					"stage":   "conversion",
 					"error":   err.Error(),
 				})
 				
@@ -181,50 +213,55 @@ func (cw *ConversionWorker) runConversion(ctx context.Context, task *models.Task
 	return nil
 }
 
+// manifestPath is where the convert stage writes the result manifest of a task,
+// relative to the extraction directory
+func (cw *ConversionWorker) manifestPath(task *models.Task) string {
+	return filepath.Join("files", "progress", fmt.Sprintf("manifest_%s.json", task.ID))
+}
+
+// processConversionResults reads the result manifest of the conversion and
+// stores it on the task
 func (cw *ConversionWorker) processConversionResults(task *models.Task, outputFileName string) error {
-	// Check if main output file was created
-	outputFilePath := filepath.Join(cw.extractionDir, outputFileName)
-	if _, err := os.Stat(outputFilePath); err != nil {
-		cw.logger.WithField("task_id", task.ID).
-			WithField("output_file", outputFilePath).
-			Warn("Main output file not found, checking special directories")
-	} else {
-		cw.logger.WithField("task_id", task.ID).
-			WithField("output_file", outputFilePath).
-			Info("Main output file created successfully")
+	manifestPath := filepath.Join(cw.extractionDir, cw.manifestPath(task))
+	defer os.Remove(manifestPath)
+
+	manifest, err := convert.ReadManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		return fmt.Errorf("convert stage wrote no result manifest")
 	}
 
-	// Check for special output directories as per PRD
-	specialDirs := map[string]string{
-		"done":    "files/done",     // Context for found search strings
-		"errors":  "files/errors",   // Quarantined problematic files
-		"etbanks": "files/etbanks",  // Files with search strings but no credentials
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode result manifest: %w", err)
 	}
 
-	results := make(map[string]int)
-	for dirName, dirPath := range specialDirs {
-		fullPath := filepath.Join(cw.extractionDir, dirPath)
-		fileCount, err := cw.countFilesInDirectory(fullPath)
-		if err != nil {
-			cw.logger.WithField("task_id", task.ID).
-				WithField("directory", fullPath).
-				WithError(err).
-				Warn("Failed to check special directory")
-			continue
-		}
-		results[dirName] = fileCount
-		
-		if fileCount > 0 {
-			cw.logger.WithField("task_id", task.ID).
-				WithField("directory", dirName).
-				WithField("file_count", fileCount).
-				Info("Files found in special directory")
-		}
+	result := &storage.ConversionResult{
+		StartedAt:      manifest.StartedAt,
+		FinishedAt:     manifest.FinishedAt,
+		FilesProcessed: manifest.FilesProcessed,
+		FilesProduced:  len(manifest.FilesProduced),
+		Credentials:    manifest.Credentials,
+		Domains:        manifest.Domains,
+		Warnings:       len(manifest.Warnings) + manifest.DroppedWarnings,
+		Manifest:       string(data),
+		RecordedAt:     time.Now(),
+	}
+	if err := cw.taskStore.SaveConversionResult(result, []string{task.ID}); err != nil {
+		cw.logger.WithField("task_id", task.ID).
+			WithError(err).
+			Warn("Failed to store conversion result")
 	}
 
 	// Log conversion results summary
 	cw.logger.WithField("task_id", task.ID).
-		WithField("results", results).
+		WithField("files_processed", manifest.FilesProcessed).
+		WithField("credentials", manifest.Credentials).
+		WithField("domains", manifest.Domains).
+		WithField("outcomes", manifest.Outcomes).
+		WithField("warnings", result.Warnings).
 		Info("Conversion results processed")
 
 	// Clean up processed files from files/pass directory
@@ -237,26 +274,6 @@ func (cw *ConversionWorker) processConversionResults(task *models.Task, outputFi
 	return nil
 }
 
-func (cw *ConversionWorker) countFilesInDirectory(dirPath string) (int, error) {
-	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-		return 0, nil
-	}
-
-	files, err := filepath.Glob(filepath.Join(dirPath, "*"))
-	if err != nil {
-		return 0, err
-	}
-
-	count := 0
-	for _, file := range files {
-		if info, err := os.Stat(file); err == nil && !info.IsDir() {
-			count++
-		}
-	}
-
-	return count, nil
-}
-
 func (cw *ConversionWorker) cleanupProcessedFiles(task *models.Task) error {
 	passDir := filepath.Join(cw.extractionDir, "files", "pass")
 	
@@ -2,11 +2,12 @@ package workers
 
 import (
 	"context"
-	"crypto/sha256"
+	"database/sql"
+	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"runtime/debug"
 	"strings"
 	"time"
 
@@ -17,30 +18,25 @@ import (
 )
 
 type DownloadWorker struct {
-	bot               *tgbotapi.BotAPI
+	bot               utils.FileFetcher
 	config            *utils.Config
 	logger            *utils.Logger
-	taskStore         *storage.TaskStore
+	taskStore         DownloadStore
 	timeout           time.Duration
 	maxRetries        int
 	securityValidator *utils.SecurityValidator
 	securityAudit     *storage.SecurityAuditLogger
 	tempManager       *utils.SecureTempManager
 	botAPIPathManager *utils.BotAPIPathManager
+	ioTuning          utils.IOTuning
+	dispatch          *storage.TaskDispatch
+	limits            *utils.StageLimits
 }
 
-func NewDownloadWorker(bot *tgbotapi.BotAPI, config *utils.Config, logger *utils.Logger, taskStore *storage.TaskStore) *DownloadWorker {
-	// Get database connection from TaskStore for security auditing
-	db := taskStore.GetDB()
-	
-	// Initialize Bot API path manager for dynamic path detection
-	botAPIPathManager := utils.NewBotAPIPathManager(config, logger)
-	
-	// Ensure Local Bot API directories exist
-	if err := botAPIPathManager.EnsureDirectories(); err != nil {
-		logger.WithError(err).Fatal("Failed to ensure Local Bot API directories")
-	}
-	
+// NewDownloadWorker creates a download worker. botAPIPathManager locates the
+// Local Bot API directories, which must exist already (see EnsureDirectories);
+// auditDB holds the security audit log
+func NewDownloadWorker(bot utils.FileFetcher, botAPIPathManager *utils.BotAPIPathManager, config *utils.Config, logger *utils.Logger, taskStore DownloadStore, auditDB *sql.DB) *DownloadWorker {
 	// Initialize secure temporary file manager using Local Bot API temp path
 	tempPath, err := botAPIPathManager.GetTempPath()
 	if err != nil {
@@ -52,6 +48,10 @@ func NewDownloadWorker(bot *tgbotapi.BotAPI, config *utils.Config, logger *utils
 		logger.WithError(err).Fatal("Failed to initialize secure temp manager")
 	}
 	
+	// Skip full rescans of content already scanned with the current signature definitions
+	securityValidator := utils.NewSecurityValidator(logger, config)
+	securityValidator.SetScanCache(taskStore, config.ScanCacheTTL)
+	
 	return &DownloadWorker{
 		bot:               bot,
 		config:            config,
@@ -59,10 +59,11 @@ func NewDownloadWorker(bot *tgbotapi.BotAPI, config *utils.Config, logger *utils
 		taskStore:         taskStore,
 		timeout:           10 * time.Minute,
 		maxRetries:        3,
-		securityValidator: utils.NewSecurityValidator(logger, config),
-		securityAudit:     storage.NewSecurityAuditLogger(db, logger),
+		securityValidator: securityValidator,
+		securityAudit:     storage.NewSecurityAuditLogger(auditDB, logger),
 		tempManager:       tempManager,
 		botAPIPathManager: botAPIPathManager,
+		ioTuning:          config.IOTuning(),
 	}
 }
 
@@ -86,22 +87,28 @@ func (dw *DownloadWorker) Process(ctx context.Context, job Job) error {
 	defer cancel()
 
 	// Download file with retries
+	traffic := &downloadTraffic{}
 	var downloadErr error
 	for attempt := 1; attempt <= dw.maxRetries; attempt++ {
 		dw.logger.WithField("task_id", task.ID).
 			WithField("attempt", attempt).
 			Debug("Attempting file download")
 
-		if err := dw.downloadFile(downloadCtx, task); err != nil {
+		if err := dw.downloadFile(downloadCtx, task, traffic); err != nil {
 			downloadErr = err
 			dw.logger.WithField("task_id", task.ID).
 				WithField("attempt", attempt).
 				WithError(err).
 				Warn("Download attempt failed")
 
+			// Exponential backoff, or the exact flood wait Telegram asked for
+			var backoff time.Duration
+			if attempt < dw.maxRetries {
+				backoff = dw.retryBackoff(attempt, err)
+			}
+			dw.recordTaskError(task.ID, storage.StageDownload, attempt, err, backoff)
+
 			if attempt < dw.maxRetries {
-				// Exponential backoff
-				backoff := time.Duration(attempt) * time.Second * 2
 				select {
 				case <-downloadCtx.Done():
 					return downloadCtx.Err()
@@ -115,6 +122,8 @@ func (dw *DownloadWorker) Process(ctx context.Context, job Job) error {
 		}
 	}
 
+	dw.recordTraffic(task.ID, traffic)
+
 	if downloadErr != nil {
 		dw.logger.WithField("task_id", task.ID).
 			WithError(downloadErr).
@@ -137,99 +146,212 @@ func (dw *DownloadWorker) Process(ctx context.Context, job Job) error {
 	return nil
 }
 
-// StartPolling continuously polls for PENDING tasks and processes them
-// This is used for Option 1 architecture where download workers poll the queue
-// Up to 3 concurrent downloads are supported (Telegram API limit)
+// SetDispatch makes idle workers wait for dispatch instead of only polling
+func (dw *DownloadWorker) SetDispatch(dispatch *storage.TaskDispatch) {
+	dw.dispatch = dispatch
+}
+
+// SetStageLimits caps how many workers download at once; without limits
+// every started worker does
+func (dw *DownloadWorker) SetStageLimits(limits *utils.StageLimits) {
+	dw.limits = limits
+}
+
+// StartPolling claims and processes PENDING tasks until none are left, then
+// waits for the dispatch to announce a new one. Polling every
+// DOWNLOAD_POLL_INTERVAL picks up tasks queued without it, e.g. by another
+// process. Workers beyond the download limit stay idle until it is raised
+// Tasks are claimed with a lease, so no two workers download the same task
 func (dw *DownloadWorker) StartPolling(ctx context.Context, workerID int) error {
 	dw.logger.WithField("worker_id", workerID).Info("Download worker started polling")
 
-	ticker := time.NewTicker(5 * time.Second)
+	owner := fmt.Sprintf("%s/download-%d", dw.config.HAInstanceID, workerID)
+
+	ticker := time.NewTicker(dw.config.DownloadPollInterval)
 	defer ticker.Stop()
 
 	for {
+		utils.Heartbeat(ctx)
+		for dw.claimAndProcess(ctx, workerID, owner) {
+			utils.Heartbeat(ctx)
+		}
+
 		select {
 		case <-ctx.Done():
 			dw.logger.WithField("worker_id", workerID).Info("Download worker stopped (context cancelled)")
 			return ctx.Err()
-
+		case <-dw.dispatch.Wake():
 		case <-ticker.C:
-			// Get one PENDING task (each worker gets one at a time)
-			tasks, err := dw.taskStore.GetPendingTasks(1)
-			if err != nil {
-				dw.logger.WithField("worker_id", workerID).
-					WithError(err).
-					Error("Failed to get pending tasks")
-				continue
-			}
+		}
+	}
+}
 
-			if len(tasks) == 0 {
-				// No tasks to process, continue polling
-				continue
-			}
+// claimAndProcess claims one PENDING task and processes it. It reports
+// whether a task was claimed, so the caller knows to try for another
+func (dw *DownloadWorker) claimAndProcess(ctx context.Context, workerID int, owner string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
 
-			task := tasks[0]
+	// Nothing is claimed while a restore holds the pipeline
+	if utils.Maintenance.Paused() {
+		return false
+	}
 
-			dw.logger.WithField("worker_id", workerID).
-				WithField("task_id", task.ID).
-				WithField("file_name", task.FileName).
-				Info("Picked up task for download")
+	// A worker over the limit leaves the task to a running one, which
+	// claims the next task as soon as it is done
+	if !dw.limits.TryAcquire(utils.StageDownloads) {
+		return false
+	}
+	defer dw.limits.Release(utils.StageDownloads)
 
-			// Process the task
-			if err := dw.processTask(ctx, task); err != nil {
-				dw.logger.WithField("worker_id", workerID).
-					WithField("task_id", task.ID).
-					WithError(err).
-					Error("Failed to process task")
-
-				// Mark task as FAILED
-				task.Status = models.TaskStatusFailed
-				task.ErrorMessage = err.Error()
-				if updateErr := dw.taskStore.UpdateTask(task); updateErr != nil {
-					dw.logger.WithField("task_id", task.ID).
-						WithError(updateErr).
-						Error("Failed to update task to FAILED")
-				}
-				continue
+	// Claim one PENDING task (each worker gets one at a time)
+	task, err := dw.taskStore.ClaimPendingTask(owner, dw.config.TaskLeaseTTL)
+	if err != nil {
+		dw.logger.WithField("worker_id", workerID).
+			WithError(err).
+			Error("Failed to claim pending task")
+		return false
+	}
+	if task == nil {
+		return false
+	}
+
+	dw.logger.WithField("worker_id", workerID).
+		WithField("task_id", task.ID).
+		WithField("file_name", task.FileName).
+		Info("Picked up task for download")
+
+	dw.processClaimedTask(ctx, workerID, owner, task)
+	return true
+}
+
+// processClaimedTask downloads a claimed task and moves it on to extraction,
+// holding the lease until done
+func (dw *DownloadWorker) processClaimedTask(ctx context.Context, workerID int, owner string, task *models.Task) {
+	leaseCtx, release := dw.holdLease(ctx, task.ID, owner)
+	defer release()
+
+	// Process the task
+	if err := dw.processTaskSafe(leaseCtx, task, owner); err != nil {
+		dw.logger.WithField("worker_id", workerID).
+			WithField("task_id", task.ID).
+			WithError(err).
+			Error("Failed to process task")
+
+		if errors.Is(err, storage.ErrTaskLeaseLost) || errors.Is(context.Cause(leaseCtx), storage.ErrTaskLeaseLost) {
+			// The task was reclaimed and belongs to another worker now
+			return
+		}
+
+		// Mark task as FAILED, unless it was reclaimed in the meantime
+		task.Status = models.TaskStatusFailed
+		task.ErrorMessage = err.Error()
+		if updateErr := dw.taskStore.UpdateClaimedTask(task, owner); errors.Is(updateErr, storage.ErrTaskLeaseLost) {
+			dw.logger.WithField("task_id", task.ID).
+				Warn("Download lease lost, leaving the task to its new owner")
+		} else if updateErr != nil {
+			dw.logger.WithField("task_id", task.ID).
+				WithError(updateErr).
+				Error("Failed to update task to FAILED")
+		}
+		return
+	}
+
+	// Move file to extraction directory after download
+	if err := dw.moveTaskFileToExtraction(task); err != nil {
+		dw.logger.WithField("worker_id", workerID).
+			WithField("task_id", task.ID).
+			WithError(err).
+			Error("Failed to move file to extraction directory")
+		// Don't mark as failed, file is downloaded successfully
+	}
+}
+
+// holdLease renews owner's lease on a task every third of TASK_LEASE_TTL until
+// release is called. Renewal failures are retried until the lease would have
+// expired; once it is lost the returned context is cancelled with
+// storage.ErrTaskLeaseLost, so the download stops instead of racing the
+// worker that took the task over
+func (dw *DownloadWorker) holdLease(ctx context.Context, taskID, owner string) (context.Context, func()) {
+	ttl := dw.config.TaskLeaseTTL
+	leaseCtx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+
+		renewed := time.Now()
+		for {
+			select {
+			case <-leaseCtx.Done():
+				return
+			case <-ticker.C:
 			}
 
-			// Move file to extraction directory after download
-			if err := dw.moveTaskFileToExtraction(task); err != nil {
-				dw.logger.WithField("worker_id", workerID).
-					WithField("task_id", task.ID).
+			err := dw.taskStore.RenewTaskLease(taskID, owner, ttl)
+			if err == nil {
+				renewed = time.Now()
+				continue
+			}
+			if errors.Is(err, storage.ErrTaskLeaseLost) || time.Since(renewed) >= ttl {
+				dw.logger.WithField("task_id", taskID).
 					WithError(err).
-					Error("Failed to move file to extraction directory")
-				// Don't mark as failed, file is downloaded successfully
+					Error("Lost download lease, abandoning task")
+				cancel(storage.ErrTaskLeaseLost)
+				return
 			}
+			dw.logger.WithField("task_id", taskID).
+				WithError(err).
+				Warn("Failed to renew download lease")
+		}
+	}()
+
+	return leaseCtx, func() {
+		cancel(nil)
+		<-done
+		if err := dw.taskStore.ReleaseTaskLease(taskID, owner); err != nil {
+			dw.logger.WithField("task_id", taskID).
+				WithError(err).
+				Warn("Failed to release download lease")
 		}
 	}
 }
 
-// processTask handles a single task download with status transitions
-func (dw *DownloadWorker) processTask(ctx context.Context, task *models.Task) error {
+// processTask handles a single task download claimed by owner with status transitions
+func (dw *DownloadWorker) processTask(ctx context.Context, task *models.Task, owner string) error {
 	dw.logger.WithField("task_id", task.ID).
 		WithField("file_name", task.FileName).
 		Info("Starting file download")
 
-	// Mark task as DOWNLOADING
-	if err := dw.taskStore.MarkDownloading(task.ID); err != nil {
-		dw.logger.WithField("task_id", task.ID).
-			WithError(err).
-			Error("Failed to mark task as DOWNLOADING")
-		return fmt.Errorf("failed to mark task as downloading: %w", err)
+	// Mark task as DOWNLOADING, unless claiming it already did
+	if task.Status != models.TaskStatusDownloading {
+		if err := dw.taskStore.MarkDownloading(task.ID); err != nil {
+			dw.logger.WithField("task_id", task.ID).
+				WithError(err).
+				Error("Failed to mark task as DOWNLOADING")
+			return fmt.Errorf("failed to mark task as downloading: %w", err)
+		}
 	}
 
+	startTime := time.Now()
+
 	// Create context with timeout
 	downloadCtx, cancel := context.WithTimeout(ctx, dw.timeout)
 	defer cancel()
 
 	// Download file with retries
+	traffic := &downloadTraffic{}
 	var downloadErr error
 	for attempt := 1; attempt <= dw.maxRetries; attempt++ {
 		dw.logger.WithField("task_id", task.ID).
 			WithField("attempt", attempt).
 			Debug("Attempting file download")
 
-		if err := dw.downloadFile(downloadCtx, task); err != nil {
+		if err := dw.downloadFile(downloadCtx, task, traffic); err != nil {
 			downloadErr = err
 			dw.logger.WithField("task_id", task.ID).
 				WithField("attempt", attempt).
@@ -237,8 +359,8 @@ func (dw *DownloadWorker) processTask(ctx context.Context, task *models.Task) er
 				Warn("Download attempt failed")
 
 			if attempt < dw.maxRetries {
-				// Exponential backoff
-				backoff := time.Duration(attempt) * time.Second * 2
+				// Exponential backoff, or the exact flood wait Telegram asked for
+				backoff := dw.retryBackoff(attempt, err)
 				select {
 				case <-downloadCtx.Done():
 					return downloadCtx.Err()
@@ -252,6 +374,8 @@ func (dw *DownloadWorker) processTask(ctx context.Context, task *models.Task) er
 		}
 	}
 
+	dw.recordTraffic(task.ID, traffic)
+
 	if downloadErr != nil {
 		dw.logger.WithField("task_id", task.ID).
 			WithError(downloadErr).
@@ -263,18 +387,49 @@ func (dw *DownloadWorker) processTask(ctx context.Context, task *models.Task) er
 		WithField("file_name", task.FileName).
 		Info("File download completed successfully")
 
-	// Mark task as DOWNLOADED
-	if err := dw.taskStore.MarkDownloaded(task.ID); err != nil {
+	// Mark task as DOWNLOADED, unless it was reclaimed in the meantime
+	if err := dw.taskStore.MarkClaimedDownloaded(task.ID, owner); err != nil {
 		dw.logger.WithField("task_id", task.ID).
 			WithError(err).
 			Error("Failed to mark task as DOWNLOADED")
 		return fmt.Errorf("failed to mark task as downloaded: %w", err)
 	}
+	task.Status = models.TaskStatusDownloaded
+
+	// Record download duration for queue ETA estimates
+	if err := dw.taskStore.RecordStageTiming(storage.StageDownload, task.ID, time.Since(startTime), 1, task.FileSize); err != nil {
+		dw.logger.WithField("task_id", task.ID).
+			WithError(err).
+			Warn("Failed to record download timing")
+	}
 
 	return nil
 }
 
-func (dw *DownloadWorker) downloadFile(ctx context.Context, task *models.Task) error {
+// downloadTraffic is the network usage of one task's download attempts
+type downloadTraffic struct {
+	botAPI     utils.TrafficMeter
+	downloaded int64
+}
+
+// recordTraffic adds a task's download traffic to its record and to the
+// process-wide accounting
+func (dw *DownloadWorker) recordTraffic(taskID string, traffic *downloadTraffic) {
+	utils.Traffic.Downloaded.Add(traffic.downloaded)
+	if err := dw.taskStore.RecordDownloadTraffic(taskID, storage.DownloadTraffic{
+		DownloadedBytes: traffic.downloaded,
+		BotAPIBytes:     traffic.botAPI.Total(),
+	}); err != nil {
+		dw.logger.WithField("task_id", taskID).
+			WithError(err).
+			Warn("Failed to record download traffic")
+	}
+}
+
+func (dw *DownloadWorker) downloadFile(ctx context.Context, task *models.Task, traffic *downloadTraffic) error {
+	if err := utils.Faults.Inject(ctx, utils.FaultDownload); err != nil {
+		return err
+	}
 	
 	// Always use Local Bot API server for all file downloads (0GB-4GB)
 	isLocalAPI := dw.config.UseLocalBotAPI && dw.config.LocalBotAPIEnabled
@@ -308,7 +463,7 @@ func (dw *DownloadWorker) downloadFile(ctx context.Context, task *models.Task) e
 	
 	// Try to get file info using GetFile API
 	fileConfig := tgbotapi.FileConfig{FileID: task.TelegramFileID}
-	file, err := dw.bot.GetFile(fileConfig)
+	file, err := utils.MeteredFetcher(dw.bot, &traffic.botAPI).GetFile(fileConfig)
 	
 	if err != nil && (strings.Contains(err.Error(), "file is too big") || strings.Contains(err.Error(), "too big")) {
 		dw.logger.WithField("task_id", task.ID).
@@ -392,15 +547,9 @@ func (dw *DownloadWorker) downloadFile(ctx context.Context, task *models.Task) e
 		return fmt.Errorf("failed to get file info: %w", err)
 	}
 
-	// Calculate file hash directly from Local Bot API file
-	sourceFile, err := os.Open(sourceFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to open source file for hashing: %w", err)
-	}
-	defer sourceFile.Close()
-
-	hasher := sha256.New()
-	bytesRead, err := io.Copy(hasher, sourceFile)
+	// Calculate file hash directly from Local Bot API file using tuned I/O
+	// (large buffers, sequential read-ahead, and page cache release)
+	fileHash, bytesRead, err := utils.HashFileTuned(sourceFilePath, dw.ioTuning)
 	if err != nil {
 		return fmt.Errorf("failed to calculate file hash: %w", err)
 	}
@@ -410,18 +559,17 @@ func (dw *DownloadWorker) downloadFile(ctx context.Context, task *models.Task) e
 	if bytesRead != actualFileSize {
 		return fmt.Errorf("file size mismatch during hash calculation: expected %d, got %d", actualFileSize, bytesRead)
 	}
+	// The Bot API server fetched the file once, however many attempts read it
+	traffic.downloaded = bytesRead
 
-	// Update task with file hash and confirm download
-	fileHash := fmt.Sprintf("%x", hasher.Sum(nil))
-	
-	// Check for duplicate files
-	existingTask, err := dw.taskStore.GetByFileHash(fileHash)
-	if err == nil && existingTask != nil && existingTask.ID != task.ID {
+	// Check for duplicate files; retries of the same upload are not duplicates
+	existingTask, err := dw.taskStore.GetDuplicateByFileHash(fileHash, task.ID)
+	if err == nil && existingTask != nil {
 		return fmt.Errorf("duplicate file detected, already processed as task %s", existingTask.ID)
 	}
 	
 	// Perform comprehensive security validation on the Local Bot API file
-	validationResult, err := dw.securityValidator.ValidateFile(sourceFilePath, task.FileType)
+	validationResult, cached, err := dw.securityValidator.ValidateFileWithHash(sourceFilePath, task.FileType, fileHash)
 	if err != nil {
 		return fmt.Errorf("security validation failed: %w", err)
 	}
@@ -431,41 +579,12 @@ func (dw *DownloadWorker) downloadFile(ctx context.Context, task *models.Task) e
 		WithField("threat_level", validationResult.ThreatLevel.String()).
 		WithField("warnings_count", len(validationResult.SecurityWarnings)).
 		WithField("valid", validationResult.Valid).
+		WithField("cached", cached).
 		Info("Security validation completed")
 	
 	// Handle files that should be quarantined
 	if dw.securityValidator.ShouldQuarantine(validationResult) {
-		quarantinePath := filepath.Join("app/extraction/files/errors", fmt.Sprintf("quarantine_%s_%s", task.ID, task.FileName))
-		if err := os.MkdirAll(filepath.Dir(quarantinePath), 0755); err == nil {
-			// Move the Local Bot API file directly to quarantine
-			if err := os.Rename(sourceFilePath, quarantinePath); err == nil {
-				// Log quarantine event to security audit
-				dw.securityAudit.LogQuarantineEvent(
-					task.ID, 
-					task.FileName, 
-					fileHash, 
-					fmt.Sprintf("Threat level %s with %d security warnings", validationResult.ThreatLevel.String(), len(validationResult.SecurityWarnings)),
-					task.UserID,
-				)
-				
-				dw.logger.WithField("task_id", task.ID).
-					WithField("quarantine_path", quarantinePath).
-					WithField("threat_level", validationResult.ThreatLevel.String()).
-					Warn("File quarantined due to security threats")
-				return fmt.Errorf("file quarantined due to security threats: %s", validationResult.ThreatLevel.String())
-			}
-		}
-		
-		// Log failed quarantine attempt - file will remain in Local Bot API directory
-		dw.securityAudit.LogQuarantineEvent(
-			task.ID, 
-			task.FileName, 
-			fileHash, 
-			fmt.Sprintf("Failed to quarantine file, remains in Local Bot API directory. Threat level: %s", validationResult.ThreatLevel.String()),
-			task.UserID,
-		)
-		
-		return fmt.Errorf("file rejected due to security threats: %s", validationResult.ThreatLevel.String())
+		return dw.quarantineTask(task, sourceFilePath, fileHash, validationResult)
 	}
 	
 	// Attempt sanitization for medium-threat files (skip for now since we're doing direct moves)
@@ -491,6 +610,10 @@ func (dw *DownloadWorker) downloadFile(ctx context.Context, task *models.Task) e
 		dw.logger.WithError(err).Warn("Failed to log security validation event")
 	}
 
+	// Record the archive layout for /task and the archive export; a file the
+	// inspector cannot read is left for extraction to reject
+	dw.recordArchiveMetadata(task, sourceFilePath)
+
 	// Store file hash and move to Local Bot API temp directory first
 	task.FileHash = fileHash
 	
@@ -501,8 +624,9 @@ func (dw *DownloadWorker) downloadFile(ctx context.Context, task *models.Task) e
 	}
 	
 	// Move file from documents to temp directory for processing
-	// Use task ID prefix to track files properly
-	tempFileName := fmt.Sprintf("%s_%s", task.ID, task.FileName)
+	// Use task ID prefix to track files properly; the on-disk name is sanitized,
+	// the original name stays in task.FileName for messages and reports
+	tempFileName := fmt.Sprintf("%s_%s", task.ID, utils.SanitizeFileName(task.FileName))
 	tempFilePath := filepath.Join(tempPath, tempFileName)
 	
 	// Handle filename conflicts in temp directory
@@ -514,7 +638,7 @@ func (dw *DownloadWorker) downloadFile(ctx context.Context, task *models.Task) e
 	}
 	
 	// Move file from documents to temp directory
-	if err := os.Rename(sourceFilePath, tempFilePath); err != nil {
+	if err := dw.moveFile(task.ID, sourceFilePath, tempFilePath); err != nil {
 		dw.logger.WithError(err).Error("Failed to move file from documents to temp directory")
 		return fmt.Errorf("failed to move file to temp directory: %w", err)
 	}
@@ -634,7 +758,7 @@ func (dw *DownloadWorker) GetStats() DownloadStats {
 }
 
 // Shutdown performs graceful shutdown of the download worker
-func (dw *DownloadWorker) Shutdown() error {
+func (dw *DownloadWorker) Shutdown(ctx context.Context) error {
 	dw.logger.Info("Shutting down download worker")
 	
 	// Move any remaining downloaded files before shutdown
@@ -644,7 +768,7 @@ func (dw *DownloadWorker) Shutdown() error {
 	
 	// Shutdown the secure temp manager
 	if dw.tempManager != nil {
-		if err := dw.tempManager.Shutdown(); err != nil {
+		if err := dw.tempManager.Shutdown(ctx); err != nil {
 			dw.logger.WithError(err).Warn("Error shutting down secure temp manager")
 			return err
 		}
@@ -663,44 +787,99 @@ func (dw *DownloadWorker) GetTempManagerStats() interface{} {
 }
 
 // GetTaskStore returns the task store for accessing task data
-func (dw *DownloadWorker) GetTaskStore() *storage.TaskStore {
+func (dw *DownloadWorker) GetTaskStore() DownloadStore {
 	return dw.taskStore
 }
 
+// retryBackoff returns the delay before the next download attempt. A 429
+// from the Bot API carries retry_after, which replaces the generic backoff
+func (dw *DownloadWorker) retryBackoff(attempt int, err error) time.Duration {
+	if wait, ok := utils.TelegramRetryAfter(err); ok {
+		dw.logger.WithField("retry_after", wait).
+			WithField("attempt", attempt).
+			Warn("Telegram flood wait, delaying next download attempt")
+		return wait
+	}
+	return time.Duration(attempt) * time.Second * 2
+}
+
+// recordTaskError adds a failed attempt to the task's error history
+func (dw *DownloadWorker) recordTaskError(taskID, stage string, attempt int, err error, retryDelay time.Duration) {
+	if recordErr := dw.taskStore.RecordTaskError(storage.NewTaskError(taskID, stage, attempt, err, retryDelay)); recordErr != nil {
+		dw.logger.WithField("task_id", taskID).WithError(recordErr).Warn("Failed to record task error")
+	}
+}
+
+// recordArchiveMetadata inspects the archive and stores its metadata, logging failures
+func (dw *DownloadWorker) recordArchiveMetadata(task *models.Task, path string) {
+	meta, err := utils.InspectArchive(path, task.FileType)
+	if err != nil {
+		dw.logger.WithField("task_id", task.ID).WithError(err).Warn("Failed to inspect archive metadata")
+		return
+	}
+
+	dw.logger.WithField("task_id", task.ID).
+		WithField("entries", meta.Entries).
+		WithField("uncompressed_size", meta.UncompressedSize).
+		WithField("ratio", fmt.Sprintf("%.1f", meta.Ratio())).
+		WithField("max_entry_ratio", fmt.Sprintf("%.1f", meta.MaxEntryRatio)).
+		WithField("encrypted", meta.Encrypted).
+		Info("Archive metadata inspected")
+
+	if err := dw.taskStore.SaveArchiveMetadata(task.ID, meta); err != nil {
+		dw.logger.WithField("task_id", task.ID).WithError(err).Warn("Failed to save archive metadata")
+	}
+}
+
+// SetBandwidthLimiter throttles download hashing and moves; call before polling starts
+func (dw *DownloadWorker) SetBandwidthLimiter(limiter *utils.BandwidthLimiter) {
+	dw.ioTuning.Limiter = limiter
+}
+
+// GetSignatureRegistry returns the signature definitions used to validate downloads
+func (dw *DownloadWorker) GetSignatureRegistry() *utils.SignatureRegistry {
+	return dw.securityValidator.SignatureRegistry()
+}
+
 // GetBotAPIPathManager returns the bot API path manager
 func (dw *DownloadWorker) GetBotAPIPathManager() *utils.BotAPIPathManager {
 	return dw.botAPIPathManager
 }
 
+// GetTempManager returns the secure temporary file manager
+func (dw *DownloadWorker) GetTempManager() *utils.SecureTempManager {
+	return dw.tempManager
+}
+
 // MoveDownloadedFilesToExtraction moves files from Local Bot API temp to extraction directories
 func (dw *DownloadWorker) MoveDownloadedFilesToExtraction() error {
 	dw.logger.Info("Starting auto-move of downloaded files to extraction directories")
 	
-	// Get downloaded tasks that need to be moved
-	tasks, err := dw.taskStore.GetByStatus(models.TaskStatusDownloaded)
-	if err != nil {
-		return fmt.Errorf("failed to get downloaded tasks: %w", err)
-	}
-	
-	if len(tasks) == 0 {
-		dw.logger.Debug("No downloaded files to move")
-		return nil
-	}
-	
-	movedCount := 0
-	for _, task := range tasks {
+	// Move the downloaded tasks a page at a time
+	movedCount, totalCount := 0, 0
+	err := dw.taskStore.EachByStatus(models.TaskStatusDownloaded, func(task *models.Task) error {
+		totalCount++
 		if err := dw.moveTaskFileToExtraction(task); err != nil {
 			dw.logger.WithField("task_id", task.ID).
 				WithField("file_name", task.FileName).
 				WithError(err).
 				Error("Failed to move file to extraction directory")
-			continue
+			return nil
 		}
 		movedCount++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get downloaded tasks: %w", err)
+	}
+	
+	if totalCount == 0 {
+		dw.logger.Debug("No downloaded files to move")
+		return nil
 	}
 	
 	dw.logger.WithField("moved_count", movedCount).
-		WithField("total_count", len(tasks)).
+		WithField("total_count", totalCount).
 		Info("Auto-move of downloaded files completed")
 	
 	return nil
@@ -727,7 +906,8 @@ func (dw *DownloadWorker) moveTaskFileToExtraction(task *models.Task) error {
 	
 	switch fileExt {
 	case ".txt":
-		destDir = "app/extraction/files/txt"
+		// Text files skip extraction: the orchestrator's fast path validates and converts them
+		destDir = "app/extraction/files/fastpath"
 	case ".zip", ".rar":
 		destDir = "app/extraction/files/all"
 	default:
@@ -743,20 +923,21 @@ func (dw *DownloadWorker) moveTaskFileToExtraction(task *models.Task) error {
 		return fmt.Errorf("failed to create destination directory %s: %w", destDir, err)
 	}
 	
-	// Use original filename (not task ID prefix) for final storage
-	finalFileName := task.FileName
+	// Use the sanitized original filename (not task ID prefix) for final storage
+	storageName := utils.SanitizeFileName(task.FileName)
+	finalFileName := storageName
 	finalPath := filepath.Join(destDir, finalFileName)
 	
 	// Handle filename conflicts by adding task ID if file already exists
 	if _, err := os.Stat(finalPath); err == nil {
-		baseName := strings.TrimSuffix(task.FileName, filepath.Ext(task.FileName))
-		ext := filepath.Ext(task.FileName)
+		baseName := strings.TrimSuffix(storageName, filepath.Ext(storageName))
+		ext := filepath.Ext(storageName)
 		finalFileName = fmt.Sprintf("%s_%s%s", baseName, task.ID, ext)
 		finalPath = filepath.Join(destDir, finalFileName)
 	}
 	
 	// Move file from temp to extraction directory
-	if err := os.Rename(task.LocalAPIPath, finalPath); err != nil {
+	if err := dw.moveFile(task.ID, task.LocalAPIPath, finalPath); err != nil {
 		return fmt.Errorf("failed to move file from %s to %s: %w", task.LocalAPIPath, finalPath, err)
 	}
 	
@@ -765,9 +946,14 @@ func (dw *DownloadWorker) moveTaskFileToExtraction(task *models.Task) error {
 	if err := dw.taskStore.UpdateTask(task); err != nil {
 		dw.logger.WithError(err).Warn("Failed to update task after moving file")
 	}
+	// Remember where the file went, so /delete can purge it
+	if err := dw.taskStore.SetTaskStoragePath(task.ID, finalPath); err != nil {
+		dw.logger.WithError(err).Warn("Failed to record task storage path")
+	}
 	
 	dw.logger.WithField("task_id", task.ID).
 		WithField("file_name", task.FileName).
+		WithField("storage_name", finalFileName).
 		WithField("file_type", fileExt).
 		WithField("temp_path", task.LocalAPIPath).
 		WithField("final_path", finalPath).
@@ -782,4 +968,41 @@ type DownloadStats struct {
 	ActiveDownloads int
 	FailedDownloads int
 	BytesDownloaded int64
-}
\ No newline at end of file
+}
+
+// processTaskSafe runs processTask, turning a panic into a task failure with a crash report
+func (dw *DownloadWorker) processTaskSafe(ctx context.Context, task *models.Task, owner string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			report := utils.CapturePanic("download_worker", r, debug.Stack(), map[string]interface{}{
+				"task_id":   task.ID,
+				"user_id":   task.UserID,
+				"file_name": task.FileName,
+				"file_size": task.FileSize,
+			})
+			err = fmt.Errorf("download worker panicked: %v", r)
+			if report != nil {
+				err = fmt.Errorf("download worker panicked (crash report %s): %v", report.ID[:8], r)
+			}
+		}
+	}()
+
+	return dw.processTask(ctx, task, owner)
+}
+
+// moveFile moves a task file, copying across filesystems when temp and
+// extraction directories live on different mounts
+func (dw *DownloadWorker) moveFile(taskID, src, dst string) error {
+	opts := utils.MoveOptions{
+		Tuning: dw.ioTuning,
+		Verify: true,
+		Progress: func(copied, total int64) {
+			dw.logger.WithField("task_id", taskID).
+				WithField("destination", dst).
+				WithField("copied", copied).
+				WithField("total", total).
+				Debug("Cross-filesystem move in progress")
+		},
+	}
+	return utils.MoveFile(src, dst, opts)
+}
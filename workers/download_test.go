@@ -0,0 +1,189 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+
+	"telegram-archive-bot/bot/bottest"
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// testBotToken names the Local Bot API directory the path manager finds
+const testBotToken = "123:test"
+
+// newTestDownloadWorker returns a download worker on an in-memory database,
+// fetching files through a fake client. The Local Bot API directories are
+// created in a temporary working directory
+func newTestDownloadWorker(t *testing.T) (*DownloadWorker, *storage.TaskStore, *bottest.Client) {
+	t.Helper()
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, sub := range []string{"documents", "temp"} {
+		if err := os.MkdirAll(filepath.Join(dir, testBotToken, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(workDir) })
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	taskStore := storage.NewTaskStore(db)
+
+	base := logrus.New()
+	base.SetOutput(io.Discard)
+	logger := &utils.Logger{Logger: base}
+	config := &utils.Config{
+		TelegramBotToken:     testBotToken,
+		UseLocalBotAPI:       true,
+		LocalBotAPIEnabled:   true,
+		HAInstanceID:         "test",
+		TaskLeaseTTL:         time.Minute,
+		DownloadPollInterval: time.Second,
+	}
+	client := bottest.NewClient()
+	dw := NewDownloadWorker(client, utils.NewBotAPIPathManager(config, logger), config, logger, taskStore, db.DB())
+	// One attempt each, so the test does not wait out the retry backoff
+	dw.maxRetries = 1
+	return dw, taskStore, client
+}
+
+func TestDownloadWorkerFaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		inject func(t *testing.T, client *bottest.Client)
+		want   string // In the failed task's error message
+	}{
+		{
+			name: "download fault",
+			inject: func(t *testing.T, client *bottest.Client) {
+				faults, err := utils.ParseFaultInjection(func(name string) string {
+					if name == "FAIL_DOWNLOAD_PERCENT" {
+						return "100"
+					}
+					return ""
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				previous := utils.Faults
+				utils.Faults = faults
+				t.Cleanup(func() { utils.Faults = previous })
+			},
+			want: utils.ErrInjectedFault.Error(),
+		},
+		{
+			name: "getFile rejected",
+			inject: func(t *testing.T, client *bottest.Client) {
+				client.FailNext("getFile", &tgbotapi.Error{Code: 400, Message: "Bad Request: wrong file_id"})
+			},
+			want: "wrong file_id",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dw, taskStore, client := newTestDownloadWorker(t)
+			document := client.AddDocument(42, "logs.zip", 1024, "documents/file_1.zip")
+			task := &models.Task{
+				UserID:               42,
+				ChatID:               42,
+				FileName:             document.FileName,
+				FileSize:             int64(document.FileSize),
+				FileType:             "ZIP",
+				TelegramFileID:       document.FileID,
+				TelegramFileUniqueID: document.FileUniqueID,
+				Status:               models.TaskStatusPending,
+				CreatedAt:            time.Now(),
+				UpdatedAt:            time.Now(),
+			}
+			if err := taskStore.Create(task); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.inject(t, client)
+			if !dw.claimAndProcess(context.Background(), 1, "test/download-1") {
+				t.Fatal("pending task not claimed")
+			}
+
+			got, err := taskStore.GetByID(task.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != models.TaskStatusFailed || !strings.Contains(got.ErrorMessage, tt.want) {
+				t.Fatalf("task %s with error %q, want FAILED with %q", got.Status, got.ErrorMessage, tt.want)
+			}
+
+			// The failed task is not handed to another worker
+			if dw.claimAndProcess(context.Background(), 2, "test/download-2") {
+				t.Fatal("failed task claimed again")
+			}
+		})
+	}
+}
+
+// A worker whose lease was reclaimed leaves the task's status to its new owner
+func TestDownloadWorkerLeaseLost(t *testing.T) {
+	dw, taskStore, client := newTestDownloadWorker(t)
+	document := client.AddDocument(42, "logs.zip", 1024, "documents/file_1.zip")
+	task := &models.Task{
+		UserID:               42,
+		ChatID:               42,
+		FileName:             document.FileName,
+		FileSize:             int64(document.FileSize),
+		FileType:             "ZIP",
+		TelegramFileID:       document.FileID,
+		TelegramFileUniqueID: document.FileUniqueID,
+		Status:               models.TaskStatusPending,
+		CreatedAt:            time.Now(),
+		UpdatedAt:            time.Now(),
+	}
+	if err := taskStore.Create(task); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := taskStore.ClaimPendingTask("test/download-1", time.Minute)
+	if err != nil || claimed == nil {
+		t.Fatalf("ClaimPendingTask = %v, %v", claimed, err)
+	}
+
+	// The lease ran out between renewals and another worker took the task
+	if _, err := taskStore.GetDB().Exec(`UPDATE tasks SET claimed_by = 'test/download-2' WHERE id = ?`, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := taskStore.MarkClaimedDownloaded(task.ID, "test/download-1"); !errors.Is(err, storage.ErrTaskLeaseLost) {
+		t.Fatalf("MarkClaimedDownloaded by the old owner = %v, want ErrTaskLeaseLost", err)
+	}
+
+	client.FailNext("getFile", &tgbotapi.Error{Code: 400, Message: "Bad Request: wrong file_id"})
+	dw.processClaimedTask(context.Background(), 1, "test/download-1", claimed)
+
+	got, err := taskStore.GetByID(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.TaskStatusDownloading || got.ErrorMessage != "" {
+		t.Fatalf("task %s with error %q, want it left DOWNLOADING for the new owner", got.Status, got.ErrorMessage)
+	}
+	if err := taskStore.RenewTaskLease(task.ID, "test/download-2", time.Minute); err != nil {
+		t.Fatalf("new owner lost its lease: %v", err)
+	}
+}
@@ -14,6 +14,9 @@ import (
 	"telegram-archive-bot/utils"
 )
 
+// ExtractionOperation is the queued operation name for extractions deferred by an outage
+const ExtractionOperation = "archive_extraction"
+
 type ExtractionWorker struct {
 	config              *utils.Config
 	logger              *utils.Logger
@@ -30,10 +33,17 @@ type ExtractionWorker struct {
 func NewExtractionWorker(config *utils.Config, logger *utils.Logger, taskStore *storage.TaskStore) *ExtractionWorker {
 	degradationManager := utils.NewGracefulDegradationManager(logger)
 	
-	// Register extract.go and dependencies
-	degradationManager.RegisterDependency("extract", "executable", 2*time.Minute, utils.FallbackQueue)
-	degradationManager.RegisterDependency("go", "executable", 5*time.Minute, utils.FallbackManual)
+	// Register the compiled-in extractor and its dependencies
+	degradationManager.RegisterDependency("extract", "builtin", 2*time.Minute, utils.FallbackQueue)
 	degradationManager.RegisterDependency("app/extraction", "directory", 1*time.Minute, utils.FallbackManual)
+	degradationManager.RegisterDependency(utils.PassFileDependency, "file", 5*time.Minute, utils.FallbackSkip)
+	
+	// Try to repair missing dependencies before degrading, audit-logging each attempt
+	degradationManager.RegisterDefaultRecoveryActions(config)
+	degradationManager.SetRecoveryAuditor(storage.NewAdminAuditLogger(taskStore.GetDB(), logger).LogRecoveryAttempt)
+	
+	// Keep operations deferred by an outage across restarts
+	degradationManager.SetOperationStore(taskStore)
 	
 	return &ExtractionWorker{
 		config:             config,
@@ -52,9 +62,17 @@ func (ew *ExtractionWorker) StartMonitoring(ctx context.Context) {
 	ew.degradationManager.StartMonitoring(ctx)
 }
 
-// StopMonitoring stops dependency monitoring
+// StopMonitoring stops dependency monitoring and waits for a running check
 func (ew *ExtractionWorker) StopMonitoring() {
-	ew.degradationManager.StopMonitoring()
+	if err := ew.degradationManager.Shutdown(context.Background()); err != nil {
+		ew.logger.WithError(err).Warn("Failed to stop dependency monitoring")
+	}
+}
+
+// SetQueuedOperationHandler sets how archive extractions queued during an outage of
+// their dependencies are re-dispatched once they recover
+func (ew *ExtractionWorker) SetQueuedOperationHandler(handler utils.OperationHandler) {
+	ew.degradationManager.RegisterOperationHandler(ExtractionOperation, handler)
 }
 
 // GetDependencyHealth returns health status of extraction dependencies
@@ -85,6 +103,10 @@ func (ew *ExtractionWorker) Process(ctx context.Context, job Job) error {
 		return fmt.Errorf("file not found in extraction directory: %w", err)
 	}
 
+	if err := utils.Faults.Inject(ctx, utils.FaultExtract); err != nil {
+		return fmt.Errorf("extraction failed: %w", err)
+	}
+
 	// Handle different file types
 	switch task.FileType {
 	case "zip", "rar":
@@ -103,10 +125,10 @@ func (ew *ExtractionWorker) verifyFileInExtractionDirectory(task *models.Task) e
 	switch task.FileType {
 	case "txt":
 		// TXT files should be in files/txt/ directory
-		extractionFilePath = filepath.Join(ew.extractionDir, "files", "txt", task.FileName)
+		extractionFilePath = filepath.Join(ew.extractionDir, "files", "txt", utils.SanitizeFileName(task.FileName))
 	case "zip", "rar":
 		// Archive files should be in files/all/ directory
-		extractionFilePath = filepath.Join(ew.extractionDir, "files", "all", task.FileName)
+		extractionFilePath = filepath.Join(ew.extractionDir, "files", "all", utils.SanitizeFileName(task.FileName))
 	default:
 		return fmt.Errorf("unsupported file type: %s", task.FileType)
 	}
@@ -137,11 +159,12 @@ func (ew *ExtractionWorker) extractArchive(ctx context.Context, task *models.Tas
 		
 		parameters := map[string]interface{}{
 			"task_id":   task.ID,
+			"stage":     "extraction",
 			"file_name": task.FileName,
 			"file_type": task.FileType,
 		}
 		
-		degradationErr := ew.degradationManager.HandleUnavailableDependency("extract", "archive_extraction", parameters)
+		degradationErr := ew.degradationManager.HandleUnavailableDependency("extract", ExtractionOperation, parameters)
 		if degradationErr != nil {
 			return fmt.Errorf("graceful degradation for extract.go: %w", degradationErr)
 		}
@@ -181,7 +204,7 @@ func (ew *ExtractionWorker) extractArchive(ctx context.Context, task *models.Tas
 			}()
 			
 			// Call the extraction function directly
-			extract.ExtractArchives()
+			extract.ExtractArchives(1)
 			done <- nil
 		}()
 
@@ -193,8 +216,9 @@ func (ew *ExtractionWorker) extractArchive(ctx context.Context, task *models.Tas
 					Error("extraction function execution failed")
 				
 				// Mark dependency as potentially unavailable for degradation handling
-				ew.degradationManager.HandleUnavailableDependency("extract", "archive_extraction", map[string]interface{}{
+				ew.degradationManager.HandleUnavailableDependency("extract", ExtractionOperation, map[string]interface{}{
 					"task_id": task.ID,
+					"stage":   "extraction",
 					"error":   err.Error(),
 				})
 				
@@ -234,7 +258,7 @@ func (ew *ExtractionWorker) processTxtFile(ctx context.Context, task *models.Tas
 	ew.logger.WithField("task_id", task.ID).Info("Processing TXT file - already in txt directory")
 
 	// TXT files are already moved directly to files/txt directory by moveFileToExtraction
-	targetFile := filepath.Join(ew.extractionDir, "files", "txt", task.FileName)
+	targetFile := filepath.Join(ew.extractionDir, "files", "txt", utils.SanitizeFileName(task.FileName))
 
 	// Verify the file exists in the txt directory
 	if _, err := os.Stat(targetFile); err != nil {
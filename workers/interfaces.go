@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"telegram-archive-bot/models"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
 )
 
 // Job interface to avoid import cycles
@@ -22,4 +24,18 @@ type Job interface {
 // Worker interface for processing jobs
 type Worker interface {
 	Process(ctx context.Context, job Job) error
-}
\ No newline at end of file
+}
+
+// DownloadStore is what the download worker records in the task store
+type DownloadStore interface {
+	storage.TaskRepository
+	utils.ScanResultStore
+	RecordStageTiming(stage, taskID string, duration time.Duration, items int, bytes int64) error
+	RecordTaskError(taskErr *storage.TaskError) error
+	SaveArchiveMetadata(taskID string, meta *utils.ArchiveMetadata) error
+	SaveQuarantineEntry(entry *storage.QuarantineEntry) error
+	GetQuarantineEntries(status storage.QuarantineStatus) ([]*storage.QuarantineEntry, error)
+	SetTaskStoragePath(taskID, path string) error
+	RecordDownloadTraffic(taskID string, traffic storage.DownloadTraffic) error
+	GetDuplicateByFileHash(fileHash, taskID string) (*models.Task, error)
+}
@@ -0,0 +1,88 @@
+package workers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"time"
+
+	"telegram-archive-bot/app/extraction/convert"
+	"telegram-archive-bot/app/extraction/extract"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// ProcessorBuild describes an extraction stage compiled into the bot binary
+type ProcessorBuild struct {
+	Name       string `json:"name"`
+	SourcePath string `json:"source_path"`
+	BuiltHash  string `json:"built_hash"`
+	DiskHash   string `json:"disk_hash,omitempty"`
+	BotVersion string `json:"bot_version"`
+	Updated    bool   `json:"updated"` // Built hash differs from the previous run
+	Stale      bool   `json:"stale"`   // Source on disk differs from the built one
+}
+
+// processorSources lists the compiled-in processors and their source files
+var processorSources = []struct {
+	name       string
+	sourcePath string
+	builtHash  func() string
+}{
+	{"extract", "app/extraction/extract/extract.go", extract.SourceHash},
+	{"convert", "app/extraction/convert/convert.go", convert.SourceHash},
+}
+
+// VerifyProcessorBuilds checks at startup that the compiled-in extractor and
+// converter match their sources on disk and records their hashes, so a
+// changed source that has not been rebuilt yet is reported instead of
+// silently ignored
+func VerifyProcessorBuilds(taskStore *storage.TaskStore, logger *utils.Logger) []ProcessorBuild {
+	version := utils.BotVersion()
+	now := time.Now()
+
+	builds := make([]ProcessorBuild, 0, len(processorSources))
+	for _, p := range processorSources {
+		build := ProcessorBuild{
+			Name:       p.name,
+			SourcePath: p.sourcePath,
+			BuiltHash:  p.builtHash(),
+			BotVersion: version,
+		}
+
+		// Production deployments ship without sources; only compare when present
+		if content, err := os.ReadFile(p.sourcePath); err == nil {
+			sum := sha256.Sum256(content)
+			build.DiskHash = hex.EncodeToString(sum[:])
+			build.Stale = build.DiskHash != build.BuiltHash
+		}
+
+		previous, err := taskStore.GetProcessorBuild(p.name)
+		if err != nil {
+			logger.WithError(err).WithField("processor", p.name).Warn("Failed to read recorded processor build")
+		} else if previous != nil && previous.SourceHash != build.BuiltHash {
+			build.Updated = true
+		}
+
+		if err := taskStore.SaveProcessorBuild(p.name, build.BuiltHash, version, now); err != nil {
+			logger.WithError(err).WithField("processor", p.name).Warn("Failed to record processor build")
+		}
+
+		entry := logger.WithField("processor", p.name).
+			WithField("source_hash", build.BuiltHash[:12]).
+			WithField("bot_version", version)
+		switch {
+		case build.Stale:
+			entry.WithField("source_path", p.sourcePath).
+				Warn("Processor source changed since this binary was built; rebuild the bot to apply it")
+		case build.Updated:
+			entry.Info("Processor updated since last run")
+		default:
+			entry.Debug("Processor build verified")
+		}
+
+		builds = append(builds, build)
+	}
+
+	return builds
+}
@@ -0,0 +1,201 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+const (
+	// purgeInterval is how often deleted tasks past their grace period are purged
+	purgeInterval = 15 * time.Minute
+	// purgeBatchSize caps the tasks purged per run
+	purgeBatchSize = 50
+)
+
+// PurgeStore is what the task purger reads and records in the task store
+type PurgeStore interface {
+	GetByID(id string) (*models.Task, error)
+	GetDuePurges(now time.Time, limit int) ([]*storage.TaskDeletion, error)
+	GetTaskArtifacts(taskID string) ([]storage.TaskArtifact, error)
+	MarkTaskPurged(taskID string, filesRemoved int, purgeErr error) error
+	DeleteTaskDetails(taskID string) error
+}
+
+// TaskPurger removes the files of tasks deleted with /delete once their
+// grace period is over, and records each purge in the admin audit log
+type TaskPurger struct {
+	store    PurgeStore
+	audit    *storage.AdminAuditLogger
+	logger   *utils.Logger
+	ioTuning utils.IOTuning
+}
+
+// NewTaskPurger creates a task purger
+func NewTaskPurger(store PurgeStore, audit *storage.AdminAuditLogger, logger *utils.Logger, ioTuning utils.IOTuning) *TaskPurger {
+	return &TaskPurger{
+		store:    store,
+		audit:    audit,
+		logger:   logger,
+		ioTuning: ioTuning,
+	}
+}
+
+// Run purges due tasks every purgeInterval until ctx is cancelled
+func (tp *TaskPurger) Run(ctx context.Context) error {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		utils.Heartbeat(ctx)
+		tp.PurgeDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeDue purges every deleted task whose grace period is over and returns
+// how many were purged completely
+func (tp *TaskPurger) PurgeDue(ctx context.Context) int {
+	deletions, err := tp.store.GetDuePurges(time.Now(), purgeBatchSize)
+	if err != nil {
+		tp.logger.WithError(err).Warn("Failed to load deleted tasks due for purge")
+		return 0
+	}
+
+	purged := 0
+	for _, deletion := range deletions {
+		if ctx.Err() != nil {
+			break
+		}
+		// Hashing large files takes a while, so report progress per task
+		utils.Heartbeat(ctx)
+		if tp.purge(deletion) {
+			purged++
+		}
+	}
+	return purged
+}
+
+// purge removes one task's files and reports whether all of them are gone
+func (tp *TaskPurger) purge(deletion *storage.TaskDeletion) bool {
+	logger := tp.logger.WithField("task_id", deletion.TaskID)
+
+	task, err := tp.store.GetByID(deletion.TaskID)
+	if errors.Is(err, storage.ErrTaskNotFound) {
+		tp.purgeMissingTask(deletion)
+		return true
+	}
+	if err != nil {
+		logger.WithError(err).Warn("Failed to load deleted task")
+		return false
+	}
+
+	artifacts, err := tp.store.GetTaskArtifacts(deletion.TaskID)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to list deleted task files")
+		return false
+	}
+
+	var removed []string
+	var errs []error
+	for _, artifact := range artifacts {
+		ok, err := tp.removeArtifact(task, artifact)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", artifact.Path, err))
+		} else if ok {
+			removed = append(removed, artifact.Path)
+		}
+	}
+	if err := tp.store.DeleteTaskDetails(deletion.TaskID); err != nil {
+		errs = append(errs, err)
+	}
+	purgeErr := errors.Join(errs...)
+
+	if err := tp.store.MarkTaskPurged(deletion.TaskID, len(removed), purgeErr); err != nil {
+		logger.WithError(err).Error("Failed to record task purge")
+	}
+
+	if purgeErr != nil {
+		logger.WithError(purgeErr).Warn("Failed to purge deleted task, retrying on the next run")
+	} else {
+		logger.WithField("files_removed", len(removed)).Info("Deleted task purged")
+	}
+	tp.audit.LogSystemAction(0, "system", storage.AdminActionTaskPurge, deletion.TaskID, map[string]interface{}{
+		"file_name":  task.FileName,
+		"deleted_by": deletion.DeletedBy,
+		"deleted_at": deletion.DeletedAt,
+		"removed":    removed,
+	}, "SUCCESS", purgeErr)
+
+	return purgeErr == nil
+}
+
+// purgeMissingTask closes the deletion of a task whose record is gone, e.g.
+// after a restore. Its file paths were kept on the record, so there is nothing
+// left to remove; retrying would only warn every run
+func (tp *TaskPurger) purgeMissingTask(deletion *storage.TaskDeletion) {
+	logger := tp.logger.WithField("task_id", deletion.TaskID)
+	const note = "task record no longer exists, its files could not be located"
+
+	purgeErr := tp.store.DeleteTaskDetails(deletion.TaskID)
+	if err := tp.store.MarkTaskPurged(deletion.TaskID, 0, nil); err != nil {
+		logger.WithError(err).Error("Failed to record task purge")
+	}
+	if purgeErr != nil {
+		logger.WithError(purgeErr).Warn("Failed to delete details of deleted task")
+	}
+	logger.Warn("Deleted task's record is gone, marking it purged without removing files")
+
+	tp.audit.LogSystemAction(0, "system", storage.AdminActionTaskPurge, deletion.TaskID, map[string]interface{}{
+		"deleted_by": deletion.DeletedBy,
+		"deleted_at": deletion.DeletedAt,
+		"note":       note,
+	}, "SUCCESS", purgeErr)
+}
+
+// removeArtifact securely deletes a file of the task. A shared path may hold
+// another task's file by now, so it is only removed when it has the task's content
+func (tp *TaskPurger) removeArtifact(task *models.Task, artifact storage.TaskArtifact) (bool, error) {
+	if _, err := os.Stat(artifact.Path); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if artifact.Shared {
+		hash := ""
+		if task.FileHash != "" {
+			var err error
+			if hash, _, err = utils.HashFileTuned(artifact.Path, tp.ioTuning); err != nil {
+				return false, err
+			}
+		}
+		if hash == "" || hash != task.FileHash {
+			tp.logger.WithField("task_id", task.ID).
+				WithField("path", artifact.Path).
+				Info("Skipping file of another task at a deleted task's path")
+			return false, nil
+		}
+	}
+
+	if err := utils.RemoveSecurely(artifact.Path); errors.Is(err, utils.ErrRemovedWithoutOverwrite) {
+		tp.logger.WithField("task_id", task.ID).
+			WithField("path", artifact.Path).
+			WithError(err).
+			Warn("Deleted task's file removed without overwrite")
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,62 @@
+package workers
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// A deletion whose task record is gone is closed instead of retried forever
+func TestPurgeDeletionOfMissingTask(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	taskStore := storage.NewTaskStore(db)
+
+	task := &models.Task{
+		UserID:    42,
+		ChatID:    42,
+		FileName:  "logs.zip",
+		FileType:  "ZIP",
+		Status:    models.TaskStatusCompleted,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := taskStore.Create(task); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := taskStore.SoftDeleteTask(task.ID, 42, "admin", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DB().Exec(`DELETE FROM tasks WHERE id = ?`, task.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	base := logrus.New()
+	base.SetOutput(io.Discard)
+	logger := &utils.Logger{Logger: base}
+	purger := NewTaskPurger(taskStore, storage.NewAdminAuditLogger(db.DB(), logger), logger, utils.DefaultIOTuning())
+
+	if purged := purger.PurgeDue(context.Background()); purged != 1 {
+		t.Fatalf("PurgeDue = %d, want 1", purged)
+	}
+	deletion, err := taskStore.GetTaskDeletion(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deletion.PurgedAt == nil || deletion.PurgeError != "" {
+		t.Fatalf("deletion = %+v, want it purged without error", deletion)
+	}
+	if purged := purger.PurgeDue(context.Background()); purged != 0 {
+		t.Fatalf("PurgeDue ran again on a closed deletion: %d", purged)
+	}
+}
@@ -0,0 +1,205 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"telegram-archive-bot/models"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+// primaryQuarantineDir is tried before the configured fallback locations
+const primaryQuarantineDir = "app/extraction/files/errors"
+
+// quarantineLocations lists the directories a flagged file may be moved to, in order
+func (dw *DownloadWorker) quarantineLocations() []string {
+	return append([]string{primaryQuarantineDir}, dw.config.QuarantineFallbackDirs...)
+}
+
+// quarantineFile wraps a flagged file in an encrypted quarantine container at
+// the first location that accepts it, removes the original and returns where
+// the container ended up. The original is never left in the quarantine
+// directory in a form that could be opened or executed
+func (dw *DownloadWorker) quarantineFile(entry *storage.QuarantineEntry) (string, error) {
+	var key []byte
+	var err error
+	if dw.config.QuarantineKey != "" {
+		key, err = utils.ParseQuarantineKey(dw.config.QuarantineKey)
+	} else {
+		key, err = utils.LoadQuarantineKey(dw.config.QuarantineKeyPath)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	source, err := os.Open(entry.SourcePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open flagged file: %w", err)
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat flagged file: %w", err)
+	}
+	meta := utils.QuarantineMetadata{
+		TaskID:        entry.TaskID,
+		FileName:      entry.FileName,
+		FileHash:      entry.FileHash,
+		FileSize:      info.Size(),
+		UserID:        entry.UserID,
+		SourcePath:    entry.SourcePath,
+		ThreatLevel:   entry.ThreatLevel,
+		Reason:        entry.Reason,
+		Findings:      entry.Findings,
+		QuarantinedAt: time.Now(),
+	}
+
+	var errs []string
+	for _, dir := range dw.quarantineLocations() {
+		name := fmt.Sprintf("quarantine_%s_%s%s", entry.TaskID, utils.SanitizeFileName(entry.FileName), utils.QuarantineContainerExt)
+		quarantinePath := filepath.Join(dir, name)
+		if _, err := source.Seek(0, io.SeekStart); err != nil {
+			return "", fmt.Errorf("failed to rewind flagged file: %w", err)
+		}
+		if err := utils.WriteQuarantineContainer(quarantinePath, source, key, meta); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", dir, err))
+			continue
+		}
+
+		if err := utils.RemoveSecurely(entry.SourcePath); errors.Is(err, utils.ErrRemovedWithoutOverwrite) {
+			dw.logger.WithField("task_id", entry.TaskID).
+				WithField("path", entry.SourcePath).
+				WithError(err).
+				Warn("Quarantined original removed without overwrite")
+		} else if err != nil {
+			return "", fmt.Errorf("quarantine container written to %s but the original could not be removed: %w", quarantinePath, err)
+		}
+		return quarantinePath, nil
+	}
+	return "", fmt.Errorf("no quarantine location accepted the file: %s", strings.Join(errs, "; "))
+}
+
+// quarantineTask quarantines a file flagged by security validation. Every
+// flagged file is tracked in the quarantine queue; a file that cannot be moved
+// anywhere stays queued and is retried by RunQuarantineRetry
+func (dw *DownloadWorker) quarantineTask(task *models.Task, sourcePath, fileHash string, result *utils.ValidationResult) error {
+	reason := fmt.Sprintf("Threat level %s with %d security warnings", result.ThreatLevel.String(), len(result.SecurityWarnings))
+	entry := &storage.QuarantineEntry{
+		TaskID:      task.ID,
+		FileName:    task.FileName,
+		FileHash:    fileHash,
+		UserID:      task.UserID,
+		SourcePath:  sourcePath,
+		Reason:      reason,
+		ThreatLevel: result.ThreatLevel.String(),
+		Findings:    result.SecurityWarnings,
+		Attempts:    1,
+	}
+
+	quarantinePath, err := dw.quarantineFile(entry)
+	if err == nil {
+		entry.Status = storage.QuarantineStatusQuarantined
+		entry.QuarantinePath = quarantinePath
+	} else {
+		entry.Status = storage.QuarantineStatusPending
+		entry.LastError = err.Error()
+	}
+	if saveErr := dw.taskStore.SaveQuarantineEntry(entry); saveErr != nil {
+		dw.logger.WithField("task_id", task.ID).WithError(saveErr).Error("Failed to track flagged file in quarantine queue")
+	}
+
+	if err != nil {
+		dw.securityAudit.LogQuarantineEvent(
+			task.ID,
+			task.FileName,
+			fileHash,
+			fmt.Sprintf("Failed to quarantine file, queued for retry from %s. Threat level: %s", sourcePath, result.ThreatLevel.String()),
+			task.UserID,
+		)
+		dw.logger.WithField("task_id", task.ID).
+			WithField("source_path", sourcePath).
+			WithError(err).
+			Error("Failed to quarantine flagged file, queued for retry")
+		return fmt.Errorf("file rejected due to security threats: %s", result.ThreatLevel.String())
+	}
+
+	dw.securityAudit.LogQuarantineEvent(task.ID, task.FileName, fileHash, reason, task.UserID)
+	dw.logger.WithField("task_id", task.ID).
+		WithField("quarantine_path", quarantinePath).
+		WithField("threat_level", result.ThreatLevel.String()).
+		Warn("File quarantined due to security threats")
+	return fmt.Errorf("file quarantined due to security threats: %s", result.ThreatLevel.String())
+}
+
+// RunQuarantineRetry retries queued quarantine moves until ctx is cancelled
+func (dw *DownloadWorker) RunQuarantineRetry(ctx context.Context) error {
+	ticker := time.NewTicker(dw.config.QuarantineRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		utils.Heartbeat(ctx)
+		dw.retryQuarantine()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// retryQuarantine makes one attempt at each pending quarantine move
+func (dw *DownloadWorker) retryQuarantine() {
+	entries, err := dw.taskStore.GetQuarantineEntries(storage.QuarantineStatusPending)
+	if err != nil {
+		dw.logger.WithError(err).Warn("Failed to load quarantine queue")
+		return
+	}
+
+	for _, entry := range entries {
+		logger := dw.logger.WithField("task_id", entry.TaskID).WithField("source_path", entry.SourcePath)
+
+		if _, err := os.Stat(entry.SourcePath); errors.Is(err, os.ErrNotExist) {
+			entry.Status = storage.QuarantineStatusMissing
+			entry.LastError = "source file no longer exists"
+			logger.Error("Flagged file disappeared before it could be quarantined")
+			dw.securityAudit.LogQuarantineEvent(entry.TaskID, entry.FileName, entry.FileHash,
+				fmt.Sprintf("Flagged file missing from %s before quarantine", entry.SourcePath), entry.UserID)
+		} else if quarantinePath, err := dw.quarantineFile(entry); err == nil {
+			entry.Attempts++
+			entry.Status = storage.QuarantineStatusQuarantined
+			entry.QuarantinePath = quarantinePath
+			entry.LastError = ""
+			logger.WithField("quarantine_path", quarantinePath).
+				WithField("attempts", entry.Attempts).
+				Warn("Flagged file quarantined on retry")
+			dw.securityAudit.LogQuarantineEvent(entry.TaskID, entry.FileName, entry.FileHash,
+				fmt.Sprintf("%s (quarantined after %d attempts)", entry.Reason, entry.Attempts), entry.UserID)
+		} else {
+			entry.Attempts++
+			entry.LastError = err.Error()
+			if entry.Attempts >= dw.config.QuarantineMaxAttempts {
+				entry.Status = storage.QuarantineStatusFailed
+				logger.WithField("attempts", entry.Attempts).
+					WithError(err).
+					Error("Giving up quarantining flagged file, manual action required")
+				dw.securityAudit.LogQuarantineEvent(entry.TaskID, entry.FileName, entry.FileHash,
+					fmt.Sprintf("Quarantine failed after %d attempts, file remains at %s", entry.Attempts, entry.SourcePath), entry.UserID)
+			} else {
+				logger.WithField("attempts", entry.Attempts).WithError(err).Warn("Quarantine retry failed")
+			}
+		}
+
+		if err := dw.taskStore.SaveQuarantineEntry(entry); err != nil {
+			logger.WithError(err).Error("Failed to update quarantine queue entry")
+		}
+	}
+}
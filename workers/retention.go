@@ -0,0 +1,370 @@
+package workers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"telegram-archive-bot/app/extraction"
+	"telegram-archive-bot/storage"
+	"telegram-archive-bot/utils"
+)
+
+const (
+	// retentionTaskBatchSize caps the tasks deleted per transaction
+	retentionTaskBatchSize = 500
+	// certificateItemLimit caps the items listed in a deletion certificate;
+	// the manifest hash and count always cover all of them
+	certificateItemLimit = 500
+)
+
+// retentionOutputDirs hold the files of the output_files category
+var retentionOutputDirs = []string{
+	"app/extraction/files/Sorted_toshare",
+	"app/extraction/files/bettings",
+	"app/extraction/files/backups",
+}
+
+// retentionConversionInputsDir holds a directory per conversion pass whose
+// input was kept, the conversion_inputs category
+const retentionConversionInputsDir = "app/extraction/files/conversion_inputs"
+
+// conversionInputLayout is the time layout the kept passes are named with
+const conversionInputLayout = "20060102-150405.000"
+
+// Deletion methods recorded in certificates
+const (
+	deletionMethodOverwrite = "overwrite_3_pass" // Zeros, ones and random data, synced, then unlinked
+	deletionMethodRowDelete = "row_delete"
+)
+
+// RetentionStore is what the retention manager deletes from the task store
+type RetentionStore interface {
+	PurgeExpiredTasks(cutoff time.Time, limit int) (*storage.ExpiredTaskPurge, error)
+	ClearConversionInput(dir string) error
+}
+
+// DeletedItem is one file, task or batch of rows a retention run deleted
+type DeletedItem struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size,omitempty"`   // Bytes, for files
+	SHA256 string `json:"sha256,omitempty"` // Content hash before deletion, for files
+	Rows   int64  `json:"rows,omitempty"`   // Deleted rows, for database items
+}
+
+// DeletionCertificate records what one retention run deleted for a category,
+// how, and under which policy. The manifest hash covers every item, so a
+// certificate listing only the first items still pins down the whole set
+type DeletionCertificate struct {
+	ID             string           `json:"id"`
+	Category       string           `json:"category"`
+	Policy         string           `json:"policy"`
+	Cutoff         time.Time        `json:"cutoff"`
+	Method         string           `json:"method"`
+	ItemCount      int64            `json:"item_count"`
+	Items          []DeletedItem    `json:"items"`
+	ItemsTruncated bool             `json:"items_truncated,omitempty"`
+	ManifestSHA256 string           `json:"manifest_sha256"`
+	Tables         map[string]int64 `json:"tables,omitempty"` // Deleted rows per table, for task metadata
+	StartedAt      time.Time        `json:"started_at"`
+	CompletedAt    time.Time        `json:"completed_at"`
+}
+
+// add records a deleted item in the certificate and its manifest
+func (c *DeletionCertificate) add(manifest *certificateManifest, item DeletedItem) {
+	manifest.add(item)
+	c.ItemCount++
+	if len(c.Items) < certificateItemLimit {
+		c.Items = append(c.Items, item)
+	} else {
+		c.ItemsTruncated = true
+	}
+}
+
+// certificateManifest hashes the items of a certificate, one line per item
+type certificateManifest struct {
+	hash hash.Hash
+}
+
+func newCertificateManifest() *certificateManifest {
+	return &certificateManifest{hash: sha256.New()}
+}
+
+func (m *certificateManifest) add(item DeletedItem) {
+	fmt.Fprintf(m.hash, "%s\t%d\t%s\t%d\n", item.Name, item.Size, item.SHA256, item.Rows)
+}
+
+func (m *certificateManifest) sum() string {
+	return hex.EncodeToString(m.hash.Sum(nil))
+}
+
+// RetentionManager deletes data older than its category's retention policy
+// and leaves a deletion certificate in the admin audit log for each run that
+// deleted something
+type RetentionManager struct {
+	store    RetentionStore
+	audit    *storage.AdminAuditLogger
+	logger   *utils.Logger
+	policies []utils.RetentionPolicy
+	interval time.Duration
+	ioTuning utils.IOTuning
+}
+
+// NewRetentionManager creates a retention manager enforcing policies every interval
+func NewRetentionManager(store RetentionStore, audit *storage.AdminAuditLogger, logger *utils.Logger, policies []utils.RetentionPolicy, interval time.Duration, ioTuning utils.IOTuning) *RetentionManager {
+	return &RetentionManager{
+		store:    store,
+		audit:    audit,
+		logger:   logger,
+		policies: policies,
+		interval: interval,
+		ioTuning: ioTuning,
+	}
+}
+
+// Run enforces the policies every interval until ctx is cancelled
+func (rm *RetentionManager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(rm.interval)
+	defer ticker.Stop()
+
+	for {
+		utils.Heartbeat(ctx)
+		rm.Enforce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Enforce applies every policy once and returns the certificates of the
+// categories where something was deleted
+func (rm *RetentionManager) Enforce(ctx context.Context) []*DeletionCertificate {
+	var certificates []*DeletionCertificate
+	for _, policy := range rm.policies {
+		if ctx.Err() != nil {
+			break
+		}
+		utils.Heartbeat(ctx)
+
+		certificate := &DeletionCertificate{
+			ID:        uuid.New().String(),
+			Category:  policy.Category,
+			Policy:    policy.String(),
+			StartedAt: time.Now(),
+		}
+		certificate.Cutoff = certificate.StartedAt.Add(-policy.MaxAge)
+		manifest := newCertificateManifest()
+
+		var err error
+		switch policy.Category {
+		case utils.RetentionOutputFiles:
+			certificate.Method = deletionMethodOverwrite
+			err = rm.purgeOutputFiles(ctx, certificate, manifest)
+		case utils.RetentionStoreLines:
+			certificate.Method = deletionMethodRowDelete
+			err = rm.purgeStoreLines(ctx, certificate, manifest)
+		case utils.RetentionTaskMetadata:
+			certificate.Method = deletionMethodRowDelete
+			err = rm.purgeTaskMetadata(certificate, manifest)
+		case utils.RetentionConversionInputs:
+			certificate.Method = deletionMethodOverwrite
+			err = rm.purgeConversionInputs(ctx, certificate, manifest)
+		}
+		certificate.ManifestSHA256 = manifest.sum()
+		certificate.CompletedAt = time.Now()
+
+		logger := rm.logger.WithField("category", policy.Category).WithField("policy", certificate.Policy)
+		if err != nil {
+			logger.WithError(err).Warn("Retention policy not fully enforced, retrying on the next run")
+		}
+		if certificate.ItemCount == 0 && err == nil {
+			continue
+		}
+
+		logger.WithField("certificate_id", certificate.ID).
+			WithField("items", certificate.ItemCount).
+			Info("Expired data deleted")
+		rm.audit.LogSystemAction(0, "system", storage.AdminActionRetentionPurge, certificate.ID,
+			map[string]interface{}{"certificate": certificate}, "SUCCESS", err)
+		if certificate.ItemCount > 0 {
+			certificates = append(certificates, certificate)
+		}
+	}
+	return certificates
+}
+
+// purgeOutputFiles securely deletes output files last modified before the cutoff
+func (rm *RetentionManager) purgeOutputFiles(ctx context.Context, certificate *DeletionCertificate, manifest *certificateManifest) error {
+	var errs []error
+	for _, dir := range retentionOutputDirs {
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				errs = append(errs, err)
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil || !info.ModTime().Before(certificate.Cutoff) {
+				return nil
+			}
+
+			// Hashing and overwriting large files takes a while
+			utils.Heartbeat(ctx)
+			fileHash, _, err := utils.HashFileTuned(path, rm.ioTuning)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				return nil
+			}
+			if err := utils.SecureDelete(path); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				return nil
+			}
+			certificate.add(manifest, DeletedItem{Name: path, Size: info.Size(), SHA256: fileHash})
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// purgeStoreLines deletes store database lines entered before the cutoff
+func (rm *RetentionManager) purgeStoreLines(ctx context.Context, certificate *DeletionCertificate, manifest *certificateManifest) error {
+	logFunc := func(format string, args ...interface{}) {
+		rm.logger.Debugf(format, args...)
+	}
+	lines, err := extraction.PurgeStoreLines(ctx, certificate.Cutoff, logFunc)
+	if lines > 0 {
+		certificate.add(manifest, DeletedItem{
+			Name: "lines entered before " + certificate.Cutoff.Format("2006-01-02"),
+			Rows: lines,
+		})
+	}
+	return err
+}
+
+// purgeTaskMetadata deletes finished tasks completed before the cutoff, in
+// batches until none are left
+func (rm *RetentionManager) purgeTaskMetadata(certificate *DeletionCertificate, manifest *certificateManifest) error {
+	for {
+		purge, err := rm.store.PurgeExpiredTasks(certificate.Cutoff, retentionTaskBatchSize)
+		if err != nil {
+			return err
+		}
+		if certificate.Tables == nil {
+			certificate.Tables = make(map[string]int64)
+		}
+		for table, rows := range purge.Rows {
+			if rows > 0 {
+				certificate.Tables[table] += rows
+			}
+		}
+		for _, taskID := range purge.TaskIDs {
+			certificate.add(manifest, DeletedItem{Name: "task " + taskID})
+		}
+		if len(purge.TaskIDs) < retentionTaskBatchSize {
+			return nil
+		}
+	}
+}
+
+// purgeConversionInputs securely deletes the conversion inputs kept before
+// the cutoff, a pass at a time, so a reprocess campaign no longer finds them.
+// A file still linked from elsewhere, e.g. a pass being converted, is only
+// unlinked so the other copy is not overwritten
+func (rm *RetentionManager) purgeConversionInputs(ctx context.Context, certificate *DeletionCertificate, manifest *certificateManifest) error {
+	passes, err := os.ReadDir(retentionConversionInputsDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, pass := range passes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !pass.IsDir() {
+			continue
+		}
+		// Passes are named after when they were kept; removing files from a
+		// directory updates its modification time, which would put off a retry
+		keptAt, err := time.ParseInLocation(conversionInputLayout, pass.Name(), time.Local)
+		if err != nil {
+			info, err := pass.Info()
+			if err != nil {
+				continue
+			}
+			keptAt = info.ModTime()
+		}
+		if !keptAt.Before(certificate.Cutoff) {
+			continue
+		}
+
+		dir := filepath.Join(retentionConversionInputsDir, pass.Name())
+		// Forget the input first so a campaign never picks up half of it
+		if err := rm.store.ClearConversionInput(dir); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, file := range files {
+			path := filepath.Join(dir, file.Name())
+			info, err := file.Info()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+
+			utils.Heartbeat(ctx)
+			fileHash, _, err := utils.HashFileTuned(path, rm.ioTuning)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+			if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Nlink > 1 {
+				err = os.Remove(path)
+			} else {
+				err = utils.SecureDelete(path)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+			certificate.add(manifest, DeletedItem{Name: path, Size: info.Size(), SHA256: fileHash})
+		}
+		if err := os.Remove(dir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}